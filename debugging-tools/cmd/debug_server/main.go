@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
 
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
@@ -12,7 +15,7 @@ import (
 
 func main() {
 	fmt.Println("Debug: Checking which server is running...")
-	
+
 	// Create HTTP transport
 	httpTransport, err := transport.NewStreamableHTTP("http://localhost:8080/mcp")
 	if err != nil {
@@ -30,6 +33,15 @@ func main() {
 		log.Fatalf("Failed to start client: %v", err)
 	}
 
+	if err := inspect(ctx, mcpClient, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inspect initializes an MCP session on a started client and writes the
+// full negotiation to w: protocol versions, both sides' capabilities and
+// every tool with its input schema.
+func inspect(ctx context.Context, mcpClient *client.Client, w io.Writer) error {
 	// Initialize the MCP session
 	initRequest := mcp.InitializeRequest{
 		Params: mcp.InitializeParams{
@@ -44,22 +56,42 @@ func main() {
 
 	initResponse, err := mcpClient.Initialize(ctx, initRequest)
 	if err != nil {
-		log.Fatalf("Failed to initialize MCP session: %v", err)
+		return fmt.Errorf("Failed to initialize MCP session: %v", err)
 	}
 
-	fmt.Printf("Connected to: %s v%s\n", initResponse.ServerInfo.Name, initResponse.ServerInfo.Version)
-	
+	fmt.Fprintf(w, "Connected to: %s v%s\n", initResponse.ServerInfo.Name, initResponse.ServerInfo.Version)
+	fmt.Fprintf(w, "Protocol version: %s (requested %s)\n", initResponse.ProtocolVersion, initRequest.Params.ProtocolVersion)
+	if initResponse.ProtocolVersion != initRequest.Params.ProtocolVersion {
+		fmt.Fprintln(w, "⚠️  The server negotiated a different protocol version than requested")
+	}
+	if initResponse.Instructions != "" {
+		fmt.Fprintf(w, "Instructions: %s\n", initResponse.Instructions)
+	}
+
+	printJSON(w, "", "Server capabilities", initResponse.Capabilities)
+	printJSON(w, "", "Client capabilities (as sent)", initRequest.Params.Capabilities)
+
 	// List tools to see which server is running
 	toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
 	if err != nil {
-		log.Fatalf("Failed to list tools: %v", err)
+		return fmt.Errorf("Failed to list tools: %v", err)
 	}
 
-	fmt.Println("Available tools:")
+	fmt.Fprintf(w, "\nAvailable tools (%d):\n", len(toolsResult.Tools))
 	for _, tool := range toolsResult.Tools {
-		fmt.Printf("- %s: %s\n", tool.Name, tool.Description)
+		fmt.Fprintf(w, "- %s: %s\n", tool.Name, tool.Description)
+		printJSON(w, "  ", "Input schema", tool.InputSchema)
 	}
+	return nil
+}
 
-	// Check if server has sampling capability
-	fmt.Printf("Server capabilities: %+v\n", initResponse.Capabilities)
-}
\ No newline at end of file
+// printJSON writes v to w as indented JSON under a label, so nested capability
+// structs and schemas are shown in full. Every line starts with indent.
+func printJSON(w io.Writer, indent, label string, v any) {
+	data, err := json.MarshalIndent(v, indent+"  ", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "%s%s: (cannot encode: %v)\n", indent, label, err)
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n%s  %s\n", indent, label, indent, data)
+}
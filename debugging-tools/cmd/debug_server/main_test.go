@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hardwaylabs/learn-mcp-sampling/mcp-implementations/analysis"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestInspectDumpsNegotiation(t *testing.T) {
+	s := analysis.New(analysis.Config{FilesDir: t.TempDir(), PartialsDir: t.TempDir()})
+	c, err := client.NewInProcessClient(s.MCPServer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := inspect(ctx, c, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	text := out.String()
+	for _, want := range []string{
+		"Connected to: enhanced-sampling-server v1.0.0",
+		"Protocol version: " + mcp.LATEST_PROTOCOL_VERSION + " (requested " + mcp.LATEST_PROTOCOL_VERSION + ")",
+		"Server capabilities:\n  {\n",
+		`"tools": {`,
+		"Client capabilities (as sent):\n",
+		"- analyze_file: ",
+		"  Input schema:\n    {\n",
+		`"filename": {`,
+		`"required": [`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output is missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "negotiated a different protocol version") {
+		t.Errorf("warned about a version mismatch that did not happen:\n%s", text)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hardwaylabs/learn-mcp-sampling/mcp-implementations/analysis"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const mockResponse = "SELFTEST RESPONSE: the sampling round trip works."
+
+func main() {
+	fmt.Println("MCP Sampling Self-Test")
+	fmt.Println("======================")
+	fmt.Println("Runs the enhanced server and a mock sampling client in one process.")
+	fmt.Println("No network or API key is needed.")
+	fmt.Println("")
+
+	if err := run(); err != nil {
+		fmt.Printf("❌ Self-test failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("\n🎉 SUCCESS: analyze_file completed a full sampling round trip")
+}
+
+func run() error {
+	// Stage a file in a throwaway files directory
+	filesDir, err := os.MkdirTemp("", "mcp-selftest-")
+	if err != nil {
+		return fmt.Errorf("could not create temp files directory: %w", err)
+	}
+	defer os.RemoveAll(filesDir)
+
+	if err := os.WriteFile(filepath.Join(filesDir, "selftest.md"), []byte("# Self-test\n\nThis file exists only for the self-test."), 0644); err != nil {
+		return fmt.Errorf("could not stage test file: %w", err)
+	}
+	fmt.Printf("✅ Staged selftest.md in %s\n", filesDir)
+
+	// Create the real analysis server and connect an in-process client to it
+	analysisServer := analysis.New(analysis.Config{FilesDir: filesDir})
+
+	handler := &MockSamplingHandler{}
+	mcpClient, err := client.NewInProcessClientWithSamplingHandler(analysisServer.MCPServer(), handler)
+	if err != nil {
+		return fmt.Errorf("could not create in-process client: %w", err)
+	}
+	defer mcpClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := mcpClient.Start(ctx); err != nil {
+		return fmt.Errorf("could not start client: %w", err)
+	}
+
+	initRequest := mcp.InitializeRequest{
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			Capabilities:    mcp.ClientCapabilities{},
+			ClientInfo: mcp.Implementation{
+				Name:    "selftest-client",
+				Version: "1.0.0",
+			},
+		},
+	}
+
+	initResponse, err := mcpClient.Initialize(ctx, initRequest)
+	if err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+	fmt.Printf("✅ Connected to: %s v%s\n", initResponse.ServerInfo.Name, initResponse.ServerInfo.Version)
+
+	// The server should have recorded this client as able to sample
+	clients := analysisServer.Clients()
+	if len(clients) != 1 || !clients[0].Sampling {
+		return fmt.Errorf("expected one sampling-capable client in the registry, got %+v", clients)
+	}
+	fmt.Printf("✅ Server registered %s with sampling support\n", clients[0].Name)
+
+	// Run analyze_file, which must go through the mock sampling handler
+	result, err := mcpClient.CallTool(ctx, mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name: "analyze_file",
+			Arguments: map[string]any{
+				"filename":      "selftest.md",
+				"analysis_type": "summarize",
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("analyze_file call failed: %w", err)
+	}
+
+	text := ""
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			text = textContent.Text
+		}
+	}
+
+	if result.IsError {
+		return fmt.Errorf("analyze_file returned an error result: %s", text)
+	}
+	if handler.calls.Load() != 1 {
+		return fmt.Errorf("expected 1 sampling request, the handler saw %d", handler.calls.Load())
+	}
+	if !strings.Contains(text, mockResponse) {
+		return fmt.Errorf("analysis result does not contain the sampled response:\n%s", text)
+	}
+
+	fmt.Println("✅ analyze_file returned the sampled response")
+	return nil
+}
+
+// MockSamplingHandler answers every sampling request with a fixed response
+// and counts how many requests it has seen.
+type MockSamplingHandler struct {
+	calls atomic.Int32
+}
+
+func (h *MockSamplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	h.calls.Add(1)
+
+	if len(request.Messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role: mcp.RoleAssistant,
+			Content: mcp.TextContent{
+				Type: "text",
+				Text: mockResponse,
+			},
+		},
+		Model:      "selftest-mock-model",
+		StopReason: "endTurn",
+	}, nil
+}
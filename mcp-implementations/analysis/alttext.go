@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultAltTextLength is the alt text limit, in characters, when a call
+// does not set max_length. Screen readers handle longer text, but 125 is
+// the common accessibility guideline.
+const DefaultAltTextLength = 125
+
+var generateAltTextTool = mcp.Tool{
+	Name:        "generate_alt_text",
+	Description: "Write accessible alt text for an image file using LLM sampling, within a character limit, together with a longer description",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The image to describe (relative to files directory)",
+			},
+			"max_length": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Most characters of alt text (default %d)", DefaultAltTextLength),
+			},
+			"context": map[string]any{
+				"type":        "string",
+				"description": "Where the image appears, e.g. \"product page for a hiking boot\", so the alt text says what matters there (optional)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// AltText is the structured result of generate_alt_text.
+type AltText struct {
+	File        string `json:"file"`
+	Model       string `json:"model"`
+	AltText     string `json:"alt_text"`
+	Length      int    `json:"length"`
+	MaxLength   int    `json:"max_length"`
+	Description string `json:"description"`
+}
+
+func (s *Server) handleGenerateAltText(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	maxLength := request.GetInt("max_length", DefaultAltTextLength)
+	if maxLength < 10 {
+		return errorResult("max_length must be at least 10 characters"), nil
+	}
+	usage := request.GetString("context", "")
+
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	mimeType := mimeTypeFor(filename)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return errorResult("%s is not an image (%s)", filename, mimeType), nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return errorResult("Error reading file: %v", err), nil
+	}
+	data, mimeType, err = s.fitImageFile(ctx, filename, data, mimeType, 0)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	// Images never reach the binary policy, so there is no error
+	content, _, _ := buildContent(filename, mimeType, data, "", s.cfg.BinaryPolicy)
+	systemPrompt := fmt.Sprintf("Write alt text for this image for people using screen readers. "+
+		"The alt text conveys what the image shows and why it matters in at most %d characters: "+
+		"no \"image of\" or \"picture of\", no guesses presented as fact, and any text in the image quoted if it is important. "+
+		"Also write a longer description of a few sentences covering layout, colors, people and text. "+
+		`Respond with only a JSON object: {"alt_text": "<alt text>", "description": "<longer description>"}.`, maxLength)
+	if usage != "" {
+		systemPrompt += fmt.Sprintf(" The image appears in this context: %s.", usage)
+	}
+
+	var answer AltText
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0.2
+	samplingRequest.MaxTokens = 800
+
+	logf(ctx, "📤 Sending sampling request for alt text: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid alt text", func(text string) (err error) {
+		answer.AltText, answer.Description, err = parseAltText(text, maxLength)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	answer.Model = result.Model
+
+	answer.File, answer.MaxLength = filename, maxLength
+	answer.Length = utf8.RuneCountInString(answer.AltText)
+	logf(ctx, "✅ Wrote alt text for %s (%d characters)", filename, answer.Length)
+
+	data, err = json.MarshalIndent(answer, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding alt text: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseAltText decodes the model's alt text and description, requiring
+// both and the alt text to fit in maxLength characters.
+func parseAltText(text string, maxLength int) (string, string, error) {
+	var answer struct {
+		AltText     string `json:"alt_text"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return "", "", fmt.Errorf("not valid JSON: %v", err)
+	}
+	altText := strings.TrimSpace(answer.AltText)
+	if altText == "" {
+		return "", "", fmt.Errorf("the alt text is empty")
+	}
+	if n := utf8.RuneCountInString(altText); n > maxLength {
+		return "", "", fmt.Errorf("the alt text is %d characters, over the limit of %d", n, maxLength)
+	}
+	if strings.TrimSpace(answer.Description) == "" {
+		return "", "", fmt.Errorf("the description is empty")
+	}
+	return altText, strings.TrimSpace(answer.Description), nil
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// altTextAnswer is a model answer with the given alt text.
+func altTextAnswer(altText string) string {
+	data, _ := json.Marshal(map[string]string{"alt_text": altText, "description": "A red boot on a rock, laces undone, mountains behind."})
+	return string(data)
+}
+
+func TestGenerateAltTextEnforcesLength(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"boot.png": pngFixture(t, 20, 20, false)})
+	sampler := &mockSampler{respond: answers(
+		altTextAnswer(strings.Repeat("A very detailed red hiking boot ", 3)),
+		altTextAnswer("Red hiking boot on a rock"),
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_alt_text", map[string]any{"filename": "boot.png", "max_length": 40, "context": "product page"})
+
+	var got AltText
+	if err := json.Unmarshal([]byte(text), &got); err != nil {
+		t.Fatalf("result is not alt text: %v\n%s", err, text)
+	}
+	if got.AltText != "Red hiking boot on a rock" || got.Length != 25 || got.MaxLength != 40 || got.Description == "" {
+		t.Errorf("unexpected alt text: %+v", got)
+	}
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if _, ok := requests[0].Messages[0].Content.(mcp.ImageContent); !ok {
+		t.Errorf("the image was not sent: %T", requests[0].Messages[0].Content)
+	}
+	for _, want := range []string{"in at most 40 characters", "The image appears in this context: product page."} {
+		if !strings.Contains(requests[0].SystemPrompt, want) {
+			t.Errorf("system prompt is missing %q: %q", want, requests[0].SystemPrompt)
+		}
+	}
+	// The trailing space of the first answer is trimmed before it is counted
+	if !strings.Contains(requests[1].SystemPrompt, "the alt text is 95 characters, over the limit of 40") {
+		t.Errorf("reprompt does not give the length: %q", requests[1].SystemPrompt)
+	}
+}
+
+func TestGenerateAltTextFailsWhenStillTooLong(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"boot.png": pngFixture(t, 20, 20, false)})
+	c := connect(t, s, &mockSampler{respond: answers(altTextAnswer(strings.Repeat("x", DefaultAltTextLength+1)))})
+
+	text := mustFail(t, c, "generate_alt_text", map[string]any{"filename": "boot.png"})
+	if !strings.Contains(text, fmt.Sprintf("did not return valid alt text after a retry: the alt text is %d characters, over the limit of %d", DefaultAltTextLength+1, DefaultAltTextLength)) {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestGenerateAltTextCountsCharactersNotBytes(t *testing.T) {
+	altText, _, err := parseAltText(altTextAnswer("Café crème on a zinc bar"), 24)
+	if err != nil || altText != "Café crème on a zinc bar" {
+		t.Errorf("parseAltText() = %q, %v; 24 characters should fit a limit of 24", altText, err)
+	}
+}
+
+func TestGenerateAltTextValidatesInput(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"boot.png": pngFixture(t, 20, 20, false), "notes.txt": "text"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "generate_alt_text", map[string]any{"filename": "notes.txt"}); !strings.Contains(text, "notes.txt is not an image") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "generate_alt_text", map[string]any{"filename": "boot.png", "max_length": 5}); !strings.Contains(text, "max_length must be at least 10") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid input sent %d sampling requests", n)
+	}
+}
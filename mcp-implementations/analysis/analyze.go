@@ -0,0 +1,786 @@
+package analysis
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var analyzeFileTool = mcp.Tool{
+	Name:        "analyze_file",
+	Description: "Analyze a file from the local directory using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The name of the file to analyze (relative to files directory)",
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis to perform",
+				"enum":        AnalysisTypeNames(),
+			},
+			"custom_prompt": map[string]any{
+				"type":        "string",
+				"description": "Optional custom prompt for the analysis",
+			},
+			"debug_raw": map[string]any{
+				"type":        "boolean",
+				"description": "Include the raw provider response (secrets redacted) in the result metadata",
+			},
+			"resume": map[string]any{
+				"type":        "boolean",
+				"description": "For long files analyzed in chunks, reuse chunks completed by an earlier interrupted call (default true)",
+			},
+			"multi_length": map[string]any{
+				"type":        "boolean",
+				"description": "For summarize: return a one-line TL;DR, a paragraph summary and bullet key points in one call",
+			},
+			"with_neighbors": map[string]any{
+				"type":        "boolean",
+				"description": fmt.Sprintf("Name up to %d other files in the same directory in the prompt, so the model can place the file in context. Local files directories only (default false)", MaxNeighbors),
+			},
+			"neighbor_summaries": map[string]any{
+				"type":        "boolean",
+				"description": "With with_neighbors: add the start of each neighbor's cached summary, if it has one; nothing extra is sampled (default false)",
+			},
+			"include_outputs": map[string]any{
+				"type":        "boolean",
+				"description": "For Jupyter notebooks: include the text of code cell outputs (default false)",
+			},
+			"image_max_dimension": map[string]any{
+				"type":        "integer",
+				"description": "For images: downscale so the long edge is at most this many pixels (default: the server's provider limit)",
+			},
+			"target_length": map[string]any{
+				"type":        "string",
+				"description": "For summarize: the summary's length, e.g. \"50 words\" or \"3 sentences\"; an answer more than twice as long or under half is reprompted once",
+			},
+			"with_citations": map[string]any{
+				"type":        "boolean",
+				"description": "Ask for quotes from the source backing each claim; quotes not found in the file are flagged as unverified (text files only)",
+			},
+			"map_prompt": map[string]any{
+				"type":        "string",
+				"description": "For long files analyzed in chunks: the instruction for each chunk (default: the analysis prompt)",
+			},
+			"reduce_prompt": map[string]any{
+				"type":        "string",
+				"description": "For long files analyzed in chunks: how to combine the chunk results (default: merge into one answer without repetition)",
+			},
+			"chunk_mode": map[string]any{
+				"type":        "string",
+				"description": "For long files: map_reduce (default) samples each chunk then combines them; window slides an overlapping window over the text, carrying running notes from one window to the next",
+				"enum":        chunkModes,
+			},
+			"window_overlap": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("With chunk_mode window: bytes of each window repeated at the start of the next (default %d)", DefaultWindowOverlap),
+			},
+			"extract_section": map[string]any{
+				"type":        "string",
+				"description": "Analyze only part of a text or source file: its YAML frontmatter, its code comments, or its body after any frontmatter",
+				"enum":        extractSections,
+			},
+			"byte_offset": map[string]any{
+				"type":        "integer",
+				"description": "Analyze only a window of the file starting at this byte offset (for very large logs)",
+			},
+			"byte_length": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Size of the window in bytes (default: the chunk size, max %d)", MaxWindowBytes),
+			},
+			"temperature": map[string]any{
+				"type":        "number",
+				"description": "Sampling temperature, overriding the analysis type's default (use 0 for reproducible output)",
+			},
+			"seed": map[string]any{
+				"type":        "integer",
+				"description": "Sampling seed for reproducible output; forwarded to providers that support one (best effort)",
+			},
+			"model": map[string]any{
+				"type":        "string",
+				"description": "Model alias such as \"fast\" or \"smart\", resolved by the sampling client (default: the client's model)",
+			},
+			"audience": audienceProperty,
+			"language": map[string]any{
+				"type":        "string",
+				"description": "Language to write the answer in, as an ISO 639-1 code such as \"fr\" or a name such as \"French\"",
+			},
+			"auto_language": map[string]any{
+				"type":        "boolean",
+				"description": "Without language: detect a text file's language and answer in it (default: the server's -auto-language setting)",
+			},
+			"redact": redactProperty,
+			"tools": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Server tools the model may call while analyzing (e.g. list_files); not tools that sample themselves",
+			},
+			"force": map[string]any{
+				"type":        "boolean",
+				"description": "Analyze the file even if it is smaller than the server's minimum file size",
+			},
+			"use_cache": map[string]any{
+				"type":        "boolean",
+				"description": "Return a cached result for the same file content and arguments when there is one (default true)",
+			},
+			"with_timings": map[string]any{
+				"type":        "boolean",
+				"description": "Add how long reading the file, building the prompt and sampling took to the result",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// analyzeOptions holds the per-file analyze_file arguments, so tools that
+// analyze several files can run the same pipeline.
+type analyzeOptions struct {
+	Filename     string
+	AnalysisType string
+	CustomPrompt string
+	DebugRaw     bool
+	Resume       bool
+	MultiLength  bool
+	// WithNeighbors lists the other files in the file's directory in the
+	// prompt, with their cached summaries when NeighborSummaries is set
+	WithNeighbors     bool
+	NeighborSummaries bool
+	// IncludeOutputs adds code cell outputs to a notebook's content
+	IncludeOutputs bool
+	// ImageMaxDimension lowers the server's image size limit for this call
+	ImageMaxDimension int
+	// TargetLength is a summary length such as "50 words" or "3 sentences"
+	TargetLength string
+	// WithCitations asks for supporting quotes, verified against the source
+	WithCitations bool
+	// MapPrompt and ReducePrompt override the two phases of chunked analysis
+	MapPrompt    string
+	ReducePrompt string
+	// ChunkMode picks how long text is chunked: map_reduce or window.
+	// WindowOverlap is nil unless the caller set it.
+	ChunkMode     string
+	WindowOverlap *int
+	// ExtractSection narrows the file to one section before sampling
+	ExtractSection string
+	// Window, when set, limits the analysis to a byte range of the file
+	Window *byteWindow
+	// Temperature and Seed are nil unless the caller set them
+	Temperature *float64
+	Seed        *int
+	// Model is a model alias passed to the client as a hint
+	Model string
+	// Audience pitches the answer at a reader, e.g. "child" or "expert"
+	Audience string
+	// Language is the language of the answer. Without it, AutoLanguage
+	// answers in the file's language; nil means Config.AutoLanguage.
+	Language     string
+	AutoLanguage *bool
+	// Redact masks PII in the result: "", "patterns" or "model"
+	Redact string
+	// Force analyzes files below Config.MinFileBytes
+	Force bool
+	// Tools are server tools the model may call while answering
+	Tools []string
+	// UseCache allows answering from the result cache. It is not part of
+	// the cache key.
+	UseCache bool `json:"-"`
+	// WithTimings adds a breakdown of where the time went to the result
+	WithTimings bool `json:"-"`
+}
+
+// analyzeOptionsFrom reads the analysis arguments shared by analyze_file
+// and the batch tools. The filename is left to the caller, and a missing
+// analysis_type is defaultType.
+func analyzeOptionsFrom(request mcp.CallToolRequest, defaultType string) analyzeOptions {
+	opts := analyzeOptions{
+		AnalysisType: request.GetString("analysis_type", defaultType),
+		CustomPrompt: request.GetString("custom_prompt", ""),
+		DebugRaw:     request.GetBool("debug_raw", false),
+		Resume:       request.GetBool("resume", true),
+		MultiLength:  request.GetBool("multi_length", false),
+	}
+	opts.WithCitations = request.GetBool("with_citations", false)
+	opts.TargetLength = request.GetString("target_length", "")
+	opts.IncludeOutputs = request.GetBool("include_outputs", false)
+	opts.WithNeighbors = request.GetBool("with_neighbors", false)
+	opts.NeighborSummaries = request.GetBool("neighbor_summaries", false)
+	opts.ImageMaxDimension = request.GetInt("image_max_dimension", 0)
+	opts.MapPrompt = request.GetString("map_prompt", "")
+	opts.ReducePrompt = request.GetString("reduce_prompt", "")
+	opts.ChunkMode = request.GetString("chunk_mode", "")
+	opts.ExtractSection = request.GetString("extract_section", "")
+	opts.Model = request.GetString("model", "")
+	opts.Audience = request.GetString("audience", DefaultAudience)
+	opts.Language = request.GetString("language", "")
+	opts.Redact = request.GetString("redact", "")
+	opts.UseCache = request.GetBool("use_cache", true)
+	opts.WithTimings = request.GetBool("with_timings", false)
+	opts.Tools = request.GetStringSlice("tools", nil)
+	opts.Force = request.GetBool("force", false)
+
+	args := request.GetArguments()
+	_, hasOffset := args["byte_offset"]
+	_, hasLength := args["byte_length"]
+	if hasOffset || hasLength {
+		opts.Window = &byteWindow{
+			Offset: int64(request.GetInt("byte_offset", 0)),
+			Length: int64(request.GetInt("byte_length", 0)),
+		}
+	}
+
+	if _, ok := args["temperature"]; ok {
+		temperature := request.GetFloat("temperature", 0)
+		opts.Temperature = &temperature
+	}
+	if _, ok := args["auto_language"]; ok {
+		auto := request.GetBool("auto_language", false)
+		opts.AutoLanguage = &auto
+	}
+	if _, ok := args["window_overlap"]; ok {
+		overlap := request.GetInt("window_overlap", 0)
+		opts.WindowOverlap = &overlap
+	}
+	if _, ok := args["seed"]; ok {
+		seed := request.GetInt("seed", 0)
+		opts.Seed = &seed
+	}
+	return opts
+}
+
+// applyTo sets the sampling parameters chosen by the options on request.
+func (opts analyzeOptions) applyTo(request *mcp.CreateMessageRequest) {
+	if t, ok := lookupAnalysisType(opts.AnalysisType); ok {
+		request.Temperature = t.Temperature
+	}
+	if opts.Temperature != nil {
+		request.Temperature = *opts.Temperature
+	}
+	if opts.Seed != nil {
+		setMetadata(request, "seed", *opts.Seed)
+	}
+	if opts.Model != "" {
+		request.ModelPreferences = &mcp.ModelPreferences{
+			Hints: []mcp.ModelHint{{Name: opts.Model}},
+		}
+	}
+}
+
+func (s *Server) handleAnalyzeFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := analyzeOptionsFrom(request, s.cfg.DefaultAnalysis)
+	opts.Filename = filename
+
+	return s.analyzeFile(ctx, opts)
+}
+
+// analyzeFile runs one file through sampling and formats the result,
+// masking PII in it when the caller asked for redaction.
+func (s *Server) analyzeFile(ctx context.Context, opts analyzeOptions) (*mcp.CallToolResult, error) {
+	return s.analyzeFileAt(ctx, opts, "", "")
+}
+
+// analyzeFileAt is analyzeFile for a caller that has already resolved the
+// file, such as analyze_batch after deduplicating: filePath and the
+// content's hash are used instead of fetching and hashing it again. Empty
+// values are worked out here.
+func (s *Server) analyzeFileAt(ctx context.Context, opts analyzeOptions, filePath, hash string) (*mcp.CallToolResult, error) {
+	if opts.Redact != "" && !slices.Contains(redactModes, opts.Redact) {
+		return errorResult("Unknown redact mode %q (use %s)", opts.Redact, strings.Join(redactModes, " or ")), nil
+	}
+	start := time.Now()
+	var timer *timings
+	if opts.WithTimings {
+		timer = &timings{phases: map[string]time.Duration{}}
+		ctx = withTimings(ctx, timer)
+	}
+
+	// The file is resolved once, since a remote source fetches it
+	if filePath == "" {
+		var err error
+		filePath, err = s.resolveFile(opts.Filename)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+	}
+
+	// Raw responses live in _meta, which the cache does not keep
+	var key string
+	if !opts.DebugRaw {
+		if hash == "" {
+			hash, _ = hashFile(filePath)
+		}
+		if hash != "" {
+			key, _ = cacheKey(ctx, hash, opts)
+		}
+	}
+	if key != "" && opts.UseCache {
+		if text, ok := s.cachedAnalysis(key); ok {
+			logf(ctx, "💾 Cache hit for %s (%s)", opts.Filename, opts.AnalysisType)
+			result := textResult(text)
+			if timer != nil {
+				timer.addTo(result, time.Since(start), true)
+			}
+			return result, nil
+		}
+	}
+	if opts.UseCache {
+		ctx = withResponseCache(ctx)
+	}
+
+	ctx, uncacheable := trackUncacheable(ctx)
+	result, err := s.sampleFile(ctx, opts, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Redact != "" {
+		result = s.redactResult(ctx, result, opts.Redact)
+	}
+	// An incomplete answer would be served later as if it were complete
+	if key != "" && !result.IsError && !uncacheable.Load() {
+		s.cacheAnalysis(key, opts, toolResultText(result))
+	}
+	// Added after caching, since they describe this call only
+	if timer != nil {
+		timer.addTo(result, time.Since(start), false)
+	}
+	return result, nil
+}
+
+// sampleFile does the work of analyzeFile before any redaction.
+func (s *Server) sampleFile(ctx context.Context, opts analyzeOptions, filePath string) (*mcp.CallToolResult, error) {
+	filename, analysisType, customPrompt, debugRaw := opts.Filename, opts.AnalysisType, opts.CustomPrompt, opts.DebugRaw
+
+	// Empty files have nothing to analyze, even with force, and tiny ones
+	// are not worth a round trip to the model
+	var fileSize int64
+	if info, err := os.Stat(filePath); err == nil {
+		fileSize = info.Size()
+		if info.Size() == 0 && !info.IsDir() {
+			return errorResult("%s is empty; there is nothing to analyze", filename), nil
+		}
+		if s.cfg.MinFileBytes > 0 && !opts.Force && info.Size() < s.cfg.MinFileBytes {
+			return errorResult("%s is only %d bytes, below the server's minimum of %d; pass force to analyze it anyway",
+				filename, info.Size(), s.cfg.MinFileBytes), nil
+		}
+	}
+
+	if err := s.checkToolNames(opts.Tools); err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	if opts.MultiLength && analysisType != "summarize" {
+		return errorResult("multi_length only applies to analysis_type summarize"), nil
+	}
+	if opts.TargetLength != "" {
+		if analysisType != "summarize" || opts.MultiLength {
+			return errorResult("target_length only applies to analysis_type summarize without multi_length"), nil
+		}
+		if _, err := parseTargetLength(opts.TargetLength); err != nil {
+			return errorResult("%v", err), nil
+		}
+	}
+
+	if err := checkChunkMode(opts, s.cfg.ChunkSize); err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	// Create appropriate prompt based on analysis type
+	donePrompt := timePhase(ctx, phasePrompt)
+	basePrompt := promptFor(analysisType)
+	if customPrompt != "" {
+		basePrompt = customPrompt
+	}
+	if opts.MultiLength {
+		basePrompt = multiLengthPrompt
+	}
+	basePrompt, err := withAudience(basePrompt, opts.Audience)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	languagePrompt, err := s.languageInstruction(ctx, opts, filePath)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	basePrompt += languagePrompt
+	if opts.WithCitations {
+		basePrompt += citationsPrompt
+	}
+	if opts.NeighborSummaries && !opts.WithNeighbors {
+		return errorResult("neighbor_summaries only applies with with_neighbors"), nil
+	}
+	if opts.WithNeighbors {
+		neighbors, err := s.neighborContext(ctx, filename, filePath, opts.NeighborSummaries)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		basePrompt += neighbors
+	}
+	donePrompt()
+
+	// Archives are analyzed member by member instead of as one binary blob
+	if isArchive(filename) {
+		if opts.Window != nil {
+			return errorResult("byte_offset and byte_length cannot be used with archives"), nil
+		}
+		return s.analyzeArchive(ctx, opts, filePath, basePrompt)
+	}
+
+	// Determine file type
+	mimeType := mimeTypeFor(filename)
+
+	// Long text files are streamed into chunked analysis a chunk at a time
+	// instead of read whole. Sections and citations need the whole text.
+	if isTextFile(filename, mimeType) && !isNotebook(filename) && opts.Window == nil && opts.ExtractSection == "" &&
+		!opts.WithCitations && fileSize > int64(s.cfg.ChunkSize) {
+		doneRead := timePhase(ctx, phaseRead)
+		chunks, err := scanChunks(filePath, s.cfg.ChunkSize)
+		doneRead()
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+		// UTF-16 files come back nil and are decoded whole below
+		if chunks != nil {
+			if chunks.latin1 {
+				logf(ctx, "Normalized %s from Latin-1 to UTF-8", filename)
+			}
+			return s.analyzeChunked(ctx, opts, mimeType, chunks, nil, basePrompt)
+		}
+	}
+
+	// Read file content, or only the requested window of it
+	doneRead := timePhase(ctx, phaseRead)
+	var fileContent []byte
+	if isNotebook(filename) {
+		// Notebooks are analyzed as their cells, without the JSON around them
+		if opts.Window != nil {
+			return errorResult("byte_offset and byte_length cannot be used with notebooks"), nil
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+		cells, err := notebookContent(data, opts.IncludeOutputs)
+		if err != nil {
+			return errorResult("%s: %v", filename, err), nil
+		}
+		logf(ctx, "Extracted the cells of notebook %s (%d of %d bytes)", filename, len(cells), len(data))
+		fileContent, mimeType = []byte(cells), "text/plain"
+		basePrompt += notebookPrompt
+	} else if opts.Window != nil {
+		window := *opts.Window
+		if window.Length == 0 {
+			window.Length = int64(s.cfg.ChunkSize)
+		}
+		var size int64
+		fileContent, size, err = readWindow(filePath, window)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		if isTextFile(filename, mimeType) {
+			var skipped int
+			fileContent, skipped = trimPartialRunes(fileContent)
+			window.Offset += int64(skipped)
+		}
+		basePrompt = fmt.Sprintf("%s The content is bytes %d-%d of a %d-byte file, so it may start and end partway through a line or record.",
+			basePrompt, window.Offset, window.Offset+int64(len(fileContent)), size)
+		logf(ctx, "Read window of %s: %d bytes at offset %d (file is %d bytes)", filename, len(fileContent), window.Offset, size)
+	} else {
+		fileContent, err = os.ReadFile(filePath)
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+		doneRead()
+
+		// Providers reject images over their size limits, so shrink them first
+		if strings.HasPrefix(mimeType, "image/") {
+			fileContent, mimeType, err = s.fitImageFile(ctx, filename, fileContent, mimeType, opts.ImageMaxDimension)
+			if err != nil {
+				return errorResult("%v", err), nil
+			}
+		}
+	}
+
+	doneRead()
+	donePrompt = timePhase(ctx, phasePrompt)
+
+	// Narrow the file to the requested section; the result is always text
+	if opts.ExtractSection != "" {
+		section, err := extractSection(filename, fileContent, opts.ExtractSection)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		logf(ctx, "Extracted %s of %s (%d of %d bytes)", opts.ExtractSection, filename, len(section), len(fileContent))
+		fileContent = []byte(section)
+		if !isTextFile(filename, mimeType) {
+			mimeType = "text/plain"
+		}
+	}
+
+	if opts.WithCitations && !isTextFile(filename, mimeType) {
+		return errorResult("with_citations only works on text files; %s is %s", filename, mimeType), nil
+	}
+
+	// Clean up encodings and line endings before text reaches the prompt
+	if isTextFile(filename, mimeType) {
+		text, encoding := normalizeText(fileContent)
+		if encoding != "UTF-8" {
+			logf(ctx, "Normalized %s from %s to UTF-8", filename, encoding)
+		}
+		fileContent = []byte(text)
+	}
+
+	// Text too long for one request is analyzed in chunks
+	if isTextFile(filename, mimeType) && len(fileContent) > s.cfg.ChunkSize {
+		donePrompt()
+		return s.analyzeChunked(ctx, opts, mimeType, newSplitText(fileContent, s.cfg.ChunkSize), fileContent, basePrompt)
+	}
+
+	contentForLLM, systemPrompt, err := buildContent(filename, mimeType, fileContent, basePrompt, s.cfg.BinaryPolicy)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	samplingRequest := newSamplingRequest(contentForLLM, systemPrompt)
+	opts.applyTo(&samplingRequest)
+	if debugRaw {
+		// Ask the client's handler to send back the provider's raw JSON
+		setMetadata(&samplingRequest, "debug_raw", true)
+	}
+	donePrompt()
+
+	logf(ctx, "📤 Sending sampling request for file: %s (analysis: %s)", filename, analysisType)
+	result, err := s.sampleToLength(ctx, samplingRequest, opts.TargetLength, func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return s.sampleWithTools(ctx, request, opts.Tools)
+	})
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+
+	logf(ctx, "✅ Sampling request successful! Model: %s", result.Model)
+
+	// Return the analysis result
+	toolResult := textResult(fmt.Sprintf("File Analysis Results\n"+
+		"=====================\n"+
+		"File: %s\n"+
+		"Type: %s\n"+
+		"Analysis: %s\n"+
+		"Model: %s\n\n"+
+		"%s", filename, mimeType, analysisType, result.Model, opts.answerText(result, fileContent)))
+
+	if debugRaw {
+		toolResult.Meta = mcp.NewMetaFromMap(map[string]any{"raw_response": rawResponse(result)})
+	}
+
+	return toolResult, nil
+}
+
+// answerText returns the response text, split into labeled sections when
+// the options asked for a structured answer. source is the text that was
+// analyzed, against which citations are checked.
+func (opts analyzeOptions) answerText(result *mcp.CreateMessageResult, source []byte) string {
+	text := resultText(result)
+
+	var citations string
+	if opts.WithCitations {
+		var quotes []string
+		text, quotes = splitCitations(text)
+		citations = "\n\n" + formatCitations(verifyCitations(string(source), quotes))
+	}
+
+	if opts.MultiLength {
+		text = formatMultiLength(text)
+	}
+	return text + citations
+}
+
+// promptFor returns the base instruction for an analysis type.
+func promptFor(analysisType string) string {
+	if t, ok := lookupAnalysisType(analysisType); ok {
+		return t.Prompt
+	}
+	return "Please analyze this content and provide insights."
+}
+
+// isTextFile reports whether a file should be sent to the LLM as plain text.
+func isTextFile(filename, mimeType string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return strings.HasPrefix(mimeType, "text/") || ext == ".md" || ext == ".txt" || ext == ".json" || ext == ".xml" || ext == ".csv"
+}
+
+// buildContent prepares the file for the LLM based on its type and returns
+// the message content together with a system prompt describing it. Other
+// binary files are handled as binaryPolicy says.
+func buildContent(filename, mimeType string, fileContent []byte, basePrompt, binaryPolicy string) (mcp.Content, string, error) {
+	if isTextFile(filename, mimeType) {
+		// Text file - send as text content
+		return mcp.TextContent{
+			Type: "text",
+			Text: string(fileContent),
+		}, fmt.Sprintf("%s The content is a %s file named '%s'.", basePrompt, mimeType, filename), nil
+	}
+
+	if strings.HasPrefix(mimeType, "image/") {
+		// Image file - send as base64 encoded image
+		return mcp.ImageContent{
+			Type:     "image",
+			Data:     base64.StdEncoding.EncodeToString(fileContent),
+			MIMEType: mimeType,
+		}, fmt.Sprintf("%s The content is an image file named '%s' of type %s.", basePrompt, filename, mimeType), nil
+	}
+
+	return binaryContent(filename, mimeType, fileContent, basePrompt, binaryPolicy)
+}
+
+// newSamplingRequest creates a single-message sampling request with the
+// settings used for file analysis.
+func newSamplingRequest(content mcp.Content, systemPrompt string) mcp.CreateMessageRequest {
+	return mcp.CreateMessageRequest{
+		CreateMessageParams: mcp.CreateMessageParams{
+			Messages: []mcp.SamplingMessage{
+				{
+					Role:    mcp.RoleUser,
+					Content: content,
+				},
+			},
+			SystemPrompt: systemPrompt,
+			MaxTokens:    2000,
+			Temperature:  0.3, // Lower temperature for more focused analysis
+		},
+	}
+}
+
+// setMetadata adds a key to a sampling request's metadata, copying the map
+// so requests built from the same template never share it.
+func setMetadata(request *mcp.CreateMessageRequest, key string, value any) {
+	metadata, _ := request.Metadata.(map[string]any)
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata[key] = value
+	request.Metadata = metadata
+}
+
+// requestSampling asks the connected client to run the request through its
+// LLM, with a timeout so a missing sampling client cannot hang the tool.
+// At most Config.MaxConcurrentSampling requests are in flight at once;
+// when all are busy, waiting requests are served by the call's priority.
+// With moderation enabled, content is screened before it is sent. An
+// identical earlier request is answered from the provider-response cache.
+func (s *Server) requestSampling(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	if err := s.moderate(ctx, request); err != nil {
+		return nil, err
+	}
+
+	key, cacheable := responseCacheKey(ctx, request)
+	if cacheable {
+		if result, ok := s.cachedSampling(key); ok {
+			logf(ctx, "💾 Provider response cache hit (model: %s)", result.Model)
+			return result, nil
+		}
+	}
+
+	result, err := s.sendSampling(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	// A refusal is not an answer, so it is neither returned nor cached
+	if refusal := checkRefusal(result, s.cfg.RefusalPatterns); refusal != nil {
+		log.Printf("🚫 Model refused the request (%s)", refusal.Reason)
+		recordRefusal(ctx, refusal)
+		return nil, refusal
+	}
+	// A streamed answer cut off partway is returned, marked, but only once
+	if reason, ok := partialReason(result); ok {
+		log.Printf("⚠️  Sampling result is partial, the client's stream was interrupted: %s", reason)
+		recordPartial(ctx, reason)
+		return result, nil
+	}
+	if stoppedAtMaxTokens(result) {
+		result = s.completeTruncated(ctx, request, result)
+	}
+	// An answer still cut off would be served as if complete
+	if cacheable && !stoppedAtMaxTokens(result) {
+		s.cacheSampling(key, result)
+	}
+	return result, nil
+}
+
+// sendSampling sends one sampling request to the client once a sampling
+// slot is free.
+func (s *Server) sendSampling(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	samplingCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	if err := s.samplingSlots.acquire(samplingCtx, priorityFrom(ctx)); err != nil {
+		return nil, fmt.Errorf("waiting for a free sampling slot: %w", err)
+	}
+	defer s.samplingSlots.release()
+
+	doneSampling := timePhase(ctx, phaseSampling)
+	start := time.Now()
+	result, err := s.mcp.RequestSampling(samplingCtx, withAPIKeyMetadata(ctx, request))
+	doneSampling()
+	if s.cfg.Debug || debugging(ctx) {
+		logSamplingSizes(request, result)
+	}
+	if debugging(ctx) {
+		s.logSamplingExchange(ctx, request, result, err, time.Since(start))
+	}
+	return result, err
+}
+
+// partialReason reports whether the sampling client marked the result as
+// the beginning of an interrupted response, and why.
+func partialReason(result *mcp.CreateMessageResult) (string, bool) {
+	if result.Meta == nil {
+		return "", false
+	}
+	partial, ok := result.Meta.AdditionalFields["partial"].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	reason, _ := partial["reason"].(string)
+	return reason, true
+}
+
+// rawResponse returns the raw provider response a handler attached to the
+// result's _meta, or a note when the handler does not support debug_raw.
+func rawResponse(result *mcp.CreateMessageResult) any {
+	if result.Meta != nil {
+		if raw, ok := result.Meta.AdditionalFields["raw_response"]; ok {
+			return raw
+		}
+	}
+	return "not provided by the sampling client"
+}
+
+// resultText extracts the response text from a sampling result.
+func resultText(result *mcp.CreateMessageResult) string {
+	if textContent, ok := result.Content.(mcp.TextContent); ok {
+		return textContent.Text
+	}
+	return fmt.Sprintf("%v", result.Content)
+}
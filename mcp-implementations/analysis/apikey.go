@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// apiKeyContextKey stores a caller-supplied provider API key in a tool
+// call's context.
+type apiKeyContextKey struct{}
+
+// apiKeyProperty documents the api_key argument on tools that sample.
+var apiKeyProperty = map[string]any{
+	"type":        "string",
+	"description": "Optional provider API key for the sampling client to use for this call instead of its own key (never logged)",
+}
+
+// withCallerAPIKey is tool middleware that moves an api_key argument into
+// the context, so every sampling request made for the call carries it.
+// This lets a multi-tenant host bill each caller's own provider account.
+func withCallerAPIKey(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if key := request.GetString("api_key", ""); key != "" {
+			ctx = context.WithValue(ctx, apiKeyContextKey{}, key)
+		}
+		return next(ctx, request)
+	}
+}
+
+// withAPIKeyMetadata adds the caller's API key, if any, to the sampling
+// request metadata read by the client's handler.
+func withAPIKeyMetadata(ctx context.Context, request mcp.CreateMessageRequest) mcp.CreateMessageRequest {
+	key, ok := ctx.Value(apiKeyContextKey{}).(string)
+	if !ok {
+		return request
+	}
+
+	setMetadata(&request, "api_key", key)
+	return request
+}
+
+// callerKeyHash returns a hash of the caller's API key, or "" when the call
+// has none. Caches include it in their keys, so a result bought with one
+// key is never served to a call made with another or with none, and the
+// key itself is never stored.
+func callerKeyHash(ctx context.Context) string {
+	key, ok := ctx.Value(apiKeyContextKey{}).(string)
+	if !ok {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
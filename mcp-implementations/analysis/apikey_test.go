@@ -0,0 +1,35 @@
+package analysis
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCallerAPIKeyReachesSamplingMetadata(t *testing.T) {
+	var logs bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "api_key": "tenant-secret"})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "analysis_type": "explain"})
+
+	requests := sampler.Requests()
+	withKey, _ := requests[0].Metadata.(map[string]any)
+	if withKey["api_key"] != "tenant-secret" {
+		t.Errorf("sampling metadata %v, want the caller's api_key", withKey)
+	}
+	withoutKey, _ := requests[1].Metadata.(map[string]any)
+	if _, ok := withoutKey["api_key"]; ok {
+		t.Errorf("sampling metadata %v carries an api_key the call did not pass", withoutKey)
+	}
+	if strings.Contains(text, "tenant-secret") || strings.Contains(logs.String(), "tenant-secret") {
+		t.Error("the caller's API key appears in the result or the server log")
+	}
+}
@@ -0,0 +1,286 @@
+package analysis
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Archive limits used when the Config leaves them unset.
+const (
+	DefaultArchiveMaxMembers     = 10
+	DefaultArchiveMaxListed      = 100
+	DefaultArchiveMaxMemberBytes = 1 << 20  // 1 MiB
+	DefaultArchiveMaxTotalBytes  = 10 << 20 // 10 MiB
+)
+
+var (
+	// errMemberTooLarge is returned when a member decompresses past the limit.
+	errMemberTooLarge = errors.New("member exceeds decompressed size limit")
+	// errArchiveTooLarge stops reading an archive whose stream runs past
+	// the total limit.
+	errArchiveTooLarge = errors.New("archive exceeds total size limit")
+)
+
+// archiveMember is one file inside an archive. Text is only set for members
+// that were extracted; Skipped explains why a member was not.
+type archiveMember struct {
+	Name    string
+	Size    int64
+	Text    string
+	Skipped string
+}
+
+// archiveLimits bounds how much an archive may expand to, so a small
+// compressed upload cannot exhaust memory (a "zip bomb").
+type archiveLimits struct {
+	maxMembers     int
+	maxListed      int
+	maxMemberBytes int64
+	maxTotalBytes  int64
+}
+
+// archiveListing is what readArchive found: the members listed, how many
+// more there were past the listing limit, and why reading stopped early,
+// if it did.
+type archiveListing struct {
+	members  []archiveMember
+	unlisted int
+	stopped  string
+}
+
+// isArchive reports whether the file is an archive we know how to open.
+func isArchive(filename string) bool {
+	name := strings.ToLower(filename)
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// archiveExtractor decides which members to extract while an archive is
+// walked, keeping track of how much has been decompressed so far.
+type archiveExtractor struct {
+	limits    archiveLimits
+	extracted int
+	total     int64
+	archiveListing
+}
+
+// add records a member and extracts it if it is text and within limits.
+// Members past the listing limit are only counted.
+func (e *archiveExtractor) add(name string, size int64, open func() (io.ReadCloser, error)) {
+	if len(e.members) >= e.limits.maxListed {
+		e.unlisted++
+		return
+	}
+	member := archiveMember{Name: name, Size: size}
+	defer func() { e.members = append(e.members, member) }()
+
+	switch {
+	case !isTextFile(name, mimeTypeFor(name)):
+		member.Skipped = "not a text file"
+		return
+	case e.extracted >= e.limits.maxMembers:
+		member.Skipped = fmt.Sprintf("member limit (%d) reached", e.limits.maxMembers)
+		return
+	case size > e.limits.maxMemberBytes:
+		member.Skipped = fmt.Sprintf("larger than %d bytes", e.limits.maxMemberBytes)
+		return
+	case e.total+size > e.limits.maxTotalBytes:
+		member.Skipped = fmt.Sprintf("archive total limit (%d bytes) reached", e.limits.maxTotalBytes)
+		return
+	}
+
+	rc, err := open()
+	if err != nil {
+		member.Skipped = fmt.Sprintf("could not open: %v", err)
+		return
+	}
+	defer rc.Close()
+
+	// Never trust the size recorded in the archive header
+	data, err := readAtMost(rc, min(e.limits.maxMemberBytes, e.limits.maxTotalBytes-e.total))
+	if err != nil {
+		member.Skipped = err.Error()
+		return
+	}
+
+	e.extracted++
+	e.total += int64(len(data))
+	member.Text, _ = normalizeText(data)
+}
+
+// readAtMost reads r fully, failing once more than limit bytes come out.
+func readAtMost(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errMemberTooLarge
+	}
+	return data, nil
+}
+
+// limitedStream fails with errArchiveTooLarge once more than allowed()
+// bytes have been read through it.
+type limitedStream struct {
+	r       io.Reader
+	read    int64
+	allowed func() int64
+}
+
+func (l *limitedStream) Read(p []byte) (int, error) {
+	if l.read > l.allowed() {
+		return 0, errArchiveTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// readArchive lists the members of a zip or tar.gz archive and extracts the
+// text members allowed by limits.
+func readArchive(path string, limits archiveLimits) (archiveListing, error) {
+	extractor := &archiveExtractor{limits: limits}
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return archiveListing{}, err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			extractor.add(f.Name, int64(f.UncompressedSize64), f.Open)
+		}
+		return extractor.archiveListing, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return archiveListing{}, err
+	}
+	defer file.Close()
+
+	// A tar.gz is one stream: headers and skipped members are decompressed
+	// too, so both ends of the stream count against the total limit
+	compressed := &limitedStream{r: file, allowed: func() int64 { return limits.maxTotalBytes }}
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return archiveListing{}, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(&limitedStream{r: gz, allowed: func() int64 { return limits.maxTotalBytes + extractor.total }})
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errArchiveTooLarge) {
+			extractor.stopped = fmt.Sprintf("the archive stream passed the total limit (%d bytes)", limits.maxTotalBytes)
+			break
+		}
+		if err != nil {
+			return extractor.archiveListing, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		extractor.add(header.Name, header.Size, func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+	}
+	return extractor.archiveListing, nil
+}
+
+// analyzeArchive lists an archive and samples each extracted text member
+// separately, returning one summary per member. Every member request gets
+// the sampling options of the call, and with debug_raw the raw response
+// of each member is returned in the result's _meta. The result is an
+// error when every sampled member failed, and is not cached when any did.
+func (s *Server) analyzeArchive(ctx context.Context, opts analyzeOptions, filePath, basePrompt string) (*mcp.CallToolResult, error) {
+	filename, analysisType := opts.Filename, opts.AnalysisType
+	listing, err := readArchive(filePath, archiveLimits{
+		maxMembers:     s.cfg.ArchiveMaxMembers,
+		maxListed:      s.cfg.ArchiveMaxListed,
+		maxMemberBytes: s.cfg.ArchiveMaxMemberBytes,
+		maxTotalBytes:  s.cfg.ArchiveMaxTotalBytes,
+	})
+	if err != nil {
+		return errorResult("Error reading archive: %v", err), nil
+	}
+
+	var sections []string
+	analyzed, failed := 0, 0
+	raw := map[string]any{}
+	for _, member := range listing.members {
+		if member.Text == "" {
+			reason := member.Skipped
+			if reason == "" {
+				reason = "empty file"
+			}
+			sections = append(sections, fmt.Sprintf("### %s (%d bytes)\nSkipped: %s", member.Name, member.Size, reason))
+			continue
+		}
+
+		// Only text members are extracted, so the binary policy never applies
+		content, systemPrompt, _ := buildContent(member.Name, mimeTypeFor(member.Name), []byte(member.Text), basePrompt, s.cfg.BinaryPolicy)
+		systemPrompt += fmt.Sprintf(" It was extracted from the archive '%s'.", filename)
+
+		logf(ctx, "📤 Sending sampling request for archive member: %s/%s (analysis: %s)", filename, member.Name, analysisType)
+		memberRequest := newSamplingRequest(content, systemPrompt)
+		opts.applyTo(&memberRequest)
+		if opts.DebugRaw {
+			setMetadata(&memberRequest, "debug_raw", true)
+		}
+		result, err := s.requestSampling(ctx, memberRequest)
+		if err != nil {
+			log.Printf("❌ Sampling request failed: %v", err)
+			sections = append(sections, fmt.Sprintf("### %s (%d bytes)\nError requesting sampling: %v", member.Name, member.Size, err))
+			// A retry should sample the member again, not get the error back
+			failed++
+			markUncacheable(ctx)
+			continue
+		}
+
+		analyzed++
+		if opts.DebugRaw {
+			raw[member.Name] = rawResponse(result)
+		}
+		sections = append(sections, fmt.Sprintf("### %s (%d bytes)\nModel: %s\n\n%s", member.Name, member.Size, result.Model, resultText(result)))
+	}
+	if listing.unlisted > 0 {
+		sections = append(sections, fmt.Sprintf("… and %d more members", listing.unlisted))
+	}
+	if listing.stopped != "" {
+		sections = append(sections, fmt.Sprintf("Reading stopped early: %s. Later members are not listed.", listing.stopped))
+	}
+
+	toolResult := textResult(fmt.Sprintf("Archive Analysis Results\n"+
+		"========================\n"+
+		"File: %s\n"+
+		"Type: %s\n"+
+		"Analysis: %s\n"+
+		"Members: %d (%d analyzed)\n\n"+
+		"%s", filename, mimeTypeFor(filename), analysisType, len(listing.members)+listing.unlisted, analyzed, strings.Join(sections, "\n\n")))
+
+	// With every member failed there is no analysis to return
+	toolResult.IsError = analyzed == 0 && failed > 0
+
+	if opts.DebugRaw {
+		toolResult.Meta = mcp.NewMetaFromMap(map[string]any{"raw_responses": raw})
+	}
+	return toolResult, nil
+}
@@ -0,0 +1,278 @@
+package analysis
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// tarGzFixture builds a tar.gz archive of the given members, in order.
+func tarGzFixture(t *testing.T, members []archiveMember) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, member := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: member.Name, Mode: 0644, Size: int64(len(member.Text))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(member.Text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// zipFixture builds a zip archive of files, adding them in the order of
+// names.
+func zipFixture(t *testing.T, names []string, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestAnalyzeArchiveSamplesEachTextMember(t *testing.T) {
+	archive := zipFixture(t, []string{"notes.txt", "docs/readme.md", "logo.png"}, map[string]string{
+		"notes.txt":      "Meeting notes about the launch.",
+		"docs/readme.md": "# Readme\n\nHow to install the tool.",
+		"logo.png":       "\x89PNG\r\n\x1a\n\x00\x00",
+	})
+	s := newTestServer(t, Config{}, map[string]string{"bundle.zip": archive})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "bundle.zip"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want one per text member (2)", len(requests))
+	}
+	if !strings.Contains(messageText(requests[0]), "Meeting notes") || !strings.Contains(messageText(requests[1]), "How to install") {
+		t.Errorf("member contents did not reach the requests: %q, %q", messageText(requests[0]), messageText(requests[1]))
+	}
+	for _, want := range []string{"Members: 3 (2 analyzed)", "### notes.txt", "### docs/readme.md", "### logo.png", "Skipped: not a text file", mockAnswer} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestAnalyzeArchiveAppliesSamplingOptions(t *testing.T) {
+	archive := zipFixture(t, []string{"a.txt", "b.txt"}, map[string]string{
+		"a.txt": "First member.",
+		"b.txt": "Second member.",
+	})
+	s := newTestServer(t, Config{}, map[string]string{"bundle.zip": archive})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{
+		"filename":    "bundle.zip",
+		"temperature": 0.9,
+		"seed":        7,
+		"model":       "member-model",
+	})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want 2", len(requests))
+	}
+	for i, request := range requests {
+		if request.Temperature != 0.9 {
+			t.Errorf("member %d: temperature %v, want 0.9", i, request.Temperature)
+		}
+		metadata, _ := request.Metadata.(map[string]any)
+		if seed, ok := metadata["seed"]; !ok || fmt.Sprint(seed) != "7" {
+			t.Errorf("member %d: seed %v, want 7", i, metadata["seed"])
+		}
+		if request.ModelPreferences == nil || len(request.ModelPreferences.Hints) == 0 || request.ModelPreferences.Hints[0].Name != "member-model" {
+			t.Errorf("member %d: model preferences %+v, want a member-model hint", i, request.ModelPreferences)
+		}
+	}
+}
+
+func TestAnalyzeArchiveWithAllMembersFailedIsAnError(t *testing.T) {
+	archive := zipFixture(t, []string{"a.txt", "b.txt"}, map[string]string{
+		"a.txt": "First member.",
+		"b.txt": "Second member.",
+	})
+	s := newTestServer(t, Config{}, map[string]string{"bundle.zip": archive})
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return nil, errors.New("provider unavailable")
+	}}
+	c := connect(t, s, sampler)
+
+	for range 2 {
+		text := mustFail(t, c, "analyze_file", map[string]any{"filename": "bundle.zip"})
+		if !strings.Contains(text, "Members: 2 (0 analyzed)") || !strings.Contains(text, "provider unavailable") {
+			t.Errorf("the failures are not reported per member:\n%s", text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 4 {
+		t.Errorf("got %d sampling requests, want the failed archive sampled again (4)", n)
+	}
+}
+
+func TestAnalyzeArchiveWithFailedMemberIsNotCached(t *testing.T) {
+	archive := zipFixture(t, []string{"a.txt", "b.txt"}, map[string]string{
+		"a.txt": "First member.",
+		"b.txt": "Second member.",
+	})
+	s := newTestServer(t, Config{}, map[string]string{"bundle.zip": archive})
+	sampler := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if strings.Contains(messageText(request), "Second") {
+			return nil, errors.New("provider unavailable")
+		}
+		return textAnswer(mockAnswer), nil
+	}}
+	c := connect(t, s, sampler)
+
+	for range 2 {
+		_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "bundle.zip"})
+		if !strings.Contains(text, "Members: 2 (1 analyzed)") {
+			t.Errorf("result does not count the analyzed member:\n%s", text)
+		}
+	}
+	// The member that succeeded may come from the provider-response cache
+	failedRequests := 0
+	for _, request := range sampler.Requests() {
+		if strings.Contains(messageText(request), "Second") {
+			failedRequests++
+		}
+	}
+	if failedRequests != 2 {
+		t.Errorf("the failed member was sampled %d times, want a result with a failed member never cached (2)", failedRequests)
+	}
+}
+
+func TestReadArchiveLimits(t *testing.T) {
+	archive := zipFixture(t, []string{"small.txt", "big.txt", "third.txt", "fourth.txt"}, map[string]string{
+		"small.txt":  "tiny",
+		"big.txt":    strings.Repeat("x", 100),
+		"third.txt":  "also tiny",
+		"fourth.txt": "one too many",
+	})
+	path := filepath.Join(t.TempDir(), "limits.zip")
+	if err := os.WriteFile(path, []byte(archive), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listing, err := readArchive(path, archiveLimits{maxMembers: 2, maxListed: 10, maxMemberBytes: 50, maxTotalBytes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := listing.members
+	if len(members) != 4 {
+		t.Fatalf("got %d members, want 4", len(members))
+	}
+	if members[0].Text != "tiny" || members[2].Text != "also tiny" {
+		t.Errorf("small.txt and third.txt were not both extracted: %+v", members)
+	}
+	if !strings.Contains(members[1].Skipped, "larger than 50 bytes") {
+		t.Errorf("big.txt skipped for %q, want the member size limit", members[1].Skipped)
+	}
+	if !strings.Contains(members[3].Skipped, "member limit (2) reached") {
+		t.Errorf("fourth.txt skipped for %q, want the member count limit", members[3].Skipped)
+	}
+}
+
+func TestAnalyzeArchiveCountsMembersPastTheListingLimit(t *testing.T) {
+	var names []string
+	files := map[string]string{}
+	for i := range 5000 {
+		name := fmt.Sprintf("blobs/%05d.bin", i)
+		names = append(names, name)
+		files[name] = "\x00"
+	}
+	archive := zipFixture(t, names, files)
+	s := newTestServer(t, Config{ArchiveMaxListed: 20}, map[string]string{"many.zip": archive})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "many.zip"})
+
+	if n := strings.Count(text, "### "); n != 20 {
+		t.Errorf("got %d member sections, want the listing limit (20)", n)
+	}
+	for _, want := range []string{"Members: 5000 (0 analyzed)", "… and 4980 more members"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q", want)
+		}
+	}
+	if strings.Contains(text, "blobs/00020.bin") {
+		t.Error("a member past the listing limit got its own section")
+	}
+}
+
+func TestReadArchiveStopsTarGzPastTotalLimit(t *testing.T) {
+	// Skipped members are never extracted, but a tar.gz decompresses them
+	// anyway on the way to the next header
+	var members []archiveMember
+	for i := range 50 {
+		members = append(members, archiveMember{Name: fmt.Sprintf("blob%02d.bin", i), Text: strings.Repeat("\x00", 10_000)})
+	}
+	members = append(members, archiveMember{Name: "late.txt", Text: "never reached"})
+	path := filepath.Join(t.TempDir(), "bomb.tar.gz")
+	if err := os.WriteFile(path, []byte(tarGzFixture(t, members)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listing, err := readArchive(path, archiveLimits{maxMembers: 10, maxListed: 100, maxMemberBytes: 50_000, maxTotalBytes: 100_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(listing.stopped, "total limit (100000 bytes)") {
+		t.Errorf("stopped = %q, want reading stopped at the total limit", listing.stopped)
+	}
+	if n := len(listing.members); n == 0 || n > 11 {
+		t.Errorf("read %d members, want reading to stop after about 100000 bytes (10 members)", n)
+	}
+	for _, member := range listing.members {
+		if member.Name == "late.txt" {
+			t.Error("the member past the limit was read")
+		}
+	}
+}
+
+func TestReadArchiveTarGzWithinLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.tar.gz")
+	fixture := tarGzFixture(t, []archiveMember{{Name: "a.txt", Text: "First member."}, {Name: "b.bin", Text: "\x00\x01"}})
+	if err := os.WriteFile(path, []byte(fixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listing, err := readArchive(path, archiveLimits{maxMembers: 10, maxListed: 100, maxMemberBytes: 1000, maxTotalBytes: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listing.stopped != "" || len(listing.members) != 2 || listing.members[0].Text != "First member." {
+		t.Errorf("listing = %+v, want both members and the text one extracted", listing)
+	}
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import "fmt"
+
+// DefaultAudience leaves the analysis prompt as it is.
+const DefaultAudience = "general"
+
+// Audience describes one accepted value of the audience argument.
+type Audience struct {
+	Name        string
+	Description string
+	// Prompt is appended to the system prompt to pitch the answer at this
+	// audience. It is empty for the default.
+	Prompt string
+}
+
+var audiences = []Audience{
+	{
+		Name:        "child",
+		Description: "A young child (explain like I'm five)",
+		Prompt:      "Write for a five-year-old child: short sentences, everyday words, no jargon, and a simple comparison to something from daily life.",
+	},
+	{
+		Name:        DefaultAudience,
+		Description: "A general reader",
+	},
+	{
+		Name:        "student",
+		Description: "A student new to the subject",
+		Prompt:      "Write for a student new to the subject: define each technical term the first time it appears and build from the basics up.",
+	},
+	{
+		Name:        "expert",
+		Description: "A specialist in the field",
+		Prompt:      "Write for an expert in the field: use precise technical vocabulary without defining it, skip the basics, and focus on details, trade-offs and edge cases.",
+	},
+	{
+		Name:        "executive",
+		Description: "A busy decision maker",
+		Prompt:      "Write for a busy executive: lead with the bottom line, focus on impact, risks and decisions needed, and leave out implementation detail.",
+	},
+}
+
+// audienceNames lists the accepted audience values, for tool schemas.
+func audienceNames() []string {
+	names := make([]string, len(audiences))
+	for i, a := range audiences {
+		names[i] = a.Name
+	}
+	return names
+}
+
+var audienceProperty = map[string]any{
+	"type":        "string",
+	"description": "Who the answer is for; adjusts its complexity and vocabulary (default general)",
+	"enum":        audienceNames(),
+}
+
+// withAudience appends the audience's instructions to a system prompt. An
+// empty audience is the default.
+func withAudience(prompt, audience string) (string, error) {
+	if audience == "" {
+		audience = DefaultAudience
+	}
+	for _, a := range audiences {
+		if a.Name != audience {
+			continue
+		}
+		if a.Prompt == "" {
+			return prompt, nil
+		}
+		return prompt + " " + a.Prompt, nil
+	}
+	return "", fmt.Errorf("Unknown audience %q", audience)
+}
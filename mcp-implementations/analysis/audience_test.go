@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeFileAudienceReachesSystemPrompt(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"physics.md": "Gravity bends spacetime."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, a := range audiences {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "physics.md", "analysis_type": "explain", "audience": a.Name, "use_cache": false})
+	}
+
+	explain, _ := lookupAnalysisType("explain")
+	for i, a := range audiences {
+		prompt := sampler.Requests()[i].SystemPrompt
+		if !strings.HasPrefix(prompt, explain.Prompt) {
+			t.Errorf("%s: the analysis prompt was replaced: %q", a.Name, prompt)
+		}
+		if a.Prompt != "" && !strings.Contains(prompt, a.Prompt) {
+			t.Errorf("%s: system prompt does not carry the audience descriptor: %q", a.Name, prompt)
+		}
+	}
+}
+
+func TestAnalyzeFileDefaultAudience(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"physics.md": "Gravity bends spacetime."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "physics.md"})
+	prompt := sampler.Requests()[0].SystemPrompt
+	for _, a := range audiences {
+		if a.Prompt != "" && strings.Contains(prompt, a.Prompt) {
+			t.Errorf("the default audience added the %s descriptor: %q", a.Name, prompt)
+		}
+	}
+}
+
+func TestAnalyzeFileUnknownAudience(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"physics.md": "Gravity bends spacetime."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "physics.md", "audience": "alien"})
+	if !strings.Contains(text, `Unknown audience "alien"`) || len(sampler.Requests()) != 0 {
+		t.Errorf("unexpected result for an unknown audience: %s", text)
+	}
+}
@@ -0,0 +1,303 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var analyzeBatchTool = mcp.Tool{
+	Name:        "analyze_batch",
+	Description: "Analyze several files from the local directory using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filenames": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "The files to analyze (relative to files directory)",
+			},
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("A glob such as *.log whose matching files are analyzed, after any filenames. Only the first %d matches in name order are analyzed; the result says how many were left out", MaxGlobFiles),
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis to perform on every file",
+				"enum":        AnalysisTypeNames(),
+			},
+			"custom_prompt": map[string]any{
+				"type":        "string",
+				"description": "Optional custom prompt for the analysis",
+			},
+			"multi_length": map[string]any{
+				"type":        "boolean",
+				"description": "For summarize: return a TL;DR, a paragraph summary and bullet key points for each file",
+			},
+			"with_neighbors": map[string]any{
+				"type":        "boolean",
+				"description": fmt.Sprintf("Name up to %d other files in the same directory in the prompt, so the model can place the file in context (default false)", MaxNeighbors),
+			},
+			"neighbor_summaries": map[string]any{
+				"type":        "boolean",
+				"description": "With with_neighbors: add the start of each neighbor's cached summary, if it has one; nothing extra is sampled (default false)",
+			},
+			"include_outputs": map[string]any{
+				"type":        "boolean",
+				"description": "For Jupyter notebooks: include the text of code cell outputs (default false)",
+			},
+			"image_max_dimension": map[string]any{
+				"type":        "integer",
+				"description": "For images: downscale so the long edge is at most this many pixels",
+			},
+			"target_length": map[string]any{
+				"type":        "string",
+				"description": "For summarize: each summary's length, e.g. \"50 words\" or \"3 sentences\"",
+			},
+			"extract_section": map[string]any{
+				"type":        "string",
+				"description": "Analyze only the frontmatter, code comments, or body of each file",
+				"enum":        extractSections,
+			},
+			"temperature": map[string]any{
+				"type":        "number",
+				"description": "Sampling temperature, overriding the analysis type's default",
+			},
+			"seed": map[string]any{
+				"type":        "integer",
+				"description": "Sampling seed for reproducible output (best effort)",
+			},
+			"model": map[string]any{
+				"type":        "string",
+				"description": "Model alias such as \"fast\" or \"smart\", resolved by the sampling client",
+			},
+			"audience": audienceProperty,
+			"redact":   redactProperty,
+			"force": map[string]any{
+				"type":        "boolean",
+				"description": "Analyze files even if they are smaller than the server's minimum file size",
+			},
+			"use_cache": map[string]any{
+				"type":        "boolean",
+				"description": "Reuse cached results for unchanged files (default true)",
+			},
+			"max_parallel": map[string]any{
+				"type":        "integer",
+				"description": "How many files to analyze at once (capped by the server's sampling limit)",
+			},
+			"ordered": map[string]any{
+				"type":        "boolean",
+				"description": "Return results in input order (default) instead of the order they complete",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+	},
+}
+
+// batchItem is the outcome of analyzing one file in a batch. Path and
+// Hash are set once the file has been resolved and read.
+type batchItem struct {
+	Index    int
+	Filename string
+	Path     string
+	Hash     string
+	Text     string
+	IsError  bool
+}
+
+func (s *Server) handleAnalyzeBatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filenames := request.GetStringSlice("filenames", nil)
+	capNote := ""
+	if pattern := request.GetString("pattern", ""); pattern != "" {
+		matches, omitted, err := s.expandGlob(pattern)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		if len(matches) == 0 {
+			return errorResult("No files match pattern %q", pattern), nil
+		}
+		logf(ctx, "Pattern %q matched %d files", pattern, len(matches)+omitted)
+		if omitted > 0 {
+			capNote = fmt.Sprintf("Pattern %q matched %d files; only the first %d in name order were analyzed, %d left out.\n\n", pattern, len(matches)+omitted, len(matches), omitted)
+		}
+		filenames = append(filenames, matches...)
+	}
+	if len(filenames) == 0 {
+		return errorResult("No filenames provided; pass filenames or a pattern"), nil
+	}
+
+	opts := analyzeOptionsFrom(request, s.cfg.DefaultAnalysis)
+	ordered := request.GetBool("ordered", true)
+
+	// The global sampling limit applies anyway; capping here keeps the
+	// reported parallelism honest and avoids idle goroutines
+	maxParallel := request.GetInt("max_parallel", s.cfg.MaxConcurrentSampling)
+	maxParallel = max(1, min(maxParallel, s.cfg.MaxConcurrentSampling, len(filenames)))
+
+	// Identical files are analyzed once and share the result
+	unique, duplicateOf := s.dedupFiles(filenames)
+	items := s.runBatch(ctx, unique, opts, maxParallel)
+
+	byIndex := make(map[int]batchItem, len(items))
+	for _, item := range items {
+		byIndex[item.Index] = item
+	}
+	var dedupLines []string
+	for i, filename := range filenames {
+		original, ok := duplicateOf[i]
+		if !ok {
+			continue
+		}
+		shared := byIndex[original]
+		items = append(items, batchItem{
+			Index:    i,
+			Filename: filename,
+			Text:     fmt.Sprintf("(Same content as %s; its result is reused.)\n%s", shared.Filename, shared.Text),
+			IsError:  shared.IsError,
+		})
+		dedupLines = append(dedupLines, fmt.Sprintf("- %s = %s", filename, shared.Filename))
+	}
+
+	order := "completion"
+	if ordered {
+		order = "input"
+		sort.Slice(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+	}
+
+	failed := 0
+	var sections []string
+	for _, item := range items {
+		status := "ok"
+		if item.IsError {
+			status = "error"
+			failed++
+		}
+		sections = append(sections, fmt.Sprintf("--- [%d/%d] %s (%s) ---\n%s", item.Index+1, len(filenames), item.Filename, status, item.Text))
+	}
+
+	dedupNote := ""
+	if len(dedupLines) > 0 {
+		dedupNote = fmt.Sprintf("Deduplicated (identical content, analyzed once):\n%s\n\n", strings.Join(dedupLines, "\n"))
+	}
+
+	result := textResult(fmt.Sprintf("Batch Analysis Results\n"+
+		"======================\n"+
+		"Files: %d (%d failed, %d deduplicated)\n"+
+		"Analysis: %s\n"+
+		"Parallelism: %d\n"+
+		"Order: %s\n\n"+
+		"%s"+
+		"%s"+
+		"%s", len(filenames), failed, len(dedupLines), opts.AnalysisType, maxParallel, order, capNote, dedupNote, strings.Join(sections, "\n\n")))
+	result.IsError = failed == len(filenames)
+	return result, nil
+}
+
+// dedupFiles hashes each file's content and returns the files to analyze,
+// in batch order, and for each duplicate the index of the first file with
+// the same content. The files to analyze keep the path and hash found
+// here, so a remote source is not fetched again. Files that cannot be read
+// are kept so their error is reported as usual.
+func (s *Server) dedupFiles(filenames []string) ([]batchItem, map[int]int) {
+	var unique []batchItem
+	duplicateOf := map[int]int{}
+	firstByHash := map[string]int{}
+
+	for i, filename := range filenames {
+		filePath, err := s.resolveFile(filename)
+		if err != nil {
+			unique = append(unique, batchItem{Index: i, Filename: filename})
+			continue
+		}
+		hash, err := hashFile(filePath)
+		if err != nil {
+			unique = append(unique, batchItem{Index: i, Filename: filename})
+			continue
+		}
+		if first, seen := firstByHash[hash]; seen {
+			duplicateOf[i] = first
+			continue
+		}
+		firstByHash[hash] = i
+		unique = append(unique, batchItem{Index: i, Filename: filename, Path: filePath, Hash: hash})
+	}
+	return unique, duplicateOf
+}
+
+// hashFile returns the hex SHA-256 of a file's content, streaming it so
+// large files are never held in memory.
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// runBatch analyzes the jobs' files with at most maxParallel running at
+// once and returns the outcomes in the order they finished.
+func (s *Server) runBatch(ctx context.Context, jobs []batchItem, opts analyzeOptions, maxParallel int) []batchItem {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		items = make([]batchItem, 0, len(jobs))
+		slots = make(chan struct{}, maxParallel)
+	)
+
+	for _, job := range jobs {
+		i, filename, filePath, hash := job.Index, job.Filename, job.Path, job.Hash
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			fileOpts := opts
+			fileOpts.Filename = filename
+			item := batchItem{Index: i, Filename: filename}
+
+			result, err := s.analyzeFileAt(ctx, fileOpts, filePath, hash)
+			if err != nil {
+				item.Text, item.IsError = err.Error(), true
+			} else {
+				item.Text, item.IsError = toolResultText(result), result.IsError
+			}
+
+			mu.Lock()
+			items = append(items, item)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return items
+}
+
+// toolResultText joins the text blocks of a tool result.
+func toolResultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if textContent, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, textContent.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// concurrencyTracker is a respond function that holds each sampling
+// request for a while and records the most requests in flight at once.
+type concurrencyTracker struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	delay    func(request mcp.CreateMessageRequest) time.Duration
+}
+
+func (c *concurrencyTracker) respond(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.peak = max(c.peak, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(c.delay(request))
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return textAnswer(mockAnswer), nil
+}
+
+func (c *concurrencyTracker) Peak() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peak
+}
+
+// batchFiles returns n files with distinct content, so none are deduplicated.
+func batchFiles(n int) (map[string]string, []string) {
+	files := map[string]string{}
+	var names []string
+	for i := range n {
+		name := fmt.Sprintf("file%d.txt", i)
+		files[name] = fmt.Sprintf("Contents of file number %d.", i)
+		names = append(names, name)
+	}
+	return files, names
+}
+
+func TestAnalyzeBatchCapsParallelism(t *testing.T) {
+	files, names := batchFiles(6)
+	s := newTestServer(t, Config{MaxConcurrentSampling: 4}, files)
+	tracker := &concurrencyTracker{delay: func(mcp.CreateMessageRequest) time.Duration { return 30 * time.Millisecond }}
+	c := connect(t, s, &mockSampler{respond: tracker.respond})
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": names, "max_parallel": 2})
+	if !strings.Contains(text, "Parallelism: 2") {
+		t.Errorf("result does not report the requested parallelism:\n%s", text)
+	}
+	if peak := tracker.Peak(); peak > 2 {
+		t.Errorf("%d sampling requests ran at once, want at most max_parallel (2)", peak)
+	}
+}
+
+func TestAnalyzeBatchParallelismBoundByServerLimit(t *testing.T) {
+	files, names := batchFiles(6)
+	s := newTestServer(t, Config{MaxConcurrentSampling: 3}, files)
+	tracker := &concurrencyTracker{delay: func(mcp.CreateMessageRequest) time.Duration { return 30 * time.Millisecond }}
+	c := connect(t, s, &mockSampler{respond: tracker.respond})
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": names, "max_parallel": 10})
+	if !strings.Contains(text, "Parallelism: 3") {
+		t.Errorf("max_parallel was not capped by the server's limit:\n%s", text)
+	}
+	if peak := tracker.Peak(); peak > 3 {
+		t.Errorf("%d sampling requests ran at once, want at most the server's limit (3)", peak)
+	}
+}
+
+// slowFirstFile delays the request for file0.txt so it finishes last.
+func slowFirstFile(request mcp.CreateMessageRequest) time.Duration {
+	if strings.Contains(messageText(request), "file number 0") {
+		return 150 * time.Millisecond
+	}
+	return 10 * time.Millisecond
+}
+
+func TestAnalyzeBatchOrderedKeepsInputOrder(t *testing.T) {
+	files, names := batchFiles(3)
+	s := newTestServer(t, Config{}, files)
+	tracker := &concurrencyTracker{delay: slowFirstFile}
+	c := connect(t, s, &mockSampler{respond: tracker.respond})
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": names, "max_parallel": 3})
+	if !strings.Contains(text, "Order: input") {
+		t.Errorf("result does not report input order:\n%s", text)
+	}
+	first, second, third := strings.Index(text, "file0.txt"), strings.Index(text, "file1.txt"), strings.Index(text, "file2.txt")
+	if !(first < second && second < third) {
+		t.Errorf("results are not in input order:\n%s", text)
+	}
+}
+
+func TestAnalyzeBatchUnorderedReturnsCompletionOrder(t *testing.T) {
+	files, names := batchFiles(3)
+	s := newTestServer(t, Config{}, files)
+	tracker := &concurrencyTracker{delay: slowFirstFile}
+	c := connect(t, s, &mockSampler{respond: tracker.respond})
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": names, "max_parallel": 3, "ordered": false})
+	if !strings.Contains(text, "Order: completion") {
+		t.Errorf("result does not report completion order:\n%s", text)
+	}
+	if strings.Index(text, "--- [1/3] file0.txt") < strings.Index(text, "--- [2/3] file1.txt") {
+		t.Errorf("the slow first file is not listed after a faster one:\n%s", text)
+	}
+}
+
+func TestAnalyzeBatchDeduplicatesIdenticalFiles(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"a.txt":      "Shared content.",
+		"copy/a.txt": "Shared content.",
+		"b.txt":      "Different content.",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{
+		"filenames": []string{"a.txt", "b.txt", "copy/a.txt"},
+		"use_cache": false,
+	})
+
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want one per unique content (2)", n)
+	}
+	for _, want := range []string{
+		"Files: 3 (0 failed, 1 deduplicated)",
+		"- copy/a.txt = a.txt",
+		"--- [3/3] copy/a.txt (ok) ---\n(Same content as a.txt; its result is reused.)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Binary policies decide what is sent for a file that is neither text nor
+// an image.
+const (
+	// BinaryReject refuses to analyze the file.
+	BinaryReject = "reject"
+	// BinaryMetadata sends the file's name, type, size, hash and first
+	// bytes, and asks the model what kind of file it is.
+	BinaryMetadata = "metadata"
+	// BinaryBase64 sends the whole file base64 encoded as text.
+	BinaryBase64 = "base64"
+)
+
+// BinaryPolicies are the accepted values of the -binary-policy flag.
+var BinaryPolicies = []string{BinaryReject, BinaryMetadata, BinaryBase64}
+
+// DefaultBinaryPolicy is used when Config leaves BinaryPolicy unset. Few
+// models can make sense of base64, which costs a third more tokens than
+// the file's size.
+const DefaultBinaryPolicy = BinaryMetadata
+
+// binaryHeadBytes is how much of the start of a binary file the metadata
+// policy shows, enough for most magic numbers.
+const binaryHeadBytes = 64
+
+// metadataTokens approximates the prompt the metadata policy sends.
+const metadataTokens = 120
+
+// isBinaryFile reports whether a file is handled by the binary policy.
+func isBinaryFile(filename string) bool {
+	mimeType := mimeTypeFor(filename)
+	return !isTextFile(filename, mimeType) && !strings.HasPrefix(mimeType, "image/")
+}
+
+// binaryContent prepares a binary file for the LLM according to policy.
+func binaryContent(filename, mimeType string, fileContent []byte, basePrompt, policy string) (mcp.Content, string, error) {
+	switch policy {
+	case BinaryReject:
+		return nil, "", fmt.Errorf("%s is a binary file (%s), which this server is configured not to analyze", filename, mimeType)
+
+	case BinaryBase64:
+		return mcp.TextContent{
+			Type: "text",
+			Text: fmt.Sprintf("This is a binary file (%s) encoded in base64:\n\n%s", mimeType, base64.StdEncoding.EncodeToString(fileContent)),
+		}, fmt.Sprintf("%s The content is a binary file named '%s' of type %s, provided as base64-encoded data.", basePrompt, filename, mimeType), nil
+	}
+
+	sum := sha256.Sum256(fileContent)
+	head := fileContent[:min(binaryHeadBytes, len(fileContent))]
+	return mcp.TextContent{
+		Type: "text",
+		Text: fmt.Sprintf("Binary file metadata\n"+
+			"Name: %s\n"+
+			"Detected type: %s\n"+
+			"Size: %d bytes\n"+
+			"SHA-256: %x\n"+
+			"First %d bytes (hex): %s", filename, mimeType, len(fileContent), sum, len(head), hex.EncodeToString(head)),
+	}, fmt.Sprintf("%s The file '%s' is binary, so only its metadata and first bytes are provided, not its content. "+
+		"Identify what kind of file it most likely is, from its name, type and any recognizable signature in the first bytes, "+
+		"and what it is typically used for. Answer the request only as far as the metadata allows, and say what cannot be known without the content.", basePrompt, filename), nil
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// elfFixture is the start of an ELF executable followed by padding.
+var elfFixture = "\x7fELF\x02\x01\x01\x00" + strings.Repeat("\x00\x01\x02\x03", 40)
+
+func TestBinaryPolicyMetadataIsDefault(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"tool.bin": elfFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "tool.bin"})
+
+	request := sampler.Requests()[0]
+	sent := messageText(request)
+	for _, want := range []string{
+		"Name: tool.bin\n",
+		fmt.Sprintf("Size: %d bytes\n", len(elfFixture)),
+		fmt.Sprintf("SHA-256: %x\n", sha256.Sum256([]byte(elfFixture))),
+		"First 64 bytes (hex): 7f454c4602010100",
+	} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("metadata is missing %q:\n%s", want, sent)
+		}
+	}
+	if strings.Contains(sent, base64.StdEncoding.EncodeToString([]byte(elfFixture))[:20]) {
+		t.Errorf("the metadata policy sent the file's content:\n%s", sent)
+	}
+	if !strings.Contains(request.SystemPrompt, "Identify what kind of file it most likely is") {
+		t.Errorf("system prompt does not ask about the file type: %q", request.SystemPrompt)
+	}
+}
+
+func TestBinaryPolicyBase64SendsContent(t *testing.T) {
+	s := newTestServer(t, Config{BinaryPolicy: BinaryBase64}, map[string]string{"tool.bin": elfFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "tool.bin"})
+
+	request := sampler.Requests()[0]
+	if !strings.HasSuffix(messageText(request), "encoded in base64:\n\n"+base64.StdEncoding.EncodeToString([]byte(elfFixture))) {
+		t.Errorf("the file was not sent as base64:\n%s", messageText(request))
+	}
+	if !strings.Contains(request.SystemPrompt, "provided as base64-encoded data") {
+		t.Errorf("unexpected system prompt: %q", request.SystemPrompt)
+	}
+}
+
+func TestBinaryPolicyRejectFailsWithoutSampling(t *testing.T) {
+	s := newTestServer(t, Config{BinaryPolicy: BinaryReject}, map[string]string{"tool.bin": elfFixture, "notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "tool.bin"})
+	if !strings.Contains(text, "tool.bin is a binary file (application/octet-stream), which this server is configured not to analyze") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a rejected binary sent %d sampling requests", n)
+	}
+
+	// Text files are unaffected
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+}
+
+func TestBinaryPolicyRejectSkipsBinariesInCostEstimate(t *testing.T) {
+	s := newTestServer(t, Config{BinaryPolicy: BinaryReject}, map[string]string{"tool.bin": elfFixture, "notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "estimate_batch_cost", map[string]any{"filenames": []string{"tool.bin", "notes.txt"}})
+	if !strings.Contains(text, "- tool.bin: skipped (binary files are rejected)") {
+		t.Errorf("the binary is not listed as skipped:\n%s", text)
+	}
+}
@@ -0,0 +1,245 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is how long a cached analysis is served when Config
+// leaves CacheTTL unset.
+const DefaultCacheTTL = time.Hour
+
+// CacheBackends are the accepted values of the -cache-backend flag.
+var CacheBackends = []string{"memory", "disk"}
+
+// CacheEntry is one cached tool result.
+type CacheEntry struct {
+	Key    string `json:"key"`
+	Result string `json:"result"`
+	// Metadata describes what produced the result, e.g. the filename and
+	// analysis type, for anyone inspecting the store.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// expired reports whether the entry is past its TTL at now.
+func (e CacheEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// CacheStore holds analysis results keyed by file content and options.
+// Get never returns an expired entry.
+type CacheStore interface {
+	Get(key string) (CacheEntry, bool, error)
+	Set(entry CacheEntry) error
+	Delete(key string) error
+}
+
+// MemoryCache is a CacheStore that lives as long as the process.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+}
+
+// NewMemoryCache creates an empty in-memory cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]CacheEntry{}}
+}
+
+// Get implements CacheStore.
+func (c *MemoryCache) Get(key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+	if entry.expired(time.Now()) {
+		delete(c.entries, key)
+		return CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// Set implements CacheStore.
+func (c *MemoryCache) Set(entry CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.Key] = entry
+	return nil
+}
+
+// Delete implements CacheStore.
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	return nil
+}
+
+// DiskCache is a CacheStore that keeps one JSON file per entry in a
+// directory, so cached results survive a restart. Writes go through a
+// temporary file and a rename, so a crash never leaves a torn entry.
+type DiskCache struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDiskCache creates a disk cache in dir, creating the directory if
+// needed, and removes entries that expired while the server was down.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{dir: dir}
+	c.prune()
+	return c, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get implements CacheStore. Unreadable entries are treated as misses.
+func (c *DiskCache) Get(key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("Warning: Ignoring unreadable cache entry %s: %v", key, err)
+		return CacheEntry{}, false, nil
+	}
+	if entry.expired(time.Now()) {
+		os.Remove(c.path(key))
+		return CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// Set implements CacheStore.
+func (c *DiskCache) Set(entry CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp := c.path(entry.Key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(entry.Key))
+}
+
+// Delete implements CacheStore.
+func (c *DiskCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// prune removes expired and unreadable entries.
+func (c *DiskCache) prune() {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var entry CacheEntry
+		if json.Unmarshal(data, &entry) != nil || entry.expired(now) {
+			os.Remove(path)
+		}
+	}
+}
+
+// cacheKey identifies an analysis: the same file content analyzed with the
+// same options, by a call with the same caller API key, produces the same
+// key.
+func cacheKey(ctx context.Context, contentHash string, opts analyzeOptions) (string, error) {
+	settings, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("encoding options: %v", err)
+	}
+	sum := sha256.Sum256(append([]byte(contentHash+"\x00"+callerKeyHash(ctx)+"\x00"), settings...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// uncacheableKey carries the flag with which the sampling behind one
+// analysis reports an answer that must not be cached.
+type uncacheableKey struct{}
+
+// trackUncacheable returns a context in which markUncacheable sets the
+// returned flag.
+func trackUncacheable(ctx context.Context) (context.Context, *atomic.Bool) {
+	var uncacheable atomic.Bool
+	return context.WithValue(ctx, uncacheableKey{}, &uncacheable), &uncacheable
+}
+
+// markUncacheable notes that the analysis in progress used an incomplete
+// answer, so its result is kept out of the analysis cache.
+func markUncacheable(ctx context.Context) {
+	if uncacheable, ok := ctx.Value(uncacheableKey{}).(*atomic.Bool); ok {
+		uncacheable.Store(true)
+	}
+}
+
+// cachedAnalysis returns the cached result for an analysis, if any.
+func (s *Server) cachedAnalysis(key string) (string, bool) {
+	entry, ok, err := s.cfg.Cache.Get(key)
+	if err != nil {
+		log.Printf("Warning: Cache lookup failed: %v", err)
+		return "", false
+	}
+	return entry.Result, ok
+}
+
+// cacheAnalysis stores a successful analysis result. Failures only cost a
+// future cache miss, so they are logged and otherwise ignored.
+func (s *Server) cacheAnalysis(key string, opts analyzeOptions, result string) {
+	now := time.Now()
+	err := s.cfg.Cache.Set(CacheEntry{
+		Key:    key,
+		Result: result,
+		Metadata: map[string]string{
+			"filename":      opts.Filename,
+			"analysis_type": opts.AnalysisType,
+		},
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.CacheTTL),
+	})
+	if err != nil {
+		log.Printf("Warning: Could not cache result for %s: %v", opts.Filename, err)
+	}
+}
@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheRoundTripAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := CacheEntry{
+		Key:       "abc",
+		Result:    "cached analysis",
+		Metadata:  map[string]string{"filename": "notes.txt"},
+		CreatedAt: time.Now().Round(0),
+		ExpiresAt: time.Now().Add(time.Hour).Round(0),
+	}
+	if err := c.Set(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store over the same directory stands in for a restart
+	reopened, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := reopened.Get("abc")
+	if err != nil || !ok {
+		t.Fatalf("Get after reopening: ok %t, err %v", ok, err)
+	}
+	if got.Result != entry.Result || got.Metadata["filename"] != "notes.txt" || !got.ExpiresAt.Equal(entry.ExpiresAt) {
+		t.Errorf("Get = %+v, want %+v", got, entry)
+	}
+
+	if err := reopened.Delete("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := reopened.Get("abc"); ok {
+		t.Error("entry still present after Delete")
+	}
+}
+
+func TestDiskCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	c.Set(CacheEntry{Key: "stale", Result: "old", ExpiresAt: past})
+	c.Set(CacheEntry{Key: "stale-too", Result: "old", ExpiresAt: past})
+	c.Set(CacheEntry{Key: "fresh", Result: "new", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if _, ok, _ := c.Get("stale"); ok {
+		t.Error("an expired entry was returned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.json")); !os.IsNotExist(err) {
+		t.Error("an expired entry was not removed on Get")
+	}
+
+	// Opening the store prunes entries that expired while it was closed
+	if _, err := NewDiskCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale-too.json")); !os.IsNotExist(err) {
+		t.Error("an expired entry survived reopening")
+	}
+	if _, ok, _ := c.Get("fresh"); !ok {
+		t.Error("an unexpired entry was pruned")
+	}
+}
+
+func TestDiskCacheIgnoresUnreadableEntry(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "torn.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := c.Get("torn"); ok || err != nil {
+		t.Errorf("an unreadable entry gave ok %t, err %v; want a miss", ok, err)
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	c := NewMemoryCache()
+	c.Set(CacheEntry{Key: "stale", ExpiresAt: time.Now().Add(-time.Second)})
+	c.Set(CacheEntry{Key: "forever", Result: "kept"})
+	if _, ok, _ := c.Get("stale"); ok {
+		t.Error("an expired entry was returned")
+	}
+	if entry, ok, _ := c.Get("forever"); !ok || entry.Result != "kept" {
+		t.Error("an entry without an expiry was not returned")
+	}
+}
+
+func TestAnalyzeFileServedFromDiskCacheAfterRestart(t *testing.T) {
+	cacheDir := t.TempDir()
+	files := map[string]string{"notes.txt": "Some notes."}
+
+	first, err := NewDiskCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, Config{Cache: first}, files)
+	sampler := &mockSampler{}
+	mustSucceed(t, connect(t, s, sampler), "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	second, err := NewDiskCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := newTestServer(t, Config{Cache: second, FilesDir: s.cfg.FilesDir}, nil)
+	c := connect(t, restarted, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want the restarted server to answer from the cache", n)
+	}
+	if !strings.Contains(text, mockAnswer) {
+		t.Errorf("cached result does not carry the answer:\n%s", text)
+	}
+
+	// A call with its own API key never gets a result bought with another
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "api_key": "tenant-key"})
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want a cache miss for a different API key", n)
+	}
+}
@@ -0,0 +1,303 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var generateChangelogTool = mcp.Tool{
+	Name:        "generate_changelog",
+	Description: "Write a changelog entry for a diff or patch file using LLM sampling, with changes grouped into features, fixes, breaking changes and other. Returns JSON with the categories, the files each change touches and the entry as Markdown",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "A unified diff or git patch (relative to files directory)",
+			},
+			"version": map[string]any{
+				"type":        "string",
+				"description": "Version the entry is headed with, e.g. 1.4.0 (default: Unreleased)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// ChangedFile is one file touched by a diff. Status is added, deleted,
+// renamed or modified; From is the old path of a renamed file.
+type ChangedFile struct {
+	Path    string `json:"path"`
+	From    string `json:"from,omitempty"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// ChangelogEntry is one change, with the changed files it concerns.
+type ChangelogEntry struct {
+	Description string   `json:"description"`
+	Files       []string `json:"files,omitempty"`
+}
+
+// Changelog is the structured result of generate_changelog. Truncated
+// reports that the diff was cut to fit one request, so later changes are
+// missing from the categories.
+type Changelog struct {
+	File      string           `json:"file"`
+	Version   string           `json:"version"`
+	Model     string           `json:"model"`
+	Files     []ChangedFile    `json:"changed_files"`
+	Truncated bool             `json:"truncated,omitempty"`
+	Features  []ChangelogEntry `json:"features"`
+	Fixes     []ChangelogEntry `json:"fixes"`
+	Breaking  []ChangelogEntry `json:"breaking"`
+	Other     []ChangelogEntry `json:"other"`
+	Entry     string           `json:"entry"`
+}
+
+func (s *Server) handleGenerateChangelog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	version := request.GetString("version", "Unreleased")
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	files := parseDiffFiles(text)
+	if len(files) == 0 {
+		return errorResult("%s is not a unified diff: no file headers found", filename), nil
+	}
+
+	// Oversized diffs are cut to what fits in one request
+	promptDiff, truncated := truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	var listing strings.Builder
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+		fmt.Fprintf(&listing, "- %s (%s, +%d -%d)\n", f.Path, f.Status, f.Added, f.Removed)
+	}
+	content := mcp.TextContent{Type: "text", Text: fmt.Sprintf("Changed files:\n%s\nDiff:\n%s", listing.String(), promptDiff)}
+
+	systemPrompt := "The content is a diff, preceded by the list of files it changes. Write a changelog entry for it for the project's users: " +
+		"describe each change by its effect, not by the lines edited, and group related edits into one change. " +
+		"Put new capabilities in features, corrected behavior in fixes, changes that require users to change their code, configuration or data in breaking, " +
+		"and everything else worth mentioning (documentation, refactoring, dependencies) in other. A change can be both a feature and breaking; list it under breaking. " +
+		"Give each change the paths, exactly as listed, of the files it concerns. " +
+		`Respond with only a JSON object: {"features": [{"description": "...", "files": ["..."]}], "fixes": [...], "breaking": [...], "other": [...]}, using [] for empty categories.`
+	if truncated {
+		systemPrompt += " The diff was truncated; describe only the changes shown."
+	}
+
+	changelog := Changelog{File: filename, Version: version, Files: files, Truncated: truncated}
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 2000
+
+	logf(ctx, "📤 Sending sampling request for a changelog of %s (%d files)", filename, len(files))
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid changelog", func(text string) error {
+		return parseChangelog(text, paths, &changelog)
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	changelog.Model = result.Model
+	changelog.Entry = changelog.markdown()
+
+	logf(ctx, "✅ Changelog for %s: %d features, %d fixes, %d breaking, %d other", filename,
+		len(changelog.Features), len(changelog.Fixes), len(changelog.Breaking), len(changelog.Other))
+
+	data, err := json.MarshalIndent(changelog, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding changelog: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseChangelog decodes the model's answer into changelog's categories.
+// Every change needs a description, and the files it names must be among
+// paths.
+func parseChangelog(text string, paths []string, changelog *Changelog) error {
+	var answer struct {
+		Features []ChangelogEntry `json:"features"`
+		Fixes    []ChangelogEntry `json:"fixes"`
+		Breaking []ChangelogEntry `json:"breaking"`
+		Other    []ChangelogEntry `json:"other"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	categories := map[string][]ChangelogEntry{"features": answer.Features, "fixes": answer.Fixes, "breaking": answer.Breaking, "other": answer.Other}
+	total := 0
+	for name, entries := range categories {
+		for _, entry := range entries {
+			if strings.TrimSpace(entry.Description) == "" {
+				return fmt.Errorf("a change in %s has no description", name)
+			}
+			for _, file := range entry.Files {
+				if !slices.Contains(paths, file) {
+					return fmt.Errorf("%q in %s is not one of the changed files", file, name)
+				}
+			}
+		}
+		total += len(entries)
+	}
+	if total == 0 {
+		return fmt.Errorf("no changes listed")
+	}
+
+	// Empty categories encode as [] rather than null
+	changelog.Features = append([]ChangelogEntry{}, answer.Features...)
+	changelog.Fixes = append([]ChangelogEntry{}, answer.Fixes...)
+	changelog.Breaking = append([]ChangelogEntry{}, answer.Breaking...)
+	changelog.Other = append([]ChangelogEntry{}, answer.Other...)
+	return nil
+}
+
+// markdown renders the changelog as an entry in the Keep a Changelog style.
+func (c Changelog) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n", c.Version)
+	for _, section := range []struct {
+		title   string
+		entries []ChangelogEntry
+	}{
+		{"Breaking Changes", c.Breaking},
+		{"Features", c.Features},
+		{"Fixes", c.Fixes},
+		{"Other", c.Other},
+	} {
+		if len(section.entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n", section.title)
+		for _, entry := range section.entries {
+			fmt.Fprintf(&b, "- %s\n", strings.TrimSpace(entry.Description))
+		}
+	}
+	return b.String()
+}
+
+// parseDiffFiles lists the files a unified diff or git patch changes, in
+// order, counting the lines added and removed in each. Both git headers
+// ("diff --git a/x b/x") and plain ones ("--- x" and "+++ x") are read.
+func parseDiffFiles(text string) []ChangedFile {
+	var files []ChangedFile
+	var current *ChangedFile
+	var oldPath string
+	// Lines left in the current hunk, from its @@ header
+	var oldLeft, newLeft int
+
+	for _, line := range splitLines(text) {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.Added++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				current.Removed++
+				oldLeft--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file"
+			default:
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, ChangedFile{Status: "modified"})
+			current = &files[len(files)-1]
+			oldPath = ""
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				oldPath, current.Path = strings.TrimPrefix(a, "a/"), b
+			}
+		case current != nil && strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case current != nil && strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "deleted"
+		case current != nil && strings.HasPrefix(line, "rename from "):
+			current.Status, current.From = "renamed", strings.TrimPrefix(line, "rename from ")
+		case current != nil && strings.HasPrefix(line, "rename to "):
+			current.Path = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "--- "):
+			oldPath = diffHeaderPath(line[4:])
+		case strings.HasPrefix(line, "+++ "):
+			newPath := diffHeaderPath(line[4:])
+			// A plain diff has no "diff --git" line; each ---/+++ pair starts a file
+			if current == nil || current.Path != "" && current.Path != newPath && current.Path != oldPath {
+				files = append(files, ChangedFile{Status: "modified"})
+				current = &files[len(files)-1]
+			}
+			switch {
+			case oldPath == "/dev/null":
+				current.Status, current.Path = "added", newPath
+			case newPath == "/dev/null":
+				current.Status, current.Path = "deleted", oldPath
+			default:
+				current.Path = newPath
+			}
+
+		case current != nil && strings.HasPrefix(line, "@@ "):
+			oldLeft, newLeft = hunkLengths(line)
+		}
+	}
+
+	// A git header that never named its file is not a change
+	return slices.DeleteFunc(files, func(f ChangedFile) bool { return f.Path == "" })
+}
+
+// hunkLengths reads the old and new line counts from a hunk header such
+// as "@@ -12,5 +12,7 @@"; a missing count is 1.
+func hunkLengths(header string) (int, int) {
+	var lengths [2]int
+	fields := strings.Fields(header)
+	for i, prefix := range []string{"-", "+"} {
+		lengths[i] = 1
+		for _, field := range fields[1:] {
+			if rest, ok := strings.CutPrefix(field, prefix); ok {
+				if _, count, ok := strings.Cut(rest, ","); ok {
+					lengths[i], _ = strconv.Atoi(count)
+				}
+				break
+			}
+		}
+	}
+	return lengths[0], lengths[1]
+}
+
+// diffHeaderPath returns the path of a ---/+++ header, without the a/ or
+// b/ prefix git adds and any timestamp after a tab.
+func diffHeaderPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return path
+	}
+	if rest, ok := strings.CutPrefix(path, "a/"); ok {
+		return rest
+	}
+	if rest, ok := strings.CutPrefix(path, "b/"); ok {
+		return rest
+	}
+	return path
+}
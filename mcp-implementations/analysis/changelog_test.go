@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const gitDiffFixture = `diff --git a/server.go b/server.go
+index 1a2b3c4..5d6e7f8 100644
+--- a/server.go
++++ b/server.go
+@@ -1,4 +1,4 @@
+ package main
+-// old comment
++// Server serves files.
++// It retries failed requests.
+ func main() {}
+--- not a header, a removed line
+diff --git a/docs/retries.md b/docs/retries.md
+new file mode 100644
+index 0000000..9a8b7c6
+--- /dev/null
++++ b/docs/retries.md
+@@ -0,0 +1,2 @@
++# Retries
++Failed requests are retried.
+diff --git a/legacy.txt b/legacy.txt
+deleted file mode 100644
+index 1234567..0000000
+--- a/legacy.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/util.go b/internal/util.go
+similarity index 100%
+rename from util.go
+rename to internal/util.go
+`
+
+func TestParseDiffFiles(t *testing.T) {
+	want := []ChangedFile{
+		{Path: "server.go", Status: "modified", Added: 2, Removed: 2},
+		{Path: "docs/retries.md", Status: "added", Added: 2},
+		{Path: "legacy.txt", Status: "deleted", Removed: 1},
+		{Path: "internal/util.go", From: "util.go", Status: "renamed"},
+	}
+	if got := parseDiffFiles(gitDiffFixture); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseDiffFiles() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseDiffFilesPlainDiff(t *testing.T) {
+	diff := "--- a.txt\t2024-05-01 10:00:00\n+++ a.txt\t2024-05-02 10:00:00\n@@ -1 +1,2 @@\n-x\n+y\n+z\n" +
+		"--- b.txt\n+++ b.txt\n@@ -1,2 +1 @@\n-x\n-y\n+z\n"
+	want := []ChangedFile{
+		{Path: "a.txt", Status: "modified", Added: 2, Removed: 1},
+		{Path: "b.txt", Status: "modified", Added: 1, Removed: 2},
+	}
+	if got := parseDiffFiles(diff); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseDiffFiles() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGenerateChangelogCategorizesChanges(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"retries.patch": gitDiffFixture})
+	sampler := &mockSampler{respond: answers(`{
+		"features": [{"description": "Failed requests are retried.", "files": ["server.go", "docs/retries.md"]}],
+		"fixes": [],
+		"breaking": [{"description": "legacy.txt is no longer shipped.", "files": ["legacy.txt"]}],
+		"other": [{"description": "Moved util.go to internal/.", "files": ["internal/util.go"]}]
+	}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_changelog", map[string]any{"filename": "retries.patch", "version": "1.4.0"})
+
+	sent := messageText(sampler.Requests()[0])
+	for _, want := range []string{"Changed files:\n- server.go (modified, +2 -2)\n- docs/retries.md (added, +2 -0)\n", "- internal/util.go (renamed, +0 -0)\n", "\nDiff:\ndiff --git"} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("prompt content is missing %q:\n%s", want, sent)
+		}
+	}
+
+	var got Changelog
+	if err := json.Unmarshal([]byte(text), &got); err != nil {
+		t.Fatalf("result is not a changelog: %v\n%s", err, text)
+	}
+	if len(got.Files) != 4 || len(got.Features) != 1 || len(got.Breaking) != 1 || len(got.Other) != 1 || got.Fixes == nil {
+		t.Errorf("unexpected categories: %+v", got)
+	}
+	if !strings.Contains(text, `"fixes": []`) {
+		t.Errorf("an empty category is not encoded as []:\n%s", text)
+	}
+	want := "## 1.4.0\n\n### Breaking Changes\n- legacy.txt is no longer shipped.\n\n### Features\n- Failed requests are retried.\n\n### Other\n- Moved util.go to internal/.\n"
+	if got.Entry != want {
+		t.Errorf("entry =\n%s\nwant\n%s", got.Entry, want)
+	}
+}
+
+func TestGenerateChangelogRepromptsOnUnknownFile(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"retries.patch": gitDiffFixture})
+	sampler := &mockSampler{respond: answers(
+		`{"features": [{"description": "Retries.", "files": ["client.go"]}], "fixes": [], "breaking": [], "other": []}`,
+		`{"features": [{"description": "Retries.", "files": ["server.go"]}], "fixes": [], "breaking": [], "other": []}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_changelog", map[string]any{"filename": "retries.patch"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, `"client.go" in features is not one of the changed files`) {
+		t.Errorf("reprompt does not name the unknown file: %q", requests[1].SystemPrompt)
+	}
+	if !strings.Contains(text, `"version": "Unreleased"`) {
+		t.Errorf("the version does not default to Unreleased:\n%s", text)
+	}
+}
+
+func TestGenerateChangelogRejectsNonDiff(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Just some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "generate_changelog", map[string]any{"filename": "notes.txt"}); !strings.Contains(text, "notes.txt is not a unified diff") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a non-diff sent %d sampling requests", n)
+	}
+}
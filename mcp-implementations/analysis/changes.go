@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var summarizeChangesTool = mcp.Tool{
+	Name:        "summarize_changes",
+	Description: "Summarize what changed between a previous and the current version of a text file using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The current version of the file (relative to files directory)",
+			},
+			"previous_content": map[string]any{
+				"type":        "string",
+				"description": "The previous version's text",
+			},
+			"previous_filename": map[string]any{
+				"type":        "string",
+				"description": "A file holding the previous version, instead of previous_content (relative to files directory)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+func (s *Server) handleSummarizeChanges(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	previousContent := request.GetString("previous_content", "")
+	previousFilename := request.GetString("previous_filename", "")
+
+	if (previousContent == "") == (previousFilename == "") {
+		return errorResult("Provide exactly one of previous_content or previous_filename"), nil
+	}
+
+	current, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	previousName := "previous"
+	if previousFilename != "" {
+		previousContent, err = s.readTextFile(previousFilename)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		previousName = previousFilename
+	} else {
+		if strings.ContainsRune(previousContent, 0) {
+			return errorResult("previous_content looks binary; summarize_changes only compares text"), nil
+		}
+		previousContent, _ = normalizeText([]byte(previousContent))
+	}
+
+	diff, added, removed := unifiedDiff(previousName, filename, previousContent, current, 3)
+	if diff == "" {
+		return textResult(fmt.Sprintf("Change Summary\n"+
+			"==============\n"+
+			"File: %s\n\n"+
+			"No changes: the two versions are identical.", filename)), nil
+	}
+
+	// Oversized diffs are cut to what fits in one request
+	promptDiff, truncated := truncateFor(ctx, diff, s.cfg.ChunkSize)
+
+	systemPrompt := fmt.Sprintf("The content is a unified diff between a previous and the current version of the file '%s'. "+
+		"Summarize what changed and why it matters: describe the semantic changes in behavior, meaning or structure, "+
+		"grouping related edits, rather than restating the diff line by line.", filename)
+	if truncated {
+		systemPrompt += " The diff was truncated; say that the summary covers only the changes shown."
+	}
+
+	logf(ctx, "📤 Sending sampling request to summarize changes in: %s (%d diff bytes)", filename, len(diff))
+	result, err := s.requestSampling(ctx, newSamplingRequest(mcp.TextContent{Type: "text", Text: promptDiff}, systemPrompt))
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+
+	logf(ctx, "✅ Sampling request successful! Model: %s", result.Model)
+
+	return textResult(fmt.Sprintf("Change Summary\n"+
+		"==============\n"+
+		"File: %s\n"+
+		"Compared with: %s\n"+
+		"Diff: +%d -%d lines\n"+
+		"Model: %s\n\n"+
+		"%s", filename, previousName, added, removed, result.Model, resultText(result))), nil
+}
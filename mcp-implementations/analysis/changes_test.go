@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarizeChangesIncludesDiff(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"config.txt": "timeout = 60\nretries = 3\nmode = fast\n",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_changes", map[string]any{
+		"filename":         "config.txt",
+		"previous_content": "timeout = 30\nretries = 3\nmode = fast\n",
+	})
+
+	prompt := messageText(sampler.Requests()[0])
+	for _, want := range []string{"-timeout = 30", "+timeout = 60", " retries = 3"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("sampling request does not carry the diff line %q:\n%s", want, prompt)
+		}
+	}
+	if !strings.Contains(sampler.Requests()[0].SystemPrompt, "semantic changes") {
+		t.Errorf("system prompt does not ask for a semantic summary: %q", sampler.Requests()[0].SystemPrompt)
+	}
+	if !strings.Contains(text, "Diff: +1 -1 lines") || !strings.Contains(text, mockAnswer) {
+		t.Errorf("unexpected result:\n%s", text)
+	}
+}
+
+func TestSummarizeChangesFromPreviousFile(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"v1.md": "# Title\n\nOld text.\n",
+		"v2.md": "# Title\n\nNew text.\n",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_changes", map[string]any{"filename": "v2.md", "previous_filename": "v1.md"})
+	if !strings.Contains(messageText(sampler.Requests()[0]), "-Old text.") || !strings.Contains(text, "Compared with: v1.md") {
+		t.Errorf("the previous file was not diffed:\n%s", text)
+	}
+}
+
+func TestSummarizeChangesIdenticalVersions(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "same\n"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_changes", map[string]any{"filename": "notes.txt", "previous_content": "same\n"})
+	if !strings.Contains(text, "No changes") || len(sampler.Requests()) != 0 {
+		t.Errorf("identical versions should not be sampled:\n%s", text)
+	}
+}
+
+func TestSummarizeChangesRejectsBinary(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"logo.png":  "\x89PNG\r\n\x1a\n\x00\x00",
+		"notes.txt": "text\n",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "summarize_changes", map[string]any{"filename": "logo.png", "previous_content": "old"}); !strings.Contains(text, "not a text file") {
+		t.Errorf("binary file error: %s", text)
+	}
+	if text := mustFail(t, c, "summarize_changes", map[string]any{"filename": "notes.txt", "previous_content": "old\x00binary"}); !strings.Contains(text, "looks binary") {
+		t.Errorf("binary previous_content error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("binary input sent %d sampling requests, want none", n)
+	}
+}
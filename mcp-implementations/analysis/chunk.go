@@ -0,0 +1,248 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultChunkSize is the largest text, in bytes, sent in one sampling
+// request (~12k tokens). Longer text files are analyzed chunk by chunk.
+const DefaultChunkSize = 50000
+
+// DefaultReducePrompt is the combine instruction used when a chunked
+// analysis does not set reduce_prompt.
+const DefaultReducePrompt = "Combine them into a single answer for the whole file, merging overlapping points without repeating them."
+
+// splitChunks cuts text into pieces of at most size bytes, preferring to
+// break after a newline and never splitting a UTF-8 sequence.
+func splitChunks(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := size
+		if nl := strings.LastIndexByte(text[:size], '\n'); nl >= size/2 {
+			cut = nl + 1
+		} else {
+			for cut > 1 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	return append(chunks, text)
+}
+
+// textChunks is the text of a long file split into chunks, either held in
+// memory or read back from disk one chunk at a time.
+type textChunks interface {
+	Len() int
+	Chunk(i int) (string, error)
+	// Sum is a hash of the whole text, which keys saved partial results
+	Sum() [sha256.Size]byte
+}
+
+// splitText is text already in memory, split with splitChunks.
+type splitText struct {
+	chunks []string
+	sum    [sha256.Size]byte
+}
+
+func newSplitText(text []byte, size int) *splitText {
+	return &splitText{chunks: splitChunks(string(text), size), sum: sha256.Sum256(text)}
+}
+
+func (t *splitText) Len() int                    { return len(t.chunks) }
+func (t *splitText) Chunk(i int) (string, error) { return t.chunks[i], nil }
+func (t *splitText) Sum() [sha256.Size]byte      { return t.sum }
+
+// chunkProgress is the on-disk record of which chunks of an analysis have
+// already been sampled.
+type chunkProgress struct {
+	Filename string         `json:"filename"`
+	Total    int            `json:"total"`
+	Results  map[int]string `json:"results"`
+}
+
+// partialStore persists per-chunk results so an interrupted chunked
+// analysis can resume where it stopped instead of starting over.
+type partialStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// partialKey identifies one chunked analysis: the same file content with the
+// same prompt, chunking and sampling parameters, from the same caller,
+// produces the same key, so a resumed run never mixes in chunks sampled with
+// another model, temperature or seed, or with another caller's API key.
+func partialKey(ctx context.Context, contentHash [sha256.Size]byte, prompt string, chunkSize int, opts analyzeOptions) string {
+	var params mcp.CreateMessageRequest
+	opts.applyTo(&params)
+	seed := "none"
+	if opts.Seed != nil {
+		seed = fmt.Sprint(*opts.Seed)
+	}
+	settingsHash := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%v\x00%s\x00%s",
+		chunkSize, prompt, opts.Model, params.Temperature, seed, callerKeyHash(ctx))))
+	return hex.EncodeToString(contentHash[:]) + "-" + hex.EncodeToString(settingsHash[:8])
+}
+
+func (p *partialStore) path(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+// load returns saved progress for key, or an empty record.
+func (p *partialStore) load(key, filename string, total int) *chunkProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress := &chunkProgress{Filename: filename, Total: total, Results: map[int]string{}}
+	data, err := os.ReadFile(p.path(key))
+	if err != nil {
+		return progress
+	}
+
+	var saved chunkProgress
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Total != total || saved.Results == nil {
+		log.Printf("Warning: Ignoring unusable partial results for %s", filename)
+		return progress
+	}
+	return &saved
+}
+
+// save writes progress for key, replacing the file atomically.
+func (p *partialStore) save(key string, progress *chunkProgress) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmp := p.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path(key))
+}
+
+// clear removes saved progress for key.
+func (p *partialStore) clear(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.Remove(p.path(key)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Could not remove partial results: %v", err)
+	}
+}
+
+// analyzeChunked samples each chunk of a long text file, then asks for one
+// combined answer. Completed chunks are saved as they finish so a retry
+// with resume enabled only samples the chunks that are still missing.
+// source is the whole text for verifying citations, and nil when the
+// chunks are streamed from disk.
+func (s *Server) analyzeChunked(ctx context.Context, opts analyzeOptions, mimeType string, chunks textChunks, source []byte, basePrompt string) (*mcp.CallToolResult, error) {
+	if opts.ChunkMode == ChunkModeWindow {
+		return s.analyzeWindowed(ctx, opts, mimeType, chunks, source, basePrompt)
+	}
+
+	filename := opts.Filename
+	total := chunks.Len()
+
+	// The map prompt analyzes each chunk, the reduce prompt combines them
+	mapPrompt := basePrompt
+	if opts.MapPrompt != "" {
+		mapPrompt = opts.MapPrompt
+	}
+	reducePrompt := DefaultReducePrompt
+	if opts.ReducePrompt != "" {
+		reducePrompt = opts.ReducePrompt
+	}
+
+	// Saved chunk results are only reusable under the same map prompt
+	key := partialKey(ctx, chunks.Sum(), mapPrompt, s.cfg.ChunkSize, opts)
+
+	if !opts.Resume {
+		// Start over, discarding whatever an earlier attempt saved
+		s.partials.clear(key)
+	}
+	progress := s.partials.load(key, filename, total)
+	resumed := len(progress.Results)
+	if resumed > 0 {
+		logf(ctx, "↩️  Resuming chunked analysis of %s: %d of %d chunks already done", filename, resumed, total)
+	}
+
+	for i := range total {
+		if _, done := progress.Results[i]; done {
+			continue
+		}
+		doneRead := timePhase(ctx, phaseRead)
+		chunk, err := chunks.Chunk(i)
+		doneRead()
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+
+		systemPrompt := fmt.Sprintf("%s The content is part %d of %d of a %s file named '%s'. "+
+			"Focus on this part; the results for all parts will be combined afterwards.", mapPrompt, i+1, total, mimeType, filename)
+
+		logf(ctx, "📤 Sending sampling request for file: %s chunk %d/%d (analysis: %s)", filename, i+1, total, opts.AnalysisType)
+		chunkRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: chunk}, systemPrompt)
+		opts.applyTo(&chunkRequest)
+		result, err := s.requestSampling(ctx, chunkRequest)
+		if err != nil {
+			log.Printf("❌ Sampling request failed: %v", err)
+			return errorResult("Error requesting sampling for chunk %d of %d: %v\n"+
+				"%d chunks are saved; call again with resume enabled to continue from chunk %d.",
+				i+1, total, err, len(progress.Results), i+1), nil
+		}
+
+		progress.Results[i] = resultText(result)
+		if err := s.partials.save(key, progress); err != nil {
+			log.Printf("Warning: Could not save partial results: %v", err)
+		}
+	}
+
+	// Reduce: combine the per-chunk answers into one
+	parts := make([]string, total)
+	for i := range total {
+		parts[i] = fmt.Sprintf("Part %d of %d:\n%s", i+1, total, progress.Results[i])
+	}
+	systemPrompt := fmt.Sprintf("%s The content is a set of analyses of consecutive parts of a %s file named '%s'. %s",
+		basePrompt, mimeType, filename, reducePrompt)
+
+	logf(ctx, "📤 Sending sampling request to combine %d chunks of %s", total, filename)
+	reduceRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: strings.Join(parts, "\n\n")}, systemPrompt)
+	opts.applyTo(&reduceRequest)
+	result, err := s.sampleToLength(ctx, reduceRequest, opts.TargetLength, s.requestSampling)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling to combine chunks: %v\n"+
+			"All %d chunks are saved; call again with resume enabled to retry only the combine step.", err, total), nil
+	}
+
+	logf(ctx, "✅ Chunked analysis successful! Model: %s", result.Model)
+	s.partials.clear(key)
+
+	return textResult(fmt.Sprintf("File Analysis Results\n"+
+		"=====================\n"+
+		"File: %s\n"+
+		"Type: %s\n"+
+		"Analysis: %s\n"+
+		"Model: %s\n"+
+		"Chunks: %d (%d resumed)\n\n"+
+		"%s", filename, mimeType, opts.AnalysisType, result.Model, total, resumed, opts.answerText(result, source))), nil
+}
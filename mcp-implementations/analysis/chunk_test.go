@@ -0,0 +1,197 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// chunkedFile returns text that splits into n chunks of chunkSize bytes,
+// one line per chunk.
+func chunkedFile(n, chunkSize int) string {
+	var b strings.Builder
+	for i := range n {
+		line := fmt.Sprintf("chunk %d ", i)
+		b.WriteString(line + strings.Repeat("x", chunkSize-len(line)-1) + "\n")
+	}
+	return b.String()
+}
+
+var chunkPartRe = regexp.MustCompile(`part (\d+) of \d+`)
+
+// chunkPart returns which chunk a sampling request is for, or 0 for the
+// combine request.
+func chunkPart(request mcp.CreateMessageRequest) int {
+	match := chunkPartRe.FindStringSubmatch(request.SystemPrompt)
+	if match == nil || strings.Contains(request.SystemPrompt, "set of analyses") {
+		return 0
+	}
+	var part int
+	fmt.Sscan(match[1], &part)
+	return part
+}
+
+// failingChunk answers every request except those for chunk failAt while
+// fail is set.
+type failingChunk struct {
+	mu     sync.Mutex
+	failAt int
+	fail   bool
+}
+
+func (f *failingChunk) respond(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	part := chunkPart(request)
+	if f.fail && part == f.failAt {
+		return nil, errors.New("provider unavailable")
+	}
+	return textAnswer(fmt.Sprintf("analysis of part %d", part)), nil
+}
+
+func (f *failingChunk) recover() {
+	f.mu.Lock()
+	f.fail = false
+	f.mu.Unlock()
+}
+
+// sampledParts lists the chunk of each request, 0 being the combine step.
+func sampledParts(requests []mcp.CreateMessageRequest) []int {
+	var parts []int
+	for _, request := range requests {
+		parts = append(parts, chunkPart(request))
+	}
+	return parts
+}
+
+func TestChunkedAnalysisResumesAfterFailure(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(4, 100)})
+	failer := &failingChunk{failAt: 3, fail: true}
+	sampler := &mockSampler{respond: failer.respond}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "long.txt"})
+	if !strings.Contains(text, "chunk 3 of 4") || !strings.Contains(text, "2 chunks are saved") {
+		t.Fatalf("failure does not report the saved chunks:\n%s", text)
+	}
+	if got := fmt.Sprint(sampledParts(sampler.Requests())); got != "[1 2 3]" {
+		t.Fatalf("first run sampled parts %s, want [1 2 3]", got)
+	}
+
+	failer.recover()
+	_, text = mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt"})
+	if got := fmt.Sprint(sampledParts(sampler.Requests()[3:])); got != "[3 4 0]" {
+		t.Errorf("retry sampled parts %s, want only the remaining chunks and the combine step [3 4 0]", got)
+	}
+	if !strings.Contains(text, "Chunks: 4 (2 resumed)") {
+		t.Errorf("result does not report the resumed chunks:\n%s", text)
+	}
+	combine := messageText(sampler.Requests()[len(sampler.Requests())-1])
+	for part := 1; part <= 4; part++ {
+		if !strings.Contains(combine, fmt.Sprintf("analysis of part %d", part)) {
+			t.Errorf("combine request is missing part %d:\n%s", part, combine)
+		}
+	}
+
+	entries, err := os.ReadDir(s.cfg.PartialsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("partial results were not cleared after success: %v", entries)
+	}
+}
+
+func TestChunkedAnalysisWithoutResumeStartsOver(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(3, 100)})
+	failer := &failingChunk{failAt: 2, fail: true}
+	sampler := &mockSampler{respond: failer.respond}
+	c := connect(t, s, sampler)
+
+	// use_cache is off so chunk 1 is not served from the response cache
+	mustFail(t, c, "analyze_file", map[string]any{"filename": "long.txt", "use_cache": false})
+	failer.recover()
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "resume": false, "use_cache": false})
+
+	if got := fmt.Sprint(sampledParts(sampler.Requests()[2:])); got != "[1 2 3 0]" {
+		t.Errorf("retry without resume sampled parts %s, want every chunk again [1 2 3 0]", got)
+	}
+	if !strings.Contains(text, "Chunks: 3 (0 resumed)") {
+		t.Errorf("result reports resumed chunks without resume:\n%s", text)
+	}
+}
+
+func TestPartialKeyDependsOnSamplingOptions(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	seed, temperature := 7, 0.9
+	base := analyzeOptions{AnalysisType: "summarize"}
+	ctx := context.Background()
+	key := partialKey(ctx, sum, "prompt", 100, base)
+
+	if partialKey(ctx, sum, "prompt", 100, base) != key {
+		t.Fatal("partialKey is not stable for the same inputs")
+	}
+	variants := map[string]string{
+		"prompt":      partialKey(ctx, sum, "other prompt", 100, base),
+		"chunk size":  partialKey(ctx, sum, "prompt", 200, base),
+		"model":       partialKey(ctx, sum, "prompt", 100, analyzeOptions{AnalysisType: "summarize", Model: "smart"}),
+		"seed":        partialKey(ctx, sum, "prompt", 100, analyzeOptions{AnalysisType: "summarize", Seed: &seed}),
+		"temperature": partialKey(ctx, sum, "prompt", 100, analyzeOptions{AnalysisType: "summarize", Temperature: &temperature}),
+		"caller key":  partialKey(context.WithValue(ctx, apiKeyContextKey{}, "sk-other"), sum, "prompt", 100, base),
+	}
+	for what, other := range variants {
+		if other == key {
+			t.Errorf("changing the %s does not change the key", what)
+		}
+	}
+}
+
+func TestChunkedAnalysisUsesMapAndReducePrompts(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(2, 100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{
+		"filename":      "long.txt",
+		"map_prompt":    "List every name mentioned.",
+		"reduce_prompt": "Merge the lists without duplicates.",
+	})
+
+	requests := sampler.Requests()
+	if got := fmt.Sprint(sampledParts(requests)); got != "[1 2 0]" {
+		t.Fatalf("sampled parts %s, want two chunks and the combine step", got)
+	}
+	for _, request := range requests[:2] {
+		if !strings.HasPrefix(request.SystemPrompt, "List every name mentioned.") || strings.Contains(request.SystemPrompt, "Merge the lists") {
+			t.Errorf("chunk request does not use the map prompt alone: %q", request.SystemPrompt)
+		}
+	}
+	if reduce := requests[2].SystemPrompt; !strings.HasSuffix(reduce, "Merge the lists without duplicates.") || strings.Contains(reduce, "List every name") {
+		t.Errorf("combine request does not use the reduce prompt alone: %q", reduce)
+	}
+}
+
+func TestChunkedAnalysisDefaultPrompts(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(2, 100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "analysis_type": "explain"})
+
+	requests := sampler.Requests()
+	explain, _ := lookupAnalysisType("explain")
+	if !strings.HasPrefix(requests[0].SystemPrompt, explain.Prompt) {
+		t.Errorf("chunk request does not default to the analysis prompt: %q", requests[0].SystemPrompt)
+	}
+	if !strings.HasSuffix(requests[len(requests)-1].SystemPrompt, DefaultReducePrompt) {
+		t.Errorf("combine request does not default to DefaultReducePrompt: %q", requests[len(requests)-1].SystemPrompt)
+	}
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// citationsPrompt asks the model to back its claims with exact quotes in a
+// section splitCitations can find.
+const citationsPrompt = " After your answer, add a line containing only \"CITATIONS:\" followed by the quotes from the source that " +
+	"support your claims, one per line as - \"exact quote\". Copy each quote character for character from the source; do not paraphrase."
+
+var citationsHeading = regexp.MustCompile(`(?im)^[#*\s]*CITATIONS[*\s]*:[*]*\s*$`)
+
+// quotedSpan matches one quote in straight or curly double quotes.
+var quotedSpan = regexp.MustCompile(`["“]([^"”]+)["”]`)
+
+// splitCitations separates the answer from its citations section and
+// returns the quotes listed there.
+func splitCitations(text string) (string, []string) {
+	loc := citationsHeading.FindStringIndex(text)
+	if loc == nil {
+		return text, nil
+	}
+
+	var quotes []string
+	for _, line := range strings.Split(text[loc[1]:], "\n") {
+		if m := quotedSpan.FindStringSubmatch(line); m != nil {
+			quotes = append(quotes, strings.TrimSpace(m[1]))
+		}
+	}
+	return strings.TrimSpace(text[:loc[0]]), quotes
+}
+
+// verifyCitations sorts quotes into those found in the source and those
+// that are not, comparing case-insensitively with whitespace collapsed so
+// line wrapping in the source does not count as a mismatch.
+func verifyCitations(source string, quotes []string) (verified, unverified []string) {
+	haystack := normalizeForQuote(source)
+	for _, quote := range quotes {
+		needle := normalizeForQuote(strings.Trim(quote, ".… "))
+		if needle != "" && strings.Contains(haystack, needle) {
+			verified = append(verified, quote)
+		} else {
+			unverified = append(unverified, quote)
+		}
+	}
+	return verified, unverified
+}
+
+func normalizeForQuote(s string) string {
+	s = strings.NewReplacer("’", "'", "‘", "'", "“", `"`, "”", `"`).Replace(s)
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// formatCitations renders the verified and unverified quotes.
+func formatCitations(verified, unverified []string) string {
+	if len(verified)+len(unverified) == 0 {
+		return "Citations\n---------\n(The response included no citations.)"
+	}
+	return citationList(fmt.Sprintf("Verified Citations (%d)", len(verified)), verified) + "\n\n" +
+		citationList(fmt.Sprintf("Unverified Citations (%d, not found in the source)", len(unverified)), unverified)
+}
+
+func citationList(title string, quotes []string) string {
+	lines := []string{title, strings.Repeat("-", len(title))}
+	for _, q := range quotes {
+		lines = append(lines, fmt.Sprintf("- %q", q))
+	}
+	if len(quotes) == 0 {
+		lines = append(lines, "(none)")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const citationSource = "The council approved the new budget on Monday.\n" +
+	"Funding for the library will rise by ten percent,\nwhile road repairs are delayed until spring.\n"
+
+func TestAnalyzeFileCatchesFabricatedQuote(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"minutes.txt": citationSource})
+	sampler := &mockSampler{respond: answers("The council passed a budget that favors the library.\n\n" +
+		"CITATIONS:\n" +
+		"- \"The council approved the new budget on Monday.\"\n" +
+		"- “Funding for the library will rise by ten percent, while road repairs”\n" +
+		"- \"The mayor called it a historic victory.\"\n")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "minutes.txt", "with_citations": true})
+
+	if !strings.Contains(sampler.Requests()[0].SystemPrompt, "CITATIONS:") {
+		t.Errorf("system prompt does not ask for citations: %q", sampler.Requests()[0].SystemPrompt)
+	}
+	verified := text[strings.Index(text, "Verified Citations"):strings.Index(text, "Unverified Citations")]
+	unverified := text[strings.Index(text, "Unverified Citations"):]
+
+	if !strings.Contains(text, "Verified Citations (2)") || !strings.Contains(text, "Unverified Citations (1, not found in the source)") {
+		t.Errorf("unexpected citation counts:\n%s", text)
+	}
+	if !strings.Contains(verified, "approved the new budget") || !strings.Contains(verified, "library will rise") {
+		t.Errorf("quotes from the source, one wrapped across lines, are not verified:\n%s", verified)
+	}
+	if !strings.Contains(unverified, "historic victory") || strings.Contains(verified, "historic victory") {
+		t.Errorf("the fabricated quote is not flagged:\n%s", text)
+	}
+	if strings.Contains(text, "CITATIONS:") {
+		t.Errorf("the raw citations section was left in the answer:\n%s", text)
+	}
+}
+
+func TestAnalyzeFileWithoutCitationsSection(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"minutes.txt": citationSource})
+	c := connect(t, s, &mockSampler{respond: answers("A summary without quotes.")})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "minutes.txt", "with_citations": true})
+	if !strings.Contains(text, "(The response included no citations.)") {
+		t.Errorf("a missing citations section is not reported:\n%s", text)
+	}
+}
+
+func TestVerifyCitationsIgnoresCaseAndCurlyQuotes(t *testing.T) {
+	verified, unverified := verifyCitations("It’s the BUDGET   for\nnext year.", []string{"it's the budget for next year...", "not there"})
+	if len(verified) != 1 || len(unverified) != 1 || unverified[0] != "not there" {
+		t.Errorf("verified %q, unverified %q", verified, unverified)
+	}
+}
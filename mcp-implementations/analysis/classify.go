@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var classifyFileTool = mcp.Tool{
+	Name:        "classify_file",
+	Description: "Classify a file into exactly one of the given categories using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The name of the file to classify (relative to files directory)",
+			},
+			"categories": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "The allowed categories; the answer is always one of these",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename", "categories"},
+	},
+}
+
+// classification is the answer classify_file asks the model for.
+type classification struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+func (s *Server) handleClassifyFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	categories, err := request.RequireStringSlice("categories")
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []string
+	for _, c := range categories {
+		if c = strings.TrimSpace(c); c != "" {
+			allowed = append(allowed, c)
+		}
+	}
+	if len(allowed) < 2 {
+		return errorResult("Provide at least two categories to choose from"), nil
+	}
+
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return errorResult("Error reading file: %v", err), nil
+	}
+
+	mimeType := mimeTypeFor(filename)
+	if isTextFile(filename, mimeType) {
+		text, _ := normalizeText(fileContent)
+		// Part of a long file, by default its opening, is enough to classify it
+		text, _ = truncateFor(ctx, text, s.cfg.ChunkSize)
+		fileContent = []byte(text)
+	}
+
+	categoryList, _ := json.Marshal(allowed)
+	basePrompt := fmt.Sprintf("Classify this content into exactly one of these categories: %s. "+
+		"Respond with only a JSON object of the form "+
+		`{"category": "<one of the categories, spelled exactly as given>", "confidence": <0.0 to 1.0>, "reason": "<one sentence>"}.`, categoryList)
+	content, systemPrompt, err := buildContent(filename, mimeType, fileContent, basePrompt, s.cfg.BinaryPolicy)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	var answer classification
+	var model string
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 300
+
+	logf(ctx, "📤 Sending sampling request to classify file: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "one of the allowed categories", func(text string) error {
+		var ok bool
+		if answer, ok = parseClassification(text, allowed); !ok {
+			return fmt.Errorf("%q is not one of the allowed categories; choose one of exactly: %s", answer.Category, categoryList)
+		}
+		return nil
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	model = result.Model
+
+	logf(ctx, "✅ Classified %s as %s", filename, answer.Category)
+
+	return textResult(fmt.Sprintf("File Classification Results\n"+
+		"===========================\n"+
+		"File: %s\n"+
+		"Category: %s\n"+
+		"Confidence: %.2f\n"+
+		"Model: %s\n\n"+
+		"%s", filename, answer.Category, answer.Confidence, model, answer.Reason)), nil
+}
+
+// parseClassification reads the model's JSON answer and maps its category
+// onto the allowed set, ignoring case and surrounding whitespace. It reports
+// false when the answer is not one of the allowed categories.
+func parseClassification(text string, allowed []string) (classification, bool) {
+	var answer classification
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		// Some models answer with just the category name
+		answer.Category = strings.Trim(strings.TrimSpace(text), `"'.`)
+	}
+
+	for _, c := range allowed {
+		if strings.EqualFold(strings.TrimSpace(answer.Category), c) {
+			answer.Category = c
+			answer.Confidence = min(max(answer.Confidence, 0), 1)
+			return answer, true
+		}
+	}
+	return answer, false
+}
+
+// jsonObject returns the outermost {...} in text, dropping any prose or
+// code fences a model wrapped around its JSON answer.
+func jsonObject(text string) string {
+	start := strings.IndexByte(text, '{')
+	end := strings.LastIndexByte(text, '}')
+	if start < 0 || end < start {
+		return text
+	}
+	return text[start : end+1]
+}
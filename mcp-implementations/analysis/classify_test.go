@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+var triageCategories = []string{"bug", "feature", "question"}
+
+func TestClassifyFileReturnsAllowedCategory(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"issue.txt": "The app crashes when I click save."})
+	sampler := &mockSampler{respond: answers("```json\n" + `{"category": "Bug", "confidence": 1.7, "reason": "It describes a crash."}` + "\n```")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "classify_file", map[string]any{"filename": "issue.txt", "categories": triageCategories})
+
+	for _, want := range []string{"Category: bug\n", "Confidence: 1.00", "It describes a crash."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("a valid answer took %d requests, want 1", n)
+	}
+	if !strings.Contains(sampler.Requests()[0].SystemPrompt, `["bug","feature","question"]`) {
+		t.Errorf("system prompt does not list the categories: %q", sampler.Requests()[0].SystemPrompt)
+	}
+}
+
+func TestClassifyFileRepromptsOnUnknownCategory(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"issue.txt": "Please add dark mode."})
+	sampler := &mockSampler{respond: answers(
+		`{"category": "enhancement", "confidence": 0.8, "reason": "A request."}`,
+		`{"category": "feature", "confidence": 0.9, "reason": "A request for dark mode."}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "classify_file", map[string]any{"filename": "issue.txt", "categories": triageCategories})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, `"enhancement" is not one of the allowed categories`) {
+		t.Errorf("reprompt does not name the rejected category: %q", requests[1].SystemPrompt)
+	}
+	if !strings.Contains(text, "Category: feature\n") {
+		t.Errorf("result does not carry the corrected category:\n%s", text)
+	}
+}
+
+func TestClassifyFileNeverReturnsOutsideCategory(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"issue.txt": "Hello."})
+	sampler := &mockSampler{respond: answers(`{"category": "spam"}`)}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "classify_file", map[string]any{"filename": "issue.txt", "categories": triageCategories})
+	if !strings.Contains(text, "did not return one of the allowed categories after a retry") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want 2", n)
+	}
+}
+
+func TestParseClassificationAcceptsBareCategory(t *testing.T) {
+	answer, ok := parseClassification(" Question. ", triageCategories)
+	if !ok || answer.Category != "question" {
+		t.Errorf("parseClassification = %+v, %t; want question", answer, ok)
+	}
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ClientInfo is what a connected client declared when it initialized.
+type ClientInfo struct {
+	SessionID       string
+	Name            string
+	Version         string
+	ProtocolVersion string
+	// Sampling reports whether the client can answer sampling requests.
+	// Without it, every tool that samples will wait until it times out.
+	Sampling    bool
+	Roots       bool
+	ConnectedAt time.Time
+}
+
+// clientRegistry tracks connected clients by session ID.
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]ClientInfo
+}
+
+func (r *clientRegistry) record(info ClientInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[info.SessionID] = info
+}
+
+func (r *clientRegistry) remove(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, sessionID)
+}
+
+func (r *clientRegistry) get(sessionID string) (ClientInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, ok := r.clients[sessionID]
+	return info, ok
+}
+
+// list returns the connected clients, oldest first.
+func (r *clientRegistry) list() []ClientInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]ClientInfo, 0, len(r.clients))
+	for _, info := range r.clients {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ConnectedAt.Before(infos[j].ConnectedAt) })
+	return infos
+}
+
+// clientHooks records clients as they initialize and forgets them when
+// their session ends.
+func (s *Server) clientHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+
+	hooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		session := server.ClientSessionFromContext(ctx)
+		if session == nil {
+			return
+		}
+
+		info := ClientInfo{
+			SessionID:       session.SessionID(),
+			Name:            message.Params.ClientInfo.Name,
+			Version:         message.Params.ClientInfo.Version,
+			ProtocolVersion: message.Params.ProtocolVersion,
+			Sampling:        message.Params.Capabilities.Sampling != nil,
+			Roots:           message.Params.Capabilities.Roots != nil,
+			ConnectedAt:     time.Now(),
+		}
+		s.clients.record(info)
+
+		if s.cfg.Debug {
+			log.Printf("🔌 Client initialized: %s v%s (session %s, protocol %s) capabilities: sampling=%t roots=%t experimental=%d",
+				info.Name, info.Version, info.SessionID, info.ProtocolVersion, info.Sampling, info.Roots, len(message.Params.Capabilities.Experimental))
+		}
+		if !info.Sampling {
+			log.Printf("Warning: Client %s (session %s) does not support sampling; analysis tools will time out for it", info.Name, info.SessionID)
+		}
+	})
+
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.clients.remove(session.SessionID())
+		if s.cfg.Debug {
+			log.Printf("🔌 Client session ended: %s", session.SessionID())
+		}
+	})
+
+	return hooks
+}
+
+// Clients returns the currently connected clients and their declared
+// capabilities.
+func (s *Server) Clients() []ClientInfo {
+	return s.clients.list()
+}
+
+// Client returns the capabilities a session declared, if it initialized.
+func (s *Server) Client(sessionID string) (ClientInfo, bool) {
+	return s.clients.get(sessionID)
+}
@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestClientRegistryRecordsSamplingCapability(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	connect(t, s, &mockSampler{})
+
+	clients := s.Clients()
+	if len(clients) != 1 {
+		t.Fatalf("registry holds %d clients, want 1", len(clients))
+	}
+	info := clients[0]
+	if !info.Sampling || info.Name != "test-client" || info.Version != "1.0.0" || info.SessionID == "" {
+		t.Errorf("recorded %+v, want a sampling-capable test-client 1.0.0", info)
+	}
+}
+
+// plainSession is a client session for a client without a sampling
+// handler, which the in-process transport does not give a session.
+type plainSession struct{ notifications chan mcp.JSONRPCNotification }
+
+func (p plainSession) Initialize()                                         {}
+func (p plainSession) Initialized() bool                                   { return true }
+func (p plainSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return p.notifications }
+func (p plainSession) SessionID() string                                   { return "plain-session" }
+
+func TestClientRegistryRecordsClientWithoutSampling(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	session := plainSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	ctx := s.MCPServer().WithContext(context.Background(), session)
+
+	s.MCPServer().HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{`+
+		`"protocolVersion":"`+mcp.LATEST_PROTOCOL_VERSION+`","capabilities":{},"clientInfo":{"name":"plain-client","version":"0.1.0"}}}`))
+
+	info, ok := s.Client("plain-session")
+	if !ok || info.Name != "plain-client" || info.Sampling {
+		t.Errorf("recorded %+v (found %t), want plain-client without sampling", info, ok)
+	}
+}
@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var checkComplianceTool = mcp.Tool{
+	Name:        "check_compliance",
+	Description: "Check whether a text file meets the requirements of a reference template or ruleset using LLM sampling, returning pass/fail per rule as JSON",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to check (relative to files directory)",
+			},
+			"template": map[string]any{
+				"type":        "string",
+				"description": "The reference document or ruleset to check against (relative to files directory)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "template"},
+	},
+}
+
+// complianceStatuses are the verdicts a rule can get.
+var complianceStatuses = []string{"pass", "fail", "not_applicable"}
+
+// ComplianceRule is the verdict on one requirement of the template.
+type ComplianceRule struct {
+	Rule   string `json:"rule"`
+	Status string `json:"status"`
+	// Evidence quotes or points to the part of the file behind the verdict
+	Evidence string `json:"evidence"`
+}
+
+// ComplianceReport is the structured result of check_compliance.
+// Compliant is computed by the server: true when no rule failed.
+type ComplianceReport struct {
+	File      string           `json:"file"`
+	Template  string           `json:"template"`
+	Model     string           `json:"model"`
+	Compliant bool             `json:"compliant"`
+	Passed    int              `json:"passed"`
+	Failed    int              `json:"failed"`
+	Rules     []ComplianceRule `json:"rules"`
+}
+
+func (s *Server) handleCheckCompliance(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	template, err := request.RequireString("template")
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	templateText, err := s.readTextFile(template)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if strings.TrimSpace(templateText) == "" {
+		return errorResult("Template %s is empty", template), nil
+	}
+	// Both documents go in one request, so together they must fit in a chunk
+	if len(text)+len(templateText) > s.cfg.ChunkSize {
+		return errorResult("%s and %s together are %d bytes, more than the %d bytes that fit in one request",
+			filename, template, len(text)+len(templateText), s.cfg.ChunkSize), nil
+	}
+
+	content := mcp.TextContent{
+		Type: "text",
+		Text: fmt.Sprintf("<template name=%q>\n%s\n</template>\n\n<document name=%q>\n%s\n</document>", template, templateText, filename, text),
+	}
+	systemPrompt := "The user message contains a template (a reference document or ruleset) and a document. " +
+		"List every requirement the template sets out and decide, using only the two texts given, whether the document meets it. " +
+		`Respond with only a JSON object: {"rules": [{"rule": "<the requirement>", "status": "pass" | "fail" | "not_applicable", "evidence": "<short quote from or reference to the document>"}]}.`
+
+	var rules []ComplianceRule
+	var model string
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 3000
+
+	logf(ctx, "📤 Sending sampling request to check %s against %s", filename, template)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid rule list", func(text string) (err error) {
+		rules, err = parseComplianceRules(text)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	model = result.Model
+
+	report := ComplianceReport{File: filename, Template: template, Model: model, Rules: rules}
+	for _, r := range rules {
+		switch r.Status {
+		case "pass":
+			report.Passed++
+		case "fail":
+			report.Failed++
+		}
+	}
+	report.Compliant = report.Failed == 0
+
+	logf(ctx, "✅ Compliance check of %s: %d passed, %d failed", filename, report.Passed, report.Failed)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding compliance report: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseComplianceRules decodes the model's verdicts, normalizing statuses
+// to lowercase and rejecting empty lists, empty rules and unknown statuses.
+func parseComplianceRules(text string) ([]ComplianceRule, error) {
+	var answer struct {
+		Rules []ComplianceRule `json:"rules"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if len(answer.Rules) == 0 {
+		return nil, fmt.Errorf("no rules listed")
+	}
+
+	for i := range answer.Rules {
+		r := &answer.Rules[i]
+		if strings.TrimSpace(r.Rule) == "" {
+			return nil, fmt.Errorf("rule %d has no text", i+1)
+		}
+		r.Status = strings.ToLower(strings.TrimSpace(r.Status))
+		if !slices.Contains(complianceStatuses, r.Status) {
+			return nil, fmt.Errorf("rule %d has status %q, expected one of %s", i+1, r.Status, strings.Join(complianceStatuses, ", "))
+		}
+	}
+	return answer.Rules, nil
+}
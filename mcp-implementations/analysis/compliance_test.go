@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const complianceTemplate = "Every policy must:\n1. Have a title.\n2. Name an owner.\n3. Give a review date.\n"
+
+const compliancePolicy = "# Password Policy\n\nOwner: Security team\n\nPasswords must be at least 12 characters.\n"
+
+func TestCheckComplianceReportsPassAndFail(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"template.md": complianceTemplate, "policy.md": compliancePolicy})
+	sampler := &mockSampler{respond: answers(`{"rules": [` +
+		`{"rule": "Have a title", "status": "pass", "evidence": "# Password Policy"},` +
+		`{"rule": "Name an owner", "status": "PASS", "evidence": "Owner: Security team"},` +
+		`{"rule": "Give a review date", "status": "fail", "evidence": "No review date is given"}]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "check_compliance", map[string]any{"filename": "policy.md", "template": "template.md"})
+
+	var report ComplianceReport
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatalf("check_compliance did not return JSON: %v\n%s", err, text)
+	}
+	if report.Passed != 2 || report.Failed != 1 || report.Compliant {
+		t.Errorf("report has %d passed, %d failed, compliant %t; want 2, 1, false", report.Passed, report.Failed, report.Compliant)
+	}
+	if len(report.Rules) != 3 || report.Rules[1].Status != "pass" || report.Rules[2].Status != "fail" {
+		t.Errorf("rules %+v, want per-rule statuses normalized to lower case", report.Rules)
+	}
+
+	// Both documents ground the check
+	prompt := messageText(sampler.Requests()[0])
+	for _, want := range []string{`<template name="template.md">`, "Give a review date", `<document name="policy.md">`, "Owner: Security team"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("sampling request is missing %q", want)
+		}
+	}
+}
+
+func TestCheckComplianceAllPass(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"template.md": complianceTemplate, "policy.md": compliancePolicy})
+	c := connect(t, s, &mockSampler{respond: answers(`{"rules": [{"rule": "Have a title", "status": "pass"}, {"rule": "Mention pets", "status": "not_applicable"}]}`)})
+
+	_, text := mustSucceed(t, c, "check_compliance", map[string]any{"filename": "policy.md", "template": "template.md"})
+	var report ComplianceReport
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Compliant || report.Passed != 1 || report.Failed != 0 {
+		t.Errorf("report %+v, want compliant with one pass", report)
+	}
+}
+
+func TestCheckComplianceRepromptsOnUnknownStatus(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"template.md": complianceTemplate, "policy.md": compliancePolicy})
+	sampler := &mockSampler{respond: answers(
+		`{"rules": [{"rule": "Have a title", "status": "mostly"}]}`,
+		`{"rules": [{"rule": "Have a title", "status": "pass"}]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "check_compliance", map[string]any{"filename": "policy.md", "template": "template.md"})
+	if n := len(sampler.Requests()); n != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", n)
+	}
+	if !strings.Contains(sampler.Requests()[1].SystemPrompt, `status "mostly"`) {
+		t.Errorf("reprompt does not name the bad status: %q", sampler.Requests()[1].SystemPrompt)
+	}
+}
+
+func TestCheckComplianceTooLarge(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"template.md": complianceTemplate, "policy.md": compliancePolicy})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "check_compliance", map[string]any{"filename": "policy.md", "template": "template.md"})
+	if !strings.Contains(text, "more than the 100 bytes") || len(sampler.Requests()) != 0 {
+		t.Errorf("unexpected result for documents over the limit: %s", text)
+	}
+}
@@ -0,0 +1,285 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var analyzeConversationTool = mcp.Tool{
+	Name:        "analyze_conversation",
+	Description: "Summarize or extract action items from a chat transcript using LLM sampling. The transcript is replayed to the model as user and assistant messages",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The transcript: JSON messages with role and content, or lines of \"role: text\" (relative to files directory)",
+			},
+			"task": map[string]any{
+				"type":        "string",
+				"description": "What to get from the conversation (default summarize)",
+				"enum":        slices.Sorted(maps.Keys(conversationTasks)),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// conversationTasks are the instructions for each analyze_conversation task.
+var conversationTasks = map[string]string{
+	"summarize":    "Summarize the conversation: what the user wanted, what the assistant answered or did, and how it ended.",
+	"action_items": "List the action items agreed on or left open in the conversation, one per line, each with who owns it (user or assistant) when that is clear. If there are none, say so.",
+}
+
+// conversationRoles maps the speaker labels transcripts use to sampling
+// roles. "system" turns are not part of the dialogue and become context in
+// the system prompt instead.
+var conversationRoles = map[string]mcp.Role{
+	"user":      mcp.RoleUser,
+	"human":     mcp.RoleUser,
+	"customer":  mcp.RoleUser,
+	"assistant": mcp.RoleAssistant,
+	"ai":        mcp.RoleAssistant,
+	"bot":       mcp.RoleAssistant,
+	"model":     mcp.RoleAssistant,
+	"agent":     mcp.RoleAssistant,
+	"system":    "system",
+}
+
+// conversationTurn is one message of a transcript.
+type conversationTurn struct {
+	Role mcp.Role
+	Text string
+}
+
+// transcript is a parsed conversation. Skipped counts the lines or JSON
+// messages that could not be read.
+type transcript struct {
+	System  []string
+	Turns   []conversationTurn
+	Skipped int
+}
+
+func (s *Server) handleAnalyzeConversation(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	task := request.GetString("task", "summarize")
+	instruction, ok := conversationTasks[task]
+	if !ok {
+		return errorResult("Unknown task %q", task), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if len(text) > s.cfg.ChunkSize {
+		return errorResult("%s is %d bytes, more than the %d bytes that fit in one request", filename, len(text), s.cfg.ChunkSize), nil
+	}
+
+	conv, err := parseTranscript(text)
+	if err != nil {
+		return errorResult("%s: %v", filename, err), nil
+	}
+	if conv.Skipped > 0 {
+		log.Printf("Warning: Skipped %d unreadable lines or messages in %s", conv.Skipped, filename)
+	}
+
+	systemPrompt := "The messages are a transcript of a conversation between a user and an assistant, replayed with their original roles. " +
+		"Do not continue the conversation; answer the request in the final message about it."
+	if len(conv.System) > 0 {
+		systemPrompt += " The conversation's own system prompt was: " + strings.Join(conv.System, "\n")
+	}
+
+	samplingRequest := newSamplingRequest(nil, systemPrompt)
+	samplingRequest.Messages = conversationMessages(conv.Turns, "[End of transcript] "+instruction)
+
+	logf(ctx, "📤 Sending sampling request for conversation: %s (%d turns, task: %s)", filename, len(conv.Turns), task)
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+	logf(ctx, "✅ Conversation analysis successful! Model: %s", result.Model)
+
+	var users, assistants int
+	for _, turn := range conv.Turns {
+		if turn.Role == mcp.RoleUser {
+			users++
+		} else {
+			assistants++
+		}
+	}
+	return textResult(fmt.Sprintf("Conversation Analysis Results\n"+
+		"=============================\n"+
+		"File: %s\n"+
+		"Task: %s\n"+
+		"Turns: %d (%d user, %d assistant, %d skipped)\n"+
+		"Model: %s\n\n"+
+		"%s", filename, task, len(conv.Turns), users, assistants, conv.Skipped, result.Model, resultText(result))), nil
+}
+
+// conversationMessages turns transcript turns into sampling messages ending
+// with a user message that asks for the task. Consecutive turns by the same
+// role are merged, and a transcript that opens with the assistant gets a
+// placeholder user turn, since providers expect alternating roles starting
+// with the user.
+func conversationMessages(turns []conversationTurn, request string) []mcp.SamplingMessage {
+	turns = append(slices.Clone(turns), conversationTurn{Role: mcp.RoleUser, Text: request})
+	if turns[0].Role != mcp.RoleUser {
+		turns = append([]conversationTurn{{Role: mcp.RoleUser, Text: "[The transcript starts with the assistant.]"}}, turns...)
+	}
+
+	var messages []mcp.SamplingMessage
+	var current strings.Builder
+	for i, turn := range turns {
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(turn.Text)
+		if i+1 < len(turns) && turns[i+1].Role == turn.Role {
+			continue
+		}
+		messages = append(messages, mcp.SamplingMessage{
+			Role:    turn.Role,
+			Content: mcp.TextContent{Type: "text", Text: current.String()},
+		})
+		current.Reset()
+	}
+	return messages
+}
+
+// parseTranscript reads a transcript as JSON when it looks like JSON, and as
+// "role: text" lines otherwise.
+func parseTranscript(text string) (transcript, error) {
+	var conv transcript
+	var err error
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		conv, err = parseJSONTranscript(trimmed)
+	} else {
+		conv = parseLineTranscript(text)
+	}
+	if err != nil {
+		return transcript{}, err
+	}
+	if len(conv.Turns) == 0 {
+		return transcript{}, fmt.Errorf("no user or assistant messages found (%d lines or messages skipped)", conv.Skipped)
+	}
+	return conv, nil
+}
+
+// add appends a message by the speaker label role, counting it as skipped
+// when the label is unknown or the text is empty.
+func (t *transcript) add(role, text string) {
+	mapped, ok := conversationRoles[strings.ToLower(strings.TrimSpace(role))]
+	text = strings.TrimSpace(text)
+	switch {
+	case !ok || text == "":
+		t.Skipped++
+	case mapped == "system":
+		t.System = append(t.System, text)
+	default:
+		t.Turns = append(t.Turns, conversationTurn{Role: mapped, Text: text})
+	}
+}
+
+// parseJSONTranscript reads a list of {"role", "content"} messages, bare or
+// under "messages". content may be a string or a list of text blocks, as
+// in provider APIs; "text" is accepted in place of "content".
+func parseJSONTranscript(text string) (transcript, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		var wrapped struct {
+			Messages []json.RawMessage `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(text), &wrapped); err != nil {
+			return transcript{}, fmt.Errorf("not a valid JSON transcript: %v", err)
+		}
+		raw = wrapped.Messages
+	}
+
+	var conv transcript
+	for _, item := range raw {
+		var message struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+			Text    string          `json:"text"`
+		}
+		if err := json.Unmarshal(item, &message); err != nil {
+			conv.Skipped++
+			continue
+		}
+		body := message.Text
+		var content string
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(message.Content, &content) == nil {
+			body = content
+		} else if json.Unmarshal(message.Content, &blocks) == nil {
+			var parts []string
+			for _, block := range blocks {
+				if block.Type == "text" {
+					parts = append(parts, block.Text)
+				}
+			}
+			body = strings.Join(parts, "\n")
+		}
+		conv.add(message.Role, body)
+	}
+	return conv, nil
+}
+
+// speakerLine matches the start of a turn in a "role: text" transcript.
+var speakerLine = regexp.MustCompile(`^\s*([A-Za-z]+)\s*:\s?(.*)$`)
+
+// parseLineTranscript reads "role: text" lines. A line without a known
+// role continues the message before it, so messages can span lines; such
+// lines before the first message are skipped.
+func parseLineTranscript(text string) transcript {
+	var conv transcript
+	var role string
+	var body strings.Builder
+	started := false
+	flush := func() {
+		if started {
+			conv.add(role, body.String())
+		}
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if m := speakerLine.FindStringSubmatch(line); m != nil {
+			if _, known := conversationRoles[strings.ToLower(m[1])]; known {
+				flush()
+				role, started = m[1], true
+				body.WriteString(m[2])
+				continue
+			}
+		}
+		if !started {
+			if strings.TrimSpace(line) != "" {
+				conv.Skipped++
+			}
+			continue
+		}
+		body.WriteString("\n" + line)
+	}
+	flush()
+	return conv
+}
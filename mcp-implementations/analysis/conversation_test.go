@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const lineTranscriptFixture = `Exported from chat on Monday
+System: Be brief.
+User: Hi
+Assistant: Hello!
+How can I help?
+User: Book a table.
+User: For two.
+Bot: Done. Note: it is at 7pm.
+`
+
+// roles lists the roles of sampling messages in order.
+func roles(messages []mcp.SamplingMessage) []mcp.Role {
+	var r []mcp.Role
+	for _, m := range messages {
+		r = append(r, m.Role)
+	}
+	return r
+}
+
+func TestAnalyzeConversationPreservesRoles(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"chat.txt": lineTranscriptFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_conversation", map[string]any{"filename": "chat.txt"})
+
+	request := sampler.Requests()[0]
+	wantRoles := []mcp.Role{mcp.RoleUser, mcp.RoleAssistant, mcp.RoleUser, mcp.RoleAssistant, mcp.RoleUser}
+	if got := roles(request.Messages); !slices.Equal(got, wantRoles) {
+		t.Fatalf("messages have roles %v, want %v", got, wantRoles)
+	}
+	for i, want := range []string{"Hi", "Hello!\nHow can I help?", "Book a table.\n\nFor two.", "Done. Note: it is at 7pm."} {
+		if got := request.Messages[i].Content.(mcp.TextContent).Text; got != want {
+			t.Errorf("message %d is %q, want %q", i, got, want)
+		}
+	}
+	if last := request.Messages[4].Content.(mcp.TextContent).Text; !strings.HasPrefix(last, "[End of transcript] Summarize the conversation") {
+		t.Errorf("final message does not ask for the task: %q", last)
+	}
+	if !strings.Contains(request.SystemPrompt, "The conversation's own system prompt was: Be brief.") {
+		t.Errorf("system turn did not become context: %q", request.SystemPrompt)
+	}
+	if !strings.Contains(text, "Turns: 5 (3 user, 2 assistant, 1 skipped)") {
+		t.Errorf("result does not count the turns:\n%s", text)
+	}
+}
+
+func TestAnalyzeConversationReadsJSONTranscript(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"chat.json": `{"messages": [
+		{"role": "assistant", "content": [{"type": "text", "text": "Welcome."}]},
+		{"role": "user", "content": "Cancel my order."},
+		{"role": "tool", "content": "lookup result"},
+		42,
+		{"role": "assistant", "text": "Cancelled."}
+	]}`})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_conversation", map[string]any{"filename": "chat.json", "task": "action_items"})
+
+	request := sampler.Requests()[0]
+	texts := []string{"[The transcript starts with the assistant.]", "Welcome.", "Cancel my order.", "Cancelled."}
+	if len(request.Messages) != 5 {
+		t.Fatalf("got %d messages, want 5", len(request.Messages))
+	}
+	for i, want := range texts {
+		if got := request.Messages[i].Content.(mcp.TextContent).Text; got != want {
+			t.Errorf("message %d is %q, want %q", i, got, want)
+		}
+	}
+	if last := request.Messages[4].Content.(mcp.TextContent).Text; !strings.Contains(last, "List the action items") {
+		t.Errorf("final message does not ask for action items: %q", last)
+	}
+	if !strings.Contains(text, "2 skipped") {
+		t.Errorf("unreadable messages were not counted:\n%s", text)
+	}
+}
+
+func TestAnalyzeConversationRejectsUnreadableTranscripts(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"broken.json": `[{"role": "user", "content": "Hi"`,
+		"notes.txt":   "Nothing but notes\nand more notes",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "analyze_conversation", map[string]any{"filename": "broken.json"}); !strings.Contains(text, "broken.json: not a valid JSON transcript") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "analyze_conversation", map[string]any{"filename": "notes.txt"}); !strings.Contains(text, "no user or assistant messages found (2 lines or messages skipped)") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "analyze_conversation", map[string]any{"filename": "notes.txt", "task": "translate"}); !strings.Contains(text, `Unknown task "translate"`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("unreadable transcripts sent %d sampling requests", n)
+	}
+}
@@ -0,0 +1,304 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// convertFormats are the formats convert_format knows, each with the name
+// the prompt uses for it.
+var convertFormats = map[string]string{
+	"csv":      "CSV",
+	"html":     "HTML",
+	"json":     "JSON",
+	"markdown": "Markdown",
+	"text":     "plain text",
+	"xml":      "XML",
+	"yaml":     "YAML",
+}
+
+// localConversions convert between formats that have one right answer, so
+// they are done in code instead of by the model.
+var localConversions = map[[2]string]func(string) (string, error){
+	{"csv", "json"}:     csvToJSON,
+	{"csv", "markdown"}: csvToMarkdown,
+	{"json", "yaml"}:    jsonToYAML,
+	{"yaml", "json"}:    yamlToJSON,
+}
+
+var convertFormatTool = mcp.Tool{
+	Name:        "convert_format",
+	Description: "Convert a file to another format, e.g. Markdown to HTML or CSV to a Markdown table. Exact conversions (CSV to JSON or Markdown, JSON to and from YAML) are done locally; the rest use LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to convert (relative to files directory)",
+			},
+			"target_format": map[string]any{
+				"type":        "string",
+				"description": "The format to convert to",
+				"enum":        slices.Sorted(maps.Keys(convertFormats)),
+			},
+			"source_format": map[string]any{
+				"type":        "string",
+				"description": "The file's format (default: from the extension, else text)",
+				"enum":        slices.Sorted(maps.Keys(convertFormats)),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "target_format"},
+	},
+}
+
+func (s *Server) handleConvertFormat(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	target, err := request.RequireString("target_format")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := convertFormats[target]; !ok {
+		return errorResult("Unknown target_format %q", target), nil
+	}
+	source := request.GetString("source_format", sourceFormatFor(filename))
+	if _, ok := convertFormats[source]; !ok {
+		return errorResult("Unknown source_format %q", source), nil
+	}
+	if source == target {
+		return errorResult("%s is already %s", filename, convertFormats[target]), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	var converted, by string
+	if convert, ok := localConversions[[2]string{source, target}]; ok {
+		converted, err = convert(text)
+		if err != nil {
+			return errorResult("%s is not valid %s: %v", filename, convertFormats[source], err), nil
+		}
+		by = "local conversion"
+		logf(ctx, "✅ Converted %s from %s to %s locally", filename, source, target)
+	} else {
+		if len(text) > s.cfg.ChunkSize {
+			return errorResult("%s is %d bytes, more than the %d bytes that fit in one request", filename, len(text), s.cfg.ChunkSize), nil
+		}
+		var model string
+		converted, model, err = s.convertWithModel(ctx, filename, text, source, target)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+		by = "model " + model
+	}
+
+	return textResult(fmt.Sprintf("Format Conversion Results\n"+
+		"=========================\n"+
+		"File: %s\n"+
+		"From: %s\n"+
+		"To: %s\n"+
+		"Converted by: %s\n\n"+
+		"%s", filename, source, target, by, converted)), nil
+}
+
+// convertWithModel asks the model to convert text. JSON, YAML and XML
+// answers are parsed, and one that does not parse is reprompted once.
+func (s *Server) convertWithModel(ctx context.Context, filename, text, source, target string) (string, string, error) {
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := fmt.Sprintf("Convert this %s document to %s. Keep all of its content and structure, mapping each element to "+
+		"the closest %s equivalent, and do not summarize, add to or comment on it. "+
+		"Respond with only the converted document, without code fences.", convertFormats[source], convertFormats[target], convertFormats[target])
+
+	var converted, model string
+	for attempt := 1; attempt <= 2; attempt++ {
+		samplingRequest := newSamplingRequest(content, systemPrompt)
+		samplingRequest.Temperature = 0
+		samplingRequest.MaxTokens = 4000
+
+		logf(ctx, "📤 Sending sampling request to convert %s to %s (attempt %d)", filename, target, attempt)
+		result, err := s.requestSampling(ctx, samplingRequest)
+		if err != nil {
+			log.Printf("❌ Sampling request failed: %v", err)
+			return "", "", fmt.Errorf("Error requesting sampling: %v", err)
+		}
+		converted, model = stripCodeFence(resultText(result)), result.Model
+
+		// Only the formats validate_file can parse are checked
+		if _, structured := validateFormats[target]; !structured {
+			break
+		}
+		syntaxErr := checkSyntax(target, converted)
+		if syntaxErr == nil {
+			break
+		}
+
+		log.Printf("Malformed %s conversion: %v", target, syntaxErr)
+		if attempt == 2 {
+			return "", "", fmt.Errorf("The model did not return valid %s after a retry: %v", convertFormats[target], syntaxErr)
+		}
+		systemPrompt += fmt.Sprintf(" Your previous answer was not valid %s (%v). Respond again with only the converted document.", convertFormats[target], syntaxErr)
+	}
+
+	logf(ctx, "✅ Converted %s from %s to %s! Model: %s", filename, source, target, model)
+	return converted, model, nil
+}
+
+// sourceFormatFor guesses a file's format from its extension.
+func sourceFormatFor(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if format := formatForExt(ext); format != "" {
+		return format
+	}
+	switch ext {
+	case ".csv":
+		return "csv"
+	case ".md", ".markdown":
+		return "markdown"
+	case ".html", ".htm":
+		return "html"
+	}
+	return "text"
+}
+
+// codeFence matches a whole answer wrapped in one Markdown code fence.
+var codeFence = regexp.MustCompile("(?s)^\\s*(?:```|~~~)[\\w+-]*[ \\t]*\\n(.*?)\\n?(?:```|~~~)\\s*$")
+
+// stripCodeFence returns text without a code fence wrapped around all of it.
+func stripCodeFence(text string) string {
+	if m := codeFence.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return strings.TrimSpace(text)
+}
+
+// readCSV parses text as CSV with a header row, returning the header and
+// the records after it.
+func readCSV(text string) ([]string, [][]string, error) {
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("no header row")
+	}
+	return records[0], records[1:], nil
+}
+
+// csvToJSON converts CSV to an array of objects keyed by the header row,
+// keeping the columns in order.
+func csvToJSON(text string) (string, error) {
+	header, rows, err := readCSV(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for j, column := range header {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			key, _ := json.Marshal(column)
+			value, _ := json.Marshal(row[j])
+			fmt.Fprintf(&buf, "%s: %s", key, value)
+		}
+		buf.WriteString("}")
+	}
+	if len(rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]")
+	return buf.String(), nil
+}
+
+// csvToMarkdown converts CSV to a Markdown table with the header row as
+// its column names.
+func csvToMarkdown(text string) (string, error) {
+	header, rows, err := readCSV(text)
+	if err != nil {
+		return "", err
+	}
+
+	row := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", "<br>")
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	lines := []string{row(header), "|" + strings.Repeat(" --- |", len(header))}
+	for _, cells := range rows {
+		lines = append(lines, row(cells))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// jsonToYAML converts a JSON document to YAML, keeping object keys in
+// their original order.
+func jsonToYAML(text string) (string, error) {
+	if err := json.Unmarshal([]byte(text), new(any)); err != nil {
+		return "", err
+	}
+	// JSON is also YAML, and decoding it as a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &node); err != nil {
+		return "", err
+	}
+	plainStyle(&node)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// plainStyle clears the JSON styling yaml.v3 keeps on decoded nodes, flow
+// {} and [] and quoted strings, so the output reads as ordinary YAML.
+// Strings that would read as another type unquoted are still quoted.
+func plainStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		plainStyle(child)
+	}
+}
+
+// yamlToJSON converts a single YAML document to indented JSON.
+func yamlToJSON(text string) (string, error) {
+	var value any
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+		return "", err
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const inventoryCSV = "name,qty,note\nbolt,10,\"M6, zinc\"\npipe|fitting,2,\n"
+
+func TestConvertFormatSendsTargetFormatToModel(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"readme.md": "# Title\n\nSome *text*."})
+	sampler := &mockSampler{respond: answers("```html\n<h1>Title</h1>\n<p>Some <em>text</em>.</p>\n```")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "convert_format", map[string]any{"filename": "readme.md", "target_format": "html"})
+
+	request := sampler.Requests()[0]
+	if !strings.Contains(request.SystemPrompt, "Convert this Markdown document to HTML.") {
+		t.Errorf("system prompt does not name the formats: %q", request.SystemPrompt)
+	}
+	if messageText(request) != "# Title\n\nSome *text*." {
+		t.Errorf("the file was not sent: %q", messageText(request))
+	}
+	if !strings.Contains(text, "Converted by: model mock-model\n\n<h1>Title</h1>\n<p>Some <em>text</em>.</p>") || strings.Contains(text, "```") {
+		t.Errorf("unexpected result, the code fence should be stripped:\n%s", text)
+	}
+}
+
+func TestConvertFormatConvertsCSVLocally(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"inventory.csv": inventoryCSV})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "convert_format", map[string]any{"filename": "inventory.csv", "target_format": "json"})
+	want := "[\n  {\"name\": \"bolt\", \"qty\": \"10\", \"note\": \"M6, zinc\"},\n  {\"name\": \"pipe|fitting\", \"qty\": \"2\", \"note\": \"\"}\n]"
+	if !strings.Contains(text, "Converted by: local conversion\n\n"+want) {
+		t.Errorf("unexpected CSV to JSON result:\n%s", text)
+	}
+
+	_, text = mustSucceed(t, c, "convert_format", map[string]any{"filename": "inventory.csv", "target_format": "markdown"})
+	want = "| name | qty | note |\n| --- | --- | --- |\n| bolt | 10 | M6, zinc |\n| pipe\\|fitting | 2 |  |"
+	if !strings.Contains(text, want) {
+		t.Errorf("unexpected CSV to Markdown result:\n%s", text)
+	}
+
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("local conversions sent %d sampling requests", n)
+	}
+}
+
+func TestConvertFormatJSONAndYAMLRoundTrip(t *testing.T) {
+	yamlText, err := jsonToYAML(`{"zeta": {"b": [1, "two"], "a": true}, "alpha": "true", "count": "10"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys keep their order, and strings that would read as another type stay quoted
+	if want := "zeta:\n  b:\n    - 1\n    - two\n  a: true\nalpha: \"true\"\ncount: \"10\"\n"; yamlText != want {
+		t.Errorf("jsonToYAML() = %q, want %q", yamlText, want)
+	}
+
+	jsonText, err := yamlToJSON(yamlText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"alpha\": \"true\",\n  \"count\": \"10\",\n  \"zeta\": {\n    \"a\": true,\n    \"b\": [\n      1,\n      \"two\"\n    ]\n  }\n}"; jsonText != want {
+		t.Errorf("yamlToJSON() = %q, want %q", jsonText, want)
+	}
+}
+
+func TestConvertFormatRepromptsOnInvalidStructuredOutput(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Name: bolt. Quantity: 10."})
+	sampler := &mockSampler{respond: answers(`{"name": "bolt",`, `{"name": "bolt", "qty": 10}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "convert_format", map[string]any{"filename": "notes.txt", "target_format": "json"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, "Your previous answer was not valid JSON") {
+		t.Errorf("reprompt does not give the syntax error: %q", requests[1].SystemPrompt)
+	}
+	if !strings.HasSuffix(text, `{"name": "bolt", "qty": 10}`) {
+		t.Errorf("result does not carry the valid answer:\n%s", text)
+	}
+}
+
+func TestConvertFormatRejectsInvalidRequests(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"inventory.csv": inventoryCSV, "broken.json": `{"a": `})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"filename": "inventory.csv", "target_format": "pdf"}, `Unknown target_format "pdf"`},
+		{map[string]any{"filename": "inventory.csv", "target_format": "csv"}, "inventory.csv is already CSV"},
+		{map[string]any{"filename": "broken.json", "target_format": "yaml"}, "broken.json is not valid JSON"},
+	} {
+		if text := mustFail(t, c, "convert_format", tc.args); !strings.Contains(text, tc.want) {
+			t.Errorf("%v: unexpected error: %s", tc.args, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid requests sent %d sampling requests", n)
+	}
+}
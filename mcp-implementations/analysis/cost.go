@@ -0,0 +1,189 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DEFAULT_MODEL is the model the enhanced client samples with, used when a
+// cost estimate does not name one.
+const DEFAULT_MODEL = "claude-3-5-sonnet-20241022"
+
+// ModelPrice is a model's list price in US dollars per million tokens.
+type ModelPrice struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// PriceTable holds list prices for the models the enhanced client can use.
+var PriceTable = map[string]ModelPrice{
+	"claude-3-5-sonnet-20241022": {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+	"claude-3-5-haiku-20241022":  {InputPerMTok: 0.80, OutputPerMTok: 4.00},
+	"claude-3-opus-20240229":     {InputPerMTok: 15.00, OutputPerMTok: 75.00},
+	"claude-3-haiku-20240307":    {InputPerMTok: 0.25, OutputPerMTok: 1.25},
+}
+
+const (
+	// charsPerToken is the usual rule of thumb for English text and code.
+	charsPerToken = 4
+	// imageTokens approximates a large image after the provider resizes it.
+	imageTokens = 1600
+	// promptOverheadTokens covers the system prompt sent with every file.
+	promptOverheadTokens = 50
+)
+
+// estimateInputTokens approximates how many input tokens a file of the
+// given size costs once analyze_file has encoded it.
+func estimateInputTokens(filename string, size int64, binaryPolicy string) int {
+	mimeType := mimeTypeFor(filename)
+	switch {
+	case isTextFile(filename, mimeType):
+		return int(size)/charsPerToken + promptOverheadTokens
+	case strings.HasPrefix(mimeType, "image/"):
+		return imageTokens + promptOverheadTokens
+	case binaryPolicy == BinaryBase64:
+		// Binary files are sent as base64, which is a third larger
+		return int(size*4/3)/charsPerToken + promptOverheadTokens
+	default:
+		return metadataTokens + promptOverheadTokens
+	}
+}
+
+// estimateCost returns the dollar cost of the given token counts.
+func estimateCost(price ModelPrice, inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)/1e6*price.InputPerMTok + float64(outputTokens)/1e6*price.OutputPerMTok
+}
+
+var estimateBatchCostTool = mcp.Tool{
+	Name:        "estimate_batch_cost",
+	Description: "Estimate the token usage and cost of analyzing a batch of files, without sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filenames": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Files to estimate (relative to files directory)",
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis that would be performed",
+				"enum":        AnalysisTypeNames(),
+			},
+			"model": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("Model to price the batch against (default %s)", DEFAULT_MODEL),
+			},
+		},
+		Required: []string{"filenames"},
+	},
+}
+
+func (s *Server) handleEstimateBatchCost(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filenames, err := request.RequireStringSlice("filenames")
+	if err != nil {
+		return nil, err
+	}
+
+	analysisType := request.GetString("analysis_type", s.cfg.DefaultAnalysis)
+	model := request.GetString("model", DEFAULT_MODEL)
+
+	price, ok := PriceTable[model]
+	if !ok {
+		known := make([]string, 0, len(PriceTable))
+		for name := range PriceTable {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return errorResult("No price known for model %s. Known models: %s", model, strings.Join(known, ", ")), nil
+	}
+
+	// Unknown types are priced at the sampling MaxTokens
+	outputTokens := 2000
+	if t, ok := lookupAnalysisType(analysisType); ok {
+		outputTokens = t.ExpectedOutputTokens
+	}
+
+	var lines []string
+	var totalInput, totalOutput, counted int
+	var totalCost float64
+	for _, filename := range filenames {
+		filePath, err := s.resolveFile(filename)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("- %s: skipped (%v)", filename, err))
+			continue
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("- %s: skipped (%v)", filename, err))
+			continue
+		}
+
+		if s.cfg.BinaryPolicy == BinaryReject && isBinaryFile(filename) {
+			lines = append(lines, fmt.Sprintf("- %s: skipped (binary files are rejected)", filename))
+			continue
+		}
+
+		inputTokens := estimateInputTokens(filename, info.Size(), s.cfg.BinaryPolicy)
+		cost := estimateCost(price, inputTokens, outputTokens)
+
+		counted++
+		totalInput += inputTokens
+		totalOutput += outputTokens
+		totalCost += cost
+		lines = append(lines, fmt.Sprintf("- %s: %d bytes, ~%d input + ~%d output tokens, $%.4f", filename, info.Size(), inputTokens, outputTokens, cost))
+	}
+
+	return textResult(fmt.Sprintf("Batch Cost Estimate\n"+
+		"===================\n"+
+		"Model: %s ($%.2f / $%.2f per million input/output tokens)\n"+
+		"Analysis: %s\n\n"+
+		"%s\n\n"+
+		"Files: %d of %d\n"+
+		"Tokens: ~%d input, ~%d output\n"+
+		"Estimated cost: $%.4f\n\n"+
+		"Estimates use ~%d characters per token and list prices; actual usage varies.",
+		model, price.InputPerMTok, price.OutputPerMTok, analysisType,
+		strings.Join(lines, "\n"), counted, len(filenames), totalInput, totalOutput, totalCost, charsPerToken)), nil
+}
+
+// contentSize returns the bytes of one piece of sampling content as sent,
+// with images counted at their base64 size, and its estimated tokens.
+func contentSize(content any) (int, int) {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return len(c.Text), len(c.Text) / charsPerToken
+	case mcp.ImageContent:
+		return len(c.Data), imageTokens
+	default:
+		text := fmt.Sprintf("%v", c)
+		return len(text), len(text) / charsPerToken
+	}
+}
+
+// logSamplingSizes logs how large a sampling request and its response were,
+// in bytes and estimated tokens, to help spot the prompts that drive cost.
+// result is nil when the request failed.
+func logSamplingSizes(request mcp.CreateMessageRequest, result *mcp.CreateMessageResult) {
+	promptBytes, promptTokens := len(request.SystemPrompt), len(request.SystemPrompt)/charsPerToken
+	for _, message := range request.Messages {
+		bytes, tokens := contentSize(message.Content)
+		promptBytes += bytes
+		promptTokens += tokens
+	}
+	if result == nil {
+		log.Printf("📏 Sampling sizes: prompt_bytes=%d prompt_tokens_est=%d messages=%d response=none",
+			promptBytes, promptTokens, len(request.Messages))
+		return
+	}
+	responseBytes, responseTokens := contentSize(result.Content)
+	log.Printf("📏 Sampling sizes: prompt_bytes=%d prompt_tokens_est=%d messages=%d response_bytes=%d response_tokens_est=%d model=%s",
+		promptBytes, promptTokens, len(request.Messages), responseBytes, responseTokens, result.Model)
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var estimatedCostRe = regexp.MustCompile(`Estimated cost: \$([0-9.]+)`)
+
+// estimatedCost calls estimate_batch_cost and returns the total it reports.
+func estimatedCost(t *testing.T, c *client.Client, filenames ...string) float64 {
+	t.Helper()
+	_, text := mustSucceed(t, c, "estimate_batch_cost", map[string]any{
+		"filenames":     filenames,
+		"analysis_type": "summarize",
+	})
+	match := estimatedCostRe.FindStringSubmatch(text)
+	if match == nil {
+		t.Fatalf("no estimated cost in:\n%s", text)
+	}
+	cost, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cost
+}
+
+func TestEstimateBatchCostScalesWithFiles(t *testing.T) {
+	small := strings.Repeat("word ", 2000)
+	s := newTestServer(t, Config{}, map[string]string{
+		"a.txt":   small,
+		"b.txt":   small,
+		"big.txt": strings.Repeat(small, 10),
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	one := estimatedCost(t, c, "a.txt")
+	two := estimatedCost(t, c, "a.txt", "b.txt")
+	big := estimatedCost(t, c, "big.txt")
+
+	if one <= 0 {
+		t.Fatalf("estimate for one file is %v, want a positive cost", one)
+	}
+	if diff := two - 2*one; diff > 0.0002 || diff < -0.0002 {
+		t.Errorf("two equal files cost %v, want twice one file (%v)", two, 2*one)
+	}
+	if big <= one {
+		t.Errorf("a file ten times larger costs %v, want more than %v", big, one)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("estimating sent %d sampling requests, want none", n)
+	}
+}
+
+func TestEstimateBatchCostSkipsMissingFiles(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"a.txt": "some text"})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "estimate_batch_cost", map[string]any{"filenames": []string{"a.txt", "missing.txt"}})
+	for _, want := range []string{"missing.txt: skipped", "Files: 1 of 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("estimate is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+var samplingSizesRe = regexp.MustCompile(`📏 Sampling sizes: prompt_bytes=(\d+) prompt_tokens_est=(\d+) messages=(\d+) (.*)`)
+
+// samplingSizes returns the fields of each sampling size line in logs.
+func samplingSizes(logs string) [][]string {
+	var lines [][]string
+	for _, match := range samplingSizesRe.FindAllStringSubmatch(logs, -1) {
+		lines = append(lines, match[1:])
+	}
+	return lines
+}
+
+func TestDebugLogsSamplingSizes(t *testing.T) {
+	s := newTestServer(t, Config{Debug: true}, map[string]string{"notes.txt": strings.Repeat("Some notes. ", 100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+	logs := captureLogs(t)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	request := sampler.Requests()[0]
+	promptBytes := len(request.SystemPrompt) + len(messageText(request))
+	want := []string{
+		strconv.Itoa(promptBytes),
+		strconv.Itoa(len(request.SystemPrompt)/charsPerToken + len(messageText(request))/charsPerToken),
+		"1",
+		fmt.Sprintf("response_bytes=%d response_tokens_est=%d model=mock-model", len(mockAnswer), len(mockAnswer)/charsPerToken),
+	}
+	if got := samplingSizes(logs.String()); len(got) != 1 || fmt.Sprint(got[0]) != fmt.Sprint(want) {
+		t.Errorf("sampling size lines = %q, want one with %q\n%s", got, want, logs)
+	}
+}
+
+func TestDebugLogsImagesAtEncodedSize(t *testing.T) {
+	image := mcp.ImageContent{Type: "image", Data: strings.Repeat("A", 4000), MIMEType: "image/png"}
+	logs := captureLogs(t)
+
+	logSamplingSizes(newSamplingRequest(image, "Describe."), nil)
+
+	got := samplingSizes(logs.String())
+	want := []string{"4009", strconv.Itoa(len("Describe.")/charsPerToken + imageTokens), "1", "response=none"}
+	if len(got) != 1 || fmt.Sprint(got[0]) != fmt.Sprint(want) {
+		t.Errorf("sampling size lines = %q, want one with %q", got, want)
+	}
+}
+
+func TestDebugLogsFailedSamplingSize(t *testing.T) {
+	s := newTestServer(t, Config{Debug: true}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return nil, errors.New("prompt is too long")
+	}})
+	logs := captureLogs(t)
+
+	mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if got := samplingSizes(logs.String()); len(got) != 1 || got[0][3] != "response=none" {
+		t.Errorf("sampling size lines = %q, want one with response=none\n%s", got, logs)
+	}
+}
+
+func TestSamplingSizesNeedDebug(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if strings.Contains(logs.String(), "Sampling sizes") {
+		t.Errorf("sampling sizes logged without Debug:\n%s", logs)
+	}
+}
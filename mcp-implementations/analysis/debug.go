@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// debugDumpBytes caps each block of text a debug call logs, so one call
+// on a large file cannot flood the log.
+const debugDumpBytes = 16 << 10
+
+// debugKey marks a tool call made with the debug argument.
+type debugKey struct{}
+
+// debugProperty documents the debug argument on tools that sample.
+var debugProperty = map[string]any{
+	"type":        "boolean",
+	"description": "Log this call in detail: its arguments, each sampling request's prompt and response, and timings, whatever the server's log settings. PII and API keys are masked (default false)",
+}
+
+// withDebug is tool middleware that turns on detailed logging for a call
+// made with debug set. Such a call is never sampled out of the log, and
+// sendSampling dumps its prompts and responses. It logs the arguments and
+// the outcome with the call's total time.
+func (s *Server) withDebug(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !request.GetBool("debug", false) {
+			return next(ctx, request)
+		}
+		ctx = context.WithValue(ctx, debugKey{}, true)
+		name := request.Params.Name
+
+		// The key is masked in the dump too, but it need not be in it at all
+		args := map[string]any{}
+		for arg, value := range request.GetArguments() {
+			if arg != "api_key" {
+				args[arg] = value
+			}
+		}
+		data, _ := json.Marshal(args)
+		log.Printf("🔍 [debug] %s called with %s", name, s.debugText(request, string(data)))
+
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		switch {
+		case err != nil:
+			log.Printf("🔍 [debug] %s failed after %v: %v", name, elapsed, err)
+		case result != nil && result.IsError:
+			log.Printf("🔍 [debug] %s returned an error after %v: %s", name, elapsed, s.debugText(request, resultSummary(result)))
+		default:
+			log.Printf("🔍 [debug] %s finished in %v", name, elapsed)
+		}
+		return result, err
+	}
+}
+
+// debugging reports whether ctx belongs to a call made with debug set.
+func debugging(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
+
+// debugText prepares text from a debug call for the log: PII is masked
+// with the redact patterns, the caller's API key is masked, and the text
+// is cut to debugDumpBytes, on a character boundary. keySource is anything
+// carrying the key: the tool call request, or the context its sampling
+// requests run in.
+func (s *Server) debugText(keySource any, text string) string {
+	var key string
+	switch source := keySource.(type) {
+	case mcp.CallToolRequest:
+		key = source.GetString("api_key", "")
+	case context.Context:
+		key, _ = source.Value(apiKeyContextKey{}).(string)
+	}
+	if key != "" {
+		text = strings.ReplaceAll(text, key, "[REDACTED]")
+	}
+	text = redactPII(text, s.cfg.PIIPatterns, map[string]int{})
+	if len(text) > debugDumpBytes {
+		cut := debugDumpBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = fmt.Sprintf("%s[... %d more bytes]", text[:cut], len(text)-cut)
+	}
+	return text
+}
+
+// logSamplingExchange dumps one sampling request of a debug call and the
+// client's answer, with how long the answer took.
+func (s *Server) logSamplingExchange(ctx context.Context, request mcp.CreateMessageRequest, result *mcp.CreateMessageResult, err error, elapsed time.Duration) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔍 [debug] Sampling request (max_tokens=%d, temperature=%g)\n", request.MaxTokens, request.Temperature)
+	fmt.Fprintf(&b, "--- system prompt ---\n%s\n", s.debugText(ctx, request.SystemPrompt))
+	for i, message := range request.Messages {
+		fmt.Fprintf(&b, "--- message %d (%s) ---\n%s\n", i+1, message.Role, s.debugText(ctx, debugContent(message.Content)))
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "--- failed after %v ---\n%s", elapsed.Round(time.Millisecond), s.debugText(ctx, err.Error()))
+	case result != nil:
+		fmt.Fprintf(&b, "--- response after %v (model=%s, stop=%s) ---\n%s", elapsed.Round(time.Millisecond),
+			result.Model, result.StopReason, s.debugText(ctx, debugContent(result.Content)))
+	}
+	log.Print(b.String())
+}
+
+// debugContent is the text of a message, or a description of content that
+// is not text.
+func debugContent(content any) string {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return c.Text
+	case mcp.ImageContent:
+		return fmt.Sprintf("[image %s, %d bytes base64]", c.MIMEType, len(c.Data))
+	case mcp.AudioContent:
+		return fmt.Sprintf("[audio %s, %d bytes base64]", c.MIMEType, len(c.Data))
+	default:
+		return fmt.Sprintf("[%T]", content)
+	}
+}
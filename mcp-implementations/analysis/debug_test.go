@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestDebugLogsOnlyTheDebugCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	if strings.Contains(logs.String(), "[debug]") {
+		t.Fatalf("a call without debug logged debug lines:\n%s", logs)
+	}
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false, "debug": true})
+	debugLogs := logs.String()
+	for _, want := range []string{
+		`🔍 [debug] analyze_file called with {"debug":true,"filename":"notes.txt","use_cache":false}`,
+		"🔍 [debug] Sampling request (max_tokens=",
+		"--- system prompt ---\nPlease provide a clear and concise summary",
+		"--- message 1 (user) ---\nSome notes.\n",
+		"--- response after ",
+		"(model=mock-model, stop=endTurn) ---\n" + mockAnswer,
+		"🔍 [debug] analyze_file finished in ",
+	} {
+		if !strings.Contains(debugLogs, want) {
+			t.Errorf("debug call logs are missing %q:\n%s", want, debugLogs)
+		}
+	}
+
+	logs.Reset()
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	if strings.Contains(logs.String(), "[debug]") {
+		t.Errorf("a call after the debug call logged debug lines:\n%s", logs)
+	}
+}
+
+func TestDebugMasksPIIAndAPIKey(t *testing.T) {
+	const key = "tenant-secret-123"
+	s := newTestServer(t, Config{}, map[string]string{"contact.txt": "Write to jane@example.com or call 555-123-4567. Key: " + key})
+	// The answer repeats the secrets, so the response dump is masked too
+	c := connect(t, s, &mockSampler{respond: answers("Jane is jane@example.com and the key is " + key)})
+	logs := captureLogs(t)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "contact.txt", "debug": true, "api_key": key})
+
+	dump := logs.String()
+	for _, secret := range []string{key, "jane@example.com", "555-123-4567"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("debug logs contain %q:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "Write to [EMAIL] or call [PHONE]. Key: [REDACTED]") ||
+		!strings.Contains(dump, "Jane is [EMAIL] and the key is [REDACTED]") {
+		t.Errorf("prompt and response are not dumped masked:\n%s", dump)
+	}
+	if strings.Contains(dump, `"api_key"`) {
+		t.Errorf("logged arguments include api_key:\n%s", dump)
+	}
+}
+
+func TestDebugIgnoresLogSampling(t *testing.T) {
+	s := newTestServer(t, Config{LogSampleRate: 1000}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	// The first call is logged, and at rate 1000 the next ones would not be
+	for range 2 {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	}
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false, "debug": true})
+
+	if n := strings.Count(logs.String(), "📤 Sending sampling request for file: notes.txt"); n != 2 {
+		t.Errorf("routine messages logged for %d calls, want the first and the debug call:\n%s", n, logs)
+	}
+	if !strings.Contains(logs.String(), "--- system prompt ---") {
+		t.Errorf("the debug call's prompt was sampled out:\n%s", logs)
+	}
+}
+
+func TestDebugLogsFailedCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	mustFail(t, c, "analyze_file", map[string]any{"filename": "missing.txt", "debug": true})
+
+	if !strings.Contains(logs.String(), "🔍 [debug] analyze_file returned an error after ") {
+		t.Errorf("debug logs do not report the error:\n%s", logs)
+	}
+}
+
+func TestDebugTextCapsLength(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+
+	text := s.debugText(context.Background(), strings.Repeat("a", debugDumpBytes+10))
+	if !strings.HasSuffix(text, "a[... 10 more bytes]") || len(text) != debugDumpBytes+len("[... 10 more bytes]") {
+		t.Errorf("long text is not cut to debugDumpBytes: %d bytes ending %q", len(text), text[len(text)-30:])
+	}
+	// A cut through a multi-byte character falls back to its start
+	text = s.debugText(context.Background(), strings.Repeat("a", debugDumpBytes-1)+"é and more")
+	if !utf8.ValidString(text) || !strings.HasSuffix(text, "a[... 11 more bytes]") {
+		t.Errorf("long text is not cut on a character boundary: ending %q", text[len(text)-30:])
+	}
+	if got := s.debugText(mcp.CallToolRequest{}, "short"); got != "short" {
+		t.Errorf("short text changed to %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestAnalyzeFileDebugRawReturnsRawResponse(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		result := textAnswer(mockAnswer)
+		result.Meta = mcp.NewMetaFromMap(map[string]any{"raw_response": `{"id":"msg_raw"}`})
+		return result, nil
+	}}
+	c := connect(t, s, sampler)
+
+	result, _ := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "debug_raw": true})
+
+	metadata, _ := sampler.Requests()[0].Metadata.(map[string]any)
+	if metadata["debug_raw"] != true {
+		t.Errorf("sampling request metadata %v, want debug_raw set", metadata)
+	}
+	if result.Meta == nil || result.Meta.AdditionalFields["raw_response"] != `{"id":"msg_raw"}` {
+		t.Fatalf("tool result _meta %+v, want the handler's raw_response", result.Meta)
+	}
+}
+
+func TestAnalyzeFileDebugRawNotesMissingRawResponse(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	result, _ := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "debug_raw": true})
+
+	if result.Meta == nil || result.Meta.AdditionalFields["raw_response"] != "not provided by the sampling client" {
+		t.Fatalf("tool result _meta %+v, want a note that the client gave no raw response", result.Meta)
+	}
+}
+
+func TestAnalyzeFileWithoutDebugRaw(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	result, _ := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	metadata, _ := sampler.Requests()[0].Metadata.(map[string]any)
+	if _, ok := metadata["debug_raw"]; ok {
+		t.Errorf("sampling request metadata %v, want no debug_raw", metadata)
+	}
+	if result.Meta != nil {
+		if _, ok := result.Meta.AdditionalFields["raw_response"]; ok {
+			t.Errorf("raw_response returned without debug_raw: %+v", result.Meta)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table unifiedDiff builds (4M cells, 16 MB).
+// Beyond it the changed region is reported as one replaced block.
+const maxDiffCells = 4 << 20
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// diffLines computes a line edit script from a to b. Common leading and
+// trailing lines are trimmed first, which keeps the LCS table small for
+// the usual case of a few localized edits.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{' ', a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		ops = append(ops, lcsDiff(midA, midB)...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// lcsDiff is the textbook longest-common-subsequence diff.
+func lcsDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	// lcs[i*(m+1)+j] is the LCS length of a[i:] and b[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff renders the changes from oldText to newText as a unified
+// diff with the given lines of context, along with the number of added and
+// removed lines. The diff is "" when nothing changed.
+func unifiedDiff(oldName, newName, oldText, newText string, contextLines int) (diff string, added, removed int) {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	// Mark which ops fall within context of a change
+	show := make([]bool, len(ops))
+	for k, op := range ops {
+		switch op.Kind {
+		case ' ':
+			continue
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+		for c := max(0, k-contextLines); c <= min(len(ops)-1, k+contextLines); c++ {
+			show[c] = true
+		}
+	}
+	if added+removed == 0 {
+		return "", 0, 0
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	oldLine, newLine := 1, 1
+	for k := 0; k < len(ops); {
+		if !show[k] {
+			if ops[k].Kind != '+' {
+				oldLine++
+			}
+			if ops[k].Kind != '-' {
+				newLine++
+			}
+			k++
+			continue
+		}
+
+		// Collect one hunk
+		end := k
+		for end < len(ops) && show[end] {
+			end++
+		}
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[k:end] {
+			if op.Kind != '+' {
+				oldCount++
+			}
+			if op.Kind != '-' {
+				newCount++
+			}
+			fmt.Fprintf(&body, "%c%s\n", op.Kind, op.Line)
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n%s", oldLine, oldCount, newLine, newCount, body.String())
+
+		oldLine += oldCount
+		newLine += newCount
+		k = end
+	}
+	return out.String(), added, removed
+}
+
+// splitLines splits text into lines without their trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,153 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var diffAnalysesTool = mcp.Tool{
+	Name:        "diff_analyses",
+	Description: "Debug prompts and models: analyze the same file with two configurations (models or custom prompts) using LLM sampling, and return a diff of the two outputs with an explanation of how they differ",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to analyze (relative to files directory)",
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis both configurations perform",
+				"enum":        AnalysisTypeNames(),
+			},
+			"model_a": map[string]any{
+				"type":        "string",
+				"description": "Model alias for configuration A, resolved by the sampling client (default: the client's model)",
+			},
+			"model_b": map[string]any{
+				"type":        "string",
+				"description": "Model alias for configuration B (default: the client's model)",
+			},
+			"custom_prompt_a": map[string]any{
+				"type":        "string",
+				"description": "Custom prompt for configuration A (default: the analysis type's prompt)",
+			},
+			"custom_prompt_b": map[string]any{
+				"type":        "string",
+				"description": "Custom prompt for configuration B (default: the analysis type's prompt)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// analysisConfig is one side of diff_analyses.
+type analysisConfig struct {
+	Model        string
+	CustomPrompt string
+}
+
+func (c analysisConfig) String() string {
+	model, prompt := "the client's model", "the analysis type's prompt"
+	if c.Model != "" {
+		model = fmt.Sprintf("model %q", c.Model)
+	}
+	if c.CustomPrompt != "" {
+		prompt = fmt.Sprintf("custom prompt %q", c.CustomPrompt)
+	}
+	return model + ", " + prompt
+}
+
+func (s *Server) handleDiffAnalyses(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	analysisType := request.GetString("analysis_type", s.cfg.DefaultAnalysis)
+	if _, ok := lookupAnalysisType(analysisType); !ok {
+		return errorResult("Unknown analysis_type %q", analysisType), nil
+	}
+	configA := analysisConfig{Model: request.GetString("model_a", ""), CustomPrompt: request.GetString("custom_prompt_a", "")}
+	configB := analysisConfig{Model: request.GetString("model_b", ""), CustomPrompt: request.GetString("custom_prompt_b", "")}
+	if configA == configB {
+		return errorResult("The two configurations are the same; set model_a and model_b or custom_prompt_a and custom_prompt_b to compare"), nil
+	}
+
+	outputs := make([]string, 2)
+	for i, config := range []analysisConfig{configA, configB} {
+		label := string(rune('A' + i))
+		logf(ctx, "🔬 Analyzing %s with configuration %s: %s", filename, label, config)
+		result, err := s.analyzeFile(ctx, analyzeOptions{
+			Filename:     filename,
+			AnalysisType: analysisType,
+			CustomPrompt: config.CustomPrompt,
+			Model:        config.Model,
+			Audience:     DefaultAudience,
+			Resume:       true,
+			UseCache:     true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.IsError {
+			return errorResult("Configuration %s failed: %s", label, toolResultText(result)), nil
+		}
+		outputs[i] = toolResultText(result)
+	}
+
+	diff, added, removed := unifiedDiff("A", "B", outputs[0], outputs[1], 3)
+	header := fmt.Sprintf("Analysis Diff\n"+
+		"=============\n"+
+		"File: %s\n"+
+		"Analysis: %s\n"+
+		"A: %s\n"+
+		"B: %s\n", filename, analysisType, configA, configB)
+	if diff == "" {
+		return textResult(header + "\nThe two outputs are identical."), nil
+	}
+
+	explanation, model, err := s.explainAnalysisDiff(ctx, configA, configB, outputs, diff)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+	logf(ctx, "✅ Explained the differences between the two analyses of %s. Model: %s", filename, model)
+
+	return textResult(fmt.Sprintf("%s"+
+		"Changes: +%d -%d lines\n\n"+
+		"Diff:\n%s\n"+
+		"Explanation (model: %s):\n%s", header, added, removed, diff, model, explanation)), nil
+}
+
+// explainAnalysisDiff asks the model how the two outputs differ and what
+// in their configurations likely caused it.
+func (s *Server) explainAnalysisDiff(ctx context.Context, configA, configB analysisConfig, outputs []string, diff string) (string, string, error) {
+	// Both outputs and the diff have to fit in one request, so each gets a
+	// third of it
+	budget := s.cfg.ChunkSize / 3
+	outputA, _ := truncateFor(ctx, outputs[0], budget)
+	outputB, _ := truncateFor(ctx, outputs[1], budget)
+	diff, _ = truncateFor(ctx, diff, budget)
+
+	content := mcp.TextContent{Type: "text", Text: fmt.Sprintf("<output_a>\n%s\n</output_a>\n<output_b>\n%s\n</output_b>\n<diff>\n%s</diff>", outputA, outputB, diff)}
+	systemPrompt := fmt.Sprintf("These are two analyses of the same file. A was produced with %s; B with %s. "+
+		"Explain how the two outputs differ: in content, accuracy, emphasis, tone, structure and length. "+
+		"Then say which differences likely come from the difference in configuration. Be concise.", configA, configB)
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.MaxTokens = 1000
+
+	logf(ctx, "📤 Sending sampling request to explain the differences between the two analyses")
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		return "", "", err
+	}
+	return resultText(result), result.Model, nil
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// modelProviders is a respond function that sends each request to the
+// provider for its model hint, as a client serving several models would.
+// Requests without a hint go to the "" provider.
+func modelProviders(providers map[string]func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)) func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	return func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		model := ""
+		if prefs := request.ModelPreferences; prefs != nil && len(prefs.Hints) > 0 {
+			model = prefs.Hints[0].Name
+		}
+		result, err := providers[model](request)
+		if result != nil && model != "" {
+			result.Model = model + "-model"
+		}
+		return result, err
+	}
+}
+
+func TestDiffAnalysesComparesTwoModels(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: modelProviders(map[string]func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error){
+		"fast":  answers("The notes are short.\nThey mention nothing urgent."),
+		"smart": answers("The notes are short.\nThey list three open questions."),
+		"":      answers("B names the open questions that A misses."),
+	})}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "diff_analyses", map[string]any{"filename": "notes.txt", "model_a": "fast", "model_b": "smart"})
+
+	for _, want := range []string{
+		`A: model "fast", the analysis type's prompt`,
+		`B: model "smart", the analysis type's prompt`,
+		"-They mention nothing urgent.",
+		"+They list three open questions.",
+		" The notes are short.",
+		"Explanation (model: mock-model):\nB names the open questions that A misses.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+
+	requests := sampler.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("%d sampling requests, want one per configuration and the explanation", len(requests))
+	}
+	explain := messageText(requests[2])
+	if !strings.Contains(explain, "<output_a>") || !strings.Contains(explain, "nothing urgent") || !strings.Contains(explain, "three open questions") {
+		t.Errorf("explanation request does not carry both outputs:\n%s", explain)
+	}
+	if !strings.Contains(requests[2].SystemPrompt, `A was produced with model "fast"`) {
+		t.Errorf("explanation prompt does not describe the configurations: %s", requests[2].SystemPrompt)
+	}
+}
+
+func TestDiffAnalysesComparesTwoPrompts(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		switch {
+		case strings.HasPrefix(request.SystemPrompt, "List the people."):
+			return textAnswer("Ana, Bo"), nil
+		case strings.HasPrefix(request.SystemPrompt, "List the dates."):
+			return textAnswer("Friday"), nil
+		}
+		return textAnswer("A lists people, B lists dates."), nil
+	}}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "diff_analyses", map[string]any{
+		"filename":        "notes.txt",
+		"custom_prompt_a": "List the people.",
+		"custom_prompt_b": "List the dates.",
+	})
+
+	if !strings.Contains(text, "-Ana, Bo") || !strings.Contains(text, "+Friday") || !strings.Contains(text, `B: the client's model, custom prompt "List the dates."`) {
+		t.Errorf("unexpected diff:\n%s", text)
+	}
+}
+
+func TestDiffAnalysesIdenticalOutputs(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "diff_analyses", map[string]any{"filename": "notes.txt", "model_a": "fast", "model_b": "smart"})
+
+	if !strings.HasSuffix(text, "The two outputs are identical.") {
+		t.Errorf("unexpected result:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want no explanation of identical outputs", n)
+	}
+}
+
+func TestDiffAnalysesRefusals(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "diff_analyses", map[string]any{"filename": "notes.txt", "model_a": "fast", "model_b": "fast"}); !strings.Contains(text, "The two configurations are the same") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "diff_analyses", map[string]any{"filename": "notes.txt", "model_b": "smart", "analysis_type": "poem"}); !strings.Contains(text, `Unknown analysis_type "poem"`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("refused calls sent %d sampling requests", n)
+	}
+
+	if text := mustFail(t, c, "diff_analyses", map[string]any{"filename": "missing.txt", "model_b": "smart"}); !strings.Contains(text, "Configuration A failed") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
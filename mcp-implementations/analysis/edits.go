@@ -0,0 +1,218 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DefaultEditSuggestions is used when suggest_edits is not given a count.
+	DefaultEditSuggestions = 10
+	// MaxEditSuggestions bounds max_suggestions so one answer fits in MaxTokens.
+	MaxEditSuggestions = 30
+)
+
+var suggestEditsTool = mcp.Tool{
+	Name:        "suggest_edits",
+	Description: "Review a text or source file and suggest concrete edits using LLM sampling. Returns JSON listing each edit's location, the issue and the proposed replacement, rather than a rewritten file",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to review (relative to files directory)",
+			},
+			"focus": map[string]any{
+				"type":        "string",
+				"description": "What to look for, e.g. \"clarity\", \"grammar\" or \"error handling\" (default: anything worth fixing)",
+			},
+			"max_suggestions": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Most suggestions to return (default %d, max %d)", DefaultEditSuggestions, MaxEditSuggestions),
+			},
+			"line_numbers": map[string]any{
+				"type":        "boolean",
+				"description": "Show the model numbered lines and report the line of each suggestion (default true)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// EditSuggestion is one proposed edit. Original is the exact text to
+// change and Replacement what to put instead. Verified reports whether
+// Original was found in the file; Line is read from where it was found,
+// or from the model when it was not.
+type EditSuggestion struct {
+	Line        int    `json:"line,omitempty"`
+	Original    string `json:"original"`
+	Issue       string `json:"issue"`
+	Replacement string `json:"replacement"`
+	Verified    bool   `json:"verified"`
+}
+
+// EditSuggestions is the structured result of suggest_edits.
+type EditSuggestions struct {
+	File        string           `json:"file"`
+	Model       string           `json:"model"`
+	Suggestions []EditSuggestion `json:"suggestions"`
+	// Truncated reports that only part of the file was reviewed
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleSuggestEdits(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	focus := strings.TrimSpace(request.GetString("focus", ""))
+	maxSuggestions := request.GetInt("max_suggestions", DefaultEditSuggestions)
+	if maxSuggestions < 1 || maxSuggestions > MaxEditSuggestions {
+		return errorResult("max_suggestions must be between 1 and %d", MaxEditSuggestions), nil
+	}
+	lineNumbers := request.GetBool("line_numbers", true)
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	// Lines are numbered before truncating, so the numbers stay right
+	// whichever part is kept
+	shown := text
+	if lineNumbers {
+		shown = numberLines(text)
+	}
+	edits := EditSuggestions{File: filename}
+	shown, edits.Truncated = truncateFor(ctx, shown, s.cfg.ChunkSize)
+
+	content := mcp.TextContent{Type: "text", Text: shown}
+	systemPrompt := fmt.Sprintf("Review this file and suggest at most %d concrete edits, most important first. ", maxSuggestions)
+	if focus != "" {
+		systemPrompt += fmt.Sprintf("Focus on %s. ", focus)
+	}
+	if lineNumbers {
+		systemPrompt += "Each line is prefixed with its number and \" | \", which is not part of the file. "
+	}
+	systemPrompt += "For each edit, quote the exact text to change, copied character for character from one place in the file, and give the text to replace it with. " +
+		"Suggest only changes that fix a real problem; return an empty list if there are none. " +
+		`Respond with only a JSON object: {"suggestions": [{"line": <line number>, "original": "<exact text>", "issue": "<what is wrong>", "replacement": "<new text>"}]}.`
+	if edits.Truncated {
+		systemPrompt += " The file was truncated; review only what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 200*maxSuggestions + 200
+
+	logf(ctx, "📤 Sending sampling request to suggest edits for: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid edit suggestions", func(text string) (err error) {
+		edits.Suggestions, err = parseEditSuggestions(text, maxSuggestions)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	edits.Model = result.Model
+
+	verified := 0
+	for i := range edits.Suggestions {
+		if groundSuggestion(text, &edits.Suggestions[i]) {
+			verified++
+		}
+	}
+	logf(ctx, "✅ Suggested %d edits for %s (%d located in the file)", len(edits.Suggestions), filename, verified)
+
+	data, err := json.MarshalIndent(edits, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding edit suggestions: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseEditSuggestions decodes the model's suggestions. Each needs the
+// original text, an issue and a replacement that differs from the
+// original; suggestions past limit are dropped.
+func parseEditSuggestions(text string, limit int) ([]EditSuggestion, error) {
+	var answer struct {
+		Suggestions []EditSuggestion `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if answer.Suggestions == nil {
+		return nil, fmt.Errorf("suggestions is missing")
+	}
+
+	for i, suggestion := range answer.Suggestions {
+		switch {
+		case suggestion.Original == "":
+			return nil, fmt.Errorf("suggestion %d has no original text", i+1)
+		case strings.TrimSpace(suggestion.Issue) == "":
+			return nil, fmt.Errorf("suggestion %d has no issue", i+1)
+		case suggestion.Replacement == suggestion.Original:
+			return nil, fmt.Errorf("suggestion %d does not change anything", i+1)
+		}
+		answer.Suggestions[i].Issue = strings.TrimSpace(suggestion.Issue)
+		answer.Suggestions[i].Verified = false
+	}
+	if len(answer.Suggestions) > limit {
+		answer.Suggestions = answer.Suggestions[:limit]
+	}
+	return answer.Suggestions, nil
+}
+
+// groundSuggestion looks for a suggestion's original text in the file and
+// takes its line from where it is. When the text occurs more than once the
+// occurrence nearest the model's line is used. A model line outside the
+// file is dropped. It reports whether the original was found.
+func groundSuggestion(text string, suggestion *EditSuggestion) bool {
+	lines := strings.Count(text, "\n") + 1
+	best := 0
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], suggestion.Original)
+		if i < 0 {
+			break
+		}
+		line := strings.Count(text[:offset+i], "\n") + 1
+		if best == 0 || abs(line-suggestion.Line) < abs(best-suggestion.Line) {
+			best = line
+		}
+		offset += i + 1
+	}
+
+	if best > 0 {
+		suggestion.Line, suggestion.Verified = best, true
+		return true
+	}
+	if suggestion.Line < 1 || suggestion.Line > lines {
+		suggestion.Line = 0
+	}
+	return false
+}
+
+// numberLines prefixes each line of text with its number, as errorExcerpt
+// does.
+func numberLines(text string) string {
+	var b strings.Builder
+	for n, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(&b, "%4d | %s\n", n+1, line)
+	}
+	return b.String()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
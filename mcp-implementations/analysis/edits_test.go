@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// essay has a typo on line 2 and the same phrase on lines 1 and 4.
+const essay = `It is what it is.
+Teh results were good.
+We ran three trials.
+It is what it is.
+`
+
+// editsOf decodes a suggest_edits result.
+func editsOf(t *testing.T, text string) EditSuggestions {
+	t.Helper()
+	var edits EditSuggestions
+	if err := json.Unmarshal([]byte(text), &edits); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	return edits
+}
+
+func TestSuggestEditsParsesAndGroundsSuggestions(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.txt": essay})
+	sampler := &mockSampler{respond: answers("```json\n" + `{"suggestions": [
+		{"line": 9, "original": "Teh results", "issue": " Typo. ", "replacement": "The results"},
+		{"line": 4, "original": "It is what it is.", "issue": "Filler.", "replacement": ""},
+		{"line": 3, "original": "four trials", "issue": "Count is wrong.", "replacement": "three trials"},
+		{"line": 99, "original": "missing", "issue": "Not there.", "replacement": "gone"}
+	]}` + "\n```")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "suggest_edits", map[string]any{"filename": "essay.txt"})
+
+	edits := editsOf(t, text)
+	want := []EditSuggestion{
+		{Line: 2, Original: "Teh results", Issue: "Typo.", Replacement: "The results", Verified: true},
+		{Line: 4, Original: "It is what it is.", Issue: "Filler.", Replacement: "", Verified: true},
+		{Line: 3, Original: "four trials", Issue: "Count is wrong.", Replacement: "three trials"},
+		{Line: 0, Original: "missing", Issue: "Not there.", Replacement: "gone"},
+	}
+	if len(edits.Suggestions) != len(want) {
+		t.Fatalf("%d suggestions, want %d:\n%s", len(edits.Suggestions), len(want), text)
+	}
+	for i := range want {
+		if edits.Suggestions[i] != want[i] {
+			t.Errorf("suggestion %d = %+v, want %+v", i+1, edits.Suggestions[i], want[i])
+		}
+	}
+	if edits.File != "essay.txt" || edits.Model != "mock-model" || edits.Truncated {
+		t.Errorf("unexpected result: %+v", edits)
+	}
+}
+
+func TestSuggestEditsLineNumbers(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.txt": essay})
+	sampler := &mockSampler{respond: answers(`{"suggestions": []}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "suggest_edits", map[string]any{"filename": "essay.txt", "focus": "spelling"})
+	if edits := editsOf(t, text); edits.Suggestions == nil || len(edits.Suggestions) != 0 {
+		t.Errorf("an empty list should stay an empty list: %s", text)
+	}
+	mustSucceed(t, c, "suggest_edits", map[string]any{"filename": "essay.txt", "line_numbers": false})
+
+	requests := sampler.Requests()
+	if sent := messageText(requests[0]); !strings.Contains(sent, "   2 | Teh results were good.\n") {
+		t.Errorf("lines were not numbered:\n%s", sent)
+	}
+	if prompt := requests[0].SystemPrompt; !strings.Contains(prompt, "Focus on spelling.") || !strings.Contains(prompt, `prefixed with its number and " | "`) {
+		t.Errorf("prompt does not mention the focus and numbering: %s", prompt)
+	}
+	if sent := messageText(requests[1]); sent != essay || strings.Contains(requests[1].SystemPrompt, "prefixed with its number") {
+		t.Errorf("line_numbers false still numbered the file:\n%s", sent)
+	}
+}
+
+func TestSuggestEditsLimitsSuggestions(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.txt": essay})
+	sampler := &mockSampler{respond: answers(`{"suggestions": [
+		{"original": "Teh", "issue": "Typo.", "replacement": "The"},
+		{"original": "three", "issue": "Spell out.", "replacement": "3"}
+	]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "suggest_edits", map[string]any{"filename": "essay.txt", "max_suggestions": 1})
+
+	if edits := editsOf(t, text); len(edits.Suggestions) != 1 || edits.Suggestions[0].Original != "Teh" {
+		t.Errorf("suggestions past max_suggestions were kept: %s", text)
+	}
+	if request := sampler.Requests()[0]; !strings.Contains(request.SystemPrompt, "at most 1 concrete edits") || request.MaxTokens != 400 {
+		t.Errorf("unexpected request: %q, max tokens %d", request.SystemPrompt, request.MaxTokens)
+	}
+	for _, n := range []int{0, MaxEditSuggestions + 1} {
+		if text := mustFail(t, c, "suggest_edits", map[string]any{"filename": "essay.txt", "max_suggestions": n}); !strings.Contains(text, "max_suggestions must be between 1 and 30") {
+			t.Errorf("max_suggestions %d: unexpected error %s", n, text)
+		}
+	}
+}
+
+func TestParseEditSuggestionsRejectsIncompleteSuggestions(t *testing.T) {
+	tests := map[string]string{
+		`{"suggestions": [{"issue": "Typo.", "replacement": "The"}]}`:                    "suggestion 1 has no original text",
+		`{"suggestions": [{"original": "Teh", "issue": " ", "replacement": "The"}]}`:     "suggestion 1 has no issue",
+		`{"suggestions": [{"original": "Teh", "issue": "Typo.", "replacement": "Teh"}]}`: "suggestion 1 does not change anything",
+		`{"edits": []}`:     "suggestions is missing",
+		`{"suggestions": [`: "not valid JSON",
+	}
+	for answer, want := range tests {
+		if _, err := parseEditSuggestions(answer, 10); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", answer, err, want)
+		}
+	}
+}
+
+func TestSuggestEditsRetriesIncompleteSuggestions(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.txt": essay})
+	sampler := &mockSampler{respond: answers(
+		`{"suggestions": [{"original": "Teh", "replacement": "The"}]}`,
+		`{"suggestions": [{"original": "Teh", "issue": "Typo.", "replacement": "The"}]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "suggest_edits", map[string]any{"filename": "essay.txt"})
+
+	if edits := editsOf(t, text); len(edits.Suggestions) != 1 || !edits.Suggestions[0].Verified {
+		t.Errorf("unexpected suggestions: %s", text)
+	}
+	if prompt := sampler.Requests()[1].SystemPrompt; !strings.Contains(prompt, "suggestion 1 has no issue") {
+		t.Errorf("the retry does not give the reason: %s", prompt)
+	}
+}
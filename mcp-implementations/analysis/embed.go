@@ -0,0 +1,198 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultEmbeddingChunkSize is the largest text, in bytes, embedded as one
+// vector (~2k tokens, well inside common embedding model limits).
+const DefaultEmbeddingChunkSize = 8000
+
+// Embedder turns text into embedding vectors, one per input.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+	Model() string
+}
+
+// Embedding providers with an OpenAI-compatible /embeddings API.
+var EmbeddingProviders = map[string]struct {
+	URL    string
+	Model  string
+	KeyEnv string
+}{
+	"openai": {URL: "https://api.openai.com/v1/embeddings", Model: "text-embedding-3-small", KeyEnv: "OPENAI_API_KEY"},
+	"voyage": {URL: "https://api.voyageai.com/v1/embeddings", Model: "voyage-3", KeyEnv: "VOYAGE_API_KEY"},
+}
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint. Unlike the
+// analysis tools it talks to the provider directly, because MCP sampling
+// has no way to ask the client for embeddings.
+type HTTPEmbedder struct {
+	URL        string
+	ModelName  string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewHTTPEmbedder creates an embedder for the given endpoint and model.
+func NewHTTPEmbedder(url, model, apiKey string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		URL:       url,
+		ModelName: model,
+		APIKey:    apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 1 * time.Minute,
+		},
+	}
+}
+
+// Model returns the embedding model name.
+func (e *HTTPEmbedder) Model() string {
+	return e.ModelName
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed sends all texts in one request and returns their vectors in order.
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	reqBody, err := json.Marshal(embeddingRequest{Model: e.ModelName, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", e.URL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+e.APIKey)
+
+	resp, err := e.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("embeddings request failed with status %d", resp.StatusCode)
+	}
+
+	var embResp embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, d := range embResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+var embedFileTool = mcp.Tool{
+	Name:        "embed_file",
+	Description: "Generate embedding vectors for a text file, one per chunk",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The name of the file to embed (relative to files directory)",
+			},
+			"full_vectors": map[string]any{
+				"type":        "boolean",
+				"description": "Return complete vectors instead of a short preview (default false)",
+			},
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// embeddingPreviewLength is how many leading values a vector preview shows.
+const embeddingPreviewLength = 8
+
+type embeddedChunk struct {
+	Index   int       `json:"index"`
+	Bytes   int       `json:"bytes"`
+	Vector  []float64 `json:"vector,omitempty"`
+	Preview []float64 `json:"vector_preview,omitempty"`
+}
+
+type embedFileResult struct {
+	File       string          `json:"file"`
+	Model      string          `json:"model"`
+	Dimensions int             `json:"dimensions"`
+	Chunks     []embeddedChunk `json:"chunks"`
+}
+
+func (s *Server) handleEmbedFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	fullVectors := request.GetBool("full_vectors", false)
+
+	if s.cfg.Embedder == nil {
+		return errorResult("Embeddings are not configured on this server (start it with -embeddings-provider)"), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	chunks := splitChunks(text, s.cfg.EmbeddingChunkSize)
+
+	logf(ctx, "📤 Requesting %d embeddings for file: %s (model: %s)", len(chunks), filename, s.cfg.Embedder.Model())
+	vectors, err := s.cfg.Embedder.Embed(ctx, chunks)
+	if err != nil {
+		log.Printf("❌ Embeddings request failed: %v", err)
+		return errorResult("Error requesting embeddings: %v", err), nil
+	}
+
+	result := embedFileResult{File: filename, Model: s.cfg.Embedder.Model()}
+	for i, vector := range vectors {
+		chunk := embeddedChunk{Index: i, Bytes: len(chunks[i])}
+		if fullVectors {
+			chunk.Vector = vector
+		} else {
+			chunk.Preview = vector[:min(len(vector), embeddingPreviewLength)]
+		}
+		result.Dimensions = len(vector)
+		result.Chunks = append(result.Chunks, chunk)
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding embeddings: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
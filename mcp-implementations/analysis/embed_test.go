@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockEmbeddings is an embeddings endpoint answering each input with a
+// vector of dims values, the first being the input's length.
+type mockEmbeddings struct {
+	mu     sync.Mutex
+	inputs [][]string
+	auth   []string
+	dims   int
+}
+
+func (m *mockEmbeddings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request embeddingRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	m.inputs = append(m.inputs, request.Input)
+	m.auth = append(m.auth, r.Header.Get("Authorization"))
+	m.mu.Unlock()
+
+	type item struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	}
+	var data []item
+	// Answer in reverse so the embedder must order by index
+	for i := len(request.Input) - 1; i >= 0; i-- {
+		vector := make([]float64, m.dims)
+		vector[0] = float64(len(request.Input[i]))
+		data = append(data, item{Index: i, Embedding: vector})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func TestEmbedFileReturnsVectorPerChunk(t *testing.T) {
+	endpoint := &mockEmbeddings{dims: 16}
+	ts := httptest.NewServer(endpoint)
+	t.Cleanup(ts.Close)
+
+	content := strings.Repeat("a", 90) + "\n" + strings.Repeat("b", 40) + "\n"
+	s := newTestServer(t, Config{
+		Embedder:           NewHTTPEmbedder(ts.URL, "test-embed", "embed-key"),
+		EmbeddingChunkSize: 100,
+	}, map[string]string{"notes.txt": content})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "embed_file", map[string]any{"filename": "notes.txt"})
+	var result embedFileResult
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("embed_file did not return JSON: %v\n%s", err, text)
+	}
+
+	if len(endpoint.inputs) != 1 || len(endpoint.inputs[0]) != 2 {
+		t.Fatalf("endpoint got inputs %v, want one request with 2 chunks", endpoint.inputs)
+	}
+	if endpoint.auth[0] != "Bearer embed-key" {
+		t.Errorf("endpoint got Authorization %q", endpoint.auth[0])
+	}
+	if result.Model != "test-embed" || result.Dimensions != 16 || len(result.Chunks) != 2 {
+		t.Fatalf("result %+v, want 2 chunks of 16 dimensions from test-embed", result)
+	}
+	for i, chunk := range result.Chunks {
+		if len(chunk.Preview) != embeddingPreviewLength || chunk.Vector != nil {
+			t.Errorf("chunk %d: preview of %d values and vector %v, want only a preview", i, len(chunk.Preview), chunk.Vector)
+		}
+		if chunk.Preview[0] != float64(chunk.Bytes) {
+			t.Errorf("chunk %d got the vector of another chunk", i)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("embed_file sent %d sampling requests, want none", n)
+	}
+
+	_, text = mustSucceed(t, c, "embed_file", map[string]any{"filename": "notes.txt", "full_vectors": true})
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Chunks[0].Vector) != 16 {
+		t.Errorf("full_vectors returned %d values, want 16", len(result.Chunks[0].Vector))
+	}
+}
+
+func TestEmbedFileReportsEndpointErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+
+	s := newTestServer(t, Config{Embedder: NewHTTPEmbedder(ts.URL, "test-embed", "")}, map[string]string{"notes.txt": "text"})
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "embed_file", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(text, "status 503") {
+		t.Errorf("error does not report the endpoint's status: %s", text)
+	}
+}
+
+func TestEmbedFileNotConfigured(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "text"})
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "embed_file", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(text, "not configured") {
+		t.Errorf("error does not say embeddings are not configured: %s", text)
+	}
+}
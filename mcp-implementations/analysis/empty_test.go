@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeFileRejectsEmptyFileWithoutSampling(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"empty.txt": ""})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "empty.txt"})
+	if text != "empty.txt is empty; there is nothing to analyze" {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("an empty file sent %d sampling requests", n)
+	}
+}
+
+func TestAnalyzeBatchReportsEmptyFile(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"empty.txt": "", "notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := callTool(t, c, "analyze_batch", map[string]any{"filenames": []any{"empty.txt", "notes.txt"}})
+	if !strings.Contains(text, "empty.txt is empty; there is nothing to analyze") || !strings.Contains(text, mockAnswer) {
+		t.Errorf("batch does not report the empty file beside the other result:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want 1 for the non-empty file", n)
+	}
+}
+
+func TestTextToolsRejectEmptyFile(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"empty.txt": ""})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tool := range []string{"generate_quiz", "outline", "extract_tables"} {
+		text := mustFail(t, c, tool, map[string]any{"filename": "empty.txt"})
+		if !strings.Contains(text, "empty.txt is empty") {
+			t.Errorf("%s: unexpected error: %s", tool, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("empty files sent %d sampling requests", n)
+	}
+}
@@ -0,0 +1,211 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var evalFileTool = mcp.Tool{
+	Name:        "eval_file",
+	Description: "Analyze a file and score the output against a reference answer, for tuning prompts. The score comes from a grading request to the model or from word overlap",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to analyze (relative to files directory)",
+			},
+			"expected": map[string]any{
+				"type":        "string",
+				"description": "The reference answer the analysis should match",
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis to perform",
+				"enum":        AnalysisTypeNames(),
+			},
+			"custom_prompt": map[string]any{
+				"type":        "string",
+				"description": "Custom analysis prompt, the one being tuned (optional)",
+			},
+			"grader": map[string]any{
+				"type":        "string",
+				"description": "How to score the output: model asks the model to grade it, overlap measures shared words without sampling (default model)",
+				"enum":        evalGraders,
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "expected"},
+	},
+}
+
+// evalGraders are the ways eval_file can score an output.
+var evalGraders = []string{"model", "overlap"}
+
+// EvalResult is the structured result of eval_file. Score runs from 0 (no
+// match) to 1 (matches the reference).
+type EvalResult struct {
+	File         string  `json:"file"`
+	AnalysisType string  `json:"analysis_type"`
+	Model        string  `json:"model"`
+	Grader       string  `json:"grader"`
+	GraderModel  string  `json:"grader_model,omitempty"`
+	Score        float64 `json:"score"`
+	Rationale    string  `json:"rationale"`
+	Output       string  `json:"output"`
+}
+
+func (s *Server) handleEvalFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	expected, err := request.RequireString("expected")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(expected) == "" {
+		return errorResult("expected is empty; give the reference answer to score against"), nil
+	}
+	grader := request.GetString("grader", "model")
+	if !slices.Contains(evalGraders, grader) {
+		return errorResult("Unknown grader %q (use %s)", grader, strings.Join(evalGraders, " or ")), nil
+	}
+
+	opts := analyzeOptionsFrom(request, s.cfg.DefaultAnalysis)
+	opts.Filename = filename
+	analysis, err := s.analyzeFile(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.IsError {
+		return analysis, nil
+	}
+	model, output := analysisAnswer(toolResultText(analysis))
+
+	eval := EvalResult{File: filename, AnalysisType: opts.AnalysisType, Model: model, Grader: grader, Output: output}
+	if grader == "overlap" {
+		eval.Score, eval.Rationale = wordOverlap(output, expected)
+	} else {
+		eval.Score, eval.Rationale, eval.GraderModel, err = s.gradeOutput(ctx, output, expected)
+		if err != nil {
+			return errorResult("%v", err), nil
+		}
+	}
+
+	logf(ctx, "✅ Evaluated %s (%s): score %.2f", filename, opts.AnalysisType, eval.Score)
+
+	data, err := json.MarshalIndent(eval, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding evaluation: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// analysisAnswer splits an analyze_file result into the model named in its
+// header and the answer after it.
+func analysisAnswer(text string) (string, string) {
+	header, answer, ok := strings.Cut(text, "\n\n")
+	if !ok {
+		return "", text
+	}
+	var model string
+	for _, line := range strings.Split(header, "\n") {
+		if name, ok := strings.CutPrefix(line, "Model: "); ok {
+			model = name
+		}
+	}
+	return model, answer
+}
+
+// gradeOutput asks the model how closely output matches expected, and
+// returns its score, rationale and the grading model.
+func (s *Server) gradeOutput(ctx context.Context, output, expected string) (float64, string, string, error) {
+	content := mcp.TextContent{
+		Type: "text",
+		Text: fmt.Sprintf("<reference>\n%s\n</reference>\n\n<output>\n%s\n</output>", expected, output),
+	}
+	systemPrompt := "You grade an analysis against a reference answer. Judge whether the output conveys the same content as the reference: " +
+		"the same points, facts and conclusions, however it is worded. Missing points, wrong facts and unsupported additions lower the score. " +
+		`Respond with only a JSON object: {"score": <number from 0 to 1>, "rationale": "<one or two sentences>"}.`
+
+	var score float64
+	var rationale string
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 500
+
+	logf(ctx, "📤 Sending sampling request to grade an analysis")
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid grade", func(text string) (err error) {
+		score, rationale, err = parseGrade(text)
+		return err
+	})
+	if err != nil {
+		return 0, "", "", err
+	}
+	return score, rationale, result.Model, nil
+}
+
+// parseGrade decodes the model's grade, requiring a score from 0 to 1.
+func parseGrade(text string) (float64, string, error) {
+	var answer struct {
+		Score     *float64 `json:"score"`
+		Rationale string   `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return 0, "", fmt.Errorf("not valid JSON: %v", err)
+	}
+	if answer.Score == nil {
+		return 0, "", fmt.Errorf("no score given")
+	}
+	if *answer.Score < 0 || *answer.Score > 1 {
+		return 0, "", fmt.Errorf("score %v is outside 0 to 1", *answer.Score)
+	}
+	return *answer.Score, strings.TrimSpace(answer.Rationale), nil
+}
+
+// wordOverlap scores output against expected by the words they share: the
+// F1 of word precision and recall, ignoring case and punctuation.
+func wordOverlap(output, expected string) (float64, string) {
+	words := func(text string) map[string]int {
+		counts := map[string]int{}
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			counts[w]++
+		}
+		return counts
+	}
+	got, want := words(output), words(expected)
+
+	var gotTotal, wantTotal, shared int
+	for w, n := range got {
+		gotTotal += n
+		shared += min(n, want[w])
+	}
+	for _, n := range want {
+		wantTotal += n
+	}
+	if gotTotal == 0 || wantTotal == 0 {
+		return 0, "The output or the reference has no words to compare."
+	}
+
+	precision := float64(shared) / float64(gotTotal)
+	recall := float64(shared) / float64(wantTotal)
+	var score float64
+	if shared > 0 {
+		score = 2 * precision * recall / (precision + recall)
+	}
+	return math.Round(score*100) / 100, fmt.Sprintf("%d of the reference's %d words appear in the output (recall %.2f); %d of the output's %d words are in the reference (precision %.2f).",
+		shared, wantTotal, recall, shared, gotTotal, precision)
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func parseEval(t *testing.T, text string) EvalResult {
+	t.Helper()
+	var eval EvalResult
+	if err := json.Unmarshal([]byte(text), &eval); err != nil {
+		t.Fatalf("result is not an evaluation: %v\n%s", err, text)
+	}
+	return eval
+}
+
+func TestEvalFileWithModelGrader(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"report.txt": "Sales rose 10% in March."})
+	sampler := &mockSampler{respond: answers(
+		"Sales rose in March.",
+		`{"score": 0.8, "rationale": "Same trend, but the figure is missing."}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "eval_file", map[string]any{"filename": "report.txt", "expected": "Sales rose 10% in March."})
+
+	eval := parseEval(t, text)
+	want := EvalResult{
+		File: "report.txt", AnalysisType: "summarize", Model: "mock-model", Grader: "model", GraderModel: "mock-model",
+		Score: 0.8, Rationale: "Same trend, but the figure is missing.", Output: "Sales rose in March.",
+	}
+	if eval != want {
+		t.Errorf("got %+v, want %+v", eval, want)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want the analysis and the grade", len(requests))
+	}
+	if grading := messageText(requests[1]); !strings.Contains(grading, "<reference>\nSales rose 10% in March.\n</reference>") || !strings.Contains(grading, "<output>\nSales rose in March.\n</output>") {
+		t.Errorf("grader did not get the reference and output:\n%s", grading)
+	}
+}
+
+func TestEvalFileRepromptsGraderOnInvalidScore(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"report.txt": "Sales rose."})
+	sampler := &mockSampler{respond: answers("Sales rose.", `{"score": 8, "rationale": "Out of ten."}`, `{"score": 0.9, "rationale": "Close."}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "eval_file", map[string]any{"filename": "report.txt", "expected": "Sales rose."})
+
+	if eval := parseEval(t, text); eval.Score != 0.9 {
+		t.Errorf("got score %v, want the regraded 0.9", eval.Score)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 3 || !strings.Contains(requests[2].SystemPrompt, "score 8 is outside 0 to 1") {
+		t.Errorf("an out-of-range score was not reprompted: %d requests", len(requests))
+	}
+}
+
+func TestEvalFileWithOverlapGrader(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"report.txt": "Sales rose."})
+	sampler := &mockSampler{respond: answers("Sales rose in March.")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "eval_file", map[string]any{"filename": "report.txt", "expected": "sales rose in April", "grader": "overlap"})
+
+	eval := parseEval(t, text)
+	if eval.Score != 0.75 || eval.GraderModel != "" || !strings.HasPrefix(eval.Rationale, "3 of the reference's 4 words") {
+		t.Errorf("unexpected evaluation: %+v", eval)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("overlap grading sent %d sampling requests, want only the analysis", n)
+	}
+}
+
+func TestEvalFileValidatesArguments(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"report.txt": "Sales rose."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "eval_file", map[string]any{"filename": "report.txt", "expected": "  "}); !strings.Contains(text, "expected is empty") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "eval_file", map[string]any{"filename": "report.txt", "expected": "x", "grader": "bleu"}); !strings.Contains(text, `Unknown grader "bleu"`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid arguments sent %d sampling requests", n)
+	}
+}
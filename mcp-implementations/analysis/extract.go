@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var extractFieldTool = mcp.Tool{
+	Name:        "extract_field",
+	Description: "Find one value in a document, such as an invoice total or an author's name, using LLM sampling. Returns the value as JSON with a confidence and the passage it was read from, or found: false",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to search (relative to files directory)",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The value to extract, e.g. \"invoice total\" or \"author name\"",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "query"},
+	},
+}
+
+// FieldExtraction is the structured result of extract_field. Value,
+// Confidence and Source are set only when Found is true.
+type FieldExtraction struct {
+	File       string      `json:"file"`
+	Query      string      `json:"query"`
+	Model      string      `json:"model"`
+	Found      bool        `json:"found"`
+	Value      string      `json:"value,omitempty"`
+	Confidence float64     `json:"confidence,omitempty"`
+	Source     *SourceSpan `json:"source,omitempty"`
+}
+
+// SourceSpan is the passage a value was read from. Start and End are byte
+// offsets into the document and Line is 1-based; Line is zero, and left
+// out, when the quote could not be located exactly. Verified reports whether the
+// quote appears in the document at all, ignoring case and whitespace.
+type SourceSpan struct {
+	Text     string `json:"text"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Line     int    `json:"line,omitempty"`
+	Verified bool   `json:"verified"`
+}
+
+func (s *Server) handleExtractField(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	query, err := request.RequireString("query")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return errorResult("query is empty; name the value to extract"), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if len(text) > s.cfg.ChunkSize {
+		return errorResult("%s is %d bytes, more than the %d bytes that fit in one request", filename, len(text), s.cfg.ChunkSize), nil
+	}
+
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := fmt.Sprintf("Find this value in the document: %s. Return the value exactly as the document states it, without explanation. "+
+		"Quote the shortest passage of the document that contains it, copied character for character. "+
+		`Respond with only a JSON object: {"found": true, "value": "<value>", "confidence": <number from 0 to 1>, "source": "<quoted passage>"}, `+
+		`or {"found": false} if the document does not contain the value. Do not guess.`, query)
+
+	extraction := FieldExtraction{File: filename, Query: query}
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 500
+
+	logf(ctx, "📤 Sending sampling request to extract %q from %s", query, filename)
+	var quote string
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid extraction", func(answer string) (err error) {
+		extraction.Found, extraction.Value, extraction.Confidence, quote, err = parseExtraction(answer)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	extraction.Model = result.Model
+	if extraction.Found {
+		extraction.Source = locateSource(text, quote)
+	}
+
+	if extraction.Found {
+		logf(ctx, "✅ Extracted %q from %s (confidence %.2f)", query, filename, extraction.Confidence)
+	} else {
+		logf(ctx, "✅ %q not found in %s", query, filename)
+	}
+
+	data, err := json.MarshalIndent(extraction, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding extraction: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseExtraction decodes the model's answer. A found value needs a
+// non-empty value and source and a confidence between 0 and 1.
+func parseExtraction(text string) (found bool, value string, confidence float64, source string, err error) {
+	var answer struct {
+		Found      *bool    `json:"found"`
+		Value      any      `json:"value"`
+		Confidence *float64 `json:"confidence"`
+		Source     string   `json:"source"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return false, "", 0, "", fmt.Errorf("not valid JSON: %v", err)
+	}
+	if answer.Found == nil {
+		return false, "", 0, "", fmt.Errorf("found is missing")
+	}
+	if !*answer.Found {
+		return false, "", 0, "", nil
+	}
+
+	// Models sometimes answer a number or boolean without quotes
+	switch v := answer.Value.(type) {
+	case string:
+		value = strings.TrimSpace(v)
+	case nil:
+	default:
+		data, _ := json.Marshal(v)
+		value = string(data)
+	}
+	if value == "" {
+		return false, "", 0, "", fmt.Errorf("found is true but value is empty")
+	}
+	if answer.Confidence == nil || *answer.Confidence < 0 || *answer.Confidence > 1 {
+		return false, "", 0, "", fmt.Errorf("confidence must be a number from 0 to 1")
+	}
+	if strings.TrimSpace(answer.Source) == "" {
+		return false, "", 0, "", fmt.Errorf("source is empty")
+	}
+	return true, value, *answer.Confidence, strings.TrimSpace(answer.Source), nil
+}
+
+// locateSource finds quote in text, exactly if it can, for its offsets and
+// line, and otherwise by the looser comparison citations use.
+func locateSource(text, quote string) *SourceSpan {
+	span := &SourceSpan{Text: quote}
+	if start := strings.Index(text, quote); start >= 0 {
+		span.Start, span.End = start, start+len(quote)
+		span.Line = strings.Count(text[:start], "\n") + 1
+		span.Verified = true
+		return span
+	}
+	verified, _ := verifyCitations(text, []string{quote})
+	span.Verified = len(verified) == 1
+	return span
+}
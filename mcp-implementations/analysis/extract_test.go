@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const invoiceFixture = `ACME Supplies Ltd.
+Invoice #INV-2024-0042
+Date: 2024-03-15
+
+Item            Qty   Price
+Widgets          10   $12.50
+Gadgets           2   $40.00
+
+Subtotal: $205.00
+Tax (10%): $20.50
+Total due: $225.50
+`
+
+// extractionResult decodes an extract_field result.
+func extractionResult(t *testing.T, text string) FieldExtraction {
+	t.Helper()
+	var extraction FieldExtraction
+	if err := json.Unmarshal([]byte(text), &extraction); err != nil {
+		t.Fatalf("result is not an extraction: %v\n%s", err, text)
+	}
+	return extraction
+}
+
+func TestExtractFieldFindsValueWithSource(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"invoice.txt": invoiceFixture})
+	sampler := &mockSampler{respond: answers(`{"found": true, "value": "$225.50", "confidence": 0.95, "source": "Total due: $225.50"}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "extract_field", map[string]any{"filename": "invoice.txt", "query": "invoice total"})
+
+	got := extractionResult(t, text)
+	if !got.Found || got.Value != "$225.50" || got.Confidence != 0.95 || got.Model != "mock-model" {
+		t.Errorf("unexpected extraction: %+v", got)
+	}
+	start := strings.Index(invoiceFixture, "Total due")
+	want := SourceSpan{Text: "Total due: $225.50", Start: start, End: start + len("Total due: $225.50"), Line: 11, Verified: true}
+	if got.Source == nil || *got.Source != want {
+		t.Errorf("source = %+v, want %+v", got.Source, want)
+	}
+	if prompt := sampler.Requests()[0].SystemPrompt; !strings.Contains(prompt, "Find this value in the document: invoice total.") {
+		t.Errorf("system prompt does not carry the query: %q", prompt)
+	}
+}
+
+func TestExtractFieldReportsNotFound(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"invoice.txt": invoiceFixture})
+	c := connect(t, s, &mockSampler{respond: answers(`{"found": false}`)})
+
+	_, text := mustSucceed(t, c, "extract_field", map[string]any{"filename": "invoice.txt", "query": "purchase order number"})
+
+	got := extractionResult(t, text)
+	if got.Found || got.Value != "" || got.Source != nil {
+		t.Errorf("unexpected extraction for an absent field: %+v", got)
+	}
+	if strings.Contains(text, `"value"`) || strings.Contains(text, `"source"`) {
+		t.Errorf("a not-found result carries value fields:\n%s", text)
+	}
+}
+
+func TestExtractFieldRepromptsOnEmptyValue(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"invoice.txt": invoiceFixture})
+	sampler := &mockSampler{respond: answers(
+		`{"found": true, "value": "", "confidence": 0.9, "source": "Date: 2024-03-15"}`,
+		`{"found": true, "value": "2024-03-15", "confidence": 0.9, "source": "date:   2024-03-15"}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "extract_field", map[string]any{"filename": "invoice.txt", "query": "invoice date"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, "found is true but value is empty") {
+		t.Errorf("reprompt does not give the problem: %q", requests[1].SystemPrompt)
+	}
+	// The quote differs in case and spacing, so it is verified but not located
+	got := extractionResult(t, text)
+	if got.Value != "2024-03-15" || got.Source == nil || !got.Source.Verified || got.Source.Line != 0 {
+		t.Errorf("unexpected extraction: %+v, source %+v", got, got.Source)
+	}
+}
+
+func TestParseExtraction(t *testing.T) {
+	found, value, _, _, err := parseExtraction(`{"found": true, "value": 225.5, "confidence": 1, "source": "Total due: $225.50"}`)
+	if err != nil || !found || value != "225.5" {
+		t.Errorf("an unquoted number: found %t, value %q, err %v", found, value, err)
+	}
+	for answer, want := range map[string]string{
+		`{"value": "x"}`: "found is missing",
+		`{"found": true, "value": "x", "confidence": 1.5, "source": "x"}`: "confidence must be a number from 0 to 1",
+		`{"found": true, "value": "x", "confidence": 0.5}`:                "source is empty",
+		`not json`: "not valid JSON",
+	} {
+		if _, _, _, _, err := parseExtraction(answer); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseExtraction(%s) = %v, want %q", answer, err, want)
+		}
+	}
+}
+
+func TestExtractFieldNeedsQuery(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"invoice.txt": invoiceFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "extract_field", map[string]any{"filename": "invoice.txt", "query": "  "}); !strings.Contains(text, "query is empty") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("an empty query sent %d sampling requests", n)
+	}
+}
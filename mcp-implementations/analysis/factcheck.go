@@ -0,0 +1,247 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxFactSources bounds how many sources one fact_check call takes.
+const MaxFactSources = 10
+
+var factCheckTool = mcp.Tool{
+	Name:        "fact_check",
+	Description: "Check each factual claim in a text file against the given sources using LLM sampling, grounded only in those sources. Returns JSON with a supported, unsupported or contradicted verdict per claim and the sources behind it",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file whose claims to check (relative to files directory)",
+			},
+			"sources": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"filename": map[string]any{"type": "string", "description": "A file to use as a source (relative to files directory)"},
+						"text":     map[string]any{"type": "string", "description": "Source text given inline, instead of filename"},
+						"label":    map[string]any{"type": "string", "description": "Name for an inline source in the verdicts (default: source N)"},
+					},
+				},
+				"description": fmt.Sprintf("The sources to check against, each a filename or inline text (at least 1, at most %d)", MaxFactSources),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "sources"},
+	},
+}
+
+// factVerdicts are the verdicts a claim can get.
+var factVerdicts = []string{"supported", "unsupported", "contradicted"}
+
+// FactSource is one source passed to fact_check: a file or inline text.
+type FactSource struct {
+	Filename string `json:"filename,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// ClaimVerdict is the verdict on one claim of the document. Sources names
+// the sources behind a supported or contradicted verdict, and Quote is the
+// passage of them the model relied on. QuoteVerified is set by the server
+// when the quote appears in one of those sources.
+type ClaimVerdict struct {
+	Claim         string   `json:"claim"`
+	Verdict       string   `json:"verdict"`
+	Sources       []string `json:"sources,omitempty"`
+	Quote         string   `json:"quote,omitempty"`
+	QuoteVerified bool     `json:"quote_verified"`
+	Explanation   string   `json:"explanation"`
+}
+
+// FactCheckReport is the structured result of fact_check. The counts are
+// computed by the server.
+type FactCheckReport struct {
+	File         string         `json:"file"`
+	Model        string         `json:"model"`
+	Sources      []string       `json:"sources"`
+	Supported    int            `json:"supported"`
+	Unsupported  int            `json:"unsupported"`
+	Contradicted int            `json:"contradicted"`
+	Claims       []ClaimVerdict `json:"claims"`
+}
+
+func (s *Server) handleFactCheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	var args struct {
+		Sources []FactSource `json:"sources"`
+	}
+	if err := request.BindArguments(&args); err != nil {
+		return errorResult("Invalid sources: %v", err), nil
+	}
+	if len(args.Sources) == 0 {
+		return errorResult("sources is empty; pass at least one file or text to check against"), nil
+	}
+	if len(args.Sources) > MaxFactSources {
+		return errorResult("%d sources is more than the limit of %d", len(args.Sources), MaxFactSources), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	// Sources are given to the model by id, and named by file or label in
+	// the report
+	names := make([]string, len(args.Sources))
+	texts := make([]string, len(args.Sources))
+	total := len(text)
+	for i, source := range args.Sources {
+		switch {
+		case source.Filename == "" && source.Text == "":
+			return errorResult("source %d needs a filename or text", i+1), nil
+		case source.Filename != "" && source.Text != "":
+			return errorResult("source %d has both filename and text; give one", i+1), nil
+		case source.Filename != "":
+			texts[i], err = s.readTextFile(source.Filename)
+			if err != nil {
+				return errorResult("%v", err), nil
+			}
+			names[i] = source.Filename
+		default:
+			texts[i] = source.Text
+			names[i] = strings.TrimSpace(source.Label)
+			if names[i] == "" {
+				names[i] = fmt.Sprintf("source %d", i+1)
+			}
+		}
+		if slices.Contains(names[:i], names[i]) {
+			return errorResult("Source %s is listed twice", names[i]), nil
+		}
+		if strings.TrimSpace(texts[i]) == "" {
+			return errorResult("Source %s is empty", names[i]), nil
+		}
+		total += len(texts[i])
+	}
+	// The document and every source go in one request, so none is truncated
+	if total > s.cfg.ChunkSize {
+		return errorResult("%s and its sources together are %d bytes, more than the %d bytes that fit in one request",
+			filename, total, s.cfg.ChunkSize), nil
+	}
+
+	var b strings.Builder
+	for i := range texts {
+		fmt.Fprintf(&b, "<source id=\"S%d\" name=%q>\n%s\n</source>\n\n", i+1, names[i], texts[i])
+	}
+	fmt.Fprintf(&b, "<document name=%q>\n%s\n</document>", filename, text)
+	content := mcp.TextContent{Type: "text", Text: b.String()}
+	systemPrompt := "The user message contains numbered sources and a document. List every factual claim the document makes " +
+		"and check each one against the sources only, ignoring anything you know from elsewhere. " +
+		"A claim is supported when a source states it, contradicted when a source states otherwise, and unsupported when no source settles it, " +
+		"even if you believe it is true. For supported and contradicted claims, give the ids of the deciding sources and quote the deciding passage word for word. " +
+		`Respond with only a JSON object: {"claims": [{"claim": "...", "verdict": "supported" | "unsupported" | "contradicted", "sources": ["S1"], "quote": "...", "explanation": "<one sentence>"}]}.`
+
+	report := FactCheckReport{File: filename, Sources: names}
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 4000
+
+	logf(ctx, "📤 Sending sampling request to fact-check %s against %d sources", filename, len(names))
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid fact check", func(text string) (err error) {
+		report.Claims, err = parseClaimVerdicts(text, names)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	report.Model = result.Model
+
+	for i := range report.Claims {
+		claim := &report.Claims[i]
+		switch claim.Verdict {
+		case "supported":
+			report.Supported++
+		case "unsupported":
+			report.Unsupported++
+		case "contradicted":
+			report.Contradicted++
+		}
+		// A quote only counts if it is really in a source the verdict cites
+		for _, name := range claim.Sources {
+			if claim.Quote == "" {
+				break
+			}
+			verified, _ := verifyCitations(texts[slices.Index(names, name)], []string{claim.Quote})
+			if len(verified) > 0 {
+				claim.QuoteVerified = true
+				break
+			}
+		}
+	}
+
+	logf(ctx, "✅ Fact check of %s: %d supported, %d unsupported, %d contradicted",
+		filename, report.Supported, report.Unsupported, report.Contradicted)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding fact check: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseClaimVerdicts decodes the model's verdicts, normalizing them to
+// lowercase and replacing source ids with the names in names. Supported
+// and contradicted claims must cite at least one source, and every id must
+// be one of the sources given.
+func parseClaimVerdicts(text string, names []string) ([]ClaimVerdict, error) {
+	var answer struct {
+		Claims []ClaimVerdict `json:"claims"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if len(answer.Claims) == 0 {
+		return nil, fmt.Errorf("no claims listed")
+	}
+
+	for i := range answer.Claims {
+		c := &answer.Claims[i]
+		c.Claim, c.Quote, c.Explanation = strings.TrimSpace(c.Claim), strings.TrimSpace(c.Quote), strings.TrimSpace(c.Explanation)
+		c.QuoteVerified = false
+		if c.Claim == "" {
+			return nil, fmt.Errorf("claim %d has no text", i+1)
+		}
+		c.Verdict = strings.ToLower(strings.TrimSpace(c.Verdict))
+		if !slices.Contains(factVerdicts, c.Verdict) {
+			return nil, fmt.Errorf("claim %d has verdict %q, expected one of %s", i+1, c.Verdict, strings.Join(factVerdicts, ", "))
+		}
+
+		var cited []string
+		for _, id := range c.Sources {
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(id), "S"))
+			if err != nil || n < 1 || n > len(names) {
+				return nil, fmt.Errorf("claim %d cites %q, which is not one of the sources S1 to S%d", i+1, id, len(names))
+			}
+			if !slices.Contains(cited, names[n-1]) {
+				cited = append(cited, names[n-1])
+			}
+		}
+		if c.Verdict != "unsupported" && len(cited) == 0 {
+			return nil, fmt.Errorf("claim %d is %s but cites no source", i+1, c.Verdict)
+		}
+		c.Sources = cited
+	}
+	return answer.Claims, nil
+}
@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// bridgeFiles hold a document with one claim history.txt supports and one
+// the survey source contradicts.
+var bridgeFiles = map[string]string{
+	"bridge.md":   "The Harbour Bridge opened in 1932. It is 500 metres long.",
+	"history.txt": "The Harbour Bridge opened to traffic in March 1932.",
+}
+
+// bridgeSources are history.txt and an inline survey.
+var bridgeSources = []map[string]any{
+	{"filename": "history.txt"},
+	{"text": "Survey: the bridge is 1,149 metres long.", "label": "survey"},
+}
+
+// factCheckOf decodes a fact_check result.
+func factCheckOf(t *testing.T, text string) FactCheckReport {
+	t.Helper()
+	var report FactCheckReport
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	return report
+}
+
+func TestFactCheckVerdicts(t *testing.T) {
+	s := newTestServer(t, Config{}, bridgeFiles)
+	// The verdict case differs and one source is cited twice, both tolerated
+	sampler := &mockSampler{respond: answers(`{"claims": [
+		{"claim": "The bridge opened in 1932.", "verdict": "supported", "sources": ["S1"], "quote": "opened to traffic in March 1932", "explanation": "The history says so."},
+		{"claim": "The bridge is 500 metres long.", "verdict": "Contradicted", "sources": ["S2", "2"], "quote": "1,149 metres long", "explanation": "The survey gives 1,149 metres."}
+	]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "fact_check", map[string]any{"filename": "bridge.md", "sources": bridgeSources})
+
+	report := factCheckOf(t, text)
+	if report.Supported != 1 || report.Unsupported != 0 || report.Contradicted != 1 {
+		t.Errorf("counts %d/%d/%d, want 1 supported and 1 contradicted", report.Supported, report.Unsupported, report.Contradicted)
+	}
+	if !slices.Equal(report.Sources, []string{"history.txt", "survey"}) || report.Model != "mock-model" {
+		t.Errorf("report names sources %v and model %q", report.Sources, report.Model)
+	}
+	if len(report.Claims) != 2 {
+		t.Fatalf("%d claims, want 2", len(report.Claims))
+	}
+	supported, contradicted := report.Claims[0], report.Claims[1]
+	if supported.Verdict != "supported" || !slices.Equal(supported.Sources, []string{"history.txt"}) || !supported.QuoteVerified {
+		t.Errorf("supported claim is %+v", supported)
+	}
+	if contradicted.Verdict != "contradicted" || !slices.Equal(contradicted.Sources, []string{"survey"}) || !contradicted.QuoteVerified {
+		t.Errorf("contradicted claim is %+v", contradicted)
+	}
+
+	request := sampler.Requests()[0]
+	content := messageText(request)
+	for _, want := range []string{
+		"<source id=\"S1\" name=\"history.txt\">\nThe Harbour Bridge opened to traffic in March 1932.\n</source>",
+		"<source id=\"S2\" name=\"survey\">\nSurvey: the bridge is 1,149 metres long.\n</source>",
+		"<document name=\"bridge.md\">\nThe Harbour Bridge opened in 1932. It is 500 metres long.\n</document>",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("request content is missing %q:\n%s", want, content)
+		}
+	}
+	if request.Temperature != 0 || !strings.Contains(request.SystemPrompt, "against the sources only") {
+		t.Errorf("request is not grounded in the sources: temperature %g, prompt %q", request.Temperature, request.SystemPrompt)
+	}
+}
+
+func TestFactCheckQuoteMustBeInCitedSource(t *testing.T) {
+	s := newTestServer(t, Config{}, bridgeFiles)
+	// The quote is from the survey but the verdict cites history.txt
+	c := connect(t, s, &mockSampler{respond: answers(`{"claims": [
+		{"claim": "The bridge is 500 metres long.", "verdict": "contradicted", "sources": ["S1"], "quote": "1,149 metres long", "explanation": "Wrong length."},
+		{"claim": "It was painted grey.", "verdict": "unsupported", "explanation": "No source covers it."}
+	]}`)})
+
+	_, text := mustSucceed(t, c, "fact_check", map[string]any{"filename": "bridge.md", "sources": bridgeSources})
+
+	report := factCheckOf(t, text)
+	if report.Claims[0].QuoteVerified {
+		t.Errorf("quote from an uncited source is verified: %+v", report.Claims[0])
+	}
+	if report.Claims[1].Sources != nil || report.Unsupported != 1 {
+		t.Errorf("unsupported claim is %+v with %d unsupported", report.Claims[1], report.Unsupported)
+	}
+}
+
+func TestFactCheckRetriesUnknownSource(t *testing.T) {
+	s := newTestServer(t, Config{}, bridgeFiles)
+	sampler := &mockSampler{respond: answers(
+		`{"claims": [{"claim": "The bridge opened in 1932.", "verdict": "supported", "sources": ["S3"], "explanation": "x"}]}`,
+		`{"claims": [{"claim": "The bridge opened in 1932.", "verdict": "supported", "sources": ["S1"], "explanation": "x"}]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "fact_check", map[string]any{"filename": "bridge.md", "sources": bridgeSources})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d sampling requests, want a retry", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, `cites "S3", which is not one of the sources S1 to S2`) {
+		t.Errorf("retry does not say what was wrong: %s", requests[1].SystemPrompt)
+	}
+	if report := factCheckOf(t, text); !slices.Equal(report.Claims[0].Sources, []string{"history.txt"}) {
+		t.Errorf("claim cites %v after the retry", report.Claims[0].Sources)
+	}
+}
+
+func TestFactCheckRejectsInvalidAnswers(t *testing.T) {
+	tests := map[string]struct {
+		answer, want string
+	}{
+		"unknown verdict":   {`{"claims": [{"claim": "A.", "verdict": "plausible"}]}`, `verdict "plausible", expected one of supported, unsupported, contradicted`},
+		"uncited supported": {`{"claims": [{"claim": "A.", "verdict": "supported"}]}`, "claim 1 is supported but cites no source"},
+		"no claims":         {`{"claims": []}`, "no claims listed"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, Config{}, bridgeFiles)
+			sampler := &mockSampler{respond: answers(tt.answer)}
+			c := connect(t, s, sampler)
+
+			text := mustFail(t, c, "fact_check", map[string]any{"filename": "bridge.md", "sources": bridgeSources})
+			if !strings.Contains(text, "did not return a valid fact check after a retry") || !strings.Contains(text, tt.want) {
+				t.Errorf("error %q does not mention %q", text, tt.want)
+			}
+			if n := len(sampler.Requests()); n != 2 {
+				t.Errorf("%d sampling requests, want one retry", n)
+			}
+		})
+	}
+}
+
+func TestFactCheckValidatesSources(t *testing.T) {
+	files := map[string]string{"bridge.md": bridgeFiles["bridge.md"], "history.txt": bridgeFiles["history.txt"], "blank.txt": "  \n"}
+	s := newTestServer(t, Config{ChunkSize: 300}, files)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	tests := []struct {
+		sources []map[string]any
+		want    string
+	}{
+		{nil, "sources is empty"},
+		{[]map[string]any{{"label": "nothing"}}, "source 1 needs a filename or text"},
+		{[]map[string]any{{"filename": "history.txt", "text": "x"}}, "source 1 has both filename and text"},
+		{[]map[string]any{{"filename": "history.txt"}, {"filename": "history.txt"}}, "Source history.txt is listed twice"},
+		{[]map[string]any{{"text": "a"}, {"text": "b", "label": "source 1"}}, "Source source 1 is listed twice"},
+		{[]map[string]any{{"filename": "blank.txt"}}, "Source blank.txt is empty"},
+		{[]map[string]any{{"text": strings.Repeat("x", 300)}}, "more than the 300 bytes that fit in one request"},
+		{slices.Repeat([]map[string]any{{"text": "x"}}, MaxFactSources+1), "11 sources is more than the limit of 10"},
+	}
+	for _, tt := range tests {
+		if text := mustFail(t, c, "fact_check", map[string]any{"filename": "bridge.md", "sources": tt.sources}); !strings.Contains(text, tt.want) {
+			t.Errorf("%v: error %q does not mention %q", tt.sources, text, tt.want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid sources sent %d sampling requests", n)
+	}
+}
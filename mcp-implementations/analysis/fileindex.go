@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultIndexTTL is how long the file index is trusted when Config leaves
+// IndexTTL unset.
+const DefaultIndexTTL = 30 * time.Second
+
+// indexedFile is one file in the files directory.
+type indexedFile struct {
+	Name     string
+	Size     int64
+	MIMEType string
+}
+
+// fileIndex caches the listing of the files directory. It is rebuilt when
+// older than its TTL or when the directory's modification time changes,
+// which happens whenever a file is added, removed or renamed. The TTL
+// catches edits to existing files, which leave the directory untouched.
+type fileIndex struct {
+	dir string
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	files    []indexedFile
+	builtAt  time.Time
+	dirMTime time.Time
+}
+
+// list returns the indexed files, rebuilding the index first if it is stale.
+func (x *fileIndex) list() ([]indexedFile, error) {
+	info, err := os.Stat(x.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.RLock()
+	fresh := !x.builtAt.IsZero() && time.Since(x.builtAt) < x.ttl && info.ModTime().Equal(x.dirMTime)
+	files := x.files
+	x.mu.RUnlock()
+	if fresh {
+		return files, nil
+	}
+
+	return x.rebuild(info.ModTime())
+}
+
+// rebuild reads the directory and replaces the index.
+func (x *fileIndex) rebuild(dirMTime time.Time) ([]indexedFile, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	entries, err := os.ReadDir(x.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []indexedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, indexedFile{Name: entry.Name(), Size: info.Size(), MIMEType: mimeTypeFor(entry.Name())})
+	}
+
+	x.files, x.builtAt, x.dirMTime = files, time.Now(), dirMTime
+	return files, nil
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListFilesIndexUpdatesWhenFilesAreAddedAndRemoved(t *testing.T) {
+	s := newTestServer(t, Config{IndexTTL: time.Hour}, map[string]string{"a.txt": "a"})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "list_files", map[string]any{})
+	if !strings.Contains(text, "a.txt") {
+		t.Fatalf("listing is missing a.txt:\n%s", text)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.cfg.FilesDir, "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, text = mustSucceed(t, c, "list_files", map[string]any{})
+	if !strings.Contains(text, "b.txt") {
+		t.Errorf("listing did not pick up an added file:\n%s", text)
+	}
+
+	if err := os.Remove(filepath.Join(s.cfg.FilesDir, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	_, text = mustSucceed(t, c, "list_files", map[string]any{})
+	if strings.Contains(text, "a.txt") {
+		t.Errorf("listing still shows a removed file:\n%s", text)
+	}
+}
+
+func TestFileIndexServesCachedListingWithinTTL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := &fileIndex{dir: dir, ttl: time.Hour}
+
+	files, err := index.list()
+	if err != nil || len(files) != 1 || files[0].Size != 5 {
+		t.Fatalf("list() = %+v, %v", files, err)
+	}
+
+	// Editing a file leaves the directory untouched, so the cached entry
+	// stands until the TTL runs out
+	if err := os.WriteFile(path, []byte("much longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := index.list(); files[0].Size != 5 {
+		t.Errorf("index was rebuilt within its TTL: size %d", files[0].Size)
+	}
+
+	index.ttl = 0
+	if files, _ := index.list(); files[0].Size != 11 {
+		t.Errorf("expired index was not rebuilt: size %d", files[0].Size)
+	}
+}
+
+func TestFileIndexConcurrentUse(t *testing.T) {
+	dir := t.TempDir()
+	index := &fileIndex{dir: dir, ttl: time.Millisecond}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 20 {
+			os.WriteFile(filepath.Join(dir, strings.Repeat("f", i+1)), nil, 0644)
+		}
+	}()
+	for range 50 {
+		if _, err := index.list(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	index.ttl = 0
+	if files, _ := index.list(); len(files) != 20 {
+		t.Errorf("got %d files, want 20", len(files))
+	}
+}
@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resolveFile maps a filename from a tool call to a local path holding
+// the file, from the server's FileSource. The returned error message is
+// safe to show to the caller.
+func (s *Server) resolveFile(filename string) (string, error) {
+	return s.cfg.FileSource.Resolve(filename)
+}
+
+// resolveInRoot joins filename to root, refusing paths that escape it.
+func resolveInRoot(root, filename string) (string, error) {
+	// Construct file path
+	filePath := filepath.Join(root, filename)
+
+	// Security check - ensure file is within the files directory
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", fmt.Errorf("Error resolving file path: %v", err)
+	}
+
+	absDirPath, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("Error resolving directory path: %v", err)
+	}
+
+	// Compare whole path elements, so "files2" is not inside "files"
+	if absFilePath != absDirPath && !strings.HasPrefix(absFilePath, absDirPath+string(filepath.Separator)) {
+		return "", fmt.Errorf("Access denied: File must be within the files directory")
+	}
+
+	return filePath, nil
+}
+
+// readTextFile resolves, reads and normalizes a file for tools that only
+// work on text. Error messages are safe to show to the caller.
+func (s *Server) readTextFile(filename string) (string, error) {
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	// Source files count as text even when their MIME type is unknown
+	_, isSource := commentSyntaxByExt[strings.ToLower(filepath.Ext(filename))]
+	if !isTextFile(filename, mimeTypeFor(filename)) && !isSource {
+		return "", fmt.Errorf("%s is not a text file (%s)", filename, mimeTypeFor(filename))
+	}
+
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	if len(fileContent) == 0 {
+		return "", fmt.Errorf("%s is empty; there is nothing to analyze", filename)
+	}
+
+	text, _ := normalizeText(fileContent)
+	return text, nil
+}
+
+// mimeTypeFor guesses a MIME type from the file extension.
+func mimeTypeFor(filename string) string {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return mimeType
+}
+
+// DefaultMaxListEntries is the most files list_files returns in one call
+// when Config leaves MaxListEntries unset.
+const DefaultMaxListEntries = 500
+
+var listFilesTool = mcp.Tool{
+	Name:        "list_files",
+	Description: "List the files available for analysis in the files directory, a page at a time in large directories",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Most files to list (default and maximum: the server's -max-list-entries)",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Number of files to skip, to fetch the next page (default 0)",
+			},
+		},
+	},
+}
+
+func (s *Server) handleListFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	limit := request.GetInt("limit", s.cfg.MaxListEntries)
+	offset := request.GetInt("offset", 0)
+	if limit < 1 || offset < 0 {
+		return errorResult("limit must be at least 1 and offset at least 0"), nil
+	}
+	limit = min(limit, s.cfg.MaxListEntries)
+
+	files, err := s.cfg.FileSource.List()
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	var fileList []string
+	for _, f := range files {
+		switch {
+		case f.Root == "":
+			fileList = append(fileList, fmt.Sprintf("- %s (%d bytes, %s)", f.Name, f.Size, f.MIMEType))
+		case f.ShadowedBy != "":
+			// Shadowed by an earlier root; only its qualified name reaches it
+			fileList = append(fileList, fmt.Sprintf("- %s (%d bytes, %s; shadowed by the copy in %s)",
+				filepath.Join(f.Root, f.Name), f.Size, f.MIMEType, f.ShadowedBy))
+		default:
+			fileList = append(fileList, fmt.Sprintf("- %s (%d bytes, %s, in %s)", f.Name, f.Size, f.MIMEType, f.Root))
+		}
+	}
+
+	if len(fileList) == 0 {
+		return textResult(fmt.Sprintf("No files found in %s", s.cfg.FileSource)), nil
+	}
+
+	total := len(fileList)
+	if offset >= total {
+		return errorResult("offset %d is past the end of the listing (%d files)", offset, total), nil
+	}
+	end := min(offset+limit, total)
+	page := fmt.Sprintf("Available files in %s:\n\n%s", s.cfg.FileSource, strings.Join(fileList[offset:end], "\n"))
+	if offset > 0 || end < total {
+		page += fmt.Sprintf("\n\nShowing files %d-%d of %d.", offset+1, end, total)
+		if end < total {
+			page += fmt.Sprintf(" The listing is truncated; call again with offset %d for more.", end)
+		}
+	}
+	return textResult(page), nil
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedFiles returns n small files named file00.txt onwards.
+func numberedFiles(n int) map[string]string {
+	files := map[string]string{}
+	for i := range n {
+		files[fmt.Sprintf("file%02d.txt", i)] = "x"
+	}
+	return files
+}
+
+func TestListFilesTruncatesAtMaxListEntries(t *testing.T) {
+	s := newTestServer(t, Config{MaxListEntries: 3}, numberedFiles(5))
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "list_files", map[string]any{})
+
+	if n := strings.Count(text, "\n- "); n != 3 {
+		t.Errorf("listed %d files, want 3:\n%s", n, text)
+	}
+	if !strings.Contains(text, "Showing files 1-3 of 5. The listing is truncated; call again with offset 3 for more.") {
+		t.Errorf("result does not note the truncation:\n%s", text)
+	}
+	// A larger limit is still capped by the server
+	if _, text := mustSucceed(t, c, "list_files", map[string]any{"limit": 50}); strings.Count(text, "\n- ") != 3 {
+		t.Errorf("limit above the cap was not capped:\n%s", text)
+	}
+}
+
+func TestListFilesPaginates(t *testing.T) {
+	s := newTestServer(t, Config{}, numberedFiles(5))
+	c := connect(t, s, &mockSampler{})
+
+	var seen []string
+	for offset := 0; offset < 5; offset += 2 {
+		_, text := mustSucceed(t, c, "list_files", map[string]any{"limit": 2, "offset": offset})
+		for _, line := range strings.Split(text, "\n") {
+			if name, ok := strings.CutPrefix(line, "- "); ok {
+				seen = append(seen, strings.Fields(name)[0])
+			}
+		}
+	}
+	if got := strings.Join(seen, ","); got != "file00.txt,file01.txt,file02.txt,file03.txt,file04.txt" {
+		t.Errorf("pages listed %s", got)
+	}
+
+	_, last := mustSucceed(t, c, "list_files", map[string]any{"limit": 2, "offset": 4})
+	if !strings.Contains(last, "Showing files 5-5 of 5.") || strings.Contains(last, "truncated") {
+		t.Errorf("last page is not marked as the end:\n%s", last)
+	}
+}
+
+func TestListFilesUntruncatedHasNoNote(t *testing.T) {
+	s := newTestServer(t, Config{}, numberedFiles(2))
+	c := connect(t, s, &mockSampler{})
+
+	if _, text := mustSucceed(t, c, "list_files", map[string]any{}); strings.Contains(text, "Showing files") {
+		t.Errorf("a complete listing carries a paging note:\n%s", text)
+	}
+}
+
+func TestListFilesRejectsBadPaging(t *testing.T) {
+	s := newTestServer(t, Config{}, numberedFiles(2))
+	c := connect(t, s, &mockSampler{})
+
+	for _, args := range []map[string]any{{"limit": 0}, {"offset": -1}} {
+		if text := mustFail(t, c, "list_files", args); !strings.Contains(text, "limit must be at least 1 and offset at least 0") {
+			t.Errorf("%v: unexpected error: %s", args, text)
+		}
+	}
+	if text := mustFail(t, c, "list_files", map[string]any{"offset": 2}); !strings.Contains(text, "offset 2 is past the end of the listing (2 files)") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
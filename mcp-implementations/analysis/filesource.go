@@ -0,0 +1,167 @@
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSources are the accepted values of the -file-source flag.
+var FileSources = []string{"local", "s3"}
+
+// SourceFile is one file in a FileSource's listing.
+type SourceFile struct {
+	Name     string
+	Size     int64
+	MIMEType string
+	// Root is the directory holding the file, set by sources with several.
+	// ShadowedBy names the earlier root whose file of the same name a
+	// plain filename resolves to instead.
+	Root       string
+	ShadowedBy string
+}
+
+// FileSource is where the files the server analyzes come from. Filenames
+// are relative to the source, which refuses any that reach outside it.
+// Error messages are safe to show to the caller.
+type FileSource interface {
+	// Resolve returns a local path holding the named file's content,
+	// fetching it first if the source is remote.
+	Resolve(name string) (string, error)
+	// List returns the files at the top level of the source.
+	List() ([]SourceFile, error)
+	// Glob returns the names of the files matching a pattern in the
+	// syntax of filepath.Match, without directories or hidden files.
+	Glob(pattern string) ([]string, error)
+	// String describes the source for listings and logs.
+	String() string
+}
+
+// LocalSource serves files from one or more local directories, searched
+// in order.
+type LocalSource struct {
+	roots   []string
+	indexes []*fileIndex
+}
+
+// NewLocalSource creates a source over filesDir, a directory or a search
+// path of directories separated by the OS list separator. Missing
+// directories are created. Each directory's listing is cached for
+// indexTTL.
+func NewLocalSource(filesDir string, indexTTL time.Duration) *LocalSource {
+	source := &LocalSource{}
+	for _, root := range filepath.SplitList(filesDir) {
+		if root == "" {
+			continue
+		}
+		source.roots = append(source.roots, root)
+		source.indexes = append(source.indexes, &fileIndex{dir: root, ttl: indexTTL})
+
+		// Ensure files directory exists
+		if err := os.MkdirAll(root, 0755); err != nil {
+			log.Printf("Warning: Could not create files directory: %v", err)
+		}
+	}
+	return source
+}
+
+// String implements FileSource. It is the search path.
+func (l *LocalSource) String() string {
+	return strings.Join(l.roots, string(filepath.ListSeparator))
+}
+
+// Resolve implements FileSource. Roots are searched in order, so a name
+// present in two roots resolves to the first; with several roots,
+// prefixing the name with a root as listed by list_files looks only in
+// that root.
+func (l *LocalSource) Resolve(filename string) (string, error) {
+	roots, name := l.roots, filename
+	if root, rest, ok := l.qualifiedRoot(filename); ok {
+		roots, name = []string{root}, rest
+	}
+
+	for _, root := range roots {
+		filePath, err := resolveInRoot(root, name)
+		if err != nil {
+			return "", err
+		}
+		// Check if file exists
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			return filePath, nil
+		}
+	}
+	return "", fmt.Errorf("File not found: %s", filename)
+}
+
+// qualifiedRoot splits a filename written as "<root>/<name>" when the
+// source has several roots.
+func (l *LocalSource) qualifiedRoot(filename string) (root, name string, ok bool) {
+	if len(l.roots) < 2 {
+		return "", "", false
+	}
+	cleaned := filepath.Clean(filename)
+	for _, root := range l.roots {
+		prefix := filepath.Clean(root) + string(filepath.Separator)
+		if strings.HasPrefix(cleaned, prefix) {
+			return root, strings.TrimPrefix(cleaned, prefix), true
+		}
+	}
+	return "", "", false
+}
+
+// List implements FileSource. With several roots every file is listed
+// with its root, and those hidden by an earlier root's file of the same
+// name are marked.
+func (l *LocalSource) List() ([]SourceFile, error) {
+	var files []SourceFile
+	firstRoot := map[string]string{}
+	for i, root := range l.roots {
+		indexed, err := l.indexes[i].list()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading files directory %s: %v", root, err)
+		}
+		for _, f := range indexed {
+			file := SourceFile{Name: f.Name, Size: f.Size, MIMEType: f.MIMEType}
+			if len(l.roots) > 1 {
+				file.Root, file.ShadowedBy = root, firstRoot[f.Name]
+				if file.ShadowedBy == "" {
+					firstRoot[f.Name] = root
+				}
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Glob implements FileSource. A name found in several roots is listed
+// once, as Resolve would find it in the first.
+func (l *LocalSource) Glob(pattern string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, root := range l.roots {
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		for _, match := range matches {
+			name, err := filepath.Rel(root, match)
+			if err != nil || seen[name] || strings.HasPrefix(filepath.Base(name), ".") {
+				continue
+			}
+			// The same check every other filename goes through
+			if _, err := resolveInRoot(root, name); err != nil {
+				continue
+			}
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// searchPath creates two roots holding shared.txt with different content
+// and one file of their own each, and returns them.
+func searchPath(t *testing.T) (first, second string) {
+	t.Helper()
+	first, second = t.TempDir(), t.TempDir()
+	for dir, files := range map[string]map[string]string{
+		first:  {"shared.txt": "from the first root", "only-first.txt": "one"},
+		second: {"shared.txt": "from the second root", "only-second.txt": "two"},
+	} {
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return first, second
+}
+
+func TestListFilesAggregatesSearchPath(t *testing.T) {
+	first, second := searchPath(t)
+	s := newTestServer(t, Config{FilesDir: first + string(filepath.ListSeparator) + second}, nil)
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "list_files", map[string]any{})
+
+	for _, want := range []string{
+		"- only-first.txt (3 bytes, text/plain; charset=utf-8, in " + first + ")",
+		"- only-second.txt (3 bytes, text/plain; charset=utf-8, in " + second + ")",
+		"- shared.txt (19 bytes, text/plain; charset=utf-8, in " + first + ")",
+		"- " + filepath.Join(second, "shared.txt") + " (20 bytes, text/plain; charset=utf-8; shadowed by the copy in " + first + ")",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("listing is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestAnalyzeFileResolvesAcrossSearchPath(t *testing.T) {
+	first, second := searchPath(t)
+	s := newTestServer(t, Config{FilesDir: first + string(filepath.ListSeparator) + second}, nil)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tc := range []struct{ filename, want string }{
+		{"shared.txt", "from the first root"},
+		{filepath.Join(second, "shared.txt"), "from the second root"},
+		{"only-second.txt", "two"},
+	} {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": tc.filename, "use_cache": false})
+		requests := sampler.Requests()
+		if got := messageText(requests[len(requests)-1]); !strings.Contains(got, tc.want) {
+			t.Errorf("%s sampled %q, want the content %q", tc.filename, got, tc.want)
+		}
+	}
+}
+
+func TestSearchPathChecksEachRoot(t *testing.T) {
+	first, second := searchPath(t)
+	s := newTestServer(t, Config{FilesDir: first + string(filepath.ListSeparator) + second}, nil)
+	c := connect(t, s, &mockSampler{})
+
+	escape := filepath.Join("..", filepath.Base(second), "only-second.txt")
+	if text := mustFail(t, c, "analyze_file", map[string]any{"filename": escape}); !strings.Contains(text, "Access denied") {
+		t.Errorf("a path leaving the roots was not refused: %s", text)
+	}
+	if text := mustFail(t, c, "analyze_file", map[string]any{"filename": "missing.txt"}); !strings.Contains(text, "File not found: missing.txt") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
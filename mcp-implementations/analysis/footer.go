@@ -0,0 +1,33 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// withResultFooter appends Config.ResultFooter to successful results of a
+// sampling tool. Text output gets it after a rule; a JSON payload is left
+// intact and the footer follows as a separate content block, so it still
+// parses.
+func (s *Server) withResultFooter(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+
+		last := len(result.Content) - 1
+		if last >= 0 {
+			if textContent, ok := result.Content[last].(mcp.TextContent); ok && !json.Valid([]byte(textContent.Text)) {
+				textContent.Text += "\n\n---\n" + s.cfg.ResultFooter
+				result.Content[last] = textContent
+				return result, nil
+			}
+		}
+		result.Content = append(result.Content, mcp.NewTextContent(s.cfg.ResultFooter))
+		return result, nil
+	}
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const testFooter = "Generated by AI; verify before use."
+
+func TestResultFooterAppendedToTextOutput(t *testing.T) {
+	s := newTestServer(t, Config{ResultFooter: testFooter}, map[string]string{"notes.txt": "Hello."})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if !strings.HasSuffix(text, "\n\n---\n"+testFooter) {
+		t.Errorf("text output does not end with the footer:\n%s", text)
+	}
+}
+
+func TestResultFooterKeepsJSONParseable(t *testing.T) {
+	s := newTestServer(t, Config{ResultFooter: testFooter}, map[string]string{"doc.txt": "Photosynthesis turns light into sugar."})
+	c := connect(t, s, &mockSampler{respond: answers(quizAnswer(1, 1))})
+
+	result, _ := mustSucceed(t, c, "generate_quiz", map[string]any{"filename": "doc.txt", "num_questions": 1})
+	if len(result.Content) != 2 {
+		t.Fatalf("got %d content blocks, want the JSON and the footer", len(result.Content))
+	}
+	payload, _ := result.Content[0].(mcp.TextContent)
+	var quiz Quiz
+	if err := json.Unmarshal([]byte(payload.Text), &quiz); err != nil {
+		t.Errorf("JSON payload no longer parses: %v\n%s", err, payload.Text)
+	}
+	if footer, _ := result.Content[1].(mcp.TextContent); footer.Text != testFooter {
+		t.Errorf("second block is %q, want the footer", footer.Text)
+	}
+}
+
+func TestResultFooterSkipsNonSamplingTools(t *testing.T) {
+	s := newTestServer(t, Config{ResultFooter: testFooter}, map[string]string{"notes.txt": "Hello."})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "list_files", map[string]any{})
+	if strings.Contains(text, testFooter) {
+		t.Errorf("list_files output carries the footer:\n%s", text)
+	}
+}
+
+func TestResultFooterOmittedFromErrors(t *testing.T) {
+	s := newTestServer(t, Config{ResultFooter: testFooter}, nil)
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "missing.txt"})
+	if strings.Contains(text, testFooter) {
+		t.Errorf("error result carries the footer:\n%s", text)
+	}
+}
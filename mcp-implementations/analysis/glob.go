@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MaxGlobFiles bounds how many files one glob pattern can expand to, so a
+// broad pattern cannot start an unexpectedly large batch.
+const MaxGlobFiles = 100
+
+// expandGlob returns the files in the files directory matching pattern, in
+// the glob syntax of filepath.Match, e.g. "*.log" or "reports/2024-*.md",
+// sorted by name. Directories and hidden files are skipped. Only the first
+// MaxGlobFiles matches are returned; omitted counts the rest.
+func (s *Server) expandGlob(pattern string) (names []string, omitted int, err error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, 0, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	if filepath.IsAbs(pattern) || slices.Contains(strings.Split(filepath.ToSlash(pattern), "/"), "..") {
+		return nil, 0, fmt.Errorf("Access denied: pattern %q must stay within the files directory", pattern)
+	}
+
+	names, err = s.cfg.FileSource.Glob(pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Sorted, so the files kept under the cap don't depend on the source
+	slices.Sort(names)
+	if len(names) > MaxGlobFiles {
+		return names[:MaxGlobFiles], len(names) - MaxGlobFiles, nil
+	}
+	return names, 0, nil
+}
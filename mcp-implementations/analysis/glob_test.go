@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var globFiles = map[string]string{
+	"app.log":         "app started",
+	"worker.log":      "worker started",
+	"notes.txt":       "not a log",
+	".secret.log":     "hidden",
+	"archive.log/old": "a directory named like a log",
+	"logs/db.log":     "db started",
+}
+
+func TestAnalyzeBatchExpandsPattern(t *testing.T) {
+	s := newTestServer(t, Config{}, globFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"pattern": "*.log"})
+
+	for _, want := range []string{"Files: 2 (0 failed", "--- [1/2] app.log (ok) ---", "--- [2/2] worker.log (ok) ---"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want one per matching file", n)
+	}
+	for _, unwanted := range []string{"notes.txt", ".secret.log", "archive.log", "db.log"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("%s does not match *.log as a visible file but was analyzed:\n%s", unwanted, text)
+		}
+	}
+}
+
+func TestAnalyzeBatchCombinesFilenamesAndPattern(t *testing.T) {
+	s := newTestServer(t, Config{}, globFiles)
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": []string{"notes.txt"}, "pattern": "logs/*.log"})
+
+	if !strings.Contains(text, "--- [1/2] notes.txt (ok) ---") || !strings.Contains(text, "--- [2/2] logs/db.log (ok) ---") {
+		t.Errorf("filenames should come first, then the pattern's matches:\n%s", text)
+	}
+}
+
+func TestAnalyzeBatchCapsPatternMatches(t *testing.T) {
+	files := map[string]string{}
+	for i := range MaxGlobFiles + 1 {
+		files[fmt.Sprintf("run%03d.log", i)] = fmt.Sprintf("run %d ok", i)
+	}
+	s := newTestServer(t, Config{}, files)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"pattern": "*.log", "max_parallel": 1})
+	wantNote := fmt.Sprintf(`Pattern "*.log" matched %d files; only the first %d in name order were analyzed, 1 left out.`, MaxGlobFiles+1, MaxGlobFiles)
+	if !strings.Contains(text, wantNote) {
+		t.Errorf("result does not say how many files were left out:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != MaxGlobFiles {
+		t.Errorf("got %d sampling requests, want the first %d matches analyzed", n, MaxGlobFiles)
+	}
+	last := fmt.Sprintf("run%03d.log", MaxGlobFiles-1)
+	if !strings.Contains(text, fmt.Sprintf("Files: %d (0 failed", MaxGlobFiles)) || !strings.Contains(text, last) {
+		t.Errorf("the batch is not the first %d matches:\n%.500s", MaxGlobFiles, text)
+	}
+	if strings.Contains(text, fmt.Sprintf("run%03d.log (", MaxGlobFiles)) {
+		t.Errorf("the last match in name order was analyzed past the cap")
+	}
+
+	// Exactly the limit leaves nothing out
+	if names, omitted, err := s.expandGlob("run0*.log"); err != nil || len(names) != MaxGlobFiles || omitted != 0 {
+		t.Errorf("expandGlob(run0*.log) = %d files, %d omitted, %v; want %d, 0", len(names), omitted, err, MaxGlobFiles)
+	}
+}
+
+func TestAnalyzeBatchRejectsBadPatterns(t *testing.T) {
+	s := newTestServer(t, Config{}, globFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for pattern, want := range map[string]string{
+		"../*":         `Access denied: pattern "../*" must stay within the files directory`,
+		"logs/../../*": "Access denied",
+		"/etc/*":       "Access denied",
+		"[":            `invalid pattern "["`,
+		"*.csv":        `No files match pattern "*.csv"`,
+	} {
+		if text := mustFail(t, c, "analyze_batch", map[string]any{"pattern": pattern}); !strings.Contains(text, want) {
+			t.Errorf("pattern %q: unexpected error: %s", pattern, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("rejected patterns sent %d sampling requests", n)
+	}
+}
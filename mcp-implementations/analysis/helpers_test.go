@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mockSampler stands in for the client's LLM. It records every sampling
+// request and answers with respond, or with mockAnswer when respond is nil.
+type mockSampler struct {
+	mu       sync.Mutex
+	requests []mcp.CreateMessageRequest
+	respond  func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+}
+
+const mockAnswer = "MOCK RESPONSE: the sampling round trip works."
+
+func (m *mockSampler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	m.mu.Lock()
+	m.requests = append(m.requests, request)
+	respond := m.respond
+	m.mu.Unlock()
+
+	if respond != nil {
+		return respond(request)
+	}
+	return textAnswer(mockAnswer), nil
+}
+
+// Requests returns the sampling requests seen so far.
+func (m *mockSampler) Requests() []mcp.CreateMessageRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mcp.CreateMessageRequest(nil), m.requests...)
+}
+
+// answers returns a respond function giving each text in turn, repeating
+// the last one once they run out.
+func answers(texts ...string) func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	var mu sync.Mutex
+	next := 0
+	return func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		text := texts[min(next, len(texts)-1)]
+		next++
+		return textAnswer(text), nil
+	}
+}
+
+// messageText returns the text of a sampling request's messages.
+func messageText(request mcp.CreateMessageRequest) string {
+	var parts []string
+	for _, message := range request.Messages {
+		if text, ok := message.Content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// textAnswer is a finished text answer from the mock model.
+func textAnswer(text string) *mcp.CreateMessageResult {
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.TextContent{Type: "text", Text: text},
+		},
+		Model:      "mock-model",
+		StopReason: "endTurn",
+	}
+}
+
+// newTestServer creates a server over a temporary files directory holding
+// files, with its partial results kept in another one.
+func newTestServer(t *testing.T, cfg Config, files map[string]string) *Server {
+	t.Helper()
+	if cfg.FilesDir == "" {
+		cfg.FilesDir = t.TempDir()
+	}
+	if cfg.PartialsDir == "" {
+		cfg.PartialsDir = t.TempDir()
+	}
+	for name, content := range files {
+		path := filepath.Join(cfg.FilesDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(cfg)
+}
+
+// connect starts an in-process client with sampler as its sampling
+// handler and initializes a session with s.
+func connect(t *testing.T, s *Server, sampler *mockSampler) *client.Client {
+	t.Helper()
+	c, err := client.NewInProcessClientWithSamplingHandler(s.MCPServer(), sampler)
+	if err != nil {
+		t.Fatalf("creating in-process client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("starting client: %v", err)
+	}
+	_, err = c.Initialize(ctx, mcp.InitializeRequest{
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			ClientInfo:      mcp.Implementation{Name: "test-client", Version: "1.0.0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return c
+}
+
+// callTool calls a tool and returns its result and text.
+func callTool(t *testing.T, c *client.Client, name string, args map[string]any) (*mcp.CallToolResult, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := c.CallTool(ctx, mcp.CallToolRequest{
+		Params: mcp.CallToolParams{Name: name, Arguments: args},
+	})
+	if err != nil {
+		t.Fatalf("calling %s: %v", name, err)
+	}
+	return result, toolResultText(result)
+}
+
+// mustSucceed calls a tool and fails the test if it returns an error
+// result.
+func mustSucceed(t *testing.T, c *client.Client, name string, args map[string]any) (*mcp.CallToolResult, string) {
+	t.Helper()
+	result, text := callTool(t, c, name, args)
+	if result.IsError {
+		t.Fatalf("%s returned an error: %s", name, text)
+	}
+	return result, text
+}
+
+// mustFail calls a tool and fails the test unless it returns an error
+// result.
+func mustFail(t *testing.T, c *client.Client, name string, args map[string]any) string {
+	t.Helper()
+	result, text := callTool(t, c, name, args)
+	if !result.IsError {
+		t.Fatalf("%s succeeded, want an error: %s", name, text)
+	}
+	return text
+}
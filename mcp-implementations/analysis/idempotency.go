@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultIdempotencyTTL is how long a tool call's result is kept for
+// repeats with the same idempotency key when Config leaves it unset.
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+// idempotencyKeyProperty documents the idempotency_key argument on tools
+// that sample.
+var idempotencyKeyProperty = map[string]any{
+	"type":        "string",
+	"description": "Any unique string. Repeating the call with the same key and arguments, while it runs or shortly after, returns its result without sampling again. Calls without a key always run",
+}
+
+// idempotencyEntry is one tool call remembered under its idempotency key.
+// done is closed when the call finishes; result is then its result, or nil
+// if it failed.
+type idempotencyEntry struct {
+	hash    string
+	done    chan struct{}
+	result  *mcp.CallToolResult
+	expires time.Time
+}
+
+// idempotencyStore remembers tool calls by idempotency key, so a client
+// retrying a call it gave up waiting for is not charged for it twice.
+// Finished entries are also queued by expiry, so claim only looks at the
+// ones that have expired instead of scanning every key.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+	expiry  expiryHeap
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, entries: map[string]*idempotencyEntry{}}
+}
+
+// expiring is a finished entry waiting in the expiry heap.
+type expiring struct {
+	key   string
+	entry *idempotencyEntry
+}
+
+// expiryHeap orders finished entries by expiry, soonest first.
+type expiryHeap []expiring
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].entry.expires.Before(h[j].entry.expires) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiring)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// expire forgets the entries that expired by now. The caller holds mu.
+func (st *idempotencyStore) expire(now time.Time) {
+	for st.expiry.Len() > 0 && now.After(st.expiry[0].entry.expires) {
+		e := heap.Pop(&st.expiry).(expiring)
+		// The key may have been claimed again since
+		if st.entries[e.key] == e.entry {
+			delete(st.entries, e.key)
+		}
+	}
+}
+
+// claim returns the entry for key, creating it if there is none. owner is
+// true when the caller created it and must run the call and then finish
+// it. A key already used with a different call is an error.
+func (st *idempotencyStore) claim(key, hash string, now time.Time) (entry *idempotencyEntry, owner bool, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.expire(now)
+	if e, ok := st.entries[key]; ok {
+		if e.hash != hash {
+			return nil, false, fmt.Errorf("idempotency_key %q was already used for a different call", key)
+		}
+		return e, false, nil
+	}
+	e := &idempotencyEntry{hash: hash, done: make(chan struct{})}
+	st.entries[key] = e
+	return e, true, nil
+}
+
+// finish records the result of an entry's call and releases the calls
+// waiting on it. A failed call, with a nil result, is forgotten, so a
+// retry runs it again.
+func (st *idempotencyStore) finish(key string, e *idempotencyEntry, result *mcp.CallToolResult, now time.Time) {
+	st.mu.Lock()
+	if result != nil {
+		e.result, e.expires = result, now.Add(st.ttl)
+		heap.Push(&st.expiry, expiring{key: key, entry: e})
+	} else {
+		delete(st.entries, key)
+	}
+	st.mu.Unlock()
+	close(e.done)
+}
+
+// withIdempotency is tool middleware that runs each call once per
+// idempotency key. A repeat while the first call runs waits for it, and a
+// repeat after returns its result, in both cases without sampling. Calls
+// without a key, and tools that do not take idempotency_key, which do not
+// sample, always run and are not kept. Error results are not kept either,
+// so retrying a failed call runs it again.
+func (s *Server) withIdempotency(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, ok := s.tools[request.Params.Name].Tool.InputSchema.Properties["idempotency_key"]; !ok {
+			return next(ctx, request)
+		}
+		key := request.GetString("idempotency_key", "")
+		if key == "" {
+			return next(ctx, request)
+		}
+		hash, err := callHash(request)
+		if err != nil {
+			return next(ctx, request)
+		}
+
+		for {
+			entry, owner, err := s.idempotency.claim(key, hash, time.Now())
+			if err != nil {
+				return errorResult("%v", err), nil
+			}
+			if owner {
+				result, err := next(ctx, request)
+				if err != nil || result == nil || result.IsError {
+					s.idempotency.finish(key, entry, nil, time.Now())
+					return withIdempotencyMeta(result, key, false), err
+				}
+				s.idempotency.finish(key, entry, result, time.Now())
+				return withIdempotencyMeta(result, key, false), nil
+			}
+
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if entry.result != nil {
+				logf(ctx, "♻️  Returning the result of idempotency key %q without running %s again", key, request.Params.Name)
+				return withIdempotencyMeta(entry.result, key, true), nil
+			}
+			// The first call failed, so this one runs it again
+		}
+	}
+}
+
+// callHash identifies a tool call by its tool and arguments, other than
+// the idempotency key itself and debug, which only changes what is logged.
+func callHash(request mcp.CallToolRequest) (string, error) {
+	args := maps.Clone(request.GetArguments())
+	delete(args, "idempotency_key")
+	delete(args, "debug")
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(request.Params.Name+"\x00"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withIdempotencyMeta returns a copy of result whose _meta names the
+// idempotency key and whether the result is a replay. The stored result
+// is shared by every replay, so it is never changed.
+func withIdempotencyMeta(result *mcp.CallToolResult, key string, replayed bool) *mcp.CallToolResult {
+	if result == nil {
+		return nil
+	}
+	copied := *result
+	fields := map[string]any{}
+	if result.Meta != nil {
+		fields = maps.Clone(result.Meta.AdditionalFields)
+		if fields == nil {
+			fields = map[string]any{}
+		}
+	}
+	fields["idempotency"] = map[string]any{"key": key, "replayed": replayed}
+	copied.Meta = mcp.NewMetaFromMap(fields)
+	if result.Meta != nil {
+		copied.Meta.ProgressToken = result.Meta.ProgressToken
+	}
+	return &copied
+}
@@ -0,0 +1,170 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// idempotencyOf returns the idempotency entry of a result's _meta.
+func idempotencyOf(t *testing.T, result *mcp.CallToolResult) map[string]any {
+	t.Helper()
+	if result.Meta == nil {
+		t.Fatal("result has no _meta")
+	}
+	meta, ok := result.Meta.AdditionalFields["idempotency"].(map[string]any)
+	if !ok {
+		t.Fatalf("_meta has no idempotency entry: %v", result.Meta.AdditionalFields)
+	}
+	return meta
+}
+
+func TestIdempotencyKeyReturnsFirstResult(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: answers("first answer", "second answer")}
+	c := connect(t, s, sampler)
+	args := map[string]any{"filename": "notes.txt", "use_cache": false, "idempotency_key": "retry-1"}
+
+	first, firstText := mustSucceed(t, c, "analyze_file", args)
+	// debug only changes what is logged, so it is still the same call
+	args["debug"] = true
+	second, secondText := mustSucceed(t, c, "analyze_file", args)
+
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("%d sampling requests, want the repeat answered without sampling", n)
+	}
+	if secondText != firstText || !strings.Contains(secondText, "first answer") {
+		t.Errorf("repeat returned a different result:\n%s", secondText)
+	}
+	if meta := idempotencyOf(t, first); meta["key"] != "retry-1" || meta["replayed"] != false {
+		t.Errorf("first call _meta = %v", meta)
+	}
+	if meta := idempotencyOf(t, second); meta["key"] != "retry-1" || meta["replayed"] != true {
+		t.Errorf("repeat _meta = %v, want it marked replayed", meta)
+	}
+}
+
+func TestIdempotencyKeyReusedForDifferentCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"a.txt": "A.", "b.txt": "B."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "a.txt", "idempotency_key": "k"})
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "b.txt", "idempotency_key": "k"})
+
+	if !strings.Contains(text, `idempotency_key "k" was already used for a different call`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("%d sampling requests, want only the first call's", n)
+	}
+}
+
+func TestCallsWithoutIdempotencyKeyAlwaysRun(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+	args := map[string]any{"filename": "notes.txt", "use_cache": false}
+
+	result, _ := mustSucceed(t, c, "analyze_file", args)
+	mustSucceed(t, c, "analyze_file", args)
+
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want both calls to sample", n)
+	}
+	if result.Meta != nil && result.Meta.AdditionalFields["idempotency"] != nil {
+		t.Errorf("a call without a key has idempotency _meta: %v", result.Meta.AdditionalFields)
+	}
+}
+
+func TestIdempotencyKeyWaitsForCallInFlight(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := slowSampler(100 * time.Millisecond)
+	c := connect(t, s, sampler)
+	request := mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Name:      "analyze_file",
+		Arguments: map[string]any{"filename": "notes.txt", "use_cache": false, "idempotency_key": "once"},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	results := make([]*mcp.CallToolResult, 3)
+	errs := make([]error, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.CallTool(ctx, request)
+		}()
+	}
+	wg.Wait()
+
+	replayed := 0
+	for i, result := range results {
+		if errs[i] != nil || result.IsError {
+			t.Fatalf("call %d failed: %v %s", i, errs[i], toolResultText(result))
+		}
+		if idempotencyOf(t, result)["replayed"] == true {
+			replayed++
+		}
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("%d sampling requests for three concurrent calls, want 1", n)
+	}
+	if replayed != 2 {
+		t.Errorf("%d results marked replayed, want 2", replayed)
+	}
+}
+
+func TestIdempotencyKeyRetriesFailedCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	failed := false
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("provider unavailable")
+		}
+		return textAnswer(mockAnswer), nil
+	}}
+	c := connect(t, s, sampler)
+	args := map[string]any{"filename": "notes.txt", "use_cache": false, "idempotency_key": "flaky"}
+
+	mustFail(t, c, "analyze_file", args)
+	result, _ := mustSucceed(t, c, "analyze_file", args)
+
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want the failed call run again", n)
+	}
+	if meta := idempotencyOf(t, result); meta["replayed"] != false {
+		t.Errorf("the retry of a failed call is marked replayed: %v", meta)
+	}
+}
+
+func TestIdempotencyStoreExpiresEntries(t *testing.T) {
+	st := newIdempotencyStore(time.Minute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	result := textResult("done")
+
+	entry, owner, err := st.claim("k", "hash", now)
+	if err != nil || !owner {
+		t.Fatalf("first claim: owner %t, %v", owner, err)
+	}
+	st.finish("k", entry, result, now)
+
+	again, owner, err := st.claim("k", "hash", now.Add(time.Minute))
+	if err != nil || owner || again.result != result {
+		t.Errorf("claim within the TTL: owner %t, %v; want the stored result", owner, err)
+	}
+	if _, owner, _ := st.claim("k", "other hash", now.Add(time.Minute+time.Second)); !owner {
+		t.Error("claim after the TTL did not start over, even with different arguments")
+	}
+	if st.expiry.Len() != 0 {
+		t.Errorf("%d expired entries are still queued", st.expiry.Len())
+	}
+}
@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	// Registered for image.Decode
+	_ "image/gif"
+)
+
+// Default image limits, from the strictest common provider: Anthropic
+// rejects images over 8000 pixels on a side or 5 MB once base64 encoded.
+const (
+	DefaultImageMaxDimension = 8000
+	DefaultImageMaxBytes     = 5 << 20
+)
+
+// minImageDimension is the smallest long edge fitImage shrinks an image to
+// on its own while trying to meet the byte limit. A smaller
+// image_max_dimension goes lower.
+const minImageDimension = 256
+
+// fitImage returns an image that fits within maxDimension pixels on its
+// long edge and maxBytes once base64 encoded, downscaling and re-encoding
+// it if needed. Images that already fit are returned untouched. The
+// returned MIME type changes when the image is re-encoded.
+func fitImage(data []byte, mimeType string, maxDimension int, maxBytes int) ([]byte, string, bool, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if base64.StdEncoding.EncodedLen(len(data)) <= maxBytes {
+			// Can't read it, e.g. WebP, but it is small enough to send as is
+			return data, mimeType, false, nil
+		}
+		return nil, "", false, fmt.Errorf("it is %d bytes, over the provider limit of %d, and %s images cannot be downscaled; convert it to PNG or JPEG", len(data), maxBytes, mimeType)
+	}
+	longEdge := max(config.Width, config.Height)
+	if longEdge <= maxDimension && base64.StdEncoding.EncodedLen(len(data)) <= maxBytes {
+		return data, mimeType, false, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("decoding the image to downscale it: %v", err)
+	}
+
+	// Shrink to the dimension limit, then further while the encoding is
+	// still over the byte limit
+	target := min(longEdge, maxDimension)
+	floor := min(target, minImageDimension)
+	for {
+		scaled := downscale(img, target*config.Width/longEdge, target*config.Height/longEdge)
+		encoded, encodedType, err := encodeImage(scaled, format)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("re-encoding the downscaled image: %v", err)
+		}
+		if base64.StdEncoding.EncodedLen(len(encoded)) <= maxBytes {
+			return encoded, encodedType, true, nil
+		}
+		if target <= floor {
+			return nil, "", false, fmt.Errorf("it is still %d bytes after downscaling to %d pixels, over the provider limit of %d; "+
+				"pass a smaller image_max_dimension to shrink it further", len(encoded), target, maxBytes)
+		}
+		target = max(target*3/4, floor)
+	}
+}
+
+// fitImageFile fits an image file's data to the configured provider
+// limits, or to maxDimension pixels when that is set and smaller. Errors are
+// safe to show to the caller.
+func (s *Server) fitImageFile(ctx context.Context, filename string, data []byte, mimeType string, maxDimension int) ([]byte, string, error) {
+	limit := s.cfg.ImageMaxDimension
+	if maxDimension > 0 {
+		limit = min(limit, maxDimension)
+	}
+	fitted, fittedType, scaled, err := fitImage(data, mimeType, limit, s.cfg.ImageMaxBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s cannot be sent to the model: %v", filename, err)
+	}
+	if scaled {
+		logf(ctx, "Downscaled image %s to fit provider limits (%d -> %d bytes, %s)", filename, len(data), len(fitted), fittedType)
+	}
+	return fitted, fittedType, nil
+}
+
+// encodeImage encodes img as PNG when it came from a PNG or GIF, which may
+// rely on transparency or sharp edges, and as JPEG otherwise.
+func encodeImage(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "png" || format == "gif" {
+		err := png.Encode(&buf, img)
+		return buf.Bytes(), "image/png", err
+	}
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	return buf.Bytes(), "image/jpeg", err
+}
+
+// downscale resizes src to width x height by averaging the source pixels
+// that fall in each destination pixel. The result has 8 bits per channel,
+// so a PNG re-encode is not twice the size of the source.
+func downscale(src image.Image, width, height int) image.Image {
+	width, height = max(width, 1), max(height, 1)
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// pngFixture encodes a width x height PNG. Noisy images barely compress,
+// so their size tracks their pixel count.
+func pngFixture(t *testing.T, width, height int, noisy bool) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rng := rand.New(rand.NewSource(1))
+	for y := range height {
+		for x := range width {
+			c := color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255}
+			if noisy {
+				c = color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// sentImage decodes the image of the first sampling request.
+func sentImage(t *testing.T, sampler *mockSampler) (image.Config, mcp.ImageContent) {
+	t.Helper()
+	content, ok := sampler.Requests()[0].Messages[0].Content.(mcp.ImageContent)
+	if !ok {
+		t.Fatalf("sampling request did not carry an image: %T", sampler.Requests()[0].Messages[0].Content)
+	}
+	data, err := base64.StdEncoding.DecodeString(content.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("sent image does not decode: %v", err)
+	}
+	return config, content
+}
+
+func TestAnalyzeFileDownscalesOversizedImage(t *testing.T) {
+	s := newTestServer(t, Config{ImageMaxDimension: 100}, map[string]string{"wide.png": pngFixture(t, 400, 200, false)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "wide.png"})
+
+	config, content := sentImage(t, sampler)
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("image sent at %dx%d, want 100x50", config.Width, config.Height)
+	}
+	if content.MIMEType != "image/png" {
+		t.Errorf("PNG re-encoded as %s", content.MIMEType)
+	}
+}
+
+func TestAnalyzeFileShrinksImageToByteLimit(t *testing.T) {
+	fixture := pngFixture(t, 300, 300, true)
+	s := newTestServer(t, Config{ImageMaxBytes: 300_000}, map[string]string{"noise.png": fixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "noise.png"})
+
+	config, content := sentImage(t, sampler)
+	if len(content.Data) > 300_000 {
+		t.Errorf("sent %d base64 bytes, over the limit of 300000", len(content.Data))
+	}
+	if config.Width >= 300 {
+		t.Errorf("image was not shrunk: %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestAnalyzeFileSendsFittingImageUntouched(t *testing.T) {
+	fixture := pngFixture(t, 40, 20, false)
+	s := newTestServer(t, Config{}, map[string]string{"small.png": fixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "small.png"})
+
+	if _, content := sentImage(t, sampler); content.Data != base64.StdEncoding.EncodeToString([]byte(fixture)) {
+		t.Error("an image within the limits was re-encoded")
+	}
+}
+
+func TestAnalyzeFileImageMaxDimensionArgument(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"wide.png": pngFixture(t, 400, 200, false)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "wide.png", "image_max_dimension": 80})
+
+	if config, _ := sentImage(t, sampler); config.Width != 80 || config.Height != 40 {
+		t.Errorf("image sent at %dx%d, want 80x40", config.Width, config.Height)
+	}
+}
+
+func TestAnalyzeFileSuggestsImageMaxDimensionWhenStillTooLarge(t *testing.T) {
+	s := newTestServer(t, Config{ImageMaxBytes: 1000}, map[string]string{"noise.png": pngFixture(t, 300, 300, true)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "noise.png"})
+	if !strings.Contains(text, "noise.png cannot be sent to the model") || !strings.Contains(text, "pass a smaller image_max_dimension") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("an image over the limit sent %d sampling requests", n)
+	}
+}
+
+func TestDownscaleKeepsEightBitChannels(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	dst := downscale(src, 2, 2)
+	if _, ok := dst.(*image.RGBA); !ok {
+		t.Fatalf("downscale returned %T, want *image.RGBA", dst)
+	}
+	if got := dst.At(1, 1).(color.RGBA); got != (color.RGBA{R: 200, G: 100, B: 50, A: 255}) {
+		t.Errorf("averaged pixel is %v", got)
+	}
+}
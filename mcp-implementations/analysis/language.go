@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// languageSampleBytes is how much of a file detect_language sends; the
+// opening of a file is plenty to identify its language.
+const languageSampleBytes = 4000
+
+// naturalLanguages maps ISO 639-1 codes to English language names.
+var naturalLanguages = map[string]string{
+	"ar": "Arabic", "bg": "Bulgarian", "bn": "Bengali", "ca": "Catalan", "cs": "Czech",
+	"da": "Danish", "de": "German", "el": "Greek", "en": "English", "es": "Spanish",
+	"et": "Estonian", "fa": "Persian", "fi": "Finnish", "fr": "French", "he": "Hebrew",
+	"hi": "Hindi", "hr": "Croatian", "hu": "Hungarian", "id": "Indonesian", "it": "Italian",
+	"ja": "Japanese", "ko": "Korean", "lt": "Lithuanian", "lv": "Latvian", "ms": "Malay",
+	"nl": "Dutch", "no": "Norwegian", "pl": "Polish", "pt": "Portuguese", "ro": "Romanian",
+	"ru": "Russian", "sk": "Slovak", "sl": "Slovenian", "sr": "Serbian", "sv": "Swedish",
+	"sw": "Swahili", "ta": "Tamil", "th": "Thai", "tr": "Turkish", "uk": "Ukrainian",
+	"ur": "Urdu", "vi": "Vietnamese", "zh": "Chinese",
+}
+
+// programmingLanguages lists the programming and data formats accepted as
+// answers, by their usual names.
+var programmingLanguages = []string{
+	"Bash", "C", "C#", "C++", "CSS", "CSV", "Dart", "Dockerfile", "Elixir", "Go", "GraphQL",
+	"Haskell", "HTML", "INI", "Java", "JavaScript", "JSON", "Julia", "Kotlin", "Lua", "Makefile",
+	"Markdown", "Objective-C", "Perl", "PHP", "PowerShell", "Protobuf", "Python", "R", "Ruby",
+	"Rust", "Scala", "Shell", "SQL", "Swift", "TOML", "TypeScript", "XML", "YAML", "Zig",
+}
+
+var detectLanguageTool = mcp.Tool{
+	Name:        "detect_language",
+	Description: "Identify the natural language or programming language/format of a text file using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The name of the file to inspect (relative to files directory)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// detectedLanguage is the answer detect_language asks the model for.
+type detectedLanguage struct {
+	Kind string `json:"kind"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func (s *Server) handleDetectLanguage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if strings.TrimSpace(text) == "" {
+		return errorResult("%s is empty", filename), nil
+	}
+	sample := splitChunks(text, languageSampleBytes)[0]
+
+	answer, model, err := s.detectLanguage(ctx, filename, sample)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	logf(ctx, "✅ Detected %s as %s (%s)", filename, answer.Name, answer.Code)
+
+	return textResult(fmt.Sprintf("Language Detection Results\n"+
+		"==========================\n"+
+		"File: %s\n"+
+		"Kind: %s\n"+
+		"Code: %s\n"+
+		"Language: %s\n"+
+		"Sample: %d of %d bytes\n"+
+		"Model: %s", filename, answer.Kind, answer.Code, answer.Name, len(sample), len(text), model)), nil
+}
+
+// detectLanguage asks the model for the language of sample, a short
+// excerpt of filename, reprompting once if the answer is not a known
+// language. It also returns the model that answered.
+func (s *Server) detectLanguage(ctx context.Context, filename, sample string) (detectedLanguage, string, error) {
+	systemPrompt := "Identify the language of this content. If it is prose, give its natural language as an ISO 639-1 code. " +
+		"If it is source code or a data format, give the programming language or format name. Respond with only a JSON object: " +
+		`{"kind": "natural" or "programming", "code": "<ISO 639-1 code, or the language name for programming>", "name": "<language name in English>"}.`
+
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: sample}, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 100
+
+	logf(ctx, "📤 Sending sampling request to detect language of: %s", filename)
+	var answer detectedLanguage
+	result, err := s.sampleJSON(ctx, samplingRequest, "a recognized language", func(text string) error {
+		var ok bool
+		if answer, ok = parseDetectedLanguage(text); !ok {
+			return fmt.Errorf("%q does not name a recognized ISO 639-1 code or programming language", strings.TrimSpace(text))
+		}
+		return nil
+	})
+	if err != nil {
+		return detectedLanguage{}, "", err
+	}
+	return answer, result.Model, nil
+}
+
+// lookupNaturalLanguage finds a natural language by ISO 639-1 code or
+// English name, returning its name.
+func lookupNaturalLanguage(language string) (string, bool) {
+	language = strings.TrimSpace(language)
+	if name, ok := naturalLanguages[strings.ToLower(language)]; ok {
+		return name, true
+	}
+	for _, name := range naturalLanguages {
+		if strings.EqualFold(language, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// languageInstruction is the sentence added to an analysis prompt to set
+// the language of the answer. An explicit language always wins. Without
+// one, and with detection on for the server or the call, the opening of a
+// text file is sent to detectLanguage and the answer is asked for in the
+// file's own language. Detection is best effort: when it fails, or finds a
+// programming language, no instruction is added.
+func (s *Server) languageInstruction(ctx context.Context, opts analyzeOptions, filePath string) (string, error) {
+	if opts.Language != "" {
+		name, ok := lookupNaturalLanguage(opts.Language)
+		if !ok {
+			return "", fmt.Errorf("unknown language %q; use an ISO 639-1 code such as fr, or a language name", opts.Language)
+		}
+		return fmt.Sprintf(" Write your answer in %s.", name), nil
+	}
+
+	auto := s.cfg.AutoLanguage
+	if opts.AutoLanguage != nil {
+		auto = *opts.AutoLanguage
+	}
+	if !auto || !isTextFile(opts.Filename, mimeTypeFor(opts.Filename)) || isNotebook(opts.Filename) {
+		return "", nil
+	}
+
+	data, _, err := readWindow(filePath, byteWindow{Offset: 0, Length: languageSampleBytes})
+	if err != nil {
+		log.Printf("Warning: Could not read %s to detect its language: %v", opts.Filename, err)
+		return "", nil
+	}
+	data, _ = trimPartialRunes(data)
+	sample, _ := normalizeText(data)
+	if strings.TrimSpace(sample) == "" {
+		return "", nil
+	}
+
+	detected, _, err := s.detectLanguage(ctx, opts.Filename, sample)
+	if err != nil {
+		log.Printf("Warning: Could not detect the language of %s: %v", opts.Filename, err)
+		return "", nil
+	}
+	if detected.Kind != "natural" {
+		return "", nil
+	}
+	logf(ctx, "🌐 %s is in %s; asking for the answer in %s", opts.Filename, detected.Name, detected.Name)
+	return fmt.Sprintf(" Write your answer in %s, the language of the document.", detected.Name), nil
+}
+
+// parseDetectedLanguage validates the model's answer against the known
+// languages and normalizes it: natural languages to lowercase ISO 639-1
+// codes, programming languages to their canonical names.
+func parseDetectedLanguage(text string) (detectedLanguage, bool) {
+	var answer detectedLanguage
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return answer, false
+	}
+
+	// Accept either field holding a code or a name, whatever the kind says
+	for _, candidate := range []string{answer.Code, answer.Name} {
+		candidate = strings.TrimSpace(candidate)
+		if name, ok := naturalLanguages[strings.ToLower(candidate)]; ok {
+			return detectedLanguage{Kind: "natural", Code: strings.ToLower(candidate), Name: name}, true
+		}
+		for code, name := range naturalLanguages {
+			if strings.EqualFold(candidate, name) {
+				return detectedLanguage{Kind: "natural", Code: code, Name: name}, true
+			}
+		}
+		for _, name := range programmingLanguages {
+			if strings.EqualFold(candidate, name) {
+				return detectedLanguage{Kind: "programming", Code: name, Name: name}, true
+			}
+		}
+	}
+	return answer, false
+}
@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// languageAnswerer plays a model that recognizes English and French.
+func languageAnswerer(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	if strings.Contains(messageText(request), "Bonjour") {
+		return textAnswer(`{"kind": "natural", "code": "FR", "name": "French"}`), nil
+	}
+	return textAnswer(`{"kind": "natural", "code": "en", "name": "English"}`), nil
+}
+
+func TestDetectLanguageEnglishAndFrench(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"english.txt": "Hello, this is a short note about the weather today.",
+		"french.txt":  "Bonjour, ceci est une courte note sur la météo d'aujourd'hui.",
+	})
+	c := connect(t, s, &mockSampler{respond: languageAnswerer})
+
+	_, text := mustSucceed(t, c, "detect_language", map[string]any{"filename": "english.txt"})
+	if !strings.Contains(text, "Code: en\n") || !strings.Contains(text, "Language: English") {
+		t.Errorf("English fixture:\n%s", text)
+	}
+	_, text = mustSucceed(t, c, "detect_language", map[string]any{"filename": "french.txt"})
+	if !strings.Contains(text, "Code: fr\n") || !strings.Contains(text, "Language: French") {
+		t.Errorf("French fixture, with the code normalized to lower case:\n%s", text)
+	}
+}
+
+func TestDetectLanguageSendsOnlyASample(t *testing.T) {
+	long := strings.Repeat("Hello there. ", 2000)
+	s := newTestServer(t, Config{}, map[string]string{"long.txt": long})
+	sampler := &mockSampler{respond: languageAnswerer}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "detect_language", map[string]any{"filename": "long.txt"})
+	if n := len(messageText(sampler.Requests()[0])); n > languageSampleBytes {
+		t.Errorf("sent %d bytes, want at most %d", n, languageSampleBytes)
+	}
+	if !strings.Contains(text, "of 26000 bytes") {
+		t.Errorf("result does not report the sample size:\n%s", text)
+	}
+}
+
+func TestDetectLanguageRepromptsOnGarbage(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"english.txt": "Hello, a note."})
+	sampler := &mockSampler{respond: answers("I think it's probably some language.", `{"kind": "natural", "code": "en", "name": "English"}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "detect_language", map[string]any{"filename": "english.txt"})
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want a single reprompt", n)
+	}
+	if !strings.Contains(text, "Code: en\n") {
+		t.Errorf("result does not carry the corrected answer:\n%s", text)
+	}
+}
+
+// detectingSampler answers language detection like languageAnswerer, and
+// recognizes Go, and everything else with mockAnswer.
+func detectingSampler() *mockSampler {
+	return &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		switch {
+		case !strings.HasPrefix(request.SystemPrompt, "Identify the language"):
+			return textAnswer(mockAnswer), nil
+		case strings.Contains(messageText(request), "package main"):
+			return textAnswer(`{"kind": "programming", "code": "Go", "name": "Go"}`), nil
+		}
+		return languageAnswerer(request)
+	}}
+}
+
+const frenchNote = "Bonjour, ceci est une courte note sur la météo d'aujourd'hui."
+
+const frenchInstruction = " Write your answer in French, the language of the document."
+
+func TestAutoLanguagePassesDetectedLanguageToAnalysis(t *testing.T) {
+	s := newTestServer(t, Config{AutoLanguage: true}, map[string]string{"french.txt": frenchNote})
+	sampler := detectingSampler()
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "french.txt"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d sampling requests, want detection and analysis", len(requests))
+	}
+	if messageText(requests[0]) != frenchNote {
+		t.Errorf("detection was sent %q", messageText(requests[0]))
+	}
+	summarize, _ := lookupAnalysisType("summarize")
+	if prompt := requests[1].SystemPrompt; !strings.HasPrefix(prompt, summarize.Prompt+frenchInstruction+" ") {
+		t.Errorf("analysis prompt does not ask for French:\n%s", prompt)
+	}
+}
+
+func TestAutoLanguageArgumentOverridesConfig(t *testing.T) {
+	tests := []struct {
+		config, arg bool
+	}{
+		{config: false, arg: true},
+		{config: true, arg: false},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t, Config{AutoLanguage: tt.config}, map[string]string{"french.txt": frenchNote})
+		sampler := detectingSampler()
+		c := connect(t, s, sampler)
+
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "french.txt", "auto_language": tt.arg})
+
+		requests := sampler.Requests()
+		analysis := requests[len(requests)-1].SystemPrompt
+		if detected := len(requests) == 2; detected != tt.arg {
+			t.Errorf("config %v, argument %v: %d sampling requests", tt.config, tt.arg, len(requests))
+		}
+		if asked := strings.Contains(analysis, frenchInstruction); asked != tt.arg {
+			t.Errorf("config %v, argument %v: analysis prompt %q", tt.config, tt.arg, analysis)
+		}
+	}
+}
+
+func TestExplicitLanguageSkipsDetection(t *testing.T) {
+	s := newTestServer(t, Config{AutoLanguage: true}, map[string]string{"french.txt": frenchNote})
+	sampler := detectingSampler()
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "french.txt", "language": "de"})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "french.txt", "language": "german", "use_cache": false})
+
+	for _, request := range sampler.Requests() {
+		if !strings.Contains(request.SystemPrompt, " Write your answer in German. ") {
+			t.Errorf("request is not an analysis in German: %q", request.SystemPrompt)
+		}
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want two analyses and no detection", n)
+	}
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "french.txt", "language": "klingon"})
+	if !strings.Contains(text, `unknown language "klingon"`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestAutoLanguageAddsNothingForCodeOrFailure(t *testing.T) {
+	s := newTestServer(t, Config{AutoLanguage: true}, map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"french.txt": frenchNote,
+	})
+	sampler := detectingSampler()
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "main.go"})
+	if prompt := sampler.Requests()[1].SystemPrompt; strings.Contains(prompt, "Write your answer in") {
+		t.Errorf("a Go file got a language instruction: %q", prompt)
+	}
+
+	// Detection that never gives a language leaves the analysis as it was
+	garbage := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if strings.HasPrefix(request.SystemPrompt, "Identify the language") {
+			return textAnswer("No idea."), nil
+		}
+		return textAnswer(mockAnswer), nil
+	}}
+	c = connect(t, s, garbage)
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "french.txt"})
+	requests := garbage.Requests()
+	if len(requests) != 3 || strings.Contains(requests[2].SystemPrompt, "Write your answer in") {
+		t.Errorf("failed detection changed the analysis: %d requests, prompt %q", len(requests), requests[len(requests)-1].SystemPrompt)
+	}
+	if !strings.Contains(text, mockAnswer) {
+		t.Errorf("analysis did not complete:\n%s", text)
+	}
+}
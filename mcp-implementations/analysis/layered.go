@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxLayerTitleLength rejects titles that are really sentences.
+const maxLayerTitleLength = 120
+
+var layeredSummaryTool = mcp.Tool{
+	Name:        "layered_summary",
+	Description: "Summarize a text file at four levels of detail in one response using LLM sampling: a title, one sentence, one paragraph and a detailed summary, returned as JSON for progressive disclosure",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to summarize (relative to files directory)",
+			},
+			"audience":        audienceProperty,
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// LayeredSummary is the structured result of layered_summary, from least
+// to most detailed.
+type LayeredSummary struct {
+	File      string `json:"file"`
+	Model     string `json:"model"`
+	Title     string `json:"title"`
+	Sentence  string `json:"sentence"`
+	Paragraph string `json:"paragraph"`
+	Detailed  string `json:"detailed"`
+	// Truncated reports that only part of the file was summarized
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleLayeredSummary(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	summary := LayeredSummary{File: filename}
+	text, summary.Truncated = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt, err := withAudience("Summarize this document at four levels of detail, each able to stand on its own: "+
+		"a short title; a one-sentence summary; a one-paragraph summary; and a detailed summary of several paragraphs "+
+		"covering every main point. "+
+		`Respond with only a JSON object: {"title": "...", "sentence": "...", "paragraph": "...", "detailed": "..."}.`,
+		request.GetString("audience", ""))
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if summary.Truncated {
+		systemPrompt += " The document was truncated; summarize only what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 2000
+
+	logf(ctx, "📤 Sending sampling request for a layered summary of: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid layered summary", func(text string) error {
+		return parseLayeredSummary(text, &summary)
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	summary.Model = result.Model
+
+	logf(ctx, "✅ Layered summary of %s: %q", filename, summary.Title)
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding layered summary: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseLayeredSummary decodes the model's four layers into summary. Every
+// layer must be present, the title and sentence must each fit on one
+// line, the paragraph must be one paragraph, and each layer must be longer
+// than the one before.
+func parseLayeredSummary(text string, summary *LayeredSummary) error {
+	var answer struct {
+		Title     string `json:"title"`
+		Sentence  string `json:"sentence"`
+		Paragraph string `json:"paragraph"`
+		Detailed  string `json:"detailed"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	layers := []struct {
+		name  string
+		value *string
+	}{
+		{"title", &answer.Title},
+		{"sentence", &answer.Sentence},
+		{"paragraph", &answer.Paragraph},
+		{"detailed", &answer.Detailed},
+	}
+	for i, layer := range layers {
+		*layer.value = strings.TrimSpace(*layer.value)
+		if *layer.value == "" {
+			return fmt.Errorf("%s is missing or empty", layer.name)
+		}
+		if i > 0 && len(*layer.value) <= len(*layers[i-1].value) {
+			return fmt.Errorf("%s is not longer than %s", layer.name, layers[i-1].name)
+		}
+	}
+	switch {
+	case strings.Contains(answer.Title, "\n") || len(answer.Title) > maxLayerTitleLength:
+		return fmt.Errorf("title must be one line of at most %d characters", maxLayerTitleLength)
+	case strings.Contains(answer.Sentence, "\n"):
+		return fmt.Errorf("sentence must be one line")
+	case strings.Contains(answer.Paragraph, "\n\n"):
+		return fmt.Errorf("paragraph must be a single paragraph")
+	}
+
+	summary.Title = strings.TrimRight(answer.Title, ".")
+	summary.Sentence, summary.Paragraph, summary.Detailed = answer.Sentence, answer.Paragraph, answer.Detailed
+	return nil
+}
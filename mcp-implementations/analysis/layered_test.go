@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// layeredAnswer is a valid answer with four layers, each longer than the
+// last.
+const layeredAnswer = `{
+	"title": " Quarterly Sales Review. ",
+	"sentence": "Sales rose 10% in Q1 on strong demand in Europe.",
+	"paragraph": "Sales rose 10% in Q1. Demand in Europe drove most of the growth, while the US was flat. Churn fell to 2%.",
+	"detailed": "Sales rose 10% in Q1, the best quarter in two years.\n\nEurope drove most of the growth after the new distributor started. The US was flat.\n\nChurn fell to 2%, and the team expects Q2 to continue the trend."
+}`
+
+func TestLayeredSummaryStructure(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"q1.md": "Q1 report."})
+	sampler := &mockSampler{respond: answers(layeredAnswer)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "layered_summary", map[string]any{"filename": "q1.md"})
+
+	var summary LayeredSummary
+	if err := json.Unmarshal([]byte(text), &summary); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	if summary.File != "q1.md" || summary.Model != "mock-model" || summary.Truncated {
+		t.Errorf("unexpected result: %+v", summary)
+	}
+	if summary.Title != "Quarterly Sales Review" {
+		t.Errorf("title = %q, want it trimmed of spaces and the final period", summary.Title)
+	}
+	layers := []string{summary.Title, summary.Sentence, summary.Paragraph, summary.Detailed}
+	for i := 1; i < len(layers); i++ {
+		if len(layers[i]) <= len(layers[i-1]) {
+			t.Errorf("layer %d is not longer than layer %d: %q", i+1, i, layers[i])
+		}
+	}
+	if !strings.Contains(summary.Detailed, "\n\n") {
+		t.Errorf("detailed summary lost its paragraphs: %q", summary.Detailed)
+	}
+	// The JSON keys come from least to most detailed
+	order := []int{strings.Index(text, `"title"`), strings.Index(text, `"sentence"`), strings.Index(text, `"paragraph"`), strings.Index(text, `"detailed"`)}
+	for i := 1; i < len(order); i++ {
+		if order[i] < order[i-1] {
+			t.Errorf("layers are out of order:\n%s", text)
+		}
+	}
+}
+
+func TestParseLayeredSummaryValidatesLayers(t *testing.T) {
+	valid := map[string]string{
+		"title":     "Sales",
+		"sentence":  "Sales rose in Q1.",
+		"paragraph": "Sales rose in Q1, driven by Europe. The US was flat.",
+		"detailed":  "Sales rose in Q1, driven by Europe.\n\nThe US was flat, and churn fell to 2% as support improved.",
+	}
+	tests := []struct {
+		field, value, want string
+	}{
+		{"title", "", "title is missing or empty"},
+		{"detailed", "  ", "detailed is missing or empty"},
+		{"sentence", "Up.", "sentence is not longer than title"},
+		{"detailed", "Short.", "detailed is not longer than paragraph"},
+		{"title", "Sales\nQ1", "title must be one line"},
+		{"sentence", "Sales rose\nin Q1.", "sentence must be one line"},
+		{"paragraph", "Sales rose in Q1.\n\nThe US was flat, churn fell.", "paragraph must be a single paragraph"},
+	}
+	for _, tt := range tests {
+		answer := map[string]string{}
+		for k, v := range valid {
+			answer[k] = v
+		}
+		answer[tt.field] = tt.value
+		data, _ := json.Marshal(answer)
+
+		var summary LayeredSummary
+		if err := parseLayeredSummary(string(data), &summary); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: error %v, want %q", tt.field, tt.value, err, tt.want)
+		}
+	}
+
+	data, _ := json.Marshal(valid)
+	var summary LayeredSummary
+	if err := parseLayeredSummary(string(data), &summary); err != nil || summary.Paragraph != valid["paragraph"] {
+		t.Errorf("valid layers: %+v, %v", summary, err)
+	}
+}
+
+func TestLayeredSummaryRetriesMissingLayer(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"q1.md": "Q1 report."})
+	sampler := &mockSampler{respond: answers(`{"title": "Sales", "sentence": "Sales rose in Q1."}`, layeredAnswer)}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "layered_summary", map[string]any{"filename": "q1.md", "audience": "executive"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, "paragraph is missing or empty") {
+		t.Errorf("the incomplete answer was not asked again with the reason: %d requests", len(requests))
+	}
+	if !strings.Contains(requests[0].SystemPrompt, "Write for a busy executive") {
+		t.Errorf("prompt is not written for the audience: %s", requests[0].SystemPrompt)
+	}
+}
@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultLinkDepth is how many links away from the starting file
+// summarize_with_links follows when a call does not set max_depth.
+const DefaultLinkDepth = 2
+
+var summarizeWithLinksTool = mcp.Tool{
+	Name:        "summarize_with_links",
+	Description: "Summarize a Markdown file together with the local files it links to, following links up to a depth and size limit, using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The Markdown file to start from (relative to files directory)",
+			},
+			"max_depth": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("How many links away from the file to follow; 0 summarizes the file alone (default %d)", DefaultLinkDepth),
+			},
+			"max_bytes": map[string]any{
+				"type":        "integer",
+				"description": "Most bytes of file content to include in total (default and maximum: the server's chunk size)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// markdownLink matches the target of an inline Markdown link or image,
+// [text](target "title"), and of a reference definition, [id]: target.
+var markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)|(?m)^[ \t]{0,3}\[[^\]]+\]:[ \t]*<?([^\s>]+)`)
+
+// linkedFile is a file included in a summarize_with_links prompt.
+type linkedFile struct {
+	Name  string
+	Depth int
+	Text  string
+}
+
+func (s *Server) handleSummarizeWithLinks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	maxDepth := request.GetInt("max_depth", DefaultLinkDepth)
+	if maxDepth < 0 {
+		return errorResult("max_depth cannot be negative"), nil
+	}
+	maxBytes := request.GetInt("max_bytes", s.cfg.ChunkSize)
+	if maxBytes <= 0 || maxBytes > s.cfg.ChunkSize {
+		maxBytes = s.cfg.ChunkSize
+	}
+	if !isMarkdown(filename) {
+		return errorResult("%s is not a Markdown file", filename), nil
+	}
+
+	files, skipped, truncated, err := s.collectLinkedFiles(filename, maxDepth, maxBytes)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	for _, note := range skipped {
+		logf(ctx, "Skipped link: %s", note)
+	}
+
+	var content strings.Builder
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name
+		fmt.Fprintf(&content, "=== %s ===\n%s\n\n", file.Name, file.Text)
+	}
+	systemPrompt := fmt.Sprintf("The content is the Markdown file %s followed by the local files it links to, each after a === name === line. "+
+		"Write one holistic summary of what this set of documents covers, led by %s: how the linked files relate to it and what each adds. "+
+		"Do not summarize the files one by one.", filename, filename)
+	if truncated {
+		systemPrompt += " Some of the linked content was cut off to fit the size limit."
+	}
+
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: content.String()}, systemPrompt)
+	samplingRequest.MaxTokens = 1500
+
+	logf(ctx, "📤 Sending sampling request to summarize %s with %d linked files", filename, len(files)-1)
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+	logf(ctx, "✅ Linked summary successful! Model: %s", result.Model)
+
+	skippedText := "none"
+	if len(skipped) > 0 {
+		skippedText = strings.Join(skipped, "; ")
+	}
+	return textResult(fmt.Sprintf("Linked Summary Results\n"+
+		"======================\n"+
+		"File: %s\n"+
+		"Included: %s\n"+
+		"Skipped: %s\n"+
+		"Model: %s\n\n"+
+		"%s", filename, strings.Join(names, ", "), skippedText, result.Model, resultText(result))), nil
+}
+
+// collectLinkedFiles reads filename and, breadth first, the local files its
+// Markdown links lead to, up to maxDepth links away. Files are included
+// until maxBytes of text is used, the last one cut to fit. Each file is
+// read once however many times it is linked, which also stops cycles.
+// skipped describes the links that were not followed; truncated reports
+// whether any content was cut off.
+func (s *Server) collectLinkedFiles(filename string, maxDepth, maxBytes int) (files []linkedFile, skipped []string, truncated bool, err error) {
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	visited := map[string]bool{filePath: true}
+	queue := []linkedFile{{Name: filename}}
+	remaining := maxBytes
+
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if remaining == 0 {
+			skipped = append(skipped, file.Name+" (size limit reached)")
+			truncated = true
+			continue
+		}
+
+		text, err := s.readTextFile(file.Name)
+		if err != nil {
+			if file.Depth == 0 {
+				return nil, nil, false, err
+			}
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", file.Name, err))
+			continue
+		}
+
+		// Links are read from the whole file, even when it is cut below
+		if file.Depth < maxDepth && isMarkdown(file.Name) {
+			for _, target := range markdownLinks(text) {
+				name, ok := localLink(file.Name, target)
+				if !ok {
+					continue
+				}
+				linkedPath, err := s.resolveFile(name)
+				if err != nil {
+					skipped = append(skipped, fmt.Sprintf("%s (%v)", name, err))
+					continue
+				}
+				if visited[linkedPath] {
+					continue
+				}
+				visited[linkedPath] = true
+				queue = append(queue, linkedFile{Name: name, Depth: file.Depth + 1})
+			}
+		}
+
+		if len(text) > remaining {
+			cut := remaining
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "\n[... cut off at the size limit]"
+			truncated = true
+			remaining = 0
+		} else {
+			remaining -= len(text)
+		}
+		file.Text = text
+		files = append(files, file)
+	}
+	return files, skipped, truncated, nil
+}
+
+// markdownLinks returns the targets of the links in a Markdown document, in
+// order of appearance.
+func markdownLinks(text string) []string {
+	var targets []string
+	for _, m := range markdownLink.FindAllStringSubmatch(text, -1) {
+		if m[1] != "" {
+			targets = append(targets, m[1])
+		} else {
+			targets = append(targets, m[2])
+		}
+	}
+	return targets
+}
+
+// localLink resolves a link target found in the file from to a file name
+// relative to the files directory. It reports false for links that do not
+// name a local file: URLs, absolute paths and links within the page.
+func localLink(from, target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return "", false
+	}
+	return path.Join(path.Dir(filepath.ToSlash(from)), u.Path), true
+}
+
+// isMarkdown reports whether filename has a Markdown extension.
+func isMarkdown(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".md" || ext == ".markdown"
+}
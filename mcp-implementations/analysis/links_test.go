@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var linkedDocs = map[string]string{
+	"README.md": "# Project\n\nStart with the [guide](docs/guide.md), see [the site](https://example.com) " +
+		"or [below](#usage).\n\n## Usage\n\nRun it.\n",
+	"docs/guide.md": "# Guide\n\nBack to the [readme](../README.md). The [API](./api.md \"API reference\") " +
+		"lists the endpoints, and [the guide](guide.md) is this page.\n",
+	"docs/api.md": "# API\n\n[home]: ../README.md\n\nGET /items\n",
+}
+
+func TestSummarizeWithLinksFollowsLocalLinks(t *testing.T) {
+	s := newTestServer(t, Config{}, linkedDocs)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_with_links", map[string]any{"filename": "README.md"})
+
+	if !strings.Contains(text, "Included: README.md, docs/guide.md, docs/api.md\n") || !strings.Contains(text, "Skipped: none\n") {
+		t.Errorf("unexpected included files:\n%s", text)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d sampling requests, want one holistic summary", len(requests))
+	}
+	content := messageText(requests[0])
+	for _, name := range []string{"README.md", "docs/guide.md", "docs/api.md"} {
+		// The cycles back to the readme and the self link add no copies
+		if n := strings.Count(content, "=== "+name+" ===\n"); n != 1 {
+			t.Errorf("%s appears %d times in the prompt, want once", name, n)
+		}
+	}
+	if !strings.Contains(content, "GET /items") {
+		t.Errorf("the linked file's content is missing:\n%s", content)
+	}
+	if !strings.Contains(requests[0].SystemPrompt, "holistic summary") {
+		t.Errorf("unexpected system prompt: %q", requests[0].SystemPrompt)
+	}
+}
+
+func TestSummarizeWithLinksRespectsDepth(t *testing.T) {
+	s := newTestServer(t, Config{}, linkedDocs)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for depth, want := range []string{"README.md", "README.md, docs/guide.md", "README.md, docs/guide.md, docs/api.md"} {
+		_, text := mustSucceed(t, c, "summarize_with_links", map[string]any{"filename": "README.md", "max_depth": depth})
+		if !strings.Contains(text, "Included: "+want+"\n") {
+			t.Errorf("max_depth %d: want %s included:\n%s", depth, want, text)
+		}
+	}
+}
+
+func TestSummarizeWithLinksStaysInFilesDir(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"index.md":  "[outside](../secret.md), [missing](missing.md) and [notes](notes.txt)",
+		"notes.txt": "Plain notes with a [link](index.md) that is not followed.",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_with_links", map[string]any{"filename": "index.md"})
+
+	if !strings.Contains(text, "Included: index.md, notes.txt\n") {
+		t.Errorf("unexpected included files:\n%s", text)
+	}
+	for _, want := range []string{"../secret.md (", "missing.md ("} {
+		if !strings.Contains(text, want) {
+			t.Errorf("skipped links do not list %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestSummarizeWithLinksRespectsSizeLimit(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"index.md": "See [one](one.md) and [two](two.md).\n",
+		"one.md":   strings.Repeat("one ", 50),
+		"two.md":   "two",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_with_links", map[string]any{"filename": "index.md", "max_bytes": 100})
+
+	if !strings.Contains(text, "Skipped: two.md (size limit reached)") {
+		t.Errorf("the file past the limit is not reported:\n%s", text)
+	}
+	request := sampler.Requests()[0]
+	if !strings.Contains(messageText(request), "[... cut off at the size limit]") {
+		t.Errorf("the file at the limit is not cut:\n%s", messageText(request))
+	}
+	if !strings.Contains(request.SystemPrompt, "cut off to fit the size limit") {
+		t.Errorf("system prompt does not mention the cut: %q", request.SystemPrompt)
+	}
+}
+
+func TestSummarizeWithLinksValidatesInput(t *testing.T) {
+	s := newTestServer(t, Config{}, linkedDocs)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"filename": "notes.txt"}, "notes.txt is not a Markdown file"},
+		{map[string]any{"filename": "README.md", "max_depth": -1}, "max_depth cannot be negative"},
+	} {
+		if text := mustFail(t, c, "summarize_with_links", tc.args); !strings.Contains(text, tc.want) {
+			t.Errorf("%v: unexpected error: %s", tc.args, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid input sent %d sampling requests", n)
+	}
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	text := "[a](one.md) ![img](pics/a.png \"A\") [b](<two.md>)\n[ref]: three.md\n  [ref2]: <four.md>\n"
+	if got := fmt.Sprint(markdownLinks(text)); got != "[one.md pics/a.png two.md three.md four.md]" {
+		t.Errorf("markdownLinks() = %s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Defaults for a RotatingFile opened by the -log-file flag.
+const (
+	DefaultLogMaxSize    = 100 << 20
+	DefaultLogMaxBackups = 3
+)
+
+// RotatingFile is an io.Writer that appends to a log file and rotates it
+// before it grows past MaxSize bytes: path becomes path.1, path.1 becomes
+// path.2 and so on, and backups beyond MaxBackups are deleted, so the logs
+// never take more than about (MaxBackups+1) * MaxSize of disk. A single
+// write larger than MaxSize still goes to one file.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed. A
+// maxSize of zero or less means DefaultLogMaxSize; maxBackups of zero keeps
+// no backups, so the file is simply started over.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultLogMaxSize
+	}
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: max(0, maxBackups)}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening log file: %v", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past MaxSize.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along, moves the current file to path.1 and
+// starts a new one.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("rotating log file: %v", err)
+	}
+
+	if f.maxBackups == 0 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(f.backup(f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			// Missing backups are expected until the first few rotations
+			os.Rename(f.backup(i), f.backup(i+1))
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			return fmt.Errorf("rotating log file: %v", err)
+		}
+	}
+	return f.open()
+}
+
+// backup returns the path of the nth most recent backup.
+func (f *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logLine returns line n of a test log, 38 bytes with its newline.
+func logLine(n int) string {
+	return fmt.Sprintf("2024-05-01 10:00:%02d request %04d done\n", n%60, n)
+}
+
+// readLog returns the contents of path, or "" when it does not exist.
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileRotatesAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	existing := strings.Repeat("x", 49) + "\n"
+	if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all strings.Builder
+	all.WriteString(existing)
+	for n := range 12 {
+		if _, err := f.Write([]byte(logLine(n))); err != nil {
+			t.Fatal(err)
+		}
+		all.WriteString(logLine(n))
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var kept string
+	for _, name := range []string{path + ".2", path + ".1", path} {
+		content := readLog(t, name)
+		if content == "" {
+			t.Fatalf("%s is missing or empty", filepath.Base(name))
+		}
+		if len(content) > 100 {
+			t.Errorf("%s is %d bytes, over the 100-byte limit", filepath.Base(name), len(content))
+		}
+		kept += content
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("a third backup was kept with max backups 2: %v", err)
+	}
+	// The backups and the current file hold the newest lines, in order
+	if !strings.HasSuffix(all.String(), kept) {
+		t.Errorf("kept logs are not the newest lines in order:\n%s", kept)
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	f, err := OpenRotatingFile(path, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range 5 {
+		f.Write([]byte(logLine(n)))
+	}
+	f.Close()
+
+	if got := readLog(t, path); got != logLine(4) {
+		t.Errorf("log = %q, want only the line after the last rotation", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want no backups", len(entries))
+	}
+}
+
+func TestRotatingFileKeepsLargeWriteWhole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	f, err := OpenRotatingFile(path, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := strings.Repeat("y", 250) + "\n"
+	f.Write([]byte(logLine(0)))
+	f.Write([]byte(large))
+	f.Close()
+
+	if got := readLog(t, path); got != large {
+		t.Errorf("log holds %d bytes, want the oversized write alone", len(got))
+	}
+	if got := readLog(t, path+".1"); got != logLine(0) {
+		t.Errorf("backup = %q, want the earlier line", got)
+	}
+}
+
+func TestOpenRotatingFileDefaults(t *testing.T) {
+	f, err := OpenRotatingFile(filepath.Join(t.TempDir(), "server.log"), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.maxSize != DefaultLogMaxSize || f.maxBackups != 0 {
+		t.Errorf("maxSize %d, maxBackups %d; want %d and 0", f.maxSize, f.maxBackups, DefaultLogMaxSize)
+	}
+
+	if _, err := OpenRotatingFile(filepath.Join(t.TempDir(), "missing", "server.log"), 100, 1); err == nil {
+		t.Error("opening a log file in a missing directory succeeded")
+	}
+}
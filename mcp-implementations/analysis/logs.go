@@ -0,0 +1,211 @@
+package analysis
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultLogClusters and DefaultLogSamples bound what summarize_logs sends
+// when a call does not set max_clusters or samples.
+const (
+	DefaultLogClusters = 50
+	DefaultLogSamples  = 2
+)
+
+// logSampleChars is how much of each sample line is sent.
+const logSampleChars = 300
+
+var summarizeLogsTool = mcp.Tool{
+	Name:        "summarize_logs",
+	Description: "Summarize a log file using LLM sampling. Similar lines are grouped into templates first, and only counts and a few samples of each are sent, so repetitive logs cost a fraction of the tokens",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The log file to summarize (relative to files directory)",
+			},
+			"max_clusters": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Most line templates to send, the most frequent first (default %d)", DefaultLogClusters),
+			},
+			"samples": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Example lines sent for each template (default %d)", DefaultLogSamples),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// logVariables replace the parts of a log line that vary between lines
+// printed by the same statement, in order, so those lines share a template.
+var logVariables = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`), "<TIME>"},
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b`), "<TIME>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<UUID>"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<IP>"},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]*\d[0-9a-f]*[a-f][0-9a-f]*\b`), "<HEX>"},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<STR>"},
+	// Units stay, so "30ms" and "2s" become "<NUM>ms" and "<NUM>s"
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?`), "<NUM>"},
+}
+
+// logCluster is a group of log lines that share a template. Lines are
+// 1-based line numbers.
+type logCluster struct {
+	Template  string
+	Count     int
+	FirstLine int
+	LastLine  int
+	Samples   []string
+}
+
+func (s *Server) handleSummarizeLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	maxClusters := request.GetInt("max_clusters", DefaultLogClusters)
+	samples := request.GetInt("samples", DefaultLogSamples)
+	if maxClusters < 1 || samples < 1 {
+		return errorResult("max_clusters and samples must be at least 1"), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	clusters, lines := clusterLogLines(text, samples)
+	if lines == 0 {
+		return errorResult("%s has no log lines", filename), nil
+	}
+	content, sent := formatLogClusters(clusters, lines, maxClusters, s.cfg.ChunkSize)
+	logf(ctx, "Grouped %d lines of %s into %d templates; sending %d of them (%d of %d bytes)",
+		lines, filename, len(clusters), sent, len(content), len(text))
+
+	systemPrompt := "The content describes a log file with similar lines grouped into templates, where <TIME>, <NUM>, <HEX>, <IP>, <UUID> and <STR> stand for the values that vary. " +
+		"Each template comes with how many lines matched it, where they appear and example lines. " +
+		"Summarize what the log shows: what the system was doing, errors and warnings with how often they happened, and anything unusual or worth investigating."
+
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: content}, systemPrompt)
+	samplingRequest.MaxTokens = 1500
+
+	logf(ctx, "📤 Sending sampling request to summarize logs: %s", filename)
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+	logf(ctx, "✅ Log summary successful! Model: %s", result.Model)
+
+	var top strings.Builder
+	for _, cluster := range clusters[:min(10, len(clusters))] {
+		fmt.Fprintf(&top, "%7d  %s\n", cluster.Count, cluster.Template)
+	}
+	return textResult(fmt.Sprintf("Log Summary Results\n"+
+		"===================\n"+
+		"File: %s\n"+
+		"Lines: %d in %d templates (%d sent to the model, %d of %d bytes)\n"+
+		"Model: %s\n\n"+
+		"Most frequent templates:\n%s\n"+
+		"%s", filename, lines, len(clusters), sent, len(content), len(text), result.Model, top.String(), resultText(result))), nil
+}
+
+// logTemplate reduces a log line to its template.
+func logTemplate(line string) string {
+	for _, v := range logVariables {
+		line = v.re.ReplaceAllString(line, v.placeholder)
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// clusterLogLines groups the non-blank lines of text by template, keeping
+// up to samples example lines of each, and returns the clusters from most
+// to least frequent with the number of lines read.
+func clusterLogLines(text string, samples int) ([]*logCluster, int) {
+	byTemplate := map[string]*logCluster{}
+	var clusters []*logCluster
+	var lines int
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines++
+		template := logTemplate(line)
+		cluster, ok := byTemplate[template]
+		if !ok {
+			cluster = &logCluster{Template: template, FirstLine: i + 1}
+			byTemplate[template] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Count++
+		cluster.LastLine = i + 1
+		if len(cluster.Samples) < samples {
+			cluster.Samples = append(cluster.Samples, truncateLogLine(strings.TrimSpace(line)))
+		}
+	}
+
+	// Stable, so equally frequent templates stay in order of appearance
+	slices.SortStableFunc(clusters, func(a, b *logCluster) int { return cmp.Compare(b.Count, a.Count) })
+	return clusters, lines
+}
+
+// formatLogClusters writes up to maxClusters clusters as the prompt
+// content, stopping early rather than exceed limit bytes, and returns it
+// with the number of clusters included.
+func formatLogClusters(clusters []*logCluster, lines, maxClusters, limit int) (string, int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d log lines in %d templates.\n\n", lines, len(clusters))
+
+	sent := 0
+	for _, cluster := range clusters[:min(maxClusters, len(clusters))] {
+		var entry strings.Builder
+		fmt.Fprintf(&entry, "[count %d, lines %d-%d] %s\n", cluster.Count, cluster.FirstLine, cluster.LastLine, cluster.Template)
+		for _, sample := range cluster.Samples {
+			fmt.Fprintf(&entry, "  e.g. %s\n", sample)
+		}
+		if b.Len()+entry.Len() > limit && sent > 0 {
+			break
+		}
+		b.WriteString(entry.String())
+		sent++
+	}
+
+	if rest := clusters[sent:]; len(rest) > 0 {
+		var restLines int
+		for _, cluster := range rest {
+			restLines += cluster.Count
+		}
+		fmt.Fprintf(&b, "\n%d less frequent templates covering %d lines are not shown.\n", len(rest), restLines)
+	}
+	return b.String(), sent
+}
+
+// truncateLogLine shortens a sample line to logSampleChars bytes.
+func truncateLogLine(line string) string {
+	if len(line) <= logSampleChars {
+		return line
+	}
+	cut := logSampleChars
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "..."
+}
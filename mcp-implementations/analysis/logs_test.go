@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// repetitiveLog returns n log lines printed by three statements: 60% info,
+// 30% warnings and 10% errors.
+func repetitiveLog(n int) string {
+	var b strings.Builder
+	for i := range n {
+		timestamp := fmt.Sprintf("2024-05-01T10:%02d:%02dZ", i/60%60, i%60)
+		switch i % 10 {
+		case 0, 1, 2, 3, 4, 5:
+			fmt.Fprintf(&b, "%s INFO GET /items/%d 200 %dms from 10.0.0.%d\n", timestamp, i, i%90+5, i%250)
+		case 6, 7, 8:
+			fmt.Fprintf(&b, "%s WARN cache miss for key 0x%x\n", timestamp, i*7919)
+		default:
+			fmt.Fprintf(&b, "%s ERROR payment \"order-%d\" failed after %dms\n", timestamp, i, i%3000)
+		}
+	}
+	return b.String()
+}
+
+func TestLogTemplate(t *testing.T) {
+	tests := map[string]string{
+		"2024-05-01T10:15:07.123Z INFO GET /items/42 200 13ms from 10.0.0.7:8080": "<TIME> INFO GET /items/<NUM> <NUM> <NUM>ms from <IP>",
+		"10:15:07 WARN  cache miss for key 0x1f3a":                                "<TIME> WARN cache miss for key <HEX>",
+		`ERROR user 'bob' session 3f2a9c1e-1b2c-4d5e-8f90-123456789abc expired`:   "ERROR user <STR> session <UUID> expired",
+		"commit 9fceb02 pushed by \"alice\" in 2.5s":                              "commit <HEX> pushed by <STR> in <NUM>s",
+	}
+	for line, want := range tests {
+		if got := logTemplate(line); got != want {
+			t.Errorf("logTemplate(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestSummarizeLogsSendsClustersNotLines(t *testing.T) {
+	log := repetitiveLog(1000)
+	s := newTestServer(t, Config{}, map[string]string{"app.log": log})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "summarize_logs", map[string]any{"filename": "app.log"})
+
+	sent := messageText(sampler.Requests()[0])
+	if len(sent)*20 > len(log) {
+		t.Errorf("sent %d bytes for a %d-byte log; clustering should cut it by far more", len(sent), len(log))
+	}
+	for _, want := range []string{
+		"1000 log lines in 3 templates.",
+		"[count 600, lines 1-996] <TIME> INFO GET /items/<NUM> <NUM> <NUM>ms from <IP>\n  e.g. 2024-05-01T10:00:00Z INFO GET /items/0 200 5ms from 10.0.0.0\n",
+		"[count 300, lines 7-999] <TIME> WARN cache miss for key <HEX>",
+		"[count 100, lines 10-1000] <TIME> ERROR payment <STR> failed after <NUM>ms",
+	} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("prompt content is missing %q:\n%s", want, sent)
+		}
+	}
+	if n := strings.Count(sent, "  e.g. "); n != 3*DefaultLogSamples {
+		t.Errorf("sent %d sample lines, want %d per template", n, DefaultLogSamples)
+	}
+
+	if !strings.Contains(text, fmt.Sprintf("Lines: 1000 in 3 templates (3 sent to the model, %d of %d bytes)", len(sent), len(log))) {
+		t.Errorf("result does not report the reduction:\n%s", text)
+	}
+	if !strings.Contains(text, "    600  <TIME> INFO") || !strings.HasSuffix(text, mockAnswer) {
+		t.Errorf("result is missing the top templates or the summary:\n%s", text)
+	}
+}
+
+func TestSummarizeLogsBoundsClustersAndSamples(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"app.log": repetitiveLog(100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "summarize_logs", map[string]any{"filename": "app.log", "max_clusters": 1, "samples": 1})
+
+	sent := messageText(sampler.Requests()[0])
+	if strings.Contains(sent, "WARN") || strings.Contains(sent, "ERROR") {
+		t.Errorf("templates beyond max_clusters were sent:\n%s", sent)
+	}
+	if !strings.Contains(sent, "2 less frequent templates covering 40 lines are not shown.") {
+		t.Errorf("left out templates are not counted:\n%s", sent)
+	}
+	if n := strings.Count(sent, "  e.g. "); n != 1 {
+		t.Errorf("sent %d sample lines, want 1", n)
+	}
+}
+
+func TestSummarizeLogsStaysWithinChunkSize(t *testing.T) {
+	// Every line is its own template, so only the chunk size bounds the prompt
+	var b strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&b, "event%s happened\n", strings.Repeat("x", i))
+	}
+	s := newTestServer(t, Config{ChunkSize: 2000}, map[string]string{"unique.log": b.String()})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "summarize_logs", map[string]any{"filename": "unique.log", "max_clusters": 200})
+
+	sent := messageText(sampler.Requests()[0])
+	if !strings.Contains(sent, "less frequent templates covering") {
+		t.Errorf("templates past the chunk size were not left out:\n%s", sent)
+	}
+	if len(sent) > 2200 {
+		t.Errorf("sent %d bytes with a chunk size of 2000", len(sent))
+	}
+}
+
+func TestSummarizeLogsRejectsInvalidRequests(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"app.log": repetitiveLog(10), "empty.log": "\n  \n"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"filename": "empty.log"}, "empty.log has no log lines"},
+		{map[string]any{"filename": "app.log", "samples": 0}, "max_clusters and samples must be at least 1"},
+		{map[string]any{"filename": "app.log", "max_clusters": 0}, "max_clusters and samples must be at least 1"},
+	} {
+		if text := mustFail(t, c, "summarize_logs", tc.args); !strings.Contains(text, tc.want) {
+			t.Errorf("%v: unexpected error: %s", tc.args, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid requests sent %d sampling requests", n)
+	}
+}
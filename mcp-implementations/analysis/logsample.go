@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultSlowRequestThreshold is how long a tool call may take before it is
+// logged as slow when Config leaves SlowRequestThreshold unset.
+const DefaultSlowRequestThreshold = 30 * time.Second
+
+// quietLogKey marks a tool call whose routine logs are sampled out.
+type quietLogKey struct{}
+
+// withLogSampling is tool middleware that keeps the routine logs of only
+// one in LogSampleRate tool calls. A sampled-out call that fails still logs
+// its error, and any call slower than SlowRequestThreshold is logged.
+func (s *Server) withLogSampling(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		n := s.toolCalls.Add(1)
+		quiet := s.cfg.LogSampleRate > 1 && (n-1)%uint64(s.cfg.LogSampleRate) != 0
+		if quiet {
+			ctx = context.WithValue(ctx, quietLogKey{}, true)
+		}
+
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		if elapsed >= s.cfg.SlowRequestThreshold {
+			log.Printf("🐢 Slow request: %s took %v", request.Params.Name, elapsed)
+		}
+		if quiet {
+			switch {
+			case err != nil:
+				log.Printf("❌ %s failed: %v", request.Params.Name, err)
+			case result != nil && result.IsError:
+				log.Printf("❌ %s failed: %s", request.Params.Name, resultSummary(result))
+			}
+		}
+		return result, err
+	}
+}
+
+// logf logs a routine, per-request message unless log sampling dropped the
+// request ctx belongs to. Errors and warnings use log.Printf directly so
+// they are never sampled out, and neither is a call made with debug.
+func logf(ctx context.Context, format string, args ...any) {
+	if quiet, _ := ctx.Value(quietLogKey{}).(bool); quiet && !debugging(ctx) {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// resultSummary returns the first line of a tool result's text, for logs.
+func resultSummary(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			line, _, _ := strings.Cut(text.Text, "\n")
+			return line
+		}
+	}
+	return "error result"
+}
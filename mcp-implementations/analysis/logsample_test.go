@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// captureLogs collects log output for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+func TestLogSamplingKeepsOneInNSuccesses(t *testing.T) {
+	s := newTestServer(t, Config{LogSampleRate: 3}, map[string]string{"notes.txt": "Hello."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	for range 7 {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	}
+
+	// Calls 1, 4 and 7 are logged
+	if n := strings.Count(logs.String(), "📤 Sending sampling request for file: notes.txt"); n != 3 {
+		t.Errorf("logged %d of 7 calls at rate 3, want 3:\n%s", n, logs)
+	}
+}
+
+func TestLogSamplingDisabledLogsEveryCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Hello."})
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	for range 4 {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	}
+
+	if n := strings.Count(logs.String(), "📤 Sending sampling request for file: notes.txt"); n != 4 {
+		t.Errorf("logged %d of 4 calls without sampling", n)
+	}
+}
+
+func TestLogSamplingAlwaysLogsFailures(t *testing.T) {
+	s := newTestServer(t, Config{LogSampleRate: 100}, nil)
+	c := connect(t, s, &mockSampler{})
+	logs := captureLogs(t)
+
+	for range 3 {
+		mustFail(t, c, "analyze_file", map[string]any{"filename": "missing.txt"})
+	}
+
+	// The first call is sampled in and logs as usual; the others are quiet
+	// but still report their failure
+	if n := strings.Count(logs.String(), "❌ analyze_file failed: "); n != 2 {
+		t.Errorf("logged %d failures of sampled-out calls, want 2:\n%s", n, logs)
+	}
+}
+
+func TestLogSamplingAlwaysLogsSlowRequests(t *testing.T) {
+	s := newTestServer(t, Config{LogSampleRate: 100, SlowRequestThreshold: 10 * time.Millisecond}, map[string]string{"notes.txt": "Hello."})
+	c := connect(t, s, &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		time.Sleep(20 * time.Millisecond)
+		return textAnswer(mockAnswer), nil
+	}})
+	logs := captureLogs(t)
+
+	for range 3 {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	}
+
+	if n := strings.Count(logs.String(), "🐢 Slow request: analyze_file took"); n != 3 {
+		t.Errorf("logged %d of 3 slow requests:\n%s", n, logs)
+	}
+	if n := strings.Count(logs.String(), "📤 Sending sampling request"); n != 1 {
+		t.Errorf("routine logs of %d calls kept at rate 100, want 1", n)
+	}
+}
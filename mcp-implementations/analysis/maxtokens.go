@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultMaxContinuations is how many follow-up requests AutoContinue
+// sends for one answer when Config leaves the limit unset.
+const DefaultMaxContinuations = 2
+
+// MaxTokensNote is appended to an answer the model stopped writing
+// because it reached the request's token limit.
+const MaxTokensNote = "[truncated: increase max_tokens]"
+
+// maxTokensStopReasons are the stop reasons with which the MCP spec,
+// Anthropic and OpenAI report that an answer reached max_tokens.
+var maxTokensStopReasons = []string{"maxTokens", "max_tokens", "length"}
+
+// continuePrompt asks the model to carry on from where it was cut off.
+const continuePrompt = "Your answer was cut off by the length limit. Continue exactly where it stopped, " +
+	"without repeating anything or adding any introduction."
+
+// stoppedAtMaxTokens reports whether the model stopped because it reached
+// the token limit rather than because it finished.
+func stoppedAtMaxTokens(result *mcp.CreateMessageResult) bool {
+	return slices.Contains(maxTokensStopReasons, result.StopReason)
+}
+
+// structuredAnswerKey marks a context whose answers are parsed as JSON,
+// where MaxTokensNote would only corrupt them.
+type structuredAnswerKey struct{}
+
+// maxTokensKey carries the flag withMaxTokensMeta reports.
+type maxTokensKey struct{}
+
+// withMaxTokensMeta sets "max_tokens_reached" in the _meta of a tool
+// result built from an answer that was still cut off at max_tokens, so
+// clients of the JSON tools, whose answers carry no MaxTokensNote, can
+// tell it is incomplete.
+func withMaxTokensMeta(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var reached atomic.Bool
+		result, err := next(context.WithValue(ctx, maxTokensKey{}, &reached), request)
+		if err != nil || result == nil || result.IsError || !reached.Load() {
+			return result, err
+		}
+
+		if result.Meta == nil {
+			result.Meta = mcp.NewMetaFromMap(map[string]any{})
+		}
+		result.Meta.AdditionalFields["max_tokens_reached"] = true
+		return result, nil
+	}
+}
+
+// recordMaxTokens notes for withMaxTokensMeta that the answer a tool used
+// was cut off at max_tokens.
+func recordMaxTokens(ctx context.Context) {
+	if reached, ok := ctx.Value(maxTokensKey{}).(*atomic.Bool); ok {
+		reached.Store(true)
+	}
+}
+
+// completeTruncated handles an answer cut off at max_tokens. With
+// AutoContinue it asks the model for the rest, up to MaxContinuations
+// times, and joins the parts. If the answer is still cut off, MaxTokensNote
+// is appended so no tool presents it as complete, unless the answer is to
+// be parsed as JSON; sampleJSON reports those through withMaxTokensMeta
+// instead. Either way the analysis it belongs to is not cached. Answers
+// that are not text are returned unchanged.
+func (s *Server) completeTruncated(ctx context.Context, request mcp.CreateMessageRequest, result *mcp.CreateMessageResult) *mcp.CreateMessageResult {
+	first, ok := result.Content.(mcp.TextContent)
+	if !ok {
+		return result
+	}
+	answer := first.Text
+	last := result
+
+	for n := 1; s.cfg.AutoContinue && n <= s.cfg.MaxContinuations && stoppedAtMaxTokens(last); n++ {
+		followUp := request
+		followUp.Messages = append(slices.Clone(request.Messages),
+			mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.TextContent{Type: "text", Text: answer}},
+			mcp.SamplingMessage{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: continuePrompt}},
+		)
+
+		logf(ctx, "↪️  Answer reached max_tokens, requesting continuation %d of %d", n, s.cfg.MaxContinuations)
+		next, err := s.sendSampling(ctx, followUp)
+		if err != nil {
+			log.Printf("❌ Continuation request failed: %v", err)
+			break
+		}
+		text, ok := next.Content.(mcp.TextContent)
+		if !ok {
+			break
+		}
+		answer += text.Text
+		last = next
+	}
+
+	if stoppedAtMaxTokens(last) {
+		log.Printf("⚠️  Answer reached max_tokens (%d) and is incomplete", request.MaxTokens)
+		markUncacheable(ctx)
+		if structured, _ := ctx.Value(structuredAnswerKey{}).(bool); !structured {
+			answer += "\n\n" + MaxTokensNote
+		}
+	}
+
+	completed := *result
+	completed.Content = mcp.TextContent{Type: "text", Text: answer}
+	completed.StopReason = last.StopReason
+	return &completed
+}
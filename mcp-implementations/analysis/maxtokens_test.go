@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// cutAnswers returns a respond function giving each text in turn, the
+// first cut of them stopped at max_tokens, and repeating the last text
+// once they run out.
+func cutAnswers(cut int, texts ...string) func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	var mu sync.Mutex
+	next := 0
+	return func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result := textAnswer(texts[min(next, len(texts)-1)])
+		if next < cut {
+			result.StopReason = "maxTokens"
+		}
+		next++
+		return result, nil
+	}
+}
+
+func TestMaxTokensAnswerGetsNote(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: cutAnswers(1, "The notes cover")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if !strings.HasSuffix(text, "The notes cover\n\n"+MaxTokensNote) {
+		t.Errorf("cut-off answer is not marked:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("%d sampling requests without AutoContinue, want 1", n)
+	}
+}
+
+func TestMaxTokensAnswerIsNotReusedByResponseCache(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: cutAnswers(1, "The notes cover", "The notes cover everything.")}
+	c := connect(t, s, sampler)
+
+	// debug_raw skips the result cache, leaving only the response cache
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "debug_raw": true})
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "debug_raw": true})
+
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want the cut-off answer sampled again", n)
+	}
+	if !strings.Contains(text, "The notes cover everything.") || strings.Contains(text, MaxTokensNote) {
+		t.Errorf("second call did not get the complete answer:\n%s", text)
+	}
+}
+
+func TestMaxTokensAutoContinue(t *testing.T) {
+	s := newTestServer(t, Config{AutoContinue: true}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: cutAnswers(2, "Part one, ", "part two, ", "part three.")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if !strings.HasSuffix(text, "Part one, part two, part three.") || strings.Contains(text, MaxTokensNote) {
+		t.Errorf("continuations were not joined into a complete answer:\n%s", text)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("%d sampling requests, want the first and two continuations", len(requests))
+	}
+	last := requests[2].Messages
+	if len(last) != len(requests[0].Messages)+2 {
+		t.Fatalf("continuation has %d messages, want the original plus the answer so far and the follow-up", len(last))
+	}
+	answer, prompt := last[len(last)-2], last[len(last)-1]
+	if answer.Role != mcp.RoleAssistant || answer.Content.(mcp.TextContent).Text != "Part one, part two, " {
+		t.Errorf("continuation does not carry the answer so far: %+v", answer)
+	}
+	if prompt.Role != mcp.RoleUser || prompt.Content.(mcp.TextContent).Text != continuePrompt {
+		t.Errorf("continuation does not ask the model to go on: %+v", prompt)
+	}
+}
+
+func TestMaxTokensAutoContinueIsBounded(t *testing.T) {
+	s := newTestServer(t, Config{AutoContinue: true, MaxContinuations: 1}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: cutAnswers(10, "Part one, ", "part two, ")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want one continuation at most", n)
+	}
+	if !strings.HasSuffix(text, "Part one, part two, \n\n"+MaxTokensNote) {
+		t.Errorf("an answer still cut off after the last continuation is not marked:\n%s", text)
+	}
+}
+
+func TestMaxTokensAnswerIsNotCached(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: cutAnswers(1, "The notes cover", "The notes cover everything.")}
+	c := connect(t, s, sampler)
+
+	// use_cache is left at its default, so both caches are in play
+	_, first := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	_, second := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if !strings.HasSuffix(first, "The notes cover\n\n"+MaxTokensNote) {
+		t.Errorf("the first answer is not the cut-off one:\n%s", first)
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want the cut-off answer never served from a cache", n)
+	}
+	if strings.Contains(second, MaxTokensNote) || !strings.Contains(second, "The notes cover everything.") {
+		t.Errorf("the second call did not sample the complete answer:\n%s", second)
+	}
+}
+
+func TestMaxTokensJSONAnswerIsFlaggedInMeta(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study."})
+	c := connect(t, s, &mockSampler{respond: cutAnswers(1, `{"tags": ["go"]}`)})
+
+	result, text := mustSucceed(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": 1})
+
+	if strings.Contains(text, MaxTokensNote) {
+		t.Errorf("the note was added to a JSON answer:\n%s", text)
+	}
+	if result.Meta == nil || result.Meta.AdditionalFields["max_tokens_reached"] != true {
+		t.Errorf("cut-off JSON answer is not flagged in _meta: %+v", result.Meta)
+	}
+
+	complete, _ := mustSucceed(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": 1, "use_cache": false})
+	if complete.Meta != nil && complete.Meta.AdditionalFields["max_tokens_reached"] != nil {
+		t.Errorf("a complete answer is flagged: %v", complete.Meta.AdditionalFields)
+	}
+}
+
+func TestStoppedAtMaxTokens(t *testing.T) {
+	for reason, want := range map[string]bool{"maxTokens": true, "max_tokens": true, "length": true, "endTurn": false, "stop": false, "": false} {
+		result := textAnswer("answer")
+		result.StopReason = reason
+		if got := stoppedAtMaxTokens(result); got != want {
+			t.Errorf("stoppedAtMaxTokens(%q) = %t, want %t", reason, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxMergeSummaries bounds how many summaries merge_summaries combines in
+// one request.
+const MaxMergeSummaries = 50
+
+var mergeSummariesTool = mcp.Tool{
+	Name:        "merge_summaries",
+	Description: "Merge several summaries produced elsewhere into one coherent summary without repetition using LLM sampling. This is the combine step of chunked analysis as a standalone tool",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"summaries": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": fmt.Sprintf("The summaries to merge, in order (at least 1, at most %d)", MaxMergeSummaries),
+			},
+			"instructions": map[string]any{
+				"type":        "string",
+				"description": "How to combine them (default: merge overlapping points without repeating them)",
+			},
+			"target_length": map[string]any{
+				"type":        "string",
+				"description": "The merged summary's length, e.g. \"100 words\" or \"5 sentences\"",
+			},
+			"audience":        audienceProperty,
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"summaries"},
+	},
+}
+
+func (s *Server) handleMergeSummaries(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	summaries := request.GetStringSlice("summaries", nil)
+	if len(summaries) == 0 {
+		return errorResult("summaries is empty; pass at least one summary to merge"), nil
+	}
+	if len(summaries) > MaxMergeSummaries {
+		return errorResult("%d summaries is more than the limit of %d; merge them in groups", len(summaries), MaxMergeSummaries), nil
+	}
+	total := 0
+	for i, summary := range summaries {
+		if strings.TrimSpace(summary) == "" {
+			return errorResult("summary %d is empty", i+1), nil
+		}
+		total += len(summary)
+	}
+	// Every summary has to be represented, so none is truncated
+	if total > s.cfg.ChunkSize {
+		return errorResult("The summaries total %d bytes, more than the %d bytes that fit in one request; merge them in groups", total, s.cfg.ChunkSize), nil
+	}
+
+	instructions := request.GetString("instructions", "")
+	if instructions == "" {
+		instructions = "Merge overlapping points without repeating them."
+	}
+	targetLength := request.GetString("target_length", "")
+	if targetLength != "" {
+		if _, err := parseTargetLength(targetLength); err != nil {
+			return errorResult("%v", err), nil
+		}
+	}
+
+	systemPrompt, err := withAudience(fmt.Sprintf("The content is %d summaries of related material, produced separately. "+
+		"Write one coherent summary that covers every point made in any of them. %s "+
+		"Where they disagree, say so rather than picking one. Respond with only the merged summary.",
+		len(summaries), instructions), request.GetString("audience", ""))
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	parts := make([]string, len(summaries))
+	for i, summary := range summaries {
+		parts[i] = fmt.Sprintf("Summary %d of %d:\n%s", i+1, len(summaries), strings.TrimSpace(summary))
+	}
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: strings.Join(parts, "\n\n")}, systemPrompt)
+	samplingRequest.MaxTokens = 2000
+
+	logf(ctx, "📤 Sending sampling request to merge %d summaries", len(summaries))
+	result, err := s.sampleToLength(ctx, samplingRequest, targetLength, s.requestSampling)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+	logf(ctx, "✅ Merged %d summaries. Model: %s", len(summaries), result.Model)
+
+	return textResult(fmt.Sprintf("Merged Summary\n"+
+		"==============\n"+
+		"Summaries: %d\n"+
+		"Model: %s\n\n"+
+		"%s", len(summaries), result.Model, resultText(result))), nil
+}
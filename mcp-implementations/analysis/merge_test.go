@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMergeSummariesSendsEverySummary(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+	summaries := []string{
+		"Sales rose 10% in Q1.",
+		"  Q1 sales grew by a tenth; churn fell.\n",
+		"Churn fell to 2%. A new region opened.",
+	}
+
+	_, text := mustSucceed(t, c, "merge_summaries", map[string]any{"summaries": summaries})
+
+	sent := messageText(sampler.Requests()[0])
+	for i, summary := range summaries {
+		if want := fmt.Sprintf("Summary %d of 3:\n%s", i+1, strings.TrimSpace(summary)); !strings.Contains(sent, want) {
+			t.Errorf("summary %d is missing from the prompt:\n%s", i+1, sent)
+		}
+	}
+	prompt := sampler.Requests()[0].SystemPrompt
+	if !strings.Contains(prompt, "The content is 3 summaries") || !strings.Contains(prompt, "Merge overlapping points without repeating them.") {
+		t.Errorf("prompt does not describe the default merge: %s", prompt)
+	}
+	if !strings.Contains(text, "Summaries: 3\nModel: mock-model\n\n"+mockAnswer) {
+		t.Errorf("unexpected result:\n%s", text)
+	}
+}
+
+func TestMergeSummariesInstructionsAndAudience(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "merge_summaries", map[string]any{
+		"summaries":    []string{"One.", "Two."},
+		"instructions": "Keep the chronological order.",
+		"audience":     "executive",
+	})
+
+	prompt := sampler.Requests()[0].SystemPrompt
+	if !strings.Contains(prompt, "Keep the chronological order.") || strings.Contains(prompt, "Merge overlapping points") {
+		t.Errorf("instructions did not replace the default: %s", prompt)
+	}
+	if !strings.HasSuffix(prompt, "Write for a busy executive: lead with the bottom line, focus on impact, risks and decisions needed, and leave out implementation detail.") {
+		t.Errorf("prompt is not written for the audience: %s", prompt)
+	}
+}
+
+func TestMergeSummariesValidatesInput(t *testing.T) {
+	tests := []struct {
+		summaries []string
+		want      string
+	}{
+		{nil, "summaries is empty"},
+		{[]string{}, "summaries is empty"},
+		{[]string{"One.", " \n"}, "summary 2 is empty"},
+		{make([]string, MaxMergeSummaries+1), "51 summaries is more than the limit of 50"},
+		{[]string{strings.Repeat("a", 60), strings.Repeat("b", 60)}, "The summaries total 120 bytes, more than the 100 bytes"},
+	}
+	s := newTestServer(t, Config{ChunkSize: 100}, nil)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tt := range tests {
+		args := map[string]any{}
+		if tt.summaries != nil {
+			args["summaries"] = tt.summaries
+		}
+		if text := mustFail(t, c, "merge_summaries", args); !strings.Contains(text, tt.want) {
+			t.Errorf("%d summaries: error %q does not mention %q", len(tt.summaries), text, tt.want)
+		}
+	}
+	for args, want := range map[string]string{"target_length": `Invalid target_length "short"`, "audience": `Unknown audience "short"`} {
+		if text := mustFail(t, c, "merge_summaries", map[string]any{"summaries": []string{"One."}, args: "short"}); !strings.Contains(text, want) {
+			t.Errorf("%s: error %q does not mention %q", args, text, want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid input sent %d sampling requests", n)
+	}
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeFileRejectsFilesBelowMinimum(t *testing.T) {
+	s := newTestServer(t, Config{MinFileBytes: 10}, map[string]string{"tiny.txt": "hi"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "tiny.txt"})
+	if !strings.Contains(text, "tiny.txt is only 2 bytes, below the server's minimum of 10; pass force") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a rejected file still sent %d sampling requests", n)
+	}
+}
+
+func TestAnalyzeFileForceOverridesMinimum(t *testing.T) {
+	s := newTestServer(t, Config{MinFileBytes: 10}, map[string]string{"tiny.txt": "hi"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "tiny.txt", "force": true})
+	if !strings.Contains(text, mockAnswer) {
+		t.Errorf("forced analysis is missing the answer:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want 1", n)
+	}
+}
+
+func TestAnalyzeFileMinimumDisabledByDefault(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"tiny.txt": "hi"})
+	c := connect(t, s, &mockSampler{})
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "tiny.txt"})
+}
+
+func TestAnalyzeFileRejectsEmptyFileEvenWithForce(t *testing.T) {
+	s := newTestServer(t, Config{MinFileBytes: 10}, map[string]string{"empty.txt": ""})
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "empty.txt", "force": true})
+	if !strings.Contains(text, "empty.txt is empty") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
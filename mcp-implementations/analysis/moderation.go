@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ModerationDecision is the outcome of screening one piece of content.
+type ModerationDecision struct {
+	Flagged bool
+	Reason  string
+}
+
+// Moderator screens content before it is sent to the sampling client.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (ModerationDecision, error)
+}
+
+// PolicyError is returned when moderation refuses content.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("content refused by moderation policy: %s", e.Reason)
+}
+
+// BlocklistModerator flags content containing any of its terms, compared
+// case-insensitively.
+type BlocklistModerator struct {
+	Terms []string
+}
+
+// LoadBlocklist reads one term per line, skipping blank lines and lines
+// starting with '#'.
+func LoadBlocklist(path string) (*BlocklistModerator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var terms []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms = append(terms, strings.ToLower(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &BlocklistModerator{Terms: terms}, nil
+}
+
+// Moderate implements Moderator.
+func (b *BlocklistModerator) Moderate(ctx context.Context, text string) (ModerationDecision, error) {
+	lower := strings.ToLower(text)
+	for _, term := range b.Terms {
+		if strings.Contains(lower, strings.ToLower(term)) {
+			return ModerationDecision{Flagged: true, Reason: fmt.Sprintf("blocklisted term %q", term)}, nil
+		}
+	}
+	return ModerationDecision{}, nil
+}
+
+// OPENAI_MODERATION_URL is the OpenAI moderation endpoint.
+const OPENAI_MODERATION_URL = "https://api.openai.com/v1/moderations"
+
+// HTTPModerator calls an OpenAI-compatible moderation endpoint.
+type HTTPModerator struct {
+	URL        string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewHTTPModerator creates a moderator for the given endpoint.
+func NewHTTPModerator(url, apiKey string) *HTTPModerator {
+	return &HTTPModerator{
+		URL:    url,
+		APIKey: apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate implements Moderator.
+func (h *HTTPModerator) Moderate(ctx context.Context, text string) (ModerationDecision, error) {
+	reqBody, err := json.Marshal(map[string]string{"input": text})
+	if err != nil {
+		return ModerationDecision{}, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return ModerationDecision{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+h.APIKey)
+
+	resp, err := h.HTTPClient.Do(httpReq)
+	if err != nil {
+		return ModerationDecision{}, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return ModerationDecision{}, fmt.Errorf("moderation request failed with status %d", resp.StatusCode)
+	}
+
+	var modResp moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modResp); err != nil {
+		return ModerationDecision{}, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	for _, r := range modResp.Results {
+		if !r.Flagged {
+			continue
+		}
+		var categories []string
+		for name, hit := range r.Categories {
+			if hit {
+				categories = append(categories, name)
+			}
+		}
+		sort.Strings(categories)
+		return ModerationDecision{Flagged: true, Reason: "flagged for " + strings.Join(categories, ", ")}, nil
+	}
+	return ModerationDecision{}, nil
+}
+
+// ModeratorChain runs several moderators; the first to flag content wins.
+type ModeratorChain []Moderator
+
+// Moderate implements Moderator.
+func (c ModeratorChain) Moderate(ctx context.Context, text string) (ModerationDecision, error) {
+	for _, m := range c {
+		decision, err := m.Moderate(ctx, text)
+		if err != nil || decision.Flagged {
+			return decision, err
+		}
+	}
+	return ModerationDecision{}, nil
+}
+
+// moderate screens the text of a sampling request when moderation is
+// enabled. It fails closed: if the moderator itself errors, the request is
+// refused rather than sent unchecked.
+func (s *Server) moderate(ctx context.Context, request mcp.CreateMessageRequest) error {
+	if s.cfg.Moderator == nil {
+		return nil
+	}
+
+	var texts []string
+	for _, msg := range request.Messages {
+		if textContent, ok := msg.Content.(mcp.TextContent); ok {
+			texts = append(texts, textContent.Text)
+		}
+	}
+	if len(texts) == 0 {
+		// Images and other binary content are not screened
+		return nil
+	}
+
+	decision, err := s.cfg.Moderator.Moderate(ctx, strings.Join(texts, "\n"))
+	if err != nil {
+		log.Printf("🛡️  Moderation check failed, refusing request: %v", err)
+		return &PolicyError{Reason: fmt.Sprintf("moderation check failed: %v", err)}
+	}
+	if decision.Flagged {
+		log.Printf("🛡️  Moderation refused content: %s", decision.Reason)
+		return &PolicyError{Reason: decision.Reason}
+	}
+	log.Printf("🛡️  Moderation passed")
+	return nil
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModerationPassesCleanContent(t *testing.T) {
+	s := newTestServer(t, Config{Moderator: &BlocklistModerator{Terms: []string{"forbidden"}}},
+		map[string]string{"clean.txt": "A perfectly ordinary note."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "clean.txt"})
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("clean content sent %d sampling requests, want 1", n)
+	}
+}
+
+func TestModerationRefusesFlaggedContent(t *testing.T) {
+	s := newTestServer(t, Config{Moderator: &BlocklistModerator{Terms: []string{"forbidden"}}},
+		map[string]string{"flagged.txt": "This note mentions a FORBIDDEN topic."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "flagged.txt"})
+	if !strings.Contains(text, "content refused by moderation policy") || !strings.Contains(text, `"forbidden"`) {
+		t.Errorf("error is not a policy error naming the term: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("flagged content sent %d sampling requests, want none", n)
+	}
+}
+
+func TestModerationFailsClosed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	t.Cleanup(ts.Close)
+
+	s := newTestServer(t, Config{Moderator: NewHTTPModerator(ts.URL, "")}, map[string]string{"clean.txt": "Ordinary note."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "clean.txt"})
+	if !strings.Contains(text, "moderation check failed") {
+		t.Errorf("error does not report the failed check: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("unchecked content sent %d sampling requests, want none", n)
+	}
+}
+
+func TestHTTPModerator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer mod-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"results":[{"flagged":true,"categories":{"violence":true,"hate":true,"self-harm":false}}]}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	decision, err := NewHTTPModerator(ts.URL, "mod-key").Moderate(context.Background(), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Flagged || decision.Reason != "flagged for hate, violence" {
+		t.Errorf("decision %+v, want flagged for hate, violence", decision)
+	}
+}
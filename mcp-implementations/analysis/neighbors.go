@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxNeighbors bounds how many sibling files with_neighbors lists in the
+// prompt.
+const MaxNeighbors = 20
+
+// neighborSummaryChars is how much of a sibling's cached summary is quoted.
+const neighborSummaryChars = 200
+
+// neighborContext describes the other files in filename's directory, so
+// the model can place the file among them. With summaries, a sibling that
+// already has a cached summarize result is listed with the start of it;
+// nothing is sampled for siblings that have none. Only a local source
+// keeps files in real directories; other sources fetch each object into a
+// directory of its own, so for them it is an error.
+func (s *Server) neighborContext(ctx context.Context, filename, filePath string, summaries bool) (string, error) {
+	if _, ok := s.cfg.FileSource.(*LocalSource); !ok {
+		return "", fmt.Errorf("with_neighbors needs a local files directory; %s has no directories to read", s.cfg.FileSource)
+	}
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		return "", nil
+	}
+
+	var lines []string
+	var more int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || name == filepath.Base(filePath) {
+			continue
+		}
+		if len(lines) == MaxNeighbors {
+			more++
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		line := fmt.Sprintf("- %s (%d bytes, %s)", name, info.Size(), mimeTypeFor(name))
+		if summaries {
+			if summary, ok := s.cachedSummary(ctx, filepath.Join(filepath.Dir(filename), name), filepath.Join(filepath.Dir(filePath), name)); ok {
+				line += ": " + summary
+			}
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	logf(ctx, "Added %d neighboring files of %s to the prompt", len(lines), filename)
+	if more > 0 {
+		lines = append(lines, fmt.Sprintf("- ... and %d more", more))
+	}
+
+	return " For context only, the file sits in a directory with these other files; use them to place the file, but analyze only this file:\n" +
+		strings.Join(lines, "\n") + "\n", nil
+}
+
+// cachedSummary returns the start of the cached result of a plain
+// summarize analysis of filename, one made with no other arguments.
+func (s *Server) cachedSummary(ctx context.Context, filename, filePath string) (string, bool) {
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return "", false
+	}
+	opts := analyzeOptionsFrom(mcp.CallToolRequest{}, "summarize")
+	opts.Filename = filename
+	key, err := cacheKey(ctx, hash, opts)
+	if err != nil {
+		return "", false
+	}
+	text, ok := s.cachedAnalysis(key)
+	if !ok {
+		return "", false
+	}
+
+	_, summary := analysisAnswer(text)
+	summary = strings.Join(strings.Fields(summary), " ")
+	if len(summary) > neighborSummaryChars {
+		cut := neighborSummaryChars
+		for cut > 0 && !utf8.RuneStart(summary[cut]) {
+			cut--
+		}
+		summary = summary[:cut] + "..."
+	}
+	return summary, summary != ""
+}
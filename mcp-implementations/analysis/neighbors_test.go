@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var neighborFiles = map[string]string{
+	"docs/guide.md":   "# Guide\n\nHow to use the tool.",
+	"docs/install.md": "# Install\n\nRun the installer.",
+	"docs/api.json":   `{"endpoints": []}`,
+	"docs/.draft.md":  "Not ready.",
+	"src/main.go":     "package main",
+}
+
+// promptLine returns the line of prompt starting with prefix, or "".
+func promptLine(prompt, prefix string) string {
+	for line := range strings.Lines(prompt) {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSuffix(line, "\n")
+		}
+	}
+	return ""
+}
+
+func TestWithNeighborsNamesSiblingFiles(t *testing.T) {
+	s := newTestServer(t, Config{}, neighborFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "docs/guide.md", "with_neighbors": true})
+
+	prompt := sampler.Requests()[0].SystemPrompt
+	for _, want := range []string{"- install.md (", "- api.json (17 bytes, application/json)", "analyze only this file"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("system prompt is missing %q: %q", want, prompt)
+		}
+	}
+	for _, unwanted := range []string{"- guide.md", ".draft.md", "main.go"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Errorf("system prompt lists %q, which is not a visible sibling: %q", unwanted, prompt)
+		}
+	}
+}
+
+func TestWithoutNeighborsPromptHasNoSiblings(t *testing.T) {
+	s := newTestServer(t, Config{}, neighborFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "docs/guide.md"})
+
+	if prompt := sampler.Requests()[0].SystemPrompt; strings.Contains(prompt, "install.md") {
+		t.Errorf("siblings were listed without with_neighbors: %q", prompt)
+	}
+}
+
+func TestWithNeighborsIsBounded(t *testing.T) {
+	files := map[string]string{"target.txt": "The file to analyze."}
+	for i := range MaxNeighbors + 3 {
+		files[fmt.Sprintf("sibling%02d.txt", i)] = "A sibling."
+	}
+	s := newTestServer(t, Config{}, files)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "target.txt", "with_neighbors": true})
+
+	prompt := sampler.Requests()[0].SystemPrompt
+	if n := strings.Count(prompt, "- sibling"); n != MaxNeighbors {
+		t.Errorf("prompt names %d siblings, want %d", n, MaxNeighbors)
+	}
+	if !strings.Contains(prompt, "- ... and 3 more") {
+		t.Errorf("prompt does not count the siblings left out: %q", prompt)
+	}
+}
+
+func TestNeighborSummariesQuoteCachedSummaries(t *testing.T) {
+	s := newTestServer(t, Config{}, neighborFiles)
+	sampler := &mockSampler{respond: answers("Install explains how to run the installer.", mockAnswer)}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "docs/install.md"})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "docs/guide.md", "with_neighbors": true, "neighbor_summaries": true})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want no extra sampling for neighbors", len(requests))
+	}
+	prompt := requests[1].SystemPrompt
+	if line := promptLine(prompt, "- install.md ("); !strings.HasSuffix(line, "): Install explains how to run the installer.") {
+		t.Errorf("the cached summary of install.md is not quoted: %q", line)
+	}
+	if line := promptLine(prompt, "- api.json ("); strings.Contains(line, ":") {
+		t.Errorf("a neighbor without a cached summary got one: %q", line)
+	}
+}
+
+func TestNeighborSummariesNeedWithNeighbors(t *testing.T) {
+	s := newTestServer(t, Config{}, neighborFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "docs/guide.md", "neighbor_summaries": true})
+	if !strings.Contains(text, "neighbor_summaries only applies with with_neighbors") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid input sent %d sampling requests", n)
+	}
+}
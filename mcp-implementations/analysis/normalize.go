@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// normalizeText converts text file bytes into clean UTF-8 with "\n" line
+// endings. It strips byte order marks, decodes UTF-16 (with or without a
+// BOM) and falls back to Latin-1 for bytes that are not valid UTF-8. The
+// detected source encoding is returned for logging.
+func normalizeText(data []byte) (string, string) {
+	var text, encoding string
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text, encoding = string(data[3:]), "UTF-8 with BOM"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		text, encoding = decodeUTF16(data[2:], binary.LittleEndian), "UTF-16LE"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		text, encoding = decodeUTF16(data[2:], binary.BigEndian), "UTF-16BE"
+	default:
+		// BOM-less UTF-16 is checked first: its NUL bytes are valid UTF-8
+		if order, ok := guessUTF16(data); ok {
+			text, encoding = decodeUTF16(data, order), "UTF-16 (no BOM)"
+		} else if utf8.Valid(data) {
+			text, encoding = string(data), "UTF-8"
+		} else {
+			text, encoding = decodeLatin1(data), "Latin-1"
+		}
+	}
+
+	return normalizeLineEndings(text), encoding
+}
+
+// normalizeLineEndings turns Windows and classic Mac line endings into "\n".
+func normalizeLineEndings(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// guessUTF16 detects BOM-less UTF-16 from the NUL bytes that mostly-ASCII
+// text leaves in every other position.
+func guessUTF16(data []byte) (binary.ByteOrder, bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return nil, false
+	}
+
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	pairs := len(data) / 2
+	switch {
+	case oddZeros > pairs*3/4 && evenZeros < pairs/10:
+		return binary.LittleEndian, true
+	case evenZeros > pairs*3/4 && oddZeros < pairs/10:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// utf16Fixture encodes text as UTF-16 in order, with a BOM when bom is set.
+func utf16Fixture(text string, order binary.AppendByteOrder, bom bool) []byte {
+	var data []byte
+	if bom {
+		data = order.AppendUint16(data, 0xFEFF)
+	}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		data = order.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func TestNormalizeText(t *testing.T) {
+	const want = "Café notes\nline two\nline three\n"
+	const crlf = "Café notes\r\nline two\rline three\r\n"
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"UTF-8", []byte(crlf), "UTF-8"},
+		{"UTF-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, crlf...), "UTF-8 with BOM"},
+		{"UTF-16LE", utf16Fixture(crlf, binary.LittleEndian, true), "UTF-16LE"},
+		{"UTF-16BE", utf16Fixture(crlf, binary.BigEndian, true), "UTF-16BE"},
+		{"UTF-16 without BOM", utf16Fixture(crlf, binary.LittleEndian, false), "UTF-16 (no BOM)"},
+		{"Latin-1", []byte("Caf\xe9 notes\r\nline two\rline three\r\n"), "Latin-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, encoding := normalizeText(tt.data)
+			if text != want {
+				t.Errorf("normalizeText = %q, want %q", text, want)
+			}
+			if encoding != tt.encoding {
+				t.Errorf("encoding = %q, want %q", encoding, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFileSendsCleanUTF8(t *testing.T) {
+	fixture := utf16Fixture("Résumé of the meeting\r\nSecond line\r\n", binary.LittleEndian, true)
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": string(fixture)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	text := messageText(sampler.Requests()[0])
+	if !utf8.ValidString(text) || strings.ContainsAny(text, "\x00\r\ufeff") {
+		t.Errorf("request text is not clean UTF-8: %q", text)
+	}
+	if !strings.Contains(text, "Résumé of the meeting\nSecond line") {
+		t.Errorf("request text does not carry the decoded file: %q", text)
+	}
+}
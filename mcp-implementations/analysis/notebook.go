@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// notebookPrompt tells the model how notebookContent laid out the notebook.
+const notebookPrompt = " The content is a Jupyter notebook's cells in order, each under a label such as [markdown], [code] or [output]."
+
+// notebook is the part of the .ipynb format the server reads.
+type notebook struct {
+	Metadata struct {
+		KernelSpec struct {
+			DisplayName string `json:"display_name"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string           `json:"cell_type"`
+		Source   notebookText     `json:"source"`
+		Outputs  []notebookOutput `json:"outputs"`
+	} `json:"cells"`
+}
+
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	EName      string                  `json:"ename"`
+	EValue     string                  `json:"evalue"`
+}
+
+// notebookText is a multiline string, which notebooks store either as one
+// string or as a list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	// Non-text output data, such as a JSON widget, is skipped
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*t = notebookText(s)
+	}
+	return nil
+}
+
+// isNotebook reports whether a file is a Jupyter notebook.
+func isNotebook(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".ipynb"
+}
+
+// notebookContent turns a notebook's JSON into its markdown and code cells
+// in order, dropping the metadata, execution counts and other boilerplate.
+// Cell outputs are included as text when includeOutputs is set; rich
+// outputs such as images are noted but not included.
+func notebookContent(data []byte, includeOutputs bool) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return "", fmt.Errorf("not a valid notebook: %v", err)
+	}
+	if len(nb.Cells) == 0 {
+		return "", fmt.Errorf("the notebook has no cells")
+	}
+
+	var b strings.Builder
+	if kernel := nb.Metadata.KernelSpec.DisplayName; kernel != "" {
+		fmt.Fprintf(&b, "Kernel: %s\n\n", kernel)
+	}
+	for _, cell := range nb.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "[%s]\n%s\n\n", cell.CellType, source)
+
+		if !includeOutputs || cell.CellType != "code" {
+			continue
+		}
+		for _, output := range cell.Outputs {
+			if text := outputText(output); text != "" {
+				fmt.Fprintf(&b, "[output]\n%s\n\n", strings.TrimRight(text, "\n"))
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
+
+// outputText returns the text of one code cell output.
+func outputText(output notebookOutput) string {
+	switch output.OutputType {
+	case "stream":
+		return string(output.Text)
+	case "error":
+		return fmt.Sprintf("%s: %s", output.EName, output.EValue)
+	case "execute_result", "display_data":
+		if text, ok := output.Data["text/plain"]; ok {
+			return string(text)
+		}
+		if len(output.Data) > 0 {
+			return fmt.Sprintf("(%s output not shown)", slices.Sorted(maps.Keys(output.Data))[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const notebookFixture = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Loading data\n", "We read the CSV."]},
+  {"cell_type": "code", "execution_count": 3, "metadata": {"collapsed": false}, "source": "import pandas as pd\ndf = pd.read_csv('data.csv')",
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["loaded 42 rows\n"]}]},
+  {"cell_type": "code", "execution_count": 4, "metadata": {}, "source": [],  "outputs": []},
+  {"cell_type": "code", "execution_count": 5, "metadata": {}, "source": ["df.plot()"],
+   "outputs": [
+    {"output_type": "display_data", "data": {"image/png": "iVBORw0KGgo="}, "metadata": {}},
+    {"output_type": "error", "ename": "KeyError", "evalue": "'price'", "traceback": []}
+   ]}
+ ],
+ "metadata": {"kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+func TestAnalyzeFileSendsNotebookCells(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"explore.ipynb": notebookFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "explore.ipynb"})
+
+	request := sampler.Requests()[0]
+	sent := messageText(request)
+	want := "Kernel: Python 3\n\n" +
+		"[markdown]\n# Loading data\nWe read the CSV.\n\n" +
+		"[code]\nimport pandas as pd\ndf = pd.read_csv('data.csv')\n\n" +
+		"[code]\ndf.plot()\n"
+	if sent != want {
+		t.Errorf("notebook sent as:\n%s\nwant:\n%s", sent, want)
+	}
+	for _, boilerplate := range []string{"execution_count", "nbformat", "metadata", "{"} {
+		if strings.Contains(sent, boilerplate) {
+			t.Errorf("JSON boilerplate %q reached the prompt", boilerplate)
+		}
+	}
+	if !strings.Contains(request.SystemPrompt, "Jupyter notebook's cells in order") {
+		t.Errorf("system prompt does not explain the cell labels: %q", request.SystemPrompt)
+	}
+}
+
+func TestAnalyzeFileIncludesNotebookOutputs(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"explore.ipynb": notebookFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "explore.ipynb", "include_outputs": true})
+
+	sent := messageText(sampler.Requests()[0])
+	for _, want := range []string{"[output]\nloaded 42 rows\n", "[output]\n(image/png output not shown)\n", "[output]\nKeyError: 'price'"} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, sent)
+		}
+	}
+	if strings.Contains(sent, "iVBORw0KGgo=") {
+		t.Error("image data reached the prompt")
+	}
+}
+
+func TestAnalyzeFileRejectsInvalidNotebook(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"broken.ipynb": `{"cells": [`, "blank.ipynb": `{"cells": []}`})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "analyze_file", map[string]any{"filename": "broken.ipynb"}); !strings.Contains(text, "broken.ipynb: not a valid notebook") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "analyze_file", map[string]any{"filename": "blank.ipynb"}); !strings.Contains(text, "blank.ipynb: the notebook has no cells") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid notebooks sent %d sampling requests", n)
+	}
+}
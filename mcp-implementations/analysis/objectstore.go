@@ -0,0 +1,250 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrObjectNotFound is returned by an ObjectStore for a key with no object.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes one object in a bucket.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// ObjectStore is the part of an S3-compatible bucket the server reads.
+type ObjectStore interface {
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Stat describes the object at key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Get returns the content of the object at key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// objectTimeout bounds each call an ObjectSource makes to its store.
+const objectTimeout = 5 * time.Minute
+
+// DefaultMaxObjectBytes is the largest object an ObjectSource downloads
+// when its MaxBytes is unset.
+const DefaultMaxObjectBytes = 100 << 20 // 100 MiB
+
+// errObjectTooLarge is returned when an object is larger than the
+// source's download limit.
+var errObjectTooLarge = errors.New("object exceeds the download size limit")
+
+// ObjectSource is a FileSource reading from a bucket. Only keys under its
+// prefix are reachable: a filename is the rest of the key after the
+// prefix, and names with ".." are refused rather than cleaned. Objects are
+// downloaded into a local directory, where they are kept and reused until
+// their ETag changes.
+type ObjectSource struct {
+	store    ObjectStore
+	prefix   string
+	cacheDir string
+
+	// MaxBytes caps the size of a downloaded object, so one large object
+	// cannot fill the disk; 0 means DefaultMaxObjectBytes
+	MaxBytes int64
+
+	// mu serializes downloads, so two calls never write the same file
+	mu      sync.Mutex
+	fetched map[string]string // key to the ETag of its downloaded copy
+}
+
+// NewObjectSource creates a source over the objects in store whose keys
+// start with prefix, a "directory" such as "reports/" or "" for the whole
+// bucket. Downloaded objects are kept in cacheDir.
+func NewObjectSource(store ObjectStore, prefix, cacheDir string) (*ObjectSource, error) {
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return nil, err
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &ObjectSource{store: store, prefix: prefix, cacheDir: cacheDir, fetched: map[string]string{}}, nil
+}
+
+// String implements FileSource. It names the store, when the store can
+// describe itself, and the prefix.
+func (o *ObjectSource) String() string {
+	if store, ok := o.store.(fmt.Stringer); ok {
+		return store.String() + "/" + o.prefix
+	}
+	return fmt.Sprintf("object store prefix %q", o.prefix)
+}
+
+// key maps a filename to its object key, refusing names that would leave
+// the prefix.
+func (o *ObjectSource) key(filename string) (string, error) {
+	name := filepath.ToSlash(filename)
+	if path.IsAbs(name) || slices.Contains(strings.Split(name, "/"), "..") {
+		return "", fmt.Errorf("Access denied: File must be within the files directory")
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", fmt.Errorf("File not found: %s", filename)
+	}
+	return o.prefix + name, nil
+}
+
+// Resolve implements FileSource. The object is downloaded unless the copy
+// from an earlier call has the same ETag.
+func (o *ObjectSource) Resolve(filename string) (string, error) {
+	key, err := o.key(filename)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), objectTimeout)
+	defer cancel()
+
+	info, err := o.store.Stat(ctx, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return "", fmt.Errorf("File not found: %s", filename)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error reading %s from the object store: %v", filename, err)
+	}
+	if info.Size > o.maxBytes() {
+		return "", fmt.Errorf("Error reading %s from the object store: %v (%d bytes, limit %d)", filename, errObjectTooLarge, info.Size, o.maxBytes())
+	}
+
+	// Each key gets its own directory, so the copy keeps the object's
+	// base name and with it the extension that picks its MIME type
+	sum := sha256.Sum256([]byte(key))
+	localPath := filepath.Join(o.cacheDir, hex.EncodeToString(sum[:8]), path.Base(key))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.fetched[key] == info.ETag {
+		if _, err := os.Stat(localPath); err == nil {
+			return localPath, nil
+		}
+	}
+	if err := o.download(ctx, key, localPath); err != nil {
+		return "", fmt.Errorf("Error reading %s from the object store: %v", filename, err)
+	}
+	o.fetched[key] = info.ETag
+	return localPath, nil
+}
+
+// maxBytes returns the download limit, MaxBytes or its default.
+func (o *ObjectSource) maxBytes() int64 {
+	if o.MaxBytes <= 0 {
+		return DefaultMaxObjectBytes
+	}
+	return o.MaxBytes
+}
+
+// download writes the object at key to localPath, through a temporary
+// file so a failed download never leaves a partial copy. The size from
+// Stat is not trusted: a body longer than the limit fails the download.
+func (o *ObjectSource) download(ctx context.Context, key, localPath string) error {
+	body, err := o.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(body, o.maxBytes()+1))
+	if err == nil && n > o.maxBytes() {
+		err = fmt.Errorf("%w (limit %d bytes)", errObjectTooLarge, o.maxBytes())
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), localPath)
+}
+
+// names lists the prefix and returns each object's filename and size.
+// Keys ending in "/", which some tools create as directory markers, are
+// skipped.
+func (o *ObjectSource) names() ([]ObjectInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), objectTimeout)
+	defer cancel()
+
+	objects, err := o.store.List(ctx, o.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the object store: %v", err)
+	}
+	var files []ObjectInfo
+	for _, object := range objects {
+		name, ok := strings.CutPrefix(object.Key, o.prefix)
+		if !ok || name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		object.Key = name
+		files = append(files, object)
+	}
+	return files, nil
+}
+
+// List implements FileSource. Like a directory's top level, it leaves out
+// objects under deeper "/"-separated prefixes, which are still reachable
+// by name.
+func (o *ObjectSource) List() ([]SourceFile, error) {
+	objects, err := o.names()
+	if err != nil {
+		return nil, err
+	}
+	var files []SourceFile
+	for _, object := range objects {
+		if strings.Contains(object.Key, "/") {
+			continue
+		}
+		files = append(files, SourceFile{Name: object.Key, Size: object.Size, MIMEType: mimeTypeFor(object.Key)})
+	}
+	return files, nil
+}
+
+// Glob implements FileSource. As with files, "*" does not match "/", so
+// "*.md" matches only the top level and "reports/*.md" one level down.
+func (o *ObjectSource) Glob(pattern string) ([]string, error) {
+	objects, err := o.names()
+	if err != nil {
+		return nil, err
+	}
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	var names []string
+	for _, object := range objects {
+		if strings.HasPrefix(path.Base(object.Key), ".") {
+			continue
+		}
+		matched, err := path.Match(pattern, object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		if matched {
+			names = append(names, object.Key)
+		}
+	}
+	return names, nil
+}
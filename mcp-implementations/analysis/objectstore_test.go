@@ -0,0 +1,405 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeObject is one object in a fakeStore.
+type fakeObject struct {
+	content string
+	etag    string
+}
+
+// fakeStore is an in-memory ObjectStore that records lookups and
+// downloads.
+type fakeStore struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	stats   []string
+	gets    []string
+}
+
+func newFakeStore(objects map[string]string) *fakeStore {
+	st := &fakeStore{objects: map[string]fakeObject{}}
+	for key, content := range objects {
+		st.put(key, content, "v1")
+	}
+	return st
+}
+
+func (st *fakeStore) put(key, content, etag string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.objects[key] = fakeObject{content: content, etag: etag}
+}
+
+func (st *fakeStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var objects []ObjectInfo
+	for key, object := range st.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectInfo{Key: key, Size: int64(len(object.content)), ETag: object.etag})
+		}
+	}
+	slices.SortFunc(objects, func(a, b ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	return objects, nil
+}
+
+func (st *fakeStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.stats = append(st.stats, key)
+	object, ok := st.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	return ObjectInfo{Key: key, Size: int64(len(object.content)), ETag: object.etag}, nil
+}
+
+func (st *fakeStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.gets = append(st.gets, key)
+	object, ok := st.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return io.NopCloser(strings.NewReader(object.content)), nil
+}
+
+func (st *fakeStore) Stats() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return slices.Clone(st.stats)
+}
+
+func (st *fakeStore) Gets() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return slices.Clone(st.gets)
+}
+
+func (st *fakeStore) String() string { return "s3://fake-bucket" }
+
+// bucket holds reports/ for the source to serve and objects around it
+// that must stay out of reach.
+var bucket = map[string]string{
+	"reports/q1.md":         "# Q1\nSales rose.",
+	"reports/q2.md":         "# Q2\nSales fell.",
+	"reports/notes.txt":     "Plain notes.",
+	"reports/.hidden.md":    "Hidden.",
+	"reports/archive/":      "",
+	"reports/archive/q4.md": "# Q4\nOld.",
+	"secret.txt":            "Outside the prefix.",
+	"reports-old/q1.md":     "A sibling prefix.",
+}
+
+// objectServer serves bucket through an ObjectSource over reports/.
+func objectServer(t *testing.T) (*Server, *fakeStore) {
+	store := newFakeStore(bucket)
+	source, err := NewObjectSource(store, "/reports/", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServer(t, Config{FileSource: source}, nil), store
+}
+
+func TestObjectSourceAnalyzeFile(t *testing.T) {
+	s, store := objectServer(t)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "q1.md"})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "archive/q4.md"})
+
+	requests := sampler.Requests()
+	if sent := messageText(requests[0]); !strings.Contains(sent, "Sales rose.") {
+		t.Errorf("the object's content was not sent:\n%s", sent)
+	}
+	if sent := messageText(requests[1]); !strings.Contains(sent, "Old.") {
+		t.Errorf("an object under a deeper prefix was not read:\n%s", sent)
+	}
+	if got := fmt.Sprint(store.Gets()); got != "[reports/q1.md reports/archive/q4.md]" {
+		t.Errorf("downloaded %s", got)
+	}
+}
+
+func TestObjectSourceBatchFetchesEachFileOnce(t *testing.T) {
+	s, store := objectServer(t)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_batch", map[string]any{"filenames": []string{"q1.md", "q2.md"}})
+
+	if !strings.Contains(text, "Files: 2 (0 failed") || len(sampler.Requests()) != 2 {
+		t.Fatalf("the batch did not analyze both objects:\n%s", text)
+	}
+	// Deduplication reads each file; the analysis must reuse that copy
+	if got := fmt.Sprint(store.Stats()); got != "[reports/q1.md reports/q2.md]" {
+		t.Errorf("looked up %s, want each object once", got)
+	}
+	if got := fmt.Sprint(store.Gets()); got != "[reports/q1.md reports/q2.md]" {
+		t.Errorf("downloaded %s, want each object once", got)
+	}
+}
+
+func TestObjectSourceStaysWithinPrefix(t *testing.T) {
+	s, store := objectServer(t)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	tests := map[string]string{
+		"../secret.txt":            "Access denied",
+		"archive/../../secret.txt": "Access denied",
+		"/secret.txt":              "Access denied",
+		"secret.txt":               "File not found: secret.txt",
+		"../reports-old/q1.md":     "Access denied",
+		"q3.md":                    "File not found: q3.md",
+	}
+	for filename, want := range tests {
+		if text := mustFail(t, c, "analyze_file", map[string]any{"filename": filename}); !strings.Contains(text, want) {
+			t.Errorf("%s: error %q does not mention %q", filename, text, want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 || len(store.Gets()) != 0 {
+		t.Errorf("refused files sent %d sampling requests and %d downloads", n, len(store.Gets()))
+	}
+}
+
+func TestObjectSourceListFiles(t *testing.T) {
+	s, _ := objectServer(t)
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "list_files", nil)
+
+	if !strings.HasPrefix(text, "Available files in s3://fake-bucket/reports/:") {
+		t.Errorf("listing does not name the bucket and prefix:\n%s", text)
+	}
+	for _, want := range []string{"- notes.txt (12 bytes, text/plain", "- q1.md (", "- q2.md ("} {
+		if !strings.Contains(text, want) {
+			t.Errorf("listing is missing %q:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"q4.md", "archive", "secret", "reports-old"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("listing has %q, which is not at the top level of the prefix:\n%s", unwanted, text)
+		}
+	}
+}
+
+func TestObjectSourceGlob(t *testing.T) {
+	source, err := NewObjectSource(newFakeStore(bucket), "reports", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"*.md":         "[q1.md q2.md]",
+		"archive/*.md": "[archive/q4.md]",
+		"*":            "[notes.txt q1.md q2.md]",
+	}
+	for pattern, want := range tests {
+		names, err := source.Glob(pattern)
+		if err != nil || fmt.Sprint(names) != want {
+			t.Errorf("Glob(%q) = %v, %v; want %s", pattern, names, err, want)
+		}
+	}
+	if _, err := source.Glob("["); err == nil {
+		t.Error("a malformed pattern was accepted")
+	}
+}
+
+func TestObjectSourceReusesDownloadsUntilETagChanges(t *testing.T) {
+	store := newFakeStore(bucket)
+	source, err := NewObjectSource(store, "reports/", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := source.Resolve("q1.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Resolve("q1.md"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.Gets()); n != 1 {
+		t.Errorf("%d downloads of an unchanged object, want 1", n)
+	}
+	if !strings.HasSuffix(first, "q1.md") {
+		t.Errorf("local copy %s does not keep the object's name", first)
+	}
+
+	store.put("reports/q1.md", "# Q1\nSales rose again.", "v2")
+	again, err := source.Resolve("q1.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(again)
+	if n := len(store.Gets()); n != 2 || string(data) != "# Q1\nSales rose again." {
+		t.Errorf("after the ETag changed: %d downloads, content %q", n, data)
+	}
+}
+
+// understatedStore reports every object as one byte long, as a store
+// whose listing is out of date might, and counts the bytes read from the
+// bodies it serves.
+type understatedStore struct {
+	*fakeStore
+	read atomic.Int64
+}
+
+func (st *understatedStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := st.fakeStore.Stat(ctx, key)
+	info.Size = 1
+	return info, err
+}
+
+func (st *understatedStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := st.fakeStore.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(countingReader{body, &st.read}), nil
+}
+
+// countingReader adds the bytes read through it to n.
+type countingReader struct {
+	io.Reader
+	n *atomic.Int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+func TestObjectSourceLimitsDownloadSize(t *testing.T) {
+	understated := &understatedStore{fakeStore: newFakeStore(bucket)}
+	for name, store := range map[string]ObjectStore{
+		"stat":     newFakeStore(bucket),
+		"download": understated,
+	} {
+		dir := t.TempDir()
+		source, err := NewObjectSource(store, "reports/", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.MaxBytes = 12
+
+		if _, err := source.Resolve("notes.txt"); err != nil {
+			t.Errorf("%s: an object at the limit was refused: %v", name, err)
+		}
+		_, err = source.Resolve("q1.md")
+		if err == nil || !strings.Contains(err.Error(), errObjectTooLarge.Error()) {
+			t.Errorf("%s: an object over the limit was read: %v", name, err)
+		}
+		var files []string
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files = append(files, d.Name())
+			}
+			return nil
+		})
+		if !slices.Equal(files, []string{"notes.txt"}) {
+			t.Errorf("%s: cache holds %v, want only the object within the limit", name, files)
+		}
+	}
+	// notes.txt whole, and q1.md only to one byte past the limit
+	if n := understated.read.Load(); n > 12+13 {
+		t.Errorf("read %d bytes from the store, want the oversized download stopped at the limit", n)
+	}
+}
+
+func TestObjectSourceRejectsWithNeighbors(t *testing.T) {
+	s, _ := objectServer(t)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "q1.md", "with_neighbors": true})
+	if !strings.Contains(text, "with_neighbors needs a local files directory; s3://fake-bucket/reports/ has no directories to read") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+// s3Bucket serves a two-page ListObjectsV2 listing, one object and
+// 404s for anything else, recording the requests it gets.
+func s3Bucket(t *testing.T) (*httptest.Server, *[]*http.Request) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/bucket/" && r.URL.Query().Get("continuation-token") == "":
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>page2</NextContinuationToken>
+				<Contents><Key>reports/a.md</Key><Size>3</Size><ETag>"e1"</ETag></Contents></ListBucketResult>`)
+		case r.URL.Path == "/bucket/":
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>
+				<Contents><Key>reports/b.md</Key><Size>5</Size><ETag>"e2"</ETag></Contents></ListBucketResult>`)
+		case r.URL.Path == "/bucket/reports/a.md":
+			w.Header().Set("ETag", `"e1"`)
+			fmt.Fprint(w, "# A")
+		case r.URL.Path == "/bucket/denied.md":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestS3Client(t *testing.T) {
+	srv, requests := s3Bucket(t)
+	client := NewS3Client(S3Config{Endpoint: srv.URL + "/", Bucket: "bucket", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	ctx := context.Background()
+
+	objects, err := client.List(ctx, "reports/")
+	if err != nil || len(objects) != 2 || objects[0].Key != "reports/a.md" || objects[1].Size != 5 {
+		t.Errorf("List followed the continuation token to %+v, %v", objects, err)
+	}
+
+	info, err := client.Stat(ctx, "reports/a.md")
+	if err != nil || info.ETag != `"e1"` {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+	body, err := client.Get(ctx, "reports/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "# A" {
+		t.Errorf("Get = %q", data)
+	}
+
+	if _, err := client.Stat(ctx, "missing.md"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("a 404 returned %v, want ErrObjectNotFound", err)
+	}
+	if _, err := client.Get(ctx, "denied.md"); err == nil || !strings.Contains(err.Error(), "AccessDenied: Access Denied") {
+		t.Errorf("an S3 error returned %v, want its code and message", err)
+	}
+
+	for _, r := range *requests {
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("%s %s is not signed: %q", r.Method, r.URL, auth)
+		}
+	}
+}
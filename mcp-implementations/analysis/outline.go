@@ -0,0 +1,284 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var outlineTool = mcp.Tool{
+	Name:        "outline",
+	Description: "Produce a hierarchical table of contents of a document as nested JSON, reading Markdown headings directly and using LLM sampling for other files and section summaries",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to outline (relative to files directory)",
+			},
+			"from_headings": map[string]any{
+				"type":        "boolean",
+				"description": "For Markdown files, build the outline from # headings without sampling (default true)",
+			},
+			"summarize_sections": map[string]any{
+				"type":        "boolean",
+				"description": "Add a one-sentence summary to each heading read from Markdown (uses sampling; default false)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// OutlineEntry is one heading of a document outline. Children are the
+// headings nested under it, each at a deeper level.
+type OutlineEntry struct {
+	Level    int            `json:"level"`
+	Title    string         `json:"title"`
+	Summary  string         `json:"summary,omitempty"`
+	Children []OutlineEntry `json:"children,omitempty"`
+}
+
+// Outline is the structured result of the outline tool. Source is
+// "headings" when the outline was read from Markdown headings and "model"
+// when it was sampled.
+type Outline struct {
+	File    string         `json:"file"`
+	Source  string         `json:"source"`
+	Model   string         `json:"model,omitempty"`
+	Entries []OutlineEntry `json:"outline"`
+}
+
+// markdownHeading is a heading read from a Markdown document, with the text
+// up to the next heading.
+type markdownHeading struct {
+	Level int
+	Title string
+	Body  string
+}
+
+func (s *Server) handleOutline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	fromHeadings := request.GetBool("from_headings", true)
+	summarize := request.GetBool("summarize_sections", false)
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	outline := Outline{File: filename}
+	if fromHeadings && isMarkdown(filename) {
+		if headings := parseMarkdownHeadings(text); len(headings) > 0 {
+			var summaries []string
+			if summarize {
+				summaries, outline.Model, err = s.summarizeSections(ctx, filename, headings)
+				if err != nil {
+					return errorResult("%v", err), nil
+				}
+			}
+			outline.Source, outline.Entries = "headings", nestHeadings(headings, summaries)
+			logf(ctx, "✅ Read an outline of %d headings from %s", len(headings), filename)
+			return outlineResult(outline)
+		}
+	}
+
+	// Long documents are outlined from the part truncation keeps
+	excerpt, _ := truncateFor(ctx, text, s.cfg.ChunkSize)
+	content := mcp.TextContent{Type: "text", Text: excerpt}
+	systemPrompt := "Produce a hierarchical table of contents for this document, following its own structure. " +
+		`Respond with only a JSON object: {"outline": [{"level": 1, "title": "<section title>", "summary": "<one sentence>", "children": [...]}]}. ` +
+		"Top-level sections have level 1 and each child is exactly one level deeper than its parent. Use the document's headings as titles where it has them."
+
+	var entries []OutlineEntry
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 2000
+
+	logf(ctx, "📤 Sending sampling request to outline: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid outline", func(text string) (err error) {
+		entries, err = parseOutline(text)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	outline.Model = result.Model
+
+	logf(ctx, "✅ Outlined %s", filename)
+	outline.Source, outline.Entries = "model", entries
+	return outlineResult(outline)
+}
+
+// summarizeSections asks the model for a one-sentence summary of each
+// heading's section in a single request, and returns them in order with
+// the model that wrote them. Each section is trimmed so that together they
+// fit in one chunk.
+func (s *Server) summarizeSections(ctx context.Context, filename string, headings []markdownHeading) ([]string, string, error) {
+	budget := max(s.cfg.ChunkSize/len(headings), 200)
+	var b strings.Builder
+	for i, h := range headings {
+		body := strings.TrimSpace(h.Body)
+		if len(body) > budget {
+			cut := budget
+			for cut > 0 && !utf8.RuneStart(body[cut]) {
+				cut--
+			}
+			body = body[:cut] + "..."
+		}
+		fmt.Fprintf(&b, "<section number=\"%d\" title=%q>\n%s\n</section>\n", i+1, h.Title, body)
+	}
+
+	content := mcp.TextContent{Type: "text", Text: b.String()}
+	systemPrompt := fmt.Sprintf("These are the %d sections of '%s', in order. Summarize each in one sentence. "+
+		`Respond with only a JSON object: {"summaries": ["<section 1 summary>", ...]} with exactly one summary per section, in order. `+
+		"A section with no text of its own gets an empty string.", len(headings), filename)
+
+	var summaries []string
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 100 * len(headings)
+
+	logf(ctx, "📤 Sending sampling request to summarize %d sections of: %s", len(headings), filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid section summaries", func(text string) (err error) {
+		summaries, err = parseSummaries(text, len(headings))
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return summaries, result.Model, nil
+}
+
+// parseSummaries decodes the model's section summaries, requiring exactly
+// want of them.
+func parseSummaries(text string, want int) ([]string, error) {
+	var answer struct {
+		Summaries []string `json:"summaries"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if len(answer.Summaries) != want {
+		return nil, fmt.Errorf("got %d summaries for %d sections", len(answer.Summaries), want)
+	}
+	return answer.Summaries, nil
+}
+
+// parseOutline decodes and validates the model's outline.
+func parseOutline(text string) ([]OutlineEntry, error) {
+	var answer struct {
+		Outline []OutlineEntry `json:"outline"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if len(answer.Outline) == 0 {
+		return nil, fmt.Errorf("the outline is empty")
+	}
+	if err := validateOutline(answer.Outline, 0); err != nil {
+		return nil, err
+	}
+	return answer.Outline, nil
+}
+
+// validateOutline checks that every entry has a title and is deeper than
+// its parent at parentLevel.
+func validateOutline(entries []OutlineEntry, parentLevel int) error {
+	for _, e := range entries {
+		if strings.TrimSpace(e.Title) == "" {
+			return fmt.Errorf("an entry at level %d has no title", e.Level)
+		}
+		if e.Level <= parentLevel {
+			return fmt.Errorf("%q has level %d but is nested under level %d", e.Title, e.Level, parentLevel)
+		}
+		if err := validateOutline(e.Children, e.Level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outlineResult encodes an outline as the tool's JSON output.
+func outlineResult(outline Outline) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(outline, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding outline: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// atxHeading matches a Markdown "#" heading, without its optional closing
+// hashes.
+var atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// parseMarkdownHeadings reads the "#" headings of a Markdown document in
+// order, skipping frontmatter and fenced code blocks, where a leading "#"
+// is usually a comment.
+func parseMarkdownHeadings(text string) []markdownHeading {
+	_, body, _ := splitFrontmatter(text)
+
+	var headings []markdownHeading
+	var section strings.Builder
+	fence := ""
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			if m := atxHeading.FindStringSubmatch(line); m != nil && strings.TrimSpace(m[2]) != "" {
+				if len(headings) > 0 {
+					headings[len(headings)-1].Body = section.String()
+				}
+				section.Reset()
+				headings = append(headings, markdownHeading{Level: len(m[1]), Title: strings.TrimSpace(m[2])})
+				continue
+			}
+		}
+		section.WriteString(line + "\n")
+	}
+	if len(headings) > 0 {
+		headings[len(headings)-1].Body = section.String()
+	}
+	return headings
+}
+
+// nestHeadings turns a flat list of headings into a tree. A heading nests
+// under the closest earlier heading with a lower level, so skipped levels
+// (an h3 right after an h1) still nest. summaries, if not nil, holds one
+// summary per heading.
+func nestHeadings(headings []markdownHeading, summaries []string) []OutlineEntry {
+	var build func(i, parentLevel int) ([]OutlineEntry, int)
+	build = func(i, parentLevel int) ([]OutlineEntry, int) {
+		var entries []OutlineEntry
+		for i < len(headings) && headings[i].Level > parentLevel {
+			h := headings[i]
+			entry := OutlineEntry{Level: h.Level, Title: h.Title}
+			if summaries != nil {
+				entry.Summary = summaries[i]
+			}
+			entry.Children, i = build(i+1, h.Level)
+			entries = append(entries, entry)
+		}
+		return entries, i
+	}
+	entries, _ := build(0, 0)
+	return entries
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const outlineFixture = `---
+title: Guide
+---
+# Introduction
+Why this guide exists.
+
+## Goals
+What it covers.
+
+` + "```sh\n# not a heading\n```" + `
+
+### Details
+The finer points.
+
+# Usage ##
+How to use it.
+`
+
+func parseOutlineResult(t *testing.T, text string) Outline {
+	t.Helper()
+	var o Outline
+	if err := json.Unmarshal([]byte(text), &o); err != nil {
+		t.Fatalf("result is not an outline: %v\n%s", err, text)
+	}
+	return o
+}
+
+func TestOutlineFromMarkdownHeadings(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"guide.md": outlineFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "outline", map[string]any{"filename": "guide.md"})
+
+	got := parseOutlineResult(t, text)
+	want := []OutlineEntry{
+		{Level: 1, Title: "Introduction", Children: []OutlineEntry{
+			{Level: 2, Title: "Goals", Children: []OutlineEntry{{Level: 3, Title: "Details"}}},
+		}},
+		{Level: 1, Title: "Usage"},
+	}
+	if got.Source != "headings" || !reflect.DeepEqual(got.Entries, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a Markdown outline sent %d sampling requests", n)
+	}
+}
+
+func TestOutlineSummarizesSections(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"guide.md": outlineFixture})
+	sampler := &mockSampler{respond: answers(
+		`{"summaries": ["one"]}`,
+		`{"summaries": ["Why.", "What.", "Details.", "How."]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "outline", map[string]any{"filename": "guide.md", "summarize_sections": true})
+
+	got := parseOutlineResult(t, text)
+	if got.Model != "mock-model" || got.Entries[0].Summary != "Why." || got.Entries[0].Children[0].Children[0].Summary != "Details." || got.Entries[1].Summary != "How." {
+		t.Errorf("summaries are not attached to their headings: %+v", got)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, "got 1 summaries for 4 sections") {
+		t.Errorf("a wrong summary count was not reprompted: %d requests", len(requests))
+	}
+	if sections := messageText(requests[0]); !strings.Contains(sections, `<section number="3" title="Details">`) || strings.Contains(sections, "title: Guide") {
+		t.Errorf("unexpected sections sent:\n%s", sections)
+	}
+}
+
+func TestOutlineFromModel(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "INTRODUCTION\n...\nUSAGE\n..."})
+	sampler := &mockSampler{respond: answers(
+		`{"outline": [{"level": 1, "title": "Introduction", "children": [{"level": 1, "title": "Nested too shallow"}]}]}`,
+		`{"outline": [{"level": 1, "title": "Introduction", "summary": "Why."}, {"level": 1, "title": "Usage"}]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "outline", map[string]any{"filename": "notes.txt"})
+
+	got := parseOutlineResult(t, text)
+	if got.Source != "model" || len(got.Entries) != 2 || got.Entries[1].Title != "Usage" {
+		t.Errorf("unexpected outline: %+v", got)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, `"Nested too shallow" has level 1 but is nested under level 1`) {
+		t.Errorf("an invalid nesting was not reprompted: %d requests", len(requests))
+	}
+}
@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PartialNote is appended to a tool result built from an answer whose
+// stream was interrupted before the model finished it.
+const PartialNote = "[partial: the response stream was interrupted]"
+
+// partialResultKey carries the reason withPartialMeta reports.
+type partialResultKey struct{}
+
+// withPartialMeta marks a tool result built from a partial answer with
+// "partial" in its _meta, holding the reason the client gave, and appends
+// PartialNote to its text so no reader takes it as complete.
+func withPartialMeta(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var recorded atomic.Pointer[string]
+		result, err := next(context.WithValue(ctx, partialResultKey{}, &recorded), request)
+		reason := recorded.Load()
+		if err != nil || result == nil || result.IsError || reason == nil {
+			return result, err
+		}
+
+		if result.Meta == nil {
+			result.Meta = mcp.NewMetaFromMap(map[string]any{})
+		}
+		result.Meta.AdditionalFields["partial"] = map[string]any{"reason": *reason}
+		result.Content = append(result.Content, mcp.TextContent{Type: "text", Text: PartialNote})
+		return result, nil
+	}
+}
+
+// recordPartial notes for withPartialMeta that an answer the tool used was
+// cut off, and keeps the analysis it belongs to out of the cache.
+func recordPartial(ctx context.Context, reason string) {
+	if recorded, ok := ctx.Value(partialResultKey{}).(*atomic.Pointer[string]); ok {
+		recorded.Store(&reason)
+	}
+	markUncacheable(ctx)
+}
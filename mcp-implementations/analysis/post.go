@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// postPlatform is what generate_post knows about one social network.
+type postPlatform struct {
+	// MaxLength is the network's limit in characters, hashtags included
+	MaxLength   int
+	MaxHashtags int
+	Style       string
+}
+
+// postPlatforms are the networks generate_post writes for.
+var postPlatforms = map[string]postPlatform{
+	"twitter": {
+		MaxLength:   280,
+		MaxHashtags: 2,
+		Style:       "a post for X (Twitter): one punchy point or hook, plain and conversational, no preamble",
+	},
+	"linkedin": {
+		MaxLength:   3000,
+		MaxHashtags: 5,
+		Style: "a LinkedIn post: a strong opening line, then a few short paragraphs with the key takeaways, " +
+			"a professional but personal tone, ending with a question or call to action",
+	},
+}
+
+var generatePostTool = mcp.Tool{
+	Name:        "generate_post",
+	Description: "Write a social-media post about a text file using LLM sampling, within the platform's character limit, with optional hashtags",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to write about (relative to files directory)",
+			},
+			"platform": map[string]any{
+				"type":        "string",
+				"description": "The network to write for: twitter (280 characters) or linkedin (3000 characters)",
+				"enum":        []string{"twitter", "linkedin"},
+			},
+			"hashtags": map[string]any{
+				"type":        "boolean",
+				"description": "End the post with a few relevant hashtags, counted in the limit (default false)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename", "platform"},
+	},
+}
+
+// SocialPost is the structured result of generate_post. Post is the full
+// text to publish, hashtags included, and Length is its length in
+// characters.
+type SocialPost struct {
+	File      string   `json:"file"`
+	Model     string   `json:"model"`
+	Platform  string   `json:"platform"`
+	Post      string   `json:"post"`
+	Hashtags  []string `json:"hashtags,omitempty"`
+	Length    int      `json:"length"`
+	MaxLength int      `json:"max_length"`
+	// Truncated reports that the post covers only part of the file
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleGeneratePost(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	platformName, err := request.RequireString("platform")
+	if err != nil {
+		return nil, err
+	}
+	platformName = strings.ToLower(strings.TrimSpace(platformName))
+	platform, ok := postPlatforms[platformName]
+	if !ok {
+		return errorResult("unknown platform %q (use twitter or linkedin)", platformName), nil
+	}
+	withHashtags := request.GetBool("hashtags", false)
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	post := SocialPost{File: filename, Platform: platformName, MaxLength: platform.MaxLength}
+	text, post.Truncated = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := fmt.Sprintf("Write %s, sharing the most interesting point of this document. "+
+		"Do not invent facts that are not in the document. ", platform.Style)
+	if withHashtags {
+		systemPrompt += fmt.Sprintf("Also give 1 to %d relevant hashtags, which are added at the end of the post; "+
+			"the post and the hashtags together must be at most %d characters. "+
+			`Respond with only a JSON object: {"post": "<the post, without hashtags>", "hashtags": ["#example"]}.`,
+			platform.MaxHashtags, platform.MaxLength)
+	} else {
+		systemPrompt += fmt.Sprintf("Use no hashtags. The post must be at most %d characters. "+
+			`Respond with only a JSON object: {"post": "<the post>"}.`, platform.MaxLength)
+	}
+	if post.Truncated {
+		systemPrompt += " The document was truncated; write only about what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0.7
+	samplingRequest.MaxTokens = platform.MaxLength/2 + 200
+
+	logf(ctx, "📤 Sending sampling request for a %s post about: %s", platformName, filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid post", func(text string) (err error) {
+		post.Post, post.Hashtags, err = parseSocialPost(text, platform, withHashtags)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	post.Model = result.Model
+
+	post.Length = utf8.RuneCountInString(post.Post)
+	logf(ctx, "✅ Wrote a %s post about %s (%d of %d characters)", platformName, filename, post.Length, post.MaxLength)
+
+	data, err := json.MarshalIndent(post, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding post: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseSocialPost decodes the model's post and hashtags and returns the
+// full post, with the hashtags on a line of their own at the end. The
+// full post must fit the platform's limit. Hashtags are given a leading
+// "#" if they lack one, deduplicated, and must be a single word each.
+func parseSocialPost(text string, platform postPlatform, withHashtags bool) (string, []string, error) {
+	var answer struct {
+		Post     string   `json:"post"`
+		Hashtags []string `json:"hashtags"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return "", nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	body := strings.TrimSpace(answer.Post)
+	if body == "" {
+		return "", nil, fmt.Errorf("the post is empty")
+	}
+
+	var hashtags []string
+	if withHashtags {
+		for _, tag := range answer.Hashtags {
+			tag = "#" + strings.TrimLeft(strings.TrimSpace(tag), "#")
+			if !isHashtag(tag) {
+				return "", nil, fmt.Errorf("%q is not a hashtag: use one word of letters, digits and underscores, not only digits", tag)
+			}
+			if !slices.ContainsFunc(hashtags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+				hashtags = append(hashtags, tag)
+			}
+		}
+		if len(hashtags) == 0 {
+			return "", nil, fmt.Errorf("no hashtags given")
+		}
+		if len(hashtags) > platform.MaxHashtags {
+			return "", nil, fmt.Errorf("%d hashtags is more than the limit of %d", len(hashtags), platform.MaxHashtags)
+		}
+	}
+
+	full := body
+	if len(hashtags) > 0 {
+		full += "\n\n" + strings.Join(hashtags, " ")
+	}
+	if n := utf8.RuneCountInString(full); n > platform.MaxLength {
+		if len(hashtags) > 0 {
+			return "", nil, fmt.Errorf("the post is %d characters with its hashtags, over the limit of %d", n, platform.MaxLength)
+		}
+		return "", nil, fmt.Errorf("the post is %d characters, over the limit of %d", n, platform.MaxLength)
+	}
+	return full, hashtags, nil
+}
+
+// isHashtag reports whether tag is "#" followed by one word of letters,
+// digits and underscores, not all digits.
+func isHashtag(tag string) bool {
+	word := strings.TrimPrefix(tag, "#")
+	if word == "" {
+		return false
+	}
+	letters := false
+	for _, r := range word {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			letters = true
+		case unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+	return letters
+}
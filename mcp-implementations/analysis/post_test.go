@@ -0,0 +1,151 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// postAnswer is a generate_post answer with the given body and hashtags.
+func postAnswer(body string, hashtags ...string) string {
+	answer := map[string]any{"post": body}
+	if hashtags != nil {
+		answer["hashtags"] = hashtags
+	}
+	data, _ := json.Marshal(answer)
+	return string(data)
+}
+
+// postOf decodes a generate_post result.
+func postOf(t *testing.T, text string) SocialPost {
+	t.Helper()
+	var post SocialPost
+	if err := json.Unmarshal([]byte(text), &post); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	return post
+}
+
+func TestGeneratePostLengthLimits(t *testing.T) {
+	tests := []struct {
+		platform string
+		limit    int
+	}{
+		{"twitter", 280},
+		{"linkedin", 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			s := newTestServer(t, Config{}, map[string]string{"notes.md": "Some notes."})
+			// é is one character but two bytes, so the limit counts characters
+			sampler := &mockSampler{respond: answers(
+				postAnswer(strings.Repeat("é", tt.limit+1)),
+				postAnswer(strings.Repeat("é", tt.limit)),
+			)}
+			c := connect(t, s, sampler)
+
+			_, text := mustSucceed(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": tt.platform})
+
+			requests := sampler.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("%d sampling requests, want a retry of the long post", len(requests))
+			}
+			if !strings.Contains(requests[0].SystemPrompt, fmt.Sprintf("at most %d characters", tt.limit)) {
+				t.Errorf("prompt does not give the limit: %s", requests[0].SystemPrompt)
+			}
+			if !strings.Contains(requests[1].SystemPrompt, fmt.Sprintf("the post is %d characters, over the limit of %d", tt.limit+1, tt.limit)) {
+				t.Errorf("retry does not say the post was too long: %s", requests[1].SystemPrompt)
+			}
+			post := postOf(t, text)
+			if post.Length != tt.limit || post.MaxLength != tt.limit || utf8.RuneCountInString(post.Post) != tt.limit {
+				t.Errorf("post is %d characters of %d (reported %d)", utf8.RuneCountInString(post.Post), post.MaxLength, post.Length)
+			}
+		})
+	}
+}
+
+func TestGeneratePostFailsWhenStillTooLong(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.md": "Some notes."})
+	sampler := &mockSampler{respond: answers(postAnswer(strings.Repeat("a", 3001)))}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": "linkedin"})
+	if !strings.Contains(text, "did not return a valid post after a retry: the post is 3001 characters, over the limit of 3000") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want one retry", n)
+	}
+}
+
+func TestGeneratePostHashtagsCountTowardsLimit(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.md": "Some notes."})
+	// 272 characters fit alone, but not with "\n\n#golang"
+	sampler := &mockSampler{respond: answers(
+		postAnswer(strings.Repeat("a", 272), "golang"),
+		postAnswer(strings.Repeat("a", 250), "golang", "#GoLang", " #mcp "),
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": "twitter", "hashtags": true})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, "the post is 281 characters with its hashtags, over the limit of 280") {
+		t.Fatalf("hashtags pushing the post over the limit were not retried: %d requests", len(requests))
+	}
+	post := postOf(t, text)
+	if !slices.Equal(post.Hashtags, []string{"#golang", "#mcp"}) {
+		t.Errorf("hashtags %v, want them normalized and deduplicated", post.Hashtags)
+	}
+	if want := strings.Repeat("a", 250) + "\n\n#golang #mcp"; post.Post != want || post.Length != 264 {
+		t.Errorf("post is %q (%d characters), want the hashtags on their own line", post.Post, post.Length)
+	}
+}
+
+func TestGeneratePostRejectsInvalidHashtags(t *testing.T) {
+	tests := map[string]struct {
+		answer, want string
+	}{
+		"two words":   {postAnswer("A post.", "two words"), `"#two words" is not a hashtag`},
+		"digits only": {postAnswer("A post.", "2024"), `"#2024" is not a hashtag`},
+		"too many":    {postAnswer("A post.", "a", "b", "c"), "3 hashtags is more than the limit of 2"},
+		"none":        {postAnswer("A post."), "no hashtags given"},
+		"empty post":  {postAnswer("  ", "go"), "the post is empty"},
+		"not JSON":    {"Here is your post!", "not valid JSON"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, Config{}, map[string]string{"notes.md": "Some notes."})
+			c := connect(t, s, &mockSampler{respond: answers(tt.answer)})
+
+			text := mustFail(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": "twitter", "hashtags": true})
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("error %q does not mention %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePostWithoutHashtagsIgnoresThem(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.md": "Some notes."})
+	sampler := &mockSampler{respond: answers(postAnswer("A post.", "#unwanted"))}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": "LinkedIn"})
+
+	post := postOf(t, text)
+	if post.Post != "A post." || post.Hashtags != nil || post.Platform != "linkedin" {
+		t.Errorf("post is %+v", post)
+	}
+	if prompt := sampler.Requests()[0].SystemPrompt; !strings.Contains(prompt, "Use no hashtags") || !strings.Contains(prompt, "a LinkedIn post") {
+		t.Errorf("prompt is not for a LinkedIn post without hashtags: %s", prompt)
+	}
+
+	text = mustFail(t, c, "generate_post", map[string]any{"filename": "notes.md", "platform": "mastodon"})
+	if !strings.Contains(text, `unknown platform "mastodon" (use twitter or linkedin)`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
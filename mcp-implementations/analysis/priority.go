@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// priorities are the accepted priority argument values, highest first. A
+// call's priority is its index here.
+var priorities = []string{"high", "normal", "low"}
+
+// MaxPrioritySkips is how many times a waiting sampling request can be
+// passed over for a higher-priority one that arrived after it. It is then
+// served next, so low-priority work always makes progress.
+const MaxPrioritySkips = 8
+
+// priorityProperty documents the priority argument on tools that sample.
+var priorityProperty = map[string]any{
+	"type":        "string",
+	"description": "How soon this call's sampling requests are served while the server is at its concurrency limit (default normal)",
+	"enum":        priorities,
+}
+
+type priorityKey struct{}
+
+// withPriority is tool middleware that moves a priority argument into the
+// context, where requestSampling reads it when queueing for a slot.
+func withPriority(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("priority", "")
+		if name == "" {
+			return next(ctx, request)
+		}
+		priority := slices.Index(priorities, strings.ToLower(name))
+		if priority < 0 {
+			return errorResult("Unknown priority %q (use %s)", name, strings.Join(priorities, ", ")), nil
+		}
+		return next(context.WithValue(ctx, priorityKey{}, priority), request)
+	}
+}
+
+// priorityFrom returns the priority of the tool call ctx belongs to.
+func priorityFrom(ctx context.Context) int {
+	if priority, ok := ctx.Value(priorityKey{}).(int); ok {
+		return priority
+	}
+	return slices.Index(priorities, "normal")
+}
+
+// slotQueue bounds concurrent sampling requests like a semaphore, but
+// when every slot is taken it hands the next free one to the
+// highest-priority waiter, oldest first, rather than to whichever
+// goroutine wins the race.
+type slotQueue struct {
+	mu      sync.Mutex
+	free    int
+	waiting []*slotWaiter
+}
+
+// slotWaiter is a request waiting for a slot. skipped counts the slots
+// given to requests that arrived after it.
+type slotWaiter struct {
+	priority int
+	skipped  int
+	ready    chan struct{}
+}
+
+func newSlotQueue(slots int) *slotQueue {
+	return &slotQueue{free: slots}
+}
+
+// acquire waits for a slot until ctx is done.
+func (q *slotQueue) acquire(ctx context.Context, priority int) error {
+	q.mu.Lock()
+	if q.free > 0 {
+		q.free--
+		q.mu.Unlock()
+		return nil
+	}
+	w := &slotWaiter{priority: priority, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if i := slices.Index(q.waiting, w); i >= 0 {
+			q.waiting = slices.Delete(q.waiting, i, i+1)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		q.mu.Unlock()
+		// The slot was handed over just as ctx ended; pass it on
+		q.release()
+		return ctx.Err()
+	}
+}
+
+// release returns a slot, handing it straight to the next waiter if any.
+func (q *slotQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) == 0 {
+		q.free++
+		return
+	}
+
+	i := q.next()
+	for _, earlier := range q.waiting[:i] {
+		earlier.skipped++
+	}
+	w := q.waiting[i]
+	q.waiting = slices.Delete(q.waiting, i, i+1)
+	close(w.ready)
+}
+
+// next picks the waiter to serve: the oldest one skipped MaxPrioritySkips
+// times if there is one, else the oldest of the highest priority.
+func (q *slotQueue) next() int {
+	best := 0
+	for i, w := range q.waiting {
+		if w.skipped >= MaxPrioritySkips {
+			return i
+		}
+		if w.priority < q.waiting[best].priority {
+			best = i
+		}
+	}
+	return best
+}
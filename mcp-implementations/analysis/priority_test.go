@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// waitForWaiters waits until n requests are queued for a slot in q.
+func waitForWaiters(t *testing.T, q *slotQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q.mu.Lock()
+		waiting := len(q.waiting)
+		q.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests are waiting for a slot, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// queueWaiter starts a goroutine that waits for a slot at priority and
+// sends name on served once it has one.
+func queueWaiter(t *testing.T, q *slotQueue, priority int, name string, served chan<- string) {
+	t.Helper()
+	q.mu.Lock()
+	queued := len(q.waiting)
+	q.mu.Unlock()
+	go func() {
+		if err := q.acquire(context.Background(), priority); err != nil {
+			t.Errorf("%s: acquire: %v", name, err)
+			return
+		}
+		served <- name
+	}()
+	waitForWaiters(t, q, queued+1)
+}
+
+func TestSlotQueueServesHigherPriorityFirst(t *testing.T) {
+	q := newSlotQueue(1)
+	if err := q.acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan string, 4)
+	queueWaiter(t, q, 2, "low", served)
+	queueWaiter(t, q, 1, "normal 1", served)
+	queueWaiter(t, q, 0, "high", served)
+	queueWaiter(t, q, 1, "normal 2", served)
+
+	var order []string
+	for range 4 {
+		q.release()
+		order = append(order, <-served)
+	}
+	if got := fmt.Sprint(order); got != "[high normal 1 normal 2 low]" {
+		t.Errorf("slots were handed out in order %s, want by priority, oldest first", got)
+	}
+}
+
+func TestSlotQueueDoesNotStarveLowPriority(t *testing.T) {
+	q := newSlotQueue(1)
+	if err := q.acquire(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan string, MaxPrioritySkips+2)
+	queueWaiter(t, q, 2, "low", served)
+	for i := range MaxPrioritySkips + 1 {
+		// A new high-priority request is always waiting alongside the low one
+		queueWaiter(t, q, 0, fmt.Sprintf("high %d", i), served)
+		q.release()
+		got := <-served
+		if i < MaxPrioritySkips && got == "low" {
+			t.Fatalf("low was served after %d skips, before reaching MaxPrioritySkips", i)
+		}
+		if i == MaxPrioritySkips {
+			if got != "low" {
+				t.Fatalf("low is still waiting after %d skips; %s was served", i, got)
+			}
+			q.release()
+			if got := <-served; got != fmt.Sprintf("high %d", i) {
+				t.Errorf("%s was served after low, want the waiting high request", got)
+			}
+		}
+	}
+}
+
+func TestSlotQueueCancelledWaiterLeaves(t *testing.T) {
+	q := newSlotQueue(1)
+	if err := q.acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- q.acquire(ctx, 0) }()
+	waitForWaiters(t, q, 1)
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("acquire returned %v, want context.Canceled", err)
+	}
+	waitForWaiters(t, q, 0)
+
+	// The slot goes back to the free pool instead of to the departed waiter
+	q.release()
+	if err := q.acquire(context.Background(), 2); err != nil {
+		t.Errorf("the released slot was lost: %v", err)
+	}
+}
+
+func TestPriorityArgumentOrdersSamplingRequests(t *testing.T) {
+	s := newTestServer(t, Config{MaxConcurrentSampling: 1}, map[string]string{
+		"first.txt": "first", "low.txt": "low", "high.txt": "high",
+	})
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	sampler := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		text := messageText(request)
+		mu.Lock()
+		order = append(order, text)
+		mu.Unlock()
+		if text == "first" {
+			<-unblock
+		}
+		return textAnswer(mockAnswer), nil
+	}}
+	c := connect(t, s, sampler)
+
+	var wg sync.WaitGroup
+	call := func(filename, priority string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callTool(t, c, "analyze_file", map[string]any{"filename": filename, "priority": priority})
+		}()
+	}
+	call("first.txt", "normal")
+	waitForSampled(t, &mu, &order, 1)
+	call("low.txt", "low")
+	waitForWaiters(t, s.samplingSlots, 1)
+	call("high.txt", "HIGH")
+	waitForWaiters(t, s.samplingSlots, 2)
+	close(unblock)
+	wg.Wait()
+
+	if got := strings.Join(order, ", "); got != "first, high, low" {
+		t.Errorf("sampling requests were served in order %s, want first, high, low", got)
+	}
+}
+
+// waitForSampled waits until n sampling requests have reached the client.
+func waitForSampled(t *testing.T, mu *sync.Mutex, order *[]string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		sampled := len(*order)
+		mu.Unlock()
+		if sampled >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sampling requests reached the client, want %d", sampled, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUnknownPriorityIsRejected(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "priority": "urgent"})
+	if !strings.Contains(text, `Unknown priority "urgent" (use high, normal, low)`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a rejected call sent %d sampling requests", n)
+	}
+}
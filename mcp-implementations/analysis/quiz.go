@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DefaultQuizQuestions is used when generate_quiz is not given a count.
+	DefaultQuizQuestions = 5
+	// MaxQuizQuestions bounds num_questions so one answer fits in MaxTokens.
+	MaxQuizQuestions = 20
+)
+
+var generateQuizTool = mcp.Tool{
+	Name:        "generate_quiz",
+	Description: "Generate multiple-choice quiz questions about a text file using LLM sampling, returned as JSON",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to quiz on (relative to files directory)",
+			},
+			"num_questions": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of questions (default %d, max %d)", DefaultQuizQuestions, MaxQuizQuestions),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// QuizOption is one answer choice of a quiz question.
+type QuizOption struct {
+	Text    string `json:"text"`
+	Correct bool   `json:"correct"`
+}
+
+// QuizQuestion is one multiple-choice question.
+type QuizQuestion struct {
+	Question    string       `json:"question"`
+	Options     []QuizOption `json:"options"`
+	Explanation string       `json:"explanation,omitempty"`
+}
+
+// Quiz is the structured result of generate_quiz.
+type Quiz struct {
+	File      string         `json:"file"`
+	Model     string         `json:"model"`
+	Questions []QuizQuestion `json:"questions"`
+}
+
+func (s *Server) handleGenerateQuiz(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	numQuestions := request.GetInt("num_questions", DefaultQuizQuestions)
+	if numQuestions < 1 || numQuestions > MaxQuizQuestions {
+		return errorResult("num_questions must be between 1 and %d", MaxQuizQuestions), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if strings.TrimSpace(text) == "" {
+		return errorResult("%s is empty", filename), nil
+	}
+	// Questions are drawn from the part of long documents truncation keeps
+	text, _ = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	systemPrompt := fmt.Sprintf("Write exactly %d multiple-choice questions that test understanding of this document. "+
+		"Each question has 3 to 5 options, exactly one of them correct. Respond with only a JSON object: "+
+		`{"questions": [{"question": "...", "options": [{"text": "...", "correct": true or false}], "explanation": "why the answer is correct"}]}.`, numQuestions)
+
+	var questions []QuizQuestion
+	var model string
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: text}, systemPrompt)
+	samplingRequest.MaxTokens = 4000
+
+	logf(ctx, "📤 Sending sampling request to generate %d quiz questions for: %s", numQuestions, filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid quiz", func(text string) (err error) {
+		questions, err = parseQuiz(text, numQuestions)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	model = result.Model
+
+	logf(ctx, "✅ Generated %d quiz questions for %s", len(questions), filename)
+
+	data, err := json.MarshalIndent(Quiz{File: filename, Model: model, Questions: questions}, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding quiz: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseQuiz decodes the model's quiz and checks it has the requested
+// number of questions, each with at least two options and exactly one
+// correct answer.
+func parseQuiz(text string, want int) ([]QuizQuestion, error) {
+	var answer struct {
+		Questions []QuizQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if len(answer.Questions) != want {
+		return nil, fmt.Errorf("expected %d questions, got %d", want, len(answer.Questions))
+	}
+
+	for i, q := range answer.Questions {
+		if strings.TrimSpace(q.Question) == "" {
+			return nil, fmt.Errorf("question %d has no text", i+1)
+		}
+		if len(q.Options) < 2 {
+			return nil, fmt.Errorf("question %d has %d options, need at least 2", i+1, len(q.Options))
+		}
+		correct := 0
+		for _, o := range q.Options {
+			if strings.TrimSpace(o.Text) == "" {
+				return nil, fmt.Errorf("question %d has an empty option", i+1)
+			}
+			if o.Correct {
+				correct++
+			}
+		}
+		if correct != 1 {
+			return nil, fmt.Errorf("question %d has %d correct options, need exactly 1", i+1, correct)
+		}
+	}
+	return answer.Questions, nil
+}
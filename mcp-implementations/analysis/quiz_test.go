@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// quizAnswer builds a quiz answer of n questions, each with three options
+// of which correct are marked correct.
+func quizAnswer(n, correct int) string {
+	var questions []QuizQuestion
+	for i := range n {
+		q := QuizQuestion{Question: fmt.Sprintf("Question %d?", i+1), Explanation: "Because."}
+		for j := range 3 {
+			q.Options = append(q.Options, QuizOption{Text: fmt.Sprintf("Option %d", j+1), Correct: j < correct})
+		}
+		questions = append(questions, q)
+	}
+	data, _ := json.Marshal(map[string]any{"questions": questions})
+	return string(data)
+}
+
+func TestGenerateQuizCountAndStructure(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"lesson.md": "# Photosynthesis\n\nPlants turn light into energy."})
+	sampler := &mockSampler{respond: answers(quizAnswer(3, 1))}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_quiz", map[string]any{"filename": "lesson.md", "num_questions": 3})
+
+	var quiz Quiz
+	if err := json.Unmarshal([]byte(text), &quiz); err != nil {
+		t.Fatalf("generate_quiz did not return JSON: %v\n%s", err, text)
+	}
+	if quiz.File != "lesson.md" || quiz.Model != "mock-model" || len(quiz.Questions) != 3 {
+		t.Fatalf("quiz %+v, want 3 questions about lesson.md", quiz)
+	}
+	for i, q := range quiz.Questions {
+		correct := 0
+		for _, option := range q.Options {
+			if option.Correct {
+				correct++
+			}
+		}
+		if correct != 1 || len(q.Options) < 2 {
+			t.Errorf("question %d has %d options with %d correct", i+1, len(q.Options), correct)
+		}
+	}
+	if !strings.Contains(sampler.Requests()[0].SystemPrompt, "exactly 3 multiple-choice questions") {
+		t.Errorf("system prompt does not ask for 3 questions: %q", sampler.Requests()[0].SystemPrompt)
+	}
+}
+
+func TestGenerateQuizRepromptsOnMalformedOutput(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"lesson.md": "Plants turn light into energy."})
+	sampler := &mockSampler{respond: answers(quizAnswer(2, 2), quizAnswer(2, 1))}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_quiz", map[string]any{"filename": "lesson.md", "num_questions": 2})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, "question 1 has 2 correct options") {
+		t.Errorf("reprompt does not say what was wrong: %q", requests[1].SystemPrompt)
+	}
+	var quiz Quiz
+	if err := json.Unmarshal([]byte(text), &quiz); err != nil || len(quiz.Questions) != 2 {
+		t.Errorf("result is not the corrected quiz: %v\n%s", err, text)
+	}
+}
+
+func TestGenerateQuizFailsAfterRetry(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"lesson.md": "Plants turn light into energy."})
+	c := connect(t, s, &mockSampler{respond: answers(quizAnswer(1, 1))})
+
+	text := mustFail(t, c, "generate_quiz", map[string]any{"filename": "lesson.md", "num_questions": 4})
+	if !strings.Contains(text, "did not return a valid quiz after a retry") || !strings.Contains(text, "expected 4 questions, got 1") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestParseQuiz(t *testing.T) {
+	tests := []struct {
+		name, text, wantErr string
+	}{
+		{"valid in a code fence", "```json\n" + quizAnswer(2, 1) + "\n```", ""},
+		{"no correct option", quizAnswer(2, 0), "0 correct options"},
+		{"wrong count", quizAnswer(1, 1), "expected 2 questions"},
+		{"not JSON", "Here is your quiz!", "not valid JSON"},
+		{"empty option", `{"questions":[{"question":"Q?","options":[{"text":"","correct":true},{"text":"B"}]},{"question":"Q2?","options":[{"text":"A","correct":true},{"text":"B"}]}]}`, "empty option"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseQuiz(tt.text, 2)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("parseQuiz: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parseQuiz error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ReadingWordsPerMinute is the adult silent reading speed used for
+// reading-time estimates.
+const ReadingWordsPerMinute = 238
+
+var readabilityTool = mcp.Tool{
+	Name:        "readability",
+	Description: "Compute word count, reading time and Flesch-Kincaid grade of a text file locally, optionally adding a model's complexity assessment",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The text file to measure (relative to files directory)",
+			},
+			"assess": map[string]any{
+				"type":        "boolean",
+				"description": "Also ask the model to assess the text's complexity (uses sampling; default false)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// readabilityMetrics are the deterministic measures of a text.
+type readabilityMetrics struct {
+	Words          int
+	Sentences      int
+	Syllables      int
+	ReadingMinutes float64
+	// Grade is the Flesch-Kincaid grade level; Ease the Flesch reading ease
+	Grade float64
+	Ease  float64
+}
+
+func (s *Server) handleReadability(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	assess := request.GetBool("assess", false)
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	m := measureReadability(text)
+	if m.Words == 0 {
+		return errorResult("%s contains no words", filename), nil
+	}
+
+	report := fmt.Sprintf("Readability Results\n"+
+		"===================\n"+
+		"File: %s\n"+
+		"Words: %d\n"+
+		"Sentences: %d\n"+
+		"Reading time: %.1f min (at %d words per minute)\n"+
+		"Flesch-Kincaid grade: %.1f\n"+
+		"Flesch reading ease: %.1f", filename, m.Words, m.Sentences, m.ReadingMinutes, ReadingWordsPerMinute, m.Grade, m.Ease)
+
+	if !assess {
+		return textResult(report), nil
+	}
+
+	// Part of a long file is representative enough of its style
+	excerpt, _ := truncateFor(ctx, text, s.cfg.ChunkSize)
+	content := mcp.TextContent{Type: "text", Text: excerpt}
+	samplingRequest := newSamplingRequest(content, fmt.Sprintf("Assess how complex this text is to read: vocabulary, sentence structure, "+
+		"assumed background knowledge and density of ideas. Say who it suits. For reference, its Flesch-Kincaid grade is %.1f. "+
+		"Answer in at most five sentences.", m.Grade))
+	samplingRequest.MaxTokens = 400
+
+	logf(ctx, "📤 Sending sampling request to assess readability of: %s", filename)
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+
+	return textResult(fmt.Sprintf("%s\n\nComplexity assessment (%s):\n%s", report, result.Model, resultText(result))), nil
+}
+
+// measureReadability counts words, sentences and syllables and derives the
+// Flesch scores. A text with words but no sentence-ending punctuation
+// counts as one sentence.
+func measureReadability(text string) readabilityMetrics {
+	var m readabilityMetrics
+	inSentence := false
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if word != "" {
+			m.Words++
+			m.Syllables += countSyllables(word)
+			inSentence = true
+		}
+		if inSentence && strings.ContainsAny(field[len(field)-1:], ".!?") {
+			m.Sentences++
+			inSentence = false
+		}
+	}
+	if inSentence {
+		m.Sentences++
+	}
+	if m.Words == 0 {
+		return m
+	}
+
+	wordsPerSentence := float64(m.Words) / float64(m.Sentences)
+	syllablesPerWord := float64(m.Syllables) / float64(m.Words)
+	m.ReadingMinutes = float64(m.Words) / ReadingWordsPerMinute
+	m.Grade = round1(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59)
+	m.Ease = round1(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord)
+	return m
+}
+
+// countSyllables estimates a word's syllables as its groups of vowels,
+// less a silent final "e", and at least one.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	return max(count, 1)
+}
+
+func round1(x float64) float64 {
+	return math.Round(x*10) / 10
+}
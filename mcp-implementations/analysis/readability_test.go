@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+// readabilityFixture has 9 one-syllable words in 2 sentences, so its
+// scores follow directly from the Flesch formulas.
+const readabilityFixture = "The cat sat on the mat. The dog ran!"
+
+func TestMeasureReadability(t *testing.T) {
+	m := measureReadability(readabilityFixture)
+	want := readabilityMetrics{Words: 9, Sentences: 2, Syllables: 9, ReadingMinutes: 9.0 / ReadingWordsPerMinute, Grade: -2.0, Ease: 117.7}
+	if m != want {
+		t.Errorf("measureReadability() = %+v, want %+v", m, want)
+	}
+
+	// Text without closing punctuation is still one sentence
+	if m := measureReadability("no punctuation here"); m.Sentences != 1 || m.Words != 3 {
+		t.Errorf("unpunctuated text: %+v", m)
+	}
+	if m := measureReadability(" -- \n"); m.Words != 0 {
+		t.Errorf("text without words counted %d", m.Words)
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	for word, want := range map[string]int{
+		"cat":         1,
+		"make":        1,
+		"table":       2,
+		"reading":     2,
+		"readability": 5,
+		"syllable":    3,
+		"b":           1,
+	} {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+func TestReadabilityIsLocalByDefault(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"story.txt": readabilityFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "readability", map[string]any{"filename": "story.txt"})
+
+	for _, want := range []string{"Words: 9\n", "Sentences: 2\n", "Reading time: 0.0 min (at 238 words per minute)", "Flesch-Kincaid grade: -2.0", "Flesch reading ease: 117.7"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("local metrics sent %d sampling requests", n)
+	}
+}
+
+func TestReadabilityAddsModelAssessment(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"story.txt": readabilityFixture})
+	sampler := &mockSampler{respond: answers("Very simple; suits early readers.")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "readability", map[string]any{"filename": "story.txt", "assess": true})
+
+	if !strings.Contains(text, "Flesch-Kincaid grade: -2.0") || !strings.Contains(text, "Complexity assessment (mock-model):\nVery simple; suits early readers.") {
+		t.Errorf("result does not carry both metrics and assessment:\n%s", text)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 1 || !strings.Contains(requests[0].SystemPrompt, "Flesch-Kincaid grade is -2.0") {
+		t.Errorf("assessment prompt does not give the grade: %d requests", len(requests))
+	}
+}
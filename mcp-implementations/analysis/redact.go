@@ -0,0 +1,156 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PIIPattern is one kind of personal data the redact argument masks.
+// Matches are replaced with "[Name]".
+type PIIPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// DefaultPIIPatterns mask email addresses, US Social Security numbers and
+// phone numbers. SSNs come before phones so a dashed SSN is labeled as one.
+var DefaultPIIPatterns = []PIIPattern{
+	{Name: "EMAIL", Regexp: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{Name: "SSN", Regexp: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{Name: "PHONE", Regexp: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`)},
+}
+
+// redactModes are the values of the redact argument. "patterns" applies
+// the regular expressions only; "model" follows them with a sampling pass
+// that catches PII no pattern describes, such as names and addresses.
+var redactModes = []string{"patterns", "model"}
+
+var redactProperty = map[string]any{
+	"type":        "string",
+	"description": "Mask PII (emails, phone numbers, SSNs, ...) in the result: \"patterns\" uses the server's regular expressions, \"model\" adds a sampling pass for names and addresses",
+	"enum":        redactModes,
+}
+
+// LoadPIIPatterns reads redaction patterns from a file with one
+// "NAME regexp" pair per line, skipping blank lines and lines starting
+// with '#'.
+func LoadPIIPatterns(path string) ([]PIIPattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []PIIPattern
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(line, " ")
+		expr = strings.TrimSpace(expr)
+		if !ok || expr == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"NAME regexp\"", path, lineNo)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		patterns = append(patterns, PIIPattern{Name: name, Regexp: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// redactPII masks every pattern match in text and counts the matches per
+// pattern name.
+func redactPII(text string, patterns []PIIPattern, counts map[string]int) string {
+	for _, p := range patterns {
+		text = p.Regexp.ReplaceAllStringFunc(text, func(string) string {
+			counts[p.Name]++
+			return "[" + p.Name + "]"
+		})
+	}
+	return text
+}
+
+// redactResult masks PII in every text block of a tool result, including
+// the quoted source excerpts, and in a debug raw response. In "model" mode
+// a successful result is also rewritten by the model; if that pass fails
+// the result is withheld rather than returned half-redacted.
+func (s *Server) redactResult(ctx context.Context, result *mcp.CallToolResult, mode string) *mcp.CallToolResult {
+	counts := map[string]int{}
+
+	for i, content := range result.Content {
+		textContent, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		text := redactPII(textContent.Text, s.cfg.PIIPatterns, counts)
+		if mode == "model" && !result.IsError {
+			rewritten, err := s.redactWithModel(ctx, text)
+			if err != nil {
+				log.Printf("❌ PII redaction pass failed: %v", err)
+				return errorResult("Redaction failed, so the result was withheld: %v", err)
+			}
+			// The model may echo something a pattern catches; mask it again
+			text = redactPII(rewritten, s.cfg.PIIPatterns, counts)
+		}
+		textContent.Text = text
+		result.Content[i] = textContent
+	}
+
+	if result.Meta != nil {
+		if raw, ok := result.Meta.AdditionalFields["raw_response"].(string); ok {
+			result.Meta.AdditionalFields["raw_response"] = redactPII(raw, s.cfg.PIIPatterns, counts)
+		}
+	}
+
+	if len(counts) > 0 && !result.IsError {
+		result.Content = append(result.Content, mcp.TextContent{Type: "text", Text: "\n" + redactionNote(counts)})
+	}
+	return result
+}
+
+// redactWithModel asks the model to mask personal data the patterns miss.
+func (s *Server) redactWithModel(ctx context.Context, text string) (string, error) {
+	request := newSamplingRequest(mcp.TextContent{Type: "text", Text: text},
+		"Return the user's text unchanged except that every remaining piece of personally identifiable information "+
+			"(people's names, street addresses, dates of birth, account or ID numbers) is replaced with a bracketed label such as [NAME] or [ADDRESS]. "+
+			"Keep existing bracketed labels. Output only the rewritten text, with no commentary.")
+	request.Temperature = 0
+	request.MaxTokens = max(2000, len(text)/2)
+
+	logf(ctx, "📤 Sending sampling request for a PII redaction pass (%d bytes)", len(text))
+	result, err := s.requestSampling(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	return resultText(result), nil
+}
+
+// redactionNote summarizes what was masked, e.g. "PII redacted: 2 EMAIL, 1 PHONE".
+func redactionNote(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return "PII redacted: " + strings.Join(parts, ", ")
+}
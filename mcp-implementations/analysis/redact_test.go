@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const piiAnswer = "Contact Jane Doe at jane.doe@example.com or (555) 123-4567; her SSN is 123-45-6789."
+
+func TestAnalyzeFileRedactsPIIPatterns(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"hr.txt": "Employee record."})
+	sampler := &mockSampler{respond: answers(piiAnswer)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "hr.txt", "redact": "patterns"})
+
+	for _, pii := range []string{"jane.doe@example.com", "(555) 123-4567", "123-45-6789"} {
+		if strings.Contains(text, pii) {
+			t.Errorf("%q was not masked:\n%s", pii, text)
+		}
+	}
+	for _, want := range []string{"[EMAIL]", "[PHONE]", "[SSN]", "PII redacted: 1 EMAIL, 1 PHONE, 1 SSN"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("patterns mode sent %d sampling requests, want 1", n)
+	}
+}
+
+func TestAnalyzeFileWithoutRedactKeepsText(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"hr.txt": "Employee record."})
+	c := connect(t, s, &mockSampler{respond: answers(piiAnswer)})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "hr.txt"})
+	if !strings.Contains(text, "jane.doe@example.com") || strings.Contains(text, "PII redacted") {
+		t.Errorf("text was redacted without the redact argument:\n%s", text)
+	}
+}
+
+func TestAnalyzeFileRedactsWithModelPass(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"hr.txt": "Employee record."})
+	sampler := &mockSampler{respond: answers(piiAnswer, "Contact [NAME] at [EMAIL] or [PHONE]; her SSN is [SSN].")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "hr.txt", "redact": "model"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want the analysis and a redaction pass", len(requests))
+	}
+	if pass := messageText(requests[1]); strings.Contains(pass, "jane.doe@example.com") || !strings.Contains(pass, "Jane Doe") {
+		t.Errorf("the redaction pass should get pattern-masked text with the name left for the model: %q", pass)
+	}
+	if strings.Contains(text, "Jane Doe") || !strings.Contains(text, "[NAME]") {
+		t.Errorf("the model pass was not applied:\n%s", text)
+	}
+}
+
+func TestConfiguredPIIPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii.txt")
+	if err := os.WriteFile(path, []byte("# employee IDs\nEMPLOYEE_ID EMP-\\d{5}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := LoadPIIPatterns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, Config{PIIPatterns: patterns}, map[string]string{"hr.txt": "Employee record."})
+	c := connect(t, s, &mockSampler{respond: answers("Badge EMP-12345 belongs to jane@example.com.")})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "hr.txt", "redact": "patterns"})
+	if !strings.Contains(text, "Badge [EMPLOYEE_ID]") {
+		t.Errorf("the configured pattern was not applied:\n%s", text)
+	}
+	if !strings.Contains(text, "jane@example.com") {
+		t.Errorf("configured patterns should replace the defaults, not add to them:\n%s", text)
+	}
+}
+
+func TestLoadPIIPatternsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii.txt")
+	for content, want := range map[string]string{
+		"ONLYNAME\n": `expected "NAME regexp"`,
+		"BAD [a-z\n": "missing closing ]",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPIIPatterns(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadPIIPatterns(%q) = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestRedactPIICounts(t *testing.T) {
+	counts := map[string]int{}
+	got := redactPII("a@b.io, c@d.io", []PIIPattern{{Name: "EMAIL", Regexp: regexp.MustCompile(`\S+@\S+\.io`)}}, counts)
+	if got != "[EMAIL], [EMAIL]" || counts["EMAIL"] != 2 {
+		t.Errorf("redactPII = %q with counts %v", got, counts)
+	}
+}
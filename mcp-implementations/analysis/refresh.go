@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var analyzeIfChangedTool = mcp.Tool{
+	Name:        "analyze_if_changed",
+	Description: "Return the last analysis of a file if it has not changed since, otherwise analyze it again",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to analyze (relative to files directory)",
+			},
+			"analysis_type": map[string]any{
+				"type":        "string",
+				"description": "Type of analysis to perform",
+				"enum":        AnalysisTypeNames(),
+			},
+			"custom_prompt": map[string]any{
+				"type":        "string",
+				"description": "Optional custom prompt for the analysis",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// lastAnalysisKey identifies the most recent analysis of a file with the
+// given options, whatever its content was at the time.
+func lastAnalysisKey(opts analyzeOptions) (string, error) {
+	settings, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(settings)
+	return "last-" + hex.EncodeToString(sum[:]), nil
+}
+
+func (s *Server) handleAnalyzeIfChanged(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	opts := analyzeOptions{
+		Filename:     filename,
+		AnalysisType: request.GetString("analysis_type", s.cfg.DefaultAnalysis),
+		CustomPrompt: request.GetString("custom_prompt", ""),
+		Audience:     DefaultAudience,
+		Resume:       true,
+		UseCache:     true,
+	}
+
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return errorResult("Error reading file: %v", err), nil
+	}
+	key, err := lastAnalysisKey(opts)
+	if err != nil {
+		return errorResult("Error encoding options: %v", err), nil
+	}
+	modTime := info.ModTime().UTC().Format(time.RFC3339Nano)
+	size := strconv.FormatInt(info.Size(), 10)
+
+	last, found, err := s.cfg.Cache.Get(key)
+	if err != nil {
+		log.Printf("Warning: Cache lookup failed: %v", err)
+	}
+	if found {
+		// Same mtime and size means unchanged without reading the file;
+		// otherwise the content hash decides, so a touch is not a change
+		unchanged := last.Metadata["mtime"] == modTime && last.Metadata["size"] == size
+		if !unchanged {
+			hash, err := hashFile(filePath)
+			if err != nil {
+				return errorResult("Error reading file: %v", err), nil
+			}
+			unchanged = last.Metadata["content_hash"] == hash
+		}
+		if unchanged {
+			logf(ctx, "💾 %s unchanged since %s; returning the last analysis", filename, last.Metadata["analyzed_at"])
+			return refreshResult(filename, "no", false, last.Metadata["analyzed_at"], last.Result), nil
+		}
+	}
+
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return errorResult("Error reading file: %v", err), nil
+	}
+	result, err := s.analyzeFile(ctx, opts)
+	if err != nil || result.IsError {
+		return result, err
+	}
+
+	text := toolResultText(result)
+	analyzedAt := time.Now().UTC().Format(time.RFC3339)
+	now := time.Now()
+	err = s.cfg.Cache.Set(CacheEntry{
+		Key:    key,
+		Result: text,
+		Metadata: map[string]string{
+			"filename":      filename,
+			"analysis_type": opts.AnalysisType,
+			"content_hash":  hash,
+			"mtime":         modTime,
+			"size":          size,
+			"analyzed_at":   analyzedAt,
+		},
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.CacheTTL),
+	})
+	if err != nil {
+		log.Printf("Warning: Could not record analysis of %s: %v", filename, err)
+	}
+
+	// With no earlier analysis there is nothing to be stale
+	if !found {
+		return refreshResult(filename, "no earlier analysis", false, analyzedAt, text), nil
+	}
+	return refreshResult(filename, "yes, analyzed again", true, analyzedAt, text), nil
+}
+
+// refreshResult wraps an analysis with whether the previous one was stale,
+// both in the text and in _meta for hosts that poll.
+func refreshResult(filename, changed string, stale bool, analyzedAt, analysis string) *mcp.CallToolResult {
+	result := textResult(fmt.Sprintf("Refresh Results\n"+
+		"===============\n"+
+		"File: %s\n"+
+		"Changed: %s\n"+
+		"Stale: %t\n"+
+		"Analyzed at: %s\n\n"+
+		"%s", filename, changed, stale, analyzedAt, analysis))
+	result.Meta = mcp.NewMetaFromMap(map[string]any{"stale": stale, "analyzed_at": analyzedAt})
+	return result
+}
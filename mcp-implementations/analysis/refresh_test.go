@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeIfChangedReturnsLastAnalysisWhenUnchanged(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Quarterly numbers."})
+	sampler := &mockSampler{respond: answers("first analysis", "second analysis")}
+	c := connect(t, s, sampler)
+
+	_, first := mustSucceed(t, c, "analyze_if_changed", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(first, "Changed: no earlier analysis") || !strings.Contains(first, "first analysis") {
+		t.Errorf("first call did not analyze the file:\n%s", first)
+	}
+
+	// A touch alone is not a change: the content hash still matches
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(s.cfg.FilesDir, "notes.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	result, second := mustSucceed(t, c, "analyze_if_changed", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(second, "Changed: no\n") || !strings.Contains(second, "Stale: false") || !strings.Contains(second, "first analysis") {
+		t.Errorf("unchanged file was not answered from the last analysis:\n%s", second)
+	}
+	if result.Meta == nil || result.Meta.AdditionalFields["stale"] != false {
+		t.Errorf("_meta does not report stale=false: %+v", result.Meta)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want 1", n)
+	}
+}
+
+func TestAnalyzeIfChangedAnalyzesAgainWhenChanged(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Quarterly numbers."})
+	sampler := &mockSampler{respond: answers("first analysis", "second analysis")}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_if_changed", map[string]any{"filename": "notes.txt"})
+	if err := os.WriteFile(filepath.Join(s.cfg.FilesDir, "notes.txt"), []byte("Revised quarterly numbers."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, text := mustSucceed(t, c, "analyze_if_changed", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(text, "Changed: yes, analyzed again") || !strings.Contains(text, "Stale: true") || !strings.Contains(text, "second analysis") {
+		t.Errorf("changed file was not analyzed again:\n%s", text)
+	}
+	if result.Meta == nil || result.Meta.AdditionalFields["stale"] != true {
+		t.Errorf("_meta does not report stale=true: %+v", result.Meta)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(messageText(requests[1]), "Revised quarterly numbers.") {
+		t.Errorf("re-analysis did not sample the new content: %d requests", len(requests))
+	}
+}
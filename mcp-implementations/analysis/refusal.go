@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultRefusalPatterns match the usual openings of a model declining a
+// request. They are checked against the start of an answer only, so an
+// analysis that quotes a refusal further in is not mistaken for one.
+var DefaultRefusalPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\W*(?:(?:i'?m|i am) sorry|i apologi[sz]e|unfortunately)?[,.!]?\s*(?:but\s+)?i (?:can(?:'|no)t|can not|won'?t|will not|am unable to|'m unable to|am not able to|must decline to) (?:help|assist|comply|provide|analy[sz]e|do that|fulfil)`),
+	regexp.MustCompile(`(?i)^\W*(?:i'?m|i am) (?:sorry|afraid),? (?:but )?(?:i|that) (?:can(?:'|no)t|isn'?t something i can)`),
+}
+
+// refusalStopReasons are stop reasons with which providers report that the
+// model declined to answer.
+var refusalStopReasons = []string{"refusal", "content_filter", "SAFETY"}
+
+// refusalPrefixBytes is how much of an answer the patterns are checked
+// against.
+const refusalPrefixBytes = 300
+
+// RefusalError is returned when the model declined to answer instead of
+// doing the analysis.
+type RefusalError struct {
+	Reason string
+	// Text is the model's answer, which usually explains the refusal
+	Text string
+}
+
+func (e *RefusalError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("the model refused the request (%s)", e.Reason)
+	}
+	return fmt.Sprintf("the model refused the request (%s): %s", e.Reason, e.Text)
+}
+
+// LoadRefusalPatterns reads one regular expression per line, skipping
+// blank lines and lines starting with '#'. An empty file leaves only the
+// providers' stop reasons to detect refusals.
+func LoadRefusalPatterns(path string) ([]*regexp.Regexp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []*regexp.Regexp{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		patterns = append(patterns, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// checkRefusal returns a RefusalError when result is a refusal, judged by
+// its stop reason or by the start of its text matching one of patterns.
+func checkRefusal(result *mcp.CreateMessageResult, patterns []*regexp.Regexp) *RefusalError {
+	text := strings.TrimSpace(resultText(result))
+	if slices.Contains(refusalStopReasons, result.StopReason) {
+		return &RefusalError{Reason: fmt.Sprintf("stop reason %q", result.StopReason), Text: text}
+	}
+
+	prefix := text
+	if len(prefix) > refusalPrefixBytes {
+		prefix = prefix[:refusalPrefixBytes]
+	}
+	for _, re := range patterns {
+		if re.MatchString(prefix) {
+			return &RefusalError{Reason: "matched a refusal pattern", Text: text}
+		}
+	}
+	return nil
+}
+
+type refusalKey struct{}
+
+// withRefusalMeta marks a failed tool result whose sampling was refused
+// with "refusal" in its _meta, so clients can tell a declined request from
+// other errors without parsing the message.
+func (s *Server) withRefusalMeta(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var recorded atomic.Pointer[RefusalError]
+		result, err := next(context.WithValue(ctx, refusalKey{}, &recorded), request)
+		refusal := recorded.Load()
+		if err != nil || result == nil || !result.IsError || refusal == nil {
+			return result, err
+		}
+
+		if result.Meta == nil {
+			result.Meta = mcp.NewMetaFromMap(map[string]any{})
+		}
+		result.Meta.AdditionalFields["refusal"] = map[string]any{"reason": refusal.Reason, "text": refusal.Text}
+		return result, nil
+	}
+}
+
+// recordRefusal notes a refusal for withRefusalMeta.
+func recordRefusal(ctx context.Context, err *RefusalError) {
+	if recorded, ok := ctx.Value(refusalKey{}).(*atomic.Pointer[RefusalError]); ok {
+		recorded.Store(err)
+	}
+}
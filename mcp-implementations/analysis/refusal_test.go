@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const refusalAnswer = "I'm sorry, but I can't help with that request."
+
+func TestAnalyzeFileSurfacesRefusal(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: answers(refusalAnswer)}
+	c := connect(t, s, sampler)
+
+	result, text := callTool(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+
+	if !result.IsError || !strings.Contains(text, "the model refused the request (matched a refusal pattern): "+refusalAnswer) {
+		t.Fatalf("refusal was not returned as an error: %s", text)
+	}
+	if result.Meta == nil {
+		t.Fatal("refusal result has no _meta")
+	}
+	refusal, _ := result.Meta.AdditionalFields["refusal"].(map[string]any)
+	if refusal["reason"] != "matched a refusal pattern" || refusal["text"] != refusalAnswer {
+		t.Errorf("_meta.refusal is %v", result.Meta.AdditionalFields["refusal"])
+	}
+
+	// A refusal is not cached, so asking again samples again
+	callTool(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want 2", n)
+	}
+}
+
+func TestAnalyzeFileRefusalByStopReason(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		result := textAnswer("")
+		result.StopReason = "content_filter"
+		return result, nil
+	}})
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(text, `the model refused the request (stop reason "content_filter")`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestCheckRefusalOnlyLooksAtTheStart(t *testing.T) {
+	for answer, refused := range map[string]bool{
+		refusalAnswer: true,
+		"Unfortunately, I cannot assist with this.":                                        true,
+		"I am unable to analyze this file.":                                                true,
+		"The author writes: \"I can't help with that.\"":                                   false,
+		"Summary: " + strings.Repeat("x", refusalPrefixBytes) + " I can't help with that.": false,
+		"I can see three sections.":                                                        false,
+	} {
+		if got := checkRefusal(textAnswer(answer), DefaultRefusalPatterns) != nil; got != refused {
+			t.Errorf("checkRefusal(%.40q) = %t, want %t", answer, got, refused)
+		}
+	}
+}
+
+func TestConfiguredRefusalPatterns(t *testing.T) {
+	s := newTestServer(t, Config{RefusalPatterns: []*regexp.Regexp{regexp.MustCompile(`^DECLINED`)}}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{respond: answers("DECLINED: policy.", refusalAnswer)})
+
+	if text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false}); !strings.Contains(text, "the model refused the request") {
+		t.Errorf("custom pattern did not match: %s", text)
+	}
+	// The defaults are replaced, not extended
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+}
+
+func TestLoadRefusalPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refusals.txt")
+	if err := os.WriteFile(path, []byte("# Our model's refusals\n\n^As an AI\n(?i)^not permitted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := LoadRefusalPatterns(path)
+	if err != nil || len(patterns) != 2 || !patterns[1].MatchString("Not permitted.") {
+		t.Fatalf("LoadRefusalPatterns() = %v, %v", patterns, err)
+	}
+
+	if err := os.WriteFile(path, []byte("^ok\n[unclosed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRefusalPatterns(path); err == nil || !strings.Contains(err.Error(), "refusals.txt:2:") {
+		t.Errorf("invalid pattern gave %v, want an error naming line 2", err)
+	}
+
+	if err := os.WriteFile(path, []byte("# none\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if patterns, err := LoadRefusalPatterns(path); err != nil || patterns == nil || len(patterns) != 0 {
+		t.Errorf("a file of comments gave %v, %v; want an empty, non-nil list", patterns, err)
+	}
+}
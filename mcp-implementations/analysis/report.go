@@ -0,0 +1,280 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DefaultReportFiles is how many files report analyzes when not given
+	// max_files.
+	DefaultReportFiles = 20
+	// MaxReportFiles bounds max_files, since every file is one sampling
+	// request.
+	MaxReportFiles = 50
+)
+
+// reportColumns are the columns report can fill, each with the instruction
+// the model is given for it.
+var reportColumns = map[string]string{
+	"summary":   "a one-sentence summary",
+	"sentiment": "the overall sentiment: positive, negative, neutral or mixed",
+	"topics":    "up to three main topics, separated by semicolons",
+	"category":  "the kind of document, e.g. report, email, meeting notes, log, source code",
+	"language":  "the document's natural language as an ISO 639-1 code, e.g. en",
+	"audience":  "who the document is written for, in a few words",
+}
+
+// defaultReportColumns are used when report is not given columns.
+var defaultReportColumns = []string{"summary", "sentiment", "topics"}
+
+var reportTool = mcp.Tool{
+	Name:        "report",
+	Description: "Analyze every text file in a directory using LLM sampling and return one row per file (filename, summary, sentiment, topics and so on) as CSV or JSON, ready to import into a spreadsheet",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"directory": map[string]any{
+				"type":        "string",
+				"description": "The directory to report on (relative to files directory; default the top level)",
+			},
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "A glob selecting files within the directory, e.g. *.md (default *)",
+			},
+			"columns": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string", "enum": slices.Sorted(maps.Keys(reportColumns))},
+				"description": fmt.Sprintf("The columns to fill for each file, in order (default %s)", strings.Join(defaultReportColumns, ", ")),
+			},
+			"format": map[string]any{
+				"type":        "string",
+				"description": "csv (default) or json",
+				"enum":        []string{"csv", "json"},
+			},
+			"max_files": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Refuse to run if more files than this match (default %d, max %d)", DefaultReportFiles, MaxReportFiles),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+	},
+}
+
+// ReportRow is one file's row of a report. Values holds the requested
+// columns; a file that could not be analyzed has Error set instead.
+type ReportRow struct {
+	File      string            `json:"file"`
+	Values    map[string]string `json:"values,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// Report is the JSON form of the report tool's result.
+type Report struct {
+	Directory string      `json:"directory"`
+	Pattern   string      `json:"pattern"`
+	Columns   []string    `json:"columns"`
+	Rows      []ReportRow `json:"rows"`
+}
+
+func (s *Server) handleReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	directory := request.GetString("directory", "")
+	pattern := request.GetString("pattern", "*")
+	columns := request.GetStringSlice("columns", defaultReportColumns)
+	format := request.GetString("format", "csv")
+	maxFiles := request.GetInt("max_files", DefaultReportFiles)
+
+	if len(columns) == 0 {
+		return errorResult("columns is empty; name at least one column"), nil
+	}
+	for i, column := range columns {
+		if _, ok := reportColumns[column]; !ok {
+			return errorResult("Unknown column %q (use %s)", column, strings.Join(slices.Sorted(maps.Keys(reportColumns)), ", ")), nil
+		}
+		if slices.Contains(columns[:i], column) {
+			return errorResult("Column %q is listed twice", column), nil
+		}
+	}
+	if format != "csv" && format != "json" {
+		return errorResult("Unknown format %q (use csv or json)", format), nil
+	}
+	if maxFiles < 1 || maxFiles > MaxReportFiles {
+		return errorResult("max_files must be between 1 and %d", MaxReportFiles), nil
+	}
+
+	filenames, omitted, err := s.expandGlob(filepath.Join(directory, pattern))
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	if len(filenames) == 0 {
+		return errorResult("No files match %q", filepath.Join(directory, pattern)), nil
+	}
+	// Checked before anything is sampled, so a broad pattern costs nothing
+	if matched := len(filenames) + omitted; matched > maxFiles {
+		return errorResult("%d files match %q, more than max_files (%d); use a narrower pattern or raise max_files", matched, filepath.Join(directory, pattern), maxFiles), nil
+	}
+	sort.Strings(filenames)
+
+	logf(ctx, "📊 Building a report of %d files with columns %s", len(filenames), strings.Join(columns, ", "))
+	rows := s.reportRows(ctx, filenames, columns)
+
+	failed := 0
+	for _, row := range rows {
+		if row.Error != "" {
+			failed++
+		}
+	}
+	logf(ctx, "✅ Report complete: %d rows, %d failed", len(rows), failed)
+
+	var out string
+	if format == "json" {
+		data, err := json.MarshalIndent(Report{Directory: directory, Pattern: pattern, Columns: columns, Rows: rows}, "", "  ")
+		if err != nil {
+			return errorResult("Error encoding report: %v", err), nil
+		}
+		out = string(data)
+	} else {
+		out, err = reportCSV(rows, columns)
+		if err != nil {
+			return errorResult("Error encoding report: %v", err), nil
+		}
+	}
+
+	result := textResult(out)
+	result.IsError = failed == len(rows)
+	return result, nil
+}
+
+// reportRows analyzes the files with at most MaxConcurrentSampling running
+// at once and returns their rows in the order of filenames.
+func (s *Server) reportRows(ctx context.Context, filenames, columns []string) []ReportRow {
+	rows := make([]ReportRow, len(filenames))
+	slots := make(chan struct{}, s.cfg.MaxConcurrentSampling)
+	var wg sync.WaitGroup
+
+	for i, filename := range filenames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			row := ReportRow{File: filename}
+			values, truncated, err := s.reportRow(ctx, filename, columns)
+			if err != nil {
+				row.Error = err.Error()
+			} else {
+				row.Values, row.Truncated = values, truncated
+			}
+			rows[i] = row
+		}()
+	}
+
+	wg.Wait()
+	return rows
+}
+
+// reportRow asks the model for one file's columns.
+func (s *Server) reportRow(ctx context.Context, filename string, columns []string) (map[string]string, bool, error) {
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	text, truncated := truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	var fields []string
+	for _, column := range columns {
+		fields = append(fields, fmt.Sprintf("%q: %s", column, reportColumns[column]))
+	}
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := "Describe this document for one row of a spreadsheet. " +
+		"Respond with only a JSON object whose values are short plain strings on one line, with these keys: " +
+		strings.Join(fields, "; ") + "."
+	if truncated {
+		systemPrompt += " The document was truncated; describe what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 150 * len(columns)
+
+	logf(ctx, "📤 Sending sampling request for the report row of %s", filename)
+	var values map[string]string
+	_, err = s.sampleJSON(ctx, samplingRequest, "a valid row", func(text string) (err error) {
+		values, err = parseReportRow(text, columns)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return values, truncated, nil
+}
+
+// parseReportRow decodes the model's answer, which needs a value for every
+// column. Values that are not strings, such as a list of topics, are
+// flattened so each fits one spreadsheet cell.
+func parseReportRow(text string, columns []string) (map[string]string, error) {
+	var answer map[string]any
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	values := make(map[string]string, len(columns))
+	for _, column := range columns {
+		var value string
+		switch v := answer[column].(type) {
+		case string:
+			value = v
+		case []any:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			value = strings.Join(items, "; ")
+		case nil:
+		default:
+			value = fmt.Sprint(v)
+		}
+		value = strings.Join(strings.Fields(value), " ")
+		if value == "" {
+			return nil, fmt.Errorf("%s is missing or empty", column)
+		}
+		values[column] = value
+	}
+	return values, nil
+}
+
+// reportCSV writes rows as CSV with a header: file, the columns, then
+// truncated and error.
+func reportCSV(rows []ReportRow, columns []string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(append(append([]string{"file"}, columns...), "truncated", "error"))
+	for _, row := range rows {
+		record := []string{row.File}
+		for _, column := range columns {
+			record = append(record, row.Values[column])
+		}
+		record = append(record, fmt.Sprint(row.Truncated), row.Error)
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
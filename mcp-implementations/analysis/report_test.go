@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// reportFixtures are the files in notes/ with the row the mock model
+// gives for each.
+var reportFixtures = map[string]struct {
+	content, answer string
+}{
+	"notes/launch.md":  {"The launch went well and sales doubled.", `{"summary": "The launch succeeded.", "sentiment": "positive", "topics": ["launch", "sales"]}`},
+	"notes/outage.md":  {"The database was down for six hours.", `{"summary": "A long database outage.", "sentiment": "negative", "topics": "outage; database"}`},
+	"notes/minutes.md": {"Attendees: Ana, Bo. Next meeting on Friday.", "```json\n{\"summary\": \"Meeting minutes.\", \"sentiment\": \"neutral\", \"topics\": \"meetings\"}\n```"},
+}
+
+// reportServer serves reportFixtures, plus a file outside notes/, with a
+// mock model that answers from reportFixtures by file content.
+func reportServer(t *testing.T) (*Server, *mockSampler) {
+	files := map[string]string{"other.md": "Not in the notes directory."}
+	answers := map[string]string{}
+	for name, fixture := range reportFixtures {
+		files[name] = fixture.content
+		answers[fixture.content] = fixture.answer
+	}
+	sampler := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return textAnswer(answers[messageText(request)]), nil
+	}}
+	return newTestServer(t, Config{}, files), sampler
+}
+
+func TestReportCSVRowsMatchFiles(t *testing.T) {
+	s, sampler := reportServer(t)
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "report", map[string]any{"directory": "notes", "pattern": "*.md"})
+
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		t.Fatalf("report is not valid CSV: %v\n%s", err, text)
+	}
+	want := [][]string{
+		{"file", "summary", "sentiment", "topics", "truncated", "error"},
+		{"notes/launch.md", "The launch succeeded.", "positive", "launch; sales", "false", ""},
+		{"notes/minutes.md", "Meeting minutes.", "neutral", "meetings", "false", ""},
+		{"notes/outage.md", "A long database outage.", "negative", "outage; database", "false", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("report has %d records, want %d:\n%s", len(records), len(want), text)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+	if n := len(sampler.Requests()); n != 3 {
+		t.Errorf("%d sampling requests, want one per file", n)
+	}
+}
+
+func TestReportJSONColumns(t *testing.T) {
+	s, sampler := reportServer(t)
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "report", map[string]any{"directory": "notes", "columns": []string{"sentiment", "summary"}, "format": "json"})
+
+	var report Report
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, text)
+	}
+	if report.Directory != "notes" || report.Pattern != "*" || strings.Join(report.Columns, ",") != "sentiment,summary" {
+		t.Errorf("report header = %q, %q, %q", report.Directory, report.Pattern, report.Columns)
+	}
+	if len(report.Rows) != 3 {
+		t.Fatalf("report has %d rows, want 3:\n%s", len(report.Rows), text)
+	}
+	row := report.Rows[1]
+	if row.File != "notes/minutes.md" || row.Values["sentiment"] != "neutral" || row.Values["summary"] != "Meeting minutes." {
+		t.Errorf("unexpected row: %+v", row)
+	}
+	if _, ok := row.Values["topics"]; ok {
+		t.Errorf("row has a column that was not asked for: %+v", row)
+	}
+	if prompt := sampler.Requests()[0].SystemPrompt; !strings.Contains(prompt, `"sentiment"`) || strings.Contains(prompt, `"topics"`) {
+		t.Errorf("prompt does not ask for exactly the columns: %s", prompt)
+	}
+}
+
+func TestReportRowErrors(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"good.md": "fine", "bad.md": "broken"})
+	sampler := &mockSampler{respond: func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if messageText(request) == "broken" {
+			return textAnswer(`{"summary": "", "sentiment": "neutral", "topics": "x"}`), nil
+		}
+		return textAnswer(`{"summary": "Fine.", "sentiment": "neutral", "topics": "x"}`), nil
+	}}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "report", map[string]any{"format": "json"})
+
+	var report Report
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatal(err)
+	}
+	bad, good := report.Rows[0], report.Rows[1]
+	if bad.File != "bad.md" || !strings.Contains(bad.Error, "summary is missing or empty") || bad.Values != nil {
+		t.Errorf("unexpected row for the bad file: %+v", bad)
+	}
+	if good.Error != "" || good.Values["summary"] != "Fine." {
+		t.Errorf("unexpected row for the good file: %+v", good)
+	}
+	// The bad file is asked again once before its row gives up
+	if n := len(sampler.Requests()); n != 3 {
+		t.Errorf("%d sampling requests, want 3", n)
+	}
+}
+
+func TestReportRefusals(t *testing.T) {
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"directory": "notes", "max_files": 2}, "3 files match \"notes/*\", more than max_files (2)"},
+		{map[string]any{"max_files": MaxReportFiles + 1}, "max_files must be between 1 and 50"},
+		{map[string]any{"columns": []string{"summary", "mood"}}, `Unknown column "mood"`},
+		{map[string]any{"columns": []string{"summary", "summary"}}, `Column "summary" is listed twice`},
+		{map[string]any{"columns": []string{}}, "columns is empty"},
+		{map[string]any{"format": "xlsx"}, `Unknown format "xlsx"`},
+		{map[string]any{"directory": "notes", "pattern": "*.pdf"}, `No files match "notes/*.pdf"`},
+	}
+	s, sampler := reportServer(t)
+	c := connect(t, s, sampler)
+
+	for _, tt := range tests {
+		if text := mustFail(t, c, "report", tt.args); !strings.Contains(text, tt.want) {
+			t.Errorf("%v: error %q does not mention %q", tt.args, text, tt.want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("refused reports sent %d sampling requests", n)
+	}
+}
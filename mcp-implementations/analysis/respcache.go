@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// The provider-response cache sits under the analysis cache. The analysis
+// cache keys on every tool argument, so summarizing and then explaining a
+// file are two misses even when the sampling requests are the same. This
+// tier keys on what is actually sent (content, model and prompt), so any
+// two analyses that produce the same sampling request share one provider
+// call. It only serves the tools that expose use_cache, analyze_file and
+// analyze_batch; every other tool always reaches the client.
+
+// useResponseCacheKey marks a context whose sampling requests may be
+// answered from the provider-response cache.
+type useResponseCacheKey struct{}
+
+// withResponseCache returns a context whose sampling requests go through
+// the provider-response cache.
+func withResponseCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, useResponseCacheKey{}, true)
+}
+
+// cachedResponse is the part of a sampling result kept in the cache.
+type cachedResponse struct {
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Text       string `json:"text"`
+}
+
+// responseCacheKey hashes a sampling request down to what determines the
+// answer: each message's role and content, the model hints, the system
+// prompt with whitespace normalized, the sampling parameters and the
+// caller's API key, hashed. It reports false for requests that should not
+// be cached: those made outside withResponseCache or asking for the raw
+// provider response.
+func responseCacheKey(ctx context.Context, request mcp.CreateMessageRequest) (string, bool) {
+	if use, _ := ctx.Value(useResponseCacheKey{}).(bool); !use {
+		return "", false
+	}
+	metadata, _ := request.Metadata.(map[string]any)
+	if debugRaw, _ := metadata["debug_raw"].(bool); debugRaw {
+		return "", false
+	}
+	// Tool calls travel in _meta, which is not cached
+	if metadata["tools"] != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "caller=%s\x00", callerKeyHash(ctx))
+	for _, message := range request.Messages {
+		content, err := json.Marshal(message.Content)
+		if err != nil {
+			return "", false
+		}
+		contentHash := sha256.Sum256(content)
+		fmt.Fprintf(h, "%s\x00%x\x00", message.Role, contentHash)
+	}
+	if prefs := request.ModelPreferences; prefs != nil {
+		for _, hint := range prefs.Hints {
+			fmt.Fprintf(h, "model=%s\x00", hint.Name)
+		}
+	}
+	fmt.Fprintf(h, "%s\x00%g\x00%d\x00%v", strings.Join(strings.Fields(request.SystemPrompt), " "),
+		request.Temperature, request.MaxTokens, metadata["seed"])
+	return "response-" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cachedSampling returns a cached result for the request key, if any.
+func (s *Server) cachedSampling(key string) (*mcp.CreateMessageResult, bool) {
+	entry, ok, err := s.cfg.Cache.Get(key)
+	if err != nil {
+		log.Printf("Warning: Response cache lookup failed: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal([]byte(entry.Result), &cached); err != nil {
+		return nil, false
+	}
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.TextContent{Type: "text", Text: cached.Text},
+		},
+		Model:      cached.Model,
+		StopReason: cached.StopReason,
+	}, true
+}
+
+// cacheSampling stores a text sampling result under the request key.
+func (s *Server) cacheSampling(key string, result *mcp.CreateMessageResult) {
+	textContent, ok := result.Content.(mcp.TextContent)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(cachedResponse{Model: result.Model, StopReason: result.StopReason, Text: textContent.Text})
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	err = s.cfg.Cache.Set(CacheEntry{
+		Key:       key,
+		Result:    string(data),
+		Metadata:  map[string]string{"tier": "provider_response", "model": result.Model},
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.CacheTTL),
+	})
+	if err != nil {
+		log.Printf("Warning: Could not cache provider response: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestResponseCacheSharedAcrossAnalyses(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	summarize, _ := lookupAnalysisType("summarize")
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "analysis_type": "summarize"})
+	// Different arguments, so a result cache miss, but the same sampling
+	// request once the prompt's whitespace is normalized
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{
+		"filename":      "notes.txt",
+		"custom_prompt": "  " + strings.ReplaceAll(summarize.Prompt, " ", "\n  "),
+	})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "redact": "patterns"})
+
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want the three analyses to share one", n)
+	}
+	if !strings.Contains(text, mockAnswer) {
+		t.Errorf("the shared response was not returned:\n%s", text)
+	}
+}
+
+func TestResponseCacheMissesOnDifferentRequest(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes.", "other.txt": "Other notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	calls := []map[string]any{
+		{"filename": "notes.txt"},
+		{"filename": "notes.txt", "analysis_type": "explain"},
+		{"filename": "notes.txt", "model": "smart"},
+		{"filename": "notes.txt", "temperature": 0.9},
+		{"filename": "notes.txt", "api_key": "tenant-key"},
+		{"filename": "other.txt"},
+		{"filename": "notes.txt", "debug_raw": true},
+	}
+	for _, args := range calls {
+		mustSucceed(t, c, "analyze_file", args)
+	}
+	if n := len(sampler.Requests()); n != len(calls) {
+		t.Errorf("got %d sampling requests, want %d: each call differs in content, prompt, model, parameters or caller", n, len(calls))
+	}
+}
+
+func TestResponseCacheOnlyForUseCacheTools(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: answers(`{"category": "notes", "confidence": 1}`)}
+	c := connect(t, s, sampler)
+
+	args := map[string]any{"filename": "notes.txt", "categories": []string{"notes", "code"}}
+	mustSucceed(t, c, "classify_file", args)
+	mustSucceed(t, c, "classify_file", args)
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "use_cache": false})
+
+	if n := len(sampler.Requests()); n != 4 {
+		t.Errorf("got %d sampling requests, want 4: only use_cache calls may be answered from the cache", n)
+	}
+}
+
+func TestResponseCacheSkipsPartialResults(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		result := textAnswer("The notes cover")
+		result.Meta = mcp.NewMetaFromMap(map[string]any{"partial": map[string]any{"reason": "context canceled"}})
+		return result, nil
+	}}
+	c := connect(t, s, sampler)
+	logs := captureLogs(t)
+
+	// use_cache is left at its default, so both caches are in play
+	for range 2 {
+		result, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+		if !strings.Contains(text, "The notes cover") {
+			t.Errorf("the partial text was not returned:\n%s", text)
+		}
+		if !strings.HasSuffix(text, PartialNote) {
+			t.Errorf("the partial result is not marked in its text:\n%s", text)
+		}
+		if result.Meta == nil {
+			t.Fatalf("the partial result has no _meta")
+		}
+		partial, _ := result.Meta.AdditionalFields["partial"].(map[string]any)
+		if partial["reason"] != "context canceled" {
+			t.Errorf("_meta.partial = %v, want the client's reason", result.Meta.AdditionalFields)
+		}
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("got %d sampling requests, want a partial result never served from a cache", n)
+	}
+	if !strings.Contains(logs.String(), "Sampling result is partial, the client's stream was interrupted: context canceled") {
+		t.Errorf("the partial result was not logged:\n%s", logs)
+	}
+}
+
+func TestCompleteResultIsNotMarkedPartial(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	result, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if strings.Contains(text, PartialNote) {
+		t.Errorf("a complete result is marked partial:\n%s", text)
+	}
+	if result.Meta != nil && result.Meta.AdditionalFields["partial"] != nil {
+		t.Errorf("a complete result has _meta.partial: %v", result.Meta.AdditionalFields)
+	}
+}
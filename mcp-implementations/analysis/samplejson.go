@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sampleJSON sends request and hands the response text to parse. If parse
+// rejects it, the request is sent once more with the reason appended to the
+// system prompt, and an answer that fails again is an error; want names
+// the expected answer in that error, as in "a valid quiz". It returns the
+// result whose text parse accepted. No MaxTokensNote is appended to the
+// JSON; an accepted answer that was cut off at max_tokens is flagged in
+// the tool result's _meta instead.
+func (s *Server) sampleJSON(ctx context.Context, request mcp.CreateMessageRequest, want string, parse func(text string) error) (*mcp.CreateMessageResult, error) {
+	samplingCtx := context.WithValue(ctx, structuredAnswerKey{}, true)
+	for attempt := 1; ; attempt++ {
+		result, err := s.requestSampling(samplingCtx, request)
+		if err != nil {
+			log.Printf("❌ Sampling request failed: %v", err)
+			return nil, fmt.Errorf("Error requesting sampling: %v", err)
+		}
+
+		err = parse(resultText(result))
+		if err == nil {
+			if stoppedAtMaxTokens(result) {
+				recordMaxTokens(ctx)
+			}
+			return result, nil
+		}
+
+		log.Printf("Malformed answer, expected %s: %v", want, err)
+		if attempt == 2 {
+			return nil, fmt.Errorf("The model did not return %s after a retry: %v", want, err)
+		}
+		logf(ctx, "🔁 Asking again for %s (attempt %d)", want, attempt+1)
+		request.SystemPrompt += fmt.Sprintf(" Your previous answer was invalid (%v). Respond again with only the JSON object.", err)
+	}
+}
@@ -0,0 +1,250 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DefaultMaxScore is the top of the scale each criterion is scored on
+	// when score_content is not given max_score.
+	DefaultMaxScore = 10
+	// MaxRubricCriteria bounds the rubric so one answer fits in MaxTokens.
+	MaxRubricCriteria = 20
+	// totalTolerance is how far the model's total may be from the weighted
+	// sum before it is reported as corrected, allowing for rounding.
+	totalTolerance = 0.05
+)
+
+var scoreContentTool = mcp.Tool{
+	Name:        "score_content",
+	Description: "Grade a text file against a rubric of weighted criteria using LLM sampling. Returns JSON with a score and rationale per criterion and the weighted total, which the server recomputes",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to grade (relative to files directory)",
+			},
+			"rubric": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":        map[string]any{"type": "string", "description": "Short name of the criterion, e.g. clarity"},
+						"description": map[string]any{"type": "string", "description": "What earns a high score (optional)"},
+						"weight":      map[string]any{"type": "number", "description": "Relative weight, greater than 0"},
+					},
+					"required": []string{"name", "weight"},
+				},
+				"description": fmt.Sprintf("The criteria to score (at most %d)", MaxRubricCriteria),
+			},
+			"max_score": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Each criterion is scored from 0 to this (default %d, max 100)", DefaultMaxScore),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename", "rubric"},
+	},
+}
+
+// RubricCriterion is one criterion of a score_content rubric.
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Weight      float64 `json:"weight"`
+}
+
+// CriterionScore is the model's score for one criterion.
+type CriterionScore struct {
+	Criterion string  `json:"criterion"`
+	Weight    float64 `json:"weight"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+// ContentScore is the structured result of score_content. Total is the
+// weighted mean of the scores, on the same 0 to MaxScore scale, computed
+// by the server. ModelTotal is set when the model's own total disagreed.
+type ContentScore struct {
+	File       string           `json:"file"`
+	Model      string           `json:"model"`
+	MaxScore   int              `json:"max_score"`
+	Scores     []CriterionScore `json:"scores"`
+	Total      float64          `json:"total"`
+	ModelTotal *float64         `json:"model_total,omitempty"`
+	// Truncated reports that only part of the file was graded
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleScoreContent(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	var args struct {
+		Rubric []RubricCriterion `json:"rubric"`
+	}
+	if err := request.BindArguments(&args); err != nil {
+		return errorResult("Invalid rubric: %v", err), nil
+	}
+	rubric, err := validateRubric(args.Rubric)
+	if err != nil {
+		return errorResult("Invalid rubric: %v", err), nil
+	}
+	maxScore := request.GetInt("max_score", DefaultMaxScore)
+	if maxScore < 1 || maxScore > 100 {
+		return errorResult("max_score must be between 1 and 100"), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	score := ContentScore{File: filename, MaxScore: maxScore}
+	text, score.Truncated = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	var criteria strings.Builder
+	for _, c := range rubric {
+		fmt.Fprintf(&criteria, "- %s (weight %g)", c.Name, c.Weight)
+		if c.Description != "" {
+			fmt.Fprintf(&criteria, ": %s", c.Description)
+		}
+		criteria.WriteString("\n")
+	}
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := fmt.Sprintf("Grade this document against the rubric below. Score each criterion from 0 to %d, "+
+		"where %d fully meets it, with a one-sentence rationale. Then give the weighted total: "+
+		"the sum of each score times its weight, divided by the sum of the weights.\n\nRubric:\n%s\n"+
+		`Respond with only a JSON object: {"scores": [{"criterion": "<name as given>", "score": <number>, "rationale": "..."}], "total": <number>}.`,
+		maxScore, maxScore, criteria.String())
+	if score.Truncated {
+		systemPrompt += " The document was truncated; grade only what is shown."
+	}
+
+	var modelTotal float64
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 100*len(rubric) + 200
+
+	logf(ctx, "📤 Sending sampling request to score %s on %d criteria", filename, len(rubric))
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid scores", func(text string) (err error) {
+		score.Scores, modelTotal, err = parseCriterionScores(text, rubric, maxScore)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	score.Model = result.Model
+
+	// The model's arithmetic is not trusted; the total is recomputed
+	score.Total = weightedTotal(score.Scores)
+	if math.Abs(modelTotal-score.Total) > totalTolerance {
+		log.Printf("⚠️  Model's total %g differs from the weighted sum %g; using the weighted sum", modelTotal, score.Total)
+		score.ModelTotal = &modelTotal
+	}
+	logf(ctx, "✅ Scored %s: %g of %d", filename, score.Total, maxScore)
+
+	data, err := json.MarshalIndent(score, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding scores: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// validateRubric checks that the rubric has between one and
+// MaxRubricCriteria criteria with distinct names and positive weights,
+// and trims their text.
+func validateRubric(rubric []RubricCriterion) ([]RubricCriterion, error) {
+	if len(rubric) == 0 {
+		return nil, fmt.Errorf("it has no criteria")
+	}
+	if len(rubric) > MaxRubricCriteria {
+		return nil, fmt.Errorf("%d criteria is more than the limit of %d", len(rubric), MaxRubricCriteria)
+	}
+	seen := map[string]bool{}
+	for i, c := range rubric {
+		c.Name, c.Description = strings.TrimSpace(c.Name), strings.TrimSpace(c.Description)
+		switch {
+		case c.Name == "":
+			return nil, fmt.Errorf("criterion %d has no name", i+1)
+		case seen[strings.ToLower(c.Name)]:
+			return nil, fmt.Errorf("criterion %q is listed twice", c.Name)
+		case !(c.Weight > 0) || math.IsInf(c.Weight, 0):
+			return nil, fmt.Errorf("criterion %q needs a weight greater than 0", c.Name)
+		}
+		seen[strings.ToLower(c.Name)] = true
+		rubric[i] = c
+	}
+	return rubric, nil
+}
+
+// parseCriterionScores decodes the model's scores and total. Every
+// criterion must be scored exactly once, within 0 to maxScore; scores are
+// returned in rubric order with the rubric's names and weights.
+func parseCriterionScores(text string, rubric []RubricCriterion, maxScore int) ([]CriterionScore, float64, error) {
+	var answer struct {
+		Scores []struct {
+			Criterion string   `json:"criterion"`
+			Score     *float64 `json:"score"`
+			Rationale string   `json:"rationale"`
+		} `json:"scores"`
+		Total *float64 `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, 0, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if answer.Total == nil {
+		return nil, 0, fmt.Errorf("total is missing")
+	}
+
+	byName := map[string]int{}
+	for i, c := range rubric {
+		byName[strings.ToLower(c.Name)] = i
+	}
+	scores := make([]CriterionScore, len(rubric))
+	scored := make([]bool, len(rubric))
+	for _, s := range answer.Scores {
+		i, ok := byName[strings.ToLower(strings.TrimSpace(s.Criterion))]
+		switch {
+		case !ok:
+			return nil, 0, fmt.Errorf("%q is not a rubric criterion", s.Criterion)
+		case scored[i]:
+			return nil, 0, fmt.Errorf("%s is scored twice", rubric[i].Name)
+		case s.Score == nil:
+			return nil, 0, fmt.Errorf("%s has no score", rubric[i].Name)
+		case *s.Score < 0 || *s.Score > float64(maxScore):
+			return nil, 0, fmt.Errorf("%s scored %g, outside 0 to %d", rubric[i].Name, *s.Score, maxScore)
+		}
+		scored[i] = true
+		scores[i] = CriterionScore{Criterion: rubric[i].Name, Weight: rubric[i].Weight, Score: *s.Score, Rationale: strings.TrimSpace(s.Rationale)}
+	}
+	for i, ok := range scored {
+		if !ok {
+			return nil, 0, fmt.Errorf("%s is not scored", rubric[i].Name)
+		}
+	}
+	return scores, *answer.Total, nil
+}
+
+// weightedTotal is the weighted mean of the scores, rounded to two
+// decimal places.
+func weightedTotal(scores []CriterionScore) float64 {
+	var sum, weights float64
+	for _, s := range scores {
+		sum += s.Score * s.Weight
+		weights += s.Weight
+	}
+	return math.Round(sum/weights*100) / 100
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// essayRubric weights clarity three times as much as the other criteria.
+var essayRubric = []map[string]any{
+	{"name": "clarity", "description": "Easy to follow", "weight": 3},
+	{"name": "accuracy", "weight": 1},
+	{"name": "style", "weight": 1},
+}
+
+// scoreOf decodes a score_content result.
+func scoreOf(t *testing.T, text string) ContentScore {
+	t.Helper()
+	var score ContentScore
+	if err := json.Unmarshal([]byte(text), &score); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	return score
+}
+
+func TestScoreContentWeightedTotal(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.md": "An essay."})
+	// Out of rubric order and in another case, which is still accepted
+	sampler := &mockSampler{respond: answers(`{"scores": [
+		{"criterion": "Style", "score": 5, "rationale": " Plain. "},
+		{"criterion": "clarity", "score": 8, "rationale": "Clear."},
+		{"criterion": "accuracy", "score": 6, "rationale": "One error."}
+	], "total": 7}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "score_content", map[string]any{"filename": "essay.md", "rubric": essayRubric})
+
+	score := scoreOf(t, text)
+	// (8*3 + 6*1 + 5*1) / 5
+	if score.Total != 7 || score.ModelTotal != nil || score.MaxScore != DefaultMaxScore {
+		t.Errorf("total %g, model total %v, max %d; want 7 agreeing with the model", score.Total, score.ModelTotal, score.MaxScore)
+	}
+	want := []CriterionScore{
+		{Criterion: "clarity", Weight: 3, Score: 8, Rationale: "Clear."},
+		{Criterion: "accuracy", Weight: 1, Score: 6, Rationale: "One error."},
+		{Criterion: "style", Weight: 1, Score: 5, Rationale: "Plain."},
+	}
+	if len(score.Scores) != len(want) {
+		t.Fatalf("%d scores, want %d", len(score.Scores), len(want))
+	}
+	for i := range want {
+		if score.Scores[i] != want[i] {
+			t.Errorf("score %d = %+v, want %+v", i+1, score.Scores[i], want[i])
+		}
+	}
+	prompt := sampler.Requests()[0].SystemPrompt
+	if !strings.Contains(prompt, "- clarity (weight 3): Easy to follow\n- accuracy (weight 1)\n") || !strings.Contains(prompt, "from 0 to 10") {
+		t.Errorf("prompt does not give the rubric:\n%s", prompt)
+	}
+}
+
+func TestScoreContentRecomputesWrongTotal(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.md": "An essay."})
+	c := connect(t, s, &mockSampler{respond: answers(`{"scores": [
+		{"criterion": "clarity", "score": 8, "rationale": "Clear."},
+		{"criterion": "accuracy", "score": 6, "rationale": "One error."},
+		{"criterion": "style", "score": 5, "rationale": "Plain."}
+	], "total": 6.33}`)})
+
+	_, text := mustSucceed(t, c, "score_content", map[string]any{"filename": "essay.md", "rubric": essayRubric})
+
+	// 6.33 is the unweighted mean
+	score := scoreOf(t, text)
+	if score.Total != 7 || score.ModelTotal == nil || *score.ModelTotal != 6.33 {
+		t.Errorf("total %g, model total %v; want 7 with the model's 6.33 reported", score.Total, score.ModelTotal)
+	}
+}
+
+func TestScoreContentRetriesOutOfRangeScore(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"essay.md": "An essay."})
+	sampler := &mockSampler{respond: answers(
+		`{"scores": [{"criterion": "clarity", "score": 4, "rationale": "Clear."}], "total": 4}`,
+		`{"scores": [{"criterion": "clarity", "score": 3, "rationale": "Clear."}], "total": 3}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "score_content", map[string]any{
+		"filename":  "essay.md",
+		"rubric":    []map[string]any{{"name": "clarity", "weight": 1}},
+		"max_score": 3,
+	})
+
+	if score := scoreOf(t, text); score.Total != 3 || score.MaxScore != 3 {
+		t.Errorf("unexpected score: %+v", score)
+	}
+	if prompt := sampler.Requests()[1].SystemPrompt; !strings.Contains(prompt, "clarity scored 4, outside 0 to 3") {
+		t.Errorf("the retry does not give the reason: %s", prompt)
+	}
+}
+
+func TestParseCriterionScoresRejectsBadScores(t *testing.T) {
+	rubric := []RubricCriterion{{Name: "clarity", Weight: 2}, {Name: "style", Weight: 1}}
+	tests := map[string]string{
+		`{"scores": [{"criterion": "clarity", "score": 5}, {"criterion": "style", "score": 5}]}`:               "total is missing",
+		`{"scores": [{"criterion": "clarity", "score": 5}], "total": 5}`:                                       "style is not scored",
+		`{"scores": [{"criterion": "clarity", "score": 5}, {"criterion": "CLARITY", "score": 6}], "total": 5}`: "clarity is scored twice",
+		`{"scores": [{"criterion": "clarity", "score": 5}, {"criterion": "tone", "score": 5}], "total": 5}`:    `"tone" is not a rubric criterion`,
+		`{"scores": [{"criterion": "clarity"}, {"criterion": "style", "score": 5}], "total": 5}`:               "clarity has no score",
+		`{"scores": [{"criterion": "clarity", "score": -1}, {"criterion": "style", "score": 5}], "total": 5}`:  "clarity scored -1, outside 0 to 10",
+	}
+	for answer, want := range tests {
+		if _, _, err := parseCriterionScores(answer, rubric, 10); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", answer, err, want)
+		}
+	}
+}
+
+func TestScoreContentValidatesRubric(t *testing.T) {
+	tests := []struct {
+		rubric any
+		want   string
+	}{
+		{[]map[string]any{}, "Invalid rubric: it has no criteria"},
+		{[]map[string]any{{"name": " ", "weight": 1}}, "criterion 1 has no name"},
+		{[]map[string]any{{"name": "clarity", "weight": 1}, {"name": "Clarity", "weight": 2}}, `criterion "Clarity" is listed twice`},
+		{[]map[string]any{{"name": "clarity", "weight": 0}}, `criterion "clarity" needs a weight greater than 0`},
+		{[]map[string]any{{"name": "clarity"}}, `criterion "clarity" needs a weight greater than 0`},
+		{[]map[string]any{{"name": "clarity", "weight": "heavy"}}, "Invalid rubric"},
+		{make([]map[string]any, MaxRubricCriteria+1), "21 criteria is more than the limit of 20"},
+	}
+	s := newTestServer(t, Config{}, map[string]string{"essay.md": "An essay."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, tt := range tests {
+		if text := mustFail(t, c, "score_content", map[string]any{"filename": "essay.md", "rubric": tt.rubric}); !strings.Contains(text, tt.want) {
+			t.Errorf("%v: error %q does not mention %q", tt.rubric, text, tt.want)
+		}
+	}
+	for _, maxScore := range []int{0, 101} {
+		if text := mustFail(t, c, "score_content", map[string]any{"filename": "essay.md", "rubric": essayRubric, "max_score": maxScore}); !strings.Contains(text, "max_score must be between 1 and 100") {
+			t.Errorf("max_score %d: unexpected error %s", maxScore, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid rubrics sent %d sampling requests", n)
+	}
+}
+
+func TestWeightedTotalRounds(t *testing.T) {
+	scores := []CriterionScore{{Score: 7, Weight: 1}, {Score: 8, Weight: 1}, {Score: 8, Weight: 1}}
+	if got := weightedTotal(scores); got != 7.67 {
+		t.Errorf("weightedTotal = %g, want 7.67", got)
+	}
+}
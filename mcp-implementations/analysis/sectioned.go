@@ -0,0 +1,266 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultSectionLevel is the deepest Markdown heading that starts its own
+// section in sectioned_summary; deeper headings stay inside their parent's.
+const DefaultSectionLevel = 2
+
+var sectionedSummaryTool = mcp.Tool{
+	Name:        "sectioned_summary",
+	Description: "Summarize a document section by section, plus an overall summary, returned as JSON keyed by section title. Sections come from Markdown headings or, for other files, are identified by the model, using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to summarize (relative to files directory)",
+			},
+			"max_level": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("For Markdown: the deepest heading level that starts its own section, 1 to 6 (default %d)", DefaultSectionLevel),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// SectionSummary is the summary of one section of a document.
+type SectionSummary struct {
+	Title   string
+	Summary string
+}
+
+// SectionSummaries encode as a JSON object from title to summary, in
+// document order. A repeated title gets a " (2)", " (3)" suffix so no
+// section is lost.
+type SectionSummaries []SectionSummary
+
+func (sections SectionSummaries) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	seen := map[string]int{}
+	for i, section := range sections {
+		title := section.Title
+		if seen[title]++; seen[title] > 1 {
+			title = fmt.Sprintf("%s (%d)", title, seen[title])
+		}
+		key, err := json.Marshal(title)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(section.Summary)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// SectionedSummary is the structured result of sectioned_summary. Source
+// is "headings" when the sections are the document's Markdown headings and
+// "model" when the model identified them.
+type SectionedSummary struct {
+	File     string           `json:"file"`
+	Source   string           `json:"source"`
+	Model    string           `json:"model"`
+	Overall  string           `json:"overall"`
+	Sections SectionSummaries `json:"sections"`
+	// Truncated reports that section text was cut to fit one request
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleSectionedSummary(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	maxLevel := request.GetInt("max_level", DefaultSectionLevel)
+	if maxLevel < 1 || maxLevel > 6 {
+		return errorResult("max_level must be between 1 and 6"), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	summary := SectionedSummary{File: filename}
+	if isMarkdown(filename) {
+		if sections := foldHeadings(parseMarkdownHeadings(text), maxLevel); len(sections) > 0 {
+			summary.Source = "headings"
+			err = s.summarizeHeadingSections(ctx, filename, sections, &summary)
+		}
+	}
+	if summary.Source == "" {
+		summary.Source = "model"
+		err = s.summarizeDetectedSections(ctx, filename, text, &summary)
+	}
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	logf(ctx, "✅ Summarized %d sections of %s (%s)", len(summary.Sections), filename, summary.Source)
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding sectioned summary: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// foldHeadings keeps the headings at maxLevel or above as sections and
+// folds each deeper heading, with its text, into the section before it.
+func foldHeadings(headings []markdownHeading, maxLevel int) []markdownHeading {
+	var sections []markdownHeading
+	for _, h := range headings {
+		if h.Level > maxLevel && len(sections) > 0 {
+			last := &sections[len(sections)-1]
+			last.Body += strings.Repeat("#", h.Level) + " " + h.Title + "\n" + h.Body
+			continue
+		}
+		sections = append(sections, h)
+	}
+	return sections
+}
+
+// summarizeHeadingSections summarizes Markdown sections in one request,
+// trimming each so that together they fit in one chunk.
+func (s *Server) summarizeHeadingSections(ctx context.Context, filename string, sections []markdownHeading, summary *SectionedSummary) error {
+	budget := max(s.cfg.ChunkSize/len(sections), 200)
+	var b strings.Builder
+	for i, h := range sections {
+		body := strings.TrimSpace(h.Body)
+		if len(body) > budget {
+			body = headOf(body, budget) + "..."
+			summary.Truncated = true
+		}
+		fmt.Fprintf(&b, "<section number=\"%d\" title=%q>\n%s\n</section>\n", i+1, h.Title, body)
+	}
+
+	content := mcp.TextContent{Type: "text", Text: b.String()}
+	systemPrompt := fmt.Sprintf("These are the %d sections of '%s', in order. Summarize each section in one to three sentences, "+
+		"then the whole document in one paragraph. "+
+		`Respond with only a JSON object: {"overall": "<document summary>", "summaries": ["<section 1 summary>", ...]} with exactly one summary per section, in order. `+
+		"A section with no text of its own gets an empty string.", len(sections), filename)
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 150*len(sections) + 400
+
+	logf(ctx, "📤 Sending sampling request to summarize %d sections of: %s", len(sections), filename)
+	var summaries []string
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid sectioned summary", func(text string) (err error) {
+		summary.Overall, summaries, err = parseSectionSummaries(text, len(sections))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	summary.Model = result.Model
+	for i, h := range sections {
+		summary.Sections = append(summary.Sections, SectionSummary{Title: h.Title, Summary: summaries[i]})
+	}
+	return nil
+}
+
+// parseSectionSummaries decodes the overall summary and exactly want
+// section summaries, in order.
+func parseSectionSummaries(text string, want int) (string, []string, error) {
+	var answer struct {
+		Overall   string   `json:"overall"`
+		Summaries []string `json:"summaries"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return "", nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if strings.TrimSpace(answer.Overall) == "" {
+		return "", nil, fmt.Errorf("overall is empty")
+	}
+	if len(answer.Summaries) != want {
+		return "", nil, fmt.Errorf("got %d summaries for %d sections", len(answer.Summaries), want)
+	}
+	for i := range answer.Summaries {
+		answer.Summaries[i] = strings.TrimSpace(answer.Summaries[i])
+	}
+	return strings.TrimSpace(answer.Overall), answer.Summaries, nil
+}
+
+// summarizeDetectedSections asks the model to find the sections of a
+// document without headings it can read and summarize them.
+func (s *Server) summarizeDetectedSections(ctx context.Context, filename, text string, summary *SectionedSummary) error {
+	text, summary.Truncated = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := "Divide this document into its main sections, in order, using its own headings where it has them " +
+		"and short descriptive titles where it does not. Summarize each section in one to three sentences, then the whole document in one paragraph. " +
+		`Respond with only a JSON object: {"overall": "<document summary>", "sections": [{"title": "<section title>", "summary": "<section summary>"}]}.`
+	if summary.Truncated {
+		systemPrompt += " The document was truncated; summarize only what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 2000
+
+	logf(ctx, "📤 Sending sampling request to find and summarize the sections of: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid sectioned summary", func(text string) (err error) {
+		summary.Overall, summary.Sections, err = parseDetectedSections(text)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	summary.Model = result.Model
+	return nil
+}
+
+// parseDetectedSections decodes the model's sections, each of which needs
+// a title and a summary.
+func parseDetectedSections(text string) (string, SectionSummaries, error) {
+	var answer struct {
+		Overall  string `json:"overall"`
+		Sections []struct {
+			Title   string `json:"title"`
+			Summary string `json:"summary"`
+		} `json:"sections"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return "", nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if strings.TrimSpace(answer.Overall) == "" {
+		return "", nil, fmt.Errorf("overall is empty")
+	}
+	if len(answer.Sections) == 0 {
+		return "", nil, fmt.Errorf("no sections")
+	}
+
+	var sections SectionSummaries
+	for i, section := range answer.Sections {
+		title, summary := strings.TrimSpace(section.Title), strings.TrimSpace(section.Summary)
+		if title == "" || summary == "" {
+			return "", nil, fmt.Errorf("section %d needs a title and a summary", i+1)
+		}
+		sections = append(sections, SectionSummary{Title: title, Summary: summary})
+	}
+	return strings.TrimSpace(answer.Overall), sections, nil
+}
@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// handbook is a multi-section Markdown fixture: text before the first
+// heading, an h3 inside Setup and a repeated title.
+const handbook = `Draft, do not share.
+
+# Handbook
+How the team works.
+
+## Setup
+Install Go.
+
+### Linux
+Use the package manager.
+
+## Usage
+Run the tool.
+
+## Usage
+Run it again with -v.
+`
+
+// sectionedOf decodes a sectioned_summary result, keeping the section
+// titles in the order they appear.
+func sectionedOf(t *testing.T, text string) (SectionedSummary, []string, map[string]string) {
+	t.Helper()
+	var result struct {
+		SectionedSummary
+		Sections json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	var sections map[string]string
+	if err := json.Unmarshal(result.Sections, &sections); err != nil {
+		t.Fatalf("sections is not an object: %v\n%s", err, result.Sections)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(result.Sections)))
+	dec.Token()
+	var titles []string
+	for dec.More() {
+		key, _ := dec.Token()
+		dec.Token()
+		titles = append(titles, key.(string))
+	}
+	return result.SectionedSummary, titles, sections
+}
+
+func TestSectionedSummaryFromHeadings(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"handbook.md": handbook})
+	sampler := &mockSampler{respond: answers(`{"overall": "A team handbook.", "summaries": ["Introduces the team.", "How to install.", "How to run.", "Verbose runs."]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "sectioned_summary", map[string]any{"filename": "handbook.md"})
+
+	summary, titles, sections := sectionedOf(t, text)
+	if summary.Source != "headings" || summary.Overall != "A team handbook." || summary.Model != "mock-model" || summary.Truncated {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if got := strings.Join(titles, "|"); got != "Handbook|Setup|Usage|Usage (2)" {
+		t.Errorf("sections are %s, want the h1 and h2 headings in order", got)
+	}
+	if sections["Setup"] != "How to install." || sections["Usage (2)"] != "Verbose runs." {
+		t.Errorf("summaries are not keyed by their section: %v", sections)
+	}
+
+	sent := messageText(sampler.Requests()[0])
+	if strings.Contains(sent, "Draft, do not share.") {
+		t.Errorf("text before the first heading was sent:\n%s", sent)
+	}
+	setup := sent[strings.Index(sent, `title="Setup"`):strings.Index(sent, `title="Usage"`)]
+	if !strings.Contains(setup, "### Linux\nUse the package manager.") {
+		t.Errorf("the h3 was not folded into its h2 section:\n%s", setup)
+	}
+	if !strings.Contains(sampler.Requests()[0].SystemPrompt, "These are the 4 sections of 'handbook.md'") {
+		t.Errorf("prompt does not give the section count: %s", sampler.Requests()[0].SystemPrompt)
+	}
+}
+
+func TestSectionedSummaryMaxLevel(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"handbook.md": handbook})
+	sampler := &mockSampler{respond: answers(`{"overall": "A handbook.", "summaries": ["a", "b", "c", "d", "e"]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "sectioned_summary", map[string]any{"filename": "handbook.md", "max_level": 3})
+
+	if _, titles, _ := sectionedOf(t, text); strings.Join(titles, "|") != "Handbook|Setup|Linux|Usage|Usage (2)" {
+		t.Errorf("sections are %q, want the h3 as its own section", titles)
+	}
+}
+
+func TestSectionedSummaryRetriesWrongCount(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"handbook.md": handbook})
+	sampler := &mockSampler{respond: answers(`{"overall": "A handbook.", "summaries": ["a", "b"]}`)}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "sectioned_summary", map[string]any{"filename": "handbook.md"})
+	if !strings.Contains(text, "got 2 summaries for 4 sections") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 2 {
+		t.Errorf("%d sampling requests, want the answer asked for again once", n)
+	}
+}
+
+func TestSectionedSummaryDetectedByModel(t *testing.T) {
+	files := map[string]string{
+		"memo.txt": "Budget: we are over by 10%.\n\nHiring: two roles open.",
+		"plain.md": "A Markdown file without any headings.",
+	}
+	s := newTestServer(t, Config{}, files)
+	sampler := &mockSampler{respond: answers(`{"overall": "A memo.", "sections": [{"title": "Budget", "summary": "Over budget."}, {"title": "Hiring", "summary": "Two roles."}]}`)}
+	c := connect(t, s, sampler)
+
+	for filename := range files {
+		_, text := mustSucceed(t, c, "sectioned_summary", map[string]any{"filename": filename})
+		summary, titles, sections := sectionedOf(t, text)
+		if summary.Source != "model" || strings.Join(titles, "|") != "Budget|Hiring" || sections["Hiring"] != "Two roles." {
+			t.Errorf("%s: unexpected summary %+v with sections %v", filename, summary, sections)
+		}
+	}
+	if prompt := sampler.Requests()[0].SystemPrompt; !strings.Contains(prompt, "Divide this document into its main sections") {
+		t.Errorf("the model was not asked to find the sections: %s", prompt)
+	}
+}
+
+func TestSectionedSummaryTrimsLongSections(t *testing.T) {
+	long := "# One\n" + strings.Repeat("word ", 200) + "\n# Two\nShort.\n"
+	s := newTestServer(t, Config{ChunkSize: 400}, map[string]string{"long.md": long})
+	sampler := &mockSampler{respond: answers(`{"overall": "Two parts.", "summaries": ["Many words.", "Short."]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "sectioned_summary", map[string]any{"filename": "long.md"})
+
+	if summary, _, _ := sectionedOf(t, text); !summary.Truncated {
+		t.Error("summary of a trimmed section is not marked truncated")
+	}
+	sent := messageText(sampler.Requests()[0])
+	if !strings.Contains(sent, "...\n</section>") || !strings.Contains(sent, "Short.\n</section>") {
+		t.Errorf("only the long section should be trimmed:\n%s", sent)
+	}
+}
+
+func TestSectionedSummaryMaxLevelBounds(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"handbook.md": handbook})
+	c := connect(t, s, &mockSampler{})
+
+	for _, level := range []int{0, 7} {
+		if text := mustFail(t, c, "sectioned_summary", map[string]any{"filename": "handbook.md", "max_level": level}); !strings.Contains(text, "max_level must be between 1 and 6") {
+			t.Errorf("max_level %d: unexpected error %s", level, text)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package analysis
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Values accepted by the extract_section argument.
+var extractSections = []string{"frontmatter", "comments", "body"}
+
+// commentSyntax describes how a language marks comments.
+type commentSyntax struct {
+	Line       []string
+	BlockStart string
+	BlockEnd   string
+}
+
+var (
+	cStyleComments    = commentSyntax{Line: []string{"//"}, BlockStart: "/*", BlockEnd: "*/"}
+	hashComments      = commentSyntax{Line: []string{"#"}}
+	dashComments      = commentSyntax{Line: []string{"--"}}
+	markupComments    = commentSyntax{BlockStart: "<!--", BlockEnd: "-->"}
+	semicolonComments = commentSyntax{Line: []string{";"}}
+)
+
+// commentSyntaxByExt maps file extensions to their comment syntax.
+var commentSyntaxByExt = map[string]commentSyntax{
+	".go": cStyleComments, ".c": cStyleComments, ".h": cStyleComments, ".cpp": cStyleComments,
+	".cc": cStyleComments, ".hpp": cStyleComments, ".cs": cStyleComments, ".java": cStyleComments,
+	".js": cStyleComments, ".jsx": cStyleComments, ".ts": cStyleComments, ".tsx": cStyleComments,
+	".rs": cStyleComments, ".swift": cStyleComments, ".kt": cStyleComments, ".scala": cStyleComments,
+	".php": cStyleComments, ".css": cStyleComments,
+	".py": hashComments, ".rb": hashComments, ".sh": hashComments, ".bash": hashComments,
+	".pl": hashComments, ".r": hashComments, ".yaml": hashComments, ".yml": hashComments,
+	".toml": hashComments, ".ps1": hashComments,
+	".sql": dashComments, ".lua": dashComments, ".hs": dashComments,
+	".html": markupComments, ".htm": markupComments, ".xml": markupComments, ".md": markupComments,
+	".ini": semicolonComments, ".asm": semicolonComments, ".lisp": semicolonComments, ".clj": semicolonComments,
+}
+
+// extractSection returns the requested section of a text or source file.
+// An empty section is an error, so sampling never runs on nothing.
+func extractSection(filename string, data []byte, section string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	syntax, isSource := commentSyntaxByExt[ext]
+	if !isTextFile(filename, mimeTypeFor(filename)) && !isSource {
+		return "", fmt.Errorf("extract_section only works on text and source files, not %s", filename)
+	}
+
+	text, _ := normalizeText(data)
+
+	var extracted string
+	switch section {
+	case "frontmatter":
+		front, _, ok := splitFrontmatter(text)
+		if !ok {
+			return "", fmt.Errorf("No frontmatter found in %s (expected a block fenced by --- lines at the top)", filename)
+		}
+		extracted = front
+	case "body":
+		_, extracted, _ = splitFrontmatter(text)
+	case "comments":
+		if !isSource {
+			return "", fmt.Errorf("Don't know the comment syntax for %s files", ext)
+		}
+		extracted = extractComments(text, syntax)
+	default:
+		return "", fmt.Errorf("Unknown extract_section %q (expected one of: %s)", section, strings.Join(extractSections, ", "))
+	}
+
+	if strings.TrimSpace(extracted) == "" {
+		return "", fmt.Errorf("The %s section of %s is empty", section, filename)
+	}
+	return extracted, nil
+}
+
+// splitFrontmatter separates a leading YAML frontmatter block, fenced by
+// "---" lines, from the rest of the text. Without one, body is all of text.
+func splitFrontmatter(text string) (front, body string, ok bool) {
+	if !strings.HasPrefix(text, "---\n") {
+		return "", text, false
+	}
+
+	rest := text[len("---\n"):]
+	for offset := 0; offset < len(rest); {
+		end := strings.IndexByte(rest[offset:], '\n')
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if line == "---" || line == "..." {
+			body = ""
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			return rest[:offset], body, true
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	return "", text, false
+}
+
+// extractComments collects the comments of source text, one per line and
+// without their markers. Quoted strings are skipped so that, say, a URL's
+// "//" is not mistaken for a comment.
+func extractComments(text string, syntax commentSyntax) string {
+	var comments []string
+	var block strings.Builder
+	inBlock := false
+	var quote byte
+
+	// A shebang line looks like a '#' comment but is not one
+	start := 0
+	if strings.HasPrefix(text, "#!") {
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			start = nl + 1
+		} else {
+			start = len(text)
+		}
+	}
+
+	for i := start; i < len(text); i++ {
+		if inBlock {
+			if strings.HasPrefix(text[i:], syntax.BlockEnd) {
+				comments = append(comments, strings.TrimSpace(block.String()))
+				block.Reset()
+				inBlock = false
+				i += len(syntax.BlockEnd) - 1
+			} else {
+				block.WriteByte(text[i])
+			}
+			continue
+		}
+
+		c := text[i]
+		if quote != 0 {
+			switch {
+			case c == '\\':
+				i++
+			case c == quote || c == '\n':
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' || c == '`' {
+			quote = c
+			continue
+		}
+
+		if syntax.BlockStart != "" && strings.HasPrefix(text[i:], syntax.BlockStart) {
+			inBlock = true
+			i += len(syntax.BlockStart) - 1
+			continue
+		}
+		for _, marker := range syntax.Line {
+			if strings.HasPrefix(text[i:], marker) {
+				end := strings.IndexByte(text[i:], '\n')
+				if end < 0 {
+					end = len(text) - i
+				}
+				comments = append(comments, strings.TrimSpace(text[i+len(marker):i+end]))
+				i += end
+				break
+			}
+		}
+	}
+	if inBlock {
+		comments = append(comments, strings.TrimSpace(block.String()))
+	}
+
+	return strings.Join(comments, "\n")
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const frontmatterDoc = "---\ntitle: Launch plan\nauthor: Dana\n---\n# Launch\n\nWe ship in May.\n"
+
+const pythonSource = `#!/usr/bin/env python3
+# Compute the launch date.
+import datetime
+
+URL = "https://example.com/#anchor"  # the status page
+
+def launch():
+    # Ship on the first Monday of May.
+    return datetime.date(2026, 5, 4)
+`
+
+func TestExtractSection(t *testing.T) {
+	tests := []struct {
+		name, filename, content, section, want string
+	}{
+		{"markdown frontmatter", "plan.md", frontmatterDoc, "frontmatter", "title: Launch plan\nauthor: Dana\n"},
+		{"markdown body", "plan.md", frontmatterDoc, "body", "# Launch\n\nWe ship in May.\n"},
+		{"python comments", "launch.py", pythonSource, "comments", "Compute the launch date.\nthe status page\nShip on the first Monday of May."},
+		{"go block comments", "main.go", "/* Package main\n   runs it. */\npackage main // entry\n", "comments", "Package main\n   runs it.\nentry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractSection(tt.filename, []byte(tt.content), tt.section)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("extractSection = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSectionErrors(t *testing.T) {
+	if _, err := extractSection("notes.md", []byte("# No frontmatter\n"), "frontmatter"); err == nil || !strings.Contains(err.Error(), "No frontmatter found") {
+		t.Errorf("missing frontmatter: %v", err)
+	}
+	if _, err := extractSection("notes.txt", []byte("text"), "comments"); err == nil || !strings.Contains(err.Error(), "comment syntax") {
+		t.Errorf("comments of a file without comment syntax: %v", err)
+	}
+	if _, err := extractSection("empty.py", []byte("x = 1\n"), "comments"); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("a file without comments: %v", err)
+	}
+}
+
+func TestAnalyzeFileExtractsSection(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"plan.md": frontmatterDoc, "launch.py": pythonSource})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "plan.md", "extract_section": "frontmatter"})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "launch.py", "extract_section": "comments"})
+
+	requests := sampler.Requests()
+	if text := messageText(requests[0]); !strings.Contains(text, "title: Launch plan") || strings.Contains(text, "We ship in May") {
+		t.Errorf("frontmatter request carries %q", text)
+	}
+	if text := messageText(requests[1]); !strings.Contains(text, "Ship on the first Monday") || strings.Contains(text, "import datetime") {
+		t.Errorf("comments request carries %q", text)
+	}
+}
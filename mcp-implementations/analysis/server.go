@@ -0,0 +1,377 @@
+// Package analysis implements the file analysis tools behind the enhanced MCP
+// server. Tools that need an LLM do not call one directly: they send a
+// sampling request back to the connected client, which owns the API key.
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const DEFAULT_FILES_DIR = "./files"
+
+// Config controls where the server reads files from and how much of them
+// it is willing to process.
+type Config struct {
+	// FilesDir is the directory analyzed files must live in, or a search
+	// path of directories separated by the OS list separator (':' on Unix)
+	// that are tried in order.
+	FilesDir string
+	// FileSource, when set, replaces FilesDir, e.g. with an ObjectSource
+	// reading from a bucket.
+	FileSource FileSource
+
+	// ArchiveMaxMembers caps how many text members of a zip/tar.gz archive
+	// are extracted and analyzed.
+	ArchiveMaxMembers int
+	// ArchiveMaxListed caps how many members of an archive get a section
+	// of their own in the result. The rest are only counted.
+	ArchiveMaxListed int
+	// ArchiveMaxMemberBytes caps the decompressed size of a single member.
+	ArchiveMaxMemberBytes int64
+	// ArchiveMaxTotalBytes caps the decompressed size of all members combined.
+	ArchiveMaxTotalBytes int64
+
+	// MaxConcurrentSampling caps how many sampling requests the server has
+	// in flight at once, across all tool calls.
+	MaxConcurrentSampling int
+
+	// IndexTTL is how long list_files trusts its cached directory listing
+	// when no file has been added or removed.
+	IndexTTL time.Duration
+	// MaxListEntries caps how many files one list_files call returns.
+	MaxListEntries int
+
+	// MinFileBytes rejects analysis of smaller files unless the caller
+	// passes force. Zero disables the check.
+	MinFileBytes int64
+
+	// ImageMaxDimension and ImageMaxBytes are the provider's image limits:
+	// pixels on the long edge and base64-encoded size. Larger images are
+	// downscaled to fit. Zero means the Default values.
+	ImageMaxDimension int
+	ImageMaxBytes     int
+
+	// DefaultAnalysis is the analysis_type used when a call omits it. Empty
+	// means DefaultAnalysisType.
+	DefaultAnalysis string
+
+	// ChunkSize is the largest text, in bytes, sent in one sampling request.
+	// Longer text files are analyzed chunk by chunk and then combined.
+	ChunkSize int
+	// PartialsDir stores per-chunk results of unfinished chunked analyses.
+	PartialsDir string
+
+	// Moderator screens content before sampling. Nil disables moderation.
+	Moderator Moderator
+
+	// Cache stores analysis results keyed by file content and arguments.
+	// Nil means an in-memory cache.
+	Cache CacheStore
+	// CacheTTL is how long a cached result is served.
+	CacheTTL time.Duration
+
+	// IdempotencyTTL is how long a tool call's result is returned to calls
+	// repeating its idempotency key.
+	IdempotencyTTL time.Duration
+
+	// PIIPatterns are what the redact argument masks. Nil means
+	// DefaultPIIPatterns.
+	PIIPatterns []PIIPattern
+
+	// RefusalPatterns detect a model declining a request from the start of
+	// its answer. Nil means DefaultRefusalPatterns; an empty slice leaves
+	// only the providers' refusal stop reasons.
+	RefusalPatterns []*regexp.Regexp
+
+	// Embedder backs embed_file. Nil disables embeddings.
+	Embedder Embedder
+	// EmbeddingChunkSize is the largest text, in bytes, embedded as one vector.
+	EmbeddingChunkSize int
+
+	// AutoContinue makes an answer cut off at max_tokens be completed by
+	// follow-up requests, at most MaxContinuations of them, instead of
+	// only being marked with MaxTokensNote. Zero MaxContinuations means
+	// DefaultMaxContinuations.
+	AutoContinue     bool
+	MaxContinuations int
+
+	// AutoLanguage makes analyze_file answer in the language of a text
+	// file, detected with an extra sampling request, when the call names
+	// no language. The call's auto_language argument overrides it.
+	AutoLanguage bool
+
+	// ResultFooter, when set, is appended to the output of every tool
+	// that samples, e.g. a disclaimer.
+	ResultFooter string
+
+	// LogSampleRate keeps the routine logs of one in every LogSampleRate
+	// tool calls. Zero or one logs every call.
+	LogSampleRate int
+	// SlowRequestThreshold is the tool call duration that is always logged.
+	// Zero means DefaultSlowRequestThreshold.
+	SlowRequestThreshold time.Duration
+
+	// BinaryPolicy is what is sent for files that are neither text nor an
+	// image: one of BinaryPolicies. Empty means DefaultBinaryPolicy.
+	BinaryPolicy string
+
+	// Debug enables verbose logging, such as each client's capabilities
+	// and the size of every sampling request and response.
+	Debug bool
+}
+
+// DefaultMaxConcurrentSampling is used when Config leaves the limit unset.
+const DefaultMaxConcurrentSampling = 4
+
+// Server holds the MCP server and the state shared by its tool handlers.
+type Server struct {
+	cfg Config
+	mcp *server.MCPServer
+
+	// samplingSlots bounds concurrent sampling requests, serving waiters
+	// by priority
+	samplingSlots *slotQueue
+
+	partials    *partialStore
+	clients     *clientRegistry
+	idempotency *idempotencyStore
+
+	// toolCalls counts tool calls, for log sampling
+	toolCalls atomic.Uint64
+
+	// tools holds every registered tool, so a sampled model's tool calls
+	// can be dispatched to them
+	tools map[string]server.ServerTool
+	// sampling is set for the registered tools whose handlers sample
+	sampling map[string]bool
+}
+
+// New creates an MCP server with sampling enabled and every analysis tool
+// registered.
+func New(cfg Config) *Server {
+	if cfg.FilesDir == "" {
+		cfg.FilesDir = DEFAULT_FILES_DIR
+	}
+	if cfg.ArchiveMaxMembers <= 0 {
+		cfg.ArchiveMaxMembers = DefaultArchiveMaxMembers
+	}
+	if cfg.ArchiveMaxListed <= 0 {
+		cfg.ArchiveMaxListed = DefaultArchiveMaxListed
+	}
+	if cfg.ArchiveMaxMemberBytes <= 0 {
+		cfg.ArchiveMaxMemberBytes = DefaultArchiveMaxMemberBytes
+	}
+	if cfg.ArchiveMaxTotalBytes <= 0 {
+		cfg.ArchiveMaxTotalBytes = DefaultArchiveMaxTotalBytes
+	}
+	if cfg.MaxConcurrentSampling <= 0 {
+		cfg.MaxConcurrentSampling = DefaultMaxConcurrentSampling
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.EmbeddingChunkSize <= 0 {
+		cfg.EmbeddingChunkSize = DefaultEmbeddingChunkSize
+	}
+	if cfg.IndexTTL <= 0 {
+		cfg.IndexTTL = DefaultIndexTTL
+	}
+	if cfg.FileSource == nil {
+		cfg.FileSource = NewLocalSource(cfg.FilesDir, cfg.IndexTTL)
+	}
+	if cfg.RefusalPatterns == nil {
+		cfg.RefusalPatterns = DefaultRefusalPatterns
+	}
+	if cfg.MaxListEntries <= 0 {
+		cfg.MaxListEntries = DefaultMaxListEntries
+	}
+	if cfg.Cache == nil {
+		cfg.Cache = NewMemoryCache()
+	}
+	if cfg.DefaultAnalysis == "" {
+		cfg.DefaultAnalysis = DefaultAnalysisType
+	}
+	if cfg.ImageMaxDimension <= 0 {
+		cfg.ImageMaxDimension = DefaultImageMaxDimension
+	}
+	if cfg.ImageMaxBytes <= 0 {
+		cfg.ImageMaxBytes = DefaultImageMaxBytes
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.MaxContinuations <= 0 {
+		cfg.MaxContinuations = DefaultMaxContinuations
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = DefaultIdempotencyTTL
+	}
+	if cfg.PIIPatterns == nil {
+		cfg.PIIPatterns = DefaultPIIPatterns
+	}
+	if cfg.SlowRequestThreshold <= 0 {
+		cfg.SlowRequestThreshold = DefaultSlowRequestThreshold
+	}
+	if cfg.BinaryPolicy == "" {
+		cfg.BinaryPolicy = DefaultBinaryPolicy
+	}
+	if cfg.PartialsDir == "" {
+		cfg.PartialsDir = filepath.Join(os.TempDir(), "enhanced-sampling-server", "partials")
+	}
+
+	s := &Server{
+		cfg:           cfg,
+		samplingSlots: newSlotQueue(cfg.MaxConcurrentSampling),
+		partials:      &partialStore{dir: cfg.PartialsDir},
+		clients:       &clientRegistry{clients: map[string]ClientInfo{}},
+		idempotency:   newIdempotencyStore(cfg.IdempotencyTTL),
+		tools:         map[string]server.ServerTool{},
+		sampling:      map[string]bool{},
+	}
+	s.mcp = server.NewMCPServer("enhanced-sampling-server", "1.0.0",
+		server.WithToolHandlerMiddleware(s.withDebug),
+		server.WithToolHandlerMiddleware(s.withIdempotency),
+		server.WithToolHandlerMiddleware(withCallerAPIKey),
+		server.WithToolHandlerMiddleware(withPriority),
+		server.WithToolHandlerMiddleware(withTruncation),
+		server.WithToolHandlerMiddleware(s.withLogSampling),
+		server.WithToolHandlerMiddleware(s.withRefusalMeta),
+		server.WithToolHandlerMiddleware(withMaxTokensMeta),
+		server.WithToolHandlerMiddleware(withPartialMeta),
+		server.WithHooks(s.clientHooks()),
+	)
+
+	// Enable sampling capability
+	s.mcp.EnableSampling()
+
+	s.addTool(analyzeFileTool, s.handleAnalyzeFile)
+	s.addTool(analyzeBatchTool, s.handleAnalyzeBatch)
+	s.addLocalTool(listFilesTool, s.handleListFiles)
+	s.addLocalTool(listAnalysisTypesTool, handleListAnalysisTypes)
+	s.addLocalTool(estimateBatchCostTool, s.handleEstimateBatchCost)
+	s.addLocalTool(embedFileTool, s.handleEmbedFile)
+	s.addTool(summarizeChangesTool, s.handleSummarizeChanges)
+	s.addTool(classifyFileTool, s.handleClassifyFile)
+	s.addTool(detectLanguageTool, s.handleDetectLanguage)
+	s.addTool(generateQuizTool, s.handleGenerateQuiz)
+	s.addTool(checkComplianceTool, s.handleCheckCompliance)
+	s.addTool(analyzeIfChangedTool, s.handleAnalyzeIfChanged)
+	s.addTool(extractTablesTool, s.handleExtractTables)
+	s.addTool(readabilityTool, s.handleReadability)
+	s.addTool(validateFileTool, s.handleValidateFile)
+	s.addTool(outlineTool, s.handleOutline)
+	s.addTool(evalFileTool, s.handleEvalFile)
+	s.addTool(analyzeConversationTool, s.handleAnalyzeConversation)
+	s.addTool(generateAltTextTool, s.handleGenerateAltText)
+	s.addTool(summarizeWithLinksTool, s.handleSummarizeWithLinks)
+	s.addTool(convertFormatTool, s.handleConvertFormat)
+	s.addTool(summarizeLogsTool, s.handleSummarizeLogs)
+	s.addTool(extractFieldTool, s.handleExtractField)
+	s.addTool(generateChangelogTool, s.handleGenerateChangelog)
+	s.addTool(similarityTool, s.handleSimilarity)
+	s.addTool(verifyImageTool, s.handleVerifyImage)
+	s.addTool(reportTool, s.handleReport)
+	s.addTool(generateTagsTool, s.handleGenerateTags)
+	s.addTool(sectionedSummaryTool, s.handleSectionedSummary)
+	s.addTool(suggestEditsTool, s.handleSuggestEdits)
+	s.addTool(mergeSummariesTool, s.handleMergeSummaries)
+	s.addTool(diffAnalysesTool, s.handleDiffAnalyses)
+	s.addTool(layeredSummaryTool, s.handleLayeredSummary)
+	s.addTool(scoreContentTool, s.handleScoreContent)
+	s.addTool(factCheckTool, s.handleFactCheck)
+	s.addTool(generatePostTool, s.handleGeneratePost)
+	s.addTool(warmupTool, s.handleWarmup)
+	s.addLocalTool(echoTool, handleEcho)
+
+	return s
+}
+
+// addTool registers a tool whose handler samples. Sampled models may not
+// call it, since one analysis could then fan out into many.
+func (s *Server) addTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	s.registerTool(tool, handler, true)
+}
+
+// addLocalTool registers a tool whose handler never samples.
+func (s *Server) addLocalTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	s.registerTool(tool, handler, false)
+}
+
+// registerTool registers a tool with the MCP server and records it for
+// tool calls made by sampled models. Only sampling tools get the result
+// footer, and those calls skip it, since it is meant for the caller, not
+// the model.
+func (s *Server) registerTool(tool mcp.Tool, handler server.ToolHandlerFunc, samples bool) {
+	s.tools[tool.Name] = server.ServerTool{Tool: tool, Handler: handler}
+	s.sampling[tool.Name] = samples
+	if samples && s.cfg.ResultFooter != "" {
+		handler = s.withResultFooter(handler)
+	}
+	s.mcp.AddTool(tool, handler)
+}
+
+// MCPServer returns the underlying MCP server, for use with a transport.
+func (s *Server) MCPServer() *server.MCPServer {
+	return s.mcp
+}
+
+// FilesDir returns the directory, or search path, files are served from,
+// or a description of the FileSource that replaces them.
+func (s *Server) FilesDir() string {
+	return s.cfg.FileSource.String()
+}
+
+// errorResult builds a tool result that reports a failure to the caller.
+func errorResult(format string, args ...any) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf(format, args...),
+			},
+		},
+		IsError: true,
+	}
+}
+
+// textResult builds a successful tool result with a single text block.
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+}
+
+var echoTool = mcp.Tool{
+	Name:        "echo",
+	Description: "Echo back the input message (no sampling required)",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The message to echo back",
+			},
+		},
+		Required: []string{"message"},
+	},
+}
+
+func handleEcho(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	message := request.GetString("message", "")
+
+	return textResult(fmt.Sprintf("Echo: %s", message)), nil
+}
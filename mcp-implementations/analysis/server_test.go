@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestAnalyzeFileRoundTrip is the selftest command as a test: a real
+// server and an in-process client with a mock sampling handler complete
+// an analyze_file round trip without any external API.
+func TestAnalyzeFileRoundTrip(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{
+		"selftest.md": "# Self-test\n\nThis file exists only for the self-test.",
+	})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	clients := s.Clients()
+	if len(clients) != 1 || !clients[0].Sampling {
+		t.Fatalf("expected one sampling-capable client in the registry, got %+v", clients)
+	}
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{
+		"filename":      "selftest.md",
+		"analysis_type": "summarize",
+	})
+	if n := len(sampler.Requests()); n != 1 {
+		t.Fatalf("expected 1 sampling request, the handler saw %d", n)
+	}
+	if !strings.Contains(text, mockAnswer) {
+		t.Fatalf("analysis result does not contain the sampled response:\n%s", text)
+	}
+	if !strings.Contains(messageText(sampler.Requests()[0]), "only for the self-test") {
+		t.Errorf("sampling request does not carry the file's content")
+	}
+}
+
+func TestAnalyzeFileForwardsSeedAndTemperature(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "seed": 42, "temperature": 0})
+
+	request := sampler.Requests()[0]
+	metadata, _ := request.Metadata.(map[string]any)
+	if fmt.Sprint(metadata["seed"]) != "42" {
+		t.Errorf("sampling metadata %v, want seed 42", metadata)
+	}
+	if request.Temperature != 0 {
+		t.Errorf("temperature %v, want 0", request.Temperature)
+	}
+}
@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Similarity methods: embeddings compares embedding vectors, model asks the
+// model for a rating, and auto uses embeddings when they are configured.
+var similarityMethods = []string{"auto", "embeddings", "model"}
+
+var similarityTool = mcp.Tool{
+	Name:        "similarity",
+	Description: "Score how similar two text files are in meaning. Uses the cosine similarity of their embeddings when embeddings are configured, otherwise asks the model for a 0-100 rating with a rationale using LLM sampling",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename_a": map[string]any{
+				"type":        "string",
+				"description": "The first file (relative to files directory)",
+			},
+			"filename_b": map[string]any{
+				"type":        "string",
+				"description": "The second file (relative to files directory)",
+			},
+			"method": map[string]any{
+				"type":        "string",
+				"description": "How to compare: embeddings, model, or auto to use embeddings when configured (default auto)",
+				"enum":        similarityMethods,
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename_a", "filename_b"},
+	},
+}
+
+// Similarity is the structured result of the similarity tool. Cosine is
+// set by the embeddings method and Rating, from 0 to 100, by the model
+// method.
+type Similarity struct {
+	FileA     string   `json:"file_a"`
+	FileB     string   `json:"file_b"`
+	Method    string   `json:"method"`
+	Model     string   `json:"model"`
+	Cosine    *float64 `json:"cosine,omitempty"`
+	ChunksA   int      `json:"chunks_a,omitempty"`
+	ChunksB   int      `json:"chunks_b,omitempty"`
+	Rating    *int     `json:"rating,omitempty"`
+	Rationale string   `json:"rationale,omitempty"`
+	// Truncated reports that the files were cut to fit one request
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleSimilarity(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filenameA, err := request.RequireString("filename_a")
+	if err != nil {
+		return nil, err
+	}
+	filenameB, err := request.RequireString("filename_b")
+	if err != nil {
+		return nil, err
+	}
+	method := request.GetString("method", "auto")
+	switch method {
+	case "auto", "model":
+	case "embeddings":
+		if s.cfg.Embedder == nil {
+			return errorResult("Embeddings are not configured on this server (start it with -embeddings-provider)"), nil
+		}
+	default:
+		return errorResult("Unknown method %q (use %s)", method, strings.Join(similarityMethods, ", ")), nil
+	}
+
+	textA, err := s.readTextFile(filenameA)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	textB, err := s.readTextFile(filenameB)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	similarity := Similarity{FileA: filenameA, FileB: filenameB}
+	if method != "model" && s.cfg.Embedder != nil {
+		err := s.embeddingSimilarity(ctx, textA, textB, &similarity)
+		if err == nil {
+			return similarityResult(similarity)
+		}
+		log.Printf("❌ Embeddings request failed: %v", err)
+		if method == "embeddings" {
+			return errorResult("Error requesting embeddings: %v", err), nil
+		}
+		log.Printf("⚠️  Asking the model to rate similarity instead")
+	}
+
+	if err := s.modelSimilarity(ctx, textA, textB, &similarity); err != nil {
+		return errorResult("%v", err), nil
+	}
+	return similarityResult(similarity)
+}
+
+// embeddingSimilarity compares the files by the cosine of their embedding
+// vectors. A file longer than one embedding is embedded chunk by chunk and
+// its vector is the average of the chunks', weighted by length, so files
+// of different lengths are compared as wholes.
+func (s *Server) embeddingSimilarity(ctx context.Context, textA, textB string, similarity *Similarity) error {
+	chunksA := splitChunks(textA, s.cfg.EmbeddingChunkSize)
+	chunksB := splitChunks(textB, s.cfg.EmbeddingChunkSize)
+
+	logf(ctx, "📤 Requesting %d embeddings to compare %s and %s (model: %s)",
+		len(chunksA)+len(chunksB), similarity.FileA, similarity.FileB, s.cfg.Embedder.Model())
+	vectors, err := s.cfg.Embedder.Embed(ctx, append(append([]string{}, chunksA...), chunksB...))
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(chunksA)+len(chunksB) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(chunksA)+len(chunksB), len(vectors))
+	}
+
+	cosine, err := cosineSimilarity(meanVector(vectors[:len(chunksA)], chunksA), meanVector(vectors[len(chunksA):], chunksB))
+	if err != nil {
+		return err
+	}
+	similarity.Method = "embeddings"
+	similarity.Model = s.cfg.Embedder.Model()
+	similarity.Cosine = &cosine
+	similarity.ChunksA, similarity.ChunksB = len(chunksA), len(chunksB)
+	logf(ctx, "✅ Cosine similarity of %s and %s: %.4f", similarity.FileA, similarity.FileB, cosine)
+	return nil
+}
+
+// modelSimilarity asks the model to rate the files' similarity from 0 to
+// 100. Files that do not fit one request together are each cut to half.
+func (s *Server) modelSimilarity(ctx context.Context, textA, textB string, similarity *Similarity) error {
+	if len(textA)+len(textB) > s.cfg.ChunkSize {
+		textA, _ = truncateFor(ctx, textA, s.cfg.ChunkSize/2)
+		textB, _ = truncateFor(ctx, textB, s.cfg.ChunkSize/2)
+		similarity.Truncated = true
+	}
+
+	content := mcp.TextContent{Type: "text", Text: fmt.Sprintf("=== Document A: %s ===\n%s\n\n=== Document B: %s ===\n%s",
+		similarity.FileA, textA, similarity.FileB, textB)}
+	systemPrompt := "Rate how similar documents A and B are in meaning, from 0 (unrelated) to 100 (the same content, even if worded differently). " +
+		"Judge topic, claims and purpose rather than wording or formatting. " +
+		`Respond with only a JSON object: {"rating": <integer from 0 to 100>, "rationale": "<one or two sentences>"}.`
+	if similarity.Truncated {
+		systemPrompt += " Both documents were truncated; rate only what is shown."
+	}
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 300
+
+	logf(ctx, "📤 Sending sampling request to compare %s and %s", similarity.FileA, similarity.FileB)
+	var rating int
+	var rationale string
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid rating", func(text string) (err error) {
+		rating, rationale, err = parseSimilarityRating(text)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	similarity.Model = result.Model
+	similarity.Method = "model"
+	similarity.Rating, similarity.Rationale = &rating, rationale
+	logf(ctx, "✅ Similarity of %s and %s rated %d/100", similarity.FileA, similarity.FileB, rating)
+	return nil
+}
+
+// parseSimilarityRating decodes the model's answer, which needs an integer
+// rating from 0 to 100 and a rationale.
+func parseSimilarityRating(text string) (int, string, error) {
+	var answer struct {
+		Rating    *float64 `json:"rating"`
+		Rationale string   `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return 0, "", fmt.Errorf("not valid JSON: %v", err)
+	}
+	if answer.Rating == nil || *answer.Rating < 0 || *answer.Rating > 100 || *answer.Rating != math.Trunc(*answer.Rating) {
+		return 0, "", fmt.Errorf("rating must be an integer from 0 to 100")
+	}
+	if strings.TrimSpace(answer.Rationale) == "" {
+		return 0, "", fmt.Errorf("rationale is empty")
+	}
+	return int(*answer.Rating), strings.TrimSpace(answer.Rationale), nil
+}
+
+// meanVector averages the chunk vectors of one file, weighting each by its
+// chunk's length so a short final chunk does not count as much as a full one.
+func meanVector(vectors [][]float64, chunks []string) []float64 {
+	mean := make([]float64, len(vectors[0]))
+	var total float64
+	for i, vector := range vectors {
+		weight := float64(len(chunks[i]))
+		for j, v := range vector {
+			mean[j] += v * weight
+		}
+		total += weight
+	}
+	for j := range mean {
+		mean[j] /= total
+	}
+	return mean
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b.
+func cosineSimilarity(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("embeddings have different dimensions (%d and %d)", len(a), len(b))
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, fmt.Errorf("an embedding is all zeros")
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
+
+func similarityResult(similarity Similarity) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(similarity, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding similarity: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"testing"
+)
+
+// wordEmbedder embeds text as a bag of words, so texts sharing their words
+// point the same way and texts sharing none are orthogonal.
+type wordEmbedder struct {
+	err error
+}
+
+func (e wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, 256)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,")))
+			vectors[i][h.Sum32()%256]++
+		}
+	}
+	return vectors, nil
+}
+
+func (e wordEmbedder) Model() string { return "word-embedder" }
+
+const (
+	aboutRetries = "The client retries failed requests with exponential backoff and jitter.\n"
+	aboutCooking = "Simmer the onions slowly in butter until golden, then add thyme.\n"
+)
+
+var similarityFiles = map[string]string{
+	// Three embedding chunks of the same sentence, against one
+	"long.txt":   strings.Repeat(aboutRetries, 6),
+	"short.txt":  aboutRetries,
+	"recipe.txt": aboutCooking,
+}
+
+// similarityOf decodes a similarity result.
+func similarityOf(t *testing.T, text string) Similarity {
+	t.Helper()
+	var similarity Similarity
+	if err := json.Unmarshal([]byte(text), &similarity); err != nil {
+		t.Fatalf("result is not a similarity: %v\n%s", err, text)
+	}
+	return similarity
+}
+
+func TestSimilarityByEmbeddings(t *testing.T) {
+	s := newTestServer(t, Config{Embedder: wordEmbedder{}, EmbeddingChunkSize: 200}, similarityFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "similarity", map[string]any{"filename_a": "long.txt", "filename_b": "short.txt"})
+	near := similarityOf(t, text)
+	if near.Method != "embeddings" || near.Model != "word-embedder" || near.Cosine == nil || *near.Cosine < 0.99 {
+		t.Errorf("near-identical files: %+v, want a cosine close to 1", near)
+	}
+	if near.ChunksA < 2 || near.ChunksB != 1 {
+		t.Errorf("chunks = %d and %d, want the long file averaged over several", near.ChunksA, near.ChunksB)
+	}
+
+	_, text = mustSucceed(t, c, "similarity", map[string]any{"filename_a": "short.txt", "filename_b": "recipe.txt"})
+	if far := similarityOf(t, text); far.Cosine == nil || *far.Cosine > 0.3 {
+		t.Errorf("unrelated files: %+v, want a low cosine", far)
+	}
+
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("embeddings comparisons sent %d sampling requests", n)
+	}
+}
+
+func TestSimilarityByModelRating(t *testing.T) {
+	s := newTestServer(t, Config{}, similarityFiles)
+	sampler := &mockSampler{respond: answers(
+		`{"rating": 150, "rationale": "Very alike."}`,
+		`{"rating": 5, "rationale": "One is about retries, the other a recipe."}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "similarity", map[string]any{"filename_a": "short.txt", "filename_b": "recipe.txt"})
+
+	got := similarityOf(t, text)
+	if got.Method != "model" || got.Rating == nil || *got.Rating != 5 || got.Cosine != nil || got.Rationale == "" {
+		t.Errorf("unexpected similarity: %+v", got)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if sent := messageText(requests[0]); !strings.Contains(sent, "=== Document A: short.txt ===\n"+aboutRetries) || !strings.Contains(sent, "=== Document B: recipe.txt ===\n"+aboutCooking) {
+		t.Errorf("both documents were not sent:\n%s", sent)
+	}
+	if !strings.Contains(requests[1].SystemPrompt, "rating must be an integer from 0 to 100") {
+		t.Errorf("reprompt does not give the problem: %q", requests[1].SystemPrompt)
+	}
+}
+
+func TestSimilarityFallsBackWhenEmbeddingsFail(t *testing.T) {
+	s := newTestServer(t, Config{Embedder: wordEmbedder{err: errors.New("embeddings service unavailable")}}, similarityFiles)
+	sampler := &mockSampler{respond: answers(`{"rating": 90, "rationale": "Same topic."}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "similarity", map[string]any{"filename_a": "long.txt", "filename_b": "short.txt"})
+	if got := similarityOf(t, text); got.Method != "model" || got.Rating == nil || *got.Rating != 90 {
+		t.Errorf("method auto did not fall back to a model rating: %+v", got)
+	}
+
+	// Asking for embeddings explicitly does not fall back
+	text = mustFail(t, c, "similarity", map[string]any{"filename_a": "long.txt", "filename_b": "short.txt", "method": "embeddings"})
+	if !strings.Contains(text, "Error requesting embeddings: embeddings service unavailable") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestSimilarityMethodModelSkipsEmbeddings(t *testing.T) {
+	s := newTestServer(t, Config{Embedder: wordEmbedder{}}, similarityFiles)
+	sampler := &mockSampler{respond: answers(`{"rating": 100, "rationale": "Identical."}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "similarity", map[string]any{"filename_a": "long.txt", "filename_b": "short.txt", "method": "model"})
+	if got := similarityOf(t, text); got.Method != "model" {
+		t.Errorf("method model used %s", got.Method)
+	}
+}
+
+func TestSimilarityRejectsInvalidMethods(t *testing.T) {
+	s := newTestServer(t, Config{}, similarityFiles)
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for method, want := range map[string]string{
+		"embeddings": "Embeddings are not configured on this server",
+		"jaccard":    `Unknown method "jaccard" (use auto, embeddings, model)`,
+	} {
+		text := mustFail(t, c, "similarity", map[string]any{"filename_a": "long.txt", "filename_b": "short.txt", "method": method})
+		if !strings.Contains(text, want) {
+			t.Errorf("method %s: unexpected error: %s", method, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid methods sent %d sampling requests", n)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got, err := cosineSimilarity([]float64{1, 0}, []float64{2, 0}); err != nil || got != 1 {
+		t.Errorf("parallel vectors: %v, %v; want 1", got, err)
+	}
+	if got, err := cosineSimilarity([]float64{1, 0}, []float64{0, 3}); err != nil || got != 0 {
+		t.Errorf("orthogonal vectors: %v, %v; want 0", got, err)
+	}
+	if _, err := cosineSimilarity([]float64{1}, []float64{1, 0}); err == nil {
+		t.Error("vectors of different dimensions were compared")
+	}
+	if _, err := cosineSimilarity([]float64{0, 0}, []float64{1, 0}); err == nil {
+		t.Error("an all-zero vector was compared")
+	}
+}
+
+func TestMeanVectorWeightsByChunkLength(t *testing.T) {
+	mean := meanVector([][]float64{{1, 0}, {0, 1}}, []string{"xxx", "x"})
+	if mean[0] != 0.75 || mean[1] != 0.25 {
+		t.Errorf("meanVector() = %v, want [0.75 0.25]", mean)
+	}
+}
@@ -0,0 +1,192 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Chunk modes for text longer than the chunk size.
+const (
+	// ChunkModeMapReduce samples every chunk on its own, then combines them
+	ChunkModeMapReduce = "map_reduce"
+	// ChunkModeWindow slides a window over the text, carrying running notes
+	// from each window into the next
+	ChunkModeWindow = "window"
+)
+
+var chunkModes = []string{ChunkModeMapReduce, ChunkModeWindow}
+
+const (
+	// DefaultWindowOverlap is how many bytes of the previous window are
+	// repeated at the start of the next, so a point split across the
+	// boundary is seen whole.
+	DefaultWindowOverlap = 2000
+	// windowNotesWords bounds the running notes, which keeps every request
+	// to one window plus a fixed amount of state however long the file is.
+	windowNotesWords = 400
+)
+
+// checkChunkMode validates the chunk_mode and window_overlap arguments
+// against the server's chunk size.
+func checkChunkMode(opts analyzeOptions, chunkSize int) error {
+	switch opts.ChunkMode {
+	case "", ChunkModeMapReduce:
+		if opts.WindowOverlap != nil {
+			return fmt.Errorf("window_overlap only applies with chunk_mode %s", ChunkModeWindow)
+		}
+	case ChunkModeWindow:
+		if opts.WindowOverlap != nil && (*opts.WindowOverlap < 0 || *opts.WindowOverlap > chunkSize/2) {
+			return fmt.Errorf("window_overlap must be between 0 and %d (half the chunk size)", chunkSize/2)
+		}
+	default:
+		return fmt.Errorf("unknown chunk_mode %q (use %s)", opts.ChunkMode, strings.Join(chunkModes, " or "))
+	}
+	return nil
+}
+
+// overlapTail returns about the last n bytes of text, starting at a line
+// break when there is one in the first half of them, and otherwise at a
+// character boundary.
+func overlapTail(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(text) <= n {
+		return text
+	}
+	tail := text[len(text)-n:]
+	if nl := strings.IndexByte(tail, '\n'); nl >= 0 && nl < n/2 {
+		return tail[nl+1:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return tail
+}
+
+// analyzeWindowed reads a long text one chunk at a time, each window
+// being a chunk plus the end of the one before it. Every request carries
+// the model's condensed notes on the text so far and returns them updated
+// for the next window; the last window turns the notes into the answer.
+// Like analyzeChunked, progress is saved after each window, but only the
+// latest notes are kept, since they already cover every earlier window.
+func (s *Server) analyzeWindowed(ctx context.Context, opts analyzeOptions, mimeType string, chunks textChunks, source []byte, basePrompt string) (*mcp.CallToolResult, error) {
+	filename := opts.Filename
+	total := chunks.Len()
+	overlap := min(DefaultWindowOverlap, s.cfg.ChunkSize/2)
+	if opts.WindowOverlap != nil {
+		overlap = *opts.WindowOverlap
+	}
+
+	notesPrompt := basePrompt
+	if opts.MapPrompt != "" {
+		notesPrompt = opts.MapPrompt
+	}
+
+	// The mode and overlap change every window's notes, so they are part
+	// of the key alongside the prompt
+	key := partialKey(ctx, chunks.Sum(), fmt.Sprintf("%s\x00%d\x00%s", ChunkModeWindow, overlap, notesPrompt), s.cfg.ChunkSize, opts)
+	if !opts.Resume {
+		s.partials.clear(key)
+	}
+	progress := s.partials.load(key, filename, total)
+
+	// The notes saved for window i cover windows 0 through i
+	start, notes := 0, ""
+	for i, saved := range progress.Results {
+		if i >= 0 && i < total-1 {
+			start, notes = i+1, saved
+		}
+	}
+	if start > 0 {
+		logf(ctx, "↩️  Resuming windowed analysis of %s at window %d of %d", filename, start+1, total)
+	}
+
+	var previous string
+	if start > 0 {
+		doneRead := timePhase(ctx, phaseRead)
+		chunk, err := chunks.Chunk(start - 1)
+		doneRead()
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+		previous = chunk
+	}
+
+	var result *mcp.CreateMessageResult
+	for i := start; i < total; i++ {
+		doneRead := timePhase(ctx, phaseRead)
+		chunk, err := chunks.Chunk(i)
+		doneRead()
+		if err != nil {
+			return errorResult("Error reading file: %v", err), nil
+		}
+
+		content := chunk
+		if repeated := overlapTail(previous, overlap); repeated != "" {
+			content = fmt.Sprintf("[Repeated from the previous window]\n%s\n[New content]\n%s", repeated, chunk)
+		}
+		previous = chunk
+
+		var systemPrompt string
+		if i < total-1 {
+			systemPrompt = fmt.Sprintf("%s The content is window %d of %d of a %s file named '%s', read in order. "+
+				"Do not answer yet. Instead, write running notes on the file so far, in at most %d words, "+
+				"keeping whatever the final answer will need and dropping detail that it will not. "+
+				"Respond with only the updated notes.", notesPrompt, i+1, total, mimeType, filename, windowNotesWords)
+		} else {
+			systemPrompt = fmt.Sprintf("%s The content is the last of %d windows of a %s file named '%s'. "+
+				"Using your notes on the earlier windows and this window, give the answer for the whole file.",
+				basePrompt, total, mimeType, filename)
+			if opts.ReducePrompt != "" {
+				systemPrompt += " " + opts.ReducePrompt
+			}
+		}
+		if notes != "" {
+			systemPrompt += "\n\nYour notes on the earlier windows:\n" + notes
+		}
+
+		logf(ctx, "📤 Sending sampling request for file: %s window %d/%d (analysis: %s)", filename, i+1, total, opts.AnalysisType)
+		windowRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: content}, systemPrompt)
+		opts.applyTo(&windowRequest)
+		if i < total-1 {
+			result, err = s.requestSampling(ctx, windowRequest)
+		} else {
+			result, err = s.sampleToLength(ctx, windowRequest, opts.TargetLength, s.requestSampling)
+		}
+		if err != nil {
+			log.Printf("❌ Sampling request failed: %v", err)
+			if i == 0 {
+				return errorResult("Error requesting sampling for window 1 of %d: %v", total, err), nil
+			}
+			return errorResult("Error requesting sampling for window %d of %d: %v\n"+
+				"Notes through window %d are saved; call again with resume enabled to continue from window %d.",
+				i+1, total, err, i, i+1), nil
+		}
+
+		if i < total-1 {
+			notes = strings.TrimSpace(resultText(result))
+			progress.Results = map[int]string{i: notes}
+			if err := s.partials.save(key, progress); err != nil {
+				log.Printf("Warning: Could not save partial results: %v", err)
+			}
+		}
+	}
+
+	logf(ctx, "✅ Windowed analysis successful! Model: %s", result.Model)
+	s.partials.clear(key)
+
+	return textResult(fmt.Sprintf("File Analysis Results\n"+
+		"=====================\n"+
+		"File: %s\n"+
+		"Type: %s\n"+
+		"Analysis: %s\n"+
+		"Model: %s\n"+
+		"Windows: %d (%d resumed, %d bytes overlap)\n\n"+
+		"%s", filename, mimeType, opts.AnalysisType, result.Model, total, start, overlap, opts.answerText(result, source))), nil
+}
@@ -0,0 +1,159 @@
+package analysis
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var windowRe = regexp.MustCompile(`window (\d+) of \d+`)
+
+// windowNotes answers each window with notes naming it and the last
+// window with the final answer. While failLast is set the last window
+// fails.
+func windowNotes(failLast *atomic.Bool) func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	return func(request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if match := windowRe.FindStringSubmatch(request.SystemPrompt); match != nil {
+			return textAnswer("notes after window " + match[1]), nil
+		}
+		if failLast != nil && failLast.Load() {
+			return nil, errors.New("provider unavailable")
+		}
+		return textAnswer("final answer"), nil
+	}
+}
+
+func TestWindowModeCarriesNotesBetweenWindows(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(3, 100)})
+	sampler := &mockSampler{respond: windowNotes(nil)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "chunk_mode": "window"})
+
+	requests := sampler.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("%d sampling requests, want one per window and no combine step", len(requests))
+	}
+	if strings.Contains(requests[0].SystemPrompt, "Your notes on the earlier windows") {
+		t.Errorf("the first window has notes: %s", requests[0].SystemPrompt)
+	}
+	if !strings.HasSuffix(requests[1].SystemPrompt, "Your notes on the earlier windows:\nnotes after window 1") {
+		t.Errorf("window 2 does not carry the notes from window 1: %s", requests[1].SystemPrompt)
+	}
+	// Notes replace, not accumulate: window 2's notes already cover window 1
+	last := requests[2].SystemPrompt
+	if !strings.HasSuffix(last, "\n\nYour notes on the earlier windows:\nnotes after window 2") || strings.Contains(last, "notes after window 1") {
+		t.Errorf("the last window does not carry only the latest notes: %s", last)
+	}
+	if !strings.Contains(last, "last of 3 windows") {
+		t.Errorf("the last window is not asked for the answer: %s", last)
+	}
+	if !strings.Contains(text, "Windows: 3 (0 resumed, 50 bytes overlap)") || !strings.HasSuffix(text, "final answer") {
+		t.Errorf("unexpected result:\n%s", text)
+	}
+}
+
+func TestWindowModeRepeatsOverlap(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(2, 100)})
+	sampler := &mockSampler{respond: windowNotes(nil)}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "chunk_mode": "window", "window_overlap": 20, "use_cache": false})
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "chunk_mode": "window", "window_overlap": 0, "use_cache": false})
+
+	requests := sampler.Requests()
+	if first := messageText(requests[0]); strings.Contains(first, "[Repeated") {
+		t.Errorf("the first window repeats content:\n%s", first)
+	}
+	// The last 20 bytes of chunk 0 are 19 x's and its line break
+	want := "[Repeated from the previous window]\n" + strings.Repeat("x", 19) + "\n\n[New content]\nchunk 1 "
+	if second := messageText(requests[1]); !strings.HasPrefix(second, want) {
+		t.Errorf("window 2 does not start with the overlap:\n%s", second)
+	}
+	if second := messageText(requests[3]); !strings.HasPrefix(second, "chunk 1 ") {
+		t.Errorf("window_overlap 0 still repeated content:\n%s", second)
+	}
+}
+
+func TestWindowModeResumesWithSavedNotes(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(3, 100)})
+	var failLast atomic.Bool
+	failLast.Store(true)
+	sampler := &mockSampler{respond: windowNotes(&failLast)}
+	c := connect(t, s, sampler)
+	args := map[string]any{"filename": "long.txt", "chunk_mode": "window", "use_cache": false}
+
+	text := mustFail(t, c, "analyze_file", args)
+	if !strings.Contains(text, "window 3 of 3") || !strings.Contains(text, "continue from window 3") {
+		t.Fatalf("failure does not say where to resume:\n%s", text)
+	}
+
+	failLast.Store(false)
+	_, text = mustSucceed(t, c, "analyze_file", args)
+
+	retry := sampler.Requests()[3:]
+	if len(retry) != 1 {
+		t.Fatalf("retry sent %d sampling requests, want only the last window", len(retry))
+	}
+	if !strings.HasSuffix(retry[0].SystemPrompt, "notes after window 2") {
+		t.Errorf("the resumed window does not carry the saved notes: %s", retry[0].SystemPrompt)
+	}
+	// The overlap comes from the chunk before, read again on resume
+	if !strings.HasPrefix(messageText(retry[0]), "[Repeated from the previous window]\n") {
+		t.Errorf("the resumed window lost its overlap:\n%s", messageText(retry[0]))
+	}
+	if !strings.Contains(text, "Windows: 3 (2 resumed") {
+		t.Errorf("result does not report the resumed windows:\n%s", text)
+	}
+}
+
+func TestWindowModeValidation(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(3, 100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"window_overlap": 10}, "window_overlap only applies with chunk_mode window"},
+		{map[string]any{"chunk_mode": "map_reduce", "window_overlap": 10}, "window_overlap only applies with chunk_mode window"},
+		{map[string]any{"chunk_mode": "window", "window_overlap": 51}, "window_overlap must be between 0 and 50 (half the chunk size)"},
+		{map[string]any{"chunk_mode": "window", "window_overlap": -1}, "window_overlap must be between 0 and 50"},
+		{map[string]any{"chunk_mode": "rolling"}, `unknown chunk_mode "rolling" (use map_reduce or window)`},
+	}
+	for _, tt := range tests {
+		tt.args["filename"] = "long.txt"
+		if text := mustFail(t, c, "analyze_file", tt.args); !strings.Contains(text, tt.want) {
+			t.Errorf("%v: error %q does not mention %q", tt.args, text, tt.want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid arguments sent %d sampling requests", n)
+	}
+}
+
+func TestOverlapTail(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"anything", 0, ""},
+		{"first line\nsecond", 9, "second"},
+		{"0123456789", 4, "6789"},
+		// A line break past the first half is ignored
+		{"abcdefgh\nij", 6, "fgh\nij"},
+		{"ééé", 3, "é"},
+	}
+	for _, tt := range tests {
+		if got := overlapTail(tt.text, tt.n); got != tt.want {
+			t.Errorf("overlapTail(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
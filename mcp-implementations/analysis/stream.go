@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// fileChunks is a long text file split into chunks that are read from disk
+// one at a time, so chunked analysis never holds the whole file in memory.
+// Only the chunk boundaries and a hash of the content are kept.
+type fileChunks struct {
+	path  string
+	spans []chunkSpan
+	sum   [sha256.Size]byte
+	// latin1 is set when the file is not valid UTF-8, and then applies to
+	// every chunk, as normalizeText does for the whole file
+	latin1 bool
+}
+
+// chunkSpan is the byte range of one chunk in the file.
+type chunkSpan struct {
+	Offset int64
+	Length int
+}
+
+// scanChunks reads the text file at path once, choosing chunk boundaries
+// of at most size bytes the way splitChunks does and hashing the content.
+// It returns nil for UTF-16 files, which must be decoded whole.
+func scanChunks(path string, size int) (*fileChunks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chunks := &fileChunks{path: path}
+	hash := sha256.New()
+	// One byte past the chunk size shows whether a cut splits a character
+	buf := make([]byte, size+1)
+	var offset int64
+	var bom bool
+	for {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		data := buf[:n]
+
+		if offset == 0 {
+			if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+				return nil, nil
+			}
+			if _, ok := guessUTF16(data[:min(len(data), 4096)&^1]); ok {
+				return nil, nil
+			}
+			if bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) {
+				// The BOM is not part of the text, and settles the encoding
+				offset, bom = 3, true
+				continue
+			}
+		}
+
+		last := len(data) <= size
+		cut := len(data)
+		if !last {
+			cut = chunkCut(data, size)
+		}
+		if cut == 0 {
+			break
+		}
+		hash.Write(data[:cut])
+		if !bom && !chunks.latin1 && !utf8.Valid(data[:cut]) {
+			chunks.latin1 = true
+		}
+		chunks.spans = append(chunks.spans, chunkSpan{Offset: offset, Length: cut})
+		offset += int64(cut)
+		if last {
+			break
+		}
+	}
+	if len(chunks.spans) == 0 {
+		return nil, fmt.Errorf("no text to analyze")
+	}
+	hash.Sum(chunks.sum[:0])
+	return chunks, nil
+}
+
+// chunkCut picks where the chunk starting data ends, at most size bytes
+// in, the way splitChunks does. A "\r\n" is never split, so line endings
+// normalize the same on both sides of the cut.
+func chunkCut(data []byte, size int) int {
+	cut := size
+	if nl := bytes.LastIndexByte(data[:size], '\n'); nl >= size/2 {
+		cut = nl + 1
+	} else {
+		for cut > 1 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+	}
+	if cut > 1 && data[cut-1] == '\r' && data[cut] == '\n' {
+		cut--
+	}
+	return cut
+}
+
+func (c *fileChunks) Len() int { return len(c.spans) }
+
+func (c *fileChunks) Sum() [sha256.Size]byte { return c.sum }
+
+// Chunk reads chunk i back from the file and normalizes it.
+func (c *fileChunks) Chunk(i int) (string, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	span := c.spans[i]
+	data := make([]byte, span.Length)
+	if _, err := f.ReadAt(data, span.Offset); err != nil {
+		return "", fmt.Errorf("reading chunk %d: %v", i+1, err)
+	}
+	text := string(data)
+	if c.latin1 {
+		text = decodeLatin1(data)
+	}
+	return normalizeLineEndings(text), nil
+}
@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeFixture writes data to a temporary file and returns its path.
+func writeFixture(tb testing.TB, data string) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "fixture.txt")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// readChunks reads every chunk of c.
+func readChunks(t *testing.T, c *fileChunks) []string {
+	t.Helper()
+	chunks := make([]string, c.Len())
+	for i := range chunks {
+		chunk, err := c.Chunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i] = chunk
+	}
+	return chunks
+}
+
+func TestScanChunksMatchesInMemorySplit(t *testing.T) {
+	for name, text := range map[string]string{
+		"lines":     chunkedFile(5, 100),
+		"multibyte": strings.Repeat("héllo wörld ", 60),
+		"short":     "one line",
+	} {
+		chunks, err := scanChunks(writeFixture(t, text), 100)
+		if err != nil || chunks == nil {
+			t.Fatalf("%s: scanChunks() = %v, %v", name, chunks, err)
+		}
+
+		got, want := readChunks(t, chunks), splitChunks(text, 100)
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s: streamed chunks differ from splitChunks:\n%q\nwant\n%q", name, got, want)
+		}
+		if chunks.Sum() != sha256.Sum256([]byte(text)) {
+			t.Errorf("%s: hash does not match the content", name)
+		}
+	}
+}
+
+func TestScanChunksNormalizesLikeWholeFile(t *testing.T) {
+	for name, data := range map[string]string{
+		// The CRLF straddles the 100-byte cut and must stay together
+		"crlf":    strings.Repeat("x", 99) + "\r\n" + strings.Repeat("y", 150) + "\r\n",
+		"bom":     "\ufeff" + chunkedFile(3, 100),
+		"latin-1": strings.Repeat("caf\xe9 ", 50),
+	} {
+		chunks, err := scanChunks(writeFixture(t, data), 100)
+		if err != nil || chunks == nil {
+			t.Fatalf("%s: scanChunks() = %v, %v", name, chunks, err)
+		}
+
+		want, _ := normalizeText([]byte(data))
+		if got := strings.Join(readChunks(t, chunks), ""); got != want {
+			t.Errorf("%s: streamed text differs from normalizeText:\n%q\nwant\n%q", name, got, want)
+		}
+	}
+}
+
+func TestScanChunksLeavesUTF16ToWholeFileDecoding(t *testing.T) {
+	for _, bom := range []bool{true, false} {
+		chunks, err := scanChunks(writeFixture(t, string(utf16Fixture(chunkedFile(3, 100), binary.LittleEndian, bom))), 100)
+		if err != nil || chunks != nil {
+			t.Errorf("bom=%t: scanChunks() = %v, %v; want nil to decode the whole file", bom, chunks, err)
+		}
+	}
+}
+
+func TestScanChunksAllocatesLessThanFile(t *testing.T) {
+	const fileSize, chunkSize = 4 << 20, 4096
+	path := writeFixture(t, chunkedFile(fileSize/chunkSize, chunkSize))
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	chunks, err := scanChunks(path, chunkSize)
+	runtime.ReadMemStats(&after)
+	if err != nil || chunks == nil {
+		t.Fatalf("scanChunks() = %v, %v", chunks, err)
+	}
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > fileSize/4 {
+		t.Errorf("scanning a %d-byte file allocated %d bytes, want well under the file size", fileSize, allocated)
+	}
+	if chunks.Len() != fileSize/chunkSize {
+		t.Errorf("got %d chunks, want %d", chunks.Len(), fileSize/chunkSize)
+	}
+}
+
+func BenchmarkScanChunks(b *testing.B) {
+	path := writeFixture(b, chunkedFile(1024, 4096))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := scanChunks(path, 4096); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadAndSplit(b *testing.B) {
+	path := writeFixture(b, chunkedFile(1024, 4096))
+	b.ReportAllocs()
+	for b.Loop() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		newSplitText(data, 4096)
+	}
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// multiLengthPrompt asks for three summaries of different lengths in one
+// response, each under a heading parseMultiLength can find.
+const multiLengthPrompt = "Please summarize this content at three lengths, using exactly these headings on their own lines:\n" +
+	"TL;DR: a single sentence\n" +
+	"SUMMARY: one paragraph\n" +
+	"KEY POINTS: a bulleted list, one point per line starting with \"- \""
+
+// multiLengthSummary is a parsed multi_length response.
+type multiLengthSummary struct {
+	TLDR      string
+	Summary   string
+	KeyPoints []string
+}
+
+var multiLengthHeading = regexp.MustCompile(`(?im)^[#*\s]*(TL;DR|SUMMARY|KEY POINTS)[*\s]*:[*]*[ \t]*`)
+
+// parseMultiLength splits a response into its TL;DR, summary and key point
+// sections. It reports false when any section is missing or empty.
+func parseMultiLength(text string) (multiLengthSummary, bool) {
+	sections := map[string]string{}
+	matches := multiLengthHeading.FindAllStringSubmatchIndex(text, -1)
+	for i, m := range matches {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		name := strings.ToUpper(text[m[2]:m[3]])
+		sections[name] = strings.TrimSpace(text[m[1]:end])
+	}
+
+	var summary multiLengthSummary
+	summary.TLDR = sections["TL;DR"]
+	summary.Summary = sections["SUMMARY"]
+	for _, line := range strings.Split(sections["KEY POINTS"], "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+		if line != "" {
+			summary.KeyPoints = append(summary.KeyPoints, line)
+		}
+	}
+
+	ok := summary.TLDR != "" && summary.Summary != "" && len(summary.KeyPoints) > 0
+	return summary, ok
+}
+
+// formatMultiLength renders a multi_length response as labeled sections,
+// or returns it unchanged with a note when it could not be parsed.
+func formatMultiLength(text string) string {
+	summary, ok := parseMultiLength(text)
+	if !ok {
+		return "(The response did not contain all three summary sections; showing it as returned.)\n\n" + text
+	}
+
+	points := make([]string, len(summary.KeyPoints))
+	for i, p := range summary.KeyPoints {
+		points[i] = "- " + p
+	}
+
+	return fmt.Sprintf("TL;DR\n-----\n%s\n\n"+
+		"Summary\n-------\n%s\n\n"+
+		"Key Points\n----------\n%s", summary.TLDR, summary.Summary, strings.Join(points, "\n"))
+}
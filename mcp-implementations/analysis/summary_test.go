@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const multiLengthAnswer = "**TL;DR:** The launch moves to May.\n\n" +
+	"SUMMARY:\nThe team agreed to delay the launch by a month to finish testing.\n\n" +
+	"KEY POINTS:\n- Launch moves to May\n* Testing needs more time\n"
+
+func TestAnalyzeFileMultiLengthSections(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Meeting notes."})
+	sampler := &mockSampler{respond: answers(multiLengthAnswer)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "multi_length": true})
+
+	if !strings.HasPrefix(sampler.Requests()[0].SystemPrompt, multiLengthPrompt) {
+		t.Errorf("system prompt does not ask for three lengths: %q", sampler.Requests()[0].SystemPrompt)
+	}
+	for _, want := range []string{
+		"TL;DR\n-----\nThe launch moves to May.",
+		"Summary\n-------\nThe team agreed",
+		"Key Points\n----------\n- Launch moves to May\n- Testing needs more time",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestAnalyzeFileMultiLengthMissingSection(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Meeting notes."})
+	c := connect(t, s, &mockSampler{respond: answers("TL;DR: Short.\nSUMMARY: Longer.")})
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "multi_length": true})
+	if !strings.Contains(text, "did not contain all three summary sections") || !strings.Contains(text, "TL;DR: Short.") {
+		t.Errorf("an incomplete answer is not returned as is with a note:\n%s", text)
+	}
+}
+
+func TestAnalyzeFileMultiLengthOnlyForSummarize(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Meeting notes."})
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "multi_length": true, "analysis_type": "explain"})
+	if !strings.Contains(text, "multi_length only applies") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
@@ -0,0 +1,254 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var extractTablesTool = mcp.Tool{
+	Name:        "extract_tables",
+	Description: "Find tables in a text file and return them as structured JSON, parsing Markdown and HTML tables directly and using LLM sampling for the rest",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The document to extract tables from (relative to files directory)",
+			},
+			"parse_locally": map[string]any{
+				"type":        "boolean",
+				"description": "Parse Markdown and HTML tables without sampling when the file has any (default true)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// Table is one table found in a document. Every row has one cell per column.
+type Table struct {
+	Title   string     `json:"title,omitempty"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// TableExtraction is the structured result of extract_tables. Source is
+// "parsed" when the tables were read directly and "model" when sampled.
+type TableExtraction struct {
+	File   string  `json:"file"`
+	Source string  `json:"source"`
+	Model  string  `json:"model,omitempty"`
+	Tables []Table `json:"tables"`
+}
+
+func (s *Server) handleExtractTables(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	parseLocally := request.GetBool("parse_locally", true)
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	extraction := TableExtraction{File: filename}
+	if parseLocally {
+		var tables []Table
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".md", ".markdown":
+			tables = parseMarkdownTables(text)
+		case ".html", ".htm":
+			tables = parseHTMLTables(text)
+		}
+		if len(tables) > 0 && validateTables(tables) == nil {
+			logf(ctx, "✅ Parsed %d tables from %s without sampling", len(tables), filename)
+			extraction.Source, extraction.Tables = "parsed", tables
+			return tableResult(extraction)
+		}
+	}
+
+	// Tables are looked for in the part of long documents truncation keeps
+	excerpt, _ := truncateFor(ctx, text, s.cfg.ChunkSize)
+	content := mcp.TextContent{Type: "text", Text: excerpt}
+	systemPrompt := "Find every table or block of tabular data in this document. " +
+		`Respond with only a JSON object: {"tables": [{"title": "<caption or nearby heading, if any>", "columns": ["<header>", ...], "rows": [["<cell>", ...], ...]}]}. ` +
+		"Every row must have exactly one cell per column; use an empty string for a missing value. If there are no tables, return {\"tables\": []}."
+
+	var tables []Table
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 4000
+
+	logf(ctx, "📤 Sending sampling request to extract tables from: %s", filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid tables", func(text string) (err error) {
+		tables, err = parseTables(text)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	extraction.Model = result.Model
+
+	logf(ctx, "✅ Extracted %d tables from %s", len(tables), filename)
+	extraction.Source, extraction.Tables = "model", tables
+	return tableResult(extraction)
+}
+
+// parseTables decodes and validates the model's tables.
+func parseTables(text string) ([]Table, error) {
+	var answer struct {
+		Tables []Table `json:"tables"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+	if err := validateTables(answer.Tables); err != nil {
+		return nil, err
+	}
+	if answer.Tables == nil {
+		answer.Tables = []Table{}
+	}
+	return answer.Tables, nil
+}
+
+// tableResult encodes an extraction as the tool's JSON output.
+func tableResult(extraction TableExtraction) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(extraction, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding tables: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// validateTables checks that every table has columns and that each of its
+// rows has exactly one cell per column.
+func validateTables(tables []Table) error {
+	for i, t := range tables {
+		if len(t.Columns) == 0 {
+			return fmt.Errorf("table %d has no columns", i+1)
+		}
+		for j, row := range t.Rows {
+			if len(row) != len(t.Columns) {
+				return fmt.Errorf("table %d row %d has %d cells for %d columns", i+1, j+1, len(row), len(t.Columns))
+			}
+		}
+	}
+	return nil
+}
+
+// markdownSeparator matches a table's header separator row, e.g. |---|:--:|.
+var markdownSeparator = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+
+// parseMarkdownTables reads GitHub-style pipe tables. Each table is titled
+// with the closest heading above it. Short rows are padded with empty
+// cells; a row with too many cells is kept as is and fails validation.
+func parseMarkdownTables(text string) []Table {
+	lines := strings.Split(text, "\n")
+	var tables []Table
+	heading := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "#") {
+			heading = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			continue
+		}
+		if !strings.Contains(line, "|") || i+1 >= len(lines) || !markdownSeparator.MatchString(strings.TrimSpace(lines[i+1])) {
+			continue
+		}
+
+		table := Table{Title: heading, Columns: markdownCells(line), Rows: [][]string{}}
+		i += 2
+		for ; i < len(lines); i++ {
+			row := strings.TrimSpace(lines[i])
+			if !strings.Contains(row, "|") {
+				break
+			}
+			cells := markdownCells(row)
+			for len(cells) < len(table.Columns) {
+				cells = append(cells, "")
+			}
+			table.Rows = append(table.Rows, cells)
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// markdownCells splits a pipe table row into trimmed cells, honoring
+// escaped pipes.
+func markdownCells(row string) []string {
+	row = strings.TrimPrefix(strings.TrimSuffix(row, "|"), "|")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+var (
+	htmlTablePattern   = regexp.MustCompile(`(?is)<table\b.*?</table>`)
+	htmlCaptionPattern = regexp.MustCompile(`(?is)<caption\b[^>]*>(.*?)</caption>`)
+	htmlRowPattern     = regexp.MustCompile(`(?is)<tr\b.*?</tr>`)
+	htmlCellPattern    = regexp.MustCompile(`(?is)<t([hd])\b[^>]*>(.*?)</t[hd]>`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// parseHTMLTables reads <table> elements. The first row is the header;
+// nested tables and spanning cells are not understood, and produce rows
+// that fail validation so the model handles them instead.
+func parseHTMLTables(text string) []Table {
+	var tables []Table
+	for _, tableHTML := range htmlTablePattern.FindAllString(text, -1) {
+		table := Table{Rows: [][]string{}}
+		if m := htmlCaptionPattern.FindStringSubmatch(tableHTML); m != nil {
+			table.Title = htmlText(m[1])
+		}
+		for _, rowHTML := range htmlRowPattern.FindAllString(tableHTML, -1) {
+			var cells []string
+			for _, m := range htmlCellPattern.FindAllStringSubmatch(rowHTML, -1) {
+				cells = append(cells, htmlText(m[2]))
+			}
+			if len(cells) == 0 {
+				continue
+			}
+			if table.Columns == nil {
+				table.Columns = cells
+				continue
+			}
+			table.Rows = append(table.Rows, cells)
+		}
+		if table.Columns != nil {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// htmlText strips tags and entities from an HTML fragment and collapses
+// its whitespace.
+func htmlText(fragment string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(fragment, " "))), " ")
+}
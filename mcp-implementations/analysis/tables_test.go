@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const markdownTableFixture = `# Sales
+
+Quarterly results by region.
+
+| Region | Q1 | Q2 |
+|--------|---:|:---:|
+| North  | 10 | 12 |
+| South \| East | 7 |
+`
+
+func extraction(t *testing.T, text string) TableExtraction {
+	t.Helper()
+	var e TableExtraction
+	if err := json.Unmarshal([]byte(text), &e); err != nil {
+		t.Fatalf("result is not a table extraction: %v\n%s", err, text)
+	}
+	return e
+}
+
+func TestExtractTablesParsesMarkdownWithoutSampling(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"sales.md": markdownTableFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "extract_tables", map[string]any{"filename": "sales.md"})
+
+	got := extraction(t, text)
+	want := []Table{{
+		Title:   "Sales",
+		Columns: []string{"Region", "Q1", "Q2"},
+		Rows:    [][]string{{"North", "10", "12"}, {"South | East", "7", ""}},
+	}}
+	if got.Source != "parsed" || !reflect.DeepEqual(got.Tables, want) {
+		t.Errorf("got %+v, want parsed %+v", got, want)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a parsable table still sent %d sampling requests", n)
+	}
+}
+
+func TestExtractTablesParsesHTML(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"page.html": "<table><caption>Staff</caption>" +
+		"<tr><th>Name</th><th>Role</th></tr><tr><td>Ada &amp; co</td><td><b>Lead</b></td></tr></table>"})
+	c := connect(t, s, &mockSampler{})
+
+	_, text := mustSucceed(t, c, "extract_tables", map[string]any{"filename": "page.html"})
+
+	got := extraction(t, text)
+	want := []Table{{Title: "Staff", Columns: []string{"Name", "Role"}, Rows: [][]string{{"Ada & co", "Lead"}}}}
+	if got.Source != "parsed" || !reflect.DeepEqual(got.Tables, want) {
+		t.Errorf("got %+v, want parsed %+v", got, want)
+	}
+}
+
+func TestExtractTablesFallsBackToModel(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"sales.md": markdownTableFixture})
+	sampler := &mockSampler{respond: answers(`{"tables": [{"columns": ["Region", "Total"], "rows": [["North", "22"]]}]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "extract_tables", map[string]any{"filename": "sales.md", "parse_locally": false})
+
+	got := extraction(t, text)
+	if got.Source != "model" || got.Model != "mock-model" || len(got.Tables) != 1 || got.Tables[0].Rows[0][1] != "22" {
+		t.Errorf("unexpected extraction: %+v", got)
+	}
+	if n := len(sampler.Requests()); n != 1 {
+		t.Errorf("got %d sampling requests, want 1", n)
+	}
+}
+
+func TestExtractTablesRepromptsOnRaggedRows(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Name: Ada, Role: Lead"})
+	sampler := &mockSampler{respond: answers(
+		`{"tables": [{"columns": ["Name", "Role"], "rows": [["Ada"]]}]}`,
+		`{"tables": [{"columns": ["Name", "Role"], "rows": [["Ada", "Lead"]]}]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "extract_tables", map[string]any{"filename": "notes.txt"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, "table 1 row 1 has 1 cells for 2 columns") {
+		t.Errorf("ragged rows were not reprompted: %d requests", len(requests))
+	}
+	if got := extraction(t, text); len(got.Tables) != 1 || !reflect.DeepEqual(got.Tables[0].Rows, [][]string{{"Ada", "Lead"}}) {
+		t.Errorf("unexpected extraction: %+v", got)
+	}
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DefaultTagCount is used when generate_tags is not given a count.
+	DefaultTagCount = 5
+	// MaxTagCount bounds count; past it tags stop describing the file.
+	MaxTagCount = 30
+	// maxTagLength rejects "tags" that are really phrases or sentences.
+	maxTagLength = 40
+)
+
+var generateTagsTool = mcp.Tool{
+	Name:        "generate_tags",
+	Description: "Generate concise lowercase tags or keywords describing a text file using LLM sampling, returned as a JSON array of exactly the requested number of distinct tags",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to tag (relative to files directory)",
+			},
+			"count": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of tags (default %d, max %d)", DefaultTagCount, MaxTagCount),
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// Tags is the structured result of generate_tags.
+type Tags struct {
+	File  string   `json:"file"`
+	Model string   `json:"model"`
+	Tags  []string `json:"tags"`
+	// Truncated reports that only part of the file was tagged
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleGenerateTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	count := request.GetInt("count", DefaultTagCount)
+	if count < 1 || count > MaxTagCount {
+		return errorResult("count must be between 1 and %d", MaxTagCount), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	tags := Tags{File: filename}
+	text, tags.Truncated = truncateFor(ctx, text, s.cfg.ChunkSize)
+
+	content := mcp.TextContent{Type: "text", Text: text}
+	systemPrompt := fmt.Sprintf("Write exactly %d distinct tags that describe this document's subject, for search and categorization. "+
+		"Each tag is a lowercase keyword or short phrase of one to three words, without # or punctuation. "+
+		"Prefer specific topics over generic words such as document or text. "+
+		`Respond with only a JSON object: {"tags": ["...", "..."]}.`, count)
+
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 20*count + 50
+
+	logf(ctx, "📤 Sending sampling request to generate %d tags for: %s", count, filename)
+	result, err := s.sampleJSON(ctx, samplingRequest, "valid tags", func(text string) (err error) {
+		tags.Tags, err = parseTags(text, count)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	tags.Model = result.Model
+
+	logf(ctx, "✅ Generated %d tags for %s: %s", len(tags.Tags), filename, strings.Join(tags.Tags, ", "))
+
+	data, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding tags: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseTags decodes the model's tags, lowercasing them and dropping
+// duplicates, and checks that want distinct tags remain. Extra tags past
+// want are dropped rather than rejected.
+func parseTags(text string, want int) ([]string, error) {
+	var answer struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	var tags []string
+	for _, tag := range answer.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) < want {
+		return nil, fmt.Errorf("expected %d distinct tags, got %d", want, len(tags))
+	}
+	return tags[:want], nil
+}
+
+// normalizeTag lowercases a tag, drops a leading # and collapses its
+// whitespace, so "#Machine  Learning" and "machine learning" are one tag.
+func normalizeTag(tag string) string {
+	tag = strings.TrimLeft(strings.TrimSpace(tag), "#")
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// tagsOf decodes a generate_tags result.
+func tagsOf(t *testing.T, text string) Tags {
+	t.Helper()
+	var tags Tags
+	if err := json.Unmarshal([]byte(text), &tags); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, text)
+	}
+	return tags
+}
+
+var tagFormat = regexp.MustCompile(`^[a-z0-9]+(?: [a-z0-9]+)*$`)
+
+func TestGenerateTagsCountAndFormat(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study of neural networks for protein folding."})
+	sampler := &mockSampler{respond: answers(`{"tags": ["#Machine  Learning", "machine learning", "Proteins", "NEURAL NETWORKS", "biology", "folding", "extra"]}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": 4})
+
+	tags := tagsOf(t, text)
+	want := []string{"machine learning", "proteins", "neural networks", "biology"}
+	if strings.Join(tags.Tags, "|") != strings.Join(want, "|") {
+		t.Errorf("tags = %q, want %q", tags.Tags, want)
+	}
+	for _, tag := range tags.Tags {
+		if !tagFormat.MatchString(tag) {
+			t.Errorf("tag %q is not a lowercase keyword", tag)
+		}
+	}
+	if tags.File != "paper.md" || tags.Model != "mock-model" || tags.Truncated {
+		t.Errorf("unexpected result: %+v", tags)
+	}
+	request := sampler.Requests()[0]
+	if !strings.Contains(request.SystemPrompt, "exactly 4 distinct tags") || request.Temperature != 0 || request.MaxTokens != 130 {
+		t.Errorf("unexpected request: %q, temperature %v, max tokens %d", request.SystemPrompt, request.Temperature, request.MaxTokens)
+	}
+}
+
+func TestGenerateTagsDefaultCount(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study."})
+	c := connect(t, s, &mockSampler{respond: answers(`{"tags": ["a", "b", "c", "d", "e", "f"]}`)})
+
+	_, text := mustSucceed(t, c, "generate_tags", map[string]any{"filename": "paper.md"})
+	if tags := tagsOf(t, text); len(tags.Tags) != DefaultTagCount {
+		t.Errorf("%d tags, want DefaultTagCount %d", len(tags.Tags), DefaultTagCount)
+	}
+}
+
+func TestGenerateTagsRetriesTooFewDistinctTags(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study."})
+	sampler := &mockSampler{respond: answers(
+		`{"tags": ["Go", "go", "#go"]}`,
+		`{"tags": ["go", "concurrency", "channels"]}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": 3})
+
+	if tags := tagsOf(t, text); strings.Join(tags.Tags, ",") != "go,concurrency,channels" {
+		t.Errorf("tags = %q", tags.Tags)
+	}
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(requests[1].SystemPrompt, "expected 3 distinct tags, got 1") {
+		t.Errorf("the duplicate tags were not asked again with the reason: %d requests", len(requests))
+	}
+}
+
+func TestGenerateTagsRejectsSentences(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study."})
+	c := connect(t, s, &mockSampler{respond: answers(`{"tags": ["this document describes a study of many things"]}`)})
+
+	text := mustFail(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": 1})
+	if !strings.Contains(text, "after a retry") || !strings.Contains(text, "longer than 40 characters") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
+
+func TestGenerateTagsCountBounds(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"paper.md": "A study."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for _, count := range []int{0, MaxTagCount + 1} {
+		if text := mustFail(t, c, "generate_tags", map[string]any{"filename": "paper.md", "count": count}); !strings.Contains(text, "count must be between 1 and 30") {
+			t.Errorf("count %d: unexpected error %s", count, text)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid counts sent %d sampling requests", n)
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"  #Machine\tLearning ": "machine learning",
+		"##go":                  "go",
+		"#":                     "",
+		"C++":                   "c++",
+	}
+	for tag, want := range tests {
+		if got := normalizeTag(tag); got != want {
+			t.Errorf("normalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// targetLength is a requested summary length, such as "50 words" or
+// "3 sentences".
+type targetLength struct {
+	Count int
+	// Unit is "words" or "sentences"
+	Unit string
+}
+
+var targetLengthPattern = regexp.MustCompile(`^(\d+)\s*(words?|sentences?)$`)
+
+// parseTargetLength reads a target_length argument.
+func parseTargetLength(s string) (targetLength, error) {
+	m := targetLengthPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return targetLength{}, fmt.Errorf("Invalid target_length %q (expected e.g. \"50 words\" or \"3 sentences\")", s)
+	}
+	count, err := strconv.Atoi(m[1])
+	if err != nil || count < 1 {
+		return targetLength{}, fmt.Errorf("Invalid target_length %q: the count must be at least 1", s)
+	}
+	unit := strings.TrimSuffix(m[2], "s") + "s"
+	return targetLength{Count: count, Unit: unit}, nil
+}
+
+func (t targetLength) String() string {
+	if t.Count == 1 {
+		return "1 " + strings.TrimSuffix(t.Unit, "s")
+	}
+	return fmt.Sprintf("%d %s", t.Count, t.Unit)
+}
+
+// measure counts the words or sentences of text.
+func (t targetLength) measure(text string) int {
+	if t.Unit == "sentences" {
+		return measureReadability(text).Sentences
+	}
+	return len(strings.Fields(text))
+}
+
+// check reports an answer that is wildly off the target: more than twice
+// as long, or less than half.
+func (t targetLength) check(text string) error {
+	n := t.measure(text)
+	if n > 2*t.Count || 2*n < t.Count {
+		return fmt.Errorf("it was %d %s long, for a target of %s", n, t.Unit, t)
+	}
+	return nil
+}
+
+// sampleToLength sends request through sample with an instruction to keep
+// the answer to target, and reprompts once if the answer is wildly off.
+// The second answer is returned whatever its length. An empty target
+// samples request unchanged.
+func (s *Server) sampleToLength(ctx context.Context, request mcp.CreateMessageRequest, target string,
+	sample func(context.Context, mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)) (*mcp.CreateMessageResult, error) {
+	if target == "" {
+		return sample(ctx, request)
+	}
+	length, err := parseTargetLength(target)
+	if err != nil {
+		return nil, err
+	}
+
+	request.SystemPrompt += fmt.Sprintf(" Write exactly %s; the answer must fit a fixed space.", length)
+	result, err := sample(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	lengthErr := length.check(resultText(result))
+	if lengthErr == nil {
+		return result, nil
+	}
+
+	logf(ctx, "Answer missed target length (%v); reprompting", lengthErr)
+	request.SystemPrompt += fmt.Sprintf(" Your previous answer was the wrong length (%v). Rewrite it as exactly %s.", lengthErr, length)
+	result, err = sample(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if lengthErr := length.check(resultText(result)); lengthErr != nil {
+		log.Printf("Warning: Answer still missed target length after a retry (%v)", lengthErr)
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTargetLengthReachesPrompt(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: answers("one two three four five")}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "target_length": "5 words"})
+
+	requests := sampler.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("an answer on target took %d requests, want 1", len(requests))
+	}
+	if !strings.Contains(requests[0].SystemPrompt, "Write exactly 5 words; the answer must fit a fixed space.") {
+		t.Errorf("system prompt does not give the length: %q", requests[0].SystemPrompt)
+	}
+}
+
+func TestTargetLengthRepromptsOnOverflow(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{respond: answers(
+		"One. Two. Three. Four. Five. Six. Seven.",
+		"One. Two. Three.",
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "target_length": "3 sentences"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, "wrong length (it was 7 sentences long, for a target of 3 sentences)") {
+		t.Errorf("reprompt does not say how far off the answer was: %q", requests[1].SystemPrompt)
+	}
+	if !strings.Contains(text, "One. Two. Three.") || strings.Contains(text, "Seven") {
+		t.Errorf("result does not carry the rewritten answer:\n%s", text)
+	}
+}
+
+func TestTargetLengthAcceptsCloseAnswers(t *testing.T) {
+	length := targetLength{Count: 50, Unit: "words"}
+	for words, wantErr := range map[int]bool{24: true, 25: false, 50: false, 100: false, 101: true} {
+		err := length.check(strings.Repeat("word ", words))
+		if (err != nil) != wantErr {
+			t.Errorf("%d words for a target of 50: error %v", words, err)
+		}
+	}
+}
+
+func TestParseTargetLength(t *testing.T) {
+	for input, want := range map[string]string{"50 words": "50 words", "1 Sentence": "1 sentence", " 3sentences ": "3 sentences"} {
+		got, err := parseTargetLength(input)
+		if err != nil || got.String() != want {
+			t.Errorf("parseTargetLength(%q) = %v, %v; want %s", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "fifty words", "0 words", "3 paragraphs"} {
+		if _, err := parseTargetLength(input); err == nil {
+			t.Errorf("parseTargetLength(%q) accepted", input)
+		}
+	}
+}
+
+func TestTargetLengthOnlyForSummaries(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "analysis_type": "sentiment", "target_length": "5 words"})
+	if !strings.Contains(text, "target_length only applies to analysis_type summarize") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	text = mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "target_length": "lots"})
+	if !strings.Contains(text, `Invalid target_length "lots"`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
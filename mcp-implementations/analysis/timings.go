@@ -0,0 +1,90 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Phases of an analysis that with_timings reports.
+const (
+	phaseRead     = "read"
+	phasePrompt   = "prompt"
+	phaseSampling = "sampling"
+)
+
+// timings accumulates how long each phase of one analysis took. A phase
+// can run more than once, e.g. sampling for every chunk of a long file, so
+// durations add up.
+type timings struct {
+	mu        sync.Mutex
+	phases    map[string]time.Duration
+	samplings int
+}
+
+type timingsKey struct{}
+
+// withTimings returns a context whose analysis phases are recorded in t.
+func withTimings(ctx context.Context, t *timings) context.Context {
+	return context.WithValue(ctx, timingsKey{}, t)
+}
+
+// timePhase starts timing phase for the analysis ctx belongs to and returns
+// the function that stops it; stopping it again does nothing. Without
+// with_timings it does nothing at all.
+func timePhase(ctx context.Context, phase string) func() {
+	t, ok := ctx.Value(timingsKey{}).(*timings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.add(phase, time.Since(start)) })
+	}
+}
+
+// add records d against phase.
+func (t *timings) add(phase string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[phase] += d
+	if phase == phaseSampling {
+		t.samplings++
+	}
+}
+
+// addTo appends the breakdown to result's text and puts it, in
+// milliseconds, under "timings" in its _meta. cached marks a result served
+// from the analysis cache, for which only the total means anything.
+func (t *timings) addTo(result *mcp.CallToolResult, total time.Duration, cached bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	meta := map[string]any{"total_ms": ms(total), "cached": cached}
+	line := fmt.Sprintf("Timings: cached result, total %s", total.Round(time.Microsecond))
+	if !cached {
+		meta["read_ms"] = ms(t.phases[phaseRead])
+		meta["prompt_ms"] = ms(t.phases[phasePrompt])
+		meta["sampling_ms"] = ms(t.phases[phaseSampling])
+		meta["sampling_requests"] = t.samplings
+		line = fmt.Sprintf("Timings: file read %s, prompt build %s, sampling %s (round trips: %d), total %s",
+			t.phases[phaseRead].Round(time.Microsecond), t.phases[phasePrompt].Round(time.Microsecond),
+			t.phases[phaseSampling].Round(time.Microsecond), t.samplings, total.Round(time.Microsecond))
+	}
+
+	if result.Meta == nil {
+		result.Meta = mcp.NewMetaFromMap(map[string]any{})
+	}
+	result.Meta.AdditionalFields["timings"] = meta
+	if len(result.Content) > 0 {
+		if text, ok := result.Content[0].(mcp.TextContent); ok {
+			text.Text += "\n\n" + line
+			result.Content[0] = text
+		}
+	}
+}
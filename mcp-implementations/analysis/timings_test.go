@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// slowSampler answers after delay, so sampling time is measurable.
+func slowSampler(delay time.Duration) *mockSampler {
+	return &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		time.Sleep(delay)
+		return textAnswer(mockAnswer), nil
+	}}
+}
+
+// timingsOf returns the timings the result reports in its _meta.
+func timingsOf(t *testing.T, result *mcp.CallToolResult) map[string]any {
+	t.Helper()
+	if result.Meta == nil {
+		t.Fatal("result has no _meta")
+	}
+	timings, ok := result.Meta.AdditionalFields["timings"].(map[string]any)
+	if !ok {
+		t.Fatalf("_meta has no timings: %v", result.Meta.AdditionalFields)
+	}
+	return timings
+}
+
+func TestWithTimingsBreaksDownPhases(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, slowSampler(20*time.Millisecond))
+
+	result, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "with_timings": true})
+
+	if !strings.Contains(text, "Timings: file read ") || !strings.Contains(text, "(round trips: 1)") {
+		t.Errorf("result text has no timing line:\n%s", text)
+	}
+	timings := timingsOf(t, result)
+	ms := func(field string) float64 {
+		v, ok := timings[field].(float64)
+		if !ok || v < 0 {
+			t.Errorf("timings.%s = %v, want a duration in milliseconds", field, timings[field])
+		}
+		return v
+	}
+	read, prompt, sampling, total := ms("read_ms"), ms("prompt_ms"), ms("sampling_ms"), ms("total_ms")
+	if sampling < 20 {
+		t.Errorf("sampling_ms = %v, want at least the sampler's 20ms delay", sampling)
+	}
+	if total < read+prompt+sampling {
+		t.Errorf("total_ms %v is less than its phases %v + %v + %v", total, read, prompt, sampling)
+	}
+	if timings["cached"] != false || timings["sampling_requests"] != float64(1) {
+		t.Errorf("timings = %v, want one uncached round trip", timings)
+	}
+}
+
+func TestWithTimingsCountsEveryChunk(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 100}, map[string]string{"long.txt": chunkedFile(3, 100)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	result, _ := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "long.txt", "with_timings": true})
+
+	if len(sampler.Requests()) < 2 {
+		t.Fatalf("the file was not chunked: %d sampling requests", len(sampler.Requests()))
+	}
+	if got, want := timingsOf(t, result)["sampling_requests"], float64(len(sampler.Requests())); got != want {
+		t.Errorf("sampling_requests = %v, want every chunk and the combine step, %v", got, want)
+	}
+}
+
+func TestWithTimingsOnCachedResult(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "with_timings": true})
+	result, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "with_timings": true})
+
+	if strings.Count(text, "Timings:") != 1 || !strings.Contains(text, "Timings: cached result") {
+		t.Errorf("cached result does not report its own timings once:\n%s", text)
+	}
+	timings := timingsOf(t, result)
+	if timings["cached"] != true {
+		t.Errorf("timings = %v, want cached", timings)
+	}
+	if _, ok := timings["sampling_ms"]; ok {
+		t.Errorf("cached result reports a sampling time: %v", timings)
+	}
+}
+
+func TestWithoutTimingsReportsNone(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	result, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if strings.Contains(text, "Timings:") {
+		t.Errorf("timings reported without with_timings:\n%s", text)
+	}
+	if result.Meta != nil {
+		if _, ok := result.Meta.AdditionalFields["timings"]; ok {
+			t.Error("_meta has timings without with_timings")
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxToolTurns bounds how many tool calls a sampled model may make before
+// it must give its final answer.
+const MaxToolTurns = 5
+
+// checkToolNames reports the first name that is not a tool a sampled model
+// may call: an unknown tool, or one that samples itself, so one analysis
+// could fan out into many.
+func (s *Server) checkToolNames(names []string) error {
+	for _, name := range names {
+		if _, ok := s.tools[name]; !ok {
+			return fmt.Errorf("Unknown tool %q", name)
+		}
+		if s.sampling[name] {
+			return fmt.Errorf("Tool %q cannot be offered to the model", name)
+		}
+	}
+	return nil
+}
+
+// toolDeclarations describes the named tools in the form the sampling
+// client forwards to its provider.
+func (s *Server) toolDeclarations(names []string) []map[string]any {
+	declarations := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		tool := s.tools[name].Tool
+		declarations = append(declarations, map[string]any{
+			"name":         tool.Name,
+			"description":  tool.Description,
+			"input_schema": tool.InputSchema,
+		})
+	}
+	return declarations
+}
+
+// toolCall is a tool call returned by the sampling client in _meta.tool_use.
+type toolCall struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input"`
+}
+
+// toolCallFrom reads the model's tool call from a sampling result, if any.
+func toolCallFrom(result *mcp.CreateMessageResult) (toolCall, bool) {
+	if result.Meta == nil || result.Meta.AdditionalFields["tool_use"] == nil {
+		return toolCall{}, false
+	}
+	data, err := json.Marshal(result.Meta.AdditionalFields["tool_use"])
+	if err != nil {
+		return toolCall{}, false
+	}
+	var call toolCall
+	if err := json.Unmarshal(data, &call); err != nil || call.Name == "" {
+		return toolCall{}, false
+	}
+	return call, true
+}
+
+// sampleWithTools sends a sampling request that declares the named server
+// tools. Each time the model answers with a tool call, the tool is run and
+// its output is fed back in a follow-up turn, up to MaxToolTurns calls.
+// Without tools it is a plain requestSampling.
+func (s *Server) sampleWithTools(ctx context.Context, request mcp.CreateMessageRequest, tools []string) (*mcp.CreateMessageResult, error) {
+	if len(tools) == 0 {
+		return s.requestSampling(ctx, request)
+	}
+	setMetadata(&request, "tools", s.toolDeclarations(tools))
+
+	for turn := 0; ; turn++ {
+		result, err := s.requestSampling(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		call, ok := toolCallFrom(result)
+		if !ok {
+			return result, nil
+		}
+		if turn == MaxToolTurns {
+			return nil, fmt.Errorf("the model was still calling tools after %d calls", MaxToolTurns)
+		}
+
+		output := s.runToolCall(ctx, call, tools)
+		input, _ := json.Marshal(call.Input)
+		request.Messages = append(slices.Clone(request.Messages),
+			mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%sCalling tool %s with %s", withTrailingNewline(resultText(result)), call.Name, input),
+			}},
+			mcp.SamplingMessage{Role: mcp.RoleUser, Content: mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Result of tool %s:\n%s", call.Name, output),
+			}},
+		)
+	}
+}
+
+// runToolCall runs one tool call from the model and returns the text fed
+// back to it. Failures are reported to the model rather than ending the
+// analysis, so it can recover or answer without the tool.
+func (s *Server) runToolCall(ctx context.Context, call toolCall, allowed []string) string {
+	if !slices.Contains(allowed, call.Name) {
+		log.Printf("🔧 Model called undeclared tool %s; refusing", call.Name)
+		return fmt.Sprintf("Error: tool %s is not available", call.Name)
+	}
+
+	logf(ctx, "🔧 Model called tool %s", call.Name)
+	var request mcp.CallToolRequest
+	request.Params.Name = call.Name
+	request.Params.Arguments = call.Input
+
+	result, err := s.tools[call.Name].Handler(ctx, request)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	output := toolResultText(result)
+	if result.IsError {
+		output = "Error: " + output
+	}
+	if len(output) > s.cfg.ChunkSize {
+		output = output[:s.cfg.ChunkSize] + "\n[output truncated]"
+	}
+	return output
+}
+
+// withTrailingNewline returns text followed by a newline, or "" for empty text.
+func withTrailingNewline(text string) string {
+	if text == "" {
+		return ""
+	}
+	return text + "\n"
+}
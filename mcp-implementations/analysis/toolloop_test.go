@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// toolUseAnswer is a mock model answer calling the named tool.
+func toolUseAnswer(name string, input map[string]any) *mcp.CreateMessageResult {
+	result := textAnswer("")
+	result.Meta = mcp.NewMetaFromMap(map[string]any{
+		"tool_use": map[string]any{"id": "t1", "name": name, "input": input},
+	})
+	return result
+}
+
+func TestAnalyzeFileRunsToolCallsAndFeedsBackResults(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "See the other files.", "other.txt": "more"})
+	first := true
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if first {
+			first = false
+			return toolUseAnswer("list_files", map[string]any{}), nil
+		}
+		return textAnswer("The directory holds notes.txt and other.txt."), nil
+	}}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "tools": []any{"list_files"}, "use_cache": false})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want the tool call and a follow-up", len(requests))
+	}
+	metadata, _ := requests[0].Metadata.(map[string]any)
+	declarations, _ := metadata["tools"].([]map[string]any)
+	if len(declarations) != 1 || declarations[0]["name"] != "list_files" || declarations[0]["input_schema"] == nil {
+		t.Errorf("request does not declare list_files: %v", metadata["tools"])
+	}
+	followUp := messageText(requests[1])
+	if !strings.Contains(followUp, "Calling tool list_files") || !strings.Contains(followUp, "Result of tool list_files:") {
+		t.Errorf("follow-up does not carry the tool call and its result:\n%s", followUp)
+	}
+	if !strings.Contains(followUp, "other.txt") {
+		t.Errorf("tool result is missing the listing:\n%s", followUp)
+	}
+	if !strings.Contains(text, "The directory holds notes.txt and other.txt.") {
+		t.Errorf("result is missing the final answer:\n%s", text)
+	}
+}
+
+func TestAnalyzeFileBoundsToolCalls(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Loop forever."})
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return toolUseAnswer("list_files", nil), nil
+	}}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "tools": []any{"list_files"}, "use_cache": false})
+	if !strings.Contains(text, "still calling tools after 5 calls") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != MaxToolTurns+1 {
+		t.Errorf("got %d sampling requests, want %d", n, MaxToolTurns+1)
+	}
+}
+
+func TestAnalyzeFileRefusesUndeclaredToolCall(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Hello."})
+	first := true
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if first {
+			first = false
+			return toolUseAnswer("read_file", map[string]any{"filename": "notes.txt"}), nil
+		}
+		return textAnswer("done"), nil
+	}}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "tools": []any{"list_files"}, "use_cache": false})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 || !strings.Contains(messageText(requests[1]), "Error: tool read_file is not available") {
+		t.Errorf("undeclared tool was not refused: %d requests", len(requests))
+	}
+}
+
+func TestAnalyzeFileRejectsUnknownAndDeniedTools(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Hello."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	for tool, want := range map[string]string{
+		"no_such_tool":  `Unknown tool "no_such_tool"`,
+		"analyze_file":  `Tool "analyze_file" cannot be offered to the model`,
+		"report":        `Tool "report" cannot be offered to the model`,
+		"similarity":    `Tool "similarity" cannot be offered to the model`,
+		"classify_file": `Tool "classify_file" cannot be offered to the model`,
+	} {
+		text := mustFail(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "tools": []any{tool}})
+		if !strings.Contains(text, want) {
+			t.Errorf("tools [%s]: got %q, want %q", tool, text, want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("rejected tools still sent %d sampling requests", n)
+	}
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// truncationStrategies are the accepted truncation argument values: which
+// part of text too long for one request is kept.
+var truncationStrategies = []string{"head", "tail", "middle", "headtail"}
+
+// truncationProperty documents the truncation argument on tools that cut
+// long text to fit one request.
+var truncationProperty = map[string]any{
+	"type":        "string",
+	"description": "Which part of text too long for one request is kept: head (default; documents), tail (logs), middle, or headtail (the start and the end)",
+	"enum":        truncationStrategies,
+}
+
+type truncationKey struct{}
+
+// withTruncation is tool middleware that moves a truncation argument into
+// the context, where truncateFor reads it.
+func withTruncation(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		strategy := request.GetString("truncation", "")
+		if strategy == "" {
+			return next(ctx, request)
+		}
+		if !slices.Contains(truncationStrategies, strategy) {
+			return errorResult("Unknown truncation %q (use %s)", strategy, strings.Join(truncationStrategies, ", ")), nil
+		}
+		return next(context.WithValue(ctx, truncationKey{}, strategy), request)
+	}
+}
+
+// truncateFor cuts text to at most limit bytes with the tool call's
+// truncation strategy, reporting whether anything was cut.
+func truncateFor(ctx context.Context, text string, limit int) (string, bool) {
+	strategy, _ := ctx.Value(truncationKey{}).(string)
+	return truncateText(text, limit, strategy)
+}
+
+// truncateText keeps the part of text the strategy names within limit
+// bytes, marking each removed region with how many bytes it held. Cuts
+// fall on line breaks where one is near, as splitChunks does. An empty
+// strategy means head.
+func truncateText(text string, limit int, strategy string) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+	// Room for the markers, which name byte counts of at most len(text)
+	budget := max(0, limit-2*len(omittedMarker(len(text))))
+
+	switch strategy {
+	case "tail":
+		tail := tailOf(text, budget)
+		return omittedMarker(len(text)-len(tail)) + tail, true
+	case "middle":
+		start := headOf(text, (len(text)-budget)/2)
+		middle := headOf(text[len(start):], budget)
+		return omittedMarker(len(start)) + middle + omittedMarker(len(text)-len(start)-len(middle)), true
+	case "headtail":
+		head := headOf(text, budget/2)
+		tail := tailOf(text[len(head):], budget-len(head))
+		return head + omittedMarker(len(text)-len(head)-len(tail)) + tail, true
+	default:
+		head := headOf(text, budget)
+		return head + omittedMarker(len(text)-len(head)), true
+	}
+}
+
+// omittedMarker stands in for n removed bytes.
+func omittedMarker(n int) string {
+	return fmt.Sprintf("\n[... %d bytes omitted ...]\n", n)
+}
+
+// headOf returns the longest start of text within size bytes, ending after
+// a line break if one falls in the second half.
+func headOf(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	if nl := strings.LastIndexByte(text[:size], '\n'); nl >= size/2 {
+		return text[:nl+1]
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	return text[:size]
+}
+
+// tailOf returns the longest end of text within size bytes, starting after
+// a line break if one falls in the first half.
+func tailOf(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	start := len(text) - size
+	if nl := strings.IndexByte(text[start:], '\n'); nl >= 0 && nl < size/2 {
+		return text[start+nl+1:]
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return text[start:]
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// numberedLines returns n lines of 20 bytes each, "line 01 of the file".
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %02d of the file\n", i)
+	}
+	return b.String()
+}
+
+func TestTruncateTextStrategies(t *testing.T) {
+	text := numberedLines(40)
+	tests := []struct {
+		strategy      string
+		kept, dropped []int
+		markers       int
+	}{
+		{"", []int{1, 10}, []int{20, 40}, 1},
+		{"head", []int{1, 10}, []int{20, 40}, 1},
+		{"tail", []int{31, 40}, []int{1, 20}, 1},
+		{"middle", []int{18, 23}, []int{1, 40}, 2},
+		{"headtail", []int{1, 5, 36, 40}, []int{20}, 1},
+	}
+	for _, tt := range tests {
+		got, truncated := truncateText(text, 300, tt.strategy)
+		if !truncated || len(got) > 300 {
+			t.Errorf("%q: %d bytes, truncated %t; want at most 300 and truncated", tt.strategy, len(got), truncated)
+		}
+		for _, n := range tt.kept {
+			if !strings.Contains(got, fmt.Sprintf("line %02d of", n)) {
+				t.Errorf("%q: line %d was not kept:\n%s", tt.strategy, n, got)
+			}
+		}
+		for _, n := range tt.dropped {
+			if strings.Contains(got, fmt.Sprintf("line %02d of", n)) {
+				t.Errorf("%q: line %d was kept:\n%s", tt.strategy, n, got)
+			}
+		}
+		if n := strings.Count(got, " bytes omitted ...]"); n != tt.markers {
+			t.Errorf("%q: %d omitted markers, want %d:\n%s", tt.strategy, n, tt.markers, got)
+		}
+		// Cuts fall on line breaks, so every kept line is whole
+		for line := range strings.Lines(got) {
+			if line != "\n" && !strings.HasPrefix(line, "[... ") && !strings.HasPrefix(line, "line ") {
+				t.Errorf("%q: a line was cut: %q", tt.strategy, line)
+			}
+		}
+	}
+}
+
+func TestTruncateTextMarkerCountsOmittedBytes(t *testing.T) {
+	text := numberedLines(40)
+	got, _ := truncateText(text, 300, "tail")
+	marker, kept, _ := strings.Cut(got, "]\n")
+	if want := fmt.Sprintf("\n[... %d bytes omitted ...", len(text)-len(kept)); marker != want {
+		t.Errorf("marker = %q, want %q", marker, want)
+	}
+	if !strings.HasSuffix(text, kept) {
+		t.Errorf("tail is not the end of the text: %q", kept)
+	}
+}
+
+func TestTruncateTextKeepsCharactersWhole(t *testing.T) {
+	text := strings.Repeat("é日本", 200)
+	for _, strategy := range truncationStrategies {
+		got, _ := truncateText(text, 301, strategy)
+		if !utf8.ValidString(got) {
+			t.Errorf("%q split a multi-byte character", strategy)
+		}
+	}
+}
+
+func TestTruncateTextLeavesShortText(t *testing.T) {
+	text := numberedLines(3)
+	if got, truncated := truncateText(text, len(text), "tail"); got != text || truncated {
+		t.Errorf("text within the limit was changed: %q, %t", got, truncated)
+	}
+}
+
+func TestTruncationArgumentReachesPrompt(t *testing.T) {
+	s := newTestServer(t, Config{ChunkSize: 300}, map[string]string{"app.log": numberedLines(40)})
+	sampler := &mockSampler{respond: answers(`{"category": "log", "confidence": 1}`)}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "classify_file", map[string]any{"filename": "app.log", "categories": []string{"log", "prose"}, "truncation": "tail"})
+
+	sent := messageText(sampler.Requests()[0])
+	if !strings.Contains(sent, "line 40 of the file") || strings.Contains(sent, "line 01 of the file") {
+		t.Errorf("truncation tail did not keep the end of the file:\n%s", sent)
+	}
+}
+
+func TestUnknownTruncationIsRejected(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"app.log": numberedLines(3)})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "classify_file", map[string]any{"filename": "app.log", "categories": []string{"log"}, "truncation": "end"})
+	if !strings.Contains(text, `Unknown truncation "end" (use head, tail, middle, headtail)`) {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a rejected call sent %d sampling requests", n)
+	}
+}
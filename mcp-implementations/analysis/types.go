@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AnalysisType describes one accepted value of the analysis_type argument.
+// analysisTypes is the single source of truth: prompts, tool schemas, cost
+// estimates and list_analysis_types are all derived from it.
+type AnalysisType struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Temperature float64 `json:"default_temperature"`
+	// Structured reports whether the answer is parsed into a structure
+	// rather than returned as free text.
+	Structured bool `json:"structured_output"`
+
+	// Prompt is the instruction sent as the start of the system prompt.
+	Prompt string `json:"-"`
+	// ExpectedOutputTokens is a rough answer length, used for estimates.
+	ExpectedOutputTokens int `json:"-"`
+}
+
+// DefaultAnalysisType is the analysis_type used when a call omits it and
+// Config sets no other default.
+const DefaultAnalysisType = "summarize"
+
+var analysisTypes = []AnalysisType{
+	{
+		Name:                 "summarize",
+		Description:          "A clear and concise summary of the content",
+		Temperature:          0.3,
+		Prompt:               "Please provide a clear and concise summary of this content.",
+		ExpectedOutputTokens: 400,
+	},
+	{
+		Name:                 "explain",
+		Description:          "What the content is about and its main purpose",
+		Temperature:          0.3,
+		Prompt:               "Please explain what this content is about and its main purpose.",
+		ExpectedOutputTokens: 600,
+	},
+	{
+		Name:                 "analyze",
+		Description:          "A detailed analysis of structure, key components and notable patterns",
+		Temperature:          0.3,
+		Prompt:               "Please provide a detailed analysis of this content, including its structure, key components, and any notable patterns.",
+		ExpectedOutputTokens: 1200,
+	},
+	{
+		Name:                 "extract_key_points",
+		Description:          "The key points and main ideas of the content",
+		Temperature:          0.3,
+		Prompt:               "Please extract the key points and main ideas from this content.",
+		ExpectedOutputTokens: 500,
+	},
+}
+
+// lookupAnalysisType finds an analysis type by name.
+func lookupAnalysisType(name string) (AnalysisType, bool) {
+	for _, t := range analysisTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return AnalysisType{}, false
+}
+
+// AnalysisTypeNames lists the valid analysis_type values, for tool schemas
+// and validating -default-analysis.
+func AnalysisTypeNames() []string {
+	names := make([]string, len(analysisTypes))
+	for i, t := range analysisTypes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+var listAnalysisTypesTool = mcp.Tool{
+	Name:        "list_analysis_types",
+	Description: "List the analysis types accepted by the analysis tools, with their defaults",
+	InputSchema: mcp.ToolInputSchema{
+		Type:       "object",
+		Properties: map[string]any{},
+	},
+}
+
+func handleListAnalysisTypes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(analysisTypes, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding analysis types: %v", err), nil
+	}
+
+	return textResult(string(data)), nil
+}
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestListAnalysisTypesMatchesImplementedTypes(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "list_analysis_types", nil)
+	var listed []AnalysisType
+	if err := json.Unmarshal([]byte(text), &listed); err != nil {
+		t.Fatalf("list_analysis_types did not return JSON: %v\n%s", err, text)
+	}
+	var names []string
+	for _, listedType := range listed {
+		names = append(names, listedType.Name)
+	}
+	if fmt.Sprint(names) != fmt.Sprint(AnalysisTypeNames()) {
+		t.Fatalf("listed types %v, want %v", names, AnalysisTypeNames())
+	}
+
+	// Every listed type is accepted by analyze_file with its prompt and
+	// default temperature
+	for i, listedType := range listed {
+		mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "analysis_type": listedType.Name})
+		request := sampler.Requests()[i]
+		implemented, _ := lookupAnalysisType(listedType.Name)
+		if !strings.HasPrefix(request.SystemPrompt, implemented.Prompt) {
+			t.Errorf("%s: system prompt %q does not start with the type's prompt", listedType.Name, request.SystemPrompt)
+		}
+		if request.Temperature != listedType.Temperature {
+			t.Errorf("%s: temperature %v, want the listed default %v", listedType.Name, request.Temperature, listedType.Temperature)
+		}
+	}
+}
+
+func TestAnalyzeFileSchemaListsAnalysisTypes(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	c := connect(t, s, &mockSampler{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools.Tools {
+		if tool.Name != "analyze_file" {
+			continue
+		}
+		property, _ := tool.InputSchema.Properties["analysis_type"].(map[string]any)
+		if fmt.Sprint(property["enum"]) != fmt.Sprint(AnalysisTypeNames()) {
+			t.Errorf("analysis_type enum %v, want %v", property["enum"], AnalysisTypeNames())
+		}
+		return
+	}
+	t.Fatal("analyze_file is not listed")
+}
+
+func TestConfiguredDefaultAnalysisApplies(t *testing.T) {
+	s := newTestServer(t, Config{DefaultAnalysis: "explain"}, map[string]string{"notes.txt": "Some notes."})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"})
+	if !strings.Contains(text, "Analysis: explain\n") {
+		t.Errorf("result does not use the configured default:\n%s", text)
+	}
+	if prompt := sampler.Requests()[0].SystemPrompt; !strings.Contains(prompt, "explain what this content is about") {
+		t.Errorf("system prompt is not the explain prompt: %q", prompt)
+	}
+
+	// An explicit analysis_type still wins
+	_, text = mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt", "analysis_type": "summarize"})
+	if !strings.Contains(text, "Analysis: summarize\n") {
+		t.Errorf("analysis_type did not override the default:\n%s", text)
+	}
+}
+
+func TestDefaultAnalysisIsSummarize(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "Some notes."})
+	c := connect(t, s, &mockSampler{})
+
+	if _, text := mustSucceed(t, c, "analyze_file", map[string]any{"filename": "notes.txt"}); !strings.Contains(text, "Analysis: "+DefaultAnalysisType+"\n") {
+		t.Errorf("result does not use %s:\n%s", DefaultAnalysisType, text)
+	}
+}
@@ -0,0 +1,270 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// validateFormats maps the accepted format names to file extensions.
+var validateFormats = map[string][]string{
+	"json": {".json"},
+	"yaml": {".yaml", ".yml"},
+	"xml":  {".xml"},
+}
+
+// fixContextLines is how many lines on each side of a syntax error are sent
+// to the model when asking for a fix.
+const fixContextLines = 20
+
+var validateFileTool = mcp.Tool{
+	Name:        "validate_file",
+	Description: "Check a JSON, YAML or XML file for syntax errors locally, then use LLM sampling to explain how to fix them or to review the structure of a valid file",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The file to validate (relative to files directory)",
+			},
+			"format": map[string]any{
+				"type":        "string",
+				"description": "The file format (default: from the extension)",
+				"enum":        []string{"json", "yaml", "xml"},
+			},
+			"suggest_fix": map[string]any{
+				"type":        "boolean",
+				"description": "Ask the model how to fix a syntax error (default true)",
+			},
+			"critique": map[string]any{
+				"type":        "boolean",
+				"description": "Ask the model to review the structure of a valid file (default false)",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+			"truncation":      truncationProperty,
+		},
+		Required: []string{"filename"},
+	},
+}
+
+// syntaxError is a parse failure at a 1-based position. Column is zero
+// when the parser does not report one.
+type syntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *syntaxError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+func (s *Server) handleValidateFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	format := request.GetString("format", "")
+	suggestFix := request.GetBool("suggest_fix", true)
+	critique := request.GetBool("critique", false)
+
+	if format == "" {
+		format = formatForExt(filepath.Ext(filename))
+		if format == "" {
+			return errorResult("Cannot tell the format of %s from its extension; pass format (json, yaml or xml)", filename), nil
+		}
+	} else if _, ok := validateFormats[format]; !ok {
+		return errorResult("Unknown format %q (must be json, yaml or xml)", format), nil
+	}
+
+	text, err := s.readTextFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	parseErr := checkSyntax(format, text)
+	report := fmt.Sprintf("Validation Results\n"+
+		"==================\n"+
+		"File: %s\n"+
+		"Format: %s\n", filename, strings.ToUpper(format))
+	if parseErr == nil {
+		logf(ctx, "✅ %s is valid %s", filename, format)
+		report += "Valid: yes"
+	} else {
+		log.Printf("❌ %s is not valid %s: %v", filename, format, parseErr)
+		report += fmt.Sprintf("Valid: no\nError: %v", parseErr)
+	}
+
+	var samplingRequest mcp.CreateMessageRequest
+	var heading string
+	switch {
+	case parseErr != nil && suggestFix:
+		content := mcp.TextContent{Type: "text", Text: errorExcerpt(text, parseErr.Line)}
+		samplingRequest = newSamplingRequest(content, fmt.Sprintf("This excerpt of the %s file '%s' has a syntax error reported by the parser as: %v. "+
+			"Lines are numbered. Explain the cause in one or two sentences and show the corrected lines. Change nothing else.",
+			strings.ToUpper(format), filename, parseErr))
+		heading = "Suggested fix"
+	case parseErr == nil && critique:
+		excerpt, _ := truncateFor(ctx, text, s.cfg.ChunkSize)
+		content := mcp.TextContent{Type: "text", Text: excerpt}
+		samplingRequest = newSamplingRequest(content, fmt.Sprintf("This %s file '%s' is syntactically valid. Review its structure: "+
+			"naming consistency, nesting, repeated or conflicting keys, values of inconsistent types and anything a schema would likely reject. "+
+			"List concrete problems, most important first, or say that you found none.", strings.ToUpper(format), filename))
+		heading = "Structure review"
+	default:
+		return textResult(report), nil
+	}
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 1000
+
+	logf(ctx, "📤 Sending sampling request for %s of: %s", strings.ToLower(heading), filename)
+	result, err := s.requestSampling(ctx, samplingRequest)
+	if err != nil {
+		log.Printf("❌ Sampling request failed: %v", err)
+		return errorResult("Error requesting sampling: %v", err), nil
+	}
+
+	return textResult(fmt.Sprintf("%s\n\n%s (%s):\n%s", report, heading, result.Model, resultText(result))), nil
+}
+
+// formatForExt returns the format whose extensions include ext, or "".
+func formatForExt(ext string) string {
+	ext = strings.ToLower(ext)
+	for format, exts := range validateFormats {
+		for _, e := range exts {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// checkSyntax parses text as format and returns the first syntax error,
+// or nil if the text is well-formed.
+func checkSyntax(format, text string) *syntaxError {
+	switch format {
+	case "json":
+		return checkJSON(text)
+	case "yaml":
+		return checkYAML(text)
+	default:
+		return checkXML(text)
+	}
+}
+
+func checkJSON(text string) *syntaxError {
+	var v any
+	err := json.Unmarshal([]byte(text), &v)
+	if err == nil {
+		return nil
+	}
+	var serr *json.SyntaxError
+	if errors.As(err, &serr) {
+		// Offset is just past the offending byte
+		line, col := lineColumn(text, int(serr.Offset)-1)
+		return &syntaxError{Line: line, Column: col, Message: serr.Error()}
+	}
+	// Only truncated input fails without a SyntaxError
+	line, col := lineColumn(text, len(text))
+	return &syntaxError{Line: line, Column: col, Message: err.Error()}
+}
+
+// yamlErrorLine matches the position yaml.v3 puts in its error messages.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// checkYAML parses every document in a YAML stream. yaml.v3 reports a line
+// but no column.
+func checkYAML(text string) *syntaxError {
+	decoder := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var v any
+		err := decoder.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				return &syntaxError{Line: line, Message: m[2]}
+			}
+			return &syntaxError{Line: 1, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		}
+	}
+}
+
+// checkXML reads every token of an XML document and requires exactly one
+// root element.
+func checkXML(text string) *syntaxError {
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	roots, depth := 0, 0
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, col := decoder.InputPos()
+			var serr *xml.SyntaxError
+			if errors.As(err, &serr) {
+				return &syntaxError{Line: serr.Line, Column: col, Message: serr.Msg}
+			}
+			return &syntaxError{Line: line, Column: col, Message: err.Error()}
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+				if roots > 1 {
+					line, col := decoder.InputPos()
+					return &syntaxError{Line: line, Column: col, Message: "more than one root element"}
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots == 0 {
+		return &syntaxError{Line: 1, Message: "no root element"}
+	}
+	return nil
+}
+
+// lineColumn converts a byte offset in text to a 1-based line and column.
+func lineColumn(text string, offset int) (int, int) {
+	offset = max(0, min(offset, len(text)))
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	return line, offset - strings.LastIndexByte(before, '\n')
+}
+
+// errorExcerpt returns the numbered lines around line, so the model sees
+// the error in context without the whole file.
+func errorExcerpt(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	first := max(1, line-fixContextLines)
+	last := min(len(lines), line+fixContextLines)
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		fmt.Fprintf(&b, "%4d | %s\n", n, lines[n-1])
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	validJSONFixture     = "{\n  \"name\": \"demo\",\n  \"tags\": [\"a\", \"b\"]\n}\n"
+	malformedJSONFixture = "{\n  \"name\": \"demo\",\n  \"tags\": [\"a\", \"b\"],\n}\n"
+)
+
+func TestValidateFileAcceptsValidJSON(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"config.json": validJSONFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "validate_file", map[string]any{"filename": "config.json"})
+
+	if !strings.Contains(text, "Format: JSON\nValid: yes") {
+		t.Errorf("valid file not reported as valid:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("a valid file without critique sent %d sampling requests", n)
+	}
+}
+
+func TestValidateFileCritiquesValidJSON(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"config.json": validJSONFixture})
+	sampler := &mockSampler{respond: answers("No problems found.")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "validate_file", map[string]any{"filename": "config.json", "critique": true})
+
+	if !strings.Contains(text, "Valid: yes\n\nStructure review (mock-model):\nNo problems found.") {
+		t.Errorf("result is missing the structure review:\n%s", text)
+	}
+}
+
+func TestValidateFileReportsMalformedJSONPosition(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"config.json": malformedJSONFixture})
+	sampler := &mockSampler{respond: answers("Remove the trailing comma on line 3.")}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "validate_file", map[string]any{"filename": "config.json"})
+
+	for _, want := range []string{
+		"Valid: no\nError: line 4, column 1: invalid character '}' looking for beginning of object key string",
+		"Suggested fix (mock-model):\nRemove the trailing comma on line 3.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	requests := sampler.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d sampling requests, want 1", len(requests))
+	}
+	if !strings.Contains(requests[0].SystemPrompt, "line 4, column 1") || !strings.Contains(messageText(requests[0]), "   3 |   \"tags\": [\"a\", \"b\"],") {
+		t.Errorf("fix prompt does not carry the error and numbered lines:\n%s\n%s", requests[0].SystemPrompt, messageText(requests[0]))
+	}
+}
+
+func TestValidateFileWithoutFixSuggestion(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"config.json": malformedJSONFixture})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "validate_file", map[string]any{"filename": "config.json", "suggest_fix": false})
+
+	if !strings.Contains(text, "Valid: no") || strings.Contains(text, "Suggested fix") {
+		t.Errorf("unexpected result:\n%s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("suggest_fix=false still sent %d sampling requests", n)
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	for _, tc := range []struct {
+		format, text string
+		wantLine     int
+	}{
+		{"json", `{"a": 1}`, 0},
+		{"json", `{"a": 1`, 1},
+		{"yaml", "a: 1\nb: [2, 3]\n", 0},
+		{"yaml", "a: 1\n\tb: 2\n", 2},
+		{"xml", "<root><a/></root>", 0},
+		{"xml", "<root>\n<a>\n</root>", 3},
+		{"xml", "<a/><b/>", 1},
+	} {
+		err := checkSyntax(tc.format, tc.text)
+		switch {
+		case tc.wantLine == 0 && err != nil:
+			t.Errorf("%s %q: unexpected error %v", tc.format, tc.text, err)
+		case tc.wantLine != 0 && err == nil:
+			t.Errorf("%s %q: no error reported", tc.format, tc.text)
+		case tc.wantLine != 0 && err.Line != tc.wantLine:
+			t.Errorf("%s %q: error on line %d, want %d (%v)", tc.format, tc.text, err.Line, tc.wantLine, err)
+		}
+	}
+}
+
+func TestValidateFileNeedsKnownFormat(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"notes.txt": "{}"})
+	c := connect(t, s, &mockSampler{})
+
+	if text := mustFail(t, c, "validate_file", map[string]any{"filename": "notes.txt"}); !strings.Contains(text, "Cannot tell the format of notes.txt") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	mustSucceed(t, c, "validate_file", map[string]any{"filename": "notes.txt", "format": "json"})
+}
@@ -0,0 +1,140 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var verifyImageTool = mcp.Tool{
+	Name:        "verify_image",
+	Description: "Check whether an image meets a description such as \"contains a cat\" or \"shows a signed form\" using LLM sampling. Returns JSON with a true/false match, a confidence and an explanation, for automated content checks",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "The image to check (relative to files directory)",
+			},
+			"criteria": map[string]any{
+				"type":        "string",
+				"description": "What the image is expected to show, e.g. \"contains a cat\"",
+			},
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+		Required: []string{"filename", "criteria"},
+	},
+}
+
+// ImageCheck is the structured result of verify_image.
+type ImageCheck struct {
+	File        string  `json:"file"`
+	Criteria    string  `json:"criteria"`
+	Model       string  `json:"model"`
+	Matches     bool    `json:"matches"`
+	Confidence  float64 `json:"confidence"`
+	Explanation string  `json:"explanation"`
+}
+
+func (s *Server) handleVerifyImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename, err := request.RequireString("filename")
+	if err != nil {
+		return nil, err
+	}
+	criteria, err := request.RequireString("criteria")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(criteria) == "" {
+		return errorResult("criteria is empty; describe what the image should show"), nil
+	}
+
+	filePath, err := s.resolveFile(filename)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	mimeType := mimeTypeFor(filename)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return errorResult("%s is not an image (%s)", filename, mimeType), nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return errorResult("Error reading file: %v", err), nil
+	}
+	data, mimeType, err = s.fitImageFile(ctx, filename, data, mimeType, 0)
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+
+	// Images never reach the binary policy, so there is no error
+	content, _, _ := buildContent(filename, mimeType, data, "", s.cfg.BinaryPolicy)
+	systemPrompt := fmt.Sprintf("Decide whether this image meets the following criteria: %s. "+
+		"Judge only what is visible in the image; if it is unclear, answer false with a low confidence rather than guess. "+
+		`Respond with only a JSON object: {"matches": true or false, "confidence": <number from 0 to 1>, "explanation": "<one or two sentences on what in the image decided it>"}.`, criteria)
+
+	check := ImageCheck{File: filename, Criteria: criteria}
+	samplingRequest := newSamplingRequest(content, systemPrompt)
+	samplingRequest.Temperature = 0
+	samplingRequest.MaxTokens = 300
+
+	logf(ctx, "📤 Sending sampling request to verify %s against %q", filename, criteria)
+	result, err := s.sampleJSON(ctx, samplingRequest, "a valid image check", func(text string) (err error) {
+		check.Matches, check.Confidence, check.Explanation, err = parseImageCheck(text)
+		return err
+	})
+	if err != nil {
+		return errorResult("%v", err), nil
+	}
+	check.Model = result.Model
+
+	if check.Matches {
+		logf(ctx, "✅ %s matches %q (confidence %.2f)", filename, criteria, check.Confidence)
+	} else {
+		logf(ctx, "✅ %s does not match %q (confidence %.2f)", filename, criteria, check.Confidence)
+	}
+
+	data, err = json.MarshalIndent(check, "", "  ")
+	if err != nil {
+		return errorResult("Error encoding image check: %v", err), nil
+	}
+	return textResult(string(data)), nil
+}
+
+// parseImageCheck decodes the model's verdict. matches must be a JSON
+// boolean: "yes", "true" in quotes or 1 are rejected rather than guessed
+// at, since the answer drives automated checks.
+func parseImageCheck(text string) (bool, float64, string, error) {
+	var answer struct {
+		Matches     json.RawMessage `json:"matches"`
+		Confidence  *float64        `json:"confidence"`
+		Explanation string          `json:"explanation"`
+	}
+	if err := json.Unmarshal([]byte(jsonObject(text)), &answer); err != nil {
+		return false, 0, "", fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	var matches bool
+	switch string(answer.Matches) {
+	case "true":
+		matches = true
+	case "false":
+	case "":
+		return false, 0, "", fmt.Errorf("matches is missing")
+	default:
+		return false, 0, "", fmt.Errorf("matches must be true or false, not %s", answer.Matches)
+	}
+	if answer.Confidence == nil || *answer.Confidence < 0 || *answer.Confidence > 1 {
+		return false, 0, "", fmt.Errorf("confidence must be a number from 0 to 1")
+	}
+	if strings.TrimSpace(answer.Explanation) == "" {
+		return false, 0, "", fmt.Errorf("explanation is empty")
+	}
+	return matches, *answer.Confidence, strings.TrimSpace(answer.Explanation), nil
+}
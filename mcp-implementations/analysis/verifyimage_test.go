@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// imageCheckOf decodes a verify_image result.
+func imageCheckOf(t *testing.T, text string) ImageCheck {
+	t.Helper()
+	var check ImageCheck
+	if err := json.Unmarshal([]byte(text), &check); err != nil {
+		t.Fatalf("result is not an image check: %v\n%s", err, text)
+	}
+	return check
+}
+
+func TestVerifyImageMatch(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"pet.png": pngFixture(t, 20, 20, false)})
+	sampler := &mockSampler{respond: answers(`{"matches": true, "confidence": 0.92, "explanation": "A cat sits in the center."}`)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "verify_image", map[string]any{"filename": "pet.png", "criteria": "contains a cat"})
+
+	want := ImageCheck{File: "pet.png", Criteria: "contains a cat", Model: "mock-model", Matches: true, Confidence: 0.92, Explanation: "A cat sits in the center."}
+	if got := imageCheckOf(t, text); got != want {
+		t.Errorf("check = %+v, want %+v", got, want)
+	}
+	request := sampler.Requests()[0]
+	if _, ok := request.Messages[0].Content.(mcp.ImageContent); !ok {
+		t.Errorf("the image was not sent: %T", request.Messages[0].Content)
+	}
+	if !strings.Contains(request.SystemPrompt, "meets the following criteria: contains a cat.") {
+		t.Errorf("system prompt does not carry the criteria: %q", request.SystemPrompt)
+	}
+}
+
+func TestVerifyImageNoMatch(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"pet.png": pngFixture(t, 20, 20, false)})
+	c := connect(t, s, &mockSampler{respond: answers(`{"matches": false, "confidence": 0.8, "explanation": "Only a dog is visible."}`)})
+
+	_, text := mustSucceed(t, c, "verify_image", map[string]any{"filename": "pet.png", "criteria": "contains a cat"})
+
+	// false must be in the JSON, not dropped as a zero value
+	if got := imageCheckOf(t, text); got.Matches || got.Confidence != 0.8 || !strings.Contains(text, `"matches": false`) {
+		t.Errorf("unexpected check:\n%s", text)
+	}
+}
+
+func TestVerifyImageRequiresStrictBoolean(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"pet.png": pngFixture(t, 20, 20, false)})
+	sampler := &mockSampler{respond: answers(
+		`{"matches": "yes", "confidence": 0.9, "explanation": "A cat."}`,
+		`{"matches": true, "confidence": 0.9, "explanation": "A cat."}`,
+	)}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "verify_image", map[string]any{"filename": "pet.png", "criteria": "contains a cat"})
+
+	requests := sampler.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d sampling requests, want a single reprompt", len(requests))
+	}
+	if !strings.Contains(requests[1].SystemPrompt, `matches must be true or false, not "yes"`) {
+		t.Errorf("reprompt does not give the problem: %q", requests[1].SystemPrompt)
+	}
+	if !imageCheckOf(t, text).Matches {
+		t.Errorf("the corrected answer was not used:\n%s", text)
+	}
+}
+
+func TestParseImageCheck(t *testing.T) {
+	for answer, want := range map[string]string{
+		`{"matches": 1, "confidence": 0.5, "explanation": "x"}`:      "matches must be true or false, not 1",
+		`{"matches": "true", "confidence": 0.5, "explanation": "x"}`: `matches must be true or false, not "true"`,
+		`{"confidence": 0.5, "explanation": "x"}`:                    "matches is missing",
+		`{"matches": true, "explanation": "x"}`:                      "confidence must be a number from 0 to 1",
+		`{"matches": true, "confidence": 0.5, "explanation": " "}`:   "explanation is empty",
+	} {
+		if _, _, _, err := parseImageCheck(answer); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseImageCheck(%s) = %v, want %q", answer, err, want)
+		}
+	}
+}
+
+func TestVerifyImageValidatesInput(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"pet.png": pngFixture(t, 20, 20, false), "notes.txt": "text"})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	if text := mustFail(t, c, "verify_image", map[string]any{"filename": "notes.txt", "criteria": "a cat"}); !strings.Contains(text, "notes.txt is not an image") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if text := mustFail(t, c, "verify_image", map[string]any{"filename": "pet.png", "criteria": " "}); !strings.Contains(text, "criteria is empty") {
+		t.Errorf("unexpected error: %s", text)
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid input sent %d sampling requests", n)
+	}
+}
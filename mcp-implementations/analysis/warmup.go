@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var warmupTool = mcp.Tool{
+	Name:        "warmup",
+	Description: "Send a tiny sampling request to prime the provider connection and check it works; reports model and latency",
+	InputSchema: mcp.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"api_key":         apiKeyProperty,
+			"priority":        priorityProperty,
+			"idempotency_key": idempotencyKeyProperty,
+			"debug":           debugProperty,
+		},
+	},
+}
+
+func (s *Server) handleWarmup(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	samplingRequest := newSamplingRequest(mcp.TextContent{Type: "text", Text: "Reply with OK."}, "Reply with exactly: OK")
+	samplingRequest.MaxTokens = 5
+	samplingRequest.Temperature = 0
+
+	logf(ctx, "📤 Sending warm-up sampling request")
+	start := time.Now()
+	result, err := s.requestSampling(ctx, samplingRequest)
+	latency := time.Since(start)
+	if err != nil {
+		log.Printf("❌ Warm-up failed after %v: %v", latency.Round(time.Millisecond), err)
+		return errorResult("Warm-up failed after %v: %v", latency.Round(time.Millisecond), err), nil
+	}
+
+	logf(ctx, "✅ Warm-up successful! Model: %s, latency: %v", result.Model, latency.Round(time.Millisecond))
+
+	return textResult(fmt.Sprintf("Warm-up Results\n"+
+		"===============\n"+
+		"Status: ok\n"+
+		"Model: %s\n"+
+		"Latency: %v\n"+
+		"Reply: %s", result.Model, latency.Round(time.Millisecond), strings.TrimSpace(resultText(result)))), nil
+}
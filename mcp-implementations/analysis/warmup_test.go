@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestWarmupSendsMinimalRequest(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		time.Sleep(20 * time.Millisecond)
+		return textAnswer(" OK \n"), nil
+	}}
+	c := connect(t, s, sampler)
+
+	_, text := mustSucceed(t, c, "warmup", nil)
+
+	requests := sampler.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d sampling requests, want 1", len(requests))
+	}
+	if request := requests[0]; request.MaxTokens > 5 || request.Temperature != 0 || len(messageText(request)) > 20 {
+		t.Errorf("warm-up request is not minimal: max tokens %d, temperature %v, text %q", request.MaxTokens, request.Temperature, messageText(request))
+	}
+
+	for _, want := range []string{"Status: ok", "Model: mock-model", "Reply: OK"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result is missing %q:\n%s", want, text)
+		}
+	}
+	match := regexp.MustCompile(`Latency: (\S+)`).FindStringSubmatch(text)
+	if match == nil {
+		t.Fatalf("result does not report latency:\n%s", text)
+	}
+	latency, err := time.ParseDuration(match[1])
+	if err != nil || latency < 20*time.Millisecond {
+		t.Errorf("reported latency %q, want at least the handler's 20ms", match[1])
+	}
+}
+
+func TestWarmupReportsFailure(t *testing.T) {
+	s := newTestServer(t, Config{}, nil)
+	sampler := &mockSampler{respond: func(mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		return nil, errors.New("invalid API key")
+	}}
+	c := connect(t, s, sampler)
+
+	text := mustFail(t, c, "warmup", nil)
+	if !strings.Contains(text, "Warm-up failed after") || !strings.Contains(text, "invalid API key") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
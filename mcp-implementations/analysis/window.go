@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// MaxWindowBytes caps byte_length, so a window never loads more than this
+// much of a file into memory.
+const MaxWindowBytes = 16 << 20
+
+// byteWindow is the part of a file selected by byte_offset and byte_length.
+type byteWindow struct {
+	Offset int64
+	Length int64
+}
+
+// readWindow reads only the window from the file with ReadAt, so the size
+// of the rest of the file does not matter. A window running past the end is
+// shortened; one starting past the end is an error.
+func readWindow(filePath string, w byteWindow) ([]byte, int64, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error reading file: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error reading file: %v", err)
+	}
+	size := info.Size()
+
+	switch {
+	case w.Offset < 0:
+		return nil, size, fmt.Errorf("byte_offset must not be negative")
+	case w.Length <= 0:
+		return nil, size, fmt.Errorf("byte_length must be positive")
+	case w.Length > MaxWindowBytes:
+		return nil, size, fmt.Errorf("byte_length %d exceeds the %d byte window limit", w.Length, MaxWindowBytes)
+	case w.Offset >= size:
+		return nil, size, fmt.Errorf("byte_offset %d is beyond the end of the file (%d bytes)", w.Offset, size)
+	}
+
+	buf := make([]byte, min(w.Length, size-w.Offset))
+	n, err := f.ReadAt(buf, w.Offset)
+	if err != nil && err != io.EOF {
+		return nil, size, fmt.Errorf("Error reading file: %v", err)
+	}
+	return buf[:n], size, nil
+}
+
+// trimPartialRunes drops the bytes of UTF-8 sequences cut off at either end
+// of a window, which would otherwise make the text look like Latin-1. It
+// also returns how many leading bytes were dropped.
+func trimPartialRunes(data []byte) ([]byte, int) {
+	skipped := 0
+	for skipped < utf8.UTFMax && len(data) > 0 && !utf8.RuneStart(data[0]) {
+		data = data[1:]
+		skipped++
+	}
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				data = data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data, skipped
+}
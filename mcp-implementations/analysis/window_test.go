@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logFixture is 100 numbered lines of 10 bytes each.
+func logFixture() string {
+	var b strings.Builder
+	for i := range 100 {
+		fmt.Fprintf(&b, "line %04d\n", i)
+	}
+	return b.String()
+}
+
+func TestAnalyzeFileReadsMiddleWindow(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"app.log": logFixture()})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	mustSucceed(t, c, "analyze_file", map[string]any{"filename": "app.log", "byte_offset": 500, "byte_length": 100})
+
+	request := sampler.Requests()[0]
+	text := messageText(request)
+	if !strings.HasPrefix(text, "line 0050\n") || !strings.HasSuffix(text, "line 0059\n") {
+		t.Errorf("window text %q, want lines 50 to 59", text)
+	}
+	if strings.Contains(text, "line 0049") || strings.Contains(text, "line 0060") {
+		t.Errorf("window text reaches outside the window: %q", text)
+	}
+	if !strings.Contains(request.SystemPrompt, "bytes 500-600 of a 1000-byte file") {
+		t.Errorf("system prompt does not describe the window: %q", request.SystemPrompt)
+	}
+}
+
+func TestAnalyzeFileWindowValidation(t *testing.T) {
+	s := newTestServer(t, Config{}, map[string]string{"app.log": logFixture()})
+	sampler := &mockSampler{}
+	c := connect(t, s, sampler)
+
+	tests := []struct {
+		offset, length int
+		want           string
+	}{
+		{1000, 10, "beyond the end of the file (1000 bytes)"},
+		{-1, 10, "must not be negative"},
+		{0, -5, "byte_length must be positive"},
+		{0, MaxWindowBytes + 1, "exceeds the"},
+	}
+	for _, tt := range tests {
+		text := mustFail(t, c, "analyze_file", map[string]any{"filename": "app.log", "byte_offset": tt.offset, "byte_length": tt.length})
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("offset %d length %d: error %q, want %q", tt.offset, tt.length, text, tt.want)
+		}
+	}
+	if n := len(sampler.Requests()); n != 0 {
+		t.Errorf("invalid windows sent %d sampling requests", n)
+	}
+}
+
+func TestReadWindowShortensAtEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(logFixture()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, size, err := readWindow(path, byteWindow{Offset: 980, Length: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 1000 || string(data) != "line 0098\nline 0099\n" {
+		t.Errorf("readWindow = %q (size %d), want the last two lines", data, size)
+	}
+}
+
+func TestTrimPartialRunes(t *testing.T) {
+	// "é" is two bytes; cut through the first and last one
+	text := []byte("é middle é")
+	data, skipped := trimPartialRunes(text[1 : len(text)-1])
+	if string(data) != " middle " || skipped != 1 {
+		t.Errorf("trimPartialRunes = %q, %d; want \" middle \", 1", data, skipped)
+	}
+}
@@ -1,245 +1,215 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"math"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/hardwaylabs/learn-mcp-sampling/mcp-implementations/llm"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-// AnthropicSamplingHandler implements client.SamplingHandler using the Anthropic API
-type AnthropicSamplingHandler struct {
-	APIKey     string
-	HTTPClient *http.Client
-}
-
-// AnthropicRequest represents the structure for Anthropic API requests
-type AnthropicRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	Messages  []Message `json:"messages"`
-	System    string    `json:"system,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
-}
-
-type Message struct {
-	Role    string  `json:"role"`
-	Content Content `json:"content"`
-}
-
-type Content interface{}
-
-type TextContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
-}
-
-type ImageContent struct {
-	Type   string `json:"type"`
-	Source Source `json:"source"`
-}
-
-type Source struct {
-	Type      string `json:"type"`
-	MediaType string `json:"media_type"`
-	Data      string `json:"data"`
-}
-
-// AnthropicResponse represents the structure for Anthropic API responses
-type AnthropicResponse struct {
-	ID           string                   `json:"id"`
-	Type         string                   `json:"type"`
-	Role         string                   `json:"role"`
-	Content      []AnthropicTextContent   `json:"content"`
-	Model        string                   `json:"model"`
-	StopReason   string                   `json:"stop_reason"`
-	StopSequence string                   `json:"stop_sequence"`
-	Usage        AnthropicUsage           `json:"usage"`
-}
-
-type AnthropicTextContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
-}
+// headerFlags collects repeated -header "Name: value" flags.
+type headerFlags map[string]string
 
-type AnthropicUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+func (h headerFlags) String() string {
+	return fmt.Sprintf("%d headers", len(h))
 }
 
-func NewAnthropicSamplingHandler(apiKey string) *AnthropicSamplingHandler {
-	return &AnthropicSamplingHandler{
-		APIKey: apiKey,
-		HTTPClient: &http.Client{
-			Timeout: 2 * time.Minute,
-		},
+func (h headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", value)
 	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	return nil
 }
 
-func (h *AnthropicSamplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
-	log.Printf("📨 Received sampling request with %d messages", len(request.Messages))
-	
-	if len(request.Messages) == 0 {
-		return nil, fmt.Errorf("no messages provided")
-	}
-
-	// Convert MCP messages to Anthropic format
-	var messages []Message
-	for _, mcpMsg := range request.Messages {
-		var content Content
-
-		switch mcpContent := mcpMsg.Content.(type) {
-		case mcp.TextContent:
-			content = []TextContent{{
-				Type: "text",
-				Text: mcpContent.Text,
-			}}
-		case mcp.ImageContent:
-			// For image content, create image block
-			content = []interface{}{
-				ImageContent{
-					Type: "image",
-					Source: Source{
-						Type:      "base64",
-						MediaType: mcpContent.MIMEType,
-						Data:      mcpContent.Data,
-					},
-				},
-			}
-		default:
-			// Fallback to text
-			content = []TextContent{{
-				Type: "text",
-				Text: fmt.Sprintf("%v", mcpContent),
-			}}
-		}
-
-		role := "user"
-		if mcpMsg.Role == mcp.RoleAssistant {
-			role = "assistant"
-		}
-
-		messages = append(messages, Message{
-			Role:    role,
-			Content: content,
-		})
-	}
-
-	// Create Anthropic API request
-	anthropicReq := AnthropicRequest{
-		Model:       "claude-3-5-sonnet-20241022", // Use latest Sonnet model
-		MaxTokens:   request.MaxTokens,
-		Messages:    messages,
-		System:      request.SystemPrompt,
-		Temperature: request.Temperature,
-	}
-
-	// Marshal request to JSON
-	reqBody, err := json.Marshal(anthropicReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	log.Printf("Sending request to Anthropic API (model: %s, tokens: %d)", anthropicReq.Model, anthropicReq.MaxTokens)
-
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", h.APIKey)
-	httpReq.Header.Set("anthropic-version", "2023-06-01")
-
-	// Send request
-	resp, err := h.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+func main() {
+	rps := flag.Float64("rps", 0, "Maximum provider requests per second (0 = unlimited)")
+	burst := flag.Int("burst", 1, "Number of provider requests allowed in a burst when -rps is set")
+	provider := flag.String("provider", "anthropic", "LLM provider for sampling: anthropic, openai or gemini")
+	baseURL := flag.String("base-url", "", "Provider base URL, for a compatible proxy or gateway (default: the provider's public API)")
+	proxy := flag.String("proxy", "", "Proxy URL for provider calls, e.g. http://proxy.internal:3128, overriding HTTP_PROXY, HTTPS_PROXY and NO_PROXY")
+	model := flag.String("model", "smart", "Model alias or ID used when the server sends no model hint")
+	fallbackModel := flag.String("fallback-model", "", "Model alias or ID to retry with when the provider says the requested model does not exist (default: no fallback)")
+	modelAliases := flag.String("model-aliases", "", "JSON file of model aliases (alias -> model ID) added to the provider's built-in ones")
+	maxTokensFile := flag.String("max-tokens", "", "JSON file of per-model output token ceilings (model ID or prefix -> tokens) added to the built-in ones")
+	keepalive := flag.Duration("keepalive", 30*time.Second, "Interval between keepalive pings on the listening connection (0 disables)")
+	reconnectInitial := flag.Duration("reconnect-initial", time.Second, "Wait before the second attempt to reconnect after a keepalive ping finds the connection lost")
+	reconnectMax := flag.Duration("reconnect-max", 30*time.Second, "Longest wait between reconnect attempts")
+	reconnectMultiplier := flag.Float64("reconnect-multiplier", 2, "Factor the wait grows by after each failed reconnect attempt")
+	reconnectMaxAttempts := flag.Int("reconnect-max-attempts", 10, "Reconnect attempts before giving up and exiting (0 disables reconnecting)")
+	headers := headerFlags{}
+	flag.Var(headers, "header", "Extra header for every provider request, as \"Name: value\" (repeatable)")
+	maxResponseBytes := flag.Int64("max-response-bytes", llm.DefaultMaxResponseBytes, "Largest provider response body (bytes) the handler will read")
+	maxRetries := flag.Int("max-retries", llm.DefaultRetryPolicy.MaxRetries, "Retries of a provider request after a connection failure or a 429/5xx response")
+	retryBackoff := flag.Duration("retry-backoff", llm.DefaultRetryPolicy.Backoff, "Wait before the first retry, doubled for each one after")
+	retryTimeouts := flag.Bool("retry-timeouts", false, "Also retry provider requests that time out")
+	stream := flag.Bool("stream", false, "Stream provider responses, so text received before a request is canceled or cut off is returned as a partial answer")
+	promptCaching := flag.Bool("prompt-caching", false, "Mark the system prompt and large documents for Anthropic prompt caching")
+	systemPromptsFile := flag.String("system-prompts", "", "JSON file of per-provider system prompt prefixes (provider -> text) put before every tool's system prompt")
+	safetySettingsFile := flag.String("safety-settings", "", "JSON file of Gemini safety thresholds (harm category -> threshold), e.g. {\"harassment\": \"BLOCK_ONLY_HIGH\"} (default: the provider's defaults)")
+	flag.Parse()
+
+	schedule := backoff{Initial: *reconnectInitial, Max: *reconnectMax, Multiplier: *reconnectMultiplier, MaxAttempts: *reconnectMaxAttempts}
+	if err := schedule.validate(); err != nil {
+		log.Fatalf("Invalid reconnect flags: %v", err)
 	}
-
-	// Parse response
-	var anthropicResp AnthropicResponse
-	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
+	if err := llm.ValidateHeaders(headers); err != nil {
+		log.Fatalf("Invalid -header: %v", err)
 	}
 
-	// Extract text content
-	var responseText string
-	if len(anthropicResp.Content) > 0 {
-		responseText = anthropicResp.Content[0].Text
+	var limiter *llm.RateLimiter
+	if *rps > 0 {
+		limiter = llm.NewRateLimiter(*rps, *burst)
+		log.Printf("Rate limiting provider requests to %.2f/s (burst %d)", *rps, *burst)
 	}
 
-	log.Printf("Received response from Anthropic API (model: %s, input tokens: %d, output tokens: %d)", 
-		anthropicResp.Model, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
-
-	// Convert back to MCP format
-	result := &mcp.CreateMessageResult{
-		SamplingMessage: mcp.SamplingMessage{
-			Role: mcp.RoleAssistant,
-			Content: mcp.TextContent{
-				Type: "text",
-				Text: responseText,
-			},
-		},
-		Model:      anthropicResp.Model,
-		StopReason: anthropicResp.StopReason,
-	}
+	retry := llm.RetryPolicy{MaxRetries: *maxRetries, Backoff: *retryBackoff, RetryTimeouts: *retryTimeouts}
 
-	return result, nil
-}
-
-func main() {
-	// Get API key from environment variable
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		log.Fatal("ANTHROPIC_API_KEY environment variable is required")
+	ceilings := llm.DefaultTokenCeilings
+	if *maxTokensFile != "" {
+		var err error
+		ceilings, err = llm.LoadTokenCeilings(*maxTokensFile, llm.DefaultTokenCeilings)
+		if err != nil {
+			log.Fatalf("Invalid -max-tokens: %v", err)
+		}
 	}
 
-	// Create sampling handler with Anthropic API integration
-	samplingHandler := NewAnthropicSamplingHandler(apiKey)
-
-	// Create HTTP transport with continuous listening for sampling
-	httpTransport, err := transport.NewStreamableHTTP(
-		"http://localhost:8080/mcp",
-		transport.WithContinuousListening(),
-	)
-	if err != nil {
-		log.Fatalf("Failed to create HTTP transport: %v", err)
+	var systemPrompts llm.SystemPrompts
+	if *systemPromptsFile != "" {
+		var err error
+		systemPrompts, err = llm.LoadSystemPrompts(*systemPromptsFile)
+		if err != nil {
+			log.Fatalf("Invalid -system-prompts: %v", err)
+		}
 	}
-	defer httpTransport.Close()
 
-	// Create client with sampling support
-	mcpClient := client.NewClient(
-		httpTransport,
-		client.WithSamplingHandler(samplingHandler),
-	)
-
-	// Start the client
-	ctx := context.Background()
-	err = mcpClient.Start(ctx)
-	if err != nil {
-		log.Fatalf("Failed to start client: %v", err)
+	// Create sampling handler for the chosen provider, keyed from the environment
+	var samplingHandler client.SamplingHandler
+	switch *provider {
+	case "anthropic":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			log.Fatal("ANTHROPIC_API_KEY environment variable is required")
+		}
+		handler := llm.NewAnthropicSamplingHandler(apiKey)
+		if *baseURL != "" {
+			if err := handler.SetBaseURL(*baseURL); err != nil {
+				log.Fatalf("Invalid -base-url: %v", err)
+			}
+		}
+		if *proxy != "" {
+			if err := handler.SetProxy(*proxy); err != nil {
+				log.Fatalf("Invalid -proxy: %v", err)
+			}
+		}
+		handler.Limiter = limiter
+		handler.MaxResponseBytes = *maxResponseBytes
+		handler.Headers = headers
+		handler.Retry = retry
+		handler.Model = *model
+		handler.FallbackModel = *fallbackModel
+		handler.MaxTokens = ceilings
+		handler.PromptCaching = *promptCaching
+		handler.Stream = *stream
+		handler.SystemPrefix = systemPrompts["anthropic"]
+		if *modelAliases != "" {
+			aliases, err := llm.LoadModelAliases(*modelAliases, llm.DefaultAnthropicAliases)
+			if err != nil {
+				log.Fatalf("Invalid -model-aliases: %v", err)
+			}
+			handler.Aliases = aliases
+		}
+		samplingHandler = handler
+	case "openai":
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			log.Fatal("OPENAI_API_KEY environment variable is required")
+		}
+		handler := llm.NewOpenAISamplingHandler(apiKey)
+		if *baseURL != "" {
+			if err := handler.SetBaseURL(*baseURL); err != nil {
+				log.Fatalf("Invalid -base-url: %v", err)
+			}
+		}
+		if *proxy != "" {
+			if err := handler.SetProxy(*proxy); err != nil {
+				log.Fatalf("Invalid -proxy: %v", err)
+			}
+		}
+		handler.Limiter = limiter
+		handler.MaxResponseBytes = *maxResponseBytes
+		handler.Headers = headers
+		handler.Retry = retry
+		handler.Model = *model
+		handler.FallbackModel = *fallbackModel
+		handler.MaxTokens = ceilings
+		handler.Stream = *stream
+		handler.SystemPrefix = systemPrompts["openai"]
+		if *modelAliases != "" {
+			aliases, err := llm.LoadModelAliases(*modelAliases, llm.DefaultOpenAIAliases)
+			if err != nil {
+				log.Fatalf("Invalid -model-aliases: %v", err)
+			}
+			handler.Aliases = aliases
+		}
+		samplingHandler = handler
+	case "gemini":
+		apiKey := os.Getenv("GEMINI_API_KEY")
+		if apiKey == "" {
+			log.Fatal("GEMINI_API_KEY environment variable is required")
+		}
+		handler := llm.NewGeminiSamplingHandler(apiKey)
+		if *baseURL != "" {
+			if err := handler.SetBaseURL(*baseURL); err != nil {
+				log.Fatalf("Invalid -base-url: %v", err)
+			}
+		}
+		if *proxy != "" {
+			if err := handler.SetProxy(*proxy); err != nil {
+				log.Fatalf("Invalid -proxy: %v", err)
+			}
+		}
+		handler.Limiter = limiter
+		handler.MaxResponseBytes = *maxResponseBytes
+		handler.Headers = headers
+		handler.Retry = retry
+		handler.Model = *model
+		handler.FallbackModel = *fallbackModel
+		handler.MaxTokens = ceilings
+		handler.SystemPrefix = systemPrompts["gemini"]
+		if *modelAliases != "" {
+			aliases, err := llm.LoadModelAliases(*modelAliases, llm.DefaultGeminiAliases)
+			if err != nil {
+				log.Fatalf("Invalid -model-aliases: %v", err)
+			}
+			handler.Aliases = aliases
+		}
+		if *safetySettingsFile != "" {
+			settings, err := llm.LoadSafetySettings(*safetySettingsFile)
+			if err != nil {
+				log.Fatalf("Invalid -safety-settings: %v", err)
+			}
+			handler.SafetySettings = settings
+		}
+		samplingHandler = handler
+	default:
+		log.Fatalf("Unknown provider: %s", *provider)
 	}
 
-	// Initialize the MCP session
+	// The session is initialized with this on every connect
 	initRequest := mcp.InitializeRequest{
 		Params: mcp.InitializeParams{
 			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
@@ -253,16 +223,103 @@ func main() {
 		},
 	}
 
-	initResponse, err := mcpClient.Initialize(ctx, initRequest)
+	// connect opens a session: an HTTP transport with continuous listening
+	// for sampling, and a started, initialized client with sampling support
+	connect := func(ctx context.Context) (*client.Client, *mcp.InitializeResult, error) {
+		httpTransport, err := transport.NewStreamableHTTP(
+			"http://localhost:8080/mcp",
+			transport.WithContinuousListening(),
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create HTTP transport: %v", err)
+		}
+		mcpClient := client.NewClient(
+			httpTransport,
+			client.WithSamplingHandler(samplingHandler),
+		)
+		if err := mcpClient.Start(ctx); err != nil {
+			mcpClient.Close()
+			return nil, nil, fmt.Errorf("failed to start client: %v", err)
+		}
+		initResponse, err := mcpClient.Initialize(ctx, initRequest)
+		if err != nil {
+			mcpClient.Close()
+			return nil, nil, fmt.Errorf("failed to initialize MCP session: %v", err)
+		}
+		return mcpClient, initResponse, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mcpClient, initResponse, err := connect(ctx)
 	if err != nil {
-		log.Fatalf("Failed to initialize MCP session: %v", err)
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	// The keepalive goroutine replaces the session while main closes it on
+	// the way out, so both hold mu. Once closed, a session that a reconnect
+	// opens late is closed at once instead of leaking.
+	var mu sync.Mutex
+	closed := false
+	defer func() {
+		cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		mcpClient.Close()
+	}()
+
+	// A lost session is replaced by a new one on the schedule of the
+	// -reconnect flags; nil leaves keepalive only logging failed pings
+	var reconnectSession func(ctx context.Context) (pinger, error)
+	if schedule.MaxAttempts > 0 {
+		reconnectSession = func(ctx context.Context) (pinger, error) {
+			mu.Lock()
+			mcpClient.Close()
+			mu.Unlock()
+
+			var session *client.Client
+			err := reconnect(ctx, schedule, sleepContext, func(ctx context.Context) error {
+				c, initResponse, err := connect(ctx)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if closed {
+					c.Close()
+					return fmt.Errorf("client is shutting down")
+				}
+				mcpClient, session = c, c
+				log.Printf("🔗 Reconnected to MCP Server: %s v%s", initResponse.ServerInfo.Name, initResponse.ServerInfo.Version)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			return session, nil
+		}
 	}
 
 	log.Println("✅ Enhanced HTTP MCP Client with Anthropic API integration started successfully!")
 	log.Println("")
 	log.Printf("🔗 Connected to MCP Server: %s v%s\n", initResponse.ServerInfo.Name, initResponse.ServerInfo.Version)
-	log.Println("🤖 Connected to Anthropic API (Claude 3.5 Sonnet)")
+	log.Printf("🤖 Sampling with provider: %s", *provider)
+	if prefix := systemPrompts[*provider]; prefix != "" {
+		log.Printf("System prompts are prefixed with %d characters from -system-prompts", len(prefix))
+	}
 	log.Println("📡 Continuous listening enabled for server notifications")
+	if *keepalive > 0 {
+		go func(session pinger) {
+			// Shutting down mid-reconnect is not a failure
+			if err := keepAlive(ctx, session, *keepalive, reconnectSession); err != nil && ctx.Err() == nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}(mcpClient)
+		log.Printf("💓 Keepalive ping every %v", *keepalive)
+		if reconnectSession != nil {
+			log.Printf("🔌 Reconnecting up to %d times if the connection is lost", schedule.MaxAttempts)
+		}
+	}
 	log.Println("")
 	log.Println("Features:")
 	log.Println("- Supports text, image, and binary file analysis")
@@ -290,4 +347,120 @@ func main() {
 	}
 
 	log.Println("Shutting down client...")
-}
\ No newline at end of file
+}
+
+// pinger is the part of the MCP client keepAlive needs.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// keepAlive pings the server every interval until ctx is done. Proxies and
+// load balancers drop connections that sit idle, which would silently cut
+// off the sampling requests this client is waiting for. With a reconnect
+// function, a failed ping replaces the session with the one it returns; an
+// error from it, after its attempts run out, is returned. Without one,
+// failures are only logged.
+func keepAlive(ctx context.Context, c pinger, interval time.Duration, reconnect func(ctx context.Context) (pinger, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, min(interval, 10*time.Second))
+		err := c.Ping(pingCtx)
+		cancel()
+
+		if err != nil && reconnect != nil {
+			log.Printf("⚠️  Keepalive ping failed, reconnecting: %v", err)
+			if c, err = reconnect(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			failures++
+			log.Printf("⚠️  Keepalive ping failed (%d in a row): %v", failures, err)
+			continue
+		}
+		if failures > 0 {
+			log.Printf("💓 Keepalive ping succeeded after %d failures", failures)
+		}
+		failures = 0
+	}
+}
+
+// backoff is the schedule of reconnect attempts. The first attempt is
+// immediate; the second waits Initial, and each one after waits Multiplier
+// times longer than the last, up to Max. After MaxAttempts the client gives
+// up.
+type backoff struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	MaxAttempts int
+}
+
+func (b backoff) validate() error {
+	switch {
+	case b.Initial <= 0:
+		return fmt.Errorf("-reconnect-initial must be positive")
+	case b.Max < b.Initial:
+		return fmt.Errorf("-reconnect-max must be at least -reconnect-initial")
+	case b.Multiplier < 1:
+		return fmt.Errorf("-reconnect-multiplier must be at least 1")
+	case b.MaxAttempts < 0:
+		return fmt.Errorf("-reconnect-max-attempts cannot be negative")
+	}
+	return nil
+}
+
+// delay returns the wait before the given attempt, counting from 1.
+func (b backoff) delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-2))
+	if d >= float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// reconnect calls connect until it succeeds, at most b.MaxAttempts times,
+// waiting with sleep as b says before each attempt. The error after the
+// last attempt says the client gave up, so it is never retrying silently.
+func reconnect(ctx context.Context, b backoff, sleep func(context.Context, time.Duration) error, connect func(context.Context) error) error {
+	var err error
+	for attempt := 1; attempt <= b.MaxAttempts; attempt++ {
+		if err := sleep(ctx, b.delay(attempt)); err != nil {
+			return err
+		}
+		log.Printf("🔌 Reconnecting to the server (attempt %d of %d)", attempt, b.MaxAttempts)
+		if err = connect(ctx); err == nil {
+			return nil
+		}
+		log.Printf("⚠️  Reconnect attempt %d failed: %v", attempt, err)
+	}
+	return fmt.Errorf("gave up reconnecting to the server after %d attempts: %v", b.MaxAttempts, err)
+}
+
+// sleepContext waits for d, or returns ctx's error if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePinger records when it was pinged and fails while fail is set.
+type fakePinger struct {
+	mu    sync.Mutex
+	pings []time.Time
+	fail  bool
+}
+
+func (f *fakePinger) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pings = append(f.pings, time.Now())
+	if f.fail {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (f *fakePinger) Pings() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.pings...)
+}
+
+func TestKeepAlivePingsAtInterval(t *testing.T) {
+	p := &fakePinger{}
+	ctx, cancel := context.WithTimeout(context.Background(), 230*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := keepAlive(ctx, p, 50*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	pings := p.Pings()
+	if len(pings) < 3 || len(pings) > 5 {
+		t.Fatalf("got %d pings in 230ms, want about 4 at a 50ms interval", len(pings))
+	}
+	if first := pings[0].Sub(start); first < 40*time.Millisecond {
+		t.Errorf("first ping after %v, want one interval", first)
+	}
+	for i := 1; i < len(pings); i++ {
+		if gap := pings[i].Sub(pings[i-1]); gap < 30*time.Millisecond {
+			t.Errorf("pings %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestKeepAliveContinuesAfterFailedPings(t *testing.T) {
+	p := &fakePinger{fail: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 75*time.Millisecond)
+	defer cancel()
+
+	if err := keepAlive(ctx, p, 20*time.Millisecond, nil); err != nil {
+		t.Fatalf("failed pings without a reconnect function should only be logged, got %v", err)
+	}
+	if n := len(p.Pings()); n < 2 {
+		t.Errorf("got %d pings, want pinging to go on after a failure", n)
+	}
+}
+
+func TestKeepAliveReconnectsOnFailedPing(t *testing.T) {
+	broken := &fakePinger{fail: true}
+	replacement := &fakePinger{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	reconnects := 0
+	err := keepAlive(ctx, broken, 20*time.Millisecond, func(context.Context) (pinger, error) {
+		reconnects++
+		return replacement, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reconnects != 1 || len(broken.Pings()) != 1 {
+		t.Errorf("reconnected %d times after %d pings of the broken session, want once after one", reconnects, len(broken.Pings()))
+	}
+	if len(replacement.Pings()) == 0 {
+		t.Error("the replacement session was never pinged")
+	}
+}
+
+func TestKeepAliveReturnsReconnectError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := keepAlive(ctx, &fakePinger{fail: true}, 10*time.Millisecond, func(context.Context) (pinger, error) {
+		return nil, errors.New("gave up")
+	})
+	if err == nil || err.Error() != "gave up" {
+		t.Errorf("keepAlive returned %v, want the reconnect error", err)
+	}
+}
+
+// recordSleeps is a sleep function for reconnect that returns at once and
+// records each wait it was asked for.
+func recordSleeps(waits *[]time.Duration) func(context.Context, time.Duration) error {
+	return func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+}
+
+func TestBackoffDelays(t *testing.T) {
+	b := backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 3, MaxAttempts: 6}
+	want := []time.Duration{0, time.Second, 3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := b.delay(i + 1); got != w {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	constant := backoff{Initial: time.Second, Max: time.Minute, Multiplier: 1, MaxAttempts: 3}
+	if got := constant.delay(5); got != time.Second {
+		t.Errorf("a multiplier of 1 waited %v, want Initial every time", got)
+	}
+}
+
+func TestReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	b := backoff{Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond, Multiplier: 2, MaxAttempts: 4}
+	var waits []time.Duration
+	attempts := 0
+
+	err := reconnect(context.Background(), b, recordSleeps(&waits), func(context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	if attempts != 4 {
+		t.Errorf("made %d attempts, want MaxAttempts 4", attempts)
+	}
+	if want := "gave up reconnecting to the server after 4 attempts: connection refused"; err == nil || err.Error() != want {
+		t.Errorf("reconnect returned %v, want %q", err, want)
+	}
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("waited %v, want %v", waits, want)
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("wait before attempt %d = %v, want %v", i+1, waits[i], want[i])
+		}
+	}
+}
+
+func TestReconnectStopsOnSuccess(t *testing.T) {
+	b := backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, MaxAttempts: 10}
+	var waits []time.Duration
+	attempts := 0
+
+	err := reconnect(context.Background(), b, recordSleeps(&waits), func(context.Context) error {
+		if attempts++; attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	if err != nil || attempts != 3 || len(waits) != 3 {
+		t.Errorf("reconnect returned %v after %d attempts and %d waits, want success on the third", err, attempts, len(waits))
+	}
+}
+
+func TestReconnectStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := backoff{Initial: time.Hour, Max: time.Hour, Multiplier: 1, MaxAttempts: 5}
+	attempts := 0
+
+	start := time.Now()
+	err := reconnect(ctx, b, sleepContext, func(context.Context) error {
+		attempts++
+		cancel()
+		return errors.New("connection refused")
+	})
+
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("reconnect returned %v after %d attempts, want context.Canceled after one", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("canceling took %v to interrupt an hour's wait", elapsed)
+	}
+}
+
+func TestBackoffValidate(t *testing.T) {
+	valid := backoff{Initial: time.Second, Max: 30 * time.Second, Multiplier: 2, MaxAttempts: 10}
+	if err := valid.validate(); err != nil {
+		t.Errorf("the default flags are invalid: %v", err)
+	}
+
+	tests := []struct {
+		change func(*backoff)
+		want   string
+	}{
+		{func(b *backoff) { b.Initial = 0 }, "-reconnect-initial must be positive"},
+		{func(b *backoff) { b.Max = 500 * time.Millisecond }, "-reconnect-max must be at least -reconnect-initial"},
+		{func(b *backoff) { b.Multiplier = 0.5 }, "-reconnect-multiplier must be at least 1"},
+		{func(b *backoff) { b.MaxAttempts = -1 }, "-reconnect-max-attempts cannot be negative"},
+	}
+	for _, tt := range tests {
+		b := valid
+		tt.change(&b)
+		if err := b.validate(); err == nil || err.Error() != tt.want {
+			t.Errorf("validate() = %v, want %q", err, tt.want)
+		}
+	}
+}
@@ -1,356 +1,283 @@
 package main
 
 import (
-	"context"
-	"encoding/base64"
-	"fmt"
+	"flag"
+	"io"
 	"log"
-	"mime"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
-	"time"
 
-	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/hardwaylabs/learn-mcp-sampling/mcp-implementations/analysis"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-const DEFAULT_FILES_DIR = "./files"
-
 func main() {
-	// Create MCP server with sampling capability
-	mcpServer := server.NewMCPServer("enhanced-sampling-server", "1.0.0")
-
-	// Enable sampling capability
-	mcpServer.EnableSampling()
-
-	// Ensure files directory exists
-	if err := os.MkdirAll(DEFAULT_FILES_DIR, 0755); err != nil {
-		log.Printf("Warning: Could not create files directory: %v", err)
-	}
-
-	// Add tool to analyze a file using LLM sampling
-	mcpServer.AddTool(mcp.Tool{
-		Name:        "analyze_file",
-		Description: "Analyze a file from the local directory using LLM sampling",
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]any{
-				"filename": map[string]any{
-					"type":        "string",
-					"description": "The name of the file to analyze (relative to files directory)",
-				},
-				"analysis_type": map[string]any{
-					"type":        "string",
-					"description": "Type of analysis to perform",
-					"enum":        []string{"summarize", "explain", "analyze", "extract_key_points"},
-				},
-				"custom_prompt": map[string]any{
-					"type":        "string",
-					"description": "Optional custom prompt for the analysis",
-				},
-			},
-			Required: []string{"filename"},
-		},
-	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// Extract parameters
-		filename, err := request.RequireString("filename")
-		if err != nil {
-			return nil, err
-		}
-
-		analysisType := request.GetString("analysis_type", "summarize")
-		customPrompt := request.GetString("custom_prompt", "")
-
-		// Construct file path
-		filePath := filepath.Join(DEFAULT_FILES_DIR, filename)
-		
-		// Security check - ensure file is within the files directory
-		absFilePath, err := filepath.Abs(filePath)
-		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("Error resolving file path: %v", err),
-					},
-				},
-				IsError: true,
-			}, nil
-		}
-
-		absDirPath, err := filepath.Abs(DEFAULT_FILES_DIR)
+	filesDir := flag.String("files-dir", analysis.DEFAULT_FILES_DIR, "Directory of files to analyze, or a colon-separated search path of directories tried in order")
+	fileSource := flag.String("file-source", "local", "Where files are read from: local (-files-dir) or s3 (an S3-compatible bucket; credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	s3Bucket := flag.String("s3-bucket", "", "Bucket for -file-source s3")
+	s3Prefix := flag.String("s3-prefix", "", "Key prefix within -s3-bucket that files are read from, e.g. reports/ (default: the whole bucket)")
+	s3Region := flag.String("s3-region", "us-east-1", "Region of -s3-bucket")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3-compatible endpoint URL, e.g. for MinIO or https://storage.googleapis.com (default: the AWS endpoint for -s3-region)")
+	s3CacheDir := flag.String("s3-cache-dir", "", "Directory for downloaded objects (default: a directory under the OS temp dir)")
+	s3MaxObjectBytes := flag.Int64("s3-max-object-bytes", analysis.DefaultMaxObjectBytes, "Maximum size of an object downloaded from -s3-bucket")
+	archiveMaxMembers := flag.Int("archive-max-members", analysis.DefaultArchiveMaxMembers, "Maximum number of text members analyzed per zip/tar.gz archive")
+	archiveMaxListed := flag.Int("archive-max-listed", analysis.DefaultArchiveMaxListed, "Maximum number of archive members listed in a result; the rest are only counted")
+	archiveMaxMemberBytes := flag.Int64("archive-max-member-bytes", analysis.DefaultArchiveMaxMemberBytes, "Maximum decompressed size of a single archive member")
+	archiveMaxTotalBytes := flag.Int64("archive-max-total-bytes", analysis.DefaultArchiveMaxTotalBytes, "Maximum decompressed size of all archive members combined")
+	maxConcurrentSampling := flag.Int("max-concurrent-sampling", analysis.DefaultMaxConcurrentSampling, "Maximum sampling requests in flight at once, across all tool calls")
+	indexTTL := flag.Duration("index-ttl", analysis.DefaultIndexTTL, "How long list_files trusts its cached listing of the files directory")
+	maxListEntries := flag.Int("max-list-entries", analysis.DefaultMaxListEntries, "Maximum files list_files returns in one call; callers page through the rest")
+	minFileBytes := flag.Int64("min-file-bytes", 0, "Reject analysis of files smaller than this many bytes unless the call sets force (0 disables)")
+	imageMaxDimension := flag.Int("image-max-dimension", analysis.DefaultImageMaxDimension, "Images with a longer edge (pixels) are downscaled before sampling")
+	imageMaxBytes := flag.Int("image-max-bytes", analysis.DefaultImageMaxBytes, "Images larger than this once base64 encoded are downscaled before sampling")
+	defaultAnalysis := flag.String("default-analysis", analysis.DefaultAnalysisType, "Analysis type used when a call omits analysis_type ("+strings.Join(analysis.AnalysisTypeNames(), ", ")+")")
+	chunkSize := flag.Int("chunk-size", analysis.DefaultChunkSize, "Largest text (bytes) sent in one sampling request; longer files are analyzed in chunks")
+	partialsDir := flag.String("partials-dir", "", "Directory for resumable chunk results (default: a directory under the OS temp dir)")
+	embeddingsProvider := flag.String("embeddings-provider", "", "Embeddings provider for embed_file: openai or voyage (default: embeddings disabled)")
+	embeddingsURL := flag.String("embeddings-url", "", "Override the provider's embeddings endpoint URL")
+	embeddingsModel := flag.String("embeddings-model", "", "Override the provider's default embedding model")
+	moderation := flag.Bool("moderation", false, "Screen file content before sampling and refuse flagged content")
+	moderationBlocklist := flag.String("moderation-blocklist", "", "File of blocked terms, one per line, used when -moderation is set")
+	moderationURL := flag.String("moderation-url", "", "OpenAI-compatible moderation endpoint used when -moderation is set (key from OPENAI_API_KEY)")
+	cacheBackend := flag.String("cache-backend", "memory", "Where analysis results are cached: memory or disk")
+	cacheDir := flag.String("cache-dir", "", "Directory for -cache-backend disk (default: a directory under the OS temp dir)")
+	cacheTTL := flag.Duration("cache-ttl", analysis.DefaultCacheTTL, "How long a cached analysis result is served")
+	idempotencyTTL := flag.Duration("idempotency-ttl", analysis.DefaultIdempotencyTTL, "How long a tool call's result is returned to calls repeating its idempotency_key")
+	refusalPatterns := flag.String("refusal-patterns", "", "File of regular expressions, one per line, replacing the built-in patterns that detect a model refusing a request")
+	piiPatterns := flag.String("pii-patterns", "", "File of \"NAME regexp\" lines replacing the built-in PII patterns used by the redact argument")
+	autoContinue := flag.Bool("auto-continue", false, "Complete answers cut off at max_tokens with follow-up requests instead of marking them truncated")
+	autoLanguage := flag.Bool("auto-language", false, "Detect the language of text files and have analyze_file answer in it, unless a call sets language")
+	maxContinuations := flag.Int("max-continuations", analysis.DefaultMaxContinuations, "Most follow-up requests -auto-continue sends for one answer")
+	resultFooter := flag.String("result-footer", "", "Text appended to the output of every sampling tool, e.g. a disclaimer")
+	logSampleRate := flag.Int("log-sample-rate", 1, "Log the routine messages of one in N tool calls; failures and slow calls are always logged")
+	slowRequest := flag.Duration("slow-request", analysis.DefaultSlowRequestThreshold, "Tool call duration that is always logged as slow")
+	binaryPolicy := flag.String("binary-policy", analysis.DefaultBinaryPolicy, "What is sent for binary files that are not images: "+strings.Join(analysis.BinaryPolicies, ", "))
+	debug := flag.Bool("debug", false, "Verbose logging, including each client's declared capabilities and sampling request and response sizes")
+	logFile := flag.String("log-file", "", "Also write logs to this file, rotated by size (default: stderr only)")
+	logMaxSize := flag.Int64("log-max-size", analysis.DefaultLogMaxSize>>20, "Size in MB at which -log-file is rotated")
+	logMaxBackups := flag.Int("log-max-backups", analysis.DefaultLogMaxBackups, "Rotated -log-file backups kept (file.1 is the newest); older ones are deleted")
+	flag.Parse()
+
+	if *logFile != "" {
+		rotating, err := analysis.OpenRotatingFile(*logFile, *logMaxSize<<20, *logMaxBackups)
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("Error resolving directory path: %v", err),
-					},
-				},
-				IsError: true,
-			}, nil
+			log.Fatalf("Invalid -log-file: %v", err)
 		}
+		defer rotating.Close()
+		log.SetOutput(io.MultiWriter(os.Stderr, rotating))
+	}
 
-		if !strings.HasPrefix(absFilePath, absDirPath) {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: "Access denied: File must be within the files directory",
-					},
-				},
-				IsError: true,
-			}, nil
-		}
+	if !slices.Contains(analysis.AnalysisTypeNames(), *defaultAnalysis) {
+		log.Fatalf("Unknown -default-analysis %q (use one of: %s)", *defaultAnalysis, strings.Join(analysis.AnalysisTypeNames(), ", "))
+	}
 
-		// Check if file exists
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("File not found: %s", filename),
-					},
-				},
-				IsError: true,
-			}, nil
-		}
+	if !slices.Contains(analysis.BinaryPolicies, *binaryPolicy) {
+		log.Fatalf("Unknown -binary-policy %q (use one of: %s)", *binaryPolicy, strings.Join(analysis.BinaryPolicies, ", "))
+	}
 
-		// Read file content
-		fileContent, err := os.ReadFile(filePath)
-		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("Error reading file: %v", err),
-					},
-				},
-				IsError: true,
-			}, nil
+	var embedder analysis.Embedder
+	if *embeddingsProvider != "" {
+		provider, ok := analysis.EmbeddingProviders[*embeddingsProvider]
+		if !ok {
+			log.Fatalf("Unknown embeddings provider: %s", *embeddingsProvider)
 		}
-
-		// Determine file type
-		ext := strings.ToLower(filepath.Ext(filename))
-		mimeType := mime.TypeByExtension(ext)
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
+		if *embeddingsURL != "" {
+			provider.URL = *embeddingsURL
 		}
-
-		// Prepare content for LLM based on file type
-		var contentForLLM mcp.Content
-		var systemPrompt string
-
-		// Create appropriate prompt based on analysis type
-		var basePrompt string
-		switch analysisType {
-		case "summarize":
-			basePrompt = "Please provide a clear and concise summary of this content."
-		case "explain":
-			basePrompt = "Please explain what this content is about and its main purpose."
-		case "analyze":
-			basePrompt = "Please provide a detailed analysis of this content, including its structure, key components, and any notable patterns."
-		case "extract_key_points":
-			basePrompt = "Please extract the key points and main ideas from this content."
-		default:
-			basePrompt = "Please analyze this content and provide insights."
+		if *embeddingsModel != "" {
+			provider.Model = *embeddingsModel
 		}
-
-		if customPrompt != "" {
-			basePrompt = customPrompt
+		apiKey := os.Getenv(provider.KeyEnv)
+		if apiKey == "" {
+			log.Fatalf("%s environment variable is required for %s embeddings", provider.KeyEnv, *embeddingsProvider)
 		}
+		embedder = analysis.NewHTTPEmbedder(provider.URL, provider.Model, apiKey)
+	}
 
-		if strings.HasPrefix(mimeType, "text/") || ext == ".md" || ext == ".txt" || ext == ".json" || ext == ".xml" || ext == ".csv" {
-			// Text file - send as text content
-			contentForLLM = mcp.TextContent{
-				Type: "text",
-				Text: string(fileContent),
+	var moderator analysis.Moderator
+	if *moderation {
+		var chain analysis.ModeratorChain
+		if *moderationBlocklist != "" {
+			blocklist, err := analysis.LoadBlocklist(*moderationBlocklist)
+			if err != nil {
+				log.Fatalf("Failed to load moderation blocklist: %v", err)
 			}
-			systemPrompt = fmt.Sprintf("%s The content is a %s file named '%s'.", basePrompt, mimeType, filename)
-		} else if strings.HasPrefix(mimeType, "image/") {
-			// Image file - send as base64 encoded image
-			base64Content := base64.StdEncoding.EncodeToString(fileContent)
-			contentForLLM = mcp.ImageContent{
-				Type: "image",
-				Data: base64Content,
-				MIMEType: mimeType,
-			}
-			systemPrompt = fmt.Sprintf("%s The content is an image file named '%s' of type %s.", basePrompt, filename, mimeType)
-		} else {
-			// Binary file - send as base64 with description
-			base64Content := base64.StdEncoding.EncodeToString(fileContent)
-			contentForLLM = mcp.TextContent{
-				Type: "text",
-				Text: fmt.Sprintf("This is a binary file (%s) encoded in base64:\n\n%s", mimeType, base64Content),
+			chain = append(chain, blocklist)
+		}
+		if *moderationURL != "" {
+			apiKey := os.Getenv("OPENAI_API_KEY")
+			if apiKey == "" {
+				log.Fatal("OPENAI_API_KEY environment variable is required for -moderation-url")
 			}
-			systemPrompt = fmt.Sprintf("%s The content is a binary file named '%s' of type %s, provided as base64-encoded data.", basePrompt, filename, mimeType)
+			chain = append(chain, analysis.NewHTTPModerator(*moderationURL, apiKey))
 		}
-
-		// Create sampling request
-		samplingRequest := mcp.CreateMessageRequest{
-			CreateMessageParams: mcp.CreateMessageParams{
-				Messages: []mcp.SamplingMessage{
-					{
-						Role:    mcp.RoleUser,
-						Content: contentForLLM,
-					},
-				},
-				SystemPrompt: systemPrompt,
-				MaxTokens:    2000,
-				Temperature:  0.3, // Lower temperature for more focused analysis
-			},
+		if len(chain) == 0 {
+			log.Fatal("-moderation needs -moderation-blocklist and/or -moderation-url")
 		}
+		moderator = chain
+	}
 
-		// Request sampling from the client with timeout
-		log.Printf("📤 Sending sampling request for file: %s (analysis: %s)", filename, analysisType)
-		samplingCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-		defer cancel()
-
-		serverFromCtx := server.ServerFromContext(ctx)
-		result, err := serverFromCtx.RequestSampling(samplingCtx, samplingRequest)
+	var cache analysis.CacheStore
+	switch *cacheBackend {
+	case "memory":
+		cache = analysis.NewMemoryCache()
+	case "disk":
+		dir := *cacheDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "enhanced-sampling-server", "cache")
+		}
+		diskCache, err := analysis.NewDiskCache(dir)
 		if err != nil {
-			log.Printf("❌ Sampling request failed: %v", err)
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("Error requesting sampling: %v", err),
-					},
-				},
-				IsError: true,
-			}, nil
+			log.Fatalf("Failed to open cache directory: %v", err)
 		}
+		cache = diskCache
+		log.Printf("Caching analysis results in %s", dir)
+	default:
+		log.Fatalf("Unknown cache backend: %s (use %s)", *cacheBackend, strings.Join(analysis.CacheBackends, " or "))
+	}
 
-		log.Printf("✅ Sampling request successful! Model: %s", result.Model)
-		
-		// Extract response text safely
-		var responseText string
-		if textContent, ok := result.Content.(mcp.TextContent); ok {
-			responseText = textContent.Text
-		} else {
-			responseText = fmt.Sprintf("%v", result.Content)
+	var source analysis.FileSource
+	switch *fileSource {
+	case "local":
+	case "s3":
+		if *s3Bucket == "" {
+			log.Fatal("-file-source s3 needs -s3-bucket")
 		}
-
-		// Return the analysis result
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("File Analysis Results\n" +
-						"=====================\n" +
-						"File: %s\n" +
-						"Type: %s\n" +
-						"Analysis: %s\n" +
-						"Model: %s\n\n" +
-						"%s", filename, mimeType, analysisType, result.Model, responseText),
-				},
-			},
-		}, nil
-	})
-
-	// Add tool to list available files
-	mcpServer.AddTool(mcp.Tool{
-		Name:        "list_files",
-		Description: "List all files available for analysis in the files directory",
-		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]any{},
-		},
-	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		entries, err := os.ReadDir(DEFAULT_FILES_DIR)
+		accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == "" {
+			log.Fatal("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables are required for -file-source s3")
+		}
+		dir := *s3CacheDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "enhanced-sampling-server", "objects")
+		}
+		client := analysis.NewS3Client(analysis.S3Config{
+			Endpoint:        *s3Endpoint,
+			Region:          *s3Region,
+			Bucket:          *s3Bucket,
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretKey,
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+		objects, err := analysis.NewObjectSource(client, *s3Prefix, dir)
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("Error reading files directory: %v", err),
-					},
-				},
-				IsError: true,
-			}, nil
+			log.Fatalf("Failed to open object cache directory: %v", err)
 		}
+		objects.MaxBytes = *s3MaxObjectBytes
+		source = objects
+	default:
+		log.Fatalf("Unknown file source: %s (use %s)", *fileSource, strings.Join(analysis.FileSources, " or "))
+	}
 
-		var fileList []string
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				info, err := entry.Info()
-				if err != nil {
-					continue
-				}
-				size := info.Size()
-				mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(entry.Name())))
-				if mimeType == "" {
-					mimeType = "application/octet-stream"
-				}
-				fileList = append(fileList, fmt.Sprintf("- %s (%d bytes, %s)", entry.Name(), size, mimeType))
-			}
+	var patterns []analysis.PIIPattern
+	if *piiPatterns != "" {
+		var err error
+		patterns, err = analysis.LoadPIIPatterns(*piiPatterns)
+		if err != nil {
+			log.Fatalf("Failed to load PII patterns: %v", err)
 		}
+	}
 
-		if len(fileList) == 0 {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("No files found in %s directory", DEFAULT_FILES_DIR),
-					},
-				},
-			}, nil
+	var refusals []*regexp.Regexp
+	if *refusalPatterns != "" {
+		var err error
+		refusals, err = analysis.LoadRefusalPatterns(*refusalPatterns)
+		if err != nil {
+			log.Fatalf("Failed to load refusal patterns: %v", err)
 		}
+	}
 
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Available files in %s:\n\n%s", DEFAULT_FILES_DIR, strings.Join(fileList, "\n")),
-				},
-			},
-		}, nil
-	})
-
-	// Add the original echo tool for testing
-	mcpServer.AddTool(mcp.Tool{
-		Name:        "echo",
-		Description: "Echo back the input message (no sampling required)",
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]any{
-				"message": map[string]any{
-					"type":        "string",
-					"description": "The message to echo back",
-				},
-			},
-			Required: []string{"message"},
-		},
-	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		message := request.GetString("message", "")
-
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Echo: %s", message),
-				},
-			},
-		}, nil
+	// Create MCP server with sampling capability and the file analysis tools
+	analysisServer := analysis.New(analysis.Config{
+		FilesDir:              *filesDir,
+		FileSource:            source,
+		ArchiveMaxMembers:     *archiveMaxMembers,
+		ArchiveMaxListed:      *archiveMaxListed,
+		ArchiveMaxMemberBytes: *archiveMaxMemberBytes,
+		ArchiveMaxTotalBytes:  *archiveMaxTotalBytes,
+		MaxConcurrentSampling: *maxConcurrentSampling,
+		IndexTTL:              *indexTTL,
+		MaxListEntries:        *maxListEntries,
+		MinFileBytes:          *minFileBytes,
+		DefaultAnalysis:       *defaultAnalysis,
+		ImageMaxDimension:     *imageMaxDimension,
+		ImageMaxBytes:         *imageMaxBytes,
+		ChunkSize:             *chunkSize,
+		PartialsDir:           *partialsDir,
+		Embedder:              embedder,
+		Moderator:             moderator,
+		Cache:                 cache,
+		CacheTTL:              *cacheTTL,
+		IdempotencyTTL:        *idempotencyTTL,
+		PIIPatterns:           patterns,
+		RefusalPatterns:       refusals,
+		AutoContinue:          *autoContinue,
+		AutoLanguage:          *autoLanguage,
+		MaxContinuations:      *maxContinuations,
+		ResultFooter:          *resultFooter,
+		LogSampleRate:         *logSampleRate,
+		SlowRequestThreshold:  *slowRequest,
+		BinaryPolicy:          *binaryPolicy,
+		Debug:                 *debug,
 	})
 
 	// Create HTTP server
-	httpServer := server.NewStreamableHTTPServer(mcpServer)
+	httpServer := server.NewStreamableHTTPServer(analysisServer.MCPServer())
 
 	log.Println("Starting Enhanced HTTP MCP Server with File Analysis on :8080")
 	log.Println("Endpoint: http://localhost:8080/mcp")
-	log.Printf("Files directory: %s", DEFAULT_FILES_DIR)
+	log.Printf("Files directory: %s", analysisServer.FilesDir())
+	if moderator != nil {
+		log.Println("Content moderation: enabled")
+	}
 	log.Println("")
 	log.Println("This server supports file analysis using LLM sampling over HTTP transport.")
 	log.Println("")
 	log.Println("Available tools:")
-	log.Println("- analyze_file: Analyze files using LLM sampling (text, images, PDFs)")
+	log.Println("- analyze_file: Analyze files using LLM sampling (text, images, PDFs, zip/tar.gz archives)")
+	log.Println("- analyze_batch: Analyze several files, in parallel up to the sampling limit")
 	log.Println("- list_files: List available files for analysis")
+	log.Println("- list_analysis_types: List valid analysis types and their defaults")
+	log.Println("- estimate_batch_cost: Estimate tokens and cost for a batch of files (no sampling)")
+	log.Println("- embed_file: Generate embedding vectors for a text file (needs -embeddings-provider)")
+	log.Println("- summarize_changes: Summarize what changed between two versions of a text file")
+	log.Println("- classify_file: Classify a file into one of the given categories")
+	log.Println("- detect_language: Identify the natural or programming language of a file")
+	log.Println("- generate_quiz: Generate multiple-choice questions about a document as JSON")
+	log.Println("- check_compliance: Check a file against a template's rules, pass/fail per rule")
+	log.Println("- analyze_if_changed: Return the last analysis unless the file changed since")
+	log.Println("- extract_tables: Extract tables from a document as structured JSON")
+	log.Println("- readability: Word count, reading time and grade level of a text file")
+	log.Println("- validate_file: Check JSON, YAML or XML syntax and explain errors")
+	log.Println("- outline: Table of contents of a document as nested JSON")
+	log.Println("- eval_file: Score an analysis against a reference answer")
+	log.Println("- analyze_conversation: Summarize a chat transcript or extract its action items")
+	log.Println("- generate_alt_text: Accessible alt text for an image within a length limit")
+	log.Println("- summarize_with_links: Holistic summary of a Markdown file and the local files it links to")
+	log.Println("- convert_format: Convert a file to another format, locally when the conversion is exact")
+	log.Println("- summarize_logs: Summarize a log file from its line templates, counts and samples")
+	log.Println("- extract_field: Find one value in a document, with a confidence and its source passage")
+	log.Println("- generate_changelog: Write a categorized changelog entry for a diff or patch file")
+	log.Println("- similarity: Score how similar two files are (embeddings, or a model rating)")
+	log.Println("- verify_image: Check that an image meets a description, with a true/false verdict")
+	log.Println("- report: Tabulate a directory of files as CSV or JSON rows, one per file")
+	log.Println("- generate_tags: Generate distinct lowercase tags describing a file")
+	log.Println("- sectioned_summary: Summarize a document section by section, plus an overall summary")
+	log.Println("- suggest_edits: Suggest concrete edits with location, issue and replacement")
+	log.Println("- merge_summaries: Merge summaries produced elsewhere into one, without repetition")
+	log.Println("- diff_analyses: Analyze a file with two models or prompts and explain how the outputs differ")
+	log.Println("- layered_summary: Summarize a file as a title, a sentence, a paragraph and in detail")
+	log.Println("- score_content: Grade a file against a rubric of weighted criteria")
+	log.Println("- fact_check: Check a document's claims against given sources (using sampling)")
+	log.Println("- generate_post: Write a Twitter or LinkedIn post about a document within the character limit (using sampling)")
+	log.Println("- warmup: Prime the provider connection and report model and latency")
 	log.Println("- echo: Simple echo tool (no sampling required)")
 	log.Println("")
 	log.Println("To test:")
-	log.Printf("1. Place files to analyze in the %s directory", DEFAULT_FILES_DIR)
+	log.Printf("1. Place files to analyze in the %s directory", analysisServer.FilesDir())
 	log.Println("2. Start the enhanced client with your Anthropic API key")
 	log.Println("3. The client will connect and handle sampling requests")
 
@@ -358,4 +285,4 @@ func main() {
 	if err := httpServer.Start(":8080"); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,419 @@
+// Package llm contains sampling handlers that answer MCP sampling requests
+// by calling a hosted LLM API.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AnthropicSamplingHandler implements client.SamplingHandler using the Anthropic API
+type AnthropicSamplingHandler struct {
+	APIKey     string
+	HTTPClient *http.Client
+
+	// BaseURL is where provider API paths are sent, so requests can go
+	// through a compatible proxy or gateway. Set it with SetBaseURL.
+	BaseURL string
+
+	// Limiter paces requests to the provider's rate limit. Nil means unlimited.
+	Limiter *RateLimiter
+
+	// MaxResponseBytes caps the response body size read from the provider.
+	// Zero means DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+
+	// Headers are extra headers sent with every provider request, e.g. for
+	// an API gateway. They cannot replace the handler's own headers.
+	Headers map[string]string
+
+	// Model is the alias or model ID used when the server sends no model
+	// hint. Empty means ANTHROPIC_MODEL.
+	Model string
+
+	// Aliases resolves model hints and Model at request time.
+	Aliases ModelAliases
+
+	// FallbackModel is the alias or model ID a request is sent again with
+	// when the provider says the requested model does not exist. Empty
+	// disables the fallback.
+	FallbackModel string
+
+	// Retry decides which failed provider requests are sent again.
+	Retry RetryPolicy
+
+	// MaxTokens caps each request's max_tokens at the selected model's
+	// output limit. Nil sends max_tokens unchanged.
+	MaxTokens TokenCeilings
+
+	// PromptCaching marks the system prompt and large text blocks with an
+	// ephemeral cache_control, so repeated prompts and documents are billed
+	// at the cache-read rate. See markCacheBreakpoints.
+	PromptCaching bool
+
+	// Stream requests responses as server-sent events, so text that arrived
+	// before the request was canceled or cut off is returned, marked
+	// partial, instead of an error. Requests offering tools are not streamed.
+	Stream bool
+
+	// SystemPrefix is put before the system prompt of every request, for
+	// framing this provider's models respond better to. See SystemPrompts.
+	SystemPrefix string
+}
+
+// AnthropicRequest represents the structure for Anthropic API requests
+type AnthropicRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	Messages  []Message `json:"messages"`
+	// System is the system prompt: a string, or []TextContent when it
+	// carries a cache_control marker.
+	System any `json:"system,omitempty"`
+	// Temperature is always sent: MCP drops a zero temperature on the wire,
+	// so zero may be an explicit request for deterministic output.
+	Temperature float64 `json:"temperature"`
+	// Tools are the server tools the model may call, if any.
+	Tools []AnthropicTool `json:"tools,omitempty"`
+	// Stream asks for the response as server-sent events.
+	Stream bool `json:"stream,omitempty"`
+}
+
+// AnthropicTool declares a tool in an Anthropic request.
+type AnthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type Message struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+type Content interface{}
+
+type TextContent struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+type ImageContent struct {
+	Type         string        `json:"type"`
+	Source       Source        `json:"source"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks the end of a cacheable prompt prefix. Anthropic caches
+// everything up to and including the marked block.
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+type Source struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// AnthropicResponse represents the structure for Anthropic API responses
+type AnthropicResponse struct {
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	Role         string                 `json:"role"`
+	Content      []AnthropicTextContent `json:"content"`
+	Model        string                 `json:"model"`
+	StopReason   string                 `json:"stop_reason"`
+	StopSequence string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage         `json:"usage"`
+}
+
+type AnthropicTextContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// ID, Name and Input are set on tool_use blocks
+	ID    string         `json:"id,omitempty"`
+	Name  string         `json:"name,omitempty"`
+	Input map[string]any `json:"input,omitempty"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// Prompt caching: tokens written to and read from the cache. Both are
+	// billed separately from InputTokens.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func NewAnthropicSamplingHandler(apiKey string) *AnthropicSamplingHandler {
+	return &AnthropicSamplingHandler{
+		APIKey:    apiKey,
+		BaseURL:   ANTHROPIC_BASE_URL,
+		Retry:     DefaultRetryPolicy,
+		Aliases:   DefaultAnthropicAliases,
+		MaxTokens: DefaultTokenCeilings,
+		HTTPClient: &http.Client{
+			Timeout:   2 * time.Minute,
+			Transport: newTransport(),
+		},
+	}
+}
+
+// fallbackModel resolves FallbackModel when it is an alias. Any other name
+// is used as a model ID, so the fallback can be a model no alias names.
+func (h *AnthropicSamplingHandler) fallbackModel() string {
+	if model, ok := h.Aliases[h.FallbackModel]; ok {
+		return model
+	}
+	return h.FallbackModel
+}
+
+// SetBaseURL validates and sets the provider base URL.
+func (h *AnthropicSamplingHandler) SetBaseURL(raw string) error {
+	baseURL, err := ValidateBaseURL(raw)
+	if err != nil {
+		return err
+	}
+	h.BaseURL = baseURL
+	return nil
+}
+
+func (h *AnthropicSamplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	log.Printf("📨 Received sampling request with %d messages", len(request.Messages))
+
+	if len(request.Messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	// A caller-supplied key (multi-tenant hosts) takes precedence over ours.
+	// The key itself must never reach the logs.
+	apiKey := h.APIKey
+	if callerKey := metadataString(request.Metadata, MetadataAPIKey); callerKey != "" {
+		apiKey = callerKey
+		log.Println("Using caller-supplied API key for this request")
+	}
+
+	// Anthropic has no seed parameter; temperature 0 is the closest it gets
+	if _, ok := metadataInt(request.Metadata, MetadataSeed); ok {
+		log.Println("Seed requested but not supported by the Anthropic API; ignoring it (best effort)")
+	}
+
+	// Convert MCP messages to Anthropic format
+	var messages []Message
+	for _, mcpMsg := range request.Messages {
+		var content Content
+
+		switch mcpContent := mcpMsg.Content.(type) {
+		case mcp.TextContent:
+			content = []TextContent{{
+				Type: "text",
+				Text: mcpContent.Text,
+			}}
+		case mcp.ImageContent:
+			// For image content, create image block
+			content = []interface{}{
+				ImageContent{
+					Type: "image",
+					Source: Source{
+						Type:      "base64",
+						MediaType: mcpContent.MIMEType,
+						Data:      mcpContent.Data,
+					},
+				},
+			}
+		default:
+			// Fallback to text
+			content = []TextContent{{
+				Type: "text",
+				Text: fmt.Sprintf("%v", mcpContent),
+			}}
+		}
+
+		role := "user"
+		if mcpMsg.Role == mcp.RoleAssistant {
+			role = "assistant"
+		}
+
+		messages = append(messages, Message{
+			Role:    role,
+			Content: content,
+		})
+	}
+
+	// Create Anthropic API request
+	anthropicReq := AnthropicRequest{
+		Model:       selectModel(request, h.Aliases, h.Model, ANTHROPIC_MODEL),
+		MaxTokens:   request.MaxTokens,
+		Messages:    messages,
+		Temperature: request.Temperature,
+	}
+	if system := prefixSystemPrompt(h.SystemPrefix, request.SystemPrompt); system != "" {
+		anthropicReq.System = system
+	}
+	for _, tool := range metadataTools(request.Metadata) {
+		anthropicReq.Tools = append(anthropicReq.Tools, AnthropicTool(tool))
+	}
+	if h.PromptCaching {
+		markCacheBreakpoints(&anthropicReq)
+	}
+	anthropicReq.Stream = h.Stream && len(anthropicReq.Tools) == 0
+
+	anthropicReq.MaxTokens = h.MaxTokens.clamp(anthropicReq.Model, anthropicReq.MaxTokens)
+
+	log.Printf("Sending request to Anthropic API (model: %s, tokens: %d)", anthropicReq.Model, anthropicReq.MaxTokens)
+
+	// Send the request, waiting for the rate limiter before each attempt so
+	// bursts of sampling requests don't turn into 429s
+	requested := anthropicReq.Model
+	resp, model, err := sendWithFallback(ctx, h.HTTPClient, h.Limiter, h.Retry, requested, h.fallbackModel(), func(model string) (*http.Request, error) {
+		anthropicReq.Model = model
+		if model != requested {
+			anthropicReq.MaxTokens = h.MaxTokens.clamp(model, request.MaxTokens)
+		}
+		reqBody, err := json.Marshal(anthropicReq)
+		if err != nil {
+			return nil, err
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", h.BaseURL+"/v1/messages", bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("x-api-key", apiKey)
+		httpReq.Header.Set("anthropic-version", "2023-06-01")
+		setExtraHeaders(httpReq, h.Headers)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var anthropicResp AnthropicResponse
+	var respBody []byte
+	var interrupted error
+	if anthropicReq.Stream {
+		anthropicResp, respBody, interrupted, err = readAnthropicStream(resp.Body, h.MaxResponseBytes)
+		if err != nil {
+			if typeErr := unexpectedContentType(resp, respBody, h.APIKey, apiKey); typeErr != nil {
+				return nil, typeErr
+			}
+			return nil, err
+		}
+	} else {
+		// Read the whole body so it can be returned verbatim when debugging
+		respBody, err = readResponse(resp.Body, h.MaxResponseBytes)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(respBody, &anthropicResp); err != nil {
+			if typeErr := unexpectedContentType(resp, respBody, h.APIKey, apiKey); typeErr != nil {
+				return nil, typeErr
+			}
+			return nil, fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+
+	// Extract text content and the first tool call, if the model made one
+	var responseText string
+	var toolCall *ToolCall
+	for _, block := range anthropicResp.Content {
+		switch {
+		case block.Type == "text" && responseText == "":
+			responseText = block.Text
+		case block.Type == "tool_use" && toolCall == nil:
+			toolCall = &ToolCall{ID: block.ID, Name: block.Name, Input: block.Input}
+		}
+	}
+
+	log.Printf("Received response from Anthropic API (model: %s, input tokens: %d, output tokens: %d)",
+		anthropicResp.Model, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+	if h.PromptCaching {
+		log.Printf("Prompt cache: %d tokens written, %d tokens read",
+			anthropicResp.Usage.CacheCreationInputTokens, anthropicResp.Usage.CacheReadInputTokens)
+	}
+
+	// Convert back to MCP format
+	result := &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role: mcp.RoleAssistant,
+			Content: mcp.TextContent{
+				Type: "text",
+				Text: responseText,
+			},
+		},
+		Model:      anthropicResp.Model,
+		StopReason: anthropicResp.StopReason,
+	}
+
+	meta := map[string]any{}
+	if model != requested {
+		meta[MetadataModelFallback] = map[string]any{"requested": requested, "used": model}
+	}
+	if h.PromptCaching {
+		meta[MetadataCacheUsage] = map[string]any{
+			"cache_creation_input_tokens": anthropicResp.Usage.CacheCreationInputTokens,
+			"cache_read_input_tokens":     anthropicResp.Usage.CacheReadInputTokens,
+		}
+	}
+	if interrupted != nil {
+		markPartial(meta, interrupted, len(responseText))
+	}
+	if toolCall != nil {
+		log.Printf("Model requested tool: %s", toolCall.Name)
+		meta[MetadataToolUse] = toolCall.toMetaMap()
+	}
+	// The server asked for the provider's raw JSON, e.g. for analyze_file's debug_raw
+	if metadataBool(request.Metadata, MetadataDebugRaw) {
+		meta[MetadataRawResponse] = Redact(string(respBody), h.APIKey, apiKey)
+	}
+	if len(meta) > 0 {
+		result.Meta = mcp.NewMetaFromMap(meta)
+	}
+
+	return result, nil
+}
+
+// MinCacheableChars is the smallest text block markCacheBreakpoints marks.
+// Anthropic ignores cache markers on prompts under about 1024 tokens, so
+// smaller blocks are not worth a breakpoint.
+const MinCacheableChars = 4096
+
+// maxCacheBreakpoints is Anthropic's limit on cache_control markers per request.
+const maxCacheBreakpoints = 4
+
+// markCacheBreakpoints adds ephemeral cache_control markers to the system
+// prompt and to large text blocks of the messages, in order, up to the
+// provider's limit. The system prompt is sent as a block so it can carry
+// a marker, and is marked at any size: a short prompt is simply not cached.
+func markCacheBreakpoints(req *AnthropicRequest) {
+	ephemeral := &CacheControl{Type: "ephemeral"}
+	marked := 0
+	if system, ok := req.System.(string); ok && system != "" {
+		req.System = []TextContent{{Type: "text", Text: system, CacheControl: ephemeral}}
+		marked++
+	}
+	for _, msg := range req.Messages {
+		blocks, ok := msg.Content.([]TextContent)
+		if !ok {
+			continue
+		}
+		for i := range blocks {
+			if marked == maxCacheBreakpoints {
+				return
+			}
+			if len(blocks[i].Text) >= MinCacheableChars {
+				blocks[i].CacheControl = ephemeral
+				marked++
+			}
+		}
+	}
+}
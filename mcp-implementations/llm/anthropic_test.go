@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicRawResponseOnlyWhenRequested(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Meta != nil {
+		if _, ok := result.Meta.AdditionalFields[MetadataRawResponse]; ok {
+			t.Errorf("raw response returned without %s", MetadataDebugRaw)
+		}
+	}
+
+	result, err = h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataDebugRaw: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Meta == nil {
+		t.Fatalf("no _meta with %s set", MetadataDebugRaw)
+	}
+	raw, _ := result.Meta.AdditionalFields[MetadataRawResponse].(string)
+	if !strings.Contains(raw, `"id":"msg_test"`) {
+		t.Errorf("raw response %q, want the provider's JSON", raw)
+	}
+}
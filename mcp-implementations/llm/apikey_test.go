@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureLogs collects log output for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+// echoKey answers with a body that repeats the key the request carried, as
+// a misbehaving provider or proxy might.
+func echoKey(w http.ResponseWriter, r *http.Request, body []byte) {
+	key := r.Header.Get("x-api-key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":"msg_test","type":"message","role":"assistant","model":"claude-test",` +
+		`"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","note":"seen key ` + key + `",` +
+		`"usage":{"input_tokens":1,"output_tokens":1}}`))
+}
+
+func TestAnthropicCallerKeyOverridesHandlerKey(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataAPIKey: "caller-key"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	if got := requests[0].Header.Get("x-api-key"); got != "caller-key" {
+		t.Errorf("request with a caller key sent x-api-key %q, want caller-key", got)
+	}
+	if got := requests[1].Header.Get("x-api-key"); got != "handler-key" {
+		t.Errorf("request without a caller key sent x-api-key %q, want the handler's key", got)
+	}
+	if strings.Contains(string(requests[0].Body), "caller-key") {
+		t.Errorf("the caller key leaked into the request body: %s", requests[0].Body)
+	}
+}
+
+func TestOpenAICallerKeyOverridesHandlerKey(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, "ok")
+	})
+	h := newTestOpenAI(p)
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataAPIKey: "caller-key"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	if got := requests[0].Header.Get("Authorization"); got != "Bearer caller-key" {
+		t.Errorf("request with a caller key sent Authorization %q", got)
+	}
+	if got := requests[1].Header.Get("Authorization"); got != "Bearer handler-key" {
+		t.Errorf("request without a caller key sent Authorization %q", got)
+	}
+}
+
+func TestCallerKeyIsRedacted(t *testing.T) {
+	logs := captureLogs(t)
+	p := newFakeProvider(t, echoKey)
+	h := newTestAnthropic(p)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{
+		MetadataAPIKey:   "caller-key",
+		MetadataDebugRaw: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := result.Meta.AdditionalFields[MetadataRawResponse].(string)
+	if strings.Contains(raw, "caller-key") || !strings.Contains(raw, "seen key "+redacted) {
+		t.Errorf("raw response does not redact the caller key: %s", raw)
+	}
+	if strings.Contains(logs.String(), "caller-key") || strings.Contains(logs.String(), "handler-key") {
+		t.Errorf("an API key was logged:\n%s", logs.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	text := `{"api_key":"abc","Authorization":"Bearer xyz","message":"key sk-123 was rejected"}`
+	got := Redact(text, "sk-123", "")
+	for _, secret := range []string{"abc", "xyz", "sk-123"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Redact left %q in %s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "was rejected") {
+		t.Errorf("Redact removed more than the secrets: %s", got)
+	}
+}
@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Default provider base URLs. API paths such as /v1/messages are appended.
+const (
+	ANTHROPIC_BASE_URL = "https://api.anthropic.com"
+	OPENAI_BASE_URL    = "https://api.openai.com"
+	GEMINI_BASE_URL    = "https://generativelanguage.googleapis.com"
+)
+
+// ValidateBaseURL checks that raw is an absolute http(s) URL usable as a
+// provider base URL and returns it without a trailing slash.
+func ValidateBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: missing host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base URL %q: must not contain a query or fragment", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
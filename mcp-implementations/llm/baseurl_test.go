@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestsGoToBaseURL(t *testing.T) {
+	anthropic := newFakeProvider(t, nil)
+	openai := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, "ok")
+	})
+
+	a := NewAnthropicSamplingHandler("key")
+	a.Retry = RetryPolicy{}
+	// A trailing slash and a path prefix, as a gateway might use
+	if err := a.SetBaseURL(anthropic.URL + "/anthropic/"); err != nil {
+		t.Fatal(err)
+	}
+	o := NewOpenAISamplingHandler("key")
+	o.Retry = RetryPolicy{}
+	if err := o.SetBaseURL(openai.URL + "/openai"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := anthropic.Requests()[0]; got.Method != "POST" || got.Path != "/anthropic/v1/messages" {
+		t.Errorf("Anthropic request went to %s %s, want POST /anthropic/v1/messages", got.Method, got.Path)
+	}
+	if got := openai.Requests()[0].Path; got != "/openai/v1/chat/completions" {
+		t.Errorf("OpenAI request went to %s, want /openai/v1/chat/completions", got)
+	}
+}
+
+func TestDefaultBaseURLs(t *testing.T) {
+	if got := NewAnthropicSamplingHandler("key").BaseURL; got != ANTHROPIC_BASE_URL {
+		t.Errorf("Anthropic default base URL %q", got)
+	}
+	if got := NewOpenAISamplingHandler("key").BaseURL; got != OPENAI_BASE_URL {
+		t.Errorf("OpenAI default base URL %q", got)
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	valid := map[string]string{
+		"https://gateway.example.com":      "https://gateway.example.com",
+		"http://localhost:8080/anthropic/": "http://localhost:8080/anthropic",
+	}
+	for raw, want := range valid {
+		got, err := ValidateBaseURL(raw)
+		if err != nil || got != want {
+			t.Errorf("ValidateBaseURL(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+
+	invalid := map[string]string{
+		"ftp://example.com":            "scheme",
+		"https://":                     "missing host",
+		"https://example.com/?key=abc": "query",
+		"gateway.example.com":          "scheme",
+	}
+	for raw, reason := range invalid {
+		if _, err := ValidateBaseURL(raw); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("ValidateBaseURL(%q) = %v, want an error about the %s", raw, err, reason)
+		}
+	}
+
+	h := NewAnthropicSamplingHandler("key")
+	if err := h.SetBaseURL("not a url"); err == nil || h.BaseURL != ANTHROPIC_BASE_URL {
+		t.Errorf("SetBaseURL accepted an invalid URL or changed the base URL to %q", h.BaseURL)
+	}
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strings"
+)
+
+// TokenCeilings maps model IDs to the most output tokens each accepts. A
+// key also covers the models it is a prefix of, so "claude-3-5-haiku"
+// applies to every dated release; the longest matching key wins.
+type TokenCeilings map[string]int
+
+// DefaultTokenCeilings are the published output limits of the models the
+// handlers are commonly pointed at. LoadTokenCeilings layers a file on top.
+var DefaultTokenCeilings = TokenCeilings{
+	"claude-3-haiku":    4096,
+	"claude-3-sonnet":   4096,
+	"claude-3-opus":     4096,
+	"claude-3-5-haiku":  8192,
+	"claude-3-5-sonnet": 8192,
+	"claude-3-7-sonnet": 64000,
+	"claude-sonnet-4":   64000,
+	"claude-opus-4":     32000,
+	"gpt-3.5-turbo":     4096,
+	"gpt-4":             8192,
+	"gpt-4-turbo":       4096,
+	"gpt-4o":            16384,
+	"gpt-4.1":           32768,
+	"gemini-1.5":        8192,
+	"gemini-2.0":        8192,
+}
+
+// LoadTokenCeilings reads a JSON object of model ID (or prefix) to output
+// token limit from path and returns base with those entries added or
+// replaced.
+func LoadTokenCeilings(path string, base TokenCeilings) (TokenCeilings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fromFile map[string]int
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for model, ceiling := range fromFile {
+		if ceiling <= 0 {
+			return nil, fmt.Errorf("%s: ceiling for %q must be positive", path, model)
+		}
+	}
+
+	ceilings := maps.Clone(base)
+	if ceilings == nil {
+		ceilings = TokenCeilings{}
+	}
+	maps.Copy(ceilings, fromFile)
+	return ceilings, nil
+}
+
+// Ceiling returns the output token limit for model, if one is known.
+func (c TokenCeilings) Ceiling(model string) (int, bool) {
+	if ceiling, ok := c[model]; ok {
+		return ceiling, true
+	}
+	var best string
+	for prefix := range c {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return c[best], true
+}
+
+// clamp lowers maxTokens to model's ceiling, logging when it does, since
+// the provider would reject the request outright.
+func (c TokenCeilings) clamp(model string, maxTokens int) int {
+	ceiling, ok := c.Ceiling(model)
+	if !ok || maxTokens <= ceiling {
+		return maxTokens
+	}
+	log.Printf("⚠️  Clamping max_tokens from %d to %d, the most %s allows", maxTokens, ceiling, model)
+	return ceiling
+}
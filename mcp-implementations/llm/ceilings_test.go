@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenCeilingsMatchLongestPrefix(t *testing.T) {
+	tests := map[string]int{
+		"claude-3-5-haiku-20241022": 8192,
+		"claude-3-haiku-20240307":   4096,
+		"gpt-4o-mini":               16384,
+		"gpt-4-turbo-2024-04-09":    4096,
+		"gpt-4":                     8192,
+	}
+	for model, want := range tests {
+		if got, ok := DefaultTokenCeilings.Ceiling(model); !ok || got != want {
+			t.Errorf("Ceiling(%q) = %d, %t; want %d", model, got, ok, want)
+		}
+	}
+	if got, ok := DefaultTokenCeilings.Ceiling("local-llama"); ok {
+		t.Errorf("Ceiling of an unknown model = %d, want none", got)
+	}
+}
+
+func TestAnthropicClampsMaxTokensToCeiling(t *testing.T) {
+	logs := captureLogs(t)
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.Aliases = ModelAliases{"small": "small-model"}
+	h.Model = "small"
+	h.MaxTokens = TokenCeilings{"small-model": 1024}
+
+	for _, maxTokens := range []int{2000, 500} {
+		request := samplingRequest("hello", nil)
+		request.MaxTokens = maxTokens
+		if _, err := h.CreateMessage(context.Background(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests := p.Requests()
+	if got := requests[0].JSON(t)["max_tokens"]; got != float64(1024) {
+		t.Errorf("max_tokens 2000 was sent as %v, want the ceiling 1024", got)
+	}
+	if got := requests[1].JSON(t)["max_tokens"]; got != float64(500) {
+		t.Errorf("max_tokens 500 was sent as %v, want it unchanged under the ceiling", got)
+	}
+	if !strings.Contains(logs.String(), "Clamping max_tokens from 2000 to 1024, the most small-model allows") {
+		t.Errorf("the clamp was not logged:\n%s", logs)
+	}
+	if strings.Count(logs.String(), "Clamping") != 1 {
+		t.Errorf("a request under the ceiling was logged as clamped:\n%s", logs)
+	}
+}
+
+func TestOpenAIClampsMaxTokensToCeiling(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, "ok")
+	})
+	h := newTestOpenAI(p)
+	h.Model = "fast"
+
+	request := samplingRequest("hello", nil)
+	request.MaxTokens = 100000
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Requests()[0].JSON(t)["max_tokens"]; got != float64(16384) {
+		t.Errorf("max_tokens was sent as %v, want the gpt-4o ceiling 16384 for %s", got, DefaultOpenAIAliases["fast"])
+	}
+}
+
+func TestNilCeilingsSendMaxTokensUnchanged(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.MaxTokens = nil
+
+	request := samplingRequest("hello", nil)
+	request.MaxTokens = 100000
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Requests()[0].JSON(t)["max_tokens"]; got != float64(100000) {
+		t.Errorf("max_tokens was sent as %v, want it unchanged without ceilings", got)
+	}
+}
+
+func TestFallbackModelGetsItsOwnCeiling(t *testing.T) {
+	p := newFakeProvider(t, retireModel(t, "big-model", anthropicModelNotFound, writeAnthropicAnswer))
+	h := newTestAnthropic(p)
+	h.Aliases = ModelAliases{"big": "big-model", "small": "small-model"}
+	h.Model = "big"
+	h.FallbackModel = "small"
+	h.MaxTokens = TokenCeilings{"big-model": 8000, "small-model": 1024}
+
+	request := samplingRequest("hello", nil)
+	request.MaxTokens = 4000
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	if got := requests[0].JSON(t)["max_tokens"]; got != float64(4000) {
+		t.Errorf("first request sent max_tokens %v, want 4000", got)
+	}
+	if got := requests[1].JSON(t)["max_tokens"]; got != float64(1024) {
+		t.Errorf("fallback request sent max_tokens %v, want the fallback's ceiling 1024", got)
+	}
+}
+
+func TestLoadTokenCeilings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ceilings.json")
+	if err := os.WriteFile(path, []byte(`{"gpt-4o": 4096, "local-llama": 2048}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ceilings, err := LoadTokenCeilings(path, DefaultTokenCeilings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for model, want := range map[string]int{"gpt-4o-mini": 4096, "local-llama-3": 2048, "claude-opus-4-1": 32000} {
+		if got, _ := ceilings.Ceiling(model); got != want {
+			t.Errorf("Ceiling(%q) = %d, want %d", model, got, want)
+		}
+	}
+	if got, _ := DefaultTokenCeilings.Ceiling("gpt-4o"); got != 16384 {
+		t.Errorf("loading a file changed the defaults: gpt-4o = %d", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"gpt-4o": 0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTokenCeilings(path, DefaultTokenCeilings); err == nil || !strings.Contains(err.Error(), `ceiling for "gpt-4o" must be positive`) {
+		t.Errorf("LoadTokenCeilings accepted a zero ceiling: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// isModelNotFound reports whether err is the provider rejecting the
+// requested model: a 404 whose body mentions the model. Other 404s, such as
+// a wrong base URL, are left alone.
+func isModelNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(statusErr.Body), "model")
+}
+
+// sendWithFallback sends the request newRequest builds for model, with
+// retries. If the provider reports that model does not exist, the request
+// is sent once more for fallback, when that is a different model. It
+// returns the response and the model it answers for.
+func sendWithFallback(ctx context.Context, client *http.Client, limiter *RateLimiter, policy RetryPolicy,
+	model, fallback string, newRequest func(model string) (*http.Request, error)) (*http.Response, string, error) {
+	send := func(model string) (*http.Response, error) {
+		return sendWithRetry(ctx, client, limiter, policy, func() (*http.Request, error) {
+			return newRequest(model)
+		})
+	}
+
+	resp, err := send(model)
+	if err == nil || fallback == "" || fallback == model || !isModelNotFound(err) {
+		return resp, model, err
+	}
+
+	log.Printf("⚠️  Model %s not found; falling back to %s", model, fallback)
+	resp, err = send(fallback)
+	return resp, fallback, err
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// retireModel answers 404 with the provider's model-not-found error for
+// requests to model, and with answer for any other model.
+func retireModel(t *testing.T, model, notFound string, answer func(w http.ResponseWriter, text string)) func(w http.ResponseWriter, r *http.Request, body []byte) {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if (recordedRequest{Body: body}).JSON(t)["model"] == model {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(notFound))
+			return
+		}
+		answer(w, "fallback answer")
+	}
+}
+
+// withRetired returns aliases plus a "retired" alias for model, which
+// the provider no longer serves.
+func withRetired(aliases ModelAliases, model string) ModelAliases {
+	withRetired := ModelAliases{"retired": model}
+	for alias, id := range aliases {
+		withRetired[alias] = id
+	}
+	return withRetired
+}
+
+const (
+	anthropicModelNotFound = `{"type": "error", "error": {"type": "not_found_error", "message": "model: claude-retired"}}`
+	openAIModelNotFound    = `{"error": {"message": "The model ` + "`gpt-retired`" + ` does not exist", "type": "invalid_request_error", "code": "model_not_found"}}`
+)
+
+func TestAnthropicFallsBackWhenModelNotFound(t *testing.T) {
+	logs := captureLogs(t)
+	p := newFakeProvider(t, retireModel(t, "claude-retired", anthropicModelNotFound, writeAnthropicAnswer))
+	h := newTestAnthropic(p)
+	h.Aliases = withRetired(DefaultAnthropicAliases, "claude-retired")
+	h.Model = "retired"
+	h.FallbackModel = "fast"
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatalf("the fallback model should have answered: %v", err)
+	}
+
+	requests := p.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d provider requests, want the original and one fallback", len(requests))
+	}
+	if model := requests[1].JSON(t)["model"]; model != DefaultAnthropicAliases["fast"] {
+		t.Errorf("fallback request used model %v, want the %q alias resolved to %s", model, "fast", DefaultAnthropicAliases["fast"])
+	}
+	if result.Meta == nil {
+		t.Fatal("no _meta reporting the fallback")
+	}
+	fallback, _ := result.Meta.AdditionalFields[MetadataModelFallback].(map[string]any)
+	if fallback["requested"] != "claude-retired" || fallback["used"] != DefaultAnthropicAliases["fast"] {
+		t.Errorf("_meta.%s = %v", MetadataModelFallback, fallback)
+	}
+	if !strings.Contains(logs.String(), "Model claude-retired not found; falling back to "+DefaultAnthropicAliases["fast"]) {
+		t.Errorf("the substitution was not logged:\n%s", logs)
+	}
+}
+
+func TestOpenAIFallsBackWhenModelNotFound(t *testing.T) {
+	p := newFakeProvider(t, retireModel(t, "gpt-retired", openAIModelNotFound, writeOpenAIAnswer))
+	h := newTestOpenAI(p)
+	h.Aliases = withRetired(DefaultOpenAIAliases, "gpt-retired")
+	h.Model = "retired"
+	h.FallbackModel = "gpt-4o-mini"
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatalf("the fallback model should have answered: %v", err)
+	}
+	if model := p.Requests()[1].JSON(t)["model"]; model != "gpt-4o-mini" {
+		t.Errorf("fallback request used model %v, want gpt-4o-mini", model)
+	}
+	fallback, _ := result.Meta.AdditionalFields[MetadataModelFallback].(map[string]any)
+	if fallback["used"] != "gpt-4o-mini" {
+		t.Errorf("_meta.%s = %v", MetadataModelFallback, fallback)
+	}
+}
+
+func TestModelNotFoundFailsWithoutFallback(t *testing.T) {
+	p := newFakeProvider(t, retireModel(t, "claude-retired", anthropicModelNotFound, writeAnthropicAnswer))
+	h := newTestAnthropic(p)
+	h.Aliases = withRetired(DefaultAnthropicAliases, "claude-retired")
+	h.Model = "retired"
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if !isModelNotFound(err) {
+		t.Errorf("err = %v, want the model-not-found error", err)
+	}
+	if n := len(p.Requests()); n != 1 {
+		t.Errorf("got %d provider requests with no fallback set, want 1", n)
+	}
+}
+
+func TestOtherNotFoundDoesNotFallBack(t *testing.T) {
+	// A wrong base URL also answers 404, but changing the model cannot help
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		http.NotFound(w, r)
+	})
+	h := newTestAnthropic(p)
+	h.FallbackModel = "fast"
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want the 404", err)
+	}
+	if n := len(p.Requests()); n != 1 {
+		t.Errorf("got %d provider requests, want no fallback for a plain 404", n)
+	}
+}
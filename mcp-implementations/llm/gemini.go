@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GEMINI_MODEL is the model the Gemini handler samples with.
+const GEMINI_MODEL = "gemini-1.5-pro"
+
+// geminiStopReasons maps Gemini finish reasons to the MCP stop reasons the
+// server checks for. Others, such as "SAFETY", are passed on unchanged.
+var geminiStopReasons = map[string]string{
+	"STOP":       "endTurn",
+	"MAX_TOKENS": "maxTokens",
+}
+
+// GeminiSamplingHandler implements client.SamplingHandler using the Gemini
+// generateContent API. It does not stream and does not declare tools, so
+// -stream is ignored and a model offered tools answers without them.
+type GeminiSamplingHandler struct {
+	APIKey     string
+	HTTPClient *http.Client
+
+	// BaseURL is where provider API paths are sent, so requests can go
+	// through a compatible proxy or gateway. Set it with SetBaseURL.
+	BaseURL string
+
+	// Limiter paces requests to the provider's rate limit. Nil means unlimited.
+	Limiter *RateLimiter
+
+	// MaxResponseBytes caps the response body size read from the provider.
+	// Zero means DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+
+	// Headers are extra headers sent with every provider request, e.g. for
+	// an API gateway. They cannot replace the handler's own headers.
+	Headers map[string]string
+
+	// Model is the alias or model ID used when the server sends no model
+	// hint. Empty means GEMINI_MODEL.
+	Model string
+
+	// Aliases resolves model hints and Model at request time.
+	Aliases ModelAliases
+
+	// FallbackModel is the alias or model ID a request is sent again with
+	// when the provider says the requested model does not exist. Empty
+	// disables the fallback.
+	FallbackModel string
+
+	// Retry decides which failed provider requests are sent again.
+	Retry RetryPolicy
+
+	// MaxTokens caps each request's maxOutputTokens at the selected model's
+	// output limit. Nil sends max_tokens unchanged.
+	MaxTokens TokenCeilings
+
+	// SystemPrefix is put before the system prompt of every request, for
+	// framing this provider's models respond better to. See SystemPrompts.
+	SystemPrefix string
+
+	// SafetySettings maps harm categories to the threshold at which the
+	// provider blocks content. Nil sends none, leaving the provider's
+	// defaults. See LoadSafetySettings.
+	SafetySettings SafetySettings
+}
+
+// GeminiRequest represents the structure for generateContent requests
+type GeminiRequest struct {
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent        `json:"contents"`
+	GenerationConfig  GeminiGenerationConfig `json:"generationConfig"`
+	SafetySettings    []GeminiSafetySetting  `json:"safetySettings,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+type GeminiInlineData struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature     float64 `json:"temperature"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+	Seed            *int    `json:"seed,omitempty"`
+}
+
+// GeminiSafetySetting sets the blocking threshold of one harm category.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// GeminiResponse represents the structure for generateContent responses
+type GeminiResponse struct {
+	Candidates []struct {
+		Content      GeminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	// PromptFeedback explains a request that was blocked before any
+	// candidate was generated.
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+func NewGeminiSamplingHandler(apiKey string) *GeminiSamplingHandler {
+	return &GeminiSamplingHandler{
+		APIKey:    apiKey,
+		BaseURL:   GEMINI_BASE_URL,
+		Retry:     DefaultRetryPolicy,
+		Aliases:   DefaultGeminiAliases,
+		MaxTokens: DefaultTokenCeilings,
+		HTTPClient: &http.Client{
+			Timeout:   2 * time.Minute,
+			Transport: newTransport(),
+		},
+	}
+}
+
+// fallbackModel resolves FallbackModel when it is an alias. Any other name
+// is used as a model ID, so the fallback can be a model no alias names.
+func (h *GeminiSamplingHandler) fallbackModel() string {
+	if model, ok := h.Aliases[h.FallbackModel]; ok {
+		return model
+	}
+	return h.FallbackModel
+}
+
+// SetBaseURL validates and sets the provider base URL.
+func (h *GeminiSamplingHandler) SetBaseURL(raw string) error {
+	baseURL, err := ValidateBaseURL(raw)
+	if err != nil {
+		return err
+	}
+	h.BaseURL = baseURL
+	return nil
+}
+
+func (h *GeminiSamplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	log.Printf("📨 Received sampling request with %d messages", len(request.Messages))
+
+	if len(request.Messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	apiKey := h.APIKey
+	if callerKey := metadataString(request.Metadata, MetadataAPIKey); callerKey != "" {
+		apiKey = callerKey
+		log.Println("Using caller-supplied API key for this request")
+	}
+
+	geminiReq := GeminiRequest{
+		GenerationConfig: GeminiGenerationConfig{Temperature: request.Temperature},
+		SafetySettings:   h.SafetySettings.list(),
+	}
+	if system := prefixSystemPrompt(h.SystemPrefix, request.SystemPrompt); system != "" {
+		geminiReq.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: system}}}
+	}
+	for _, mcpMsg := range request.Messages {
+		var part GeminiPart
+
+		switch mcpContent := mcpMsg.Content.(type) {
+		case mcp.TextContent:
+			part.Text = mcpContent.Text
+		case mcp.ImageContent:
+			part.InlineData = &GeminiInlineData{MIMEType: mcpContent.MIMEType, Data: mcpContent.Data}
+		default:
+			part.Text = fmt.Sprintf("%v", mcpContent)
+		}
+
+		// Gemini calls the assistant role "model"
+		role := "user"
+		if mcpMsg.Role == mcp.RoleAssistant {
+			role = "model"
+		}
+		geminiReq.Contents = append(geminiReq.Contents, GeminiContent{Role: role, Parts: []GeminiPart{part}})
+	}
+	if seed, ok := metadataInt(request.Metadata, MetadataSeed); ok {
+		geminiReq.GenerationConfig.Seed = &seed
+	}
+
+	requested := selectModel(request, h.Aliases, h.Model, GEMINI_MODEL)
+	geminiReq.GenerationConfig.MaxOutputTokens = h.MaxTokens.clamp(requested, request.MaxTokens)
+
+	log.Printf("Sending request to Gemini API (model: %s, tokens: %d)", requested, geminiReq.GenerationConfig.MaxOutputTokens)
+
+	resp, model, err := sendWithFallback(ctx, h.HTTPClient, h.Limiter, h.Retry, requested, h.fallbackModel(), func(model string) (*http.Request, error) {
+		geminiReq.GenerationConfig.MaxOutputTokens = h.MaxTokens.clamp(model, request.MaxTokens)
+		reqBody, err := json.Marshal(geminiReq)
+		if err != nil {
+			return nil, err
+		}
+		endpoint := h.BaseURL + "/v1beta/models/" + url.PathEscape(model) + ":generateContent"
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+		setExtraHeaders(httpReq, h.Headers)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readResponse(resp.Body, h.MaxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		if typeErr := unexpectedContentType(resp, respBody, h.APIKey, apiKey); typeErr != nil {
+			return nil, typeErr
+		}
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		if reason := geminiResp.PromptFeedback.BlockReason; reason != "" {
+			return nil, fmt.Errorf("the request was blocked by the provider's safety settings (%s)", reason)
+		}
+		return nil, fmt.Errorf("response contained no candidates")
+	}
+	candidate := geminiResp.Candidates[0]
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	stopReason := candidate.FinishReason
+	if mapped, ok := geminiStopReasons[stopReason]; ok {
+		stopReason = mapped
+	}
+	// The response names the exact model version, when it says
+	answeredBy := geminiResp.ModelVersion
+	if answeredBy == "" {
+		answeredBy = model
+	}
+
+	log.Printf("Received response from Gemini API (model: %s, input tokens: %d, output tokens: %d)",
+		answeredBy, geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+
+	result := &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role: mcp.RoleAssistant,
+			Content: mcp.TextContent{
+				Type: "text",
+				Text: text.String(),
+			},
+		},
+		Model:      answeredBy,
+		StopReason: stopReason,
+	}
+
+	meta := map[string]any{}
+	if model != requested {
+		meta[MetadataModelFallback] = map[string]any{"requested": requested, "used": model}
+	}
+	if metadataBool(request.Metadata, MetadataDebugRaw) {
+		meta[MetadataRawResponse] = Redact(string(respBody), h.APIKey, apiKey)
+	}
+	if len(meta) > 0 {
+		result.Meta = mcp.NewMetaFromMap(meta)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestGeminiRequestShape(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeGeminiAnswer(w, "fake answer")
+	})
+	h := newTestGemini(p)
+
+	request := samplingRequest("hello", map[string]any{MetadataSeed: 7})
+	request.SystemPrompt = "Summarize this."
+	result, err := h.CreateMessage(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := p.Requests()[0]
+	if sent.Path != "/v1beta/models/"+GEMINI_MODEL+":generateContent" {
+		t.Errorf("path = %q, want the default model's generateContent endpoint", sent.Path)
+	}
+	if got := sent.Header.Get("x-goog-api-key"); got != "handler-key" {
+		t.Errorf("x-goog-api-key = %q, want the handler's key", got)
+	}
+	body := sent.JSON(t)
+	system := body["systemInstruction"].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if system["text"] != "Summarize this." {
+		t.Errorf("systemInstruction = %v, want the tool's system prompt", system)
+	}
+	content := body["contents"].([]any)[0].(map[string]any)
+	if content["role"] != "user" || content["parts"].([]any)[0].(map[string]any)["text"] != "hello" {
+		t.Errorf("contents[0] = %v, want the user's message", content)
+	}
+	config := body["generationConfig"].(map[string]any)
+	if config["maxOutputTokens"] != float64(100) || config["seed"] != float64(7) {
+		t.Errorf("generationConfig = %v, want maxOutputTokens 100 and seed 7", config)
+	}
+
+	if text := result.Content.(mcp.TextContent).Text; text != "fake answer" {
+		t.Errorf("text = %q, want the candidate's text", text)
+	}
+	if result.Model != "gemini-test" || result.StopReason != "endTurn" {
+		t.Errorf("model, stop reason = %q, %q, want the model version and endTurn", result.Model, result.StopReason)
+	}
+}
+
+func TestGeminiMapsMaxTokensStopReason(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "cut"}]}, "finishReason": "MAX_TOKENS"}]}`))
+	})
+
+	result, err := newTestGemini(p).CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StopReason != "maxTokens" {
+		t.Errorf("stop reason = %q, want maxTokens so the server can continue the answer", result.StopReason)
+	}
+}
+
+func TestGeminiBlockedPromptIsAnError(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"promptFeedback": {"blockReason": "SAFETY"}}`))
+	})
+
+	_, err := newTestGemini(p).CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("err = %v, want the block reason", err)
+	}
+}
+
+func TestGeminiSendsSafetySettings(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeGeminiAnswer(w, "ok")
+	})
+	h := newTestGemini(p)
+	h.SafetySettings = SafetySettings{
+		"HARM_CATEGORY_HATE_SPEECH": "BLOCK_LOW_AND_ABOVE",
+		"HARM_CATEGORY_HARASSMENT":  "BLOCK_ONLY_HIGH",
+	}
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, _ := p.Requests()[0].JSON(t)["safetySettings"].([]any)
+	want := []map[string]any{
+		{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_ONLY_HIGH"},
+		{"category": "HARM_CATEGORY_HATE_SPEECH", "threshold": "BLOCK_LOW_AND_ABOVE"},
+	}
+	if len(settings) != len(want) {
+		t.Fatalf("safetySettings = %v, want %v", settings, want)
+	}
+	for i, setting := range settings {
+		got := setting.(map[string]any)
+		if got["category"] != want[i]["category"] || got["threshold"] != want[i]["threshold"] {
+			t.Errorf("safetySettings[%d] = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestGeminiOmitsUnsetSafetySettings(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeGeminiAnswer(w, "ok")
+	})
+
+	if _, err := newTestGemini(p).CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if settings, ok := p.Requests()[0].JSON(t)["safetySettings"]; ok {
+		t.Errorf("safetySettings = %v, want none so the provider's defaults apply", settings)
+	}
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// reservedHeaders are set by the handlers themselves. Extra headers may not
+// replace them, so a gateway header can't silently swap out the API key or
+// break the request encoding.
+var reservedHeaders = map[string]bool{
+	"Content-Type":      true,
+	"Authorization":     true,
+	"X-Api-Key":         true,
+	"X-Goog-Api-Key":    true,
+	"Anthropic-Version": true,
+}
+
+// ValidateHeaders reports an error if any extra header would replace one the
+// handlers set themselves. Handlers skip such headers anyway; validating at
+// startup turns the silent skip into a clear configuration error.
+func ValidateHeaders(headers map[string]string) error {
+	for name := range headers {
+		if canonical := http.CanonicalHeaderKey(name); reservedHeaders[canonical] {
+			return fmt.Errorf("header %s is set by the sampling handler and cannot be overridden", canonical)
+		}
+	}
+	return nil
+}
+
+// setExtraHeaders adds configured headers to an outbound provider request,
+// skipping reserved ones.
+func setExtraHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedHeaders[canonical] {
+			continue
+		}
+		req.Header.Set(canonical, value)
+	}
+}
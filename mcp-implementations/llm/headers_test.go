@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestExtraHeadersAreSent(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.Headers = map[string]string{
+		"x-org-id":            "org-123",
+		"Proxy-Authorization": "Basic cHJveHk=",
+		// Reserved headers are skipped, not sent in place of the handler's
+		"x-api-key":    "gateway-key",
+		"content-type": "text/plain",
+	}
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	header := p.Requests()[0].Header
+	if got := header.Get("X-Org-Id"); got != "org-123" {
+		t.Errorf("X-Org-Id = %q, want org-123", got)
+	}
+	if got := header.Get("Proxy-Authorization"); got != "Basic cHJveHk=" {
+		t.Errorf("Proxy-Authorization = %q", got)
+	}
+	if got := header.Get("X-Api-Key"); got != "handler-key" {
+		t.Errorf("X-Api-Key = %q, want the handler's key", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestExtraHeadersOnOpenAI(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, "ok")
+	})
+	h := newTestOpenAI(p)
+	h.Headers = map[string]string{"OpenAI-Organization": "org-456", "Authorization": "Bearer other"}
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	header := p.Requests()[0].Header
+	if got := header.Get("OpenAI-Organization"); got != "org-456" {
+		t.Errorf("OpenAI-Organization = %q, want org-456", got)
+	}
+	if got := header.Get("Authorization"); got != "Bearer handler-key" {
+		t.Errorf("Authorization = %q, want the handler's key", got)
+	}
+}
+
+func TestValidateHeaders(t *testing.T) {
+	if err := ValidateHeaders(map[string]string{"X-Org-Id": "org"}); err != nil {
+		t.Errorf("a custom header was rejected: %v", err)
+	}
+	for _, name := range []string{"authorization", "X-API-KEY", "Content-Type", "anthropic-version", "x-goog-api-key"} {
+		if err := ValidateHeaders(map[string]string{name: "value"}); err == nil {
+			t.Errorf("reserved header %s was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// fakeProvider is an httptest server standing in for a provider API. It
+// records each request and answers with respond, or with a plain
+// Anthropic answer when respond is nil.
+type fakeProvider struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []recordedRequest
+	respond  func(w http.ResponseWriter, r *http.Request, body []byte)
+}
+
+// recordedRequest is one request the fake provider received.
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the request body.
+func (r recordedRequest) JSON(t *testing.T) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(r.Body, &body); err != nil {
+		t.Fatalf("request body is not JSON: %v\n%s", err, r.Body)
+	}
+	return body
+}
+
+func newFakeProvider(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, body []byte)) *fakeProvider {
+	t.Helper()
+	p := &fakeProvider{respond: respond}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p.mu.Lock()
+		p.requests = append(p.requests, recordedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		p.mu.Unlock()
+		if p.respond != nil {
+			p.respond(w, r, body)
+			return
+		}
+		writeAnthropicAnswer(w, "fake answer")
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Requests returns the requests received so far.
+func (p *fakeProvider) Requests() []recordedRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]recordedRequest(nil), p.requests...)
+}
+
+// writeAnthropicAnswer writes a finished Anthropic messages response.
+func writeAnthropicAnswer(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AnthropicResponse{
+		ID:         "msg_test",
+		Type:       "message",
+		Role:       "assistant",
+		Content:    []AnthropicTextContent{{Type: "text", Text: text}},
+		Model:      "claude-test",
+		StopReason: "end_turn",
+		Usage:      AnthropicUsage{InputTokens: 10, OutputTokens: 5},
+	})
+}
+
+// writeOpenAIAnswer writes a finished OpenAI chat completion response.
+func writeOpenAIAnswer(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"model":   "gpt-test",
+		"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": text}, "finish_reason": "stop"}},
+		"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+}
+
+// writeGeminiAnswer writes a finished Gemini generateContent response.
+func writeGeminiAnswer(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"candidates":    []map[string]any{{"content": map[string]any{"role": "model", "parts": []map[string]any{{"text": text}}}, "finishReason": "STOP"}},
+		"usageMetadata": map[string]any{"promptTokenCount": 10, "candidatesTokenCount": 5},
+		"modelVersion":  "gemini-test",
+	})
+}
+
+// newTestAnthropic returns an Anthropic handler sending to p, without
+// retries so failures show at once.
+func newTestAnthropic(p *fakeProvider) *AnthropicSamplingHandler {
+	h := NewAnthropicSamplingHandler("handler-key")
+	h.BaseURL = p.URL
+	h.Retry = RetryPolicy{}
+	return h
+}
+
+// newTestOpenAI returns an OpenAI handler sending to p, without retries.
+func newTestOpenAI(p *fakeProvider) *OpenAISamplingHandler {
+	h := NewOpenAISamplingHandler("handler-key")
+	h.BaseURL = p.URL
+	h.Retry = RetryPolicy{}
+	return h
+}
+
+// newTestGemini returns a Gemini handler sending to p, without retries.
+func newTestGemini(p *fakeProvider) *GeminiSamplingHandler {
+	h := NewGeminiSamplingHandler("handler-key")
+	h.BaseURL = p.URL
+	h.Retry = RetryPolicy{}
+	return h
+}
+
+// samplingRequest is a one-message text sampling request.
+func samplingRequest(text string, metadata map[string]any) mcp.CreateMessageRequest {
+	request := mcp.CreateMessageRequest{
+		CreateMessageParams: mcp.CreateMessageParams{
+			Messages: []mcp.SamplingMessage{
+				{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: text}},
+			},
+			MaxTokens:   100,
+			Temperature: 0.3,
+		},
+	}
+	if metadata != nil {
+		request.Metadata = metadata
+	}
+	return request
+}
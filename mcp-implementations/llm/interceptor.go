@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SamplingFunc sends one sampling request. It has the shape of
+// client.SamplingHandler's CreateMessage.
+type SamplingFunc func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+
+// Interceptor wraps a SamplingFunc. It can change the request before
+// calling next, change or replace the result after, or answer without
+// calling next at all, e.g. from a cache:
+//
+//	func(next llm.SamplingFunc) llm.SamplingFunc {
+//		return func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+//			request.SystemPrompt += " Answer in French."
+//			return next(ctx, request)
+//		}
+//	}
+type Interceptor func(next SamplingFunc) SamplingFunc
+
+// interceptedHandler is a SamplingHandler whose requests pass through a
+// chain of interceptors.
+type interceptedHandler struct {
+	send SamplingFunc
+}
+
+func (h *interceptedHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	return h.send(ctx, request)
+}
+
+// Chain returns a SamplingHandler that passes each request through the
+// interceptors before handler sends it. The first interceptor is the
+// outermost: it sees the request first and the result last.
+func Chain(handler client.SamplingHandler, interceptors ...Interceptor) client.SamplingHandler {
+	send := SamplingFunc(handler.CreateMessage)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		send = interceptors[i](send)
+	}
+	return &interceptedHandler{send: send}
+}
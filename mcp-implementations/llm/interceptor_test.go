@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// appendSystem is an interceptor that adds text to the system prompt.
+func appendSystem(text string) Interceptor {
+	return func(next SamplingFunc) SamplingFunc {
+		return func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			request.SystemPrompt += text
+			return next(ctx, request)
+		}
+	}
+}
+
+// wrapResult is an interceptor that puts brackets around the result text.
+func wrapResult(next SamplingFunc) SamplingFunc {
+	return func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		result, err := next(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		text := result.Content.(mcp.TextContent)
+		text.Text = "[" + text.Text + "]"
+		result.Content = text
+		return result, nil
+	}
+}
+
+func TestInterceptorMutatesRequest(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := Chain(newTestAnthropic(p), appendSystem(" Answer in French."))
+
+	request := samplingRequest("hello", nil)
+	request.SystemPrompt = "Summarize this."
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.Requests()[0].JSON(t)["system"]; got != "Summarize this. Answer in French." {
+		t.Errorf("provider got system %q, want the interceptor's change", got)
+	}
+}
+
+func TestInterceptorWrapsResult(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := Chain(newTestAnthropic(p), wrapResult)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Content.(mcp.TextContent).Text; got != "[fake answer]" {
+		t.Errorf("result text = %q, want the wrapped answer", got)
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(next SamplingFunc) SamplingFunc {
+			return func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+				calls = append(calls, name+"-in")
+				result, err := next(ctx, request)
+				calls = append(calls, name+"-out")
+				return result, err
+			}
+		}
+	}
+	p := newFakeProvider(t, nil)
+	h := Chain(newTestAnthropic(p), record("first"), record("second"))
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "first-in,second-in,second-out,first-out" {
+		t.Errorf("calls ran as %s, want the first interceptor outermost", got)
+	}
+}
+
+func TestInterceptorCanAnswerWithoutProvider(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	cached := func(next SamplingFunc) SamplingFunc {
+		return func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			return &mcp.CreateMessageResult{
+				SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.TextContent{Type: "text", Text: "from cache"}},
+				Model:           "cache",
+			}, nil
+		}
+	}
+	h := Chain(newTestAnthropic(p), wrapResult, cached)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Content.(mcp.TextContent).Text; got != "[from cache]" {
+		t.Errorf("result text = %q, want the cached answer through the outer interceptor", got)
+	}
+	if n := len(p.Requests()); n != 0 {
+		t.Errorf("provider got %d requests, want none", n)
+	}
+}
+
+func TestChainWithoutInterceptors(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := Chain(newTestAnthropic(p))
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Content.(mcp.TextContent).Text; got != "fake answer" || len(p.Requests()) != 1 {
+		t.Errorf("result text = %q after %d requests, want the provider's answer", got, len(p.Requests()))
+	}
+}
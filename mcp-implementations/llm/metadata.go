@@ -0,0 +1,70 @@
+package llm
+
+// Keys exchanged through sampling request metadata and result _meta. The
+// server sets the request keys in CreateMessageParams.Metadata; handlers
+// answer through CreateMessageResult.Meta.
+const (
+	// MetadataDebugRaw asks the handler to return the provider's raw response.
+	MetadataDebugRaw = "debug_raw"
+	// MetadataRawResponse carries the redacted raw provider response.
+	MetadataRawResponse = "raw_response"
+	// MetadataAPIKey carries a caller's own provider API key, which
+	// replaces the handler's key for that request.
+	MetadataAPIKey = "api_key"
+	// MetadataSeed carries a sampling seed for reproducible output. It is
+	// forwarded to providers that accept one and ignored by the rest.
+	MetadataSeed = "seed"
+	// MetadataTools lists server tools the model may call (see ToolSpec).
+	MetadataTools = "tools"
+	// MetadataToolUse carries the model's tool call in the result _meta.
+	MetadataToolUse = "tool_use"
+	// MetadataCacheUsage carries provider prompt cache token counts in the
+	// result _meta, when the handler uses prompt caching.
+	MetadataCacheUsage = "cache_usage"
+	// MetadataModelFallback reports in the result _meta that the requested
+	// model was not found and the handler's fallback model answered, as
+	// {"requested", "used"}.
+	MetadataModelFallback = "model_fallback"
+	// MetadataPartial reports in the result _meta that a streamed response
+	// was canceled or cut off, so the text is only its beginning, as
+	// {"reason"}.
+	MetadataPartial = "partial"
+)
+
+// metadataBool reads a boolean flag from sampling request metadata. Over
+// HTTP the metadata arrives as a decoded JSON object, so only map values
+// are inspected.
+func metadataBool(metadata any, key string) bool {
+	m, ok := metadata.(map[string]any)
+	if !ok {
+		return false
+	}
+	v, _ := m[key].(bool)
+	return v
+}
+
+// metadataString reads a string value from sampling request metadata.
+func metadataString(metadata any, key string) string {
+	m, ok := metadata.(map[string]any)
+	if !ok {
+		return ""
+	}
+	v, _ := m[key].(string)
+	return v
+}
+
+// metadataInt reads an integer value from sampling request metadata. JSON
+// numbers decode as float64, so both forms are accepted.
+func metadataInt(metadata any, key string) (int, bool) {
+	m, ok := metadata.(map[string]any)
+	if !ok {
+		return 0, false
+	}
+	switch v := m[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
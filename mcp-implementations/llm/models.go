@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ANTHROPIC_MODEL is the model the Anthropic handler samples with when no
+// alias selects another.
+const ANTHROPIC_MODEL = "claude-3-5-sonnet-20241022"
+
+// ModelAliases maps stable names such as "fast" or "smart" to provider
+// model IDs. Tool arguments and defaults name aliases, so a deprecated model
+// is replaced by editing one map instead of every caller.
+type ModelAliases map[string]string
+
+// DefaultAnthropicAliases, DefaultOpenAIAliases and DefaultGeminiAliases are
+// the built-in aliases for each provider. LoadModelAliases layers a file on
+// top of them.
+var (
+	DefaultAnthropicAliases = ModelAliases{
+		"fast":  "claude-3-5-haiku-20241022",
+		"smart": ANTHROPIC_MODEL,
+	}
+	DefaultOpenAIAliases = ModelAliases{
+		"fast":  "gpt-4o-mini",
+		"smart": OPENAI_MODEL,
+	}
+	DefaultGeminiAliases = ModelAliases{
+		"fast":  "gemini-1.5-flash",
+		"smart": GEMINI_MODEL,
+	}
+)
+
+// LoadModelAliases reads a JSON object of alias to model ID from path and
+// returns base with those entries added or replaced, so each environment
+// can pin its own models.
+func LoadModelAliases(path string, base ModelAliases) (ModelAliases, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fromFile map[string]string
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	aliases := ModelAliases{}
+	for alias, model := range base {
+		aliases[alias] = model
+	}
+	for alias, model := range fromFile {
+		aliases[alias] = model
+	}
+	return aliases, nil
+}
+
+// Resolve returns the model ID for name. A name that is not an alias is
+// taken to be a model ID and returned as is. An alias mapped to an empty ID
+// is logged and resolves to "".
+func (a ModelAliases) Resolve(name string) string {
+	model, ok := a[name]
+	if !ok {
+		return name
+	}
+	if model == "" {
+		log.Printf("⚠️  Model alias %q resolves to nothing", name)
+	}
+	return model
+}
+
+// selectModel picks the model for a sampling request: the first model hint
+// from the server, else the handler's configured model, each resolved
+// through aliases. fallback is used when neither names a model.
+func selectModel(request mcp.CreateMessageRequest, aliases ModelAliases, configured, fallback string) string {
+	if prefs := request.ModelPreferences; prefs != nil {
+		for _, hint := range prefs.Hints {
+			if hint.Name == "" {
+				continue
+			}
+			if model := aliases.Resolve(hint.Name); model != "" {
+				return model
+			}
+		}
+	}
+	if configured != "" {
+		if model := aliases.Resolve(configured); model != "" {
+			return model
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestModelAliasesResolve(t *testing.T) {
+	aliases := ModelAliases{"fast": "claude-haiku", "smart": "claude-sonnet", "retired": ""}
+	logs := captureLogs(t)
+
+	tests := map[string]string{
+		"fast":                   "claude-haiku",
+		"smart":                  "claude-sonnet",
+		"claude-haiku":           "claude-haiku",
+		"claude-3-opus-20240229": "claude-3-opus-20240229",
+		"retired":                "",
+	}
+	for name, want := range tests {
+		if got := aliases.Resolve(name); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if !strings.Contains(logs.String(), `Model alias "retired" resolves to nothing`) {
+		t.Errorf("no warning for an alias mapped to nothing:\n%s", logs)
+	}
+	for _, name := range []string{`"fast"`, `"claude-3-opus-20240229"`} {
+		if strings.Contains(logs.String(), name) {
+			t.Errorf("%s was warned about:\n%s", name, logs)
+		}
+	}
+}
+
+func TestLoadModelAliasesLayersOverBase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(path, []byte(`{"smart": "claude-next", "cheap": "claude-mini"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := ModelAliases{"fast": "claude-haiku", "smart": "claude-sonnet"}
+	aliases, err := LoadModelAliases(path, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ModelAliases{"fast": "claude-haiku", "smart": "claude-next", "cheap": "claude-mini"}
+	for alias, model := range want {
+		if aliases[alias] != model {
+			t.Errorf("alias %s = %q, want %q", alias, aliases[alias], model)
+		}
+	}
+	if base["smart"] != "claude-sonnet" {
+		t.Error("LoadModelAliases changed the base map")
+	}
+
+	if err := os.WriteFile(path, []byte(`not json`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModelAliases(path, base); err == nil {
+		t.Error("a malformed alias file was accepted")
+	}
+}
+
+func TestHandlerResolvesModelHint(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.Aliases = ModelAliases{"fast": "claude-haiku", "retired": ""}
+	h.Model = "fast"
+
+	alias := samplingRequest("hello", nil)
+	alias.ModelPreferences = &mcp.ModelPreferences{Hints: []mcp.ModelHint{{Name: "retired"}, {Name: "fast"}}}
+	modelID := samplingRequest("hello", nil)
+	modelID.ModelPreferences = &mcp.ModelPreferences{Hints: []mcp.ModelHint{{Name: "claude-3-opus-20240229"}}}
+	retired := samplingRequest("hello", nil)
+	retired.ModelPreferences = &mcp.ModelPreferences{Hints: []mcp.ModelHint{{Name: "retired"}}}
+
+	tests := []struct {
+		request mcp.CreateMessageRequest
+		want    string
+	}{
+		{alias, "claude-haiku"},
+		{modelID, "claude-3-opus-20240229"},
+		{retired, "claude-haiku"},
+		{samplingRequest("hello", nil), "claude-haiku"},
+	}
+	for _, tt := range tests {
+		if _, err := h.CreateMessage(context.Background(), tt.request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, request := range p.Requests() {
+		if model := request.JSON(t)["model"]; model != tests[i].want {
+			t.Errorf("request %d used model %v, want %s", i, model, tests[i].want)
+		}
+	}
+}
+
+func TestConfiguredModelIDIsSentUnchanged(t *testing.T) {
+	p := newFakeProvider(t, answerEither)
+	h := newTestOpenAI(p)
+	h.Model = "gpt-4-turbo-2024-04-09"
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if model := p.Requests()[0].JSON(t)["model"]; model != "gpt-4-turbo-2024-04-09" {
+		t.Errorf("model = %v, want the configured model ID rather than %s", model, OPENAI_MODEL)
+	}
+}
@@ -0,0 +1,333 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// OPENAI_MODEL is the model the OpenAI handler samples with.
+const OPENAI_MODEL = "gpt-4o"
+
+// OpenAISamplingHandler implements client.SamplingHandler using the OpenAI
+// Chat Completions API.
+type OpenAISamplingHandler struct {
+	APIKey     string
+	HTTPClient *http.Client
+
+	// BaseURL is where provider API paths are sent, so requests can go
+	// through a compatible proxy or gateway. Set it with SetBaseURL.
+	BaseURL string
+
+	// Limiter paces requests to the provider's rate limit. Nil means unlimited.
+	Limiter *RateLimiter
+
+	// MaxResponseBytes caps the response body size read from the provider.
+	// Zero means DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+
+	// Headers are extra headers sent with every provider request, e.g. for
+	// an API gateway. They cannot replace the handler's own headers.
+	Headers map[string]string
+
+	// Model is the alias or model ID used when the server sends no model
+	// hint. Empty means OPENAI_MODEL.
+	Model string
+
+	// Aliases resolves model hints and Model at request time.
+	Aliases ModelAliases
+
+	// FallbackModel is the alias or model ID a request is sent again with
+	// when the provider says the requested model does not exist. Empty
+	// disables the fallback.
+	FallbackModel string
+
+	// Retry decides which failed provider requests are sent again.
+	Retry RetryPolicy
+
+	// MaxTokens caps each request's max_tokens at the selected model's
+	// output limit. Nil sends max_tokens unchanged.
+	MaxTokens TokenCeilings
+
+	// Stream requests responses as server-sent events, so text that arrived
+	// before the request was canceled or cut off is returned, marked
+	// partial, instead of an error. Requests offering tools are not streamed.
+	Stream bool
+
+	// SystemPrefix is put before the system prompt of every request, for
+	// framing this provider's models respond better to. See SystemPrompts.
+	SystemPrefix string
+}
+
+// OpenAIRequest represents the structure for Chat Completions requests
+type OpenAIRequest struct {
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Messages    []OpenAIMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	Seed        *int            `json:"seed,omitempty"`
+	Tools       []OpenAITool    `json:"tools,omitempty"`
+	// Stream and StreamOptions ask for the response as server-sent
+	// events, with token usage in the last one.
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAITool declares a function the model may call.
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+type OpenAIFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// OpenAIToolCall is a function call in a Chat Completions response. The
+// arguments are a JSON-encoded string.
+type OpenAIToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// OpenAIResponse represents the structure for Chat Completions responses
+type OpenAIResponse struct {
+	ID      string         `json:"id"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// OpenAIChoice is one completion in a Chat Completions response.
+type OpenAIChoice struct {
+	Message struct {
+		Role      string           `json:"role"`
+		Content   string           `json:"content"`
+		ToolCalls []OpenAIToolCall `json:"tool_calls"`
+	} `json:"message"`
+	FinishReason string `json:"finish_reason"`
+}
+
+func NewOpenAISamplingHandler(apiKey string) *OpenAISamplingHandler {
+	return &OpenAISamplingHandler{
+		APIKey:    apiKey,
+		BaseURL:   OPENAI_BASE_URL,
+		Retry:     DefaultRetryPolicy,
+		Aliases:   DefaultOpenAIAliases,
+		MaxTokens: DefaultTokenCeilings,
+		HTTPClient: &http.Client{
+			Timeout:   2 * time.Minute,
+			Transport: newTransport(),
+		},
+	}
+}
+
+// fallbackModel resolves FallbackModel when it is an alias. Any other name
+// is used as a model ID, so the fallback can be a model no alias names.
+func (h *OpenAISamplingHandler) fallbackModel() string {
+	if model, ok := h.Aliases[h.FallbackModel]; ok {
+		return model
+	}
+	return h.FallbackModel
+}
+
+// SetBaseURL validates and sets the provider base URL.
+func (h *OpenAISamplingHandler) SetBaseURL(raw string) error {
+	baseURL, err := ValidateBaseURL(raw)
+	if err != nil {
+		return err
+	}
+	h.BaseURL = baseURL
+	return nil
+}
+
+func (h *OpenAISamplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	log.Printf("📨 Received sampling request with %d messages", len(request.Messages))
+
+	if len(request.Messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	apiKey := h.APIKey
+	if callerKey := metadataString(request.Metadata, MetadataAPIKey); callerKey != "" {
+		apiKey = callerKey
+		log.Println("Using caller-supplied API key for this request")
+	}
+
+	// The system prompt is the first message in Chat Completions
+	var messages []OpenAIMessage
+	if system := prefixSystemPrompt(h.SystemPrefix, request.SystemPrompt); system != "" {
+		messages = append(messages, OpenAIMessage{Role: "system", Content: system})
+	}
+	for _, mcpMsg := range request.Messages {
+		var content any
+
+		switch mcpContent := mcpMsg.Content.(type) {
+		case mcp.TextContent:
+			content = mcpContent.Text
+		case mcp.ImageContent:
+			content = []OpenAIContentPart{{
+				Type:     "image_url",
+				ImageURL: &OpenAIImageURL{URL: fmt.Sprintf("data:%s;base64,%s", mcpContent.MIMEType, mcpContent.Data)},
+			}}
+		default:
+			content = fmt.Sprintf("%v", mcpContent)
+		}
+
+		role := "user"
+		if mcpMsg.Role == mcp.RoleAssistant {
+			role = "assistant"
+		}
+		messages = append(messages, OpenAIMessage{Role: role, Content: content})
+	}
+
+	openaiReq := OpenAIRequest{
+		Model:       selectModel(request, h.Aliases, h.Model, OPENAI_MODEL),
+		MaxTokens:   request.MaxTokens,
+		Messages:    messages,
+		Temperature: request.Temperature,
+	}
+	if seed, ok := metadataInt(request.Metadata, MetadataSeed); ok {
+		openaiReq.Seed = &seed
+	}
+	for _, tool := range metadataTools(request.Metadata) {
+		openaiReq.Tools = append(openaiReq.Tools, OpenAITool{
+			Type:     "function",
+			Function: OpenAIFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.InputSchema},
+		})
+	}
+
+	if h.Stream && len(openaiReq.Tools) == 0 {
+		openaiReq.Stream = true
+		openaiReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	openaiReq.MaxTokens = h.MaxTokens.clamp(openaiReq.Model, openaiReq.MaxTokens)
+
+	log.Printf("Sending request to OpenAI API (model: %s, tokens: %d)", openaiReq.Model, openaiReq.MaxTokens)
+
+	requested := openaiReq.Model
+	resp, model, err := sendWithFallback(ctx, h.HTTPClient, h.Limiter, h.Retry, requested, h.fallbackModel(), func(model string) (*http.Request, error) {
+		openaiReq.Model = model
+		if model != requested {
+			openaiReq.MaxTokens = h.MaxTokens.clamp(model, request.MaxTokens)
+		}
+		reqBody, err := json.Marshal(openaiReq)
+		if err != nil {
+			return nil, err
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", h.BaseURL+"/v1/chat/completions", bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+		setExtraHeaders(httpReq, h.Headers)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var openaiResp OpenAIResponse
+	var respBody []byte
+	var interrupted error
+	if openaiReq.Stream {
+		openaiResp, respBody, interrupted, err = readOpenAIStream(resp.Body, h.MaxResponseBytes)
+		if err != nil {
+			if typeErr := unexpectedContentType(resp, respBody, h.APIKey, apiKey); typeErr != nil {
+				return nil, typeErr
+			}
+			return nil, err
+		}
+	} else {
+		respBody, err = readResponse(resp.Body, h.MaxResponseBytes)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(respBody, &openaiResp); err != nil {
+			if typeErr := unexpectedContentType(resp, respBody, h.APIKey, apiKey); typeErr != nil {
+				return nil, typeErr
+			}
+			return nil, fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	if len(openaiResp.Choices) == 0 {
+		return nil, fmt.Errorf("response contained no choices")
+	}
+	choice := openaiResp.Choices[0]
+
+	log.Printf("Received response from OpenAI API (model: %s, input tokens: %d, output tokens: %d)",
+		openaiResp.Model, openaiResp.Usage.PromptTokens, openaiResp.Usage.CompletionTokens)
+
+	result := &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role: mcp.RoleAssistant,
+			Content: mcp.TextContent{
+				Type: "text",
+				Text: choice.Message.Content,
+			},
+		},
+		Model:      openaiResp.Model,
+		StopReason: choice.FinishReason,
+	}
+
+	meta := map[string]any{}
+	if model != requested {
+		meta[MetadataModelFallback] = map[string]any{"requested": requested, "used": model}
+	}
+	if interrupted != nil {
+		markPartial(meta, interrupted, len(choice.Message.Content))
+	}
+	if len(choice.Message.ToolCalls) > 0 {
+		call := choice.Message.ToolCalls[0]
+		toolCall := ToolCall{ID: call.ID, Name: call.Function.Name}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &toolCall.Input); err != nil {
+			return nil, fmt.Errorf("failed to decode tool call arguments: %v", err)
+		}
+		log.Printf("Model requested tool: %s", toolCall.Name)
+		meta[MetadataToolUse] = toolCall.toMetaMap()
+	}
+	if metadataBool(request.Metadata, MetadataDebugRaw) {
+		meta[MetadataRawResponse] = Redact(string(respBody), h.APIKey, apiKey)
+	}
+	if len(meta) > 0 {
+		result.Meta = mcp.NewMetaFromMap(meta)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// cacheControlOf returns the cache_control type of a content block, or "".
+func cacheControlOf(block any) string {
+	control, _ := block.(map[string]any)["cache_control"].(map[string]any)
+	kind, _ := control["type"].(string)
+	return kind
+}
+
+func TestPromptCachingMarksSystemPromptAndLargeBlocks(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.PromptCaching = true
+
+	request := samplingRequest(strings.Repeat("a", MinCacheableChars), nil)
+	request.SystemPrompt = "Summarize this."
+	request.Messages = append(request.Messages, mcp.SamplingMessage{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: "short"}})
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	body := p.Requests()[0].JSON(t)
+	system, _ := body["system"].([]any)
+	if len(system) != 1 || cacheControlOf(system[0]) != "ephemeral" {
+		t.Errorf("system prompt is not a marked block: %v", body["system"])
+	}
+	messages, _ := body["messages"].([]any)
+	large := messages[0].(map[string]any)["content"].([]any)[0]
+	small := messages[1].(map[string]any)["content"].([]any)[0]
+	if cacheControlOf(large) != "ephemeral" {
+		t.Errorf("large block is not marked: %v", large)
+	}
+	if cacheControlOf(small) != "" {
+		t.Errorf("block below MinCacheableChars is marked: %v", small)
+	}
+}
+
+func TestPromptCachingRespectsBreakpointLimit(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.PromptCaching = true
+
+	request := samplingRequest(strings.Repeat("a", MinCacheableChars), nil)
+	request.SystemPrompt = "Summarize these."
+	for range maxCacheBreakpoints {
+		request.Messages = append(request.Messages, request.Messages[0])
+	}
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	body := p.Requests()[0].JSON(t)
+	marked := 0
+	if system, ok := body["system"].([]any); ok && cacheControlOf(system[0]) != "" {
+		marked++
+	}
+	for _, message := range body["messages"].([]any) {
+		for _, block := range message.(map[string]any)["content"].([]any) {
+			if cacheControlOf(block) != "" {
+				marked++
+			}
+		}
+	}
+	if marked != maxCacheBreakpoints {
+		t.Errorf("request has %d cache_control markers, want %d", marked, maxCacheBreakpoints)
+	}
+}
+
+func TestWithoutPromptCachingNothingIsMarked(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+
+	request := samplingRequest(strings.Repeat("a", MinCacheableChars), nil)
+	request.SystemPrompt = "Summarize this."
+	result, err := h.CreateMessage(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw := string(p.Requests()[0].Body); strings.Contains(raw, "cache_control") {
+		t.Errorf("request carries cache_control without prompt caching:\n%s", raw)
+	}
+	if system := p.Requests()[0].JSON(t)["system"]; system != "Summarize this." {
+		t.Errorf("system prompt sent as %v, want a plain string", system)
+	}
+	if result.Meta != nil && result.Meta.AdditionalFields[MetadataCacheUsage] != nil {
+		t.Errorf("result reports cache usage without prompt caching")
+	}
+}
+
+func TestPromptCachingReportsCacheUsage(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AnthropicResponse{
+			Type:    "message",
+			Role:    "assistant",
+			Content: []AnthropicTextContent{{Type: "text", Text: "ok"}},
+			Model:   "claude-test",
+			Usage:   AnthropicUsage{InputTokens: 10, OutputTokens: 5, CacheCreationInputTokens: 1200, CacheReadInputTokens: 3400},
+		})
+	})
+	h := newTestAnthropic(p)
+	h.PromptCaching = true
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Meta == nil {
+		t.Fatal("result has no _meta")
+	}
+	usage, _ := result.Meta.AdditionalFields[MetadataCacheUsage].(map[string]any)
+	if usage["cache_creation_input_tokens"] != 1200 || usage["cache_read_input_tokens"] != 3400 {
+		t.Errorf("cache usage is %v", usage)
+	}
+}
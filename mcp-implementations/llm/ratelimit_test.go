@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPacesToRate(t *testing.T) {
+	l := NewRateLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 6 {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The burst of 2 goes at once; the other 4 wait 50ms each at 20 rps.
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("6 requests took %v, want about 200ms at 20 rps with burst 2", elapsed)
+	}
+}
+
+func TestRateLimiterBurstIsImmediate(t *testing.T) {
+	l := NewRateLimiter(1, 3)
+
+	start := time.Now()
+	for range 3 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("a full burst took %v, want no wait", elapsed)
+	}
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	l := NewRateLimiter(0.1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait returned %v, want the context's deadline error", err)
+	}
+}
+
+func TestAnthropicHandlerWaitsForLimiter(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.Limiter = NewRateLimiter(20, 1)
+
+	start := time.Now()
+	for range 3 {
+		if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 100ms at 20 rps", elapsed)
+	}
+	if n := len(p.Requests()); n != 3 {
+		t.Errorf("provider saw %d requests, want 3", n)
+	}
+}
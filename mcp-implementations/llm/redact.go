@@ -0,0 +1,24 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretFieldPattern matches JSON string fields whose names suggest they
+// hold a credential.
+var secretFieldPattern = regexp.MustCompile(`(?i)("(?:api[_-]?key|x-api-key|authorization|access[_-]?token|secret)"\s*:\s*)"[^"]*"`)
+
+// Redact masks credential-looking JSON fields and every literal occurrence
+// of the given secrets, so the text is safe to log or return to a caller.
+func Redact(text string, secrets ...string) string {
+	text = secretFieldPattern.ReplaceAllString(text, `$1"`+redacted+`"`)
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
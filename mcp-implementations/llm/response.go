@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxResponseBytes bounds how much of a provider response a handler
+// reads. A normal completion is a few kilobytes; a runaway response should
+// fail fast instead of being buffered whole.
+const DefaultMaxResponseBytes = 10 << 20
+
+// ResponseTooLargeError is returned when a response body exceeds the limit.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("provider response exceeded %d bytes", e.Limit)
+}
+
+// readResponse reads at most limit bytes of a response body. A limit of
+// zero or less means DefaultMaxResponseBytes.
+func readResponse(body io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+
+	// Read one byte past the limit to tell "exactly full" from "too large"
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, &ResponseTooLargeError{Limit: limit}
+	}
+	return data, nil
+}
+
+// maxSnippetBytes bounds how much of an unexpected response body is quoted
+// in an error.
+const maxSnippetBytes = 300
+
+// UnexpectedContentTypeError is returned when a response that should be
+// JSON or an event stream could not be read and was something else, most
+// often an HTML error page from a proxy or gateway in front of the
+// provider.
+type UnexpectedContentTypeError struct {
+	ContentType string
+	// Snippet is the start of the body, with markup and whitespace
+	// collapsed and credentials redacted
+	Snippet string
+}
+
+func (e *UnexpectedContentTypeError) Error() string {
+	return fmt.Sprintf("provider returned %s instead of JSON (is a proxy in the way?): %s", e.ContentType, e.Snippet)
+}
+
+// htmlTag matches one HTML tag, so a snippet of an error page reads as its
+// text.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// unexpectedContentType explains a response body that failed to parse when
+// its Content-Type was neither JSON nor an event stream. It returns nil when
+// the type was one a provider sends, leaving the parse error to speak.
+func unexpectedContentType(resp *http.Response, body []byte, secrets ...string) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/event-stream") {
+		return nil
+	}
+	if contentType == "" {
+		contentType = "a response with no content type"
+	}
+
+	text := string(body)
+	if strings.Contains(mediaType, "html") {
+		text = htmlTag.ReplaceAllString(text, " ")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > maxSnippetBytes {
+		cut := maxSnippetBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "..."
+	}
+	if text == "" {
+		text = "(empty body)"
+	}
+	return &UnexpectedContentTypeError{ContentType: contentType, Snippet: Redact(text, secrets...)}
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReadResponseLimit(t *testing.T) {
+	data, err := readResponse(strings.NewReader("0123456789"), 10)
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("a body exactly at the limit: %q, %v", data, err)
+	}
+
+	_, err = readResponse(strings.NewReader("0123456789X"), 10)
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 10 {
+		t.Errorf("a body over the limit returned %v, want a ResponseTooLargeError", err)
+	}
+}
+
+func TestAnthropicRejectsOversizedResponse(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeAnthropicAnswer(w, strings.Repeat("runaway ", 1000))
+	})
+	h := newTestAnthropic(p)
+	h.MaxResponseBytes = 1024
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("CreateMessage returned %v, want a ResponseTooLargeError", err)
+	}
+	if !strings.Contains(err.Error(), "exceeded 1024 bytes") {
+		t.Errorf("error %q does not name the limit", err)
+	}
+}
+
+func TestOpenAIRejectsOversizedResponse(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, strings.Repeat("runaway ", 1000))
+	})
+	h := newTestOpenAI(p)
+	h.MaxResponseBytes = 1024
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("CreateMessage returned %v, want a ResponseTooLargeError", err)
+	}
+}
+
+// gatewayErrorPage is what a proxy in front of a provider might serve. It
+// quotes the handler's key, which must not reach the error.
+const gatewayErrorPage = `<!DOCTYPE html>
+<html><head><title>502 Bad Gateway</title></head>
+<body><center><h1>502 Bad Gateway</h1></center>
+<p>upstream rejected key handler-key</p>
+<hr><center>nginx</center></body></html>`
+
+func TestHTMLErrorPageIsExplained(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(gatewayErrorPage))
+	})
+	handlers := map[string]interface {
+		CreateMessage(context.Context, mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+	}{}
+	for _, stream := range []bool{false, true} {
+		anthropic, openai := newTestAnthropic(p), newTestOpenAI(p)
+		anthropic.Stream, openai.Stream = stream, stream
+		handlers[fmt.Sprintf("anthropic stream=%t", stream)] = anthropic
+		handlers[fmt.Sprintf("openai stream=%t", stream)] = openai
+	}
+
+	for name, h := range handlers {
+		_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+		var typeErr *UnexpectedContentTypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("%s: CreateMessage returned %v, want an UnexpectedContentTypeError", name, err)
+			continue
+		}
+		if typeErr.ContentType != "text/html; charset=utf-8" {
+			t.Errorf("%s: content type %q", name, typeErr.ContentType)
+		}
+		if want := "502 Bad Gateway 502 Bad Gateway upstream rejected key [REDACTED] nginx"; typeErr.Snippet != want {
+			t.Errorf("%s: snippet %q, want %q", name, typeErr.Snippet, want)
+		}
+		if strings.Contains(err.Error(), "handler-key") {
+			t.Errorf("%s: error leaks the API key: %v", name, err)
+		}
+	}
+}
+
+func TestMalformedJSONKeepsDecodeError(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": [`))
+	})
+
+	_, err := newTestAnthropic(p).CreateMessage(context.Background(), samplingRequest("hello", nil))
+	var typeErr *UnexpectedContentTypeError
+	if err == nil || errors.As(err, &typeErr) || !strings.Contains(err.Error(), "failed to decode response") {
+		t.Errorf("CreateMessage returned %v, want the decode error", err)
+	}
+}
+
+func TestUnexpectedContentTypeSnippet(t *testing.T) {
+	respond := func(contentType string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		if contentType != "" {
+			resp.Header.Set("Content-Type", contentType)
+		}
+		return resp
+	}
+
+	for _, contentType := range []string{"application/json", "application/problem+json", "text/event-stream; charset=utf-8"} {
+		if err := unexpectedContentType(respond(contentType), []byte("<html>")); err != nil {
+			t.Errorf("%s is a type providers send, got %v", contentType, err)
+		}
+	}
+
+	err := unexpectedContentType(respond("text/plain"), []byte(strings.Repeat("é", maxSnippetBytes)))
+	snippet := err.(*UnexpectedContentTypeError).Snippet
+	if !strings.HasSuffix(snippet, "...") || len(snippet) > maxSnippetBytes+3 || !utf8.ValidString(snippet) {
+		t.Errorf("long body was not cut cleanly: %d bytes, %q", len(snippet), snippet[len(snippet)-10:])
+	}
+
+	// Plain text keeps its angle brackets; only HTML is stripped
+	err = unexpectedContentType(respond("text/plain"), []byte("a <b> c"))
+	if got := err.(*UnexpectedContentTypeError).Snippet; got != "a <b> c" {
+		t.Errorf("plain text snippet = %q", got)
+	}
+
+	err = unexpectedContentType(respond(""), nil)
+	if got := err.Error(); got != "provider returned a response with no content type instead of JSON (is a proxy in the way?): (empty body)" {
+		t.Errorf("empty untyped response: %q", got)
+	}
+}
@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryPolicy is the retry policy handlers start with.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 2, Backoff: time.Second}
+
+// maxRetryAfter caps how long a provider's Retry-After header can make a
+// handler wait.
+const maxRetryAfter = time.Minute
+
+// RetryPolicy decides which failed provider requests are sent again.
+// Connection failures and 429/5xx responses are retried; the caller
+// cancelling or running out of time never is, since the caller has already
+// given up on the answer.
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is retried after the first
+	// attempt. Zero disables retries.
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled for each one after.
+	Backoff time.Duration
+	// RetryTimeouts also retries requests that hit the HTTP client's
+	// timeout. Off by default: a provider too slow once is usually slow
+	// again, and each attempt costs the full timeout.
+	RetryTimeouts bool
+}
+
+// ContextError is returned when the caller's context is cancelled or its
+// deadline passes. It is never retried.
+type ContextError struct {
+	Err error
+}
+
+func (e *ContextError) Error() string { return fmt.Sprintf("request cancelled: %v", e.Err) }
+func (e *ContextError) Unwrap() error { return e.Err }
+
+// TimeoutError is returned when the provider does not answer within the
+// handler's HTTP client timeout. It is retried only with RetryTimeouts.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string { return fmt.Sprintf("provider timed out: %v", e.Err) }
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ConnectionError is returned when the request could not be sent or its
+// connection failed, e.g. a refused connection or a DNS failure.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string { return fmt.Sprintf("failed to send request: %v", e.Err) }
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// StatusError is returned when the provider answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the provider's requested wait, if it sent one.
+	RetryAfter time.Duration
+	// Body is the start of the provider's error response, which names the
+	// problem, e.g. an unknown model.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API request failed with status %d", e.StatusCode)
+}
+
+// Temporary reports whether the status is worth retrying: rate limiting
+// and server-side failures, not errors in the request itself.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// retryable reports whether policy allows retrying err.
+func (p RetryPolicy) retryable(err error) bool {
+	var statusErr *StatusError
+	var timeoutErr *TimeoutError
+	var connErr *ConnectionError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Temporary()
+	case errors.As(err, &timeoutErr):
+		return p.RetryTimeouts
+	case errors.As(err, &connErr):
+		return true
+	}
+	return false
+}
+
+// classifyTransportError sorts an error from http.Client.Do into the
+// caller giving up, the provider timing out, or the connection failing.
+func classifyTransportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return &ContextError{Err: ctx.Err()}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &TimeoutError{Err: err}
+	}
+	return &ConnectionError{Err: err}
+}
+
+// sendWithRetry sends the request built by newRequest until it gets a 200
+// response, the policy says to stop, or ctx is done. newRequest is called
+// for every attempt, since a sent request's body is consumed. Each attempt
+// waits for limiter, if set. The returned error is a *ContextError,
+// *TimeoutError, *ConnectionError or *StatusError.
+func sendWithRetry(ctx context.Context, client *http.Client, limiter *RateLimiter, policy RetryPolicy, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, &ContextError{Err: err}
+			}
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			err = classifyTransportError(ctx, err)
+		} else if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			err = &StatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp), Body: string(body)}
+		} else {
+			return resp, nil
+		}
+
+		if attempt >= policy.MaxRetries || !policy.retryable(err) {
+			return nil, err
+		}
+
+		wait := policy.Backoff << attempt
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+		log.Printf("⚠️  %v; retrying in %v (retry %d of %d)", err, wait, attempt+1, policy.MaxRetries)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &ContextError{Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter reads a Retry-After header given in seconds, capped at
+// maxRetryAfter. HTTP-date values are ignored.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
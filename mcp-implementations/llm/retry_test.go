@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failFirst answers the first n requests with status and the rest with a
+// plain Anthropic answer.
+func failFirst(n, status int) func(w http.ResponseWriter, r *http.Request, body []byte) {
+	calls := 0
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		calls++
+		if calls <= n {
+			http.Error(w, `{"error": "try again"}`, status)
+			return
+		}
+		writeAnthropicAnswer(w, "ok")
+	}
+}
+
+// stall holds each request until the client gives up on it.
+func stall(w http.ResponseWriter, r *http.Request, body []byte) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+	}
+}
+
+func TestRetriesTemporaryStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		p := newFakeProvider(t, failFirst(1, status))
+		h := newTestAnthropic(p)
+		h.Retry = RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+		if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+			t.Errorf("status %d: %v", status, err)
+		}
+		if n := len(p.Requests()); n != 2 {
+			t.Errorf("status %d: sent %d requests, want 2", status, n)
+		}
+	}
+}
+
+func TestDoesNotRetryRequestErrors(t *testing.T) {
+	p := newFakeProvider(t, failFirst(1, http.StatusBadRequest))
+	h := newTestAnthropic(p)
+	h.Retry = RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got %v, want a StatusError with status 400", err)
+	}
+	if statusErr.Body == "" {
+		t.Error("StatusError does not carry the provider's error body")
+	}
+	if n := len(p.Requests()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	p := newFakeProvider(t, failFirst(10, http.StatusBadGateway))
+	h := newTestAnthropic(p)
+	h.Retry = RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("got %v, want a StatusError with status 502", err)
+	}
+	if n := len(p.Requests()); n != 3 {
+		t.Errorf("sent %d requests, want 1 and 2 retries", n)
+	}
+}
+
+func TestProviderTimeoutRetriedOnlyWhenAllowed(t *testing.T) {
+	for _, retryTimeouts := range []bool{false, true} {
+		p := newFakeProvider(t, stall)
+		h := newTestAnthropic(p)
+		h.HTTPClient = &http.Client{Timeout: 20 * time.Millisecond}
+		h.Retry = RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond, RetryTimeouts: retryTimeouts}
+
+		_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("RetryTimeouts=%t: got %v, want a TimeoutError", retryTimeouts, err)
+		}
+		want := 1
+		if retryTimeouts {
+			want = 2
+		}
+		if n := len(p.Requests()); n != want {
+			t.Errorf("RetryTimeouts=%t: sent %d requests, want %d", retryTimeouts, n, want)
+		}
+	}
+}
+
+func TestCallerDeadlineIsNeverRetried(t *testing.T) {
+	p := newFakeProvider(t, stall)
+	h := newTestAnthropic(p)
+	h.Retry = RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond, RetryTimeouts: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := h.CreateMessage(ctx, samplingRequest("hello", nil))
+
+	var contextErr *ContextError
+	if !errors.As(err, &contextErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want a ContextError wrapping the deadline", err)
+	}
+	if n := len(p.Requests()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestConnectionFailureIsRetried(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	h := NewAnthropicSamplingHandler("handler-key")
+	h.BaseURL = closed.URL
+	h.Retry = RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	logs := captureLogs(t)
+
+	_, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("got %v, want a ConnectionError", err)
+	}
+	if !h.Retry.retryable(err) {
+		t.Error("a connection failure is not retryable")
+	}
+	if got := strings.Count(logs.String(), "retrying in"); got != 2 {
+		t.Errorf("logged %d retries, want 2", got)
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"100000":                        maxRetryAfter,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0,
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if value != "" {
+			resp.Header.Set("Retry-After", value)
+		}
+		if got := retryAfter(resp); got != want {
+			t.Errorf("Retry-After %q = %v, want %v", value, got, want)
+		}
+	}
+}
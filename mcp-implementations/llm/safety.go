@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// SafetySettings maps Gemini harm categories to the threshold at which the
+// provider blocks content, e.g. "HARM_CATEGORY_HARASSMENT" to
+// "BLOCK_ONLY_HIGH". Categories left out keep the provider's default.
+type SafetySettings map[string]string
+
+// harmCategories maps the short category names a SafetySettings file may
+// use to the provider's names.
+var harmCategories = map[string]string{
+	"harassment":        "HARM_CATEGORY_HARASSMENT",
+	"hate":              "HARM_CATEGORY_HATE_SPEECH",
+	"hate_speech":       "HARM_CATEGORY_HATE_SPEECH",
+	"sexually_explicit": "HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"dangerous":         "HARM_CATEGORY_DANGEROUS_CONTENT",
+	"dangerous_content": "HARM_CATEGORY_DANGEROUS_CONTENT",
+	"civic_integrity":   "HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+// HarmThresholds are the thresholds a SafetySettings file can set, from
+// least to most blocking.
+var HarmThresholds = []string{"OFF", "BLOCK_NONE", "BLOCK_ONLY_HIGH", "BLOCK_MEDIUM_AND_ABOVE", "BLOCK_LOW_AND_ABOVE"}
+
+// LoadSafetySettings reads a JSON object of harm category to threshold from
+// path. Categories may be short names such as "harassment" or "hate", or
+// the provider's HARM_CATEGORY_* names; thresholds are case-insensitive.
+// Unknown names are an error, so a typo does not silently keep a default.
+func LoadSafetySettings(path string) (SafetySettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fromFile map[string]string
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	settings := SafetySettings{}
+	for name, threshold := range fromFile {
+		category, ok := harmCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown harm category %q", path, name)
+		}
+		threshold = strings.ToUpper(threshold)
+		if !slices.Contains(HarmThresholds, threshold) {
+			return nil, fmt.Errorf("%s: unknown threshold %q for %q (use %s)", path, threshold, name, strings.Join(HarmThresholds, ", "))
+		}
+		settings[category] = threshold
+	}
+	return settings, nil
+}
+
+// harmCategory resolves a short or full category name to the provider's name.
+func harmCategory(name string) (string, bool) {
+	if category, ok := harmCategories[strings.ToLower(name)]; ok {
+		return category, true
+	}
+	category := strings.ToUpper(name)
+	for _, known := range harmCategories {
+		if known == category {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// list returns the settings in the request's form, sorted by category so
+// identical settings always produce the same request body.
+func (s SafetySettings) list() []GeminiSafetySetting {
+	var list []GeminiSafetySetting
+	for _, category := range slices.Sorted(maps.Keys(s)) {
+		list = append(list, GeminiSafetySetting{Category: category, Threshold: s[category]})
+	}
+	return list
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSafetyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "safety.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSafetySettingsNormalizesNames(t *testing.T) {
+	path := writeSafetyFile(t, `{"harassment": "block_only_high", "hate": "BLOCK_NONE", "HARM_CATEGORY_DANGEROUS_CONTENT": "Off"}`)
+
+	settings, err := LoadSafetySettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SafetySettings{
+		"HARM_CATEGORY_HARASSMENT":        "BLOCK_ONLY_HIGH",
+		"HARM_CATEGORY_HATE_SPEECH":       "BLOCK_NONE",
+		"HARM_CATEGORY_DANGEROUS_CONTENT": "OFF",
+	}
+	if len(settings) != len(want) {
+		t.Fatalf("settings = %v, want %v", settings, want)
+	}
+	for category, threshold := range want {
+		if settings[category] != threshold {
+			t.Errorf("%s = %q, want %q", category, settings[category], threshold)
+		}
+	}
+}
+
+func TestLoadSafetySettingsRejectsUnknownNames(t *testing.T) {
+	for _, content := range []string{
+		`{"harasment": "BLOCK_NONE"}`,
+		`{"harassment": "BLOCK_SOME"}`,
+	} {
+		_, err := LoadSafetySettings(writeSafetyFile(t, content))
+		if err == nil || !strings.Contains(err.Error(), "unknown") {
+			t.Errorf("%s: err = %v, want an unknown-name error", content, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAIForwardsSeed(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		writeOpenAIAnswer(w, "ok")
+	})
+	h := newTestOpenAI(p)
+
+	// Metadata decoded from JSON carries numbers as float64
+	for _, seed := range []any{42, float64(42)} {
+		if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataSeed: seed})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	for i := range 2 {
+		if seed := requests[i].JSON(t)["seed"]; seed != float64(42) {
+			t.Errorf("request %d sent seed %v, want 42", i, seed)
+		}
+	}
+	if seed, ok := requests[2].JSON(t)["seed"]; ok {
+		t.Errorf("request without a seed sent seed %v", seed)
+	}
+}
+
+func TestAnthropicIgnoresSeed(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataSeed: 42})); err != nil {
+		t.Fatalf("a seed should be ignored, not fail the request: %v", err)
+	}
+	if seed, ok := p.Requests()[0].JSON(t)["seed"]; ok {
+		t.Errorf("Anthropic request sent seed %v, which the API does not accept", seed)
+	}
+}
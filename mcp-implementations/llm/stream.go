@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// errStreamEnded reports a stream that stopped without its final event.
+var errStreamEnded = fmt.Errorf("stream ended before the response was complete")
+
+// readEvents reads a server-sent events body, calling onData with the data
+// of each event until onData returns done or an error, or the body ends.
+// Everything read is kept in raw, for debug_raw, up to limit bytes.
+func readEvents(body io.Reader, limit int64, raw *bytes.Buffer, onData func(data string) (done bool, err error)) error {
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	scanner := bufio.NewScanner(io.TeeReader(io.LimitReader(body, limit+1), raw))
+	scanner.Buffer(make([]byte, 64*1024), int(min(limit+1, 16<<20)))
+
+	var data []string
+	for scanner.Scan() {
+		if int64(raw.Len()) > limit {
+			return &ResponseTooLargeError{Limit: limit}
+		}
+		line := scanner.Text()
+		if line != "" {
+			// Event names and comments are ignored; the data says what it is
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		done, err := onData(strings.Join(data, "\n"))
+		if done || err != nil {
+			return err
+		}
+		data = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if int64(raw.Len()) > limit {
+		return &ResponseTooLargeError{Limit: limit}
+	}
+	return errStreamEnded
+}
+
+// markPartial records in a result's _meta that the stream was interrupted
+// after chars characters of text.
+func markPartial(meta map[string]any, interrupted error, chars int) {
+	log.Printf("⚠️  Response stream interrupted after %d characters, returning the partial text: %v", chars, interrupted)
+	meta[MetadataPartial] = map[string]any{"reason": interrupted.Error()}
+}
+
+// anthropicStreamEvent is any event of an Anthropic Messages stream. Each
+// type uses a few of the fields.
+type anthropicStreamEvent struct {
+	Type    string            `json:"type"`
+	Message AnthropicResponse `json:"message"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage AnthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readAnthropicStream assembles a streamed Anthropic response as if it had
+// been sent whole, along with the raw stream. When the stream breaks off
+// after some text arrived, the response holds that text and interrupted
+// says why; with no text yet, the failure is returned as err.
+func readAnthropicStream(body io.Reader, limit int64) (resp AnthropicResponse, raw []byte, interrupted, err error) {
+	var buf bytes.Buffer
+	var text strings.Builder
+	streamErr := readEvents(body, limit, &buf, func(data string) (bool, error) {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return false, fmt.Errorf("failed to decode stream event: %v", err)
+		}
+		switch event.Type {
+		case "message_start":
+			resp = event.Message
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				text.WriteString(event.Delta.Text)
+			}
+		case "message_delta":
+			resp.StopReason = event.Delta.StopReason
+			resp.Usage.OutputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("provider stream error (%s): %s", event.Error.Type, event.Error.Message)
+		}
+		return false, nil
+	})
+
+	resp.Content = []AnthropicTextContent{{Type: "text", Text: text.String()}}
+	if streamErr != nil && text.Len() == 0 {
+		return resp, buf.Bytes(), nil, streamErr
+	}
+	return resp, buf.Bytes(), streamErr, nil
+}
+
+// openAIStreamChunk is one chunk of a Chat Completions stream. The last
+// chunk before [DONE] carries only usage, when stream_options asks for it.
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readOpenAIStream assembles a streamed Chat Completions response like
+// readAnthropicStream does.
+func readOpenAIStream(body io.Reader, limit int64) (resp OpenAIResponse, raw []byte, interrupted, err error) {
+	var buf bytes.Buffer
+	var text strings.Builder
+	var finishReason string
+	streamErr := readEvents(body, limit, &buf, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("failed to decode stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return false, fmt.Errorf("provider stream error (%s): %s", chunk.Error.Type, chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			resp.Model = chunk.Model
+		}
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.Content)
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			resp.Usage.PromptTokens = chunk.Usage.PromptTokens
+			resp.Usage.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		return false, nil
+	})
+
+	resp.Choices = make([]OpenAIChoice, 1)
+	resp.Choices[0].Message.Role = "assistant"
+	resp.Choices[0].Message.Content = text.String()
+	resp.Choices[0].FinishReason = finishReason
+	if streamErr != nil && text.Len() == 0 {
+		return resp, buf.Bytes(), nil, streamErr
+	}
+	return resp, buf.Bytes(), streamErr, nil
+}
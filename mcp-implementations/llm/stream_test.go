@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// anthropicEvents are the events of a streamed Anthropic answer, "Hello"
+// and " world", without the closing events.
+var anthropicEvents = []string{
+	`{"type": "message_start", "message": {"id": "msg_test", "type": "message", "role": "assistant", "model": "claude-test", "usage": {"input_tokens": 10}}}`,
+	`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+	`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hello"}}`,
+	`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": " world"}}`,
+}
+
+// anthropicEnd are the events that finish a streamed Anthropic answer.
+var anthropicEnd = []string{
+	`{"type": "content_block_stop", "index": 0}`,
+	`{"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 2}}`,
+	`{"type": "message_stop"}`,
+}
+
+// openAIChunks are the chunks of a streamed OpenAI answer, "Hello" and
+// " world", without the finish reason and [DONE].
+var openAIChunks = []string{
+	`{"model": "gpt-test", "choices": [{"index": 0, "delta": {"role": "assistant", "content": "Hello"}}]}`,
+	`{"model": "gpt-test", "choices": [{"index": 0, "delta": {"content": " world"}}]}`,
+}
+
+// streamEvents writes events as server-sent events, flushing after each.
+// With hang set it then waits for the client to go away instead of
+// ending the response.
+func streamEvents(events []string, hang bool) func(w http.ResponseWriter, r *http.Request, body []byte) {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			w.(http.Flusher).Flush()
+		}
+		if hang {
+			<-r.Context().Done()
+		}
+	}
+}
+
+// cancelAfter wraps a response body and cancels the request once marker
+// has been read, so the stream is cut off at a known point.
+type cancelAfter struct {
+	io.ReadCloser
+	marker string
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	read   bytes.Buffer
+}
+
+func (c *cancelAfter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.mu.Lock()
+	c.read.Write(p[:n])
+	if strings.Contains(c.read.String(), c.marker) {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+// cancelingTransport cancels each request after its response body has
+// carried marker.
+type cancelingTransport struct {
+	marker string
+	cancel context.CancelFunc
+}
+
+func (t cancelingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &cancelAfter{ReadCloser: resp.Body, marker: t.marker, cancel: t.cancel}
+	return resp, nil
+}
+
+// partialOf returns the _meta.partial of a result, if it has one.
+func partialOf(result *mcp.CreateMessageResult) (map[string]any, bool) {
+	if result.Meta == nil {
+		return nil, false
+	}
+	partial, ok := result.Meta.AdditionalFields[MetadataPartial].(map[string]any)
+	return partial, ok
+}
+
+func TestAnthropicStreamCanceledReturnsPartialText(t *testing.T) {
+	p := newFakeProvider(t, streamEvents(anthropicEvents, true))
+	h := newTestAnthropic(p)
+	h.Stream = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.HTTPClient = &http.Client{Transport: cancelingTransport{marker: " world", cancel: cancel}}
+
+	result, err := h.CreateMessage(ctx, samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatalf("a stream canceled after some text should return it: %v", err)
+	}
+	if text := result.Content.(mcp.TextContent).Text; text != "Hello world" {
+		t.Errorf("partial text = %q, want %q", text, "Hello world")
+	}
+	partial, ok := partialOf(result)
+	if !ok {
+		t.Fatalf("result is not marked partial: %v", result.Meta)
+	}
+	if reason, _ := partial["reason"].(string); !strings.Contains(reason, "context canceled") {
+		t.Errorf("partial reason = %q, want the cancellation", reason)
+	}
+	if stream := p.Requests()[0].JSON(t)["stream"]; stream != true {
+		t.Errorf("request sent stream %v, want true", stream)
+	}
+}
+
+func TestOpenAIStreamCanceledReturnsPartialText(t *testing.T) {
+	p := newFakeProvider(t, streamEvents(openAIChunks, true))
+	h := newTestOpenAI(p)
+	h.Stream = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.HTTPClient = &http.Client{Transport: cancelingTransport{marker: " world", cancel: cancel}}
+
+	result, err := h.CreateMessage(ctx, samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatalf("a stream canceled after some text should return it: %v", err)
+	}
+	if text := result.Content.(mcp.TextContent).Text; text != "Hello world" {
+		t.Errorf("partial text = %q, want %q", text, "Hello world")
+	}
+	if _, ok := partialOf(result); !ok {
+		t.Errorf("result is not marked partial: %v", result.Meta)
+	}
+}
+
+func TestStreamEndedEarlyReturnsPartialText(t *testing.T) {
+	p := newFakeProvider(t, streamEvents(anthropicEvents, false))
+	h := newTestAnthropic(p)
+	h.Stream = true
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, ok := partialOf(result)
+	if !ok || partial["reason"] != errStreamEnded.Error() {
+		t.Errorf("_meta.partial = %v, want the stream ending early", partial)
+	}
+}
+
+func TestStreamCanceledBeforeTextIsAnError(t *testing.T) {
+	p := newFakeProvider(t, streamEvents(anthropicEvents[:2], true))
+	h := newTestAnthropic(p)
+	h.Stream = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.HTTPClient = &http.Client{Transport: cancelingTransport{marker: "content_block_start", cancel: cancel}}
+
+	if result, err := h.CreateMessage(ctx, samplingRequest("hello", nil)); err == nil {
+		t.Errorf("a stream canceled before any text returned %v, want an error", result)
+	}
+}
+
+func TestCompleteStreamMatchesWholeResponse(t *testing.T) {
+	p := newFakeProvider(t, streamEvents(append(append([]string{}, anthropicEvents...), anthropicEnd...), false))
+	h := newTestAnthropic(p)
+	h.Stream = true
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Content.(mcp.TextContent).Text; text != "Hello world" || result.StopReason != "end_turn" || result.Model != "claude-test" {
+		t.Errorf("result = %q, stop %q, model %q", text, result.StopReason, result.Model)
+	}
+	if _, ok := partialOf(result); ok {
+		t.Errorf("a complete stream is marked partial: %v", result.Meta)
+	}
+}
+
+func TestToolRequestsAreNotStreamed(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.Stream = true
+
+	if _, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataTools: listFilesDeclaration})); err != nil {
+		t.Fatal(err)
+	}
+	if stream, ok := p.Requests()[0].JSON(t)["stream"]; ok {
+		t.Errorf("a request offering tools sent stream %v", stream)
+	}
+}
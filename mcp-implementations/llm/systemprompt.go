@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Providers are the provider names a SystemPrompts file can key on.
+var Providers = []string{"anthropic", "openai", "gemini"}
+
+// SystemPrompts maps a provider name to the prefix a handler for that
+// provider puts before every system prompt, e.g. framing one model family
+// responds better to.
+type SystemPrompts map[string]string
+
+// LoadSystemPrompts reads a JSON object of provider name to system prompt
+// prefix from path. Unknown provider names are an error, so a typo does not
+// silently leave a provider unprefixed.
+func LoadSystemPrompts(path string) (SystemPrompts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prompts SystemPrompts
+	if err := json.Unmarshal(data, &prompts); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for provider := range prompts {
+		if !slices.Contains(Providers, provider) {
+			return nil, fmt.Errorf("%s: unknown provider %q (use %s)", path, provider, strings.Join(Providers, ", "))
+		}
+	}
+	return prompts, nil
+}
+
+// prefixSystemPrompt puts prefix before the tool's system prompt, on its
+// own paragraph. Either may be empty.
+func prefixSystemPrompt(prefix, system string) string {
+	prefix = strings.TrimSpace(prefix)
+	switch {
+	case prefix == "":
+		return system
+	case system == "":
+		return prefix
+	}
+	return prefix + "\n\n" + system
+}
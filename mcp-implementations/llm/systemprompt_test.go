@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// systemPrompts are the prefixes the tests configure, distinct per
+// provider so a handler using the other provider's prefix shows.
+var systemPrompts = SystemPrompts{
+	"anthropic": "Follow the output format exactly.",
+	"openai":    "  You are a careful analyst.\n",
+}
+
+// answerEither answers in the format of whichever API was called.
+func answerEither(w http.ResponseWriter, r *http.Request, body []byte) {
+	if strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeOpenAIAnswer(w, "ok")
+		return
+	}
+	writeAnthropicAnswer(w, "ok")
+}
+
+func TestAnthropicAppliesItsSystemPrefix(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.SystemPrefix = systemPrompts["anthropic"]
+
+	request := samplingRequest("hello", nil)
+	request.SystemPrompt = "Summarize this."
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.Requests()[0].JSON(t)["system"]; got != "Follow the output format exactly.\n\nSummarize this." {
+		t.Errorf("system = %q, want the anthropic prefix before the tool's prompt", got)
+	}
+}
+
+func TestOpenAIAppliesItsSystemPrefix(t *testing.T) {
+	p := newFakeProvider(t, answerEither)
+	h := newTestOpenAI(p)
+	h.SystemPrefix = systemPrompts["openai"]
+
+	request := samplingRequest("hello", nil)
+	request.SystemPrompt = "Summarize this."
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := p.Requests()[0].JSON(t)["messages"].([]any)
+	system := messages[0].(map[string]any)
+	if system["role"] != "system" || system["content"] != "You are a careful analyst.\n\nSummarize this." {
+		t.Errorf("first message = %v, want the openai prefix before the tool's prompt", system)
+	}
+}
+
+func TestSystemPrefixWithoutToolPrompt(t *testing.T) {
+	p := newFakeProvider(t, answerEither)
+	anthropic, openai := newTestAnthropic(p), newTestOpenAI(p)
+	anthropic.SystemPrefix, openai.SystemPrefix = systemPrompts["anthropic"], systemPrompts["openai"]
+
+	if _, err := anthropic.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openai.CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	if got := requests[0].JSON(t)["system"]; got != "Follow the output format exactly." {
+		t.Errorf("anthropic system = %q, want the prefix alone", got)
+	}
+	messages := requests[1].JSON(t)["messages"].([]any)
+	if got := messages[0].(map[string]any)["content"]; got != "You are a careful analyst." {
+		t.Errorf("openai system message = %q, want the prefix alone", got)
+	}
+}
+
+func TestNoSystemPrefixLeavesPromptAlone(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	request := samplingRequest("hello", nil)
+	request.SystemPrompt = "Summarize this."
+	if _, err := newTestAnthropic(p).CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestAnthropic(p).CreateMessage(context.Background(), samplingRequest("hello", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := p.Requests()
+	if got := requests[0].JSON(t)["system"]; got != "Summarize this." {
+		t.Errorf("system = %q, want the tool's prompt unchanged", got)
+	}
+	if got, ok := requests[1].JSON(t)["system"]; ok {
+		t.Errorf("a request without any system prompt sent system %q", got)
+	}
+}
+
+func TestSystemPrefixIsCachedWithPrompt(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+	h.SystemPrefix = systemPrompts["anthropic"]
+	h.PromptCaching = true
+
+	request := samplingRequest("hello", nil)
+	request.SystemPrompt = "Summarize this."
+	if _, err := h.CreateMessage(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	system, _ := p.Requests()[0].JSON(t)["system"].([]any)
+	if len(system) != 1 || system[0].(map[string]any)["text"] != "Follow the output format exactly.\n\nSummarize this." {
+		t.Errorf("cached system block = %v, want the prefixed prompt", system)
+	}
+}
+
+func TestLoadSystemPrompts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "system-prompts.json")
+	if err := os.WriteFile(path, []byte(`{"anthropic": "Be precise.", "openai": "Be brief."}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := LoadSystemPrompts(path)
+	if err != nil || prompts["anthropic"] != "Be precise." || prompts["openai"] != "Be brief." {
+		t.Errorf("LoadSystemPrompts = %v, %v", prompts, err)
+	}
+
+	typo := filepath.Join(dir, "typo.json")
+	if err := os.WriteFile(typo, []byte(`{"antropic": "Be precise."}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSystemPrompts(typo); err == nil || !strings.Contains(err.Error(), `unknown provider "antropic" (use anthropic, openai, gemini)`) {
+		t.Errorf("a misspelled provider returned %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`["Be precise."]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSystemPrompts(invalid); err == nil || !strings.Contains(err.Error(), "parsing "+invalid) {
+		t.Errorf("a file that is not an object returned %v", err)
+	}
+}
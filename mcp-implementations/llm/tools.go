@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// ToolSpec is a server tool the sampled model may call, as declared in the
+// sampling request metadata under MetadataTools.
+type ToolSpec struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// ToolCall is a model's request to run a tool, returned to the server in
+// the result _meta under MetadataToolUse. The server runs the tool and
+// samples again with its output.
+type ToolCall struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input"`
+}
+
+// metadataTools reads the declared tools from sampling request metadata.
+// Over HTTP they arrive as decoded JSON, so they are re-encoded into specs.
+func metadataTools(metadata any) []ToolSpec {
+	m, ok := metadata.(map[string]any)
+	if !ok || m[MetadataTools] == nil {
+		return nil
+	}
+	data, err := json.Marshal(m[MetadataTools])
+	if err != nil {
+		return nil
+	}
+	var tools []ToolSpec
+	if err := json.Unmarshal(data, &tools); err != nil {
+		log.Printf("Ignoring malformed tool declarations: %v", err)
+		return nil
+	}
+	return tools
+}
+
+// toMetaMap converts a tool call into the plain map sent in result _meta.
+func (c ToolCall) toMetaMap() map[string]any {
+	return map[string]any{"id": c.ID, "name": c.Name, "input": c.Input}
+}
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+var listFilesDeclaration = []map[string]any{{
+	"name":         "list_files",
+	"description":  "List the files",
+	"input_schema": map[string]any{"type": "object"},
+}}
+
+func TestAnthropicForwardsToolsAndReturnsToolUse(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AnthropicResponse{
+			Type: "message",
+			Role: "assistant",
+			Content: []AnthropicTextContent{
+				{Type: "text", Text: "Let me look."},
+				{Type: "tool_use", ID: "toolu_1", Name: "list_files", Input: map[string]any{"limit": 5.0}},
+			},
+			Model:      "claude-test",
+			StopReason: "tool_use",
+		})
+	})
+	h := newTestAnthropic(p)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataTools: listFilesDeclaration}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tools, _ := p.Requests()[0].JSON(t)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "list_files" {
+		t.Errorf("request sent tools %v, want list_files", tools)
+	}
+	if result.Meta == nil {
+		t.Fatal("result has no _meta for the tool call")
+	}
+	call, _ := result.Meta.AdditionalFields[MetadataToolUse].(map[string]any)
+	if call["id"] != "toolu_1" || call["name"] != "list_files" {
+		t.Errorf("tool_use meta is %v", call)
+	}
+	if input, _ := call["input"].(map[string]any); input["limit"] != 5.0 {
+		t.Errorf("tool input is %v", call["input"])
+	}
+}
+
+func TestOpenAIForwardsToolsAndReturnsToolUse(t *testing.T) {
+	p := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"model": "gpt-test",
+			"choices": []map[string]any{{
+				"message": map[string]any{
+					"role": "assistant",
+					"tool_calls": []map[string]any{{
+						"id":       "call_1",
+						"type":     "function",
+						"function": map[string]any{"name": "list_files", "arguments": `{"limit": 5}`},
+					}},
+				},
+				"finish_reason": "tool_calls",
+			}},
+		})
+	})
+	h := newTestOpenAI(p)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", map[string]any{MetadataTools: listFilesDeclaration}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tools, _ := p.Requests()[0].JSON(t)["tools"].([]any)
+	if len(tools) != 1 {
+		t.Fatalf("request sent tools %v, want list_files", tools)
+	}
+	if function, _ := tools[0].(map[string]any)["function"].(map[string]any); function["name"] != "list_files" {
+		t.Errorf("request sent tool %v, want list_files", tools[0])
+	}
+	if result.Meta == nil {
+		t.Fatal("result has no _meta for the tool call")
+	}
+	call, _ := result.Meta.AdditionalFields[MetadataToolUse].(map[string]any)
+	if call["id"] != "call_1" || call["name"] != "list_files" {
+		t.Errorf("tool_use meta is %v", call)
+	}
+}
+
+func TestWithoutToolsNoToolsAreSent(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	h := newTestAnthropic(p)
+
+	result, err := h.CreateMessage(context.Background(), samplingRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tools, ok := p.Requests()[0].JSON(t)["tools"]; ok {
+		t.Errorf("request without tools sent %v", tools)
+	}
+	if result.Meta != nil && result.Meta.AdditionalFields[MetadataToolUse] != nil {
+		t.Errorf("plain answer carries a tool call: %v", result.Meta.AdditionalFields)
+	}
+}